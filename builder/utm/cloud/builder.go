@@ -35,11 +35,19 @@ func (b *Builder) Prepare(raws ...interface{}) ([]string, []string, error) {
 
 func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook) (packersdk.Artifact, error) {
 	// Create the driver that we'll use to communicate with UTM
-	driver, err := utmcommon.NewDriver()
+	driver, err := utmcommon.NewDriver(&b.config.DriverConfig)
 	if err != nil {
 		return nil, fmt.Errorf("failed creating UTM driver: %s", err)
 	}
 
+	// Emit progress events to events_file, if set
+	events, err := utmcommon.NewEventWriter(b.config.EventsFile)
+	if err != nil {
+		return nil, err
+	}
+	defer events.Close()
+	ui = utmcommon.NewEventUi(ui, events)
+
 	// Setup the state bag
 	state := new(multistep.BasicStateBag)
 	state.Put("config", &b.config)
@@ -47,16 +55,27 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 	state.Put("driver", driver)
 	state.Put("hook", hook)
 	state.Put("ui", ui)
+	if events != nil {
+		state.Put("events", events)
+	}
 
 	// Build the steps.
 	steps := []multistep.Step{
-		&commonsteps.StepDownload{
-			Checksum:    b.config.ISOChecksum,
-			Description: "ISO",
-			Extension:   b.config.TargetExtension,
-			ResultKey:   "iso_path",
-			TargetPath:  b.config.TargetPath,
-			Url:         b.config.ISOUrls,
+		new(utmcommon.StepPreflight),
+		&utmcommon.StepCheckHostMemory{
+			MemorySize:    b.config.MemorySize,
+			MinHostMemory: b.config.MinHostMemory,
+		},
+		&utmcommon.StepDownloadISO{
+			Download: &commonsteps.StepDownload{
+				Checksum:    b.config.ISOChecksum,
+				Description: "ISO",
+				Extension:   b.config.TargetExtension,
+				ResultKey:   "iso_path",
+				TargetPath:  b.config.TargetPath,
+				Url:         b.config.ISOUrls,
+			},
+			Resume: !b.config.DownloadResume.False(),
 		},
 		&commonsteps.StepOutputDir{
 			Force: b.config.PackerForce,
@@ -84,17 +103,39 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 			Hypervisor:     b.config.Hypervisor,
 			KeepRegistered: b.config.KeepRegistered,
 		},
+		&utmcommon.StepConfigureAccelerator{
+			Accelerator: b.config.Accelerator,
+			VMArch:      b.config.VMArch,
+		},
+		&utmcommon.StepConfigureCPU{
+			Model:    b.config.CPUModel,
+			Features: b.config.CPUFeatures,
+		},
+		&utmcommon.StepConfigureBalloon{
+			Enabled: b.config.AddBalloonDevice(),
+			VMArch:  b.config.VMArch,
+		},
 		&utmcommon.StepConfigureQemuArgs{
-			QemuArgs: b.config.QemuArgs,
+			QemuArgs:  b.config.QemuArgs,
+			RNGDevice: b.config.AddRNGDevice(),
+			VMArch:    b.config.VMArch,
+			RTCArg:    b.config.RTCQemuArg(b.config.Comm.Type == "winrm"),
+		},
+		&utmcommon.StepConfigureQemuMonitor{
+			Enabled: b.config.QemuMonitor,
 		},
 		// This step creates a disk from source (cloud image) and attaches it to the VM
 		new(stepCreateCloudDisk),
+		&utmcommon.StepConfigureNetworkAdapters{
+			Adapters:            b.config.NetworkAdapters,
+			CommunicatorAdapter: b.config.CommunicatorAdapter,
+		},
 		&utmcommon.StepPortForwarding{
 			CommConfig:             &b.config.Comm,
 			HostPortMin:            b.config.HostPortMin,
 			HostPortMax:            b.config.HostPortMax,
 			SkipNatMapping:         b.config.SkipNatMapping,
-			ClearNetworkInterfaces: true,
+			ClearNetworkInterfaces: len(b.config.NetworkAdapters) == 0,
 		},
 		// Use this step to pass the cloud-init seed data via cd or http
 		&stepConfigureCloudSeed{
@@ -104,11 +145,23 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 			Message: "UTM API Unavailable: Add a display device to the VM for debugging",
 			NoPause: b.config.DisplayNoPause,
 		},
-		&utmcommon.StepRun{},
+		&utmcommon.StepRun{
+			KeepRunning:    b.config.KeepRunning,
+			KeepRegistered: b.config.KeepRegistered,
+			StartTimeout:   b.config.StartTimeout,
+			BootWait:       b.config.BootWait,
+			QemuLog:        b.config.QemuLog,
+			OutputDir:      b.config.OutputDir,
+		},
 		&utmcommon.StepPause{
 			Message: "Confirm initial boot with cloud-init is complete and VM is running",
 			NoPause: b.config.BootNoPause,
 		},
+		&utmcommon.StepWaitForInstall{
+			Timeout:  b.config.InstallTimeout,
+			Host:     utmcommon.CommHost(b.config.Comm.Host()),
+			CommType: b.config.Comm.Type,
+		},
 		&communicator.StepConnect{
 			Config:    &b.config.Comm,
 			Host:      utmcommon.CommHost(b.config.Comm.Host()),
@@ -116,10 +169,38 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 			SSHPort:   utmcommon.CommPort,
 			WinRMPort: utmcommon.CommPort,
 		},
+		&utmcommon.StepDetectGuestOS{
+			CommType: b.config.Comm.Type,
+		},
+		&utmcommon.StepWaitForNetwork{
+			Enabled:  b.config.WaitForNetwork,
+			Timeout:  b.config.WaitForNetworkTimeout,
+			CommType: b.config.Comm.Type,
+		},
 		&utmcommon.StepUploadVersion{
 			Path: *b.config.UtmVersionFile,
 		},
+		&utmcommon.StepWaitCloudInit{
+			Enabled: b.config.CloudInitWait,
+			Timeout: b.config.CloudInitTimeout,
+		},
 		new(commonsteps.StepProvision),
+		&utmcommon.StepVerifyGuestAdditions{
+			Enabled: b.config.VerifyGuestAdditions,
+		},
+		&utmcommon.StepTestResume{
+			Enabled:  b.config.TestResume,
+			Commands: b.config.TestResumeCommands,
+			Timeout:  b.config.TestResumeTimeout,
+			CommType: b.config.Comm.Type,
+		},
+		&utmcommon.StepSetGuestHostname{
+			Hostname: b.config.GuestHostname,
+		},
+		&utmcommon.StepAddAuthorizedKeys{
+			Keys: b.config.SSHAuthorizedKeys,
+			User: b.config.SSHAuthorizedKeysUser,
+		},
 		&commonsteps.StepCleanupTempKeys{
 			Comm: &b.config.Comm,
 		},
@@ -128,6 +209,9 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 			Timeout:         b.config.ShutdownTimeout,
 			Delay:           b.config.PostShutdownDelay,
 			DisableShutdown: b.config.DisableShutdown,
+			KeepRunning:     b.config.KeepRunning,
+			Suspend:         b.config.SuspendBeforeExport,
+			Bundling:        b.config.UtmBundleConfig,
 		},
 		&utmcommon.StepRemoveDevices{
 			Bundling: b.config.UtmBundleConfig,
@@ -136,6 +220,17 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 			Message: "Make required changes to the VM before export.\nRemove display, Add Serial port, Icon, etc.",
 			NoPause: b.config.ExportNoPause,
 		},
+		&utmcommon.StepCheckExportSpace{
+			OutputDir:  b.config.OutputDir,
+			SkipExport: b.config.SkipExport,
+			MarginMB:   *b.config.ExportSpaceMargin,
+		},
+		&utmcommon.StepVerifyVMConfig{
+			HWConfig:        b.config.HWConfig,
+			Disks:           1 + len(b.config.AdditionalDiskSize),
+			NetworkAdapters: b.config.NetworkAdapters,
+			SkipExport:      b.config.SkipExport,
+		},
 		&utmcommon.StepExport{
 			Format:         b.config.Format,
 			OutputDir:      b.config.OutputDir,
@@ -143,9 +238,23 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 			SkipNatMapping: b.config.SkipNatMapping,
 			SkipExport:     b.config.SkipExport,
 		},
+		&utmcommon.StepResetNVRAM{
+			Enabled:    b.config.ResetNVRAM,
+			SkipExport: b.config.SkipExport,
+		},
+		&utmcommon.StepCopyBundleFiles{
+			Files:      b.config.ExtraBundleFiles,
+			SkipExport: b.config.SkipExport,
+		},
+		new(stepFlattenOverlay),
+		&utmcommon.StepKeepRunning{
+			Enabled:      b.config.KeepRunning,
+			StartTimeout: b.config.StartTimeout,
+		},
 	}
 
 	// Run the steps
+	steps = utmcommon.WithStepEvents(steps, events)
 	b.runner = commonsteps.NewRunnerWithPauseFn(steps, b.config.PackerConfig, ui, state)
 	b.runner.Run(ctx, state)
 
@@ -163,6 +272,14 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 		return nil, errors.New("build was halted")
 	}
 
-	generatedData := map[string]interface{}{"generated_data": state.Get("generated_data")}
-	return utmcommon.NewArtifact(b.config.OutputDir, b.config.VMName, generatedData)
+	vmId, err := utmcommon.GetVMID(state)
+	if err != nil {
+		return nil, err
+	}
+
+	generatedData := utmcommon.ArtifactStateData(state)
+	if b.config.KeepRunning {
+		return utmcommon.NewRunningArtifact(vmId, b.config.VMName, generatedData), nil
+	}
+	return utmcommon.NewArtifact(b.config.OutputDir, vmId, b.config.VMName, generatedData)
 }
@@ -17,20 +17,30 @@ import (
 
 // Config is the configuration structure for the UTM Cloud builder.
 type Config struct {
-	common.PackerConfig            `mapstructure:",squash"`
-	commonsteps.HTTPConfig         `mapstructure:",squash"`
-	commonsteps.ISOConfig          `mapstructure:",squash"`
-	commonsteps.CDConfig           `mapstructure:",squash"`
-	utmcommon.ExportConfig         `mapstructure:",squash"`
-	utmcommon.OutputConfig         `mapstructure:",squash"`
-	utmcommon.ShutdownConfig       `mapstructure:",squash"`
-	utmcommon.CommConfig           `mapstructure:",squash"`
-	utmcommon.HWConfig             `mapstructure:",squash"`
-	utmcommon.UtmVersionConfig     `mapstructure:",squash"`
-	utmcommon.UtmBundleConfig      `mapstructure:",squash"`
-	utmcommon.GuestAdditionsConfig `mapstructure:",squash"`
-	utmcommon.NoPauseConfig        `mapstructure:",squash"`
-	utmcommon.QemuConfig           `mapstructure:",squash"`
+	common.PackerConfig               `mapstructure:",squash"`
+	commonsteps.HTTPConfig            `mapstructure:",squash"`
+	commonsteps.ISOConfig             `mapstructure:",squash"`
+	commonsteps.CDConfig              `mapstructure:",squash"`
+	utmcommon.ExportConfig            `mapstructure:",squash"`
+	utmcommon.OutputConfig            `mapstructure:",squash"`
+	utmcommon.ShutdownConfig          `mapstructure:",squash"`
+	utmcommon.StartConfig             `mapstructure:",squash"`
+	utmcommon.BootWaitConfig          `mapstructure:",squash"`
+	utmcommon.CommConfig              `mapstructure:",squash"`
+	utmcommon.HWConfig                `mapstructure:",squash"`
+	utmcommon.UtmVersionConfig        `mapstructure:",squash"`
+	utmcommon.DriverConfig            `mapstructure:",squash"`
+	utmcommon.UtmBundleConfig         `mapstructure:",squash"`
+	utmcommon.GuestAdditionsConfig    `mapstructure:",squash"`
+	utmcommon.NoPauseConfig           `mapstructure:",squash"`
+	utmcommon.QemuConfig              `mapstructure:",squash"`
+	utmcommon.GuestHostnameConfig     `mapstructure:",squash"`
+	utmcommon.SSHAuthorizedKeysConfig `mapstructure:",squash"`
+	utmcommon.NetworkWaitConfig       `mapstructure:",squash"`
+	utmcommon.TestResumeConfig        `mapstructure:",squash"`
+	utmcommon.NetworkAdaptersConfig   `mapstructure:",squash"`
+	utmcommon.EventsConfig            `mapstructure:",squash"`
+	utmcommon.CloudInitConfig         `mapstructure:",squash"`
 
 	// Set this to true if you would like to use Hypervisor
 	// Defaults to false.
@@ -71,9 +81,22 @@ type Config struct {
 	// Required qemu-img to be installed in the system.
 	ResizeCloudImage bool `mapstructure:"resize_cloud_image" required:"false"`
 
+	// The path to the qcow2 disk of a previously built VM, or to a .utm bundle
+	// with a single qcow2 disk, to use as an immutable base layer instead of
+	// `iso_url`. The VM boots from a copy-on-write overlay backed by this
+	// disk, so provisioning only writes to the overlay and the base is never
+	// modified. Requires qemu-img to be installed in the system.
+	BaseVM string `mapstructure:"base_vm" required:"false"`
+	// Merge the base layer into the overlay of the exported VM, so the
+	// artifact no longer depends on `base_vm`. Only valid with `base_vm`.
+	// Defaults to false, in which case the exported disk keeps referencing
+	// the base disk by its absolute path.
+	Flatten bool `mapstructure:"flatten" required:"false"`
+
 	// Pass cloud-init data to the VM using a CD-ROM. Defaults to false.
-	// If set to true, you must provide cd_files with the path to the cloud-init
-	// data and cd_label with value "cidata".
+	// If set to true, you must provide cd_files or cd_content with the
+	// cloud-init data, and cd_label, which then defaults to "cidata", must be
+	// left to "cidata".
 	// If set to false, you must provide http_directory with the cloud-init data.
 	UseCD bool `mapstructure:"use_cd" required:"false"`
 
@@ -84,6 +107,17 @@ type Config struct {
 	// if the build output is not the resultant image, but created inside the
 	// VM.
 	SkipExport bool `mapstructure:"skip_export" required:"false"`
+	// Set this to true to leave the VM running and registered with UTM once
+	// provisioning is done, for example to keep using it as a development
+	// environment. This implies `skip_export` and `keep_registered`. ISOs
+	// attached during the build are still detached, which requires a restart
+	// of the VM when there are any. Defaults to false.
+	KeepRunning bool `mapstructure:"keep_running" required:"false"`
+	// Set this to false to always download the ISO from scratch. By default an
+	// interrupted download is resumed with HTTP range requests when the server
+	// supports them, and the resumed file is verified against `iso_checksum`.
+	// A resumed file that fails verification is downloaded again from scratch.
+	DownloadResume config.Trilean `mapstructure:"download_resume" required:"false"`
 	// UTM VM icon.
 	VMIcon string `mapstructure:"vm_icon" required:"false"`
 	// QEMU system architecture of the virtual machine.
@@ -100,6 +134,9 @@ type Config struct {
 	// "BUILDNAME" is the name of the build.
 	VMName string `mapstructure:"vm_name" required:"false"`
 
+	// baseDisk is the resolved qcow2 disk of BaseVM
+	baseDisk string
+
 	ctx interpolate.Context
 }
 
@@ -110,6 +147,8 @@ func (c *Config) Prepare(raws ...interface{}) ([]string, error) {
 		InterpolateContext: &c.ctx,
 		InterpolateFilter: &interpolate.RenderFilter{
 			Exclude: []string{
+				"guest_additions_filename",
+				"guest_additions_install_command",
 				"guest_additions_path",
 				"guest_additions_url",
 			},
@@ -123,21 +162,73 @@ func (c *Config) Prepare(raws ...interface{}) ([]string, error) {
 	var errs *packersdk.MultiError
 	warnings := make([]string, 0)
 
-	isoWarnings, isoErrs := c.ISOConfig.Prepare(&c.ctx)
-	warnings = append(warnings, isoWarnings...)
-	errs = packersdk.MultiErrorAppend(errs, isoErrs...)
+	if c.BaseVM != "" {
+		// The base layer replaces the downloaded cloud image
+		if c.RawSingleISOUrl != "" || len(c.ISOUrls) > 0 {
+			errs = packersdk.MultiErrorAppend(
+				errs, errors.New("only one of base_vm or iso_url/iso_urls may be set"))
+		}
+		baseDisk, err := utmcommon.ResolveBaseDisk(c.BaseVM)
+		if err != nil {
+			errs = packersdk.MultiErrorAppend(
+				errs, fmt.Errorf("base_vm is invalid: %s", err))
+		}
+		c.baseDisk = baseDisk
+	} else {
+		// Check the checksum format first, since ISOConfig only finds a bad
+		// one with a less helpful error once it parses it.
+		if err := utmcommon.ValidateChecksum("iso_checksum", c.ISOChecksum); err != nil {
+			errs = packersdk.MultiErrorAppend(errs, err)
+		} else {
+			isoWarnings, isoErrs := c.ISOConfig.Prepare(&c.ctx)
+			warnings = append(warnings, isoWarnings...)
+			errs = packersdk.MultiErrorAppend(errs, isoErrs...)
+		}
+
+		if c.Flatten {
+			errs = packersdk.MultiErrorAppend(
+				errs, errors.New("flatten is only valid with base_vm"))
+		}
+	}
 
 	errs = packersdk.MultiErrorAppend(errs, c.ExportConfig.Prepare(&c.ctx)...)
+	errs = packersdk.MultiErrorAppend(errs, c.ExportConfig.PrepareNVRAM(c.UEFIBoot, c.SuspendBeforeExport)...)
 	errs = packersdk.MultiErrorAppend(errs, c.CDConfig.Prepare(&c.ctx)...)
+	if c.UseCD && c.CDLabel == "" {
+		c.CDLabel = utmcommon.CDLabelCloudInit
+	}
+	cdLabel, cdWarnings, cdErrs := utmcommon.PrepareCDLabel(c.CDLabel, c.CDFiles, c.CDContent)
+	c.CDLabel = cdLabel
+	warnings = append(warnings, cdWarnings...)
+	errs = packersdk.MultiErrorAppend(errs, cdErrs...)
 	errs = packersdk.MultiErrorAppend(
 		errs, c.OutputConfig.Prepare(&c.ctx, &c.PackerConfig)...)
 	errs = packersdk.MultiErrorAppend(errs, c.HTTPConfig.Prepare(&c.ctx)...)
 	errs = packersdk.MultiErrorAppend(errs, c.ShutdownConfig.Prepare(&c.ctx)...)
+	errs = packersdk.MultiErrorAppend(errs, c.ShutdownConfig.PrepareSuspend(c.SkipExport, c.KeepRunning)...)
+	errs = packersdk.MultiErrorAppend(errs, c.ExportConfig.PrepareExtraBundleFiles(c.SkipExport, c.KeepRunning)...)
+	errs = packersdk.MultiErrorAppend(errs, c.StartConfig.Prepare()...)
+	errs = packersdk.MultiErrorAppend(errs, c.GuestHostnameConfig.Prepare(c.Comm.Type)...)
+	errs = packersdk.MultiErrorAppend(errs, c.SSHAuthorizedKeysConfig.Prepare(c.Comm.Type, c.Comm.SSHUsername)...)
+	errs = packersdk.MultiErrorAppend(errs, c.NetworkWaitConfig.Prepare(c.Comm.Type)...)
+	errs = packersdk.MultiErrorAppend(errs, c.TestResumeConfig.Prepare(c.Comm.Type)...)
+	errs = packersdk.MultiErrorAppend(errs, c.CloudInitConfig.Prepare(c.Comm.Type)...)
 	errs = packersdk.MultiErrorAppend(errs, c.CommConfig.Prepare(&c.ctx)...)
+	errs = packersdk.MultiErrorAppend(errs, c.NetworkAdaptersConfig.Prepare(c.Comm.Type, c.SkipNatMapping)...)
+	errs = packersdk.MultiErrorAppend(errs, c.HWConfig.Validate()...)
 	errs = packersdk.MultiErrorAppend(errs, c.UtmBundleConfig.Prepare(&c.ctx)...)
 	errs = packersdk.MultiErrorAppend(errs, c.UtmVersionConfig.Prepare(c.Comm.Type)...)
+	errs = packersdk.MultiErrorAppend(errs, c.DriverConfig.Prepare()...)
+	errs = packersdk.MultiErrorAppend(errs, c.GuestAdditionsConfig.PrepareVerifyOnly(c.Comm.Type)...)
 	errs = packersdk.MultiErrorAppend(errs, c.NoPauseConfig.Prepare(&c.ctx)...)
-	errs = packersdk.MultiErrorAppend(errs, c.QemuConfig.Prepare(&c.ctx)...)
+	errs = packersdk.MultiErrorAppend(errs, c.BootWaitConfig.Prepare(&c.ctx)...)
+	qemuWarnings, qemuErrs := c.QemuConfig.Prepare(&c.ctx)
+	warnings = append(warnings, qemuWarnings...)
+	errs = packersdk.MultiErrorAppend(errs, qemuErrs...)
+	if c.DisableDefaultAccelerator && c.Hypervisor {
+		errs = packersdk.MultiErrorAppend(errs, errors.New(
+			"hypervisor conflicts with disable_default_accelerator, UTM would run the VM with hvf"))
+	}
 
 	if c.DiskSize == 0 {
 		c.DiskSize = 40960
@@ -152,22 +243,35 @@ func (c *Config) Prepare(raws ...interface{}) ([]string, error) {
 	}
 
 	if c.VMBackend == "" {
-		c.VMBackend = "qemu"
+		c.VMBackend = utmcommon.VMBackendQEMU
 	}
 	// Validate and use Enums for the VM backend
 	// Only qemu cloud images are supported.
 	switch c.VMBackend {
-	case "qemu":
-		c.VMBackend = "QeMu"
+	case utmcommon.VMBackendQEMU:
+		c.VMBackend = utmcommon.VMBackendCodes[utmcommon.VMBackendQEMU]
 	default:
 		errs = packersdk.MultiErrorAppend(
 			errs, errors.New("vm_backend must be 'qemu'"))
 	}
 
+	if c.KeepRunning {
+		c.SkipExport = true
+		c.KeepRegistered = true
+	}
+
+	if c.Flatten && c.SkipExport {
+		warnings = append(warnings,
+			"flatten has no effect since the VM is not exported (skip_export).")
+	}
+
 	if c.VMName == "" {
 		c.VMName = fmt.Sprintf(
 			"packer-%s-%d", c.PackerBuildName, interpolate.InitTime.Unix())
 	}
+	if err := utmcommon.ValidateVMName(c.VMName); err != nil {
+		errs = packersdk.MultiErrorAppend(errs, err)
+	}
 
 	// Validates the presence of the cloud-init data
 	// We either use a CD-ROM or HTTP to pass cloud-init data
@@ -0,0 +1,56 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package cloud
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/naveenrajm7/packer-plugin-utm/builder/utm/common/hcl2spectest"
+)
+
+func TestHCL2Spec_fields(t *testing.T) {
+	for _, name := range hcl2spectest.MissingFields(Config{}, new(FlatConfig).HCL2Spec()) {
+		t.Errorf("%s is missing from the generated hcl2spec, run `make generate`", name)
+	}
+}
+
+func TestHCL2Spec_decode(t *testing.T) {
+	dir := t.TempDir()
+	image := filepath.Join(dir, "cloud.qcow2")
+	if err := os.WriteFile(image, nil, 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	value := hcl2spectest.Decode(t, new(FlatConfig).HCL2Spec(), `
+iso_url          = "`+image+`"
+iso_checksum     = "none"
+output_directory = "`+filepath.Join(dir, "output")+`"
+ssh_username     = "packer"
+shutdown_command = "sudo poweroff"
+http_content     = { "/user-data" = "#cloud-config" }
+
+boot_wait            = "30s"
+disk_additional_size = [1024, 2048]
+cpu_model            = "max"
+qemuargs             = [["-no-hpet"]]
+scripts_dir          = ""
+`)
+
+	var c Config
+	if _, err := c.Prepare(value); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if c.BootWait.String() != "30s" || c.CPUModel != "max" {
+		t.Fatalf("bad config: %s %s", c.BootWait, c.CPUModel)
+	}
+	if !reflect.DeepEqual(c.AdditionalDiskSize, []uint{1024, 2048}) {
+		t.Fatalf("bad disk_additional_size: %#v", c.AdditionalDiskSize)
+	}
+	if !reflect.DeepEqual(c.QemuArgs, [][]string{{"-no-hpet"}}) {
+		t.Fatalf("bad qemuargs: %#v", c.QemuArgs)
+	}
+}
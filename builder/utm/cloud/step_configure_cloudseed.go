@@ -3,8 +3,6 @@ package cloud
 import (
 	"context"
 	"fmt"
-	"log"
-	"regexp"
 
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
@@ -25,7 +23,12 @@ type stepConfigureCloudSeed struct {
 func (s *stepConfigureCloudSeed) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
 	driver := state.Get("driver").(utmcommon.Driver)
 	ui := state.Get("ui").(packersdk.Ui)
-	vmId := state.Get("vmId").(string)
+	vmId, err := utmcommon.GetVMID(state)
+	if err != nil {
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
 
 	if s.useCd {
 		return s.attachCloudInitISO(ctx, state, driver, ui, vmId)
@@ -64,7 +67,7 @@ func (s *stepConfigureCloudSeed) attachCloudInitISO(ctx context.Context, state m
 		"--source", cdFilesPath,
 		"--removable", "false", // Not removable, required for cloud init seed on virtio
 	}
-	output, err := driver.ExecuteOsaScript(attachIsoCommand...)
+	output, err := driver.ExecuteOsaScriptOutput(ctx, nil, attachIsoCommand...)
 	if err != nil {
 		err := fmt.Errorf("error attaching cloud init seed ISO: %s", err)
 		state.Put("error", err)
@@ -75,21 +78,17 @@ func (s *stepConfigureCloudSeed) attachCloudInitISO(ctx context.Context, state m
 	// Track the disks we've mounted so we can remove them without having
 	// to re-derive what was mounted where
 
-	// Regular expression to capture the UUID from the output
-	re := regexp.MustCompile(`[0-9a-fA-F-]{36}`)
-	matches := re.FindStringSubmatch(output)
-	if len(matches) > 0 {
-		uuid := matches[0] // Capture the UUID
-		unmountCommand := []string{
-			"remove_drive.applescript", vmId, uuid,
-		}
-		s.diskUnmountCommands["cloud_seed"] = unmountCommand
-	} else {
-		err := fmt.Errorf("error extracting UUID from output: %s", output)
+	uuid, err := utmcommon.ParseUUID(output.Stdout)
+	if err != nil {
+		err := fmt.Errorf("error extracting UUID from output: %s", output.Combined())
 		state.Put("error", err)
 		ui.Error(err.Error())
 		return multistep.ActionHalt
 	}
+	unmountCommand := []string{
+		"remove_drive.applescript", vmId, uuid,
+	}
+	s.diskUnmountCommands["cloud_seed"] = unmountCommand
 
 	state.Put("disk_unmount_commands", s.diskUnmountCommands)
 
@@ -106,7 +105,7 @@ func (s *stepConfigureCloudSeed) configureCloudInitHTTP(ctx context.Context, sta
 
 	// Add Qemu args to send cloud init seed file
 	ui.Say("Configuring VM to send cloud init seed file...")
-	cloudQemuArg := fmt.Sprintf("-smbios type=1,serial=ds=nocloud-net;seedfrom=http://%s:%d/", hostIP, httpPort)
+	cloudQemuArg := fmt.Sprintf("%s type=1,serial=ds=nocloud-net;seedfrom=http://%s:%d/", utmcommon.QemuFlagSMBIOS, hostIP, httpPort)
 
 	// Collect all args: user qemuargs (already set) + cloud-init arg
 	// IMPORTANT: The AppleScript replaces (not appends) all QEMU additional args,
@@ -121,7 +120,7 @@ func (s *stepConfigureCloudSeed) configureCloudInitHTTP(ctx context.Context, sta
 	addQemuArgsCommand = append(addQemuArgsCommand, cloudQemuArg)
 
 	ui.Say("Adding QEMU additional arguments...")
-	_, err := driver.ExecuteOsaScript(addQemuArgsCommand...)
+	_, err := driver.ExecuteOsaScript(ctx, addQemuArgsCommand...)
 	if err != nil {
 		err := fmt.Errorf("error adding QEMU additional arguments: %s", err)
 		state.Put("error", err)
@@ -141,15 +140,7 @@ func (s *stepConfigureCloudSeed) Cleanup(state multistep.StateBag) {
 		return
 	}
 
-	driver := state.Get("driver").(utmcommon.Driver)
-	_, ok := state.GetOk("detached_isos")
-
-	if !ok {
-		for _, command := range s.diskUnmountCommands {
-			_, err := driver.ExecuteOsaScript(command...)
-			if err != nil {
-				log.Printf("error detaching iso: %s", err)
-			}
-		}
+	if _, ok := state.GetOk("detached_isos"); !ok {
+		utmcommon.DetachDrives(state, s.diskUnmountCommands)
 	}
 }
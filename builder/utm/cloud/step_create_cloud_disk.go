@@ -25,35 +25,67 @@ func (s *stepCreateCloudDisk) Run(ctx context.Context, state multistep.StateBag)
 	config := state.Get("config").(*Config)
 	driver := state.Get("driver").(utmcommon.Driver)
 	ui := state.Get("ui").(packersdk.Ui)
-	vmId := state.Get("vmId").(string)
-
-	cloudImagePath := state.Get("iso_path").(string)
+	vmId, err := utmcommon.GetVMID(state)
+	if err != nil {
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
 
 	// Create main disk seperately for cloud image, since it uses source file
 	// Additional disks are created with use of size
 
-	// Create a temporary file to be our cloud image drive
-	TMPF, err := tmp.File("packer*.iso")
-	// Set the path so we can remove it later
-	TMPPath := TMPF.Name()
-	_ = TMPF.Close()
-	_ = os.Remove(TMPPath)
-	if err != nil {
-		state.Put("error",
-			fmt.Errorf("error creating temporary file for Cloud image: %s", err))
-		return multistep.ActionHalt
-	}
-	log.Printf("Temp cloud image path: %s", TMPPath)
-	s.ResizedCloudImagePath = TMPPath
-	// Create a copy of the original cloud image
-	ui.Say("Creating a copy of the original cloud image...")
+	if config.baseDisk != "" {
+		// Create a copy-on-write overlay so the base disk is never written to
+		TMPF, err := tmp.File("packer*.qcow2")
+		// Set the path so we can remove it later
+		TMPPath := TMPF.Name()
+		_ = TMPF.Close()
+		_ = os.Remove(TMPPath)
+		if err != nil {
+			state.Put("error",
+				fmt.Errorf("error creating temporary file for overlay disk: %s", err))
+			return multistep.ActionHalt
+		}
+		log.Printf("Temp overlay disk path: %s", TMPPath)
+		s.ResizedCloudImagePath = TMPPath
 
-	err = copyFile(cloudImagePath, s.ResizedCloudImagePath)
-	if err != nil {
-		err := fmt.Errorf("error copying cloud image: %s", err)
-		state.Put("error", err)
-		ui.Error(err.Error())
-		return multistep.ActionHalt
+		ui.Say(fmt.Sprintf("Creating overlay disk on base %s...", config.baseDisk))
+		cmd := exec.Command("qemu-img", "create", "-f", "qcow2",
+			"-b", config.baseDisk, "-F", "qcow2", s.ResizedCloudImagePath)
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			err := fmt.Errorf("error creating overlay disk: %s, output: %s", err, string(output))
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	} else {
+		cloudImagePath := state.Get("iso_path").(string)
+
+		// Create a temporary file to be our cloud image drive
+		TMPF, err := tmp.File("packer*.iso")
+		// Set the path so we can remove it later
+		TMPPath := TMPF.Name()
+		_ = TMPF.Close()
+		_ = os.Remove(TMPPath)
+		if err != nil {
+			state.Put("error",
+				fmt.Errorf("error creating temporary file for Cloud image: %s", err))
+			return multistep.ActionHalt
+		}
+		log.Printf("Temp cloud image path: %s", TMPPath)
+		s.ResizedCloudImagePath = TMPPath
+		// Create a copy of the original cloud image
+		ui.Say("Creating a copy of the original cloud image...")
+
+		err = copyFile(cloudImagePath, s.ResizedCloudImagePath)
+		if err != nil {
+			err := fmt.Errorf("error copying cloud image: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
 	}
 
 	// if ResizeCloudImage is true, resize the cloud image
@@ -91,7 +123,7 @@ func (s *stepCreateCloudDisk) Run(ctx context.Context, state multistep.StateBag)
 		"--removable", "false",
 	}
 
-	_, err = driver.ExecuteOsaScript(command...)
+	_, err = driver.ExecuteOsaScript(ctx, command...)
 	if err != nil {
 		err := fmt.Errorf("error creating hard drive: %s", err)
 		state.Put("error", err)
@@ -123,7 +155,7 @@ func (s *stepCreateCloudDisk) Run(ctx context.Context, state multistep.StateBag)
 			"--interface", controllerEnumCode,
 			"--size", strconv.FormatUint(uint64(diskSizes[i]), 10),
 		}
-		_, err = driver.ExecuteOsaScript(command...)
+		_, err = driver.ExecuteOsaScript(ctx, command...)
 		if err != nil {
 			err := fmt.Errorf("error creating hard drive: %s", err)
 			state.Put("error", err)
@@ -0,0 +1,81 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package cloud
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	utmcommon "github.com/naveenrajm7/packer-plugin-utm/builder/utm/common"
+)
+
+// This step merges the base layer into the overlay disk of the exported VM,
+// so the artifact no longer depends on base_vm.
+type stepFlattenOverlay struct{}
+
+func (s *stepFlattenOverlay) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	ui, ok := state.Get("ui").(packersdk.Ui)
+	if !ok {
+		state.Put("error", errors.New("ui is not set in state"))
+		return multistep.ActionHalt
+	}
+	config, ok := state.Get("config").(*Config)
+	if !ok {
+		return haltFlatten(state, ui, errors.New("config is not set in state"))
+	}
+
+	if config.baseDisk == "" || config.SkipExport {
+		return multistep.ActionContinue
+	}
+
+	exportPath, ok := state.Get("exportPath").(string)
+	if !ok {
+		return haltFlatten(state, ui, errors.New("no exported VM to flatten"))
+	}
+	disks, err := filepath.Glob(filepath.Join(exportPath, "Data", "*.qcow2"))
+	if err != nil {
+		return haltFlatten(state, ui, fmt.Errorf("error listing exported disks: %s", err))
+	}
+
+	for _, disk := range disks {
+		header, err := utmcommon.ReadQcow2Header(disk)
+		if err != nil {
+			return haltFlatten(state, ui, fmt.Errorf("error reading exported disk: %s", err))
+		}
+		if header.BackingFile == "" {
+			continue
+		}
+
+		if !config.Flatten {
+			ui.Say(fmt.Sprintf(
+				"Exported disk %s depends on base disk %s (flatten = false)",
+				filepath.Base(disk), header.BackingFile))
+			continue
+		}
+
+		ui.Say(fmt.Sprintf("Flattening exported disk %s...", filepath.Base(disk)))
+		// Rebasing onto no backing file copies the base data into the overlay
+		cmd := exec.CommandContext(ctx, "qemu-img", "rebase", "-f", "qcow2", "-b", "", disk)
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			return haltFlatten(state, ui, fmt.Errorf(
+				"error flattening exported disk: %s, output: %s", err, string(output)))
+		}
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *stepFlattenOverlay) Cleanup(state multistep.StateBag) {}
+
+func haltFlatten(state multistep.StateBag, ui packersdk.Ui, err error) multistep.StepAction {
+	state.Put("error", err)
+	ui.Error(err.Error())
+	return multistep.ActionHalt
+}
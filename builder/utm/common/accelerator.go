@@ -0,0 +1,77 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// The accelerators a QEMU VM can be configured with.
+const (
+	AcceleratorHVF  = "hvf"
+	AcceleratorTCG  = "tcg"
+	AcceleratorAuto = "auto"
+)
+
+// QemuFlagAccel is the QEMU flag selecting the accelerator.
+const QemuFlagAccel = "-accel"
+
+// HostArch returns the Go name of the architecture of the host hardware.
+// runtime.GOARCH is the architecture the plugin was built for, which is
+// amd64 for an Intel build running under Rosetta on Apple Silicon.
+func HostArch() string {
+	return hostArch(runtime.GOARCH, readSysctl)
+}
+
+func hostArch(goarch string, sysctl func(name string) (string, error)) string {
+	// hw.optional.arm64 is 1 on Apple Silicon, even for a translated process.
+	if goarch == "amd64" {
+		if value, err := sysctl("hw.optional.arm64"); err == nil && value == "1" {
+			return "arm64"
+		}
+	}
+	return goarch
+}
+
+// readSysctl returns the value of the named sysctl.
+func readSysctl(name string) (string, error) {
+	out, err := exec.Command("sysctl", "-n", name).Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// hostQemuArch returns the QEMU name of the host architecture.
+func hostQemuArch() string {
+	arch := HostArch()
+	switch arch {
+	case "arm64":
+		return "aarch64"
+	case "amd64":
+		return "x86_64"
+	}
+	return arch
+}
+
+// hvfAvailable reports whether the host can run a guestArch VM with hvf,
+// and why not when it can't. Hypervisor.framework only runs guests of the
+// host architecture, and only when the CPU supports it.
+func hvfAvailable(guestArch string) (bool, string) {
+	if host := hostQemuArch(); guestArch != host {
+		return false, fmt.Sprintf("hvf can't run %s guests on a %s host", guestArch, host)
+	}
+
+	support, err := readSysctl("kern.hv_support")
+	if err != nil {
+		return false, fmt.Sprintf("can't tell whether the host supports hvf: %s", err)
+	}
+	if support != "1" {
+		return false, "the host does not support Hypervisor.framework"
+	}
+	return true, ""
+}
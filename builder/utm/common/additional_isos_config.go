@@ -0,0 +1,153 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:generate packer-sdc struct-markdown
+//go:generate packer-sdc mapstructure-to-hcl2 -type AdditionalISO
+
+package common
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+)
+
+// An additional ISO to attach to the virtual machine, after the boot ISO,
+// cd_files and guest additions ISOs.
+type AdditionalISO struct {
+	// The local path or URL of the ISO. Remote ISOs are downloaded into the
+	// packer cache before being attached; local ISOs are attached in place.
+	Url string `mapstructure:"url" required:"true"`
+	// The checksum of the ISO, in the same format as `iso_checksum`. When
+	// set, the ISO is verified before it is attached.
+	Checksum string `mapstructure:"checksum" required:"false"`
+	// The interface type to use to mount the ISO. Defaults to "usb".
+	// Options are none/‌IDE/‌SCSI/‌SD/‌MTD/‌Floppy/‌PFlash/‌VirtIO/‌NVMe/‌USB.
+	Interface string `mapstructure:"interface" required:"false"`
+	// The position of the ISO among the drives on its interface, starting
+	// at 0 and counting every drive on that interface, the VM disk and the
+	// ISOs the builder attaches included. QEMU gives the drives of an
+	// interface their bus units in that order and Linux guests name them in
+	// the order they are probed, so the ISO at index 0 of the only CD
+	// interface shows up as `/dev/sr0`, the one at index 1 as `/dev/sr1`,
+	// and so on; Windows assigns drive letters in the same order. By default
+	// the ISO goes after the drives already on its interface. The build
+	// fails when the ISO doesn't end up at the index, for example because
+	// it is past the number of drives on the interface, and two ISOs can't
+	// claim the same interface and index.
+	Index *int `mapstructure:"index" required:"false"`
+	// When to attach the ISO. By default the ISO is attached before the VM
+	// boots. Set this to `communicator` to hot-plug the ISO once the
+	// communicator is connected instead, for ISOs such as driver packs that
+	// should only show up once the OS is installed. UTM can't change the
+	// drives of a running VM, so the ISO is added through the QEMU monitor,
+	// which needs `qemu_monitor`, on the `usb` or `virtio` interface. The
+	// ISO is gone once the VM stops and is never part of the exported VM.
+	AttachAfter string `mapstructure:"attach_after" required:"false"`
+}
+
+// AttachAfterCommunicator is the attach_after value of the additional ISOs
+// hot-plugged once the communicator is connected.
+const AttachAfterCommunicator = "communicator"
+
+// hotplugInterfaces are the interfaces an ISO can be hot-plugged on, with
+// the QEMU device used for each.
+var hotplugInterfaces = map[string]string{
+	"usb":    "usb-storage",
+	"virtio": "virtio-blk-pci",
+}
+
+// PostBoot reports whether the ISO is attached after the communicator
+// connects rather than before boot.
+func (iso *AdditionalISO) PostBoot() bool {
+	return iso.AttachAfter == AttachAfterCommunicator
+}
+
+type AdditionalISOsConfig struct {
+	// ISOs to attach to the virtual machine in addition to the boot ISO.
+	// Each entry may be a local path or a URL; entries are attached in the
+	// order given, after all ISOs managed by the builder.
+	//
+	// In HCL2:
+	// ```hcl
+	// additional_isos {
+	//   url       = "https://example.com/virtio-win.iso"
+	//   checksum  = "sha256:ed363350696a726b7932db864dda019bd2017365c9e299627830f06954643f93"
+	//   interface = "usb"
+	//   index     = 1
+	// }
+	//
+	// additional_isos {
+	//   url = "./drivers/extra.iso"
+	// }
+	// ```
+	AdditionalISOs []AdditionalISO `mapstructure:"additional_isos" required:"false"`
+}
+
+// Prepare validates the additional ISOs. qemuMonitor tells whether the VM
+// gets a QEMU monitor, which ISOs attached after the communicator need.
+func (c *AdditionalISOsConfig) Prepare(ctx *interpolate.Context, qemuMonitor bool) []error {
+	var errs []error
+
+	// claimed maps each interface and index to the ISO claiming it.
+	claimed := map[string]int{}
+	for i := range c.AdditionalISOs {
+		iso := &c.AdditionalISOs[i]
+
+		if iso.Url == "" {
+			errs = append(errs, fmt.Errorf("additional_isos[%d]: url is required", i))
+		}
+
+		if err := ValidateChecksum(fmt.Sprintf("additional_isos[%d]: checksum", i), iso.Checksum); err != nil {
+			errs = append(errs, err)
+		}
+
+		// Interface names are case-insensitive; lowercase them so that usb
+		// and USB claim the same indexes and match the supported interfaces.
+		iso.Interface = strings.ToLower(iso.Interface)
+		if iso.Interface == "" {
+			iso.Interface = "usb"
+		}
+
+		if _, err := GetControllerEnumCode(iso.Interface); err != nil {
+			errs = append(errs, fmt.Errorf("additional_isos[%d]: %s", i, err))
+		}
+
+		if iso.Index != nil {
+			key := fmt.Sprintf("%s/%d", iso.Interface, *iso.Index)
+			switch j, ok := claimed[key]; {
+			case *iso.Index < 0:
+				errs = append(errs, fmt.Errorf("additional_isos[%d]: index must not be negative, got %d", i, *iso.Index))
+			case iso.PostBoot():
+				errs = append(errs, fmt.Errorf(
+					"additional_isos[%d]: index can't be set for an ISO hot-plugged with attach_after", i))
+			case ok:
+				errs = append(errs, fmt.Errorf(
+					"additional_isos[%d]: index %d of the %s interface is already claimed by additional_isos[%d]",
+					i, *iso.Index, iso.Interface, j))
+			default:
+				claimed[key] = i
+			}
+		}
+
+		switch iso.AttachAfter {
+		case "":
+		case AttachAfterCommunicator:
+			if _, ok := hotplugInterfaces[iso.Interface]; !ok {
+				errs = append(errs, fmt.Errorf(
+					"additional_isos[%d]: attach_after = %q needs the usb or virtio interface, got %s",
+					i, iso.AttachAfter, iso.Interface))
+			}
+			if !qemuMonitor {
+				errs = append(errs, fmt.Errorf(
+					"additional_isos[%d]: attach_after = %q needs qemu_monitor", i, iso.AttachAfter))
+			}
+		default:
+			errs = append(errs, fmt.Errorf(
+				"additional_isos[%d]: attach_after must be empty or %q, got %q", i, AttachAfterCommunicator, iso.AttachAfter))
+		}
+	}
+
+	return errs
+}
@@ -0,0 +1,134 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAdditionalISOsConfigPrepare_empty(t *testing.T) {
+	c := new(AdditionalISOsConfig)
+	errs := c.Prepare(nil, false)
+	if len(errs) > 0 {
+		t.Fatalf("should not have errors: %#v", errs)
+	}
+}
+
+func TestAdditionalISOsConfigPrepare_defaultInterface(t *testing.T) {
+	c := &AdditionalISOsConfig{
+		AdditionalISOs: []AdditionalISO{
+			{Url: "drivers.iso"},
+			{Url: "https://example.com/tools.iso", Interface: "virtio"},
+		},
+	}
+	errs := c.Prepare(nil, false)
+	if len(errs) > 0 {
+		t.Fatalf("should not have errors: %#v", errs)
+	}
+	if c.AdditionalISOs[0].Interface != "usb" {
+		t.Fatalf("bad interface: %s", c.AdditionalISOs[0].Interface)
+	}
+	if c.AdditionalISOs[1].Interface != "virtio" {
+		t.Fatalf("bad interface: %s", c.AdditionalISOs[1].Interface)
+	}
+}
+
+func TestAdditionalISOsConfigPrepare_invalid(t *testing.T) {
+	c := &AdditionalISOsConfig{
+		AdditionalISOs: []AdditionalISO{
+			{Interface: "usb"},
+			{Url: "drivers.iso", Interface: "sata"},
+			{Url: "tools.iso", Checksum: "sha256:1234"},
+		},
+	}
+	errs := c.Prepare(nil, false)
+	if len(errs) != 3 {
+		t.Fatalf("expected 3 errors, got: %#v", errs)
+	}
+	if !strings.Contains(errs[2].Error(), "additional_isos[2]: checksum") {
+		t.Fatalf("should name the ISO with the bad checksum: %s", errs[2])
+	}
+}
+
+func TestAdditionalISOsConfigPrepare_attachAfter(t *testing.T) {
+	c := &AdditionalISOsConfig{
+		AdditionalISOs: []AdditionalISO{
+			{Url: "drivers.iso", AttachAfter: AttachAfterCommunicator},
+			{Url: "tools.iso", Interface: "virtio", AttachAfter: AttachAfterCommunicator},
+		},
+	}
+	if errs := c.Prepare(nil, true); len(errs) > 0 {
+		t.Fatalf("should not have errors: %#v", errs)
+	}
+	if !c.AdditionalISOs[0].PostBoot() || !c.AdditionalISOs[1].PostBoot() {
+		t.Fatalf("should be attached after boot: %#v", c.AdditionalISOs)
+	}
+
+	if errs := c.Prepare(nil, false); len(errs) != 2 {
+		t.Fatalf("should need qemu_monitor: %#v", errs)
+	}
+}
+
+func TestAdditionalISOsConfigPrepare_attachAfterInvalid(t *testing.T) {
+	c := &AdditionalISOsConfig{
+		AdditionalISOs: []AdditionalISO{
+			{Url: "drivers.iso", Interface: "nvme", AttachAfter: AttachAfterCommunicator},
+			{Url: "tools.iso", AttachAfter: "boot"},
+		},
+	}
+	errs := c.Prepare(nil, true)
+	if len(errs) != 2 {
+		t.Fatalf("expected 2 errors, got: %#v", errs)
+	}
+	if !strings.Contains(errs[0].Error(), "usb or virtio") {
+		t.Fatalf("bad error: %s", errs[0])
+	}
+}
+
+func TestAdditionalISOsConfigPrepare_index(t *testing.T) {
+	zero, one := 0, 1
+	c := &AdditionalISOsConfig{
+		AdditionalISOs: []AdditionalISO{
+			{Url: "drivers.iso", Index: &zero},
+			{Url: "tools.iso", Index: &one},
+			{Url: "extra.iso", Interface: "virtio", Index: &zero},
+		},
+	}
+	if errs := c.Prepare(nil, false); len(errs) > 0 {
+		t.Fatalf("should not have errors: %#v", errs)
+	}
+
+	negative := -1
+	c = &AdditionalISOsConfig{
+		AdditionalISOs: []AdditionalISO{
+			{Url: "drivers.iso", Index: &one},
+			{Url: "tools.iso", Interface: "usb", Index: &one},
+			{Url: "extra.iso", Index: &negative},
+			{Url: "late.iso", Index: &zero, AttachAfter: AttachAfterCommunicator},
+		},
+	}
+	errs := c.Prepare(nil, true)
+	if len(errs) != 3 {
+		t.Fatalf("expected 3 errors, got: %#v", errs)
+	}
+	if !strings.Contains(errs[0].Error(), "already claimed by additional_isos[0]") {
+		t.Fatalf("should name the ISO claiming the index: %s", errs[0])
+	}
+
+	// The interface is matched in any case.
+	c = &AdditionalISOsConfig{
+		AdditionalISOs: []AdditionalISO{
+			{Url: "drivers.iso", Interface: "usb", Index: &one},
+			{Url: "tools.iso", Interface: "USB", Index: &one},
+		},
+	}
+	errs = c.Prepare(nil, false)
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "already claimed by additional_isos[0]") {
+		t.Fatalf("usb and USB should claim the same index: %#v", errs)
+	}
+	if c.AdditionalISOs[1].Interface != "usb" {
+		t.Fatalf("should lowercase the interface: %s", c.AdditionalISOs[1].Interface)
+	}
+}
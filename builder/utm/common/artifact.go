@@ -5,20 +5,52 @@ package common
 
 import (
 	"fmt"
+	"log"
 	"os"
 	"path/filepath"
 
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 )
 
 // This is the common builder ID to all of these artifacts.
 const BuilderId = "naveenrajm7.utm"
 
+// The names artifacts answer State with, for post-processors and templates
+// using `build.artifact.state(...)`.
+const (
+	// The UTM ID (UUID) of the VM.
+	ArtifactStateVMID = "vm_id"
+	// The name of the VM.
+	ArtifactStateVMName = "vm_name"
+	// The path of the exported .utm file, unset when the VM is kept running.
+	ArtifactStateExportPath = "export_path"
+	// The guest additions version the build used, unset when guest
+	// additions were disabled.
+	ArtifactStateGuestAdditionsVersion = "guest_additions_version"
+)
+
+// ArtifactStateData returns the state data a builder hands to its artifact:
+// the generated data and, when the build produced them, the export path and
+// the guest additions version.
+func ArtifactStateData(state multistep.StateBag) map[string]interface{} {
+	data := map[string]interface{}{"generated_data": state.Get("generated_data")}
+	if exportPath, ok := state.GetOk("exportPath"); ok {
+		data[ArtifactStateExportPath] = exportPath
+	}
+	if version, ok := state.GetOk(StateGuestAdditionsVersion); ok {
+		data[ArtifactStateGuestAdditionsVersion] = version
+	}
+	return data
+}
+
 // Artifact is the result of running the UTM builder, namely a directory
 // of files associated with the resulting machine.
 type artifact struct {
 	// The ID of the artifact, which is the name of the VM
 	id string
+	// The UTM ID of the VM that was exported
+	vmId string
 	// The directory containing the VM files (.utm)
 	dir string
 	// The files in the directory
@@ -32,7 +64,7 @@ type artifact struct {
 // NewArtifact returns a UTM artifact containing a .utm
 // directory (file for UTM, which can be imported into UTM).
 // in the given output directory
-func NewArtifact(dir string, vmName string, generatedData map[string]interface{}) (packersdk.Artifact, error) {
+func NewArtifact(dir string, vmId string, vmName string, generatedData map[string]interface{}) (packersdk.Artifact, error) {
 	files := make([]string, 0, 5)
 	visit := func(path string, info os.FileInfo, err error) error {
 		if err != nil {
@@ -51,6 +83,7 @@ func NewArtifact(dir string, vmName string, generatedData map[string]interface{}
 
 	return &artifact{
 		id:        vmName,
+		vmId:      vmId,
 		dir:       dir,
 		f:         files,
 		StateData: generatedData,
@@ -74,9 +107,71 @@ func (a *artifact) String() string {
 }
 
 func (a *artifact) State(name string) interface{} {
+	switch name {
+	case ArtifactStateVMID:
+		return a.vmId
+	case ArtifactStateVMName:
+		return a.id
+	}
 	return a.StateData[name]
 }
 
 func (a *artifact) Destroy() error {
 	return os.RemoveAll(a.dir)
 }
+
+// runningArtifact is the result of a build with keep_running, namely a VM
+// that is left running and registered with UTM instead of exported.
+type runningArtifact struct {
+	// The UTM ID of the VM
+	vmId string
+	// The name of the VM
+	vmName string
+
+	// StateData should store data such as GeneratedData
+	// to be shared with post-processors
+	StateData map[string]interface{}
+}
+
+// NewRunningArtifact returns a UTM artifact referencing the live VM with the
+// given ID and name.
+func NewRunningArtifact(vmId string, vmName string, generatedData map[string]interface{}) packersdk.Artifact {
+	return &runningArtifact{
+		vmId:      vmId,
+		vmName:    vmName,
+		StateData: generatedData,
+	}
+}
+
+func (*runningArtifact) BuilderId() string {
+	return BuilderId
+}
+
+func (*runningArtifact) Files() []string {
+	return nil
+}
+
+func (a *runningArtifact) Id() string {
+	return a.vmName
+}
+
+func (a *runningArtifact) String() string {
+	return fmt.Sprintf("VM %s (%s) is running in UTM", a.vmName, a.vmId)
+}
+
+func (a *runningArtifact) State(name string) interface{} {
+	switch name {
+	case ArtifactStateVMID:
+		return a.vmId
+	case ArtifactStateVMName:
+		return a.vmName
+	}
+	return a.StateData[name]
+}
+
+// Destroy leaves the VM alone, since keeping it is the point of
+// keep_running. Remove it in UTM once done with it.
+func (a *runningArtifact) Destroy() error {
+	log.Printf("Not deleting VM %s (%s), it was built with keep_running", a.vmName, a.vmId)
+	return nil
+}
@@ -6,8 +6,10 @@ package common
 import (
 	"os"
 	"path/filepath"
+	"reflect"
 	"testing"
 
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 )
 
@@ -32,7 +34,7 @@ func TestNewArtifact(t *testing.T) {
 	}
 
 	generatedData := map[string]interface{}{"generated_data": "data"}
-	a, err := NewArtifact(td, "vm_name", generatedData)
+	a, err := NewArtifact(td, "vm-id", "vm_name", generatedData)
 	if err != nil {
 		t.Fatalf("err: %s", err)
 	}
@@ -46,4 +48,91 @@ func TestNewArtifact(t *testing.T) {
 	if a.State("generated_data") != "data" {
 		t.Fatalf("bad: should length have generated_data: %s", a.State("generated_data"))
 	}
+	if a.State("vm_id") != "vm-id" || a.State("vm_name") != "vm_name" {
+		t.Fatalf("bad: %#v %#v", a.State("vm_id"), a.State("vm_name"))
+	}
+}
+
+func TestRunningArtifact(t *testing.T) {
+	generatedData := map[string]interface{}{"generated_data": "data"}
+	a := NewRunningArtifact("vm-id", "vm_name", generatedData)
+
+	if a.BuilderId() != BuilderId {
+		t.Fatalf("bad: %#v", a.BuilderId())
+	}
+	if a.Id() != "vm_name" {
+		t.Fatalf("bad: %s", a.Id())
+	}
+	if len(a.Files()) != 0 {
+		t.Fatalf("should have no files: %#v", a.Files())
+	}
+	if a.State("vm_id") != "vm-id" || a.State("vm_name") != "vm_name" {
+		t.Fatalf("bad: %#v %#v", a.State("vm_id"), a.State("vm_name"))
+	}
+	if a.State("generated_data") != "data" {
+		t.Fatalf("bad: %#v", a.State("generated_data"))
+	}
+
+	// The VM is what the build was for, so it is never deleted
+	if err := a.Destroy(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+func TestNewArtifact_bundleFiles(t *testing.T) {
+	td := t.TempDir()
+	bundle := filepath.Join(td, "vm.utm")
+	if err := os.MkdirAll(filepath.Join(bundle, "Data"), 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	expected := []string{
+		filepath.Join(td, "vm.log"),
+		filepath.Join(bundle, "Data", "disk.qcow2"),
+		filepath.Join(bundle, "config.plist"),
+	}
+	for _, path := range expected {
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+
+	a, err := NewArtifact(td, "vm-id", "vm_name", nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !reflect.DeepEqual(a.Files(), expected) {
+		t.Fatalf("bad files: %#v", a.Files())
+	}
+}
+
+func TestArtifactStateData(t *testing.T) {
+	state := new(multistep.BasicStateBag)
+	state.Put("generated_data", "data")
+	state.Put("exportPath", "/output/vm.utm")
+	state.Put(StateGuestAdditionsVersion, "0.229.2")
+
+	a, err := NewArtifact(t.TempDir(), "vm-id", "vm_name", ArtifactStateData(state))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	expected := map[string]interface{}{
+		ArtifactStateVMID:                  "vm-id",
+		ArtifactStateVMName:                "vm_name",
+		ArtifactStateExportPath:            "/output/vm.utm",
+		ArtifactStateGuestAdditionsVersion: "0.229.2",
+		"generated_data":                   "data",
+	}
+	for name, value := range expected {
+		if a.State(name) != value {
+			t.Errorf("%s: expected %#v, got %#v", name, value, a.State(name))
+		}
+	}
+}
+
+func TestArtifactStateData_unset(t *testing.T) {
+	data := ArtifactStateData(new(multistep.BasicStateBag))
+	a := NewRunningArtifact("vm-id", "vm_name", data)
+	if a.State(ArtifactStateExportPath) != nil || a.State(ArtifactStateGuestAdditionsVersion) != nil {
+		t.Fatalf("should be unset: %#v", data)
+	}
 }
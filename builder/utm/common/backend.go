@@ -0,0 +1,16 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+// The backends vm_backend can create a VM with.
+const (
+	VMBackendApple = "apple"
+	VMBackendQEMU  = "qemu"
+)
+
+// VMBackendCodes maps the vm_backend names to the UTM backend enum codes.
+var VMBackendCodes = map[string]string{
+	VMBackendApple: "ApPl",
+	VMBackendQEMU:  "QeMu",
+}
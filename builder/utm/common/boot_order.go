@@ -0,0 +1,92 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"fmt"
+	"sort"
+)
+
+// These are the device categories of a boot order. UTM has no boot order
+// setting of its own: the firmware boots the drives in the order they are
+// listed, and tries the network once no drive booted.
+const (
+	BootDeviceCD      string = "cd"
+	BootDeviceDisk    string = "disk"
+	BootDeviceNetwork string = "network"
+)
+
+// ValidateBootOrder checks that devices is a boot order UTM can follow.
+func ValidateBootOrder(devices []string) error {
+	seen := map[string]bool{}
+	for i, device := range devices {
+		switch device {
+		case BootDeviceCD, BootDeviceDisk:
+		case BootDeviceNetwork:
+			if i != len(devices)-1 {
+				return fmt.Errorf("%s must come last in the boot order, as UTM only boots from the network once no drive booted", device)
+			}
+		default:
+			return fmt.Errorf("unknown boot device %q, must be one of %s, %s or %s",
+				device, BootDeviceCD, BootDeviceDisk, BootDeviceNetwork)
+		}
+		if seen[device] {
+			return fmt.Errorf("boot device %s is listed more than once", device)
+		}
+		seen[device] = true
+	}
+	return nil
+}
+
+// bootDevice returns the boot order category of drive.
+func bootDevice(drive Drive) string {
+	if drive.Removable {
+		return BootDeviceCD
+	}
+	return BootDeviceDisk
+}
+
+// bootOrder returns the categories of drives in the order they boot, with
+// network last as the firmware falls back to it.
+func bootOrder(drives []Drive) []string {
+	var devices []string
+	seen := map[string]bool{}
+	for _, drive := range drives {
+		if device := bootDevice(drive); !seen[device] {
+			seen[device] = true
+			devices = append(devices, device)
+		}
+	}
+	return append(devices, BootDeviceNetwork)
+}
+
+// bootOrderDriveIDs returns the ids of drives sorted to boot in the order
+// of devices. Drives of a category that isn't listed come last, and drives
+// of the same category keep their order.
+func bootOrderDriveIDs(drives []Drive, devices []string) ([]string, error) {
+	if err := ValidateBootOrder(devices); err != nil {
+		return nil, err
+	}
+
+	rank := map[string]int{}
+	for i, device := range devices {
+		rank[device] = i + 1
+	}
+	rankOf := func(drive Drive) int {
+		if r, ok := rank[bootDevice(drive)]; ok {
+			return r
+		}
+		return len(devices) + 1
+	}
+
+	sorted := append([]Drive(nil), drives...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return rankOf(sorted[i]) < rankOf(sorted[j])
+	})
+	ids := make([]string, len(sorted))
+	for i, drive := range sorted {
+		ids[i] = drive.ID
+	}
+	return ids, nil
+}
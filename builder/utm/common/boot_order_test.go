@@ -0,0 +1,79 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestValidateBootOrder(t *testing.T) {
+	valid := [][]string{
+		nil,
+		{"cd", "disk"},
+		{"disk", "cd", "network"},
+		{"network"},
+	}
+	for _, devices := range valid {
+		if err := ValidateBootOrder(devices); err != nil {
+			t.Fatalf("%v: should be valid: %s", devices, err)
+		}
+	}
+
+	invalid := [][]string{
+		{"floppy"},
+		{"cd", "cd"},
+		{"network", "disk"},
+	}
+	for _, devices := range invalid {
+		if err := ValidateBootOrder(devices); err == nil {
+			t.Fatalf("%v: should be invalid", devices)
+		}
+	}
+}
+
+func TestBootOrder(t *testing.T) {
+	drives := []Drive{
+		{ID: "disk0"},
+		{ID: "cd0", Removable: true},
+		{ID: "disk1"},
+		{ID: "cd1", Removable: true},
+	}
+	if devices := bootOrder(drives); !reflect.DeepEqual(devices, []string{"disk", "cd", "network"}) {
+		t.Fatalf("bad boot order: %#v", devices)
+	}
+	if devices := bootOrder(nil); !reflect.DeepEqual(devices, []string{"network"}) {
+		t.Fatalf("bad boot order: %#v", devices)
+	}
+}
+
+func TestBootOrderDriveIDs(t *testing.T) {
+	drives := []Drive{
+		{ID: "disk0"},
+		{ID: "cd0", Removable: true},
+		{ID: "disk1"},
+		{ID: "cd1", Removable: true},
+	}
+
+	ids, err := bootOrderDriveIDs(drives, []string{"cd", "disk"})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !reflect.DeepEqual(ids, []string{"cd0", "cd1", "disk0", "disk1"}) {
+		t.Fatalf("should put the CDs first, keeping their order: %#v", ids)
+	}
+
+	// Categories that aren't listed come last
+	ids, err = bootOrderDriveIDs(drives, []string{"cd", "network"})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !reflect.DeepEqual(ids, []string{"cd0", "cd1", "disk0", "disk1"}) {
+		t.Fatalf("bad ids: %#v", ids)
+	}
+
+	if _, err := bootOrderDriveIDs(drives, []string{"usb"}); err == nil {
+		t.Fatal("should reject an unknown device")
+	}
+}
@@ -0,0 +1,39 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:generate packer-sdc struct-markdown
+
+package common
+
+import (
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+)
+
+// DefaultBootWait is how long builders without a boot command wait for the
+// VM to boot before connecting to it.
+const DefaultBootWait = 5 * time.Second
+
+type BootWaitConfig struct {
+	// The time to wait after starting the virtual machine before connecting
+	// to it with the communicator, to give the firmware and the bootloader
+	// time to hand over to the OS. The value is a duration such as `30s` or
+	// `1m`. By default this is `5s`. Like the other Packer builders, `0s`
+	// means the default; to not wait at all, use a negative duration such as
+	// `-1s`.
+	BootWait time.Duration `mapstructure:"boot_wait" required:"false"`
+}
+
+func (c *BootWaitConfig) Prepare(ctx *interpolate.Context) []error {
+	var errs []error
+
+	// An unset boot_wait can't be told apart from 0s, so as in the SDK's
+	// boot command config a negative boot_wait is the one that skips the
+	// wait, which StepRun does for any boot_wait that isn't positive.
+	if c.BootWait == 0 {
+		c.BootWait = DefaultBootWait
+	}
+
+	return errs
+}
@@ -0,0 +1,31 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+)
+
+func TestBootWaitConfigPrepare(t *testing.T) {
+	c := new(BootWaitConfig)
+	if errs := c.Prepare(interpolate.NewContext()); len(errs) > 0 {
+		t.Fatalf("should not have error: %s", errs)
+	}
+	if c.BootWait != DefaultBootWait {
+		t.Fatalf("bad default: %s", c.BootWait)
+	}
+
+	c.BootWait = 30 * time.Second
+	if errs := c.Prepare(interpolate.NewContext()); len(errs) > 0 || c.BootWait != 30*time.Second {
+		t.Fatalf("should keep boot_wait: %s %s", c.BootWait, errs)
+	}
+
+	c.BootWait = -time.Second
+	if errs := c.Prepare(interpolate.NewContext()); len(errs) > 0 || c.BootWait != -time.Second {
+		t.Fatalf("should keep a negative boot_wait to skip the wait: %s %s", c.BootWait, errs)
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// CDLabelCloudInit is the volume label cloud-init's NoCloud datasource looks
+// for.
+const CDLabelCloudInit = "cidata"
+
+// The longest volume labels ISO9660 and its Joliet extension can hold.
+const (
+	maxISO9660LabelLength = 32
+	maxJolietLabelLength  = 16
+)
+
+// PrepareCDLabel returns the cd_label of the CD made of files and content,
+// defaulting it to cidata when the CD carries NoCloud user-data, so that
+// cloud-init and the autoinstallers built on it find it, and checks it fits
+// an ISO volume label. An empty label leaves the default to the CD step.
+func PrepareCDLabel(label string, files []string, content map[string]string) (string, []string, []error) {
+	if label == "" && hasCloudInitUserData(files, content) {
+		log.Printf("cd_label is not set, using %s for the cloud-init user-data", CDLabelCloudInit)
+		label = CDLabelCloudInit
+	}
+
+	var warnings []string
+	var errs []error
+	for _, r := range label {
+		if r < ' ' || r > '~' {
+			errs = append(errs, fmt.Errorf("cd_label %q must only contain printable ASCII characters", label))
+			break
+		}
+	}
+	switch {
+	case len(label) > maxISO9660LabelLength:
+		errs = append(errs, fmt.Errorf(
+			"cd_label %q is %d characters long, ISO9660 volume labels hold at most %d",
+			label, len(label), maxISO9660LabelLength))
+	case len(label) > maxJolietLabelLength:
+		warnings = append(warnings, fmt.Sprintf(
+			"cd_label %q is longer than the %d characters of a Joliet volume label, "+
+				"guests reading the Joliet label, such as Windows, see it cut short",
+			label, maxJolietLabelLength))
+	}
+	return label, warnings, errs
+}
+
+// hasCloudInitUserData reports whether a CD made of files and content has
+// a user-data file at its root.
+func hasCloudInitUserData(files []string, content map[string]string) bool {
+	if _, ok := content["user-data"]; ok {
+		return true
+	}
+	for _, file := range files {
+		if filepath.Base(file) == "user-data" {
+			return true
+		}
+		// A directory with a trailing slash has its content copied to the
+		// root of the CD, other directories keep their name.
+		if !strings.HasSuffix(file, "/") {
+			continue
+		}
+		if info, err := os.Stat(filepath.Join(file, "user-data")); err == nil && info.Mode().IsRegular() {
+			return true
+		}
+	}
+	return false
+}
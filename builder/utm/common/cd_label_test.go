@@ -0,0 +1,64 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPrepareCDLabel(t *testing.T) {
+	label, warnings, errs := PrepareCDLabel("OEMDRV", nil, nil)
+	if label != "OEMDRV" || len(warnings) > 0 || len(errs) > 0 {
+		t.Fatalf("bad result: %q %v %v", label, warnings, errs)
+	}
+
+	label, warnings, errs = PrepareCDLabel("", nil, map[string]string{"autounattend.xml": ""})
+	if label != "" || len(warnings) > 0 || len(errs) > 0 {
+		t.Fatalf("should leave the default to the CD step: %q %v %v", label, warnings, errs)
+	}
+}
+
+func TestPrepareCDLabel_cloudInit(t *testing.T) {
+	label, _, _ := PrepareCDLabel("", nil, map[string]string{"user-data": "", "meta-data": ""})
+	if label != CDLabelCloudInit {
+		t.Fatalf("should default to cidata for cd_content: %q", label)
+	}
+
+	td := t.TempDir()
+	if err := os.WriteFile(filepath.Join(td, "user-data"), nil, 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if label, _, _ := PrepareCDLabel("", []string{filepath.Join(td, "user-data")}, nil); label != CDLabelCloudInit {
+		t.Fatalf("should default to cidata for a user-data file: %q", label)
+	}
+	if label, _, _ := PrepareCDLabel("", []string{td + "/"}, nil); label != CDLabelCloudInit {
+		t.Fatalf("should default to cidata for a directory copied to the root: %q", label)
+	}
+	if label, _, _ := PrepareCDLabel("", []string{td}, nil); label != "" {
+		t.Fatalf("should not default to cidata for a directory kept by name: %q", label)
+	}
+	if label, _, _ := PrepareCDLabel("seed", nil, map[string]string{"user-data": ""}); label != "seed" {
+		t.Fatalf("should keep the configured label: %q", label)
+	}
+}
+
+func TestPrepareCDLabel_length(t *testing.T) {
+	_, warnings, errs := PrepareCDLabel(strings.Repeat("A", 20), nil, nil)
+	if len(errs) > 0 || len(warnings) != 1 || !strings.Contains(warnings[0], "Joliet") {
+		t.Fatalf("should warn about the Joliet limit: %v %v", warnings, errs)
+	}
+
+	_, _, errs = PrepareCDLabel(strings.Repeat("A", 33), nil, nil)
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "ISO9660") {
+		t.Fatalf("should reject a label over the ISO9660 limit: %v", errs)
+	}
+
+	_, _, errs = PrepareCDLabel("étiquette", nil, nil)
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "ASCII") {
+		t.Fatalf("should reject a non-ASCII label: %v", errs)
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// checksumLengths maps the checksum types Packer verifies downloads with to
+// the length of their hex digest.
+var checksumLengths = map[string]int{
+	"md5":    32,
+	"sha1":   40,
+	"sha256": 64,
+	"sha512": 128,
+}
+
+var hexDigestRe = regexp.MustCompile(`^[0-9a-fA-F]+$`)
+
+// ValidateChecksum checks the format of a download checksum such as
+// iso_checksum: "none", a hex digest, optionally prefixed with its type, or a
+// "file:" checksum file. Checksum files and URLs are fetched at download time
+// and left alone. field names the option in the error.
+func ValidateChecksum(field string, checksum string) error {
+	if checksum == "" || checksum == "none" {
+		return nil
+	}
+
+	checksumType, digest, hasType := strings.Cut(checksum, ":")
+	if !hasType {
+		checksumType, digest = "", checksum
+	}
+	checksumType = strings.ToLower(checksumType)
+	if checksumType == "file" || strings.Contains(checksum, "://") {
+		return nil
+	}
+
+	if !hexDigestRe.MatchString(digest) {
+		return fmt.Errorf("%s: %q is not a hex digest", field, digest)
+	}
+	if checksumType == "" {
+		for _, length := range checksumLengths {
+			if len(digest) == length {
+				return nil
+			}
+		}
+		return fmt.Errorf("%s: a digest of %d characters is not a md5, sha1, sha256 or sha512 checksum, "+
+			"check it was copied whole", field, len(digest))
+	}
+
+	length, ok := checksumLengths[checksumType]
+	if !ok {
+		return fmt.Errorf("%s: checksum type must be one of md5, sha1, sha256 or sha512, got %q",
+			field, checksumType)
+	}
+	if len(digest) != length {
+		return fmt.Errorf("%s: a %s checksum has %d hex characters, got %d",
+			field, checksumType, length, len(digest))
+	}
+	return nil
+}
+
+// normalizeSHA256 returns sum as a lowercase hex SHA256 digest, stripping
+// an optional "sha256:" prefix. "none" is returned as is.
+func normalizeSHA256(field string, sum string) (string, error) {
+	if sum == "none" {
+		return sum, nil
+	}
+	digest := sum
+	if prefix, rest, ok := strings.Cut(sum, ":"); ok && strings.EqualFold(prefix, "sha256") {
+		digest = rest
+	}
+	if len(digest) != checksumLengths["sha256"] || !hexDigestRe.MatchString(digest) {
+		return "", fmt.Errorf("%s must be 64 hex characters, optionally prefixed with sha256:, got %q",
+			field, sum)
+	}
+	return strings.ToLower(digest), nil
+}
@@ -0,0 +1,68 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"strings"
+	"testing"
+)
+
+const testSHA256 = "ed363350696a726b7932db864dda019bd2017365c9e299627830f06954643f93"
+
+func TestValidateChecksum(t *testing.T) {
+	valid := []string{
+		"",
+		"none",
+		testSHA256,
+		"sha256:" + testSHA256,
+		"SHA256:" + strings.ToUpper(testSHA256),
+		"md5:d41d8cd98f00b204e9800998ecf8427e",
+		"file:./SHA256SUMS",
+		"file:https://example.com/SHA256SUMS",
+		"https://example.com/SHA256SUMS",
+	}
+	for _, checksum := range valid {
+		if err := ValidateChecksum("iso_checksum", checksum); err != nil {
+			t.Errorf("%q: should be valid: %s", checksum, err)
+		}
+	}
+
+	invalid := []string{
+		testSHA256[:63],
+		"sha256:" + testSHA256[:63],
+		"sha1:" + testSHA256,
+		"sha256:" + testSHA256[:63] + "g",
+		"crc32:deadbeef",
+		"sha256:",
+	}
+	for _, checksum := range invalid {
+		err := ValidateChecksum("iso_checksum", checksum)
+		if err == nil {
+			t.Errorf("%q: should be invalid", checksum)
+		} else if !strings.HasPrefix(err.Error(), "iso_checksum: ") {
+			t.Errorf("%q: should name the option: %s", checksum, err)
+		}
+	}
+}
+
+func TestNormalizeSHA256(t *testing.T) {
+	cases := map[string]string{
+		testSHA256:                              testSHA256,
+		"sha256:" + testSHA256:                  testSHA256,
+		"SHA256:" + strings.ToUpper(testSHA256): testSHA256,
+		"none":                                  "none",
+	}
+	for sum, expected := range cases {
+		actual, err := normalizeSHA256("guest_additions_sha256", sum)
+		if err != nil || actual != expected {
+			t.Errorf("%q: expected %q, got %q (%v)", sum, expected, actual, err)
+		}
+	}
+
+	for _, sum := range []string{testSHA256[:63], "md5:" + testSHA256, testSHA256[:63] + "z"} {
+		if _, err := normalizeSHA256("guest_additions_sha256", sum); err == nil {
+			t.Errorf("%q: should be invalid", sum)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// cloneArgs returns the ExecuteOsaScript arguments duplicating the VM with
+// the given id as newName. Linked and full clones are duplicated alike; a
+// linked clone then has its disks replaced by overlays, see linkClone.
+func cloneArgs(sourceId string, newName string) []string {
+	return []string{"clone_vm.applescript", sourceId, "--name", newName}
+}
+
+// renameVMArgs returns the ExecuteOsaScript arguments renaming the VM with
+// the given id to newName.
+func renameVMArgs(vmId string, newName string) []string {
+	return []string{"customize_vm.applescript", vmId, "--name", newName}
+}
+
+// parseCloneOutput reads the output of clone_vm.applescript: the ID of the
+// new VM and the name of the source VM, as UUID and NAME markers or, from a
+// script in scripts_dir written before the markers, one per line.
+func parseCloneOutput(output string) (string, string, error) {
+	if name, ok := markerValue(output, MarkerName); ok {
+		vmId, err := ParseUUID(output)
+		if err != nil || name == "" {
+			return "", "", fmt.Errorf("unexpected clone output: %q", output)
+		}
+		return vmId, name, nil
+	}
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if len(lines) != 2 || strings.TrimSpace(lines[0]) == "" || strings.TrimSpace(lines[1]) == "" {
+		return "", "", fmt.Errorf("unexpected clone output: %q", output)
+	}
+	return strings.TrimSpace(lines[0]), strings.TrimSpace(lines[1]), nil
+}
+
+// linkedDiskArgs returns the qemu-img arguments creating disk as an overlay
+// backed by base.
+func linkedDiskArgs(base string, disk string) []string {
+	return []string{"create", "-f", "qcow2", "-b", base, "-F", "qcow2", disk}
+}
+
+// linkClone replaces the qcow2 disks of the clone bundle with overlays backed
+// by the disks of the same name in the source bundle, so that the clone only
+// stores what it changes. UTM has already copied the disks; the copies are
+// dropped.
+func linkClone(qemuImg string, sourceBundle string, cloneBundle string) error {
+	disks, err := filepath.Glob(filepath.Join(cloneBundle, "Data", "*.qcow2"))
+	if err != nil {
+		return err
+	}
+	if len(disks) == 0 {
+		return fmt.Errorf("%s has no qcow2 disk to link, use a full clone", cloneBundle)
+	}
+
+	for _, disk := range disks {
+		base := filepath.Join(sourceBundle, "Data", filepath.Base(disk))
+		if _, err := ReadQcow2Header(base); err != nil {
+			return fmt.Errorf("error reading base disk of %s: %s", filepath.Base(disk), err)
+		}
+		if err := os.Remove(disk); err != nil {
+			return err
+		}
+		output, err := exec.Command(qemuImg, linkedDiskArgs(base, disk)...).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("error creating linked disk %s: %s, output: %s",
+				filepath.Base(disk), err, strings.TrimSpace(string(output)))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:generate packer-sdc struct-markdown
+
+package common
+
+import (
+	"errors"
+	"fmt"
+)
+
+// These are the valid values of clone_type.
+const (
+	CloneTypeFull   = "full"
+	CloneTypeLinked = "linked"
+)
+
+type CloneConfig struct {
+	// The UTM ID of a VM registered with UTM to clone instead of importing
+	// `source_path`, as listed by `utmctl list`. The VM must be stopped, and
+	// is left untouched by the build.
+	SourceVM string `mapstructure:"source_vm" required:"false"`
+	// How to clone `source_vm`: `full` copies its disks, while `linked` also
+	// copies them, as UTM always does, then replaces the copies with qcow2
+	// overlays on the disks of the source VM, so that the clone only keeps what
+	// the build changes. A linked clone is no faster and needs room for the
+	// copy while it is made; it needs qemu-img and keeps depending on the
+	// source VM. A linked clone can't stand alone, so it can't be exported
+	// and needs `skip_export` or `keep_running`. Defaults to `full`.
+	CloneType string `mapstructure:"clone_type" required:"false"`
+}
+
+func (c *CloneConfig) Prepare(skipExport bool) []error {
+	var errs []error
+
+	if c.CloneType != "" && c.SourceVM == "" {
+		errs = append(errs, errors.New("clone_type needs source_vm"))
+	}
+	switch c.CloneType {
+	case "", CloneTypeFull:
+	case CloneTypeLinked:
+		if !skipExport {
+			errs = append(errs, errors.New("a linked clone depends on the disks of source_vm and "+
+				"can't be exported, set skip_export or use clone_type = \"full\""))
+		}
+	default:
+		errs = append(errs, fmt.Errorf(
+			"clone_type must be %s or %s, got %q", CloneTypeFull, CloneTypeLinked, c.CloneType))
+	}
+
+	return errs
+}
+
+// Linked reports whether source_vm is cloned as a linked clone.
+func (c *CloneConfig) Linked() bool {
+	return c.CloneType == CloneTypeLinked
+}
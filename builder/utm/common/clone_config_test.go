@@ -0,0 +1,33 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"testing"
+)
+
+func TestCloneConfigPrepare(t *testing.T) {
+	c := new(CloneConfig)
+	if errs := c.Prepare(false); len(errs) > 0 || c.Linked() {
+		t.Fatalf("should default to a full clone: %#v", errs)
+	}
+
+	c = &CloneConfig{SourceVM: "vm-id", CloneType: CloneTypeLinked}
+	if errs := c.Prepare(true); len(errs) > 0 || !c.Linked() {
+		t.Fatalf("should allow a linked clone that is not exported: %#v", errs)
+	}
+}
+
+func TestCloneConfigPrepare_invalid(t *testing.T) {
+	cases := map[string]*CloneConfig{
+		"no source":     {CloneType: CloneTypeFull},
+		"unknown type":  {SourceVM: "vm-id", CloneType: "shallow"},
+		"linked export": {SourceVM: "vm-id", CloneType: CloneTypeLinked},
+	}
+	for name, c := range cases {
+		if errs := c.Prepare(false); len(errs) != 1 {
+			t.Errorf("%s: expected 1 error, got %#v", name, errs)
+		}
+	}
+}
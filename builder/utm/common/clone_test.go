@@ -0,0 +1,72 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCloneArgs(t *testing.T) {
+	expected := []string{"clone_vm.applescript", "source-id", "--name", "packer-clone"}
+	if args := cloneArgs("source-id", "packer-clone"); !reflect.DeepEqual(args, expected) {
+		t.Fatalf("bad args: %#v", args)
+	}
+}
+
+func TestRenameVMArgs(t *testing.T) {
+	expected := []string{"customize_vm.applescript", "vm-id", "--name", "packer-clone"}
+	if args := renameVMArgs("vm-id", "packer-clone"); !reflect.DeepEqual(args, expected) {
+		t.Fatalf("bad args: %#v", args)
+	}
+}
+
+func TestLinkedDiskArgs(t *testing.T) {
+	expected := []string{"create", "-f", "qcow2", "-b", "/src.utm/Data/disk.qcow2", "-F", "qcow2", "/clone.utm/Data/disk.qcow2"}
+	if args := linkedDiskArgs("/src.utm/Data/disk.qcow2", "/clone.utm/Data/disk.qcow2"); !reflect.DeepEqual(args, expected) {
+		t.Fatalf("bad args: %#v", args)
+	}
+}
+
+func TestParseCloneOutput(t *testing.T) {
+	vmId, sourceName, err := parseCloneOutput("clone-id\nsource VM\n")
+	if err != nil || vmId != "clone-id" || sourceName != "source VM" {
+		t.Fatalf("bad: %q %q %v", vmId, sourceName, err)
+	}
+
+	for _, output := range []string{"", "clone-id", "clone-id\n\n"} {
+		if _, _, err := parseCloneOutput(output); err == nil {
+			t.Errorf("%q: should fail", output)
+		}
+	}
+}
+
+func TestLinkClone_errors(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "source.utm")
+	clone := filepath.Join(dir, "clone.utm")
+	for _, bundle := range []string{source, clone} {
+		if err := os.MkdirAll(filepath.Join(bundle, "Data"), 0755); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+
+	if err := linkClone("qemu-img", source, clone); err == nil || !strings.Contains(err.Error(), "no qcow2 disk") {
+		t.Fatalf("should need a qcow2 disk: %v", err)
+	}
+
+	disk := filepath.Join(clone, "Data", "disk.qcow2")
+	if err := os.WriteFile(disk, []byte("QFI\xfb"), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := linkClone("qemu-img", source, clone); err == nil || !strings.Contains(err.Error(), "base disk") {
+		t.Fatalf("should need the base disk: %v", err)
+	}
+	if _, err := os.Stat(disk); err != nil {
+		t.Fatalf("should keep the copy when the base is missing: %s", err)
+	}
+}
@@ -0,0 +1,45 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:generate packer-sdc struct-markdown
+
+package common
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// DefaultCloudInitTimeout is how long the builders wait for cloud-init to
+// finish when cloud_init_wait is set.
+const DefaultCloudInitTimeout = 30 * time.Minute
+
+type CloudInitConfig struct {
+	// Set this to true to wait for cloud-init to finish its first boot in
+	// the guest before the provisioners run, so that they don't race with
+	// it and the exported VM reflects a completed first boot. The build
+	// fails when cloud-init reports an error. Guests without cloud-init are
+	// warned about and the build goes on. Defaults to false.
+	CloudInitWait bool `mapstructure:"cloud_init_wait" required:"false"`
+	// How long to wait for cloud-init to finish with `cloud_init_wait`.
+	// Defaults to 30m.
+	CloudInitTimeout time.Duration `mapstructure:"cloud_init_timeout" required:"false"`
+}
+
+func (c *CloudInitConfig) Prepare(commType string) []error {
+	var errs []error
+
+	if c.CloudInitTimeout == 0 {
+		c.CloudInitTimeout = DefaultCloudInitTimeout
+	}
+	if c.CloudInitTimeout < 0 {
+		errs = append(errs, fmt.Errorf(
+			"cloud_init_timeout must not be negative, got %s", c.CloudInitTimeout))
+	}
+	if c.CloudInitWait && commType == "none" {
+		errs = append(errs, errors.New("cloud_init_wait needs a communicator"))
+	}
+
+	return errs
+}
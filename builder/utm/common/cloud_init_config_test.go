@@ -0,0 +1,29 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCloudInitConfigPrepare(t *testing.T) {
+	c := new(CloudInitConfig)
+	if errs := c.Prepare("ssh"); len(errs) > 0 {
+		t.Fatalf("should not have errors: %#v", errs)
+	}
+	if c.CloudInitTimeout != DefaultCloudInitTimeout {
+		t.Fatalf("bad default timeout: %s", c.CloudInitTimeout)
+	}
+
+	c = &CloudInitConfig{CloudInitTimeout: -time.Second}
+	if errs := c.Prepare("ssh"); len(errs) != 1 {
+		t.Fatalf("should reject a negative timeout: %#v", errs)
+	}
+
+	c = &CloudInitConfig{CloudInitWait: true}
+	if errs := c.Prepare("none"); len(errs) != 1 {
+		t.Fatalf("should need a communicator: %#v", errs)
+	}
+}
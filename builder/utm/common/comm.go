@@ -4,16 +4,70 @@
 package common
 
 import (
+	"errors"
+	"net/netip"
+
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 )
 
+// CommHost returns the host the communicator connects to. When host is
+// empty, as it is with skip_nat_mapping and no host set, it is the address
+// the guest agent of the VM reports, which isn't known until the guest is
+// up, so the lookup is retried like a refused connection.
 func CommHost(host string) func(multistep.StateBag) (string, error) {
 	return func(state multistep.StateBag) (string, error) {
-		return host, nil
+		if host != "" {
+			return host, nil
+		}
+		driver, err := getDriver(state)
+		if err != nil {
+			return "", err
+		}
+		vmId, err := GetVMID(state)
+		if err != nil {
+			return "", err
+		}
+		addresses, err := driver.IPAddresses(vmId)
+		if err != nil {
+			return "", err
+		}
+		return guestAddress(addresses)
+	}
+}
+
+// guestAddress picks the address to reach the guest on among the addresses
+// its agent reports, preferring IPv4 and leaving out loopback and
+// link-local addresses.
+func guestAddress(addresses []string) (string, error) {
+	var ipv6 string
+	for _, address := range addresses {
+		addr, err := netip.ParseAddr(address)
+		if err != nil || addr.IsLoopback() || addr.IsLinkLocalUnicast() {
+			continue
+		}
+		if addr.Is4() {
+			return address, nil
+		}
+		if ipv6 == "" {
+			ipv6 = address
+		}
 	}
+	if ipv6 == "" {
+		return "", errors.New("the guest reported no address the host can reach")
+	}
+	return ipv6, nil
 }
 
 func CommPort(state multistep.StateBag) (int, error) {
 	commHostPort := state.Get("commHostPort").(int)
 	return commHostPort, nil
 }
+
+// CommAdapterIndex returns the index of the network adapter the
+// communicator port is forwarded on.
+func CommAdapterIndex(state multistep.StateBag) int {
+	if index, ok := state.GetOk("comm_adapter_index"); ok {
+		return index.(int)
+	}
+	return DefaultCommAdapterIndex
+}
@@ -7,6 +7,8 @@ package common
 
 import (
 	"errors"
+	"fmt"
+	"time"
 
 	"github.com/hashicorp/packer-plugin-sdk/communicator"
 	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
@@ -24,8 +26,19 @@ type CommConfig struct {
 	HostPortMax int `mapstructure:"host_port_max" required:"false"`
 	// Defaults to false. When enabled, Packer
 	// does not setup forwarded port mapping for communicator (SSH or WinRM) requests and uses ssh_port or winrm_port
-	// on the host to communicate to the virtual machine.
+	// on the host to communicate to the virtual machine. Unless `ssh_host` or
+	// `winrm_host` is set, the host is the IP address the guest agent of the
+	// VM reports, so the guest needs the QEMU or SPICE guest agent.
 	SkipNatMapping bool `mapstructure:"skip_nat_mapping" required:"false"`
+	// The amount of time to wait for the installer or the first boot to
+	// finish, that is for the guest to answer on the communicator port,
+	// before connecting to it. `ssh_timeout` or `winrm_timeout` only start
+	// once the guest answers, so they can stay short to fail fast when the
+	// communicator is up but refuses the connection, without shortening the
+	// install window. The value is a duration such as `45m`. By default there
+	// is no separate install window and the communicator timeout covers the
+	// whole wait.
+	InstallTimeout time.Duration `mapstructure:"install_timeout" required:"false"`
 
 	// These are deprecated, but we keep them around for backwards compatibility
 	// TODO: remove later
@@ -53,7 +66,9 @@ func (c *CommConfig) Prepare(ctx *interpolate.Context) []error {
 		c.SkipNatMapping = c.SSHSkipNatMapping
 	}
 
-	if c.Comm.Host() == "" {
+	// Without port forwarding the communicator connects to the address
+	// the guest reports, see CommHost.
+	if c.Comm.Host() == "" && !c.SkipNatMapping {
 		c.Comm.SSHHost = "127.0.0.1"
 		c.Comm.WinRMHost = "127.0.0.1"
 	}
@@ -71,6 +86,18 @@ func (c *CommConfig) Prepare(ctx *interpolate.Context) []error {
 		errs = append(errs,
 			errors.New("host_port_min must be less than host_port_max"))
 	}
+	if c.InstallTimeout < 0 {
+		errs = append(errs, fmt.Errorf("install_timeout must not be negative, got %s", c.InstallTimeout))
+	}
+	if c.Comm.SSHTimeout < 0 {
+		errs = append(errs, fmt.Errorf("ssh_timeout must not be negative, got %s", c.Comm.SSHTimeout))
+	}
+	if c.Comm.WinRMTimeout < 0 {
+		errs = append(errs, fmt.Errorf("winrm_timeout must not be negative, got %s", c.Comm.WinRMTimeout))
+	}
+	if c.InstallTimeout > 0 && c.Comm.Type == "none" {
+		errs = append(errs, errors.New("install_timeout needs a communicator"))
+	}
 
 	return errs
 }
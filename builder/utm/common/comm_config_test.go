@@ -42,6 +42,26 @@ func TestCommConfigPrepare(t *testing.T) {
 	}
 }
 
+func TestCommConfigPrepare_Host(t *testing.T) {
+	c := testCommConfig()
+	if errs := c.Prepare(interpolate.NewContext()); len(errs) > 0 {
+		t.Fatalf("err: %#v", errs)
+	}
+	if c.Comm.Host() != "127.0.0.1" {
+		t.Errorf("bad host: %q", c.Comm.Host())
+	}
+
+	// Without port forwarding the guest address is looked up at connect time
+	c = testCommConfig()
+	c.SkipNatMapping = true
+	if errs := c.Prepare(interpolate.NewContext()); len(errs) > 0 {
+		t.Fatalf("err: %#v", errs)
+	}
+	if c.Comm.Host() != "" {
+		t.Errorf("should not default the host: %q", c.Comm.Host())
+	}
+}
+
 func TestCommConfigPrepare_SSHHostPort(t *testing.T) {
 	var c *CommConfig
 	var errs []error
@@ -65,6 +85,27 @@ func TestCommConfigPrepare_SSHHostPort(t *testing.T) {
 	}
 }
 
+func TestCommConfigPrepare_InstallTimeout(t *testing.T) {
+	c := testCommConfig()
+	c.InstallTimeout = time.Hour
+	c.Comm.SSHTimeout = time.Minute
+	if errs := c.Prepare(interpolate.NewContext()); len(errs) > 0 {
+		t.Fatalf("should not have error: %#v", errs)
+	}
+
+	c = testCommConfig()
+	c.InstallTimeout = -time.Minute
+	if errs := c.Prepare(interpolate.NewContext()); len(errs) != 1 {
+		t.Fatalf("should reject a negative install_timeout: %#v", errs)
+	}
+
+	c = testCommConfig()
+	c.Comm.SSHTimeout = -time.Minute
+	if errs := c.Prepare(interpolate.NewContext()); len(errs) != 1 {
+		t.Fatalf("should reject a negative ssh_timeout: %#v", errs)
+	}
+}
+
 func TestCommConfigPrepare_SSHPrivateKey(t *testing.T) {
 	var c *CommConfig
 	var errs []error
@@ -0,0 +1,48 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestCommHost(t *testing.T) {
+	state := testState(t)
+	state.Put("vmId", "vm-id")
+	driver := state.Get("driver").(*DriverMock)
+
+	host, err := CommHost("10.0.0.1")(state)
+	if err != nil || host != "10.0.0.1" {
+		t.Fatalf("bad host: %q, %v", host, err)
+	}
+	if len(driver.IPAddressesCalls) != 0 {
+		t.Fatalf("should not look up the guest address: %v", driver.IPAddressesCalls)
+	}
+
+	driver.IPAddressesResult = []string{"fe80::1%en0", "127.0.0.1", "fd00::5", "192.168.64.5"}
+	host, err = CommHost("")(state)
+	if err != nil || host != "192.168.64.5" {
+		t.Fatalf("bad guest host: %q, %v", host, err)
+	}
+	if !reflect.DeepEqual(driver.IPAddressesCalls, []string{"vm-id"}) {
+		t.Fatalf("bad calls: %v", driver.IPAddressesCalls)
+	}
+
+	driver.IPAddressesErr = errors.New("no guest agent")
+	if _, err := CommHost("")(state); err == nil {
+		t.Fatal("should fail without the guest address")
+	}
+}
+
+func TestGuestAddress(t *testing.T) {
+	host, err := guestAddress([]string{"fe80::1%en0", "fd00::5"})
+	if err != nil || host != "fd00::5" {
+		t.Fatalf("bad host: %q, %v", host, err)
+	}
+	if _, err := guestAddress([]string{"fe80::1%en0", "::1"}); err == nil {
+		t.Fatal("should fail without a reachable address")
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// QemuFlagCPU is the QEMU flag selecting the guest CPU model.
+const QemuFlagCPU = "-cpu"
+
+// The CPU models that work for any guest architecture. host passes the host
+// CPU through and needs hvf; max enables everything the accelerator can
+// emulate.
+const (
+	CPUModelHost = "host"
+	CPUModelMax  = "max"
+)
+
+// KnownCPUModels lists the named CPU models of the QEMU shipped with UTM for
+// the guest architectures the plugin builds. Other models are passed
+// through with a warning, since the list depends on the QEMU version.
+var KnownCPUModels = []string{
+	CPUModelHost, CPUModelMax,
+	// aarch64
+	"cortex-a35", "cortex-a53", "cortex-a55", "cortex-a57", "cortex-a72",
+	"cortex-a76", "cortex-a710", "neoverse-n1", "neoverse-n2", "neoverse-v1",
+	// x86_64
+	"qemu64", "kvm64", "Nehalem", "Westmere", "SandyBridge", "IvyBridge",
+	"Haswell", "Broadwell", "Skylake-Client", "Skylake-Server",
+	"Cascadelake-Server", "Icelake-Server", "EPYC", "EPYC-Rome", "EPYC-Milan",
+}
+
+// cpuFeatureRe matches a QEMU CPU feature: +name and -name turn it on and
+// off, name=value sets it.
+var cpuFeatureRe = regexp.MustCompile(`^(?:[+-][A-Za-z0-9_.-]+|[A-Za-z0-9_.-]+=[A-Za-z0-9_.-]+)$`)
+
+func knownCPUModel(model string) bool {
+	for _, known := range KnownCPUModels {
+		if model == known {
+			return true
+		}
+	}
+	return false
+}
+
+// cpuFeatureSetting splits a feature into its name and value, so +aes and
+// aes=on compare equal.
+func cpuFeatureSetting(feature string) (string, string) {
+	switch feature[0] {
+	case '+':
+		return feature[1:], "on"
+	case '-':
+		return feature[1:], "off"
+	}
+	parts := strings.SplitN(feature, "=", 2)
+	return parts[0], parts[1]
+}
+
+// mergeCPUFeatures appends extra to features, failing when both set the
+// same feature differently. Features set the same way are kept once.
+func mergeCPUFeatures(features, extra []string) ([]string, error) {
+	merged := append([]string(nil), features...)
+	settings := make(map[string]string, len(features))
+	for _, feature := range features {
+		name, value := cpuFeatureSetting(feature)
+		settings[name] = value
+	}
+	for _, feature := range extra {
+		name, value := cpuFeatureSetting(feature)
+		if set, ok := settings[name]; ok {
+			if set != value {
+				return nil, fmt.Errorf("cpu feature %s is set to both %s and %s", name, set, value)
+			}
+			continue
+		}
+		settings[name] = value
+		merged = append(merged, feature)
+	}
+	return merged, nil
+}
+
+// parseCPUArg splits the value of a -cpu argument into its model and
+// features.
+func parseCPUArg(value string) (string, []string) {
+	parts := strings.Split(value, ",")
+	var features []string
+	for _, part := range parts[1:] {
+		if part = strings.TrimSpace(part); part != "" {
+			features = append(features, part)
+		}
+	}
+	return strings.TrimSpace(parts[0]), features
+}
+
+// CPUQemuArg returns the -cpu argument for model with features.
+func CPUQemuArg(model string, features []string) string {
+	return QemuFlagCPU + " " + strings.Join(append([]string{model}, features...), ",")
+}
@@ -0,0 +1,96 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+// detachAttempts is how many times a drive is removed before giving up.
+const detachAttempts = 3
+
+// detachRetryDelay is the pause between attempts, set to zero by tests.
+var detachRetryDelay = 2 * time.Second
+
+// DetachDrives runs the remove_drive.applescript commands that StepAttachISOs
+// and friends record in disk_unmount_commands, for use in Cleanup. Each
+// drive is retried a few times and then checked against the drives still
+// attached to the VM. A drive that can't be detached is reported with the
+// VM it is attached to, since it would otherwise stay attached to the VM
+// unnoticed.
+func DetachDrives(state multistep.StateBag, commands map[string][]string) {
+	driver, err := getDriver(state)
+	if err != nil {
+		log.Printf("[ERROR] Can't detach drives: %s", err)
+		return
+	}
+
+	categories := make([]string, 0, len(commands))
+	for category := range commands {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+
+	var leaked []string
+	for _, category := range categories {
+		command := commands[category]
+		if len(command) < 3 {
+			continue
+		}
+		vmId, driveId := command[1], command[2]
+
+		if err := detachDrive(driver, command); err != nil {
+			leaked = append(leaked, fmt.Sprintf("%s drive %s of VM %s: %s", category, driveId, vmId, err))
+			continue
+		}
+
+		drives, err := driver.ListAttachedDrives(vmId)
+		if err != nil {
+			log.Printf("Can't verify that drive %s was detached: %s", driveId, err)
+			continue
+		}
+		for _, drive := range drives {
+			if strings.EqualFold(drive.ID, driveId) {
+				leaked = append(leaked, fmt.Sprintf(
+					"%s drive %s of VM %s: still attached after removing it", category, driveId, vmId))
+				break
+			}
+		}
+	}
+
+	if len(leaked) == 0 {
+		return
+	}
+	msg := "Warning: these drives could not be detached and are still attached " +
+		"to the VM. Remove them manually in UTM before the next build:\n  " +
+		strings.Join(leaked, "\n  ")
+	if ui, err := getUI(state); err == nil {
+		ui.Error(msg)
+	} else {
+		log.Printf("[WARN] %s", msg)
+	}
+}
+
+// detachDrive runs command, retrying it when it fails. It runs in Cleanup,
+// so it doesn't stop when the build is cancelled.
+func detachDrive(driver Driver, command []string) error {
+	var err error
+	for attempt := 1; attempt <= detachAttempts; attempt++ {
+		if _, err = driver.ExecuteOsaScript(context.Background(), command...); err == nil {
+			return nil
+		}
+		log.Printf("Error detaching drive %s (attempt %d/%d): %s", command[2], attempt, detachAttempts, err)
+		if attempt < detachAttempts {
+			time.Sleep(detachRetryDelay)
+		}
+	}
+	return err
+}
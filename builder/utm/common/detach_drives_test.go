@@ -0,0 +1,75 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+func testUiOutput(state multistep.StateBag) string {
+	return state.Get("ui").(*packersdk.BasicUi).Writer.(*bytes.Buffer).String()
+}
+
+func testDetachCommands() map[string][]string {
+	return map[string][]string{
+		"boot_iso": {"remove_drive.applescript", "vm-id", "AAAAAAAA-0000-0000-0000-000000000001"},
+	}
+}
+
+func TestDetachDrives(t *testing.T) {
+	detachRetryDelay = 0
+	state := testState(t)
+	driver := state.Get("driver").(*DriverMock)
+	driver.ExecuteOsaErrs = []error{errors.New("UTM is busy")}
+
+	DetachDrives(state, testDetachCommands())
+
+	if len(driver.ExecuteOsaCalls) != 2 {
+		t.Fatalf("should retry a failed detach: %#v", driver.ExecuteOsaCalls)
+	}
+	if len(driver.ListAttachedDrivesCalls) != 1 || driver.ListAttachedDrivesCalls[0] != "vm-id" {
+		t.Fatalf("should verify the detach: %#v", driver.ListAttachedDrivesCalls)
+	}
+	if output := testUiOutput(state); output != "" {
+		t.Fatalf("should not warn: %s", output)
+	}
+}
+
+func TestDetachDrives_persistentFailure(t *testing.T) {
+	detachRetryDelay = 0
+	state := testState(t)
+	driver := state.Get("driver").(*DriverMock)
+	err := errors.New("UTM is busy")
+	driver.ExecuteOsaErrs = []error{err, err, err}
+
+	DetachDrives(state, testDetachCommands())
+
+	if len(driver.ExecuteOsaCalls) != detachAttempts {
+		t.Fatalf("should try %d times: %#v", detachAttempts, driver.ExecuteOsaCalls)
+	}
+	warning := testUiOutput(state)
+	if !strings.Contains(warning, "vm-id") || !strings.Contains(warning, "AAAAAAAA-0000-0000-0000-000000000001") {
+		t.Fatalf("should name the VM and the drive: %q", warning)
+	}
+}
+
+func TestDetachDrives_stillAttached(t *testing.T) {
+	detachRetryDelay = 0
+	state := testState(t)
+	driver := state.Get("driver").(*DriverMock)
+	driver.ListAttachedDrivesResult = []Drive{{ID: "aaaaaaaa-0000-0000-0000-000000000001"}}
+
+	DetachDrives(state, testDetachCommands())
+
+	warning := testUiOutput(state)
+	if !strings.Contains(warning, "still attached") {
+		t.Fatalf("should warn about the drive left attached: %q", warning)
+	}
+}
@@ -0,0 +1,60 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// The modes disk_preallocation takes, passed on to qemu-img.
+const (
+	DiskPreallocationOff      = "off"
+	DiskPreallocationMetadata = "metadata"
+	DiskPreallocationFull     = "full"
+)
+
+// DiskPreallocations lists the values disk_preallocation accepts.
+var DiskPreallocations = []string{DiskPreallocationOff, DiskPreallocationMetadata, DiskPreallocationFull}
+
+// The formats UTM keeps disk images in.
+const (
+	DiskFormatQcow2 = "qcow2"
+	DiskFormatRaw   = "raw"
+)
+
+// DiskFormatForBackend returns the format of the disks UTM creates for a VM
+// of the backend named vm_backend: raw for Apple Virtualization, qcow2 for
+// QEMU.
+func DiskFormatForBackend(backend string) string {
+	if backend == VMBackendApple || backend == VMBackendCodes[VMBackendApple] {
+		return DiskFormatRaw
+	}
+	return DiskFormatQcow2
+}
+
+// ValidateDiskPreallocation checks that a disk in format can be preallocated
+// with mode. A raw disk has no metadata, so only qcow2 takes metadata.
+func ValidateDiskPreallocation(mode string, format string) error {
+	if !slices.Contains(DiskPreallocations, mode) {
+		return fmt.Errorf("disk_preallocation must be one of %s, got %q",
+			strings.Join(DiskPreallocations, ", "), mode)
+	}
+	if mode == DiskPreallocationMetadata && format != DiskFormatQcow2 {
+		return fmt.Errorf("disk_preallocation = %q only applies to qcow2 disks, the %s disks of this VM "+
+			"have no metadata to preallocate, use %q or %q", mode, format, DiskPreallocationFull, DiskPreallocationOff)
+	}
+	return nil
+}
+
+// QemuImgCreateArgs returns the qemu-img arguments creating a disk of
+// sizeMiB megabytes at path in format, preallocated with mode.
+func QemuImgCreateArgs(path string, format string, sizeMiB uint, mode string) []string {
+	args := []string{"create", "-f", format}
+	if mode != "" && mode != DiskPreallocationOff {
+		args = append(args, "-o", "preallocation="+mode)
+	}
+	return append(args, path, fmt.Sprintf("%dM", sizeMiB))
+}
@@ -0,0 +1,59 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiskFormatForBackend(t *testing.T) {
+	cases := map[string]string{
+		VMBackendApple:                 DiskFormatRaw,
+		VMBackendCodes[VMBackendApple]: DiskFormatRaw,
+		VMBackendQEMU:                  DiskFormatQcow2,
+		VMBackendCodes[VMBackendQEMU]:  DiskFormatQcow2,
+	}
+	for backend, want := range cases {
+		if got := DiskFormatForBackend(backend); got != want {
+			t.Fatalf("%s: got %q, want %q", backend, got, want)
+		}
+	}
+}
+
+func TestValidateDiskPreallocation(t *testing.T) {
+	for _, mode := range DiskPreallocations {
+		if err := ValidateDiskPreallocation(mode, DiskFormatQcow2); err != nil {
+			t.Fatalf("%s on qcow2: err: %s", mode, err)
+		}
+	}
+	for _, mode := range []string{DiskPreallocationOff, DiskPreallocationFull} {
+		if err := ValidateDiskPreallocation(mode, DiskFormatRaw); err != nil {
+			t.Fatalf("%s on raw: err: %s", mode, err)
+		}
+	}
+
+	if err := ValidateDiskPreallocation(DiskPreallocationMetadata, DiskFormatRaw); err == nil {
+		t.Fatal("should reject metadata on a raw disk")
+	}
+	for _, mode := range []string{"", "falloc", "FULL"} {
+		if err := ValidateDiskPreallocation(mode, DiskFormatQcow2); err == nil {
+			t.Fatalf("%q: should have error", mode)
+		}
+	}
+}
+
+func TestQemuImgCreateArgs(t *testing.T) {
+	got := QemuImgCreateArgs("/tmp/disk0.qcow2", DiskFormatQcow2, 40960, DiskPreallocationMetadata)
+	want := []string{"create", "-f", "qcow2", "-o", "preallocation=metadata", "/tmp/disk0.qcow2", "40960M"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+
+	got = QemuImgCreateArgs("/tmp/disk0.raw", DiskFormatRaw, 1024, DiskPreallocationOff)
+	want = []string{"create", "-f", "raw", "/tmp/disk0.raw", "1024M"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,44 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"fmt"
+	"os"
+)
+
+// NamedFile is a file the option Name points at, such as iso_url.
+type NamedFile struct {
+	Name string
+	Path string
+}
+
+// CheckDistinctFiles fails when two of files are the same file, even
+// through different paths or symlinks, as attaching one ISO twice on
+// different drives is almost always a mistake. Files of the same name, such
+// as the mirrors of iso_urls, may be the same. Remote sources and files that
+// don't exist are skipped.
+func CheckDistinctFiles(files []NamedFile) error {
+	var seen []NamedFile
+	var infos []os.FileInfo
+	for _, file := range files {
+		path, ok := localPath(file.Path)
+		if !ok {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		for i, other := range infos {
+			if seen[i].Name != file.Name && os.SameFile(info, other) {
+				return fmt.Errorf("%s and %s are the same file (%s), set them to different files",
+					seen[i].Name, file.Name, path)
+			}
+		}
+		seen = append(seen, file)
+		infos = append(infos, info)
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckDistinctFiles(t *testing.T) {
+	bootPath, _ := testISOFile(t)
+	otherPath, _ := testISOFile(t)
+	link := filepath.Join(t.TempDir(), "link.iso")
+	if err := os.Symlink(bootPath, link); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	files := []NamedFile{
+		{Name: "iso_url", Path: bootPath},
+		{Name: "iso_url", Path: "file://" + bootPath},
+		{Name: "cd_files[0]", Path: otherPath},
+		{Name: "additional_isos[0]", Path: "https://example.com/extra.iso"},
+		{Name: "additional_isos[1]", Path: filepath.Join(t.TempDir(), "missing.iso")},
+	}
+	if err := CheckDistinctFiles(files); err != nil {
+		t.Fatalf("should accept distinct files: %s", err)
+	}
+
+	err := CheckDistinctFiles(append(files, NamedFile{Name: "cd_files[1]", Path: link}))
+	if err == nil || !strings.Contains(err.Error(), "iso_url and cd_files[1] are the same file") {
+		t.Fatalf("should catch a symlink to the same file: %v", err)
+	}
+}
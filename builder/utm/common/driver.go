@@ -1,14 +1,21 @@
 package common
 
 import (
+	"context"
 	"embed"
+	"errors"
 	"fmt"
 	"log"
 	"os/exec"
 	"strings"
+	"time"
 )
 
 var (
+	// The AppleScripts are built into the plugin binary and fed to osascript
+	// on stdin, so they are never written to or read from disk, unless
+	// scripts_dir overrides them.
+	//
 	//go:embed scripts/*
 	osascripts embed.FS
 )
@@ -20,30 +27,150 @@ var (
 // versions out of the builder steps, so sometimes the methods are
 // extremely specific.
 type Driver interface {
+	// Clone duplicates the stopped VM with the given id as newName and
+	// returns the id of the new VM. A linked clone shares the disks of its
+	// source, which must then be kept, while a full clone is independent.
+	// When the VM was duplicated but linking its disks failed, its id is
+	// returned with the error so that it can be deleted.
+	Clone(sourceId string, newName string, linked bool) (string, error)
+
+	// RenameVM renames the stopped VM with the given id to newName, which
+	// must pass ValidateVMName. It is idempotent, so callers apply the
+	// configured name after cloning or importing whatever UTM named the VM.
+	RenameVM(vmId string, newName string) error
+
 	// Delete a VM by name
 	Delete(string) error
 
-	// Executes the given AppleScript with the given arguments.
-	ExecuteOsaScript(command ...string) (string, error)
+	// Executes the given AppleScript with the given arguments, and returns
+	// what it printed on stdout. Cancelling ctx kills osascript.
+	ExecuteOsaScript(ctx context.Context, command ...string) (string, error)
+
+	// Executes the given AppleScript with the given arguments, adding env
+	// to the environment of the osascript process, and returns what it
+	// printed on stdout. See ScriptEnv for the variables the plugin
+	// provides.
+	ExecuteOsaScriptWithEnv(ctx context.Context, env map[string]string, command ...string) (string, error)
+
+	// Executes the given AppleScript like ExecuteOsaScriptWithEnv, keeping
+	// stdout and stderr apart. Callers parsing a script's result should
+	// read it from Stdout.
+	ExecuteOsaScriptOutput(ctx context.Context, env map[string]string, command ...string) (OsaScriptOutput, error)
+
+	// Executes the given AppleScript source instead of one of the embedded
+	// scripts, passing it args and adding env to its environment.
+	RunApplescriptInline(ctx context.Context, script string, env map[string]string, args ...string) (OsaScriptOutput, error)
 
 	// Export a VM to a UTM file
 	Export(string, string) error
 
+	// ExportWithProgress exports the VM with the given id to path like
+	// Export, calling progress every interval with the bytes written so far
+	// when the driver can tell. Cancelling ctx returns at once, but UTM may
+	// go on writing to path for a while after.
+	ExportWithProgress(ctx context.Context, vmId string, path string, interval time.Duration, progress func(written uint64)) error
+
 	// Import a VM
 	Import(string) (string, error)
 
+	// IsInstalled checks if the UTM application is installed.
+	IsInstalled() (bool, error)
+
 	// Checks if the VM with the given id is running.
 	IsRunning(string) (bool, error)
 
+	// IPAddresses returns the IP addresses the guest agent of the running
+	// VM with the given id reports. It fails when the guest has no agent.
+	IPAddresses(vmId string) ([]string, error)
+
+	// EnsureRunning makes sure UTM is running, launching it and waiting for
+	// it to be ready when the driver was configured to. Otherwise it returns
+	// ErrUTMNotRunning when UTM isn't running.
+	EnsureRunning() error
+
+	// CheckAutomation checks that UTM can be scripted, launching it if
+	// needed. It returns an error wrapping ErrAutomationNotAuthorized when
+	// the automation permission has not been granted.
+	CheckAutomation() error
+
+	// GetBootOrder returns the device categories, cd, disk and network, of
+	// the VM with the given id in the order its firmware boots them.
+	GetBootOrder(vmId string) ([]string, error)
+
+	// SetBootOrder reorders the drives of the stopped VM with the given id
+	// so that its firmware boots the device categories in the order of
+	// devices. See ValidateBootOrder for the orders UTM can follow.
+	SetBootOrder(vmId string, devices []string) error
+
+	// SupportedDriveInterfaces returns the drive interfaces, such as usb or
+	// virtio, that the VM with the given id supports. It returns nil without
+	// an error when they are not known for the VM's architecture.
+	SupportedDriveInterfaces(vmId string) ([]string, error)
+
+	// GetVMConfig reads the configuration of the VM with the given id back
+	// from UTM.
+	GetVMConfig(vmId string) (VMConfig, error)
+
+	// BundlePath returns the path of the .utm bundle of the VM with the
+	// given id.
+	BundlePath(vmId string) (string, error)
+
 	// Get guest tools iso path
 	GuestToolsIsoPath() (string, error)
 
+	// ListAttachedDrives returns the drives of the VM with the given id.
+	ListAttachedDrives(string) ([]Drive, error)
+
+	// MonitorCommand runs a human monitor command, such as `sendkey` or
+	// `system_powerdown`, on the QEMU monitor of the VM with the given id
+	// and returns its output. The VM needs the monitor added with
+	// qemu_monitor.
+	MonitorCommand(vmId string, cmd string) (string, error)
+
+	// SendKeys types keys into the window of the VM with the given id.
+	// keys uses the boot_command syntax, literal text with special keys
+	// such as <enter> and <wait5>. It needs the Accessibility permission.
+	// Cancelling ctx stops typing.
+	SendKeys(ctx context.Context, vmId string, keys string) error
+
+	// Screenshot grabs the display of the VM with the given id as a PNG.
+	// The builder must have set up VNC for the VM. It fails when the
+	// display isn't available, for example before the VM has drawn its
+	// first frame, so callers should handle the error gracefully rather
+	// than halting the build.
+	Screenshot(vmId string) ([]byte, error)
+
+	// StartVM starts the VM with the given id and waits up to timeout for
+	// UTM to report it as started, failing with what UTM reported when it
+	// stops again or never gets there. It stops waiting when ctx is done.
+	StartVM(ctx context.Context, vmId string, timeout time.Duration) error
+
+	// WaitForState polls the power state of the VM with the given id every
+	// interval until it is target, one of VMStates such as stopped, and
+	// fails after timeout with the state the VM was left in. It stops
+	// waiting when ctx is done.
+	WaitForState(ctx context.Context, vmId string, target string, timeout time.Duration, interval time.Duration) error
+
+	// Suspend suspends the running VM with the given id, saving its state,
+	// memory included, into the VM bundle so that it resumes where it left
+	// off when started again. It returns an error wrapping
+	// ErrSuspendNotSupported when the backend of the VM can't save its state.
+	Suspend(vmId string) error
+
+	// Resume resumes the suspended VM with the given id.
+	Resume(vmId string) error
+
+	// PowerOff stops a running machine. With force it is powered off at
+	// once, otherwise the guest OS is asked to shut down and the call
+	// returns without waiting for it.
+	PowerOff(vmId string, force bool) error
+
 	// Stop stops a running machine, forcefully.
 	Stop(string) error
 
 	// Utmctl executes the given Utmctl command
-	// and returns the stdout channel as string
-	Utmctl(...string) (string, error)
+	// and returns the stdout channel as string. Cancelling ctx kills utmctl.
+	Utmctl(ctx context.Context, args ...string) (string, error)
 
 	// Verify checks to make sure that this driver should function
 	// properly. If there is any indication the driver can't function,
@@ -54,48 +181,184 @@ type Driver interface {
 	Version() (string, error)
 }
 
-// NewDriver creates a new driver for UTM.
-func NewDriver() (Driver, error) {
-	var utmctlPath string
+// OsaScriptOutput is what an AppleScript printed. Scripts return their
+// result on stdout, while osascript and UTM print diagnostics on stderr.
+type OsaScriptOutput struct {
+	Stdout string
+	Stderr string
+}
+
+// Combined returns stdout followed by stderr.
+func (o OsaScriptOutput) Combined() string {
+	if o.Stdout == "" || o.Stderr == "" {
+		return o.Stdout + o.Stderr
+	}
+	return o.Stdout + "\n" + o.Stderr
+}
+
+// VMStates are the power states utmctl status reports.
+var VMStates = []string{"stopped", "starting", "started", "pausing", "paused", "resuming", "stopping"}
+
+// Drive is a drive attached to a VM, as reported by UTM.
+type Drive struct {
+	// ID is the UUID UTM gave the drive.
+	ID string
+	// Interface is the interface the drive is attached on, such as usb.
+	Interface string
+	// Removable is set for a removable drive, such as a CD.
+	Removable bool
+	// Source is the path of the drive image, empty for a drive without one.
+	Source string
+}
+
+// parseDrives reads the output of list_drives.applescript, one drive per
+// line with its fields separated by tabs.
+func parseDrives(output string) ([]Drive, error) {
+	var drives []Drive
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		fields := strings.SplitN(line, "\t", 4)
+		if len(fields) != 4 {
+			return nil, fmt.Errorf("unexpected drive listing: %q", line)
+		}
+		drives = append(drives, Drive{
+			ID:        strings.TrimSpace(fields[0]),
+			Interface: strings.ToLower(strings.TrimSpace(fields[1])),
+			Removable: strings.TrimSpace(fields[2]) == "true",
+			Source:    fields[3],
+		})
+	}
+	return drives, nil
+}
 
-	var err error
-	utmctlPath, err = exec.LookPath("utmctl")
+// These are the reasons a driver can give for not providing the locally
+// bundled guest tools ISO.
+const (
+	GuestToolsReasonUtmNotInstalled  string = "UTM is not installed"
+	GuestToolsReasonNotBundled       string = "guest tools are not bundled"
+	GuestToolsReasonPermissionDenied string = "permission denied"
+)
+
+// GuestToolsError is returned by GuestToolsIsoPath when the guest tools ISO
+// cannot be used, recording why so callers can report it.
+type GuestToolsError struct {
+	Reason string
+	Path   string
+	Err    error
+}
+
+func (e *GuestToolsError) Error() string {
+	msg := fmt.Sprintf("guest tools ISO unavailable (%s)", e.Reason)
+	if e.Path != "" {
+		msg = fmt.Sprintf("%s at path: %s", msg, e.Path)
+	}
+	if e.Err != nil {
+		msg = fmt.Sprintf("%s: %s", msg, e.Err)
+	}
+	return msg
+}
+
+func (e *GuestToolsError) Unwrap() error {
+	return e.Err
+}
+
+// ErrAutomationNotAuthorized is returned by CheckAutomation when macOS has
+// not allowed Packer to send Apple events to UTM.
+var ErrAutomationNotAuthorized = errors.New("not authorized to send Apple events to UTM")
+
+// ErrSuspendNotSupported is returned by Suspend when UTM can't save the state
+// of the VM, such as an Apple Virtualization VM on a host older than macOS 14.
+var ErrSuspendNotSupported = errors.New("UTM can't save the state of this VM")
+
+// AutomationPermissionError is returned when macOS refuses an Apple event
+// sent to UTM, typically errAEEventNotPermitted (-1743). It explains how to
+// grant the permission and unwraps to ErrAutomationNotAuthorized.
+type AutomationPermissionError struct {
+	// Output is what osascript printed when the event was refused.
+	Output string
+}
+
+func (e *AutomationPermissionError) Error() string {
+	msg := ErrAutomationNotAuthorized.Error()
+	if e.Output != "" {
+		msg = fmt.Sprintf("%s: %s", msg, e.Output)
+	}
+	return msg + "\n" +
+		"Packer drives UTM through AppleScript, which needs the Automation permission:\n" +
+		"  1. Open System Settings > Privacy & Security > Automation.\n" +
+		"  2. Under your terminal (or the app running Packer), enable UTM.\n" +
+		"If it is not listed, run `tccutil reset AppleEvents` and start the build " +
+		"again from a logged-in session to get the permission prompt. CI runners " +
+		"need the permission granted ahead of time, for example through an MDM " +
+		"PPPC profile."
+}
+
+func (e *AutomationPermissionError) Unwrap() error {
+	return ErrAutomationNotAuthorized
+}
+
+// NewDriver creates a new driver for UTM, running AppleScript and utmctl as
+// set in config.
+func NewDriver(config *DriverConfig) (Driver, error) {
+	backend, utmctlPath, err := resolveDriverBackend(config.DriverBackend, exec.LookPath)
 	if err != nil {
 		return nil, err
 	}
-	log.Printf("utmctl path: %s", utmctlPath)
+	log.Printf("Driver backend: %s, utmctl path: %q", backend, utmctlPath)
+
+	base := Utm45Driver{
+		UtmctlPath:    utmctlPath,
+		OsascriptPath: config.OsascriptPath,
+		ScriptsDir:    config.ScriptsDir,
+		AutoLaunch:    config.AutoLaunchUTM.True(),
+	}
 
-	// Get the version of UTM
 	var driver Driver
-	driver = &Utm45Driver{utmctlPath}
-	version, err := driver.Version()
+	driver = &base
+
+	// Fail before any step runs if the plugin was built without a script, or
+	// one was modified.
+	if err := driver.Verify(); err != nil {
+		return nil, err
+	}
+
+	// Run what utmctl has a command for with it when it is the backend
+	withBackend := func(driver Driver) Driver {
+		if backend == DriverBackendUtmctl {
+			return &UtmctlDriver{Driver: driver, UtmctlPath: utmctlPath}
+		}
+		return driver
+	}
+
+	// Get the version of UTM, from UTM.app next to utmctl when it is used
+	version, err := withBackend(driver).Version()
 	if err != nil {
-		log.Fatalf("Error getting UTM version: %v", err)
+		// Let the preflight step explain what is wrong with the installation
+		log.Printf("Error getting UTM version: %v", err)
+		return withBackend(driver), nil
 	}
 	fmt.Printf("UTM version: %s\n", version)
 
 	// Parse the version to get major and minor parts
-	versionParts := strings.Split(version, ".")
-	if len(versionParts) < 2 {
+	utmVersion, err := ParseUTMVersion(version)
+	if err != nil {
 		log.Fatalf("Invalid UTM version format: %s", version)
 	}
-	majorMinorVersion := fmt.Sprintf("%s.%s", versionParts[0], versionParts[1])
 
 	// Decide which driver to use based on the version
-	switch majorMinorVersion {
+	switch utmVersion.MajorMinor() {
 	case "4.5":
-		driver = &Utm45Driver{utmctlPath}
+		driver = &base
 	case "4.6":
-		driver = &Utm46Driver{Utm45Driver{utmctlPath}}
+		driver = &Utm46Driver{base}
 	case "4.7":
-		driver = &Utm47Driver{Utm46Driver{Utm45Driver{utmctlPath}}}
+		driver = &Utm47Driver{Utm46Driver{base}}
 	default:
 		log.Fatalf("Unsupported UTM version: %s", version)
 	}
 
-	if err := driver.Verify(); err != nil {
-		return nil, err
-	}
-
-	return driver, nil
+	return withBackend(driver), nil
 }
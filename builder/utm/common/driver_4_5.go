@@ -2,52 +2,138 @@ package common
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io/fs"
 	"log"
+	"os"
 	"os/exec"
 	"path/filepath"
-	"regexp"
+	"slices"
+	"sort"
 	"strings"
+	"time"
 )
 
 // Utm45Driver is the base type for UTM drivers
 type Utm45Driver struct {
 	// This is the path to the utmctl binary
 	UtmctlPath string
+	// This is the path to the osascript binary, osascript from PATH when
+	// empty
+	OsascriptPath string
+	// This is a directory with patched AppleScripts used instead of the
+	// bundled ones, if any
+	ScriptsDir string
+	// This launches UTM when EnsureRunning finds it isn't running
+	AutoLaunch bool
+}
+
+// osascript returns the osascript binary to run.
+func (d *Utm45Driver) osascript() string {
+	if d.OsascriptPath != "" {
+		return d.OsascriptPath
+	}
+	return "osascript"
+}
+
+// vmCommandScripts maps the utmctl commands vmCommand stands in for to the
+// AppleScripts doing the same.
+var vmCommandScripts = map[string]string{
+	"delete": "delete_vm.applescript",
+	"start":  "start_vm.applescript",
+	"status": "get_status.applescript",
+	"stop":   "stop_vm.applescript",
+}
+
+// vmCommand runs the AppleScript doing what the utmctl command does on the
+// VM with the given id, and returns what it printed. The utmctl backend
+// runs utmctl itself, through UtmctlDriver.
+func (d *Utm45Driver) vmCommand(ctx context.Context, command string, vmId string, args ...string) (string, error) {
+	script, ok := vmCommandScripts[command]
+	if !ok {
+		return "", fmt.Errorf("no AppleScript for the %s command", command)
+	}
+	output, err := d.ExecuteOsaScriptOutput(ctx, nil, append([]string{script, vmId}, args...)...)
+	if err != nil && output.Stderr != "" {
+		return output.Stdout, fmt.Errorf("%s: %s", err, output.Stderr)
+	}
+	return output.Stdout, err
+}
+
+// status reads the power state of the VM with the given id.
+func (d *Utm45Driver) status(vmId string) (string, error) {
+	output, err := d.vmCommand(context.Background(), "status", vmId)
+	return parseStatus(output), err
 }
 
 func (d *Utm45Driver) Delete(name string) error {
-	_, err := d.Utmctl("delete", name)
+	_, err := d.vmCommand(context.Background(), "delete", name)
 	return err
 }
 
 // ExecuteOsaScript executes an AppleScript command with the given arguments.
-func (d *Utm45Driver) ExecuteOsaScript(command ...string) (string, error) {
+func (d *Utm45Driver) ExecuteOsaScript(ctx context.Context, command ...string) (string, error) {
+	return d.ExecuteOsaScriptWithEnv(ctx, nil, command...)
+}
+
+// ExecuteOsaScriptWithEnv executes an AppleScript command with the given
+// arguments, adding env to the environment the script runs in, and returns
+// what it printed on stdout.
+func (d *Utm45Driver) ExecuteOsaScriptWithEnv(ctx context.Context, env map[string]string, command ...string) (string, error) {
+	output, err := d.ExecuteOsaScriptOutput(ctx, env, command...)
+	return output.Stdout, err
+}
+
+// ExecuteOsaScriptOutput executes an AppleScript command with the given
+// arguments, adding env to the environment the script runs in, and returns
+// what it printed on stdout and stderr separately.
+func (d *Utm45Driver) ExecuteOsaScriptOutput(ctx context.Context, env map[string]string, command ...string) (OsaScriptOutput, error) {
 	if len(command) == 0 {
-		return "", fmt.Errorf("no command provided")
+		return OsaScriptOutput{}, fmt.Errorf("no command provided")
 	}
 
 	// log the command to be executed
 	log.Printf("Executing OSA script command: %s", command)
 
-	// Read the script content from the embedded files
-	scriptPath := filepath.Join("scripts", command[0])
-	scriptContent, err := osascripts.ReadFile(scriptPath)
+	// Read the script content from scripts_dir or the embedded files
+	scriptContent, err := readScript(d.ScriptsDir, command[0])
 	if err != nil {
-		return "", fmt.Errorf("failed to read script %s: %v", scriptPath, err)
+		return OsaScriptOutput{}, fmt.Errorf("failed to read script %s: %v", command[0], err)
+	}
+
+	return runOsaScript(ctx, d.osascript(), scriptContent, env, command[1:])
+}
+
+// RunApplescriptInline executes the given AppleScript source, which reads
+// args with `on run argv`, adding env to the environment the script runs
+// in. It runs exactly like the embedded scripts do.
+func (d *Utm45Driver) RunApplescriptInline(ctx context.Context, script string, env map[string]string, args ...string) (OsaScriptOutput, error) {
+	if strings.TrimSpace(script) == "" {
+		return OsaScriptOutput{}, fmt.Errorf("no script provided")
 	}
 
+	log.Printf("Executing inline OSA script with args: %s", args)
+	return runOsaScript(ctx, d.osascript(), []byte(script), env, args)
+}
+
+// runOsaScript feeds script to the osascript binary on stdin. The arguments
+// are passed to osascript as they are, never spliced into the script, so
+// they need no escaping.
+func runOsaScript(ctx context.Context, osascript string, scriptContent []byte, env map[string]string, args []string) (OsaScriptOutput, error) {
 	// Construct the command to execute
-	cmd := exec.Command("osascript", "-")
+	cmd := exec.CommandContext(ctx, osascript, "-")
+	if len(env) > 0 {
+		cmd.Env = osaScriptEnv(os.Environ(), env)
+	}
 
 	// Append additional arguments to the command
-	if len(command) > 1 {
-		cmd.Args = append(cmd.Args, command[1:]...)
-	}
+	cmd.Args = append(cmd.Args, args...)
 
 	stdin, err := cmd.StdinPipe()
 	if err != nil {
-		return "", err
+		return OsaScriptOutput{}, err
 	}
 
 	go func() {
@@ -58,19 +144,82 @@ func (d *Utm45Driver) ExecuteOsaScript(command ...string) (string, error) {
 	var stdout, stderr bytes.Buffer
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
-	err = cmd.Run()
+	err = runOsascript(cmd)
 
-	stdoutString := strings.TrimSpace(stdout.String())
-	stderrString := strings.TrimSpace(stderr.String())
+	output := OsaScriptOutput{
+		Stdout: strings.TrimSpace(stdout.String()),
+		Stderr: strings.TrimSpace(stderr.String()),
+	}
 
-	if stdoutString != "" {
-		log.Printf("stdout: %s", stdoutString)
+	if output.Stdout != "" {
+		log.Printf("stdout: %s", output.Stdout)
 	}
-	if stderrString != "" {
-		log.Printf("stderr: %s", stderrString)
+	if output.Stderr != "" {
+		log.Printf("stderr: %s", output.Stderr)
 	}
 
-	return stdoutString, err
+	if err != nil && isAutomationDenied(output.Stderr) {
+		return output, &AutomationPermissionError{Output: output.Stderr}
+	}
+
+	return output, err
+}
+
+// osaScriptEnv returns base with env added, in a stable order so the
+// command logs the same way every time.
+func osaScriptEnv(base []string, env map[string]string) []string {
+	keys := make([]string, 0, len(env))
+	for k := range env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	result := append([]string{}, base...)
+	for _, k := range keys {
+		result = append(result, fmt.Sprintf("%s=%s", k, env[k]))
+	}
+	return result
+}
+
+// A linked clone gets qcow2 overlays on the disks of its source, created
+// with qemu-img.
+func (d *Utm45Driver) Clone(sourceId string, newName string, linked bool) (string, error) {
+	output, err := d.ExecuteOsaScriptOutput(context.Background(), nil, cloneArgs(sourceId, newName)...)
+	if err != nil {
+		return "", fmt.Errorf("error cloning VM %s: %s", sourceId, err)
+	}
+	vmId, _, err := parseCloneOutput(output.Stdout)
+	if err != nil {
+		return "", err
+	}
+	if !linked {
+		return vmId, nil
+	}
+
+	qemuImg, err := exec.LookPath("qemu-img")
+	if err != nil {
+		return vmId, fmt.Errorf("linked clones need qemu-img: %s", err)
+	}
+	sourceBundle, err := d.BundlePath(sourceId)
+	if err != nil {
+		return vmId, err
+	}
+	cloneBundle, err := d.BundlePath(vmId)
+	if err != nil {
+		return vmId, err
+	}
+	err = linkClone(qemuImg, sourceBundle, cloneBundle)
+	return vmId, err
+}
+
+func (d *Utm45Driver) RenameVM(vmId string, newName string) error {
+	if err := ValidateVMName(newName); err != nil {
+		return err
+	}
+	if _, err := d.ExecuteOsaScript(context.Background(), renameVMArgs(vmId, newName)...); err != nil {
+		return fmt.Errorf("error renaming VM %s to %q: %s", vmId, newName, err)
+	}
+	return nil
 }
 
 // UTM 4.5 Doesn't support exporting VMs
@@ -95,9 +244,17 @@ func (d *Utm45Driver) Export(vmId string, path string) error {
 	return nil
 }
 
+// UTM 4.5 : there is no export to report the progress of.
+func (d *Utm45Driver) ExportWithProgress(ctx context.Context, vmId string, path string, interval time.Duration, progress func(written uint64)) error {
+	return d.Export(vmId, path)
+}
+
 // UTM 4.5 : doesn't support adding support guest tools
 func (d *Utm45Driver) GuestToolsIsoPath() (string, error) {
-	return "", fmt.Errorf("UTM driver does not provide guest additions")
+	return "", &GuestToolsError{
+		Reason: GuestToolsReasonNotBundled,
+		Err:    fmt.Errorf("UTM 4.5 driver does not provide guest additions"),
+	}
 }
 
 // UTM 4.5 : We just create a VM shortcut using UTM open command.
@@ -108,11 +265,11 @@ func (d *Utm45Driver) Import(path string) (string, error) {
 	// So we make sure VM name is same as the name in plist.config (previous name in UTM bundle)
 	// This is a limitation of UTM
 	cmd := exec.Command(
-		"osascript", "-e",
+		d.osascript(), "-e",
 		fmt.Sprintf(`tell application "UTM" to open POSIX file "%s"`, path),
 	)
 	cmd.Stdout = &stdout
-	if err := cmd.Run(); err != nil {
+	if err := runOsascript(cmd); err != nil {
 		return "", err
 	}
 	// "missing value" in the output means AppleScript was successful
@@ -123,16 +280,72 @@ func (d *Utm45Driver) Import(path string) (string, error) {
 	return "", nil
 }
 
-func (d *Utm45Driver) IsRunning(name string) (bool, error) {
-	var stdout bytes.Buffer
+// ListAttachedDrives reads the drives of the VM with list_drives.applescript.
+func (d *Utm45Driver) ListAttachedDrives(vmId string) ([]Drive, error) {
+	output, err := d.ExecuteOsaScriptOutput(context.Background(), nil, "list_drives.applescript", vmId)
+	if err != nil {
+		return nil, fmt.Errorf("error listing drives of VM %s: %s", vmId, err)
+	}
+	return parseDrives(output.Stdout)
+}
 
-	cmd := exec.Command(d.UtmctlPath, "status", name)
-	cmd.Stdout = &stdout
-	if err := cmd.Run(); err != nil {
+func (d *Utm45Driver) GetBootOrder(vmId string) ([]string, error) {
+	drives, err := d.ListAttachedDrives(vmId)
+	if err != nil {
+		return nil, err
+	}
+	return bootOrder(drives), nil
+}
+
+func (d *Utm45Driver) SetBootOrder(vmId string, devices []string) error {
+	drives, err := d.ListAttachedDrives(vmId)
+	if err != nil {
+		return err
+	}
+	ids, err := bootOrderDriveIDs(drives, devices)
+	if err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	command := append([]string{"reorder_drives.applescript", vmId}, ids...)
+	if _, err := d.ExecuteOsaScript(context.Background(), command...); err != nil {
+		return fmt.Errorf("error setting the boot order of VM %s: %s", vmId, err)
+	}
+	return nil
+}
+
+func (d *Utm45Driver) SupportedDriveInterfaces(vmId string) ([]string, error) {
+	output, err := d.ExecuteOsaScriptOutput(context.Background(), nil, "get_architecture.applescript", vmId)
+	if err != nil {
+		return nil, fmt.Errorf("error reading the architecture of VM %s: %s", vmId, err)
+	}
+	return DriveInterfacesForArch(strings.TrimSpace(output.Stdout)), nil
+}
+
+func (d *Utm45Driver) GetVMConfig(vmId string) (VMConfig, error) {
+	output, err := d.ExecuteOsaScriptOutput(context.Background(), nil, "get_vm_config.applescript", vmId)
+	if err != nil {
+		return VMConfig{}, fmt.Errorf("error reading the configuration of VM %s: %s", vmId, err)
+	}
+	return parseVMConfig(output.Stdout)
+}
+
+func (d *Utm45Driver) BundlePath(vmId string) (string, error) {
+	config, err := d.GetVMConfig(vmId)
+	if err != nil {
+		return "", err
+	}
+	return vmBundlePath(config), nil
+}
+
+func (d *Utm45Driver) IsRunning(name string) (bool, error) {
+	output, err := d.status(name)
+	if err != nil {
 		return false, err
 	}
 
-	output := strings.TrimSpace(stdout.String())
 	if output == "started" {
 		return true, nil
 	}
@@ -156,18 +369,168 @@ func (d *Utm45Driver) IsRunning(name string) (bool, error) {
 	return false, nil
 }
 
+func (d *Utm45Driver) IPAddresses(vmId string) ([]string, error) {
+	output, err := d.ExecuteOsaScriptOutput(context.Background(), nil, "get_ip_addresses.applescript", vmId)
+	if err != nil {
+		return nil, fmt.Errorf("error reading the IP addresses of VM %s: %s", vmId, err)
+	}
+	return parseIPAddresses(output.Stdout)
+}
+
+func (d *Utm45Driver) MonitorCommand(vmId string, cmd string) (string, error) {
+	addr, err := qemuMonitorAddress(vmId)
+	if err != nil {
+		return "", err
+	}
+	return QemuMonitorCommand(addr, cmd)
+}
+
+func (d *Utm45Driver) Screenshot(vmId string) ([]byte, error) {
+	return vncScreenshotPNG(vmId)
+}
+
+func (d *Utm45Driver) SendKeys(ctx context.Context, vmId string, keys string) error {
+	return RunKeySequence(ctx, &appleScriptKeyDriver{ctx: ctx, driver: d, vmId: vmId}, keys)
+}
+
+func (d *Utm45Driver) StartVM(ctx context.Context, vmId string, timeout time.Duration) error {
+	output, err := d.vmCommand(ctx, "start", vmId)
+	if err != nil {
+		// The script fails with the message UTM shows, which carries the
+		// QEMU error when QEMU could not be launched at all.
+		return fmt.Errorf("error starting VM %s: %s", vmId, err)
+	}
+	status := func() (string, error) {
+		return d.status(vmId)
+	}
+	if err := waitForVMStart(ctx, status, timeout, time.Second); err != nil {
+		if output != "" {
+			return fmt.Errorf("error starting VM %s: %s\nstart output: %s", vmId, err, output)
+		}
+		return fmt.Errorf("error starting VM %s: %s", vmId, err)
+	}
+	return nil
+}
+
+// waitForVMStart polls status until the VM is started. utmctl start only
+// waits for UTM to launch QEMU, so a QEMU that exits right away, typically
+// because of bad qemuargs, shows up as the VM going back to stopped.
+func waitForVMStart(ctx context.Context, status func() (string, error), timeout time.Duration, interval time.Duration) error {
+	started := func(state string) (bool, error) {
+		switch state {
+		case "started", "paused":
+			return true, nil
+		case "stopped", "stopping":
+			return false, fmt.Errorf(
+				"VM is %s right after starting, QEMU most likely exited. "+
+					"Check qemuargs, and enable the QEMU debug log in UTM to see its output", state)
+		}
+		return false, nil
+	}
+	return pollVMState(ctx, status, started, "start", timeout, interval)
+}
+
+func (d *Utm45Driver) WaitForState(ctx context.Context, vmId string, target string, timeout time.Duration, interval time.Duration) error {
+	status := func() (string, error) {
+		return d.status(vmId)
+	}
+	return waitForState(ctx, status, target, timeout, interval)
+}
+
+// waitForState polls status until the VM is in the target state.
+func waitForState(ctx context.Context, status func() (string, error), target string, timeout time.Duration, interval time.Duration) error {
+	if !slices.Contains(VMStates, target) {
+		return fmt.Errorf("unknown VM state %q, must be one of %s", target, strings.Join(VMStates, ", "))
+	}
+	reached := func(state string) (bool, error) {
+		return state == target, nil
+	}
+	return pollVMState(ctx, status, reached, "be "+target, timeout, interval)
+}
+
+// pollVMState calls status every interval until reached accepts the state
+// it returns, or fails with the error of reached. It gives up after timeout
+// or once ctx is done. goal completes "waiting for the VM to" in errors.
+func pollVMState(ctx context.Context, status func() (string, error), reached func(state string) (bool, error),
+	goal string, timeout time.Duration, interval time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		state, err := status()
+		if err != nil {
+			return fmt.Errorf("error reading VM status: %s", err)
+		}
+		state = strings.TrimSpace(state)
+		if ok, err := reached(state); ok || err != nil {
+			return err
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("VM is still %q after waiting %s for it to %s", state, timeout, goal)
+		}
+		if err := sleepCtx(ctx, interval); err != nil {
+			return fmt.Errorf("interrupted while waiting for the VM to %s: %w", goal, err)
+		}
+	}
+}
+
+func (d *Utm45Driver) Suspend(vmId string) error {
+	output, err := d.ExecuteOsaScriptOutput(context.Background(), nil, "suspend_vm.applescript", vmId)
+	if err != nil {
+		if isSuspendUnsupported(output.Combined()) {
+			return fmt.Errorf("error suspending VM %s: %w: %s", vmId, ErrSuspendNotSupported, output.Combined())
+		}
+		return fmt.Errorf("error suspending VM %s: %s", vmId, err)
+	}
+	return nil
+}
+
+// isSuspendUnsupported tells whether UTM refused to suspend a VM because its
+// backend can't save the VM state. suspend_vm.applescript marks the error,
+// while the English message of UTM is only recognized for scripts from
+// scripts_dir written before the markers.
+func isSuspendUnsupported(output string) bool {
+	return hasErrorMarker(output, MarkerErrorUnsupported) || strings.Contains(output, "not supported")
+}
+
+func (d *Utm45Driver) Resume(vmId string) error {
+	if _, err := d.ExecuteOsaScript(context.Background(), "resume_vm.applescript", vmId); err != nil {
+		return fmt.Errorf("error resuming VM %s: %s", vmId, err)
+	}
+	return nil
+}
+
 func (d *Utm45Driver) Stop(name string) error {
-	if _, err := d.Utmctl("stop", name); err != nil {
+	if _, err := d.vmCommand(context.Background(), "stop", name); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (d *Utm45Driver) PowerOff(vmId string, force bool) error {
+	mode := "--request"
+	if force {
+		mode = "--force"
+	}
+	if _, err := d.vmCommand(context.Background(), "stop", vmId, mode); err != nil {
 		return err
 	}
 	return nil
 }
 
-func (d *Utm45Driver) Utmctl(args ...string) (string, error) {
+func (d *Utm45Driver) Utmctl(ctx context.Context, args ...string) (string, error) {
+	return runUtmctl(ctx, d.UtmctlPath, args...)
+}
+
+// runUtmctl runs the utmctl binary at utmctlPath with args and returns what
+// it printed on stdout.
+func runUtmctl(ctx context.Context, utmctlPath string, args ...string) (string, error) {
 	var stdout, stderr bytes.Buffer
 
+	if utmctlPath == "" {
+		return "", errors.New("utmctl is not available, make sure UTM is installed and utmctl is on the PATH")
+	}
+
 	log.Printf("Executing utmctl: %#v", args)
-	cmd := exec.Command(d.UtmctlPath, args...)
+	cmd := exec.CommandContext(ctx, utmctlPath, args...)
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
 	err := cmd.Run()
@@ -186,40 +549,141 @@ func (d *Utm45Driver) Utmctl(args ...string) (string, error) {
 		log.Printf("stderr: %s", stderrString)
 	}
 
+	if err != nil && isAutomationDenied(stderrString) {
+		return stdoutString, &AutomationPermissionError{Output: stderrString}
+	}
+
 	return stdoutString, err
 }
 
-func (d *Utm45Driver) Verify() error {
+// IsInstalled checks for UTM.app next to utmctl, then in the usual
+// application folders.
+func (d *Utm45Driver) IsInstalled() (bool, error) {
+	for _, path := range d.utmAppPaths() {
+		_, err := os.Stat(path)
+		if err == nil {
+			return true, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return false, err
+		}
+	}
+	return false, nil
+}
+
+func (d *Utm45Driver) utmAppPaths() []string {
+	var paths []string
+	// utmctl is usually a link to UTM.app/Contents/MacOS/utmctl
+	if appPath, err := utmctlAppPath(d.UtmctlPath); err == nil {
+		paths = append(paths, appPath)
+	}
+	paths = append(paths, "/Applications/UTM.app")
+	if home, err := os.UserHomeDir(); err == nil {
+		paths = append(paths, filepath.Join(home, "Applications", "UTM.app"))
+	}
+	return paths
+}
+
+// CheckAutomation sends UTM a harmless Apple event, which also launches it
+// if it is not running.
+func (d *Utm45Driver) CheckAutomation() error {
+	var stderr bytes.Buffer
+
+	cmd := exec.Command(d.osascript(), "-e",
+		`tell application "UTM" to return count of virtual machines`)
+	cmd.Stderr = &stderr
+	if err := runOsascript(cmd); err != nil {
+		return automationError(strings.TrimSpace(stderr.String()), err)
+	}
 	return nil
 }
 
+// EnsureRunning launches UTM in the background when it isn't running and
+// AutoLaunch is set, and waits up to a minute for it to answer Apple events.
+func (d *Utm45Driver) EnsureRunning() error {
+	return ensureUTMRunning(d.isUTMRunning, launchUTM, d.CheckAutomation, d.AutoLaunch, time.Minute, time.Second)
+}
+
+// isUTMRunning tells whether UTM is running, without launching it.
+func (d *Utm45Driver) isUTMRunning() (bool, error) {
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.Command(d.osascript(), "-e",
+		fmt.Sprintf(`return application id "%s" is running`, UTMBundleID))
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := runOsascript(cmd); err != nil {
+		return false, automationError(strings.TrimSpace(stderr.String()), err)
+	}
+	return strings.TrimSpace(stdout.String()) == "true", nil
+}
+
+// launchUTM opens UTM in the background, so it doesn't take the focus from
+// the terminal running Packer.
+func launchUTM() error {
+	log.Printf("Launching UTM")
+	output, err := exec.Command("open", "-g", "-b", UTMBundleID).CombinedOutput()
+	if err != nil && len(output) > 0 {
+		return fmt.Errorf("%s: %s", err, strings.TrimSpace(string(output)))
+	}
+	return err
+}
+
+// isAutomationDenied reports whether osascript output is one of the Apple
+// event errors macOS returns when automation is not allowed.
+func isAutomationDenied(stderr string) bool {
+	// -1743: errAEEventNotPermitted, -10004: privilege violation
+	return strings.Contains(stderr, "-1743") || strings.Contains(stderr, "-10004") ||
+		strings.Contains(stderr, "Not authorized to send Apple events")
+}
+
+// automationError turns a failed osascript call into an error, recognizing
+// the Apple event errors macOS returns when automation is not allowed.
+func automationError(stderr string, err error) error {
+	if isAutomationDenied(stderr) {
+		return &AutomationPermissionError{Output: stderr}
+	}
+	if stderr != "" {
+		return fmt.Errorf("error scripting UTM: %s", stderr)
+	}
+	return fmt.Errorf("error scripting UTM: %s", err)
+}
+
+// Verify checks that the plugin was built with all the AppleScripts the
+// builders need, unmodified.
+func (d *Utm45Driver) Verify() error {
+	if err := verifyScripts(osascripts); err != nil {
+		return err
+	}
+	return verifyScriptChecksums(osascripts, scriptChecksums, d.ScriptsDir)
+}
+
 // Version reads the version of UTM that is installed.
 func (d *Utm45Driver) Version() (string, error) {
 	var stdout bytes.Buffer
 
-	cmd := exec.Command("osascript", "-e",
-		`tell application "System Events" to return version of application "UTM"`)
+	cmd := exec.Command(d.osascript(), "-e",
+		`tell application "System Events" to return "VERSION=" & (version of application "UTM")`)
 
 	cmd.Stdout = &stdout
-	if err := cmd.Run(); err != nil {
+	if err := runOsascript(cmd); err != nil {
 		return "", err
 	}
 
 	versionOutput := strings.TrimSpace(stdout.String())
 	log.Printf("UTM version output : %s", versionOutput)
 
-	// Check if the output contains the error message
-	if strings.Contains(versionOutput, "get application") {
+	raw, ok := markerValue(versionOutput, MarkerVersion)
+	if !ok || raw == "" {
 		return "", fmt.Errorf("UTM is not installed")
 	}
 
-	versionRe := regexp.MustCompile(`^(\d+\.\d+\.\d+)$`)
-	matches := versionRe.FindStringSubmatch(versionOutput)
-	if matches == nil || len(matches) != 2 {
-		return "", fmt.Errorf("no version found: %s", versionOutput)
+	version, err := ParseUTMVersion(raw)
+	if err != nil {
+		return "", err
 	}
 
-	log.Printf("UTM version: %s", matches[1])
-	return matches[1], nil
+	log.Printf("UTM version: %s", version)
+	return version.String(), nil
 
 }
@@ -4,9 +4,395 @@
 package common
 
 import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestUtm45Driver_impl(t *testing.T) {
 	var _ Driver = new(Utm45Driver)
 }
+
+func TestUtm45Driver_utmAppPaths(t *testing.T) {
+	td := t.TempDir()
+	utmctl := filepath.Join(td, "UTM.app", "Contents", "MacOS", "utmctl")
+	if err := os.MkdirAll(filepath.Dir(utmctl), 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := os.WriteFile(utmctl, nil, 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	link := filepath.Join(td, "utmctl")
+	if err := os.Symlink(utmctl, link); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	driver := &Utm45Driver{UtmctlPath: link}
+	paths := driver.utmAppPaths()
+	if len(paths) == 0 || paths[0] != filepath.Join(td, "UTM.app") {
+		t.Fatalf("bad paths: %#v", paths)
+	}
+
+	installed, err := driver.IsInstalled()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !installed {
+		t.Fatal("should be installed")
+	}
+}
+
+func TestIsAutomationDenied(t *testing.T) {
+	cases := map[string]bool{
+		"execution error: Not authorized to send Apple events to UTM. (-1743)":   true,
+		"execution error: A privilege violation occurred. (-10004)":              true,
+		"execution error: UTM got an error: Can't get virtual machine id \"x\".": false,
+		"": false,
+	}
+	for stderr, expected := range cases {
+		if actual := isAutomationDenied(stderr); actual != expected {
+			t.Errorf("%q: expected %t, got %t", stderr, expected, actual)
+		}
+	}
+}
+
+func TestOsaScriptEnv(t *testing.T) {
+	base := []string{"HOME=/Users/packer"}
+	env := osaScriptEnv(base, map[string]string{
+		ScriptEnvVMName:    "vm-name",
+		ScriptEnvBuildName: "foo",
+	})
+
+	expected := []string{
+		"HOME=/Users/packer",
+		"PACKER_BUILD_NAME=foo",
+		"PACKER_UTM_VM_NAME=vm-name",
+	}
+	if !reflect.DeepEqual(env, expected) {
+		t.Fatalf("bad env: %#v", env)
+	}
+	if len(base) != 1 {
+		t.Fatalf("should not modify base: %#v", base)
+	}
+}
+
+func TestOsaScriptOutput_Combined(t *testing.T) {
+	cases := []struct {
+		output   OsaScriptOutput
+		expected string
+	}{
+		{OsaScriptOutput{}, ""},
+		{OsaScriptOutput{Stdout: "out"}, "out"},
+		{OsaScriptOutput{Stderr: "err"}, "err"},
+		{OsaScriptOutput{Stdout: "out", Stderr: "err"}, "out\nerr"},
+	}
+	for _, tc := range cases {
+		if actual := tc.output.Combined(); actual != tc.expected {
+			t.Errorf("%#v: expected %q, got %q", tc.output, tc.expected, actual)
+		}
+	}
+}
+
+func TestUtm45Driver_RunApplescriptInline(t *testing.T) {
+	// Stand in for osascript with a script echoing its input back.
+	bin := t.TempDir()
+	fake := "#!/bin/sh\nshift\nprintf '%s|' \"$@\"\ncat\necho \"$PACKER_UTM_VM_ID\" >&2\n"
+	if err := os.WriteFile(filepath.Join(bin, "osascript"), []byte(fake), 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	driver := new(Utm45Driver)
+	output, err := driver.RunApplescriptInline(context.Background(),
+		`on run argv`, map[string]string{ScriptEnvVMID: "vm-id"}, "a b", `"quoted"`)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if output.Stdout != `a b|"quoted"|on run argv` {
+		t.Fatalf("should pass args untouched and the script on stdin: %q", output.Stdout)
+	}
+	if output.Stderr != "vm-id" {
+		t.Fatalf("should pass env: %q", output.Stderr)
+	}
+
+	if _, err := driver.RunApplescriptInline(context.Background(), "  ", nil); err == nil {
+		t.Fatal("should fail without a script")
+	}
+}
+
+func TestUtm45Driver_ExecuteOsaScript(t *testing.T) {
+	// Stand in for osascript with a script printing the script it was fed.
+	shim := filepath.Join(t.TempDir(), "osascript-shim")
+	if err := os.WriteFile(shim, []byte("#!/bin/sh\ncat\n"), 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	bundled, err := osascripts.ReadFile("scripts/attach_iso.applescript")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// The bundled script runs from any directory, with no copy on disk.
+	t.Chdir(t.TempDir())
+	driver := &Utm45Driver{OsascriptPath: shim}
+	output, err := driver.ExecuteOsaScriptOutput(context.Background(), nil, "attach_iso.applescript", "vm-id")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if output.Stdout != strings.TrimSpace(string(bundled)) {
+		t.Fatalf("should run the bundled script: %q", output.Stdout)
+	}
+
+	// A copy in scripts_dir wins over the bundled one.
+	scriptsDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(scriptsDir, "attach_iso.applescript"), []byte("-- patched"), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	driver.ScriptsDir = scriptsDir
+	output, err = driver.ExecuteOsaScriptOutput(context.Background(), nil, "attach_iso.applescript", "vm-id")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if output.Stdout != "-- patched" {
+		t.Fatalf("should run the script from scripts_dir: %q", output.Stdout)
+	}
+}
+
+func TestUtm45Driver_cancel(t *testing.T) {
+	// Stand in for osascript and utmctl with a script that hangs.
+	shim := filepath.Join(t.TempDir(), "hang")
+	if err := os.WriteFile(shim, []byte("#!/bin/sh\nexec sleep 30\n"), 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	driver := &Utm45Driver{OsascriptPath: shim, UtmctlPath: shim}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := driver.ExecuteOsaScript(ctx, "get_status.applescript", "vm-id"); err == nil {
+		t.Fatal("should fail when cancelled")
+	}
+	if _, err := driver.Utmctl(ctx, "status", "vm-id"); err == nil {
+		t.Fatal("should fail when cancelled")
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Fatalf("should stop promptly, took %s", elapsed)
+	}
+}
+
+func TestUtm45Driver_ExecuteOsaScript_stdout(t *testing.T) {
+	// Stand in for osascript with a script printing on both streams.
+	shim := filepath.Join(t.TempDir(), "osascript-shim")
+	if err := os.WriteFile(shim, []byte("#!/bin/sh\necho out\necho warning >&2\n"), 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Only stdout is the result of the script
+	driver := &Utm45Driver{OsascriptPath: shim}
+	output, err := driver.ExecuteOsaScript(context.Background(), "attach_iso.applescript", "vm-id")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if output != "out" {
+		t.Fatalf("should only return stdout: %q", output)
+	}
+}
+
+func TestUtm45Driver_RenameVM_invalidName(t *testing.T) {
+	// osascript is never run for a name UTM can't take.
+	driver := &Utm45Driver{OsascriptPath: filepath.Join(t.TempDir(), "missing")}
+	if err := driver.RenameVM("vm-id", "a/b"); err == nil || !strings.Contains(err.Error(), "must not contain") {
+		t.Fatalf("should reject the name: %v", err)
+	}
+}
+
+func TestUtm45Driver_OsascriptPath(t *testing.T) {
+	shim := filepath.Join(t.TempDir(), "osascript-shim")
+	if err := os.WriteFile(shim, []byte("#!/bin/sh\necho shim \"$@\"\n"), 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	driver := &Utm45Driver{OsascriptPath: shim}
+	output, err := driver.RunApplescriptInline(context.Background(), `on run argv`, nil, "arg")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if output.Stdout != "shim - arg" {
+		t.Fatalf("should run osascript_path: %q", output.Stdout)
+	}
+}
+
+func TestParseDrives(t *testing.T) {
+	output := "7FB247A3-DC9F-4A61-A123-0AEE1BEEC636\tvirtio\tfalse\t/Users/me/Library/Containers/com.utmapp.UTM/Data/Documents/vm.utm/Data/disk.qcow2\n" +
+		"0AEE1BEE-DC9F-4A61-A123-7FB247A3C636\tUSB\ttrue\t/Users/me/iso/boot image.iso\r\n" +
+		"A1B2C3D4-0000-0000-0000-000000000000\tusb\ttrue\t\n"
+
+	drives, err := parseDrives(output)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	expected := []Drive{
+		{
+			ID:        "7FB247A3-DC9F-4A61-A123-0AEE1BEEC636",
+			Interface: "virtio",
+			Source:    "/Users/me/Library/Containers/com.utmapp.UTM/Data/Documents/vm.utm/Data/disk.qcow2",
+		},
+		{ID: "0AEE1BEE-DC9F-4A61-A123-7FB247A3C636", Interface: "usb", Removable: true, Source: "/Users/me/iso/boot image.iso"},
+		{ID: "A1B2C3D4-0000-0000-0000-000000000000", Interface: "usb", Removable: true},
+	}
+	if !reflect.DeepEqual(drives, expected) {
+		t.Fatalf("bad drives: %#v", drives)
+	}
+
+	if drives, err := parseDrives("\n"); err != nil || len(drives) != 0 {
+		t.Fatalf("should parse a VM without drives: %#v %v", drives, err)
+	}
+	if _, err := parseDrives("7FB247A3-DC9F-4A61-A123-0AEE1BEEC636 usb\n"); err == nil {
+		t.Fatal("should reject a malformed listing")
+	}
+}
+
+func TestWaitForVMStart(t *testing.T) {
+	states := []string{"starting", "starting", "started"}
+	status := func() (string, error) {
+		state := states[0]
+		states = states[1:]
+		return state, nil
+	}
+	if err := waitForVMStart(context.Background(), status, time.Minute, time.Millisecond); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(states) != 0 {
+		t.Fatalf("should poll until started: %#v", states)
+	}
+}
+
+func TestWaitForVMStart_exited(t *testing.T) {
+	states := []string{"starting", "stopped"}
+	status := func() (string, error) {
+		state := states[0]
+		states = states[1:]
+		return state, nil
+	}
+	err := waitForVMStart(context.Background(), status, time.Minute, time.Millisecond)
+	if err == nil || !strings.Contains(err.Error(), "QEMU most likely exited") {
+		t.Fatalf("should report that QEMU exited: %v", err)
+	}
+}
+
+func TestWaitForVMStart_neverRunning(t *testing.T) {
+	status := func() (string, error) {
+		return "starting", nil
+	}
+	err := waitForVMStart(context.Background(), status, 20*time.Millisecond, time.Millisecond)
+	if err == nil || !strings.Contains(err.Error(), `still "starting" after waiting 20ms`) {
+		t.Fatalf("should time out with the last state: %v", err)
+	}
+}
+
+func TestWaitForVMStart_statusError(t *testing.T) {
+	status := func() (string, error) {
+		return "", errors.New("Utmctl error: no VM")
+	}
+	err := waitForVMStart(context.Background(), status, time.Minute, time.Millisecond)
+	if err == nil || !strings.Contains(err.Error(), "no VM") {
+		t.Fatalf("should report the status error: %v", err)
+	}
+}
+
+func TestWaitForState(t *testing.T) {
+	states := []string{"started", "stopping", "stopped"}
+	status := func() (string, error) {
+		state := states[0]
+		states = states[1:]
+		return state, nil
+	}
+	if err := waitForState(context.Background(), status, "stopped", time.Minute, time.Millisecond); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(states) != 0 {
+		t.Fatalf("should poll until stopped: %#v", states)
+	}
+}
+
+func TestWaitForState_timeout(t *testing.T) {
+	status := func() (string, error) {
+		return "stopping", nil
+	}
+	err := waitForState(context.Background(), status, "stopped", 20*time.Millisecond, time.Millisecond)
+	if err == nil || !strings.Contains(err.Error(), `still "stopping" after waiting 20ms for it to be stopped`) {
+		t.Fatalf("should time out with the last state: %v", err)
+	}
+}
+
+func TestWaitForState_unknownState(t *testing.T) {
+	status := func() (string, error) {
+		t.Fatal("should not poll for an unknown state")
+		return "", nil
+	}
+	err := waitForState(context.Background(), status, "running", time.Minute, time.Millisecond)
+	if err == nil || !strings.Contains(err.Error(), `unknown VM state "running"`) {
+		t.Fatalf("should reject the state: %v", err)
+	}
+}
+
+func TestWaitForVMStart_cancelled(t *testing.T) {
+	status := func() (string, error) {
+		return "starting", nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := waitForVMStart(ctx, status, time.Minute, time.Second)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("should stop on cancellation: %v", err)
+	}
+}
+
+func TestIsSuspendUnsupported(t *testing.T) {
+	if !isSuspendUnsupported("execution error: UTM got an error: Operation not supported by the backend. (-2700)") {
+		t.Fatal("should recognize a backend that can't save the state")
+	}
+	if isSuspendUnsupported("execution error: UTM got an error: Invalid virtual machine. (-2700)") {
+		t.Fatal("should not mistake other errors")
+	}
+}
+
+func TestUtm45Driver_appleScriptBackend(t *testing.T) {
+	// Stand in for osascript with a script printing its arguments, and
+	// "started" for the status script.
+	shim := filepath.Join(t.TempDir(), "osascript-shim")
+	script := "#!/bin/sh\nif grep -q 'status of vm'; then echo started; else echo \"$@\"; fi\n"
+	if err := os.WriteFile(shim, []byte(script), 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// utmctl is never run, UtmctlDriver does.
+	driver := &Utm45Driver{
+		UtmctlPath:    filepath.Join(t.TempDir(), "missing"),
+		OsascriptPath: shim,
+	}
+	running, err := driver.IsRunning("vm-id")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !running {
+		t.Fatal("should read the status with AppleScript")
+	}
+	output, err := driver.vmCommand(context.Background(), "stop", "vm-id", "--request")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if output != "- vm-id --request" {
+		t.Fatalf("should pass the arguments to the script: %q", output)
+	}
+	if err := driver.PowerOff("vm-id", true); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, err := driver.vmCommand(context.Background(), "list", "vm-id"); err == nil {
+		t.Fatal("should have error for a command without a script")
+	}
+}
@@ -2,12 +2,15 @@ package common
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io/fs"
 	"log"
 	"os"
 	"os/exec"
 	"path/filepath"
-	"regexp"
+	"time"
 )
 
 // Utm46Driver are inherited from Utm45Driver.
@@ -21,41 +24,47 @@ func (d *Utm46Driver) Import(path string) (string, error) {
 
 	// Import VM
 	cmd := exec.Command(
-		"osascript", "-e",
-		fmt.Sprintf(`tell application "UTM" to import new virtual machine from POSIX file "%s"`, path),
+		d.osascript(), "-e",
+		fmt.Sprintf(`tell application "UTM" to return "UUID=" & (id of (import new virtual machine from POSIX file "%s"))`, path),
 	)
 	cmd.Stdout = &stdout
-	if err := cmd.Run(); err != nil {
+	if err := runOsascript(cmd); err != nil {
 		return "", err
 	}
 
 	// Get the output of the command
 	output := stdout.String()
 
-	// Regular expression to capture the VM ID
-	re := regexp.MustCompile(`virtual machine id ([A-F0-9-]+)`)
-	matches := re.FindStringSubmatch(output)
-	if len(matches) > 1 {
-		vmId := matches[1] // Capture the VM ID
-		return vmId, nil
+	vmId, err := ParseUUID(output)
+	if err != nil {
+		return "", fmt.Errorf("failed to import VM: %s", output)
 	}
-
-	return "", fmt.Errorf("failed to import VM: %s", output)
+	return vmId, nil
 }
 
 // Export VM to UTM file
 func (d *Utm46Driver) Export(vmId string, path string) error {
+	return d.ExportWithProgress(context.Background(), vmId, path, 0, nil)
+}
+
+// UTM doesn't report the progress of an export, so it is read from the size
+// of the bundle growing at path.
+func (d *Utm46Driver) ExportWithProgress(ctx context.Context, vmId string, path string, interval time.Duration, progress func(written uint64)) error {
 	var stdout bytes.Buffer
 
 	// Export VM
-	cmd := exec.Command(
-		"osascript", "-e",
+	cmd := exec.CommandContext(ctx,
+		d.osascript(), "-e",
 		fmt.Sprintf(`tell application "UTM" to export virtual machine id "%s" to POSIX file "%s"`, vmId, path),
 	)
 	// print command to log
 	log.Printf("Executing command: %s", cmd.String())
 	cmd.Stdout = &stdout
-	if err := cmd.Run(); err != nil {
+	err := watchExport(path, interval, progress, func() error { return runOsascript(cmd) })
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return fmt.Errorf("export interrupted: %w", ctxErr)
+	}
+	if err != nil {
 		return err
 	}
 
@@ -66,13 +75,40 @@ func (d *Utm46Driver) Export(vmId string, path string) error {
 
 // Return the downloaded guest tools path if available.
 func (d *Utm46Driver) GuestToolsIsoPath() (string, error) {
-	// The default path to the guest tools, where UTM downloads it
-	guestToolsPath := filepath.Join(os.Getenv("HOME"), "Library/Containers/com.utmapp.UTM/Data/Library/Application Support/GuestSupportTools/utm-guest-tools-latest.iso")
+	// The UTM sandbox container, and the default path to the guest tools
+	// within it, where UTM downloads it
+	containerPath := filepath.Join(os.Getenv("HOME"), "Library/Containers/com.utmapp.UTM")
+	guestToolsPath := filepath.Join(containerPath, "Data/Library/Application Support/GuestSupportTools/utm-guest-tools-latest.iso")
+
+	return guestToolsIsoPath(containerPath, guestToolsPath)
+}
+
+// guestToolsIsoPath checks that the guest tools ISO exists and is readable,
+// classifying any failure into a GuestToolsError.
+func guestToolsIsoPath(containerPath string, guestToolsPath string) (string, error) {
+	if _, err := os.Stat(containerPath); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return "", &GuestToolsError{Reason: GuestToolsReasonUtmNotInstalled, Path: containerPath}
+		}
+		return "", newGuestToolsError(guestToolsPath, err)
+	}
 
-	// Check if the file exists
-	if _, err := os.Stat(guestToolsPath); os.IsNotExist(err) {
-		return "", fmt.Errorf("guest tools ISO not found at path: %s", guestToolsPath)
+	f, err := os.Open(guestToolsPath)
+	if err != nil {
+		return "", newGuestToolsError(guestToolsPath, err)
 	}
+	_ = f.Close()
 
 	return guestToolsPath, nil
 }
+
+func newGuestToolsError(path string, err error) error {
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return &GuestToolsError{Reason: GuestToolsReasonNotBundled, Path: path}
+	case errors.Is(err, fs.ErrPermission):
+		return &GuestToolsError{Reason: GuestToolsReasonPermissionDenied, Path: path, Err: err}
+	default:
+		return fmt.Errorf("error checking guest tools ISO at path %s: %s", path, err)
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestUtm46Driver_impl(t *testing.T) {
+	var _ Driver = new(Utm46Driver)
+}
+
+func testGuestToolsReason(t *testing.T, err error, reason string) {
+	var gtErr *GuestToolsError
+	if !errors.As(err, &gtErr) {
+		t.Fatalf("expected GuestToolsError, got: %#v", err)
+	}
+	if gtErr.Reason != reason {
+		t.Fatalf("expected reason %q, got %q", reason, gtErr.Reason)
+	}
+}
+
+func TestGuestToolsIsoPath_utmNotInstalled(t *testing.T) {
+	dir := t.TempDir()
+	containerPath := filepath.Join(dir, "missing")
+
+	_, err := guestToolsIsoPath(containerPath, filepath.Join(containerPath, "tools.iso"))
+	testGuestToolsReason(t, err, GuestToolsReasonUtmNotInstalled)
+}
+
+func TestGuestToolsIsoPath_notBundled(t *testing.T) {
+	dir := t.TempDir()
+
+	_, err := guestToolsIsoPath(dir, filepath.Join(dir, "tools.iso"))
+	testGuestToolsReason(t, err, GuestToolsReasonNotBundled)
+}
+
+func TestGuestToolsIsoPath_permissionDenied(t *testing.T) {
+	err := newGuestToolsError("tools.iso", &fs.PathError{Op: "open", Path: "tools.iso", Err: fs.ErrPermission})
+	testGuestToolsReason(t, err, GuestToolsReasonPermissionDenied)
+	if !errors.Is(err, fs.ErrPermission) {
+		t.Fatalf("should wrap the permission error: %#v", err)
+	}
+}
+
+func TestGuestToolsIsoPath_found(t *testing.T) {
+	dir := t.TempDir()
+	isoPath := filepath.Join(dir, "tools.iso")
+	if err := os.WriteFile(isoPath, []byte("iso"), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	path, err := guestToolsIsoPath(dir, isoPath)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if path != isoPath {
+		t.Fatalf("bad path: %s", path)
+	}
+}
+
+func TestUtm45Driver_GuestToolsIsoPath(t *testing.T) {
+	d := new(Utm45Driver)
+	_, err := d.GuestToolsIsoPath()
+	testGuestToolsReason(t, err, GuestToolsReasonNotBundled)
+}
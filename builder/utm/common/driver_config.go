@@ -0,0 +1,127 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:generate packer-sdc struct-markdown
+
+package common
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+
+	"github.com/hashicorp/packer-plugin-sdk/template/config"
+)
+
+// OsascriptPathEnv is the environment variable overriding the osascript
+// binary when osascript_path is not set.
+const OsascriptPathEnv = "PACKER_UTM_OSASCRIPT_PATH"
+
+// The backends driver_backend can drive UTM with.
+const (
+	DriverBackendAuto        = "auto"
+	DriverBackendAppleScript = "applescript"
+	DriverBackendUtmctl      = "utmctl"
+)
+
+// DriverBackends lists the values driver_backend accepts.
+var DriverBackends = []string{DriverBackendAuto, DriverBackendAppleScript, DriverBackendUtmctl}
+
+type DriverConfig struct {
+	// The path to the osascript binary the plugin runs AppleScript with,
+	// for example a wrapper script. Defaults to the value of the
+	// `PACKER_UTM_OSASCRIPT_PATH` environment variable, or `osascript` found
+	// on the `PATH` when that isn't set either.
+	OsascriptPath string `mapstructure:"osascript_path" required:"false"`
+	// A directory with patched copies of the plugin's AppleScripts, such as
+	// `attach_iso.applescript`, to use instead of the bundled ones. Scripts
+	// missing from the directory fall back to the bundled version. This is a
+	// stopgap for when a UTM release changes its scripting API before the
+	// plugin catches up. Files not named after one of the plugin's scripts
+	// are an error, so a misnamed patch doesn't go unnoticed.
+	// The bundled scripts are checked against the checksums the plugin was
+	// built with before the build starts, except those this directory
+	// replaces.
+	ScriptsDir string `mapstructure:"scripts_dir" required:"false"`
+	// How the plugin starts, stops, deletes and polls the status of VMs,
+	// and reads the UTM version and the IP addresses of the guest:
+	// `utmctl`, the command line tool shipped with UTM, `applescript`, or
+	// `auto` to use utmctl when it is found on the `PATH` and AppleScript
+	// otherwise. Defaults to `auto`. Everything utmctl has no command for,
+	// such as attaching ISOs, exporting or editing the VM configuration,
+	// always goes through AppleScript.
+	DriverBackend string `mapstructure:"driver_backend" required:"false"`
+	// Launch UTM in the background when it isn't running, and wait for it
+	// to be ready before the build goes on. When false, the build fails
+	// right away if UTM isn't running. Defaults to `true`.
+	AutoLaunchUTM config.Trilean `mapstructure:"auto_launch_utm" required:"false"`
+}
+
+func (c *DriverConfig) Prepare() []error {
+	var errs []error
+
+	if c.OsascriptPath == "" {
+		c.OsascriptPath = os.Getenv(OsascriptPathEnv)
+	}
+	if c.OsascriptPath != "" {
+		info, err := os.Stat(c.OsascriptPath)
+		switch {
+		case err != nil:
+			errs = append(errs, fmt.Errorf("osascript_path: %s", err))
+		case info.IsDir() || info.Mode().Perm()&0111 == 0:
+			errs = append(errs, fmt.Errorf("osascript_path: %s is not an executable file", c.OsascriptPath))
+		}
+	}
+
+	if c.ScriptsDir != "" {
+		if err := verifyScriptsDir(c.ScriptsDir); err != nil {
+			errs = append(errs, fmt.Errorf("scripts_dir: %s", err))
+		}
+	}
+
+	if c.AutoLaunchUTM == config.TriUnset {
+		c.AutoLaunchUTM = config.TriTrue
+	}
+
+	if c.DriverBackend == "" {
+		c.DriverBackend = DriverBackendAuto
+	}
+	if !slices.Contains(DriverBackends, c.DriverBackend) {
+		errs = append(errs, fmt.Errorf("driver_backend must be one of %s, got %q",
+			strings.Join(DriverBackends, ", "), c.DriverBackend))
+	} else if c.DriverBackend == DriverBackendUtmctl {
+		if _, err := exec.LookPath("utmctl"); err != nil {
+			errs = append(errs, fmt.Errorf("driver_backend is utmctl, but utmctl is not available: %s", err))
+		}
+	}
+
+	return errs
+}
+
+// resolveDriverBackend picks the backend to use for backend, looking utmctl
+// up with lookPath, and returns it along with the path to utmctl, empty when
+// it wasn't found.
+func resolveDriverBackend(backend string, lookPath func(string) (string, error)) (string, string, error) {
+	utmctlPath, err := lookPath("utmctl")
+	switch backend {
+	case DriverBackendAppleScript:
+		// utmctl still helps finding UTM.app when it is there.
+		if err != nil {
+			utmctlPath = ""
+		}
+		return DriverBackendAppleScript, utmctlPath, nil
+	case DriverBackendUtmctl:
+		if err != nil {
+			return "", "", fmt.Errorf("utmctl not found, is UTM installed? %s", err)
+		}
+		return DriverBackendUtmctl, utmctlPath, nil
+	case "", DriverBackendAuto:
+		if err != nil {
+			return DriverBackendAppleScript, "", nil
+		}
+		return DriverBackendUtmctl, utmctlPath, nil
+	}
+	return "", "", fmt.Errorf("unknown driver_backend %q", backend)
+}
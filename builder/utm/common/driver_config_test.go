@@ -0,0 +1,171 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/template/config"
+)
+
+func TestDriverConfigPrepare(t *testing.T) {
+	t.Setenv(OsascriptPathEnv, "")
+
+	c := new(DriverConfig)
+	if errs := c.Prepare(); len(errs) > 0 {
+		t.Fatalf("should not have error: %s", errs)
+	}
+	if c.OsascriptPath != "" {
+		t.Fatalf("should default to osascript from PATH: %q", c.OsascriptPath)
+	}
+}
+
+func TestDriverConfigPrepare_osascriptPath(t *testing.T) {
+	t.Setenv(OsascriptPathEnv, "")
+
+	dir := t.TempDir()
+	shim := filepath.Join(dir, "osascript-shim")
+	if err := os.WriteFile(shim, []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	notExecutable := filepath.Join(dir, "not-executable")
+	if err := os.WriteFile(notExecutable, []byte("#!/bin/sh\n"), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	c := &DriverConfig{OsascriptPath: shim}
+	if errs := c.Prepare(); len(errs) > 0 {
+		t.Fatalf("should not have error: %s", errs)
+	}
+
+	for _, path := range []string{notExecutable, dir, filepath.Join(dir, "missing")} {
+		c := &DriverConfig{OsascriptPath: path}
+		if errs := c.Prepare(); len(errs) != 1 {
+			t.Fatalf("%s: should have error: %s", path, errs)
+		}
+	}
+}
+
+func TestDriverConfigPrepare_env(t *testing.T) {
+	shim := filepath.Join(t.TempDir(), "osascript-shim")
+	if err := os.WriteFile(shim, []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	t.Setenv(OsascriptPathEnv, shim)
+
+	c := new(DriverConfig)
+	if errs := c.Prepare(); len(errs) > 0 {
+		t.Fatalf("should not have error: %s", errs)
+	}
+	if c.OsascriptPath != shim {
+		t.Fatalf("should use %s: %q", OsascriptPathEnv, c.OsascriptPath)
+	}
+
+	// The config wins over the environment.
+	c = &DriverConfig{OsascriptPath: "/nonexistent/osascript"}
+	if errs := c.Prepare(); len(errs) != 1 {
+		t.Fatalf("should validate osascript_path over the environment: %s", errs)
+	}
+}
+
+func TestDriverConfigPrepare_scriptsDir(t *testing.T) {
+	t.Setenv(OsascriptPathEnv, "")
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "attach_iso.applescript"), nil, 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	c := &DriverConfig{ScriptsDir: dir}
+	if errs := c.Prepare(); len(errs) > 0 {
+		t.Fatalf("should not have error: %s", errs)
+	}
+
+	c = &DriverConfig{ScriptsDir: t.TempDir()}
+	if errs := c.Prepare(); len(errs) != 1 {
+		t.Fatalf("should reject a directory without scripts: %s", errs)
+	}
+}
+
+func TestDriverConfigPrepare_driverBackend(t *testing.T) {
+	t.Setenv(OsascriptPathEnv, "")
+
+	c := new(DriverConfig)
+	if errs := c.Prepare(); len(errs) > 0 {
+		t.Fatalf("should not have error: %s", errs)
+	}
+	if c.DriverBackend != DriverBackendAuto {
+		t.Fatalf("should default to auto: %q", c.DriverBackend)
+	}
+
+	c = &DriverConfig{DriverBackend: DriverBackendAppleScript}
+	if errs := c.Prepare(); len(errs) > 0 {
+		t.Fatalf("should not have error: %s", errs)
+	}
+
+	c = &DriverConfig{DriverBackend: "cli"}
+	if errs := c.Prepare(); len(errs) != 1 {
+		t.Fatalf("should reject an unknown backend: %s", errs)
+	}
+
+	// utmctl must be on the PATH when asked for.
+	t.Setenv("PATH", t.TempDir())
+	c = &DriverConfig{DriverBackend: DriverBackendUtmctl}
+	if errs := c.Prepare(); len(errs) != 1 {
+		t.Fatalf("should require utmctl: %s", errs)
+	}
+}
+
+func TestResolveDriverBackend(t *testing.T) {
+	found := func(string) (string, error) { return "/usr/local/bin/utmctl", nil }
+	missing := func(file string) (string, error) { return "", exec.ErrNotFound }
+
+	cases := []struct {
+		backend  string
+		lookPath func(string) (string, error)
+		want     string
+		wantPath string
+		wantErr  bool
+	}{
+		{DriverBackendAuto, found, DriverBackendUtmctl, "/usr/local/bin/utmctl", false},
+		{DriverBackendAuto, missing, DriverBackendAppleScript, "", false},
+		{"", found, DriverBackendUtmctl, "/usr/local/bin/utmctl", false},
+		{DriverBackendUtmctl, found, DriverBackendUtmctl, "/usr/local/bin/utmctl", false},
+		{DriverBackendUtmctl, missing, "", "", true},
+		{DriverBackendAppleScript, found, DriverBackendAppleScript, "/usr/local/bin/utmctl", false},
+		{DriverBackendAppleScript, missing, DriverBackendAppleScript, "", false},
+		{"cli", found, "", "", true},
+	}
+	for _, tc := range cases {
+		backend, path, err := resolveDriverBackend(tc.backend, tc.lookPath)
+		if (err != nil) != tc.wantErr {
+			t.Fatalf("%q: unexpected error: %v", tc.backend, err)
+		}
+		if backend != tc.want || path != tc.wantPath {
+			t.Fatalf("%q: got %q, %q, want %q, %q", tc.backend, backend, path, tc.want, tc.wantPath)
+		}
+	}
+}
+
+func TestDriverConfigPrepare_autoLaunchUTM(t *testing.T) {
+	t.Setenv(OsascriptPathEnv, "")
+
+	c := new(DriverConfig)
+	if errs := c.Prepare(); len(errs) > 0 {
+		t.Fatalf("should not have error: %s", errs)
+	}
+	if !c.AutoLaunchUTM.True() {
+		t.Fatal("should default to launching UTM")
+	}
+
+	c = &DriverConfig{AutoLaunchUTM: config.TriFalse}
+	if errs := c.Prepare(); len(errs) > 0 {
+		t.Fatalf("should not have error: %s", errs)
+	}
+	if !c.AutoLaunchUTM.False() {
+		t.Fatal("should keep auto_launch_utm off")
+	}
+}
@@ -1,30 +1,122 @@
 package common
 
-import "sync"
+import (
+	"context"
+	"sync"
+	"time"
+)
 
 type DriverMock struct {
 	sync.Mutex
 
+	CloneCalls  [][]string
+	CloneLinked bool
+	CloneId     string
+	CloneErr    error
+
+	RenameVMCalls [][]string
+	RenameVMErr   error
+
 	DeleteCalled bool
 	DeleteName   string
 	DeleteErr    error
 
 	ExecuteOsaCalls  [][]string
+	ExecuteOsaEnvs   []map[string]string
 	ExecuteOsaErrs   []error
 	ExecuteOsaResult string
+	ExecuteOsaStderr string
+
+	RunInlineScripts []string
+	RunInlineArgs    [][]string
+	RunInlineEnvs    []map[string]string
+	RunInlineResult  OsaScriptOutput
+	RunInlineErr     error
 
 	GuestToolsIsoPathCalled bool
+	GuestToolsIsoPathResult string
 	GuestToolsIsoPathErr    error
 
+	ListAttachedDrivesCalls  []string
+	ListAttachedDrivesResult []Drive
+	ListAttachedDrivesErr    error
+
+	ExportCalls [][]string
+	// ExportProgress is reported by ExportWithProgress, one call per value.
+	ExportProgress []uint64
+	ExportErr      error
+
 	ImportCalled bool
 	ImportId     string
 	ImportPath   string
 	ImportErr    error
 
+	IsInstalledCalled bool
+	IsInstalledReturn bool
+	IsInstalledErr    error
+
+	CheckAutomationCalled bool
+	CheckAutomationErr    error
+
+	EnsureRunningCalled bool
+	EnsureRunningErr    error
+
 	IsRunningName   string
 	IsRunningReturn bool
 	IsRunningErr    error
 
+	IPAddressesCalls  []string
+	IPAddressesResult []string
+	IPAddressesErr    error
+
+	MonitorCommandCalls  [][]string
+	MonitorCommandResult string
+	MonitorCommandErr    error
+
+	BundlePathCalls  []string
+	BundlePathResult string
+	BundlePathErr    error
+
+	GetVMConfigCalls  []string
+	GetVMConfigResult VMConfig
+	GetVMConfigErr    error
+
+	GetBootOrderResult []string
+	GetBootOrderErr    error
+
+	SetBootOrderCalls [][]string
+	SetBootOrderErr   error
+
+	SupportedDriveInterfacesResult []string
+	SupportedDriveInterfacesErr    error
+
+	PowerOffCalls []bool
+	PowerOffErr   error
+
+	ScreenshotCalls  []string
+	ScreenshotResult []byte
+	ScreenshotErr    error
+
+	SendKeysCalls  [][]string
+	SendKeysEvents []KeyEvent
+	SendKeysErr    error
+
+	StartVMCalls   []string
+	StartVMTimeout time.Duration
+	StartVMErr     error
+
+	WaitForStateCalls []string
+	// WaitForStateStates scripts the states the VM goes through, one per
+	// poll. Once they run out, the VM is started or stopped depending on
+	// IsRunningReturn.
+	WaitForStateStates []string
+
+	SuspendCalls []string
+	SuspendErr   error
+
+	ResumeCalls []string
+	ResumeErr   error
+
 	StopName string
 	StopErr  error
 
@@ -40,28 +132,69 @@ type DriverMock struct {
 	VersionErr    error
 }
 
+func (d *DriverMock) Clone(sourceId string, newName string, linked bool) (string, error) {
+	d.CloneCalls = append(d.CloneCalls, []string{sourceId, newName})
+	d.CloneLinked = linked
+	return d.CloneId, d.CloneErr
+}
+
+func (d *DriverMock) RenameVM(vmId string, newName string) error {
+	d.RenameVMCalls = append(d.RenameVMCalls, []string{vmId, newName})
+	return d.RenameVMErr
+}
+
 func (d *DriverMock) Delete(name string) error {
 	d.DeleteCalled = true
 	d.DeleteName = name
 	return d.DeleteErr
 }
 
-func (d *DriverMock) ExecuteOsaScript(command ...string) (string, error) {
+func (d *DriverMock) ExecuteOsaScript(ctx context.Context, command ...string) (string, error) {
+	return d.ExecuteOsaScriptWithEnv(ctx, nil, command...)
+}
+
+func (d *DriverMock) ExecuteOsaScriptWithEnv(ctx context.Context, env map[string]string, command ...string) (string, error) {
+	output, err := d.ExecuteOsaScriptOutput(ctx, env, command...)
+	return output.Stdout, err
+}
+
+func (d *DriverMock) ExecuteOsaScriptOutput(ctx context.Context, env map[string]string, command ...string) (OsaScriptOutput, error) {
 	d.ExecuteOsaCalls = append(d.ExecuteOsaCalls, command)
+	d.ExecuteOsaEnvs = append(d.ExecuteOsaEnvs, env)
 
 	if len(d.ExecuteOsaErrs) >= len(d.ExecuteOsaCalls) {
-		return "", d.ExecuteOsaErrs[len(d.ExecuteOsaCalls)-1]
+		return OsaScriptOutput{}, d.ExecuteOsaErrs[len(d.ExecuteOsaCalls)-1]
 	}
-	return d.ExecuteOsaResult, nil
+	return OsaScriptOutput{Stdout: d.ExecuteOsaResult, Stderr: d.ExecuteOsaStderr}, nil
+}
+
+func (d *DriverMock) RunApplescriptInline(ctx context.Context, script string, env map[string]string, args ...string) (OsaScriptOutput, error) {
+	d.RunInlineScripts = append(d.RunInlineScripts, script)
+	d.RunInlineArgs = append(d.RunInlineArgs, args)
+	d.RunInlineEnvs = append(d.RunInlineEnvs, env)
+	return d.RunInlineResult, d.RunInlineErr
 }
 
 func (d *DriverMock) Export(vmId string, path string) error {
 	return nil
 }
 
+func (d *DriverMock) ExportWithProgress(ctx context.Context, vmId string, path string, interval time.Duration, progress func(written uint64)) error {
+	d.ExportCalls = append(d.ExportCalls, []string{vmId, path})
+	for _, written := range d.ExportProgress {
+		if progress != nil {
+			progress(written)
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return d.ExportErr
+}
+
 func (d *DriverMock) GuestToolsIsoPath() (string, error) {
 	d.GuestToolsIsoPathCalled = true
-	return "", d.GuestToolsIsoPathErr
+	return d.GuestToolsIsoPathResult, d.GuestToolsIsoPathErr
 }
 
 func (d *DriverMock) Import(path string) (string, error) {
@@ -70,6 +203,26 @@ func (d *DriverMock) Import(path string) (string, error) {
 	return "", d.ImportErr
 }
 
+func (d *DriverMock) IsInstalled() (bool, error) {
+	d.IsInstalledCalled = true
+	return d.IsInstalledReturn, d.IsInstalledErr
+}
+
+func (d *DriverMock) ListAttachedDrives(vmId string) ([]Drive, error) {
+	d.ListAttachedDrivesCalls = append(d.ListAttachedDrivesCalls, vmId)
+	return d.ListAttachedDrivesResult, d.ListAttachedDrivesErr
+}
+
+func (d *DriverMock) CheckAutomation() error {
+	d.CheckAutomationCalled = true
+	return d.CheckAutomationErr
+}
+
+func (d *DriverMock) EnsureRunning() error {
+	d.EnsureRunningCalled = true
+	return d.EnsureRunningErr
+}
+
 func (d *DriverMock) IsRunning(name string) (bool, error) {
 	d.Lock()
 	defer d.Unlock()
@@ -78,12 +231,86 @@ func (d *DriverMock) IsRunning(name string) (bool, error) {
 	return d.IsRunningReturn, d.IsRunningErr
 }
 
+func (d *DriverMock) IPAddresses(vmId string) ([]string, error) {
+	d.IPAddressesCalls = append(d.IPAddressesCalls, vmId)
+	return d.IPAddressesResult, d.IPAddressesErr
+}
+
+func (d *DriverMock) BundlePath(vmId string) (string, error) {
+	d.BundlePathCalls = append(d.BundlePathCalls, vmId)
+	return d.BundlePathResult, d.BundlePathErr
+}
+
+func (d *DriverMock) GetVMConfig(vmId string) (VMConfig, error) {
+	d.GetVMConfigCalls = append(d.GetVMConfigCalls, vmId)
+	return d.GetVMConfigResult, d.GetVMConfigErr
+}
+
+func (d *DriverMock) GetBootOrder(vmId string) ([]string, error) {
+	return d.GetBootOrderResult, d.GetBootOrderErr
+}
+
+func (d *DriverMock) SetBootOrder(vmId string, devices []string) error {
+	d.SetBootOrderCalls = append(d.SetBootOrderCalls, devices)
+	return d.SetBootOrderErr
+}
+
+func (d *DriverMock) SupportedDriveInterfaces(vmId string) ([]string, error) {
+	return d.SupportedDriveInterfacesResult, d.SupportedDriveInterfacesErr
+}
+
+func (d *DriverMock) PowerOff(vmId string, force bool) error {
+	d.PowerOffCalls = append(d.PowerOffCalls, force)
+	return d.PowerOffErr
+}
+
+func (d *DriverMock) StartVM(ctx context.Context, vmId string, timeout time.Duration) error {
+	d.StartVMCalls = append(d.StartVMCalls, vmId)
+	d.StartVMTimeout = timeout
+	return d.StartVMErr
+}
+
+func (d *DriverMock) WaitForState(ctx context.Context, vmId string, target string, timeout time.Duration, interval time.Duration) error {
+	d.Lock()
+	d.WaitForStateCalls = append(d.WaitForStateCalls, target)
+	d.Unlock()
+
+	status := func() (string, error) {
+		d.Lock()
+		defer d.Unlock()
+
+		if len(d.WaitForStateStates) > 0 {
+			state := d.WaitForStateStates[0]
+			d.WaitForStateStates = d.WaitForStateStates[1:]
+			return state, nil
+		}
+		if d.IsRunningErr != nil {
+			return "", d.IsRunningErr
+		}
+		if d.IsRunningReturn {
+			return "started", nil
+		}
+		return "stopped", nil
+	}
+	return waitForState(ctx, status, target, timeout, interval)
+}
+
+func (d *DriverMock) Suspend(vmId string) error {
+	d.SuspendCalls = append(d.SuspendCalls, vmId)
+	return d.SuspendErr
+}
+
+func (d *DriverMock) Resume(vmId string) error {
+	d.ResumeCalls = append(d.ResumeCalls, vmId)
+	return d.ResumeErr
+}
+
 func (d *DriverMock) Stop(name string) error {
 	d.StopName = name
 	return d.StopErr
 }
 
-func (d *DriverMock) Utmctl(args ...string) (string, error) {
+func (d *DriverMock) Utmctl(ctx context.Context, args ...string) (string, error) {
 	d.UtmctlCalls = append(d.UtmctlCalls, args)
 
 	if len(d.UtmctlErrs) >= len(d.UtmctlCalls) {
@@ -101,3 +328,38 @@ func (d *DriverMock) Version() (string, error) {
 	d.VersionCalled = true
 	return d.VersionResult, d.VersionErr
 }
+
+func (d *DriverMock) MonitorCommand(vmId string, cmd string) (string, error) {
+	d.MonitorCommandCalls = append(d.MonitorCommandCalls, []string{vmId, cmd})
+	return d.MonitorCommandResult, d.MonitorCommandErr
+}
+
+// Screenshot returns ScreenshotResult, or the bytes of a PNG signature
+// when it is not set.
+func (d *DriverMock) Screenshot(vmId string) ([]byte, error) {
+	d.Lock()
+	defer d.Unlock()
+
+	d.ScreenshotCalls = append(d.ScreenshotCalls, vmId)
+	if d.ScreenshotErr != nil {
+		return nil, d.ScreenshotErr
+	}
+	if d.ScreenshotResult == nil {
+		return []byte("\x89PNG\r\n\x1a\n"), nil
+	}
+	return d.ScreenshotResult, nil
+}
+
+// SendKeys records the keys as parsed by RunKeySequence in SendKeysEvents,
+// so the typed keys can be checked.
+func (d *DriverMock) SendKeys(ctx context.Context, vmId string, keys string) error {
+	d.SendKeysCalls = append(d.SendKeysCalls, []string{vmId, keys})
+	if d.SendKeysErr != nil {
+		return d.SendKeysErr
+	}
+
+	recorder := new(KeyRecorder)
+	err := RunKeySequence(ctx, recorder, keys)
+	d.SendKeysEvents = append(d.SendKeysEvents, recorder.Events...)
+	return err
+}
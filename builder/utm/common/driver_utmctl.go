@@ -0,0 +1,195 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// UtmctlDriver runs what utmctl has a command for with utmctl, which is
+// quicker than sending UTM Apple events, and everything else, such as
+// attaching ISOs or exporting, with the AppleScript driver it wraps. NewDriver
+// wraps the driver in it for the utmctl backend, it is the only driver
+// running VM lifecycle commands with utmctl.
+type UtmctlDriver struct {
+	Driver
+	// This is the path to the utmctl binary
+	UtmctlPath string
+}
+
+func (d *UtmctlDriver) utmctl(ctx context.Context, args ...string) (string, error) {
+	return runUtmctl(ctx, d.UtmctlPath, args...)
+}
+
+func (d *UtmctlDriver) Delete(vmId string) error {
+	_, err := d.utmctl(context.Background(), "delete", vmId)
+	return err
+}
+
+func (d *UtmctlDriver) IsRunning(vmId string) (bool, error) {
+	state, err := d.status(vmId)
+	if err != nil {
+		return false, err
+	}
+	// Like the AppleScript driver, a VM on its way to stopped or paused
+	// still counts as running.
+	return state == "started" || state == "stopping" || state == "paused", nil
+}
+
+// status reads the power state of the VM with the given id.
+func (d *UtmctlDriver) status(vmId string) (string, error) {
+	output, err := d.utmctl(context.Background(), "status", vmId)
+	if err != nil {
+		return "", err
+	}
+	return parseUtmctlStatus(output)
+}
+
+func (d *UtmctlDriver) IPAddresses(vmId string) ([]string, error) {
+	output, err := d.utmctl(context.Background(), "ip-address", vmId)
+	if err != nil {
+		return nil, fmt.Errorf("error reading the IP addresses of VM %s: %s", vmId, err)
+	}
+	return parseIPAddresses(output)
+}
+
+func (d *UtmctlDriver) StartVM(ctx context.Context, vmId string, timeout time.Duration) error {
+	output, err := d.utmctl(ctx, "start", vmId)
+	if err != nil {
+		return fmt.Errorf("error starting VM %s: %s", vmId, err)
+	}
+	status := func() (string, error) {
+		return d.status(vmId)
+	}
+	if err := waitForVMStart(ctx, status, timeout, time.Second); err != nil {
+		if output != "" {
+			return fmt.Errorf("error starting VM %s: %s\nutmctl start output: %s", vmId, err, output)
+		}
+		return fmt.Errorf("error starting VM %s: %s", vmId, err)
+	}
+	return nil
+}
+
+func (d *UtmctlDriver) WaitForState(ctx context.Context, vmId string, target string, timeout time.Duration, interval time.Duration) error {
+	status := func() (string, error) {
+		return d.status(vmId)
+	}
+	return waitForState(ctx, status, target, timeout, interval)
+}
+
+func (d *UtmctlDriver) PowerOff(vmId string, force bool) error {
+	mode := "--request"
+	if force {
+		mode = "--force"
+	}
+	_, err := d.utmctl(context.Background(), "stop", vmId, mode)
+	return err
+}
+
+func (d *UtmctlDriver) Stop(vmId string) error {
+	_, err := d.utmctl(context.Background(), "stop", vmId)
+	return err
+}
+
+func (d *UtmctlDriver) Utmctl(ctx context.Context, args ...string) (string, error) {
+	return d.utmctl(ctx, args...)
+}
+
+// Version reads the version of UTM from the Info.plist of the UTM.app
+// utmctl belongs to, without scripting UTM. It falls back to the wrapped
+// driver when utmctl isn't inside an app bundle.
+func (d *UtmctlDriver) Version() (string, error) {
+	appPath, err := utmctlAppPath(d.UtmctlPath)
+	if err != nil {
+		log.Printf("Can't find UTM.app from utmctl: %s", err)
+		return d.Driver.Version()
+	}
+	plist, err := os.ReadFile(filepath.Join(appPath, "Contents", "Info.plist"))
+	if err != nil {
+		log.Printf("Can't read the version of %s: %s", appPath, err)
+		return d.Driver.Version()
+	}
+	raw, err := parseBundleVersion(plist)
+	if err != nil {
+		log.Printf("Can't read the version of %s: %s", appPath, err)
+		return d.Driver.Version()
+	}
+	version, err := ParseUTMVersion(raw)
+	if err != nil {
+		return "", err
+	}
+	log.Printf("UTM version: %s", version)
+	return version.String(), nil
+}
+
+// utmctlAppPath returns the app bundle utmctlPath, usually a link to
+// UTM.app/Contents/MacOS/utmctl, belongs to.
+func utmctlAppPath(utmctlPath string) (string, error) {
+	resolved, err := filepath.EvalSymlinks(utmctlPath)
+	if err != nil {
+		return "", err
+	}
+	i := strings.Index(resolved, ".app/Contents/")
+	if i < 0 {
+		return "", fmt.Errorf("%s is not inside an app bundle", resolved)
+	}
+	return resolved[:i+len(".app")], nil
+}
+
+var bundleVersionRe = regexp.MustCompile(
+	`<key>CFBundleShortVersionString</key>\s*<string>([^<]*)</string>`)
+
+// parseBundleVersion reads CFBundleShortVersionString out of an XML
+// Info.plist.
+func parseBundleVersion(plist []byte) (string, error) {
+	m := bundleVersionRe.FindSubmatch(plist)
+	if m == nil {
+		return "", errors.New("no CFBundleShortVersionString in Info.plist")
+	}
+	return strings.TrimSpace(string(m[1])), nil
+}
+
+// parseUtmctlStatus reads the output of utmctl status, one of VMStates.
+func parseUtmctlStatus(output string) (string, error) {
+	state := strings.TrimSpace(output)
+	if !slices.Contains(VMStates, state) {
+		return "", fmt.Errorf("unexpected VM status %q", state)
+	}
+	return state, nil
+}
+
+// parseIPAddresses reads the IP markers of get_ip_addresses.applescript, or
+// the output of utmctl ip-address, one address per line.
+func parseIPAddresses(output string) ([]string, error) {
+	lines := markerValues(output, MarkerIP)
+	if len(lines) == 0 {
+		lines = strings.Split(output, "\n")
+	}
+	var addresses []string
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		// Link-local IPv6 addresses come with their zone, like fe80::1%en0
+		if _, err := netip.ParseAddr(line); err != nil {
+			return nil, fmt.Errorf("unexpected IP address %q", line)
+		}
+		addresses = append(addresses, line)
+	}
+	if len(addresses) == 0 {
+		return nil, errors.New("the guest reported no IP address")
+	}
+	return addresses, nil
+}
@@ -0,0 +1,213 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// utmctlTestVMEnv names the environment variable holding the name or UUID of
+// a stopped UTM VM the utmctl integration test starts and stops.
+const utmctlTestVMEnv = "PACKER_UTM_UTMCTL_TEST_VM"
+
+func TestUtmctlDriver_impl(t *testing.T) {
+	var _ Driver = new(UtmctlDriver)
+}
+
+func TestParseUtmctlStatus(t *testing.T) {
+	for _, state := range VMStates {
+		got, err := parseUtmctlStatus(state + "\n")
+		if err != nil {
+			t.Fatalf("%s: err: %s", state, err)
+		}
+		if got != state {
+			t.Fatalf("got %q, want %q", got, state)
+		}
+	}
+	for _, output := range []string{"", "running", "Error: Virtual machine not found."} {
+		if _, err := parseUtmctlStatus(output); err == nil {
+			t.Fatalf("%q: should have error", output)
+		}
+	}
+}
+
+func TestParseIPAddresses(t *testing.T) {
+	output := "192.168.64.5\nfe80::5054:ff:fe12:3456%en0\n\nfd00::1\n"
+	got, err := parseIPAddresses(output)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	want := []string{"192.168.64.5", "fe80::5054:ff:fe12:3456%en0", "fd00::1"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %#v, want %#v", got, want)
+	}
+
+	for _, output := range []string{"", "\n", "Error: Operation not supported by the backend."} {
+		if _, err := parseIPAddresses(output); err == nil {
+			t.Fatalf("%q: should have error", output)
+		}
+	}
+}
+
+func TestParseBundleVersion(t *testing.T) {
+	plist := `<?xml version="1.0" encoding="UTF-8"?>
+<plist version="1.0">
+<dict>
+	<key>CFBundleName</key>
+	<string>UTM</string>
+	<key>CFBundleShortVersionString</key>
+	<string>4.6.4</string>
+	<key>CFBundleVersion</key>
+	<string>104</string>
+</dict>
+</plist>`
+	got, err := parseBundleVersion([]byte(plist))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if got != "4.6.4" {
+		t.Fatalf("got %q", got)
+	}
+
+	if _, err := parseBundleVersion([]byte("bplist00")); err == nil {
+		t.Fatal("should have error for a binary plist")
+	}
+}
+
+func TestUtmctlDriver_Version(t *testing.T) {
+	app := filepath.Join(t.TempDir(), "UTM.app")
+	macOS := filepath.Join(app, "Contents", "MacOS")
+	if err := os.MkdirAll(macOS, 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	utmctl := filepath.Join(macOS, "utmctl")
+	if err := os.WriteFile(utmctl, []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	plist := "<dict><key>CFBundleShortVersionString</key>\n<string>4.7.1</string></dict>"
+	if err := os.WriteFile(filepath.Join(app, "Contents", "Info.plist"), []byte(plist), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	link := filepath.Join(t.TempDir(), "utmctl")
+	if err := os.Symlink(utmctl, link); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	mock := &DriverMock{VersionResult: "4.5.0"}
+	driver := &UtmctlDriver{Driver: mock, UtmctlPath: link}
+	version, err := driver.Version()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if version != "4.7.1" || mock.VersionCalled {
+		t.Fatalf("should read Info.plist: %q", version)
+	}
+
+	// Outside of an app bundle, the wrapped driver asks UTM.
+	driver.UtmctlPath = utmctl + "-missing"
+	if version, _ := driver.Version(); version != "4.5.0" || !mock.VersionCalled {
+		t.Fatalf("should fall back to the wrapped driver: %q", version)
+	}
+}
+
+func TestUtmctlDriver_lifecycle(t *testing.T) {
+	// Stand in for utmctl with a script logging its arguments, for a VM
+	// that is started.
+	dir := t.TempDir()
+	log := filepath.Join(dir, "calls")
+	utmctl := filepath.Join(dir, "utmctl")
+	script := "#!/bin/sh\necho \"$@\" >> " + log + "\n" +
+		"case \"$1\" in\n" +
+		"status) echo started ;;\n" +
+		"ip-address) printf '192.168.64.5\\nfe80::1%%en0\\n' ;;\n" +
+		"esac\n"
+	if err := os.WriteFile(utmctl, []byte(script), 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Nothing goes through the wrapped driver.
+	driver := &UtmctlDriver{Driver: nil, UtmctlPath: utmctl}
+	if err := driver.StartVM(context.Background(), "vm-id", time.Second); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if running, err := driver.IsRunning("vm-id"); err != nil || !running {
+		t.Fatalf("should be running: %v", err)
+	}
+	if err := driver.WaitForState(context.Background(), "vm-id", "started", time.Second, time.Millisecond); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	addresses, err := driver.IPAddresses("vm-id")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !reflect.DeepEqual(addresses, []string{"192.168.64.5", "fe80::1%en0"}) {
+		t.Fatalf("bad: %#v", addresses)
+	}
+	if err := driver.PowerOff("vm-id", false); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	calls, err := os.ReadFile(log)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	want := "start vm-id\nstatus vm-id\nstatus vm-id\nstatus vm-id\nip-address vm-id\nstop vm-id --request\n"
+	if string(calls) != want {
+		t.Fatalf("bad utmctl calls:\n%s", calls)
+	}
+}
+
+// TestUtmctlDriver_integration drives a real VM with utmctl. It needs UTM
+// and the name of a stopped VM with the QEMU guest agent in
+// PACKER_UTM_UTMCTL_TEST_VM.
+func TestUtmctlDriver_integration(t *testing.T) {
+	vm := os.Getenv(utmctlTestVMEnv)
+	if vm == "" {
+		t.Skipf("set %s to the name of a stopped UTM VM to run", utmctlTestVMEnv)
+	}
+	utmctl, err := exec.LookPath("utmctl")
+	if err != nil {
+		t.Fatalf("utmctl not found: %s", err)
+	}
+
+	driver := &UtmctlDriver{Driver: &Utm45Driver{UtmctlPath: utmctl}, UtmctlPath: utmctl}
+	version, err := driver.Version()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	t.Logf("UTM version: %s", version)
+
+	ctx := context.Background()
+	if err := driver.StartVM(ctx, vm, 2*time.Minute); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer func() {
+		if err := driver.PowerOff(vm, true); err != nil {
+			t.Errorf("err: %s", err)
+		}
+		if err := driver.WaitForState(ctx, vm, "stopped", time.Minute, time.Second); err != nil {
+			t.Errorf("err: %s", err)
+		}
+	}()
+
+	// The guest agent takes a while to come up after boot.
+	deadline := time.Now().Add(3 * time.Minute)
+	for {
+		addresses, err := driver.IPAddresses(vm)
+		if err == nil {
+			t.Logf("IP addresses: %s", addresses)
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("err: %s", err)
+		}
+		time.Sleep(5 * time.Second)
+	}
+}
@@ -0,0 +1,51 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// UTMBundleID is the bundle identifier of UTM.app.
+const UTMBundleID = "com.utmapp.UTM"
+
+// ErrUTMNotRunning is returned by EnsureRunning when UTM isn't running and
+// auto_launch_utm is off.
+var ErrUTMNotRunning = errors.New(
+	"UTM is not running: start UTM.app before running Packer, or set auto_launch_utm = true")
+
+// ensureUTMRunning makes sure UTM is running, launching it with launch when
+// autoLaunch is set, then calls ready every interval until UTM answers or
+// timeout passes. A refused Automation permission won't go away by waiting,
+// so it is returned at once.
+func ensureUTMRunning(isRunning func() (bool, error), launch func() error, ready func() error,
+	autoLaunch bool, timeout time.Duration, interval time.Duration) error {
+	running, err := isRunning()
+	if err != nil {
+		return fmt.Errorf("error checking whether UTM is running: %s", err)
+	}
+	if running {
+		return nil
+	}
+	if !autoLaunch {
+		return ErrUTMNotRunning
+	}
+
+	if err := launch(); err != nil {
+		return fmt.Errorf("error launching UTM: %s", err)
+	}
+	deadline := time.Now().Add(timeout)
+	for {
+		err := ready()
+		if err == nil || errors.Is(err, ErrAutomationNotAuthorized) {
+			return err
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("UTM was launched but didn't answer within %s: %s", timeout, err)
+		}
+		time.Sleep(interval)
+	}
+}
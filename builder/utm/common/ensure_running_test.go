@@ -0,0 +1,84 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestEnsureUTMRunning(t *testing.T) {
+	running := false
+	launched := 0
+	polls := 0
+	isRunning := func() (bool, error) { return running, nil }
+	launch := func() error {
+		launched++
+		return nil
+	}
+	ready := func() error {
+		polls++
+		if polls < 3 {
+			return errors.New("connection invalid (-609)")
+		}
+		return nil
+	}
+
+	if err := ensureUTMRunning(isRunning, launch, ready, true, time.Second, time.Millisecond); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if launched != 1 || polls != 3 {
+		t.Fatalf("should launch UTM and wait for it: launched %d, polls %d", launched, polls)
+	}
+
+	// A running UTM is left alone.
+	running = true
+	launched, polls = 0, 0
+	if err := ensureUTMRunning(isRunning, launch, ready, false, time.Second, time.Millisecond); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if launched != 0 || polls != 0 {
+		t.Fatalf("should not launch UTM: launched %d, polls %d", launched, polls)
+	}
+}
+
+func TestEnsureUTMRunning_noAutoLaunch(t *testing.T) {
+	isRunning := func() (bool, error) { return false, nil }
+	launch := func() error {
+		t.Fatal("should not launch UTM")
+		return nil
+	}
+	err := ensureUTMRunning(isRunning, launch, nil, false, time.Second, time.Millisecond)
+	if !errors.Is(err, ErrUTMNotRunning) {
+		t.Fatalf("should tell the user to start UTM: %v", err)
+	}
+}
+
+func TestEnsureUTMRunning_errors(t *testing.T) {
+	isRunning := func() (bool, error) { return false, nil }
+	launch := func() error { return nil }
+
+	// UTM never gets ready.
+	notReady := func() error { return errors.New("connection invalid (-609)") }
+	if err := ensureUTMRunning(isRunning, launch, notReady, true, 10*time.Millisecond, time.Millisecond); err == nil {
+		t.Fatal("should time out")
+	}
+
+	// A refused permission doesn't go away by waiting.
+	polls := 0
+	denied := func() error {
+		polls++
+		return &AutomationPermissionError{Output: "execution error (-1743)"}
+	}
+	err := ensureUTMRunning(isRunning, launch, denied, true, time.Second, time.Millisecond)
+	if !errors.Is(err, ErrAutomationNotAuthorized) || polls != 1 {
+		t.Fatalf("should fail at once on a refused permission: %v after %d polls", err, polls)
+	}
+
+	failing := func() error { return errors.New("Unable to find application") }
+	if err := ensureUTMRunning(isRunning, failing, notReady, true, time.Second, time.Millisecond); err == nil {
+		t.Fatal("should fail when UTM can't be launched")
+	}
+}
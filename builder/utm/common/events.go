@@ -0,0 +1,209 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// These are the types of the events the builders emit.
+const (
+	EventStepStarted      string = "step_started"
+	EventStepFinished     string = "step_finished"
+	EventDownloadProgress string = "download_progress"
+	EventVMState          string = "vm_state"
+)
+
+// downloadProgressInterval is the least time between two download_progress
+// events of the same download.
+const downloadProgressInterval = time.Second
+
+// Event is a progress event, written to events_file as a line of JSON.
+type Event struct {
+	Time   time.Time `json:"time"`
+	Type   string    `json:"type"`
+	Step   string    `json:"step,omitempty"`
+	Status string    `json:"status,omitempty"`
+	// Name, Current and Total describe a download_progress event, Current
+	// and Total in bytes.
+	Name    string `json:"name,omitempty"`
+	Current int64  `json:"current,omitempty"`
+	Total   int64  `json:"total,omitempty"`
+}
+
+// EventWriter writes events as lines of JSON. A nil EventWriter drops them,
+// so callers don't need to check whether events_file is set.
+type EventWriter struct {
+	mu  sync.Mutex
+	w   io.WriteCloser
+	now func() time.Time
+}
+
+// NewEventWriter opens path to append events to, or returns a nil
+// EventWriter when path is empty.
+func NewEventWriter(path string) (*EventWriter, error) {
+	if path == "" {
+		return nil, nil
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("error opening events_file: %s", err)
+	}
+	return &EventWriter{w: f, now: time.Now}, nil
+}
+
+// Emit writes event, stamping it with the current time. Failures are only
+// logged, as events must not fail the build.
+func (e *EventWriter) Emit(event Event) {
+	if e == nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	event.Time = e.now().UTC()
+	line, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("[WARN] error encoding event: %s", err)
+		return
+	}
+	if _, err := e.w.Write(append(line, '\n')); err != nil {
+		log.Printf("[WARN] error writing event: %s", err)
+	}
+}
+
+func (e *EventWriter) Close() error {
+	if e == nil {
+		return nil
+	}
+	return e.w.Close()
+}
+
+// emitEvent emits event to the EventWriter in state, if any.
+func emitEvent(state multistep.StateBag, event Event) {
+	if events, ok := state.GetOk("events"); ok {
+		events.(*EventWriter).Emit(event)
+	}
+}
+
+// WithStepEvents wraps steps to emit step_started and step_finished events
+// around each of them. It returns steps unchanged when events is nil.
+func WithStepEvents(steps []multistep.Step, events *EventWriter) []multistep.Step {
+	if events == nil {
+		return steps
+	}
+	wrapped := make([]multistep.Step, len(steps))
+	for i, step := range steps {
+		wrapped[i] = &eventStep{Step: step, name: stepName(step), events: events}
+	}
+	return wrapped
+}
+
+// stepName returns the type name of step, such as StepRun, or the name of
+// the step it wraps.
+func stepName(step multistep.Step) string {
+	if wrapped, ok := step.(multistep.StepWrapper); ok {
+		return wrapped.InnerStepName()
+	}
+	t := reflect.TypeOf(step)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t.Name()
+}
+
+type eventStep struct {
+	multistep.Step
+	name   string
+	events *EventWriter
+}
+
+// InnerStepName names the wrapped step in the pauses of packer build -debug.
+func (s *eventStep) InnerStepName() string {
+	return s.name
+}
+
+func (s *eventStep) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	s.events.Emit(Event{Type: EventStepStarted, Step: s.name})
+	action := s.Step.Run(ctx, state)
+
+	status := "continue"
+	if action == multistep.ActionHalt {
+		status = "halt"
+		if ctx.Err() != nil {
+			status = "cancelled"
+		} else if _, ok := state.GetOk("error"); ok {
+			status = "error"
+		}
+	}
+	s.events.Emit(Event{Type: EventStepFinished, Step: s.name, Status: status})
+	return action
+}
+
+// EventUi emits download_progress events for the progress bars packer
+// shows. NewEventUi returns ui unchanged when events is nil.
+type EventUi struct {
+	packersdk.Ui
+	events *EventWriter
+}
+
+func NewEventUi(ui packersdk.Ui, events *EventWriter) packersdk.Ui {
+	if events == nil {
+		return ui
+	}
+	return &EventUi{Ui: ui, events: events}
+}
+
+func (u *EventUi) TrackProgress(src string, currentSize, totalSize int64, stream io.ReadCloser) io.ReadCloser {
+	stream = u.Ui.TrackProgress(src, currentSize, totalSize, stream)
+	return &progressReader{
+		ReadCloser: stream,
+		events:     u.events,
+		name:       src,
+		current:    currentSize,
+		total:      totalSize,
+	}
+}
+
+// progressReader emits download_progress events as a stream is read, at
+// most once per downloadProgressInterval and once it is read to its end.
+type progressReader struct {
+	io.ReadCloser
+	events  *EventWriter
+	name    string
+	current int64
+	total   int64
+	last    time.Time
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.current += int64(n)
+	if err == io.EOF || time.Since(r.last) >= downloadProgressInterval {
+		r.last = time.Now()
+		status := "running"
+		if err == io.EOF {
+			status = "done"
+		}
+		r.events.Emit(Event{
+			Type:    EventDownloadProgress,
+			Status:  status,
+			Name:    r.name,
+			Current: r.current,
+			Total:   r.total,
+		})
+	}
+	return n, err
+}
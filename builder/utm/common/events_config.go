@@ -0,0 +1,17 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:generate packer-sdc struct-markdown
+
+package common
+
+type EventsConfig struct {
+	// The path of a file the build appends progress events to, one JSON
+	// object per line, for tools that track builds without parsing the log.
+	// Each event has its `time`, `type`, `step` and `status`: `step_started`
+	// and `step_finished` for every step, `download_progress` while a file
+	// downloads, and `vm_state` when the VM starts and stops. A path such as
+	// `/dev/fd/3` writes to a file descriptor Packer inherited. Unset by
+	// default, which emits no events.
+	EventsFile string `mapstructure:"events_file" required:"false"`
+}
@@ -0,0 +1,151 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// readEvents reads the events written to path.
+func readEvents(t *testing.T, path string) []Event {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer f.Close()
+
+	var events []Event
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var event Event
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatalf("bad event %q: %s", scanner.Text(), err)
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+func testEventWriter(t *testing.T) (*EventWriter, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	events, err := NewEventWriter(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	t.Cleanup(func() { _ = events.Close() })
+	return events, path
+}
+
+func TestNewEventWriter_unset(t *testing.T) {
+	events, err := NewEventWriter("")
+	if err != nil || events != nil {
+		t.Fatalf("should not write events: %v %v", events, err)
+	}
+	// A nil EventWriter drops events
+	events.Emit(Event{Type: EventStepStarted})
+	if err := events.Close(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	steps := []multistep.Step{new(StepRun)}
+	if wrapped := WithStepEvents(steps, nil); wrapped[0] != steps[0] {
+		t.Fatal("should not wrap the steps")
+	}
+	ui := packersdk.TestUi(t)
+	if NewEventUi(ui, nil) != ui {
+		t.Fatal("should not wrap the ui")
+	}
+}
+
+func TestWithStepEvents(t *testing.T) {
+	events, path := testEventWriter(t)
+	now := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	events.now = func() time.Time { return now }
+
+	state := testState(t)
+	state.Put("vmId", "foo")
+	driver := state.Get("driver").(*DriverMock)
+	driver.StartVMErr = io.ErrUnexpectedEOF
+	state.Put("events", events)
+
+	steps := WithStepEvents([]multistep.Step{&StepPause{NoPause: true}, new(StepRun)}, events)
+	for i, name := range []string{"StepPause", "StepRun"} {
+		// packer build -debug pauses with the name of the wrapped step
+		if wrapped, ok := steps[i].(multistep.StepWrapper); !ok || wrapped.InnerStepName() != name {
+			t.Fatalf("step %d should be named %s", i, name)
+		}
+	}
+	for _, step := range steps {
+		if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+			break
+		}
+	}
+
+	expected := []Event{
+		{Time: now, Type: EventStepStarted, Step: "StepPause"},
+		{Time: now, Type: EventStepFinished, Step: "StepPause", Status: "continue"},
+		{Time: now, Type: EventStepStarted, Step: "StepRun"},
+		{Time: now, Type: EventStepFinished, Step: "StepRun", Status: "error"},
+	}
+	got := readEvents(t, path)
+	if len(got) != len(expected) {
+		t.Fatalf("bad events: %#v", got)
+	}
+	for i := range expected {
+		if !got[i].Time.Equal(expected[i].Time) || got[i].Type != expected[i].Type ||
+			got[i].Step != expected[i].Step || got[i].Status != expected[i].Status {
+			t.Fatalf("bad event %d: %#v", i, got[i])
+		}
+	}
+}
+
+func TestWithStepEvents_vmState(t *testing.T) {
+	events, path := testEventWriter(t)
+	state := testState(t)
+	state.Put("vmId", "foo")
+	state.Put("events", events)
+
+	if action := new(StepRun).Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	got := readEvents(t, path)
+	if len(got) != 1 || got[0].Type != EventVMState || got[0].Status != "running" {
+		t.Fatalf("should emit the VM state: %#v", got)
+	}
+}
+
+func TestEventUi_TrackProgress(t *testing.T) {
+	events, path := testEventWriter(t)
+	ui := NewEventUi(packersdk.TestUi(t), events)
+
+	content := bytes.Repeat([]byte("x"), 1024)
+	stream := ui.TrackProgress("disk.iso", 0, int64(len(content)), io.NopCloser(bytes.NewReader(content)))
+	if _, err := io.Copy(io.Discard, stream); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	_ = stream.Close()
+
+	got := readEvents(t, path)
+	if len(got) == 0 {
+		t.Fatal("should emit download progress")
+	}
+	last := got[len(got)-1]
+	if last.Type != EventDownloadProgress || last.Status != "done" || last.Name != "disk.iso" ||
+		last.Current != 1024 || last.Total != 1024 {
+		t.Fatalf("bad last event: %#v", last)
+	}
+}
@@ -2,11 +2,16 @@
 // SPDX-License-Identifier: MPL-2.0
 
 //go:generate packer-sdc struct-markdown
+//go:generate packer-sdc mapstructure-to-hcl2 -type BundleFile
 
 package common
 
 import (
 	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
 )
@@ -15,19 +20,138 @@ type ExportConfig struct {
 	// Only UTM, this specifies the output format
 	// of the exported virtual machine. This defaults to utm.
 	Format string `mapstructure:"format" required:"false"`
+	// The free space, in megabytes, that must be left on the output volume
+	// after the export. Before exporting, the builder estimates the size of
+	// the bundle from the VM's disk images and fails early if the volume
+	// cannot hold it plus this margin. This defaults to 1024, set it to 0 to
+	// only check the bundle fits.
+	ExportSpaceMargin *uint `mapstructure:"export_space_margin" required:"false"`
+	// Remove the EFI variable store, the NVRAM, from the exported bundle.
+	// UTM then starts the imported VM with a fresh store, so boot entries
+	// pointing at the disks of the build host don't keep it from booting
+	// elsewhere. Secure boot keys and MOKs enrolled during the build are
+	// reset to the firmware defaults too, so leave this off for images that
+	// depend on them. It only applies to QEMU VMs booting with UEFI, not to
+	// the `apple` backend. By default, this is false.
+	ResetNVRAM bool `mapstructure:"reset_nvram" required:"false"`
+	// Files to copy into the exported bundle, such as a README or a
+	// license, so that they travel with the image. They go into the
+	// `Extras` directory of the bundle, which UTM ignores, and are listed
+	// in the files of the artifact. They need the VM to be exported, so
+	// they can't be used with `skip_export` or `keep_running`.
+	//
+	// In HCL2:
+	// ```hcl
+	// extra_bundle_files {
+	//   source = "./LICENSE"
+	// }
+	//
+	// extra_bundle_files {
+	//   source      = "./docs/image.md"
+	//   destination = "docs/README.md"
+	// }
+	// ```
+	ExtraBundleFiles []BundleFile `mapstructure:"extra_bundle_files" required:"false"`
 	// TODO: add export options when utm export with options is supported
 }
 
+// A file to copy into the exported bundle.
+type BundleFile struct {
+	// The path of the file on the host.
+	Source string `mapstructure:"source" required:"true"`
+	// The path to copy the file to, relative to the `Extras` directory of
+	// the bundle. Defaults to the name of the source file.
+	Destination string `mapstructure:"destination" required:"false"`
+}
+
+// BundleExtrasDir is the directory of the exported bundle extra_bundle_files
+// are copied into. UTM only reads config.plist and the Data directory of a
+// bundle, so it leaves the directory alone.
+const BundleExtrasDir = "Extras"
+
+// DefaultExportSpaceMargin is the default export_space_margin, in megabytes.
+const DefaultExportSpaceMargin = 1024
+
 func (c *ExportConfig) Prepare(ctx *interpolate.Context) []error {
 	if c.Format == "" {
 		c.Format = "utm"
 	}
+	if c.ExportSpaceMargin == nil {
+		margin := uint(DefaultExportSpaceMargin)
+		c.ExportSpaceMargin = &margin
+	}
 
 	var errs []error
 	if c.Format != "utm" {
 		errs = append(errs,
-			errors.New("invalid format, only 'utm' is allowed"))
+			fmt.Errorf("format must be 'utm', got %q", c.Format))
+	}
+
+	destinations := map[string]int{}
+	for i := range c.ExtraBundleFiles {
+		file := &c.ExtraBundleFiles[i]
+		if file.Source == "" {
+			errs = append(errs, fmt.Errorf("extra_bundle_files[%d]: source is required", i))
+			continue
+		}
+		if info, err := os.Stat(file.Source); err != nil {
+			errs = append(errs, fmt.Errorf("extra_bundle_files[%d]: %s", i, err))
+		} else if !info.Mode().IsRegular() {
+			errs = append(errs, fmt.Errorf("extra_bundle_files[%d]: %s is not a regular file", i, file.Source))
+		}
+
+		if file.Destination == "" {
+			file.Destination = filepath.Base(file.Source)
+		}
+		file.Destination = filepath.Clean(file.Destination)
+		if filepath.IsAbs(file.Destination) || file.Destination == "." ||
+			file.Destination == ".." || strings.HasPrefix(file.Destination, ".."+string(filepath.Separator)) {
+			errs = append(errs, fmt.Errorf(
+				"extra_bundle_files[%d]: destination must be a path inside the %s directory, got %q",
+				i, BundleExtrasDir, file.Destination))
+			continue
+		}
+		if j, ok := destinations[file.Destination]; ok {
+			errs = append(errs, fmt.Errorf(
+				"extra_bundle_files[%d]: destination %s is already used by extra_bundle_files[%d]", i, file.Destination, j))
+		}
+		destinations[file.Destination] = i
 	}
 
 	return errs
 }
+
+// PrepareExtraBundleFiles validates extra_bundle_files against the build
+// options of the builder, since the files are only copied into an exported
+// bundle.
+func (c *ExportConfig) PrepareExtraBundleFiles(skipExport bool, keepRunning bool) []error {
+	if len(c.ExtraBundleFiles) == 0 {
+		return nil
+	}
+
+	var errs []error
+	if keepRunning {
+		errs = append(errs, errors.New("extra_bundle_files conflicts with keep_running, which skips the export"))
+	} else if skipExport {
+		errs = append(errs, errors.New("extra_bundle_files needs the VM to be exported, it can't be used with skip_export"))
+	}
+	return errs
+}
+
+// PrepareNVRAM validates reset_nvram for a VM that boots with UEFI when efi
+// is set. A suspended VM keeps its firmware state, so the store can't be
+// reset under it.
+func (c *ExportConfig) PrepareNVRAM(efi bool, suspend bool) []error {
+	if !c.ResetNVRAM {
+		return nil
+	}
+
+	var errs []error
+	if !efi {
+		errs = append(errs, errors.New("reset_nvram only applies to VMs booting with UEFI, set uefi_boot"))
+	}
+	if suspend {
+		errs = append(errs, errors.New("reset_nvram conflicts with suspend_before_export"))
+	}
+	return errs
+}
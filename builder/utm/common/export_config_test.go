@@ -4,6 +4,9 @@
 package common
 
 import (
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
@@ -39,3 +42,94 @@ func TestExportConfigPrepare_BootWait(t *testing.T) {
 }
 
 // TODO: add export opts test, when utm export with options is supported
+
+func TestExportConfigPrepare_ExportSpaceMargin(t *testing.T) {
+	c := new(ExportConfig)
+	if errs := c.Prepare(interpolate.NewContext()); len(errs) > 0 {
+		t.Fatalf("should not have error: %s", errs)
+	}
+	if *c.ExportSpaceMargin != DefaultExportSpaceMargin {
+		t.Fatalf("bad default margin: %d", *c.ExportSpaceMargin)
+	}
+
+	for _, margin := range []uint{10, 0} {
+		c = new(ExportConfig)
+		c.ExportSpaceMargin = &margin
+		if errs := c.Prepare(interpolate.NewContext()); len(errs) > 0 {
+			t.Fatalf("should not have error: %s", errs)
+		}
+		if *c.ExportSpaceMargin != margin {
+			t.Fatalf("should keep the margin: %d", *c.ExportSpaceMargin)
+		}
+	}
+}
+
+func TestExportConfigPrepareNVRAM(t *testing.T) {
+	c := &ExportConfig{ResetNVRAM: true}
+	if errs := c.PrepareNVRAM(true, false); len(errs) > 0 {
+		t.Fatalf("should not have error: %s", errs)
+	}
+	if errs := c.PrepareNVRAM(false, false); len(errs) != 1 {
+		t.Fatalf("should need UEFI: %#v", errs)
+	}
+	if errs := c.PrepareNVRAM(true, true); len(errs) != 1 {
+		t.Fatalf("should conflict with suspend_before_export: %#v", errs)
+	}
+
+	c = new(ExportConfig)
+	if errs := c.PrepareNVRAM(false, true); len(errs) > 0 {
+		t.Fatalf("should ignore an unset reset_nvram: %s", errs)
+	}
+}
+
+func TestExportConfigPrepareExtraBundleFiles(t *testing.T) {
+	c := new(ExportConfig)
+	if errs := c.PrepareExtraBundleFiles(true, true); len(errs) > 0 {
+		t.Fatalf("should allow skipping the export without files: %#v", errs)
+	}
+
+	c.ExtraBundleFiles = []BundleFile{{Source: "LICENSE"}}
+	if errs := c.PrepareExtraBundleFiles(false, false); len(errs) > 0 {
+		t.Fatalf("err: %#v", errs)
+	}
+	if errs := c.PrepareExtraBundleFiles(true, false); len(errs) != 1 || !strings.Contains(errs[0].Error(), "skip_export") {
+		t.Fatalf("should need the VM to be exported: %#v", errs)
+	}
+	if errs := c.PrepareExtraBundleFiles(true, true); len(errs) != 1 || !strings.Contains(errs[0].Error(), "keep_running") {
+		t.Fatalf("should conflict with keep_running: %#v", errs)
+	}
+}
+
+func TestExportConfigPrepare_ExtraBundleFiles(t *testing.T) {
+	license := filepath.Join(t.TempDir(), "LICENSE")
+	if err := os.WriteFile(license, []byte("MPL-2.0"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := &ExportConfig{ExtraBundleFiles: []BundleFile{
+		{Source: license},
+		{Source: license, Destination: "docs/../legal/LICENSE"},
+	}}
+	if errs := c.Prepare(interpolate.NewContext()); len(errs) > 0 {
+		t.Fatalf("should not have error: %s", errs)
+	}
+	if c.ExtraBundleFiles[0].Destination != "LICENSE" || c.ExtraBundleFiles[1].Destination != filepath.Join("legal", "LICENSE") {
+		t.Fatalf("bad destinations: %#v", c.ExtraBundleFiles)
+	}
+
+	c = &ExportConfig{ExtraBundleFiles: []BundleFile{
+		{},
+		{Source: filepath.Join(t.TempDir(), "missing")},
+		{Source: t.TempDir()},
+		{Source: license, Destination: "../config.plist"},
+		{Source: license},
+		{Source: license, Destination: "LICENSE"},
+	}}
+	errs := c.Prepare(interpolate.NewContext())
+	if len(errs) != 5 {
+		t.Fatalf("expected 5 errors, got: %#v", errs)
+	}
+	if !strings.Contains(errs[4].Error(), "already used by extra_bundle_files[4]") {
+		t.Fatalf("should name the file using the destination: %s", errs[4])
+	}
+}
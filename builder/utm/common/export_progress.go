@@ -0,0 +1,68 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"fmt"
+	"time"
+)
+
+// watchExport runs export, calling progress every interval with the size of
+// the bundle written so far at path. It only runs export when there is no
+// progress to report.
+func watchExport(path string, interval time.Duration, progress func(written uint64), export func() error) error {
+	if progress == nil || interval <= 0 {
+		return export()
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- export() }()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case err := <-done:
+			return err
+		case <-ticker.C:
+			// The bundle only shows up once UTM starts writing it.
+			if written, err := BundleSize(path); err == nil {
+				progress(written)
+			}
+		}
+	}
+}
+
+// waitForExportStopped waits until the bundle at path stops growing,
+// checking its size every interval, for at most timeout. Interrupting an
+// export only stops osascript while UTM goes on writing the bundle, so it
+// can't be removed before UTM is done. It reports whether the bundle
+// stopped growing in time.
+func waitForExportStopped(path string, interval time.Duration, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	last, lastErr := BundleSize(path)
+	for time.Now().Before(deadline) {
+		time.Sleep(interval)
+		size, err := BundleSize(path)
+		if size == last && (err == nil) == (lastErr == nil) {
+			return true
+		}
+		last, lastErr = size, err
+	}
+	return false
+}
+
+// formatExportProgress describes written bytes of an export out of an
+// estimated total, unknown when zero.
+func formatExportProgress(written uint64, total uint64) string {
+	if total == 0 {
+		return fmt.Sprintf("Exported %s so far...", formatBytes(written))
+	}
+	percent := written * 100 / total
+	if percent > 99 {
+		// The estimate leaves out what UTM adds to the bundle.
+		percent = 99
+	}
+	return fmt.Sprintf("Exported %s of about %s (%d%%)...", formatBytes(written), formatBytes(total), percent)
+}
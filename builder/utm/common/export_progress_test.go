@@ -0,0 +1,111 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatchExport(t *testing.T) {
+	bundle := filepath.Join(t.TempDir(), "vm.utm")
+	var reported []uint64
+	progress := func(written uint64) { reported = append(reported, written) }
+
+	err := watchExport(bundle, time.Millisecond, progress, func() error {
+		// Before the bundle exists, nothing is reported. It shows up whole
+		// so that no smaller size can be seen.
+		time.Sleep(20 * time.Millisecond)
+		staging := bundle + ".tmp"
+		if err := os.MkdirAll(staging, 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(staging, "config.plist"), make([]byte, 512), 0o644); err != nil {
+			return err
+		}
+		if err := os.Rename(staging, bundle); err != nil {
+			return err
+		}
+		time.Sleep(20 * time.Millisecond)
+		return errors.New("export failed")
+	})
+	if err == nil || err.Error() != "export failed" {
+		t.Fatalf("should return the export error: %v", err)
+	}
+	if len(reported) == 0 {
+		t.Fatal("should report progress")
+	}
+	for _, written := range reported {
+		if written != 512 {
+			t.Fatalf("bad progress: %#v", reported)
+		}
+	}
+}
+
+func TestWatchExport_noProgress(t *testing.T) {
+	called := false
+	if err := watchExport("missing", 0, nil, func() error { called = true; return nil }); err != nil || !called {
+		t.Fatalf("should run the export: %v %t", err, called)
+	}
+}
+
+func TestFormatExportProgress(t *testing.T) {
+	cases := []struct {
+		written, total uint64
+		expected       string
+	}{
+		{512 * 1024 * 1024, 2 * 1024 * 1024 * 1024, "Exported 512.0 MiB of about 2.0 GiB (25%)..."},
+		{3 * 1024 * 1024 * 1024, 2 * 1024 * 1024 * 1024, "Exported 3.0 GiB of about 2.0 GiB (99%)..."},
+		{1024, 0, "Exported 1.0 KiB so far..."},
+	}
+	for _, tc := range cases {
+		if actual := formatExportProgress(tc.written, tc.total); actual != tc.expected {
+			t.Errorf("%d/%d: expected %q, got %q", tc.written, tc.total, tc.expected, actual)
+		}
+	}
+}
+
+func TestWaitForExportStopped(t *testing.T) {
+	bundle := filepath.Join(t.TempDir(), "vm.utm")
+	if !waitForExportStopped(bundle, time.Millisecond, time.Second) {
+		t.Fatal("should not wait for an export that never started")
+	}
+
+	if err := os.MkdirAll(bundle, 0o755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	disk, err := os.Create(filepath.Join(bundle, "disk.qcow2"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer disk.Close()
+
+	// UTM still writing
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				_, _ = disk.Write(make([]byte, 1024))
+				time.Sleep(time.Millisecond)
+			}
+		}
+	}()
+	if waitForExportStopped(bundle, 10*time.Millisecond, 50*time.Millisecond) {
+		t.Fatal("should time out while the bundle grows")
+	}
+
+	close(stop)
+	<-done
+	if !waitForExportStopped(bundle, 10*time.Millisecond, time.Second) {
+		t.Fatal("should see the bundle stop growing")
+	}
+}
@@ -0,0 +1,13 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:build !darwin && !linux
+
+package common
+
+import "errors"
+
+// freeSpace is not implemented on this platform; UTM only runs on macOS.
+func freeSpace(path string) (uint64, error) {
+	return 0, errors.New("checking free space is not supported on this platform")
+}
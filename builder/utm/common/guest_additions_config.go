@@ -7,7 +7,12 @@ package common
 
 import (
 	"fmt"
+	"path"
+	"sort"
+	"strconv"
 	"strings"
+
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
 )
 
 // These are the different valid mode values for "guest_additions_mode" which
@@ -18,29 +23,49 @@ const (
 	GuestAdditionsModeUpload  string = "upload"
 )
 
+// DefaultGuestAdditionsFilename is the name of the guest additions ISO
+// downloaded from getutm.app when guest_additions_filename is unset.
+const DefaultGuestAdditionsFilename = "utm-guest-tools-latest.iso"
+
 type GuestAdditionsConfig struct {
 	// The method by which guest additions are
 	// made available to the guest for installation. Valid options are `upload`,
 	// `attach`, or `disable`. If the mode is `attach` the guest additions ISO will
 	// be attached as a CD device to the virtual machine. If the mode is `upload`
 	// the guest additions ISO will be uploaded to the path specified by
-	// `guest_additions_path`. The default value is `upload`. If `disable` is used,
-	// guest additions won't be downloaded, either.
+	// `guest_additions_path`. The default value is `upload`, or `attach` when
+	// the communicator is `none` since nothing can be uploaded without one.
+	// If `disable` is used, guest additions won't be downloaded, either.
 	GuestAdditionsMode string `mapstructure:"guest_additions_mode"`
 	// The interface type to use to mount guest additions when
 	// guest_additions_mode is set to attach. Will default to the value set in
 	// iso_interface, if iso_interface is set. Will default to "USB", if
 	// iso_interface is not set. Options are none/‌IDE/‌SCSI/‌SD/‌MTD/‌Floppy/‌PFlash/‌VirtIO/‌NVMe/‌USB.
 	GuestAdditionsInterface string `mapstructure:"guest_additions_interface" required:"false"`
+	// The interface the guest additions ISO is attached to instead, with a
+	// warning, when UTM refuses `guest_additions_interface` because the
+	// VM's architecture has no such controller, such as ide on aarch64.
+	// Defaults to usb, which every architecture supports. Set it to the
+	// value of `guest_additions_interface` to fail the build instead.
+	GuestAdditionsInterfaceFallback string `mapstructure:"guest_additions_interface_fallback" required:"false"`
 	// The path on the guest virtual machine
 	//  where the UTM guest additions ISO will be uploaded. By default this
-	//  is `utm-guest-tools-<version>.iso` which should upload into the login directory of
-	//  the user.
+	//  is `utm-guest-tools.iso` which should upload into the login directory of
+	//  the user. Set it to a directory the user can write to on guests where
+	//  the login directory can't be used.
 	GuestAdditionsPath string `mapstructure:"guest_additions_path"`
+	// The file mode to give the guest additions ISO once it is uploaded with
+	// `guest_additions_mode = "upload"`, as octal such as `0644` or `0755`,
+	// for guests where the uploaded file otherwise can't be read by the user
+	// or tooling that installs it. It is applied with `chmod`, so it only
+	// works on Unix guests and can't be used with the WinRM communicator.
+	// By default the mode is left to the communicator.
+	GuestAdditionsUploadMode string `mapstructure:"guest_additions_upload_mode" required:"false"`
 	// The SHA256 checksum of the guest
 	//  additions ISO that will be uploaded to the guest VM. By default the
 	//  checksums will be downloaded from the UTM website, so this only needs
-	//  to be set if you want to be explicit about the checksum.
+	//  to be set if you want to be explicit about the checksum. It must be 64
+	//  hex characters, optionally prefixed with `sha256:`, or `none`.
 	GuestAdditionsSHA256 string `mapstructure:"guest_additions_sha256"`
 	// The path where the guest additions ISO should be saved
 	// after download. By default, it will go in the packer cache, with a hash of
@@ -52,21 +77,96 @@ type GuestAdditionsConfig struct {
 	//  on the local file system. If it is not available locally, the builder will
 	//  download the proper guest additions ISO from the internet.
 	GuestAdditionsURL string `mapstructure:"guest_additions_url" required:"false"`
+	// More URLs of the guest additions ISO, tried in order after
+	// `guest_additions_url` when a download fails, and followed by the
+	// getutm.app download. They are templates like `guest_additions_url`,
+	// and the ISO from whichever succeeds is verified against
+	// `guest_additions_sha256`. The build log names the mirror used.
+	GuestAdditionsURLs []string `mapstructure:"guest_additions_urls" required:"false"`
+	// The file name of the guest additions ISO, for mirrors that keep each
+	// version under its own name. It is a template where `{{ .Version }}` is
+	// the guest additions version of the local UTM, `{{ .GuestOS }}` and
+	// `{{ .Arch }}` the guest OS, `windows` for Windows builds, and the
+	// `vm_arch` of the VM, and must end in `.iso`.
+	// It names the ISO downloaded from getutm.app when the bundled one
+	// can't be used, and is `{{ .Filename }}` in `guest_additions_url`, so
+	// that a mirror URL only needs its base:
+	//
+	// ```hcl
+	// guest_additions_filename = "utm-guest-tools-{{ .Version }}.iso"
+	// guest_additions_url      = "https://mirror.example.com/utm/{{ .Filename }}"
+	// ```
+	//
+	// Defaults to `utm-guest-tools-latest.iso`.
+	GuestAdditionsFilename string `mapstructure:"guest_additions_filename" required:"false"`
+	// Defaults to false. When enabled, the build fails if the guest
+	// additions ISO bundled with the local UTM installation can't be used,
+	// instead of falling back to downloading it from the internet. UTM only
+	// bundles it since 4.6. Can't be used together with `guest_additions_url`.
+	RequireBundledGuestAdditions bool `mapstructure:"require_bundled_guest_additions" required:"false"`
+	// The command run over the communicator to install the guest additions
+	// once the guest is up, with its output shown in the build log. It is a
+	// template where `{{ .Path }}` is `guest_additions_path`,
+	// `{{ .GuestOS }}` the detected guest OS and `{{ .Arch }}` the `vm_arch`
+	// of the VM. The build fails when the command does. When it is unset,
+	// the plugin runs a command suited to the detected guest: a silent run
+	// of the UTM guest tools installer on Windows, the ARM64 one on aarch64
+	// guests, and an install of `qemu-guest-agent` and `spice-vdagent` from
+	// the package manager, with `sudo -n` when not root, on Linux. Nothing
+	// is run for other guests, and the build goes on with a warning when
+	// the default command fails. Nothing is run either when
+	// `guest_additions_mode` is `disable` or `skip_guest_additions_install`
+	// is enabled.
+	GuestAdditionsInstallCommand string `mapstructure:"guest_additions_install_command" required:"false"`
+	// Defaults to false. When enabled, the guest additions ISO is downloaded
+	// and attached but the install command is not run, for templates that
+	// install the tools in their own provisioner. Only valid when
+	// `guest_additions_mode` is `attach`.
+	SkipGuestAdditionsInstall bool `mapstructure:"skip_guest_additions_install" required:"false"`
+	// Defaults to false. When enabled, the build checks over the
+	// communicator, once provisioning is done, that the guest additions are
+	// active: the `QEMU-GA` and `spice-agent` services running on Windows,
+	// `qemu-ga` running and `spice-vdagent` installed on Linux. The build
+	// fails when they are not, rather than exporting an image without
+	// clipboard sharing or display resizing. Other guests are not checked.
+	VerifyGuestAdditions bool `mapstructure:"verify_guest_additions" required:"false"`
 }
 
 func (c *GuestAdditionsConfig) Prepare(communicatorType string) []error {
 	var errs []error
 
 	if c.GuestAdditionsMode == "" {
-		c.GuestAdditionsMode = "upload"
+		c.GuestAdditionsMode = GuestAdditionsModeUpload
+		if communicatorType == "none" {
+			c.GuestAdditionsMode = GuestAdditionsModeAttach
+		}
 	}
 
 	if c.GuestAdditionsPath == "" {
 		c.GuestAdditionsPath = "utm-guest-tools.iso"
 	}
 
+	if c.GuestAdditionsFilename == "" {
+		c.GuestAdditionsFilename = DefaultGuestAdditionsFilename
+	}
+	if _, err := renderGuestAdditionsFilename(c.GuestAdditionsFilename, &guestAdditionsUrlTemplate{Version: "0.0.0"}); err != nil {
+		errs = append(errs, err)
+	}
+
+	if c.GuestAdditionsInterfaceFallback == "" {
+		c.GuestAdditionsInterfaceFallback = "usb"
+	}
+	if _, err := GetControllerEnumCode(c.GuestAdditionsInterfaceFallback); err != nil {
+		errs = append(errs, fmt.Errorf("guest_additions_interface_fallback: %s", err))
+	}
+
 	if c.GuestAdditionsSHA256 != "" {
-		c.GuestAdditionsSHA256 = strings.ToLower(c.GuestAdditionsSHA256)
+		sum, err := normalizeSHA256("guest_additions_sha256", c.GuestAdditionsSHA256)
+		if err != nil {
+			errs = append(errs, err)
+		} else {
+			c.GuestAdditionsSHA256 = sum
+		}
 	}
 
 	validMode := false
@@ -85,7 +185,16 @@ func (c *GuestAdditionsConfig) Prepare(communicatorType string) []error {
 
 	if !validMode {
 		errs = append(errs,
-			fmt.Errorf("guest_additions_mode is invalid. Must be one of: %v", validModes))
+			fmt.Errorf("guest_additions_mode must be one of %v, got %q", validModes, c.GuestAdditionsMode))
+	}
+
+	if c.RequireBundledGuestAdditions && c.GuestAdditionsURL != "" {
+		errs = append(errs, fmt.Errorf("require_bundled_guest_additions "+
+			"can't be used together with guest_additions_url"))
+	}
+	if c.RequireBundledGuestAdditions && len(c.GuestAdditionsURLs) > 0 {
+		errs = append(errs, fmt.Errorf("require_bundled_guest_additions "+
+			"can't be used together with guest_additions_urls"))
 	}
 
 	if communicatorType == "none" && c.GuestAdditionsMode == "upload" {
@@ -93,5 +202,116 @@ func (c *GuestAdditionsConfig) Prepare(communicatorType string) []error {
 			"when guest_additions_mode = 'upload'"))
 	}
 
+	if c.GuestAdditionsUploadMode != "" {
+		if _, err := ParseFileMode(c.GuestAdditionsUploadMode); err != nil {
+			errs = append(errs, fmt.Errorf("guest_additions_upload_mode: %s", err))
+		}
+		if c.GuestAdditionsMode != GuestAdditionsModeUpload {
+			errs = append(errs, fmt.Errorf("guest_additions_upload_mode "+
+				"can only be used when guest_additions_mode = 'upload'"))
+		}
+		if communicatorType == "winrm" {
+			errs = append(errs, fmt.Errorf("guest_additions_upload_mode "+
+				"can't be used with the winrm communicator, it only applies to Unix guests"))
+		}
+	}
+
+	if c.SkipGuestAdditionsInstall && c.GuestAdditionsMode != GuestAdditionsModeAttach {
+		errs = append(errs, fmt.Errorf("skip_guest_additions_install "+
+			"can only be used when guest_additions_mode = 'attach'"))
+	}
+
+	if c.GuestAdditionsInstallCommand != "" {
+		if c.SkipGuestAdditionsInstall {
+			errs = append(errs, fmt.Errorf("guest_additions_install_command "+
+				"can't be used together with skip_guest_additions_install"))
+		}
+		if c.GuestAdditionsMode == GuestAdditionsModeDisable {
+			errs = append(errs, fmt.Errorf("guest_additions_install_command "+
+				"can't be used when guest_additions_mode = 'disable'"))
+		}
+		if communicatorType == "none" {
+			errs = append(errs, fmt.Errorf("communicator must not be 'none' "+
+				"when guest_additions_install_command is set"))
+		}
+	}
+
+	if c.VerifyGuestAdditions && c.GuestAdditionsMode == GuestAdditionsModeDisable {
+		errs = append(errs, fmt.Errorf("verify_guest_additions "+
+			"can't be used when guest_additions_mode = 'disable'"))
+	}
+	errs = append(errs, c.PrepareVerify(communicatorType)...)
+
 	return errs
 }
+
+// PrepareVerify only validates verify_guest_additions, for builders such as
+// cloud that never download or upload the guest additions but can check
+// those the image comes with.
+func (c *GuestAdditionsConfig) PrepareVerify(communicatorType string) []error {
+	if c.VerifyGuestAdditions && communicatorType == "none" {
+		return []error{fmt.Errorf("communicator must not be 'none' " +
+			"when verify_guest_additions is set")}
+	}
+	return nil
+}
+
+// PrepareVerifyOnly validates the guest additions options for builders
+// that only have StepVerifyGuestAdditions, rejecting those that download,
+// deliver or install the guest additions rather than ignoring them.
+func (c *GuestAdditionsConfig) PrepareVerifyOnly(communicatorType string) []error {
+	var errs []error
+
+	unsupported := map[string]bool{
+		"guest_additions_mode":               c.GuestAdditionsMode != "",
+		"guest_additions_interface":          c.GuestAdditionsInterface != "",
+		"guest_additions_interface_fallback": c.GuestAdditionsInterfaceFallback != "",
+		"guest_additions_path":               c.GuestAdditionsPath != "",
+		"guest_additions_upload_mode":        c.GuestAdditionsUploadMode != "",
+		"guest_additions_sha256":             c.GuestAdditionsSHA256 != "",
+		"guest_additions_target_path":        c.GuestAdditionsTargetPath != "",
+		"guest_additions_url":                c.GuestAdditionsURL != "",
+		"guest_additions_urls":               len(c.GuestAdditionsURLs) > 0,
+		"guest_additions_filename":           c.GuestAdditionsFilename != "",
+		"require_bundled_guest_additions":    c.RequireBundledGuestAdditions,
+		"guest_additions_install_command":    c.GuestAdditionsInstallCommand != "",
+		"skip_guest_additions_install":       c.SkipGuestAdditionsInstall,
+	}
+	keys := make([]string, 0, len(unsupported))
+	for key, set := range unsupported {
+		if set {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		errs = append(errs, fmt.Errorf("%s is not supported by this builder, "+
+			"it doesn't download or install the guest additions", key))
+	}
+
+	return append(errs, c.PrepareVerify(communicatorType)...)
+}
+
+// renderGuestAdditionsFilename renders the guest_additions_filename template
+// for the given guest additions version and platform, and checks it names an
+// ISO.
+func renderGuestAdditionsFilename(filename string, data *guestAdditionsUrlTemplate) (string, error) {
+	ctx := &interpolate.Context{Data: data}
+	name, err := interpolate.Render(filename, ctx)
+	if err != nil {
+		return "", fmt.Errorf("error rendering guest_additions_filename: %s", err)
+	}
+	if !strings.EqualFold(path.Ext(name), ".iso") {
+		return "", fmt.Errorf("guest_additions_filename must end in .iso, got %q", name)
+	}
+	return name, nil
+}
+
+// ParseFileMode parses an octal Unix file mode such as "0755".
+func ParseFileMode(mode string) (uint32, error) {
+	bits, err := strconv.ParseUint(mode, 8, 32)
+	if err != nil || bits > 07777 {
+		return 0, fmt.Errorf("%q is not an octal file mode such as 0644", mode)
+	}
+	return uint32(bits), nil
+}
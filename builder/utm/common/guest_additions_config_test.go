@@ -4,6 +4,7 @@
 package common
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -17,3 +18,229 @@ func TestGuestAdditionsConfigPrepare(t *testing.T) {
 		t.Fatalf("should not have error: %s", errs)
 	}
 }
+
+func TestGuestAdditionsConfigPrepare_defaultMode(t *testing.T) {
+	c := new(GuestAdditionsConfig)
+	if errs := c.Prepare("ssh"); len(errs) > 0 {
+		t.Fatalf("should not have error: %s", errs)
+	}
+	if c.GuestAdditionsMode != GuestAdditionsModeUpload {
+		t.Fatalf("bad mode: %s", c.GuestAdditionsMode)
+	}
+
+	// Templates without a communicator still validate
+	c = new(GuestAdditionsConfig)
+	if errs := c.Prepare("none"); len(errs) > 0 {
+		t.Fatalf("should not have error: %s", errs)
+	}
+	if c.GuestAdditionsMode != GuestAdditionsModeAttach {
+		t.Fatalf("bad mode: %s", c.GuestAdditionsMode)
+	}
+
+	c = &GuestAdditionsConfig{GuestAdditionsMode: GuestAdditionsModeUpload}
+	if errs := c.Prepare("none"); len(errs) != 1 {
+		t.Fatalf("expected 1 error, got: %s", errs)
+	}
+}
+
+func TestGuestAdditionsConfigPrepare_requireBundled(t *testing.T) {
+	c := new(GuestAdditionsConfig)
+	c.GuestAdditionsMode = "attach"
+	c.RequireBundledGuestAdditions = true
+	errs := c.Prepare("ssh")
+	if len(errs) > 0 {
+		t.Fatalf("should not have error: %s", errs)
+	}
+
+	c.GuestAdditionsURL = "https://example.com/tools.iso"
+	errs = c.Prepare("ssh")
+	if len(errs) != 1 {
+		t.Fatalf("expected 1 error, got: %s", errs)
+	}
+
+	c.GuestAdditionsURL = ""
+	c.GuestAdditionsURLs = []string{"https://example.com/tools.iso"}
+	errs = c.Prepare("ssh")
+	if len(errs) != 1 {
+		t.Fatalf("expected 1 error, got: %s", errs)
+	}
+}
+
+func TestGuestAdditionsConfigPrepare_installCommand(t *testing.T) {
+	c := &GuestAdditionsConfig{
+		GuestAdditionsMode:           GuestAdditionsModeAttach,
+		GuestAdditionsInstallCommand: "install {{ .Path }}",
+	}
+	if errs := c.Prepare("ssh"); len(errs) > 0 {
+		t.Fatalf("should not have error: %s", errs)
+	}
+
+	c.SkipGuestAdditionsInstall = true
+	if errs := c.Prepare("ssh"); len(errs) != 1 {
+		t.Fatalf("should not be used with skip_guest_additions_install: %s", errs)
+	}
+
+	c.SkipGuestAdditionsInstall = false
+	c.GuestAdditionsMode = GuestAdditionsModeDisable
+	if errs := c.Prepare("none"); len(errs) != 2 {
+		t.Fatalf("should need guest additions and a communicator: %s", errs)
+	}
+}
+
+func TestGuestAdditionsConfigPrepare_skipInstall(t *testing.T) {
+	c := &GuestAdditionsConfig{
+		GuestAdditionsMode:        GuestAdditionsModeAttach,
+		SkipGuestAdditionsInstall: true,
+	}
+	if errs := c.Prepare("ssh"); len(errs) > 0 {
+		t.Fatalf("should not have error: %s", errs)
+	}
+
+	for _, mode := range []string{GuestAdditionsModeUpload, GuestAdditionsModeDisable} {
+		c.GuestAdditionsMode = mode
+		if errs := c.Prepare("ssh"); len(errs) != 1 {
+			t.Fatalf("%s: should only be valid with attach: %s", mode, errs)
+		}
+	}
+}
+
+func TestGuestAdditionsConfigPrepare_filename(t *testing.T) {
+	c := &GuestAdditionsConfig{GuestAdditionsMode: GuestAdditionsModeAttach}
+	if errs := c.Prepare("ssh"); len(errs) > 0 {
+		t.Fatalf("should not have error: %s", errs)
+	}
+	if c.GuestAdditionsFilename != DefaultGuestAdditionsFilename {
+		t.Fatalf("bad default filename: %s", c.GuestAdditionsFilename)
+	}
+
+	c.GuestAdditionsFilename = "utm-guest-tools-{{ .Version }}.ISO"
+	if errs := c.Prepare("ssh"); len(errs) > 0 {
+		t.Fatalf("should not have error: %s", errs)
+	}
+
+	for _, filename := range []string{"utm-guest-tools-{{ .Version }}", "{{ .Nope }}.iso"} {
+		c.GuestAdditionsFilename = filename
+		if errs := c.Prepare("ssh"); len(errs) != 1 {
+			t.Fatalf("%q: expected 1 error, got: %s", filename, errs)
+		}
+	}
+}
+
+func TestRenderGuestAdditionsFilename(t *testing.T) {
+	name, err := renderGuestAdditionsFilename("utm-guest-tools-{{ .Version }}-{{ .GuestOS }}-{{ .Arch }}.iso",
+		&guestAdditionsUrlTemplate{Version: "0.229.2", GuestOS: GuestOSWindows, Arch: "aarch64"})
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if name != "utm-guest-tools-0.229.2-windows-aarch64.iso" {
+		t.Fatalf("bad filename: %s", name)
+	}
+}
+
+func TestGuestAdditionsConfigPrepare_sha256(t *testing.T) {
+	c := &GuestAdditionsConfig{
+		GuestAdditionsMode:   GuestAdditionsModeAttach,
+		GuestAdditionsSHA256: "sha256:" + strings.ToUpper(testSHA256),
+	}
+	if errs := c.Prepare("ssh"); len(errs) > 0 {
+		t.Fatalf("should not have error: %s", errs)
+	}
+	if c.GuestAdditionsSHA256 != testSHA256 {
+		t.Fatalf("should strip the prefix: %s", c.GuestAdditionsSHA256)
+	}
+
+	c.GuestAdditionsSHA256 = testSHA256[1:]
+	errs := c.Prepare("ssh")
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "guest_additions_sha256") {
+		t.Fatalf("should reject a short digest: %s", errs)
+	}
+}
+
+func TestGuestAdditionsConfigPrepare_uploadMode(t *testing.T) {
+	c := &GuestAdditionsConfig{GuestAdditionsUploadMode: "0755"}
+	if errs := c.Prepare("ssh"); len(errs) > 0 {
+		t.Fatalf("should not have error: %s", errs)
+	}
+
+	cases := map[string]*GuestAdditionsConfig{
+		"not octal":   {GuestAdditionsUploadMode: "0789"},
+		"too large":   {GuestAdditionsUploadMode: "17777"},
+		"attach mode": {GuestAdditionsUploadMode: "0644", GuestAdditionsMode: GuestAdditionsModeAttach},
+	}
+	for name, c := range cases {
+		if errs := c.Prepare("ssh"); len(errs) != 1 {
+			t.Errorf("%s: expected 1 error, got: %s", name, errs)
+		}
+	}
+
+	c = &GuestAdditionsConfig{GuestAdditionsUploadMode: "0644"}
+	if errs := c.Prepare("winrm"); len(errs) != 1 {
+		t.Fatalf("should reject winrm: %s", errs)
+	}
+}
+
+func TestGuestAdditionsConfigPrepare_interfaceFallback(t *testing.T) {
+	c := &GuestAdditionsConfig{GuestAdditionsMode: GuestAdditionsModeAttach}
+	if errs := c.Prepare("ssh"); len(errs) > 0 {
+		t.Fatalf("should not have error: %s", errs)
+	}
+	if c.GuestAdditionsInterfaceFallback != "usb" {
+		t.Fatalf("bad fallback: %s", c.GuestAdditionsInterfaceFallback)
+	}
+
+	c = &GuestAdditionsConfig{
+		GuestAdditionsMode:              GuestAdditionsModeAttach,
+		GuestAdditionsInterfaceFallback: "thunderbolt",
+	}
+	if errs := c.Prepare("ssh"); len(errs) != 1 {
+		t.Fatalf("should reject an unknown interface: %s", errs)
+	}
+}
+
+func TestGuestAdditionsConfigPrepare_verify(t *testing.T) {
+	c := &GuestAdditionsConfig{VerifyGuestAdditions: true}
+	if errs := c.Prepare("ssh"); len(errs) > 0 {
+		t.Fatalf("should not have error: %s", errs)
+	}
+
+	c = &GuestAdditionsConfig{VerifyGuestAdditions: true, GuestAdditionsMode: GuestAdditionsModeDisable}
+	if errs := c.Prepare("ssh"); len(errs) != 1 {
+		t.Fatalf("should reject disabled guest additions: %s", errs)
+	}
+
+	c = &GuestAdditionsConfig{VerifyGuestAdditions: true, GuestAdditionsMode: GuestAdditionsModeAttach}
+	if errs := c.Prepare("none"); len(errs) != 1 {
+		t.Fatalf("should need a communicator: %s", errs)
+	}
+}
+
+func TestGuestAdditionsConfigPrepareVerify(t *testing.T) {
+	// The cloud builder delivers no guest additions, so a template without
+	// a communicator is fine
+	c := new(GuestAdditionsConfig)
+	if errs := c.PrepareVerify("none"); len(errs) > 0 {
+		t.Fatalf("should not have error: %s", errs)
+	}
+
+	c.VerifyGuestAdditions = true
+	if errs := c.PrepareVerify("ssh"); len(errs) > 0 {
+		t.Fatalf("should not have error: %s", errs)
+	}
+	if errs := c.PrepareVerify("none"); len(errs) != 1 {
+		t.Fatalf("should need a communicator: %s", errs)
+	}
+}
+
+func TestGuestAdditionsConfigPrepareVerifyOnly(t *testing.T) {
+	c := &GuestAdditionsConfig{VerifyGuestAdditions: true}
+	if errs := c.PrepareVerifyOnly("ssh"); len(errs) > 0 {
+		t.Fatalf("should not have error: %s", errs)
+	}
+
+	// Nothing installs the guest additions, so these would be ignored
+	c.GuestAdditionsInstallCommand = "install.sh"
+	c.GuestAdditionsMode = GuestAdditionsModeAttach
+	if errs := c.PrepareVerifyOnly("ssh"); len(errs) != 2 {
+		t.Fatalf("should reject the install options: %s", errs)
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// guestAdditionsKey picks a guest additions version. GuestOS and Arch are
+// empty in entries that apply to every guest.
+type guestAdditionsKey struct {
+	UTMVersion string
+	GuestOS    string
+	Arch       string
+}
+
+// UTM version, guest OS and architecture to guest additions version map
+var additionsVersionMap = map[guestAdditionsKey]string{
+	{UTMVersion: "4.6.4"}: "0.229.2",
+}
+
+// guestAdditionsArchs lists, by guest OS, the architectures the UTM guest
+// tools have an installer for. Guest OSes missing here don't install from
+// the ISO, such as Linux whose agents come from the distribution.
+var guestAdditionsArchs = map[string][]string{
+	GuestOSWindows: {"x86_64", "aarch64"},
+}
+
+// CheckGuestAdditionsPlatform returns an error when the UTM guest tools
+// can't be installed on a guestOS guest of the QEMU architecture arch.
+// guestOS is empty when it isn't known before the build, which is never an
+// error.
+func CheckGuestAdditionsPlatform(guestOS string, arch string) error {
+	archs, ok := guestAdditionsArchs[guestOS]
+	if !ok {
+		return nil
+	}
+	for _, a := range archs {
+		if a == arch {
+			return nil
+		}
+	}
+	return fmt.Errorf("the UTM guest tools have no %s installer for %s guests, only for %s; "+
+		"set guest_additions_mode = \"disable\" or provide guest_additions_url and "+
+		"guest_additions_install_command", guestOS, arch, strings.Join(archs, " and "))
+}
+
+// guestAdditionsVersion returns the guest additions version that ships with
+// the given UTM version for a guestOS guest of architecture arch, or the UTM
+// version itself if none is known.
+func guestAdditionsVersion(utmVersion string, guestOS string, arch string) string {
+	v, err := ParseUTMVersion(utmVersion)
+	if err != nil {
+		return utmVersion
+	}
+	for _, key := range []guestAdditionsKey{
+		{v.String(), guestOS, arch},
+		{v.String(), guestOS, ""},
+		{v.String(), "", ""},
+	} {
+		if additionsVersion, ok := additionsVersionMap[key]; ok {
+			log.Printf("Rewriting guest additions version: %s to %s", utmVersion, additionsVersion)
+			return additionsVersion
+		}
+	}
+	return utmVersion
+}
@@ -0,0 +1,70 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckGuestAdditionsPlatform(t *testing.T) {
+	cases := []struct {
+		guestOS string
+		arch    string
+		ok      bool
+	}{
+		{GuestOSWindows, "x86_64", true},
+		{GuestOSWindows, "aarch64", true},
+		{GuestOSWindows, "riscv64", false},
+		{GuestOSLinux, "riscv64", true},
+		{"", "riscv64", true},
+	}
+	for _, tc := range cases {
+		err := CheckGuestAdditionsPlatform(tc.guestOS, tc.arch)
+		if (err == nil) != tc.ok {
+			t.Fatalf("%s/%s: bad result: %v", tc.guestOS, tc.arch, err)
+		}
+		if err != nil && !strings.Contains(err.Error(), "x86_64 and aarch64") {
+			t.Fatalf("%s/%s: should list the supported architectures: %s", tc.guestOS, tc.arch, err)
+		}
+	}
+}
+
+func TestGuestAdditionsVersion(t *testing.T) {
+	cases := map[string]string{
+		"4.6.4":      "0.229.2",
+		"4.6.4 (99)": "0.229.2",
+		"4.7.0":      "4.7.0",
+		"unknown":    "unknown",
+	}
+	for utmVersion, expected := range cases {
+		if v := guestAdditionsVersion(utmVersion, "", ""); v != expected {
+			t.Fatalf("%q: bad version: %s", utmVersion, v)
+		}
+	}
+}
+
+func TestGuestAdditionsVersion_platform(t *testing.T) {
+	defer func(m map[guestAdditionsKey]string) { additionsVersionMap = m }(additionsVersionMap)
+	additionsVersionMap = map[guestAdditionsKey]string{
+		{UTMVersion: "4.6.4"}:                                           "0.229.2",
+		{UTMVersion: "4.6.4", GuestOS: GuestOSWindows}:                  "0.229.1",
+		{UTMVersion: "4.6.4", GuestOS: GuestOSWindows, Arch: "aarch64"}: "0.229.0",
+	}
+
+	cases := []struct {
+		guestOS  string
+		arch     string
+		expected string
+	}{
+		{GuestOSWindows, "aarch64", "0.229.0"},
+		{GuestOSWindows, "x86_64", "0.229.1"},
+		{GuestOSLinux, "aarch64", "0.229.2"},
+	}
+	for _, tc := range cases {
+		if v := guestAdditionsVersion("4.6.4", tc.guestOS, tc.arch); v != tc.expected {
+			t.Fatalf("%s/%s: bad version: %s", tc.guestOS, tc.arch, v)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:generate packer-sdc struct-markdown
+
+package common
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// hostnameLabelRe matches one RFC 1123 hostname label.
+var hostnameLabelRe = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?$`)
+
+type GuestHostnameConfig struct {
+	// The hostname to give the guest, set over the communicator once the
+	// provisioners have run, with the command for the detected guest OS.
+	// This needs a communicator. Windows guests take the first label as
+	// their computer name, which can't be longer than 15 characters, and
+	// use it after the next reboot. The hostname is also available to the
+	// `windows_unattended` template as `{{ .Hostname }}`. Unset by default,
+	// which leaves the hostname alone.
+	GuestHostname string `mapstructure:"guest_hostname" required:"false"`
+}
+
+func (c *GuestHostnameConfig) Prepare(commType string) []error {
+	var errs []error
+
+	if c.GuestHostname == "" {
+		return errs
+	}
+
+	if err := validateHostname(c.GuestHostname); err != nil {
+		errs = append(errs, fmt.Errorf("guest_hostname: %s", err))
+	}
+	if commType == "none" {
+		errs = append(errs, errors.New("guest_hostname needs a communicator"))
+	}
+	if commType == "winrm" {
+		if label := ComputerName(c.GuestHostname); len(label) > 15 {
+			errs = append(errs, fmt.Errorf(
+				"guest_hostname: %q is longer than the 15 characters of a Windows computer name", label))
+		}
+	}
+
+	return errs
+}
+
+// validateHostname checks hostname against RFC 1123.
+func validateHostname(hostname string) error {
+	if len(hostname) > 253 {
+		return fmt.Errorf("%q is longer than 253 characters", hostname)
+	}
+	for _, label := range strings.Split(hostname, ".") {
+		if !hostnameLabelRe.MatchString(label) {
+			return fmt.Errorf(
+				"%q is not a valid hostname: each label must be 1 to 63 letters, digits or "+
+					"hyphens, and not start or end with a hyphen", hostname)
+		}
+	}
+	return nil
+}
+
+// ComputerName returns the first label of hostname, which is what Windows
+// uses as its computer name.
+func ComputerName(hostname string) string {
+	return strings.SplitN(hostname, ".", 2)[0]
+}
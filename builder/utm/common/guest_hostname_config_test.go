@@ -0,0 +1,48 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGuestHostnameConfigPrepare(t *testing.T) {
+	c := new(GuestHostnameConfig)
+	if errs := c.Prepare("none"); len(errs) > 0 {
+		t.Fatalf("should not have errors when unset: %#v", errs)
+	}
+
+	for _, hostname := range []string{"build01", "build-01.example.com", "a", "1host"} {
+		c := &GuestHostnameConfig{GuestHostname: hostname}
+		if errs := c.Prepare("ssh"); len(errs) > 0 {
+			t.Fatalf("%q: should not have errors: %#v", hostname, errs)
+		}
+	}
+}
+
+func TestGuestHostnameConfigPrepare_invalid(t *testing.T) {
+	for _, hostname := range []string{
+		"-build", "build-", "build_01", "build..example", "build 01",
+		strings.Repeat("a", 64), strings.Repeat("a.", 127) + "a",
+	} {
+		c := &GuestHostnameConfig{GuestHostname: hostname}
+		if errs := c.Prepare("ssh"); len(errs) != 1 {
+			t.Fatalf("%q: should be rejected: %#v", hostname, errs)
+		}
+	}
+}
+
+func TestGuestHostnameConfigPrepare_communicator(t *testing.T) {
+	c := &GuestHostnameConfig{GuestHostname: "build01"}
+	if errs := c.Prepare("none"); len(errs) != 1 {
+		t.Fatalf("should need a communicator: %#v", errs)
+	}
+
+	c = &GuestHostnameConfig{GuestHostname: "windows-build-host.example.com"}
+	errs := c.Prepare("winrm")
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "15 characters") {
+		t.Fatalf("should reject a long computer name: %#v", errs)
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// StateGuestOS is the state key of the guest OS found by StepDetectGuestOS.
+const StateGuestOS = "guest_os"
+
+// The guest operating systems StepDetectGuestOS tells apart, named like
+// GOOS.
+const (
+	GuestOSLinux   = "linux"
+	GuestOSDarwin  = "darwin"
+	GuestOSFreeBSD = "freebsd"
+	GuestOSOpenBSD = "openbsd"
+	GuestOSNetBSD  = "netbsd"
+	GuestOSWindows = "windows"
+)
+
+// GetGuestOS returns the guest OS found by StepDetectGuestOS, or an empty
+// string when it wasn't detected.
+func GetGuestOS(state multistep.StateBag) string {
+	guestOS, _ := state.Get(StateGuestOS).(string)
+	return guestOS
+}
+
+// guestOSProbe is a command that identifies the guest OS from its output.
+type guestOSProbe struct {
+	command string
+	parse   func(output string) string
+}
+
+var (
+	unameProbe = guestOSProbe{
+		command: "uname -s",
+		parse: func(output string) string {
+			switch strings.ToLower(strings.TrimSpace(output)) {
+			case "linux":
+				return GuestOSLinux
+			case "darwin":
+				return GuestOSDarwin
+			case "freebsd":
+				return GuestOSFreeBSD
+			case "openbsd":
+				return GuestOSOpenBSD
+			case "netbsd":
+				return GuestOSNetBSD
+			}
+			return ""
+		},
+	}
+	verProbe = guestOSProbe{
+		command: "cmd /c ver",
+		parse: func(output string) string {
+			if strings.Contains(output, "Windows") {
+				return GuestOSWindows
+			}
+			return ""
+		},
+	}
+)
+
+// guestOSProbes returns the probes to try in order, the most likely one for
+// the communicator first.
+func guestOSProbes(commType string) []guestOSProbe {
+	if commType == "winrm" {
+		return []guestOSProbe{verProbe, unameProbe}
+	}
+	return []guestOSProbe{unameProbe, verProbe}
+}
+
+// runGuestOSProbe runs probe on the guest and returns the OS it identifies,
+// or an empty string.
+func runGuestOSProbe(ctx context.Context, comm packersdk.Communicator, probe guestOSProbe) (string, error) {
+	var stdout bytes.Buffer
+	cmd := &packersdk.RemoteCmd{
+		Command: probe.command,
+		Stdout:  &stdout,
+		Stderr:  new(bytes.Buffer),
+	}
+	if err := comm.Start(ctx, cmd); err != nil {
+		return "", err
+	}
+	if status := cmd.Wait(); status != 0 {
+		return "", fmt.Errorf("%q exited with status %d", probe.command, status)
+	}
+	return probe.parse(stdout.String()), nil
+}
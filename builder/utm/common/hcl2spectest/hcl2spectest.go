@@ -0,0 +1,66 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package hcl2spectest helps the builders test their generated hcl2spec.
+// The builders squash the common configs, so a field added to one of them
+// leaves every builder's hcl2spec stale until `make generate` is run.
+package hcl2spectest
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// MissingFields returns the mapstructure names of the fields of config, a
+// builder Config, that spec has no entry for.
+func MissingFields(config interface{}, spec map[string]hcldec.Spec) []string {
+	var missing []string
+	for _, name := range mapstructureFields(reflect.TypeOf(config)) {
+		if _, ok := spec[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	return missing
+}
+
+// mapstructureFields returns the mapstructure names of the fields of t,
+// following squashed structs.
+func mapstructureFields(t reflect.Type) []string {
+	var names []string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		tag := strings.Split(field.Tag.Get("mapstructure"), ",")
+		if len(tag) > 1 && tag[1] == "squash" {
+			names = append(names, mapstructureFields(field.Type)...)
+			continue
+		}
+		if tag[0] != "" && tag[0] != "-" {
+			names = append(names, tag[0])
+		}
+	}
+	return names
+}
+
+// Decode decodes src with spec, the way Packer hands an HCL2 source block to
+// a builder.
+func Decode(t *testing.T, spec map[string]hcldec.Spec, src string) cty.Value {
+	t.Helper()
+
+	file, diags := hclparse.NewParser().ParseHCL([]byte(src), "source.pkr.hcl")
+	if diags.HasErrors() {
+		t.Fatalf("err: %s", diags)
+	}
+	value, diags := hcldec.Decode(file.Body, hcldec.ObjectSpec(spec), nil)
+	if diags.HasErrors() {
+		t.Fatalf("err: %s", diags)
+	}
+	return value
+}
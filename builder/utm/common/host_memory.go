@@ -0,0 +1,60 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var vmStatPageSizeRe = regexp.MustCompile(`page size of (\d+) bytes`)
+
+// availableMemory returns the bytes of RAM the host can give a new VM
+// without swapping, as reported by vm_stat.
+func availableMemory() (uint64, error) {
+	out, err := exec.Command("vm_stat").Output()
+	if err != nil {
+		return 0, fmt.Errorf("error running vm_stat: %s", err)
+	}
+	return parseVMStat(string(out))
+}
+
+// parseVMStat reads the output of vm_stat. Free pages are available, and
+// so are inactive and speculative pages, which macOS reclaims before it
+// swaps.
+func parseVMStat(output string) (uint64, error) {
+	m := vmStatPageSizeRe.FindStringSubmatch(output)
+	if m == nil {
+		return 0, fmt.Errorf("no page size in vm_stat output")
+	}
+	pageSize, err := strconv.ParseUint(m[1], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("bad page size in vm_stat output: %s", err)
+	}
+
+	var pages uint64
+	found := 0
+	for _, line := range strings.Split(output, "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		switch strings.TrimSpace(key) {
+		case "Pages free", "Pages inactive", "Pages speculative":
+			n, err := strconv.ParseUint(strings.TrimSuffix(strings.TrimSpace(value), "."), 10, 64)
+			if err != nil {
+				return 0, fmt.Errorf("bad vm_stat line %q: %s", line, err)
+			}
+			pages += n
+			found++
+		}
+	}
+	if found == 0 {
+		return 0, fmt.Errorf("no free pages in vm_stat output")
+	}
+	return pages * pageSize, nil
+}
@@ -0,0 +1,38 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import "testing"
+
+func TestParseVMStat(t *testing.T) {
+	output := `Mach Virtual Memory Statistics: (page size of 16384 bytes)
+Pages free:                                3000.
+Pages active:                            400000.
+Pages inactive:                            5000.
+Pages speculative:                         2000.
+Pages throttled:                              0.
+Pages wired down:                        100000.
+Pages purgeable:                           1000.
+"Translation faults":                 123456789.
+`
+	available, err := parseVMStat(output)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if expected := uint64(10000 * 16384); available != expected {
+		t.Fatalf("expected %d, got %d", expected, available)
+	}
+}
+
+func TestParseVMStat_bad(t *testing.T) {
+	for _, output := range []string{
+		"Pages free: 3000.\n",
+		"Mach Virtual Memory Statistics: (page size of 4096 bytes)\n",
+		"Mach Virtual Memory Statistics: (page size of 4096 bytes)\nPages free: many.\n",
+	} {
+		if _, err := parseVMStat(output); err == nil {
+			t.Fatalf("should reject %q", output)
+		}
+	}
+}
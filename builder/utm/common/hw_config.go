@@ -13,29 +13,47 @@ import (
 
 type HWConfig struct {
 	// The number of cpus to use for building the VM.
-	// Defaults to 1.
+	// Defaults to 1, or to UTM's default in the cloud builder.
 	CpuCount int `mapstructure:"cpus" required:"false"`
 	// The amount of memory to use for building the VM
-	// in megabytes. Defaults to 512 megabytes.
+	// in megabytes. Defaults to 512 megabytes, or to UTM's default in the
+	// cloud builder. With `balloon`, this is the most
+	// the VM can use rather than what it always takes from the host.
 	MemorySize int `mapstructure:"memory" required:"false"`
+	// The memory, in megabytes, the host must still have available once the
+	// VM's `memory` is taken. When set, the build fails before anything is
+	// downloaded if the host RAM that is free or can be reclaimed without
+	// swapping is less than `memory` plus this margin. Off by default.
+	MinHostMemory int `mapstructure:"min_host_memory" required:"false"`
 }
 
 func (c *HWConfig) Prepare(ctx *interpolate.Context) []error {
-	var errs []error
+	errs := c.Validate()
 
-	// Hardware and cpu options
-	if c.CpuCount < 0 {
-		errs = append(errs, fmt.Errorf("an invalid number of cpus was specified (cpus < 0): %d", c.CpuCount))
-	}
 	if c.CpuCount == 0 {
 		c.CpuCount = 1
 	}
+	if c.MemorySize == 0 {
+		c.MemorySize = 512
+	}
 
+	return errs
+}
+
+// Validate checks the hardware options without defaulting them, for the
+// cloud builder which leaves unset cpus and memory to UTM.
+func (c *HWConfig) Validate() []error {
+	var errs []error
+
+	// Hardware and cpu options
+	if c.CpuCount < 0 {
+		errs = append(errs, fmt.Errorf("cpus must not be negative, got %d", c.CpuCount))
+	}
 	if c.MemorySize < 0 {
-		errs = append(errs, fmt.Errorf("an invalid memory size was specified (memory < 0): %d", c.MemorySize))
+		errs = append(errs, fmt.Errorf("memory must not be negative, got %d", c.MemorySize))
 	}
-	if c.MemorySize == 0 {
-		c.MemorySize = 512
+	if c.MinHostMemory < 0 {
+		errs = append(errs, fmt.Errorf("min_host_memory must not be negative, got %d", c.MinHostMemory))
 	}
 
 	return errs
@@ -0,0 +1,45 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+)
+
+func TestHWConfigPrepare(t *testing.T) {
+	c := new(HWConfig)
+	if errs := c.Prepare(interpolate.NewContext()); len(errs) > 0 {
+		t.Fatalf("should not have error: %s", errs)
+	}
+	if c.CpuCount != 1 || c.MemorySize != 512 {
+		t.Fatalf("bad defaults: %d cpus, %d MB", c.CpuCount, c.MemorySize)
+	}
+
+	c = &HWConfig{CpuCount: -1, MemorySize: -1}
+	if errs := c.Prepare(interpolate.NewContext()); len(errs) != 2 {
+		t.Fatalf("should report both negative values: %s", errs)
+	}
+
+	c = &HWConfig{MinHostMemory: -1}
+	if errs := c.Prepare(interpolate.NewContext()); len(errs) != 1 {
+		t.Fatalf("should reject a negative min_host_memory: %s", errs)
+	}
+}
+
+func TestHWConfigValidate(t *testing.T) {
+	c := new(HWConfig)
+	if errs := c.Validate(); len(errs) > 0 {
+		t.Fatalf("should not have error: %s", errs)
+	}
+	if c.CpuCount != 0 || c.MemorySize != 0 {
+		t.Fatalf("should leave the defaults to UTM: %d cpus, %d MB", c.CpuCount, c.MemorySize)
+	}
+
+	c = &HWConfig{CpuCount: -1, MemorySize: -1, MinHostMemory: -1}
+	if errs := c.Validate(); len(errs) != 3 {
+		t.Fatalf("should report every negative value: %s", errs)
+	}
+}
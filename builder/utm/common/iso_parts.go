@@ -0,0 +1,175 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ISOPartsManifestSuffix is the extension of a manifest listing the parts
+// of a split ISO, one path per line, relative to the manifest.
+const ISOPartsManifestSuffix = ".parts"
+
+// localPath returns the path of a local source, either a path or a file://
+// URL, or false for a remote source.
+func localPath(source string) (string, bool) {
+	if path, ok := strings.CutPrefix(source, "file://"); ok {
+		return path, true
+	}
+	if strings.Contains(source, "://") {
+		return "", false
+	}
+	return source, true
+}
+
+// ISOParts returns the parts of the split ISO source names, in the order
+// they are joined: the files of a local directory sorted by name, or the
+// paths listed in a local .parts manifest, where empty lines and lines
+// starting with # are skipped. It returns false when source is not a split
+// ISO.
+func ISOParts(source string) ([]string, bool, error) {
+	path, ok := localPath(source)
+	if !ok {
+		return nil, false, nil
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, false, nil
+	}
+
+	var parts []string
+	switch {
+	case info.IsDir():
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, true, fmt.Errorf("error reading the ISO parts in %s: %s", path, err)
+		}
+		for _, entry := range entries {
+			if entry.Type().IsRegular() && !strings.HasPrefix(entry.Name(), ".") {
+				parts = append(parts, filepath.Join(path, entry.Name()))
+			}
+		}
+		sort.Strings(parts)
+	case strings.HasSuffix(path, ISOPartsManifestSuffix):
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, true, fmt.Errorf("error reading the ISO parts manifest: %s", err)
+		}
+		for _, line := range strings.Split(string(content), "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			if !filepath.IsAbs(line) {
+				line = filepath.Join(filepath.Dir(path), line)
+			}
+			parts = append(parts, line)
+		}
+	default:
+		return nil, false, nil
+	}
+
+	if len(parts) == 0 {
+		return nil, true, fmt.Errorf("no ISO parts found in %s", path)
+	}
+	for _, part := range parts {
+		if info, err := os.Stat(part); err != nil {
+			return nil, true, fmt.Errorf("ISO part %s is missing: %s", part, err)
+		} else if !info.Mode().IsRegular() {
+			return nil, true, fmt.Errorf("ISO part %s is not a file", part)
+		}
+	}
+	return parts, true, nil
+}
+
+// checksumHash returns the hash of checksum, such as "sha256:<hex>" or a
+// bare hex digest whose type is told by its length, and the expected digest.
+// It returns a nil hash for "none".
+func checksumHash(checksum string) (hash.Hash, string, error) {
+	if checksum == "" || checksum == "none" {
+		return nil, "", nil
+	}
+	checksumType, digest, hasType := strings.Cut(checksum, ":")
+	if !hasType {
+		checksumType, digest = "", checksum
+		for name, length := range checksumLengths {
+			if len(digest) == length {
+				checksumType = name
+			}
+		}
+	}
+
+	switch strings.ToLower(checksumType) {
+	case "md5":
+		return md5.New(), strings.ToLower(digest), nil
+	case "sha1":
+		return sha1.New(), strings.ToLower(digest), nil
+	case "sha256":
+		return sha256.New(), strings.ToLower(digest), nil
+	case "sha512":
+		return sha512.New(), strings.ToLower(digest), nil
+	}
+	return nil, "", fmt.Errorf("a split ISO needs the checksum of the joined ISO as a "+
+		"md5, sha1, sha256 or sha512 digest, got %q", checksum)
+}
+
+// JoinISOParts concatenates parts into dst and verifies the joined ISO
+// against checksum, removing dst when it doesn't match.
+func JoinISOParts(ctx context.Context, parts []string, dst string, checksum string) error {
+	h, digest, err := checksumHash(checksum)
+	if err != nil {
+		return err
+	}
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return fmt.Errorf("error creating the joined ISO: %s", err)
+	}
+	var w io.Writer = out
+	if h != nil {
+		w = io.MultiWriter(out, h)
+	}
+
+	joinErr := func() error {
+		for _, part := range parts {
+			if err := ctx.Err(); err != nil {
+				return fmt.Errorf("interrupted while joining the ISO parts: %w", err)
+			}
+			in, err := os.Open(part)
+			if err != nil {
+				return fmt.Errorf("error opening ISO part: %s", err)
+			}
+			_, err = io.Copy(w, in)
+			in.Close()
+			if err != nil {
+				return fmt.Errorf("error joining ISO part %s: %s", part, err)
+			}
+		}
+		return nil
+	}()
+	if err := out.Close(); err != nil && joinErr == nil {
+		joinErr = fmt.Errorf("error writing the joined ISO: %s", err)
+	}
+	if joinErr == nil && h != nil {
+		if sum := hex.EncodeToString(h.Sum(nil)); sum != digest {
+			joinErr = fmt.Errorf("checksum of the joined ISO does not match: expected %s, got %s", digest, sum)
+		}
+	}
+	if joinErr != nil {
+		_ = os.Remove(dst)
+	}
+	return joinErr
+}
@@ -0,0 +1,106 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// testISOParts writes the parts of a split ISO in a directory, returning it
+// and the sha256 of the joined ISO.
+func testISOParts(t *testing.T, parts map[string]string) (string, string) {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range parts {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+	sum := sha256.Sum256([]byte("part1part2"))
+	return dir, hex.EncodeToString(sum[:])
+}
+
+func TestISOParts_directory(t *testing.T) {
+	dir, _ := testISOParts(t, map[string]string{"win.iso.002": "part2", "win.iso.001": "part1", ".DS_Store": ""})
+
+	parts, split, err := ISOParts("file://" + dir)
+	if err != nil || !split {
+		t.Fatalf("should find the parts: %v %s", split, err)
+	}
+	expected := []string{filepath.Join(dir, "win.iso.001"), filepath.Join(dir, "win.iso.002")}
+	if !reflect.DeepEqual(parts, expected) {
+		t.Fatalf("bad parts: %#v", parts)
+	}
+}
+
+func TestISOParts_manifest(t *testing.T) {
+	dir, _ := testISOParts(t, map[string]string{"b": "part1", "a": "part2"})
+	manifest := filepath.Join(dir, "win.iso.parts")
+	if err := os.WriteFile(manifest, []byte("# joined in this order\nb\n\na\n"), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	parts, split, err := ISOParts(manifest)
+	if err != nil || !split {
+		t.Fatalf("should find the parts: %v %s", split, err)
+	}
+	if !reflect.DeepEqual(parts, []string{filepath.Join(dir, "b"), filepath.Join(dir, "a")}) {
+		t.Fatalf("bad parts: %#v", parts)
+	}
+
+	if err := os.WriteFile(manifest, []byte("b\nc\n"), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, _, err := ISOParts(manifest); err == nil {
+		t.Fatal("should reject a missing part")
+	}
+}
+
+func TestISOParts_notSplit(t *testing.T) {
+	dir, _ := testISOParts(t, map[string]string{"boot.iso": "iso"})
+	for _, source := range []string{"https://example.com/boot.iso", filepath.Join(dir, "boot.iso"), filepath.Join(dir, "missing.iso")} {
+		if _, split, err := ISOParts(source); split || err != nil {
+			t.Fatalf("%s: should not be split: %s", source, err)
+		}
+	}
+
+	if _, split, err := ISOParts(t.TempDir()); !split || err == nil {
+		t.Fatal("should reject an empty directory")
+	}
+}
+
+func TestJoinISOParts(t *testing.T) {
+	dir, sum := testISOParts(t, map[string]string{"1": "part1", "2": "part2"})
+	parts := []string{filepath.Join(dir, "1"), filepath.Join(dir, "2")}
+	dst := filepath.Join(t.TempDir(), "joined.iso")
+
+	if err := JoinISOParts(context.Background(), parts, dst, "sha256:"+sum); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if content, _ := os.ReadFile(dst); string(content) != "part1part2" {
+		t.Fatalf("bad joined ISO: %q", content)
+	}
+
+	// A bare digest is told apart by its length
+	if err := JoinISOParts(context.Background(), parts, dst, sum); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if err := JoinISOParts(context.Background(), parts[1:], dst, sum); err == nil {
+		t.Fatal("should reject a joined ISO that doesn't match its checksum")
+	}
+	if _, err := os.Stat(dst); !os.IsNotExist(err) {
+		t.Fatal("should remove a joined ISO that doesn't match its checksum")
+	}
+
+	if err := JoinISOParts(context.Background(), parts, dst, "file:SHA256SUMS"); err == nil {
+		t.Fatal("should need the checksum of the joined ISO")
+	}
+}
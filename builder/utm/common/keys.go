@@ -0,0 +1,148 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/packer-plugin-sdk/bootcommand"
+)
+
+// RunKeySequence parses keys with the boot_command syntax, literal text with
+// special keys such as `<enter>`, `<leftShiftOn>` and `<wait5>`, and types
+// it with d. Both Driver.SendKeys and the boot_command step go through it,
+// each with its own way of typing.
+func RunKeySequence(ctx context.Context, d bootcommand.BCDriver, keys string) error {
+	seq, err := bootcommand.GenerateExpressionSequence(keys)
+	if err != nil {
+		return fmt.Errorf("error parsing keys: %s", err)
+	}
+	return seq.Do(ctx, d)
+}
+
+// KeyEvent is a key typed by a KeyRecorder. Key is the character typed, or
+// the name of a special key in angle brackets such as `<enter>`.
+type KeyEvent struct {
+	Key    string
+	Action bootcommand.KeyAction
+}
+
+func (e KeyEvent) String() string {
+	return fmt.Sprintf("%s(%s)", e.Action, e.Key)
+}
+
+// KeyRecorder is a bootcommand.BCDriver that records the keys typed instead
+// of sending them anywhere, for tests.
+type KeyRecorder struct {
+	Events  []KeyEvent
+	Flushes int
+}
+
+func (r *KeyRecorder) SendKey(key rune, action bootcommand.KeyAction) error {
+	r.Events = append(r.Events, KeyEvent{Key: string(key), Action: action})
+	return nil
+}
+
+func (r *KeyRecorder) SendSpecial(special string, action bootcommand.KeyAction) error {
+	r.Events = append(r.Events, KeyEvent{Key: "<" + special + ">", Action: action})
+	return nil
+}
+
+func (r *KeyRecorder) Flush() error {
+	r.Flushes++
+	return nil
+}
+
+// macKeyCodes maps the boot_command special keys to macOS virtual key codes,
+// for System Events.
+var macKeyCodes = map[string]int{
+	"bs": 51, "del": 117, "down": 125, "end": 119, "enter": 36, "esc": 53,
+	"f1": 122, "f2": 120, "f3": 99, "f4": 118, "f5": 96, "f6": 97,
+	"f7": 98, "f8": 100, "f9": 101, "f10": 109, "f11": 103, "f12": 111,
+	"home": 115, "insert": 114, "left": 123, "pagedown": 121, "pageup": 116,
+	"return": 36, "right": 124, "spacebar": 49, "tab": 48, "up": 126,
+}
+
+// macModifiers maps the boot_command modifier keys to System Events
+// modifiers, which don't tell left and right apart.
+var macModifiers = map[string]string{
+	"leftshift": "shift", "rightshift": "shift",
+	"leftctrl": "control", "rightctrl": "control",
+	"leftalt": "option", "rightalt": "option",
+	"leftoption": "option", "rightoption": "option",
+	"leftsuper": "command", "rightsuper": "command",
+	"leftcommand": "command", "rightcommand": "command",
+}
+
+// appleScriptKeyDriver types keys into the window of a VM with
+// send_keys.applescript. Keys are buffered as the script's tokens, text:
+// for text, key: for a key code and down: and up: for modifiers, and sent
+// in one go on Flush.
+type appleScriptKeyDriver struct {
+	ctx    context.Context
+	driver Driver
+	vmId   string
+	tokens []string
+	text   strings.Builder
+}
+
+func (d *appleScriptKeyDriver) flushText() {
+	if d.text.Len() > 0 {
+		d.tokens = append(d.tokens, "text:"+d.text.String())
+		d.text.Reset()
+	}
+}
+
+func (d *appleScriptKeyDriver) SendKey(key rune, action bootcommand.KeyAction) error {
+	// System Events can only hold modifiers down, so a held key is typed
+	// once when pressed.
+	if action == bootcommand.KeyOff {
+		return nil
+	}
+	d.text.WriteRune(key)
+	return nil
+}
+
+func (d *appleScriptKeyDriver) SendSpecial(special string, action bootcommand.KeyAction) error {
+	if modifier, ok := macModifiers[special]; ok {
+		d.flushText()
+		switch action {
+		case bootcommand.KeyOn:
+			d.tokens = append(d.tokens, "down:"+modifier)
+		case bootcommand.KeyOff:
+			d.tokens = append(d.tokens, "up:"+modifier)
+		default:
+			// A modifier pressed alone does nothing.
+		}
+		return nil
+	}
+
+	code, ok := macKeyCodes[special]
+	if !ok {
+		return fmt.Errorf("special key <%s> is not supported", special)
+	}
+	if action == bootcommand.KeyOff {
+		return nil
+	}
+	d.flushText()
+	d.tokens = append(d.tokens, "key:"+strconv.Itoa(code))
+	return nil
+}
+
+func (d *appleScriptKeyDriver) Flush() error {
+	d.flushText()
+	if len(d.tokens) == 0 {
+		return nil
+	}
+
+	command := append([]string{"send_keys.applescript", d.vmId}, d.tokens...)
+	d.tokens = nil
+	if _, err := d.driver.ExecuteOsaScript(d.ctx, command...); err != nil {
+		return fmt.Errorf("error sending keys: %s", err)
+	}
+	return nil
+}
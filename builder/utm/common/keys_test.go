@@ -0,0 +1,126 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/bootcommand"
+)
+
+func TestAppleScriptKeyDriver_impl(t *testing.T) {
+	var _ bootcommand.BCDriver = new(appleScriptKeyDriver)
+	var _ bootcommand.BCDriver = new(KeyRecorder)
+}
+
+func TestRunKeySequence(t *testing.T) {
+	recorder := new(KeyRecorder)
+	if err := RunKeySequence(context.Background(), recorder, "ab<enter><leftShiftOn>c<leftShiftOff>"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := []KeyEvent{
+		{Key: "a", Action: bootcommand.KeyPress},
+		{Key: "b", Action: bootcommand.KeyPress},
+		{Key: "<enter>", Action: bootcommand.KeyPress},
+		{Key: "<leftshift>", Action: bootcommand.KeyOn},
+		{Key: "c", Action: bootcommand.KeyPress},
+		{Key: "<leftshift>", Action: bootcommand.KeyOff},
+	}
+	if !reflect.DeepEqual(recorder.Events, expected) {
+		t.Fatalf("bad events: %v", recorder.Events)
+	}
+	if recorder.Flushes != 1 {
+		t.Fatalf("expected 1 flush, got %d", recorder.Flushes)
+	}
+}
+
+func TestRunKeySequence_cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	recorder := new(KeyRecorder)
+	if err := RunKeySequence(ctx, recorder, "<wait10s>a"); err == nil {
+		t.Fatal("should error when cancelled")
+	}
+	if len(recorder.Events) != 0 {
+		t.Fatalf("should not type after cancel, got: %v", recorder.Events)
+	}
+}
+
+func TestAppleScriptKeyDriver(t *testing.T) {
+	mock := new(DriverMock)
+	d := &appleScriptKeyDriver{ctx: context.Background(), driver: mock, vmId: "vm-id"}
+
+	if err := RunKeySequence(context.Background(), d, "linux<tab><leftCtrlOn>c<leftCtrlOff><enter>"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := [][]string{{
+		"send_keys.applescript", "vm-id",
+		"text:linux", "key:48", "down:control", "text:c", "up:control", "key:36",
+	}}
+	if !reflect.DeepEqual(mock.ExecuteOsaCalls, expected) {
+		t.Fatalf("bad calls: %#v", mock.ExecuteOsaCalls)
+	}
+}
+
+func TestAppleScriptKeyDriver_wait(t *testing.T) {
+	mock := new(DriverMock)
+	d := &appleScriptKeyDriver{ctx: context.Background(), driver: mock, vmId: "vm-id"}
+
+	// Keys before a wait are sent before waiting.
+	if err := RunKeySequence(context.Background(), d, "a<wait0.001s>b"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	expected := [][]string{
+		{"send_keys.applescript", "vm-id", "text:a"},
+		{"send_keys.applescript", "vm-id", "text:b"},
+	}
+	if !reflect.DeepEqual(mock.ExecuteOsaCalls, expected) {
+		t.Fatalf("bad calls: %#v", mock.ExecuteOsaCalls)
+	}
+}
+
+func TestAppleScriptKeyDriver_unsupported(t *testing.T) {
+	mock := new(DriverMock)
+	d := &appleScriptKeyDriver{ctx: context.Background(), driver: mock, vmId: "vm-id"}
+
+	if err := RunKeySequence(context.Background(), d, "<menu>"); err == nil {
+		t.Fatal("should error on an unsupported key")
+	}
+	if len(mock.ExecuteOsaCalls) != 0 {
+		t.Fatalf("should not send keys, got: %#v", mock.ExecuteOsaCalls)
+	}
+}
+
+func TestAppleScriptKeyDriver_scriptError(t *testing.T) {
+	mock := &DriverMock{ExecuteOsaErrs: []error{errors.New("not allowed assistive access")}}
+	d := &appleScriptKeyDriver{ctx: context.Background(), driver: mock, vmId: "vm-id"}
+
+	if err := RunKeySequence(context.Background(), d, "a"); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestDriverMock_SendKeys(t *testing.T) {
+	mock := new(DriverMock)
+	if err := mock.SendKeys(context.Background(), "vm-id", "y<enter>"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if !reflect.DeepEqual(mock.SendKeysCalls, [][]string{{"vm-id", "y<enter>"}}) {
+		t.Fatalf("bad calls: %#v", mock.SendKeysCalls)
+	}
+	expected := []KeyEvent{
+		{Key: "y", Action: bootcommand.KeyPress},
+		{Key: "<enter>", Action: bootcommand.KeyPress},
+	}
+	if !reflect.DeepEqual(mock.SendKeysEvents, expected) {
+		t.Fatalf("bad events: %v", mock.SendKeysEvents)
+	}
+}
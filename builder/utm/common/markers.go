@@ -0,0 +1,99 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// The bundled scripts print their results as KEY=value lines, such as
+// UUID=..., rather than bare values or prose. AppleScript and UTM translate
+// their messages to the language of the host, so the plugin only ever reads
+// these markers, never the text around them.
+const (
+	// MarkerUUID carries the id of a VM or drive a script created.
+	MarkerUUID = "UUID"
+	// MarkerName carries the name of a VM.
+	MarkerName = "NAME"
+	// MarkerStatus carries the power state of a VM, one of VMStates.
+	MarkerStatus = "STATUS"
+	// MarkerIP carries an IP address of the guest, one per line.
+	MarkerIP = "IP"
+	// MarkerVersion carries the version of UTM.
+	MarkerVersion = "VERSION"
+	// MarkerError carries why a script failed, such as
+	// MarkerErrorUnsupported.
+	MarkerError = "ERROR"
+)
+
+// MarkerErrorUnsupported is the MarkerError of a script asking UTM for
+// something the backend of the VM can't do.
+const MarkerErrorUnsupported = "unsupported"
+
+// markerValues returns the values of the key markers in output, in order.
+func markerValues(output string, key string) []string {
+	var values []string
+	prefix := key + "="
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		// osascript prints the message of an error after the script name
+		// and a colon, with the marker the script put first.
+		if i := strings.Index(line, prefix); i >= 0 && (i == 0 || line[i-1] == ' ') {
+			values = append(values, strings.TrimSpace(line[i+len(prefix):]))
+		}
+	}
+	return values
+}
+
+// markerValue returns the value of the first key marker in output.
+func markerValue(output string, key string) (string, bool) {
+	values := markerValues(output, key)
+	if len(values) == 0 {
+		return "", false
+	}
+	return values[0], true
+}
+
+var (
+	uuidRe = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+	// looseUUIDRe finds an id in the output of scripts from scripts_dir
+	// written before the markers.
+	looseUUIDRe = regexp.MustCompile(`[0-9a-fA-F-]{36}`)
+)
+
+// ParseUUID reads the UUID marker out of what a script printed.
+func ParseUUID(output string) (string, error) {
+	if uuid, ok := markerValue(output, MarkerUUID); ok {
+		if !uuidRe.MatchString(uuid) {
+			return "", fmt.Errorf("malformed %s marker %q", MarkerUUID, uuid)
+		}
+		return uuid, nil
+	}
+	if uuid := looseUUIDRe.FindString(output); uuid != "" {
+		return uuid, nil
+	}
+	return "", fmt.Errorf("no %s in output: %q", MarkerUUID, output)
+}
+
+// parseStatus reads the STATUS marker out of what a script printed. utmctl
+// prints the bare state, which is used as it is.
+func parseStatus(output string) string {
+	if status, ok := markerValue(output, MarkerStatus); ok {
+		return status
+	}
+	return strings.TrimSpace(output)
+}
+
+// hasErrorMarker tells whether output, usually what osascript printed on
+// stderr, carries the ERROR marker with code.
+func hasErrorMarker(output string, code string) bool {
+	for _, value := range markerValues(output, MarkerError) {
+		if fields := strings.Fields(value); len(fields) > 0 && fields[0] == code {
+			return true
+		}
+	}
+	return false
+}
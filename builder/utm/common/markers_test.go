@@ -0,0 +1,101 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseUUID(t *testing.T) {
+	const id = "7FB247A3-DC9F-4A61-A123-0AEE1BEEC636"
+	for _, output := range []string{
+		"UUID=" + id,
+		"UUID=" + id + "\n",
+		// Whatever else a patched script prints is ignored.
+		"Lecteur ajouté\nUUID=" + id,
+		// scripts_dir copies written before the markers
+		id,
+		"virtual machine id " + id,
+	} {
+		got, err := ParseUUID(output)
+		if err != nil {
+			t.Fatalf("%q: err: %s", output, err)
+		}
+		if got != id {
+			t.Fatalf("%q: got %q", output, got)
+		}
+	}
+
+	for _, output := range []string{"", "UUID=not-a-uuid", "La machine virtuelle est introuvable."} {
+		if _, err := ParseUUID(output); err == nil {
+			t.Fatalf("%q: should have error", output)
+		}
+	}
+}
+
+func TestParseStatus(t *testing.T) {
+	cases := map[string]string{
+		"STATUS=started\n": "started",
+		// utmctl prints the bare state
+		"stopped\n": "stopped",
+	}
+	for output, want := range cases {
+		if got := parseStatus(output); got != want {
+			t.Fatalf("%q: got %q, want %q", output, got, want)
+		}
+	}
+}
+
+func TestHasErrorMarker_localized(t *testing.T) {
+	unsupported := []string{
+		"-:512:540: execution error: ERROR=unsupported Opération non prise en charge par le back-end. (-2700)",
+		"-:512:540: execution error: ERROR=unsupported バックエンドでサポートされていない操作です。 (-2700)",
+		"ERROR=unsupported",
+	}
+	for _, output := range unsupported {
+		if !hasErrorMarker(output, MarkerErrorUnsupported) {
+			t.Fatalf("%q: should find the marker", output)
+		}
+		if !isSuspendUnsupported(output) {
+			t.Fatalf("%q: should recognize a backend that can't save the state", output)
+		}
+	}
+
+	other := []string{
+		"-:512:540: execution error: Machine virtuelle non valide. (-2700)",
+		"-:512:540: execution error: 無効な仮想マシンです。 (-2700)",
+		"-:512:540: execution error: ERROR=unsupportedish (-2700)",
+		"NOERROR=unsupported",
+	}
+	for _, output := range other {
+		if hasErrorMarker(output, MarkerErrorUnsupported) {
+			t.Fatalf("%q: should not find the marker", output)
+		}
+	}
+}
+
+func TestParseCloneOutput_markers(t *testing.T) {
+	const id = "7FB247A3-DC9F-4A61-A123-0AEE1BEEC636"
+	vmId, sourceName, err := parseCloneOutput("UUID=" + id + "\nNAME=ゴールデンイメージ\n")
+	if err != nil || vmId != id || sourceName != "ゴールデンイメージ" {
+		t.Fatalf("bad: %q %q %v", vmId, sourceName, err)
+	}
+
+	for _, output := range []string{"UUID=" + id + "\nNAME=", "UUID=bad\nNAME=source"} {
+		if _, _, err := parseCloneOutput(output); err == nil {
+			t.Errorf("%q: should fail", output)
+		}
+	}
+}
+
+func TestParseIPAddresses_markers(t *testing.T) {
+	got, err := parseIPAddresses("IP=192.168.64.5\nIP=fe80::1%en0\n")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !reflect.DeepEqual(got, []string{"192.168.64.5", "fe80::1%en0"}) {
+		t.Fatalf("bad: %#v", got)
+	}
+}
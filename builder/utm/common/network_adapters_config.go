@@ -0,0 +1,136 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:generate packer-sdc struct-markdown
+//go:generate packer-sdc mapstructure-to-hcl2 -type NetworkAdapter
+
+package common
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// These are the network modes of a UTM network adapter.
+const (
+	NetworkModeShared   string = "shared"
+	NetworkModeEmulated string = "emulated"
+	NetworkModeHost     string = "host"
+	NetworkModeBridged  string = "bridged"
+)
+
+// networkModeCodes maps the network modes to the codes UTM uses for them
+// in AppleScript.
+var networkModeCodes = map[string]string{
+	NetworkModeShared:   "ShRd",
+	NetworkModeEmulated: "EmUd",
+	NetworkModeHost:     "HsOo",
+	NetworkModeBridged:  "BrGd",
+}
+
+// DefaultCommAdapterIndex is the adapter the communicator port is
+// forwarded on without network_adapters, the 'Emulated VLAN' adapter the
+// builders add after the 'Shared Network' one.
+const DefaultCommAdapterIndex = 1
+
+// NetworkAdapter is a network adapter of the VM, added in the order it is
+// listed.
+//
+// HCL2 example:
+//
+// ```hcl
+//
+//	network_adapters {
+//	  mode = "emulated"
+//	}
+//	network_adapters {
+//	  mode             = "bridged"
+//	  mac              = "52:54:00:12:34:56"
+//	  bridge_interface = "en0"
+//	}
+//
+// ```
+type NetworkAdapter struct {
+	// The network mode of the adapter, one of `shared`, `emulated`, `host`
+	// or `bridged`. Port forwarding for the communicator needs an
+	// `emulated` adapter.
+	Mode string `mapstructure:"mode" required:"true"`
+	// The MAC address of the adapter, such as `52:54:00:12:34:56`. UTM
+	// picks a random one when unset.
+	MAC string `mapstructure:"mac" required:"false"`
+	// The host interface a `bridged` adapter is bridged to, such as `en0`.
+	// UTM uses the default interface when unset. Only valid with
+	// `bridged`.
+	BridgeInterface string `mapstructure:"bridge_interface" required:"false"`
+}
+
+type NetworkAdaptersConfig struct {
+	// The network adapters of the VM, replacing the 'Shared Network' and
+	// 'Emulated VLAN' adapters the builder adds by default. See
+	// [Network adapters](#network-adapters).
+	NetworkAdapters []NetworkAdapter `mapstructure:"network_adapters" required:"false"`
+	// The index, from 0, of the adapter in `network_adapters` the
+	// communicator port is forwarded on. Defaults to the first `emulated`
+	// adapter.
+	CommunicatorAdapter *int `mapstructure:"communicator_adapter" required:"false"`
+}
+
+func (c *NetworkAdaptersConfig) Prepare(commType string, skipNatMapping bool) []error {
+	var errs []error
+
+	for i := range c.NetworkAdapters {
+		adapter := &c.NetworkAdapters[i]
+		adapter.Mode = strings.ToLower(adapter.Mode)
+		if _, ok := networkModeCodes[adapter.Mode]; !ok {
+			errs = append(errs, fmt.Errorf(
+				"network_adapters[%d]: mode must be one of shared, emulated, host or bridged, got %q", i, adapter.Mode))
+		}
+		if adapter.BridgeInterface != "" && adapter.Mode != NetworkModeBridged {
+			errs = append(errs, fmt.Errorf(
+				"network_adapters[%d]: bridge_interface is only valid with the bridged mode", i))
+		}
+		if adapter.MAC != "" {
+			if mac, err := net.ParseMAC(adapter.MAC); err != nil || len(mac) != 6 {
+				errs = append(errs, fmt.Errorf(
+					"network_adapters[%d]: mac must be a MAC address such as 52:54:00:12:34:56, got %q", i, adapter.MAC))
+			}
+		}
+	}
+
+	if len(c.NetworkAdapters) == 0 {
+		if c.CommunicatorAdapter != nil {
+			errs = append(errs, fmt.Errorf("communicator_adapter needs network_adapters"))
+		}
+		return errs
+	}
+
+	if c.CommunicatorAdapter == nil {
+		for i, adapter := range c.NetworkAdapters {
+			if adapter.Mode == NetworkModeEmulated {
+				index := i
+				c.CommunicatorAdapter = &index
+				break
+			}
+		}
+	}
+
+	// The communicator reaches the guest through a port forwarded on an
+	// emulated adapter, unless the NAT mapping is skipped.
+	if commType == "none" || skipNatMapping {
+		return errs
+	}
+	if c.CommunicatorAdapter == nil {
+		errs = append(errs, fmt.Errorf(
+			"network_adapters needs an emulated adapter for the communicator, or skip_nat_mapping"))
+	} else if index := *c.CommunicatorAdapter; index < 0 || index >= len(c.NetworkAdapters) {
+		errs = append(errs, fmt.Errorf(
+			"communicator_adapter must be the index of one of the %d network_adapters, got %d",
+			len(c.NetworkAdapters), index))
+	} else if mode := c.NetworkAdapters[index].Mode; mode != NetworkModeEmulated {
+		errs = append(errs, fmt.Errorf(
+			"communicator_adapter must be an emulated adapter for port forwarding, got %s", mode))
+	}
+
+	return errs
+}
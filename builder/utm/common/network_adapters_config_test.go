@@ -0,0 +1,83 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"testing"
+)
+
+func TestNetworkAdaptersConfigPrepare(t *testing.T) {
+	c := new(NetworkAdaptersConfig)
+	if errs := c.Prepare("ssh", false); len(errs) > 0 {
+		t.Fatalf("should not have errors: %#v", errs)
+	}
+
+	c = &NetworkAdaptersConfig{NetworkAdapters: []NetworkAdapter{
+		{Mode: "Shared"},
+		{Mode: "emulated", MAC: "52:54:00:12:34:56"},
+		{Mode: "bridged", BridgeInterface: "en0"},
+	}}
+	if errs := c.Prepare("ssh", false); len(errs) > 0 {
+		t.Fatalf("should not have errors: %#v", errs)
+	}
+	if c.NetworkAdapters[0].Mode != NetworkModeShared {
+		t.Fatalf("should lower the mode: %s", c.NetworkAdapters[0].Mode)
+	}
+	if c.CommunicatorAdapter == nil || *c.CommunicatorAdapter != 1 {
+		t.Fatalf("should default to the emulated adapter: %v", c.CommunicatorAdapter)
+	}
+}
+
+func TestNetworkAdaptersConfigPrepare_invalidAdapters(t *testing.T) {
+	c := &NetworkAdaptersConfig{NetworkAdapters: []NetworkAdapter{
+		{Mode: "emulated"},
+		{Mode: "nat"},
+		{Mode: "shared", BridgeInterface: "en0"},
+		{Mode: "host", MAC: "52:54:00:12:34"},
+	}}
+	if errs := c.Prepare("ssh", false); len(errs) != 3 {
+		t.Fatalf("should reject the mode, bridge_interface and mac: %#v", errs)
+	}
+}
+
+func TestNetworkAdaptersConfigPrepare_communicatorAdapter(t *testing.T) {
+	shared := []NetworkAdapter{{Mode: "shared"}}
+	c := &NetworkAdaptersConfig{NetworkAdapters: shared}
+	if errs := c.Prepare("ssh", false); len(errs) != 1 {
+		t.Fatalf("should need an emulated adapter: %#v", errs)
+	}
+
+	// Without port forwarding any adapter will do
+	c = &NetworkAdaptersConfig{NetworkAdapters: shared}
+	if errs := c.Prepare("ssh", true); len(errs) > 0 {
+		t.Fatalf("should not have errors: %#v", errs)
+	}
+	c = &NetworkAdaptersConfig{NetworkAdapters: shared}
+	if errs := c.Prepare("none", false); len(errs) > 0 {
+		t.Fatalf("should not have errors: %#v", errs)
+	}
+
+	index := 0
+	c = &NetworkAdaptersConfig{
+		NetworkAdapters:     []NetworkAdapter{{Mode: "shared"}, {Mode: "emulated"}},
+		CommunicatorAdapter: &index,
+	}
+	if errs := c.Prepare("ssh", false); len(errs) != 1 {
+		t.Fatalf("should need the communicator adapter to be emulated: %#v", errs)
+	}
+
+	index = 2
+	c = &NetworkAdaptersConfig{
+		NetworkAdapters:     []NetworkAdapter{{Mode: "emulated"}},
+		CommunicatorAdapter: &index,
+	}
+	if errs := c.Prepare("ssh", false); len(errs) != 1 {
+		t.Fatalf("should reject an index out of range: %#v", errs)
+	}
+
+	c = &NetworkAdaptersConfig{CommunicatorAdapter: &index}
+	if errs := c.Prepare("ssh", false); len(errs) != 1 {
+		t.Fatalf("should need network_adapters: %#v", errs)
+	}
+}
@@ -0,0 +1,45 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:generate packer-sdc struct-markdown
+
+package common
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// DefaultWaitForNetworkTimeout is how long the builders wait for the guest
+// network when wait_for_network is set.
+const DefaultWaitForNetworkTimeout = 5 * time.Minute
+
+type NetworkWaitConfig struct {
+	// Set this to true to wait, once the communicator is connected, until
+	// the guest has a default route or can resolve a host name before
+	// running the provisioners. Some guests answer SSH before their network
+	// is fully up, which fails early provisioners that download packages.
+	// Defaults to false.
+	WaitForNetwork bool `mapstructure:"wait_for_network" required:"false"`
+	// How long to wait for the guest network with `wait_for_network` before
+	// failing the build. Defaults to 5m.
+	WaitForNetworkTimeout time.Duration `mapstructure:"wait_for_network_timeout" required:"false"`
+}
+
+func (c *NetworkWaitConfig) Prepare(commType string) []error {
+	var errs []error
+
+	if c.WaitForNetworkTimeout == 0 {
+		c.WaitForNetworkTimeout = DefaultWaitForNetworkTimeout
+	}
+	if c.WaitForNetworkTimeout < 0 {
+		errs = append(errs, fmt.Errorf(
+			"wait_for_network_timeout must not be negative, got %s", c.WaitForNetworkTimeout))
+	}
+	if c.WaitForNetwork && commType == "none" {
+		errs = append(errs, errors.New("wait_for_network needs a communicator"))
+	}
+
+	return errs
+}
@@ -0,0 +1,29 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNetworkWaitConfigPrepare(t *testing.T) {
+	c := new(NetworkWaitConfig)
+	if errs := c.Prepare("ssh"); len(errs) > 0 {
+		t.Fatalf("should not have errors: %#v", errs)
+	}
+	if c.WaitForNetworkTimeout != DefaultWaitForNetworkTimeout {
+		t.Fatalf("bad default timeout: %s", c.WaitForNetworkTimeout)
+	}
+
+	c = &NetworkWaitConfig{WaitForNetworkTimeout: -time.Second}
+	if errs := c.Prepare("ssh"); len(errs) != 1 {
+		t.Fatalf("should reject a negative timeout: %#v", errs)
+	}
+
+	c = &NetworkWaitConfig{WaitForNetwork: true}
+	if errs := c.Prepare("none"); len(errs) != 1 {
+		t.Fatalf("should need a communicator: %#v", errs)
+	}
+}
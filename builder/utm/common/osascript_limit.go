@@ -0,0 +1,99 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// MaxOsascriptEnv is the environment variable setting how many osascript
+// processes the builds of the host run at once.
+const MaxOsascriptEnv = "PACKER_UTM_MAX_OSASCRIPT"
+
+// DefaultMaxOsascript is how many osascript processes run at once when
+// PACKER_UTM_MAX_OSASCRIPT is not set. Dozens of concurrent Apple events
+// make UTM time out rather than queue them.
+const DefaultMaxOsascript = 4
+
+// osascriptSlotsDir is where the slot files of the osascript limit live.
+// Packer runs every build in its own plugin process, so the slots are
+// files locked with flock rather than anything in memory.
+var osascriptSlotsDir = filepath.Join(os.TempDir(), "packer-utm-osascript")
+
+// osascriptLimiter bounds how many osascript processes run at once across
+// processes, by holding one of its slot files locked while one runs.
+type osascriptLimiter struct {
+	dir   string
+	slots int
+	// poll is how long to wait before trying the slots again when they
+	// are all taken.
+	poll time.Duration
+}
+
+func newOsascriptLimiter(dir string, n int) *osascriptLimiter {
+	return &osascriptLimiter{dir: dir, slots: n, poll: 50 * time.Millisecond}
+}
+
+// acquire blocks until a slot is free, and returns the function releasing
+// it.
+func (l *osascriptLimiter) acquire() (func(), error) {
+	if err := os.MkdirAll(l.dir, 0755); err != nil {
+		return nil, err
+	}
+	for {
+		for i := 0; i < l.slots; i++ {
+			unlock, ok, err := tryLockFile(filepath.Join(l.dir, fmt.Sprintf("slot-%d.lock", i)))
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				return unlock, nil
+			}
+		}
+		time.Sleep(l.poll)
+	}
+}
+
+var (
+	osascriptSlots     *osascriptLimiter
+	osascriptSlotsOnce sync.Once
+)
+
+// maxOsascript reads the limit from value, the content of
+// PACKER_UTM_MAX_OSASCRIPT, falling back to DefaultMaxOsascript when it is
+// empty or not a positive number.
+func maxOsascript(value string) int {
+	if value == "" {
+		return DefaultMaxOsascript
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		log.Printf("Ignoring %s=%q, which is not a positive number", MaxOsascriptEnv, value)
+		return DefaultMaxOsascript
+	}
+	return n
+}
+
+// runOsascript runs an osascript command once a slot is free. The slots are
+// shared by every build running on the host. When they can't be used, the
+// command runs without one rather than failing the build.
+func runOsascript(cmd *exec.Cmd) error {
+	osascriptSlotsOnce.Do(func() {
+		osascriptSlots = newOsascriptLimiter(osascriptSlotsDir, maxOsascript(os.Getenv(MaxOsascriptEnv)))
+	})
+	release, err := osascriptSlots.acquire()
+	if err != nil {
+		log.Printf("[WARN] Running osascript without limiting it: %s", err)
+		return cmd.Run()
+	}
+	defer release()
+	return cmd.Run()
+}
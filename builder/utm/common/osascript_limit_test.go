@@ -0,0 +1,69 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMaxOsascript(t *testing.T) {
+	cases := map[string]int{
+		"":    DefaultMaxOsascript,
+		"1":   1,
+		"16":  16,
+		"0":   DefaultMaxOsascript,
+		"-2":  DefaultMaxOsascript,
+		"two": DefaultMaxOsascript,
+	}
+	for value, expected := range cases {
+		if n := maxOsascript(value); n != expected {
+			t.Fatalf("%q: expected %d, got %d", value, expected, n)
+		}
+	}
+}
+
+func TestOsascriptLimiter(t *testing.T) {
+	const limit = 3
+	// Each limiter stands in for the plugin process of another build,
+	// sharing the slot files.
+	dir := t.TempDir()
+
+	var running, peak int32
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			l := newOsascriptLimiter(dir, limit)
+			l.poll = time.Millisecond
+			release, err := l.acquire()
+			if err != nil {
+				t.Errorf("err: %s", err)
+				return
+			}
+			defer release()
+
+			n := atomic.AddInt32(&running, 1)
+			for {
+				p := atomic.LoadInt32(&peak)
+				if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+			atomic.AddInt32(&running, -1)
+		}()
+	}
+	wg.Wait()
+
+	if peak > limit {
+		t.Fatalf("expected at most %d at once, got %d", limit, peak)
+	}
+	if peak < limit {
+		t.Fatalf("expected the limit to be used, got %d at once", peak)
+	}
+}
@@ -0,0 +1,11 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:build !darwin && !linux
+
+package common
+
+// tryLockFile always succeeds on this platform; UTM only runs on macOS.
+func tryLockFile(path string) (func(), bool, error) {
+	return func() {}, true, nil
+}
@@ -5,6 +5,7 @@ package common
 
 import (
 	"fmt"
+	"os"
 
 	"github.com/hashicorp/packer-plugin-sdk/common"
 	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
@@ -15,8 +16,10 @@ type OutputConfig struct {
 	// resulting virtual machine will be created. This may be relative or absolute.
 	// If relative, the path is relative to the working directory when packer
 	// is executed. This directory must not exist or be empty prior to running
-	// the builder. By default this is output-BUILDNAME where "BUILDNAME" is the
-	// name of the build.
+	// the builder, unless `-force` is passed to `packer build`. By default this
+	// is output-BUILDNAME where "BUILDNAME" is the name of the build. Every file
+	// the build leaves in this directory, including the exported `.utm` bundle,
+	// is part of the artifact handed to post-processors.
 	OutputDir string `mapstructure:"output_directory" required:"false"`
 	// This is the base name of the file (excluding the file extension) where
 	// the resulting virtual machine will be created. By default this is the
@@ -29,5 +32,37 @@ func (c *OutputConfig) Prepare(ctx *interpolate.Context, pc *common.PackerConfig
 		c.OutputDir = fmt.Sprintf("output-%s", pc.PackerBuildName)
 	}
 
+	var errs []error
+	if !pc.PackerForce {
+		if err := checkOutputDirUnused(c.OutputDir); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errs
+}
+
+// checkOutputDirUnused fails if dir is a file, or a directory that already
+// holds files that would be mixed into the artifact.
+func checkOutputDirUnused(dir string) error {
+	info, err := os.Stat(dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error checking output_directory: %s", err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("output_directory %s exists and is not a directory", dir)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("error checking output_directory: %s", err)
+	}
+	if len(entries) > 0 {
+		return fmt.Errorf(
+			"output_directory %s already exists and is not empty, remove it or use -force", dir)
+	}
 	return nil
 }
@@ -5,6 +5,7 @@ package common
 
 import (
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/hashicorp/packer-plugin-sdk/common"
@@ -47,3 +48,39 @@ func TestOutputConfigPrepare_exists(t *testing.T) {
 		t.Fatal("should not have errors")
 	}
 }
+
+func TestOutputConfigPrepare_notEmpty(t *testing.T) {
+	td := t.TempDir()
+	if err := os.WriteFile(filepath.Join(td, "old.utm"), nil, 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	c := new(OutputConfig)
+	c.OutputDir = td
+
+	pc := &common.PackerConfig{PackerBuildName: "foo"}
+	if errs := c.Prepare(interpolate.NewContext(), pc); len(errs) != 1 {
+		t.Fatalf("should have one error: %#v", errs)
+	}
+
+	pc.PackerForce = true
+	if errs := c.Prepare(interpolate.NewContext(), pc); len(errs) != 0 {
+		t.Fatalf("should not have errors with -force: %#v", errs)
+	}
+}
+
+func TestOutputConfigPrepare_file(t *testing.T) {
+	td := t.TempDir()
+	path := filepath.Join(td, "output")
+	if err := os.WriteFile(path, nil, 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	c := new(OutputConfig)
+	c.OutputDir = path
+
+	pc := &common.PackerConfig{PackerBuildName: "foo"}
+	if errs := c.Prepare(interpolate.NewContext(), pc); len(errs) != 1 {
+		t.Fatalf("should have one error: %#v", errs)
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// qcow2Magic is the "QFI\xfb" signature at the start of every qcow2 image.
+var qcow2Magic = []byte{'Q', 'F', 'I', 0xfb}
+
+// Qcow2Header holds the parts of a qcow2 image header the builders care about.
+type Qcow2Header struct {
+	Version uint32
+	// BackingFile is the backing file recorded in the image, empty when the
+	// image is standalone.
+	BackingFile string
+}
+
+// ReadQcow2Header reads the header of the qcow2 image at path, failing if
+// the file is not a qcow2 image.
+func ReadQcow2Header(path string) (*Qcow2Header, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	// magic, version, backing_file_offset, backing_file_size
+	var raw struct {
+		Magic             [4]byte
+		Version           uint32
+		BackingFileOffset uint64
+		BackingFileSize   uint32
+	}
+	if err := binary.Read(f, binary.BigEndian, &raw); err != nil {
+		return nil, fmt.Errorf("%s is not a qcow2 image: %s", path, err)
+	}
+	if !bytes.Equal(raw.Magic[:], qcow2Magic) {
+		return nil, fmt.Errorf("%s is not a qcow2 image", path)
+	}
+
+	header := &Qcow2Header{Version: raw.Version}
+	if raw.BackingFileOffset != 0 && raw.BackingFileSize != 0 {
+		backingFile := make([]byte, raw.BackingFileSize)
+		if _, err := f.ReadAt(backingFile, int64(raw.BackingFileOffset)); err != nil && err != io.EOF {
+			return nil, fmt.Errorf("error reading backing file of %s: %s", path, err)
+		}
+		header.BackingFile = string(backingFile)
+	}
+
+	return header, nil
+}
+
+// ResolveBaseDisk returns the qcow2 disk to use as a base layer. path is
+// either a qcow2 image or a .utm bundle holding exactly one qcow2 disk.
+func ResolveBaseDisk(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+
+	diskPath := path
+	if info.IsDir() {
+		disks, err := filepath.Glob(filepath.Join(path, "Data", "*.qcow2"))
+		if err != nil {
+			return "", err
+		}
+		switch len(disks) {
+		case 0:
+			return "", fmt.Errorf("%s has no qcow2 disk", path)
+		case 1:
+			diskPath = disks[0]
+		default:
+			return "", fmt.Errorf(
+				"%s has %d qcow2 disks, point to the disk to use instead", path, len(disks))
+		}
+	}
+
+	if _, err := ReadQcow2Header(diskPath); err != nil {
+		return "", err
+	}
+
+	return filepath.Abs(diskPath)
+}
@@ -0,0 +1,105 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeTestQcow2 writes a minimal qcow2 header, with backingFile stored
+// right after it when set.
+func writeTestQcow2(t *testing.T, path string, backingFile string) {
+	t.Helper()
+
+	header := make([]byte, 72)
+	copy(header, qcow2Magic)
+	binary.BigEndian.PutUint32(header[4:], 3)
+	if backingFile != "" {
+		binary.BigEndian.PutUint64(header[8:], uint64(len(header)))
+		binary.BigEndian.PutUint32(header[16:], uint32(len(backingFile)))
+		header = append(header, backingFile...)
+	}
+	if err := os.WriteFile(path, header, 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+func TestReadQcow2Header(t *testing.T) {
+	dir := t.TempDir()
+
+	base := filepath.Join(dir, "base.qcow2")
+	writeTestQcow2(t, base, "")
+	header, err := ReadQcow2Header(base)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if header.Version != 3 || header.BackingFile != "" {
+		t.Fatalf("bad: %#v", header)
+	}
+
+	overlay := filepath.Join(dir, "overlay.qcow2")
+	writeTestQcow2(t, overlay, base)
+	header, err = ReadQcow2Header(overlay)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if header.BackingFile != base {
+		t.Fatalf("bad backing file: %s", header.BackingFile)
+	}
+}
+
+func TestReadQcow2Header_notQcow2(t *testing.T) {
+	raw := filepath.Join(t.TempDir(), "disk.img")
+	if err := os.WriteFile(raw, make([]byte, 512), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, err := ReadQcow2Header(raw); err == nil {
+		t.Fatal("should error for a raw image")
+	}
+}
+
+func TestResolveBaseDisk(t *testing.T) {
+	dir := t.TempDir()
+
+	disk := filepath.Join(dir, "base.qcow2")
+	writeTestQcow2(t, disk, "")
+	resolved, err := ResolveBaseDisk(disk)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if resolved != disk {
+		t.Fatalf("bad: %s", resolved)
+	}
+
+	// A bundle resolves to its single disk
+	bundle := filepath.Join(dir, "base.utm")
+	if err := os.MkdirAll(filepath.Join(bundle, "Data"), 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, err := ResolveBaseDisk(bundle); err == nil {
+		t.Fatal("should error for a bundle without disks")
+	}
+	bundleDisk := filepath.Join(bundle, "Data", "disk.qcow2")
+	writeTestQcow2(t, bundleDisk, "")
+	resolved, err = ResolveBaseDisk(bundle)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if resolved != bundleDisk {
+		t.Fatalf("bad: %s", resolved)
+	}
+
+	// More than one disk is ambiguous
+	writeTestQcow2(t, filepath.Join(bundle, "Data", "other.qcow2"), "")
+	if _, err := ResolveBaseDisk(bundle); err == nil {
+		t.Fatal("should error for a bundle with several disks")
+	}
+
+	if _, err := ResolveBaseDisk(filepath.Join(dir, "missing.qcow2")); err == nil {
+		t.Fatal("should error for a missing base")
+	}
+}
@@ -6,9 +6,12 @@
 package common
 
 import (
+	"errors"
 	"fmt"
+	"log"
 	"strings"
 
+	"github.com/hashicorp/packer-plugin-sdk/template/config"
 	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
 )
 
@@ -16,7 +19,11 @@ type QemuConfig struct {
 	// Arbitrary QEMU arguments that are passed to the UTM virtual machine
 	// as QEMU additional arguments. Each element is a list of strings
 	// that are joined with a space to form a single QEMU argument.
-	// These arguments persist in the exported VM.
+	// These arguments persist in the exported VM. Flags deprecated in recent
+	// QEMU releases, such as `-no-hpet`, are passed through with a warning
+	// suggesting their replacement. The Apple Virtualization backend takes
+	// no QEMU arguments, so qemuargs are an error with `vm_backend = "apple"`
+	// rather than being silently ignored.
 	//
 	// Usage example:
 	//
@@ -36,11 +43,176 @@ type QemuConfig struct {
 	// ]
 	// ```
 	QemuArgs [][]string `mapstructure:"qemuargs" required:"false"`
+	// Set this to true to let `qemuargs` use flags the plugin manages itself,
+	// such as `-vnc` or `-netdev`. By default such arguments are an error,
+	// since they collide with the VNC, networking and cloud-init setup of the
+	// builder. When set, they are passed through with a warning.
+	AllowOverride bool `mapstructure:"allow_override" required:"false"`
+	// The QEMU accelerator to run the VM with: `hvf`, `tcg` or `auto`. `auto`
+	// uses hvf when the host can run the guest architecture with it, and
+	// falls back to tcg with a warning otherwise, so that a template works on
+	// both Intel and Apple silicon Macs. The build fails early when `hvf` is
+	// requested but not available. By default UTM picks the accelerator.
+	// Setting this conflicts with an `-accel` argument in `qemuargs`.
+	Accelerator string `mapstructure:"accelerator" required:"false"`
+	// Set this to true to make sure the VM never runs with hvf, such as for
+	// builds that must be reproducible across hosts. `accelerator` then
+	// defaults to `tcg`, and `accelerator = "hvf"` or `"auto"`, an `-accel`
+	// argument in `qemuargs` and `hypervisor = true` are errors. tcg emulates
+	// the guest CPU, so expect the build to be several times slower. Off by
+	// default.
+	DisableDefaultAccelerator bool `mapstructure:"disable_default_accelerator" required:"false"`
+	// The guest CPU model, such as `host`, `max` or a named model like
+	// `cortex-a72` or `Skylake-Client`. `host` passes the host CPU through
+	// and only works with the hvf accelerator. Models the plugin doesn't
+	// know are passed to QEMU with a warning. By default UTM picks the model.
+	CPUModel string `mapstructure:"cpu_model" required:"false"`
+	// CPU features to turn on or off on top of the model, as `+feature`,
+	// `-feature` or `feature=value`. This needs `cpu_model`, or a `-cpu`
+	// argument in `qemuargs` whose features are merged with these. Setting a
+	// feature both ways is an error.
+	//
+	// In HCL2:
+	// ```hcl
+	// cpu_model    = "max"
+	// cpu_features = ["+aes", "-sve"]
+	// ```
+	CPUFeatures []string `mapstructure:"cpu_features" required:"false"`
+	// Set this to true to give the VM a QEMU monitor (QMP) on a local port
+	// during the build, so that monitor commands such as `sendkey`,
+	// `screendump` or `system_powerdown` can be sent to it. The monitor is
+	// removed before the VM is exported. Off by default.
+	QemuMonitor bool `mapstructure:"qemu_monitor" required:"false"`
+	// Set this to true to copy the QEMU log of the VM to
+	// `<output_directory>-qemu.log`, next to the output directory, once the
+	// VM is stopped. It is kept when a failed build removes the output
+	// directory. UTM only writes the log when the QEMU debug log is enabled
+	// for the VM. Whether or not this is set, the end of the log is shown when
+	// the VM fails to start or stops during a failed build. Off by default.
+	QemuLog bool `mapstructure:"qemu_log" required:"false"`
+	// Set this to false to not give the VM a virtio-rng device. The device
+	// feeds the guest entropy from the host, so that Linux guests and
+	// installers don't stall at boot waiting for random data. It is added
+	// after the `qemuargs` and stays in the exported VM. The device is skipped
+	// with a warning when the machine of `vm_arch` can't take it, and no
+	// device is added when `qemuargs` already add a virtio-rng device.
+	// Defaults to true.
+	RNGDevice config.Trilean `mapstructure:"rng_device" required:"false"`
+	// Set this to true to give the VM a virtio-balloon device with free page
+	// reporting, so that the guest returns the memory it frees to the host
+	// and idle build VMs don't hold on to all of their RAM. `memory` stays
+	// the most the VM can use; the balloon only shrinks what it actually
+	// takes from the host. The guest needs a virtio-balloon driver, which
+	// Linux has since 5.7. The device is skipped with a warning when the
+	// machine of `vm_arch` can't take it, and no device is added when
+	// `qemuargs` already add one. Off by default.
+	Balloon bool `mapstructure:"balloon" required:"false"`
+	// The base of the real-time clock of the VM: `utc` or `localtime`.
+	// Windows keeps the hardware clock in local time while other systems
+	// keep it in UTC, and a mismatch skews the clock of the exported image.
+	// The clock is set with a `-rtc` argument that stays in the exported VM.
+	// By default Windows guests, built with the winrm communicator or
+	// `windows_unattended`, get `localtime` and other guests keep the QEMU
+	// default of `utc`. Setting this conflicts with a `-rtc` argument in
+	// `qemuargs`.
+	RTCBase string `mapstructure:"rtc_base" required:"false"`
+
+	addRNGDevice     bool
+	addBalloonDevice bool
+	hasRTCArg        bool
 }
 
-func (c *QemuConfig) Prepare(ctx *interpolate.Context) []error {
+// QEMU flags the plugin, or UTM on its behalf, sets on the VM.
+const (
+	QemuFlagVNC    = "-vnc"
+	QemuFlagNetdev = "-netdev"
+	QemuFlagNIC    = "-nic"
+	QemuFlagSMBIOS = "-smbios"
+)
+
+// ManagedQemuFlags maps the QEMU flags the plugin manages to what manages
+// them. User qemuargs using them need allow_override.
+var ManagedQemuFlags = map[string]string{
+	QemuFlagVNC:    "the VNC boot command setup",
+	QemuFlagNetdev: "the UTM network configuration and port forwarding",
+	QemuFlagNIC:    "the UTM network configuration and port forwarding",
+	QemuFlagSMBIOS: "the cloud-init seed setup",
+}
+
+// DeprecatedQemuFlags maps QEMU flags that are deprecated or removed in
+// recent QEMU releases to what to use instead. User qemuargs using them get a
+// warning rather than an error, since the QEMU bundled with UTM may still
+// take them.
+var DeprecatedQemuFlags = map[string]string{
+	"-no-acpi":   "-machine acpi=off",
+	"-no-hpet":   "-machine hpet=off",
+	"-soundhw":   "-audio or -device",
+	"-usbdevice": "-device",
+}
+
+// AddRNGDevice reports whether the VM needs the RNGQemuArg device added, as
+// rng_device is set and qemuargs don't add one. It is set by Prepare.
+func (c *QemuConfig) AddRNGDevice() bool {
+	return c.addRNGDevice
+}
+
+// AddBalloonDevice reports whether the VM needs a virtio-balloon device
+// added, as balloon is set and qemuargs don't add one. It is set by Prepare.
+func (c *QemuConfig) AddBalloonDevice() bool {
+	return c.addBalloonDevice
+}
+
+// managedQemuFlag returns the managed flag arg starts with, if any. QEMU
+// accepts flags with one or two dashes, and with or without a value.
+func managedQemuFlag(arg string) (string, bool) {
+	flag := qemuFlag(arg)
+	_, ok := ManagedQemuFlags[flag]
+	return flag, ok
+}
+
+// qemuFlag returns the flag arg starts with, normalized to a single dash.
+func qemuFlag(arg string) string {
+	fields := strings.Fields(arg)
+	if len(fields) == 0 {
+		return ""
+	}
+	return "-" + strings.TrimLeft(strings.SplitN(fields[0], "=", 2)[0], "-")
+}
+
+func (c *QemuConfig) Prepare(ctx *interpolate.Context) ([]string, []error) {
+	var warnings []string
 	var errs []error
 
+	switch c.Accelerator {
+	case "", AcceleratorHVF, AcceleratorTCG, AcceleratorAuto:
+	default:
+		errs = append(errs, fmt.Errorf(
+			"accelerator must be one of hvf, tcg or auto, got %q", c.Accelerator))
+	}
+
+	if c.DisableDefaultAccelerator {
+		switch c.Accelerator {
+		case "":
+			c.Accelerator = AcceleratorTCG
+		case AcceleratorHVF, AcceleratorAuto:
+			errs = append(errs, fmt.Errorf(
+				"accelerator %s conflicts with disable_default_accelerator, which only allows tcg", c.Accelerator))
+		}
+		warnings = append(warnings,
+			"disable_default_accelerator is set, the VM runs with the tcg accelerator, "+
+				"which emulates the guest CPU and is much slower than hvf")
+	}
+
+	switch c.RTCBase {
+	case "", RTCBaseUTC, RTCBaseLocaltime:
+	default:
+		errs = append(errs, fmt.Errorf(
+			"rtc_base must be one of utc or localtime, got %q", c.RTCBase))
+	}
+
+	cpuArg := -1
+	rngArg := -1
+	balloonArg := -1
 	for i, args := range c.QemuArgs {
 		if len(args) == 0 {
 			errs = append(errs, fmt.Errorf("qemuargs[%d]: empty argument list", i))
@@ -49,8 +221,166 @@ func (c *QemuConfig) Prepare(ctx *interpolate.Context) []error {
 		joined := strings.Join(args, " ")
 		if strings.TrimSpace(joined) == "" {
 			errs = append(errs, fmt.Errorf("qemuargs[%d]: argument resolves to empty string", i))
+			continue
+		}
+
+		if c.Accelerator != "" && qemuFlag(joined) == QemuFlagAccel {
+			errs = append(errs, fmt.Errorf(
+				"qemuargs[%d]: %s conflicts with accelerator, set only one of them", i, QemuFlagAccel))
+		}
+
+		if qemuFlag(joined) == QemuFlagRTC {
+			c.hasRTCArg = true
+			if c.RTCBase != "" {
+				errs = append(errs, fmt.Errorf(
+					"qemuargs[%d]: %s conflicts with rtc_base, set only one of them", i, QemuFlagRTC))
+			}
+		}
+
+		if qemuFlag(joined) == QemuFlagCPU {
+			cpuArg = i
+		}
+
+		if isRNGQemuArg(joined) {
+			rngArg = i
+		}
+
+		if isBalloonQemuArg(joined) {
+			balloonArg = i
+		}
+
+		if replacement, ok := DeprecatedQemuFlags[qemuFlag(joined)]; ok {
+			warnings = append(warnings, fmt.Sprintf(
+				"qemuargs[%d]: %s is deprecated in QEMU, use %s instead", i, qemuFlag(joined), replacement))
+		}
+
+		if flag, ok := managedQemuFlag(joined); ok {
+			msg := fmt.Sprintf("qemuargs[%d]: %s is managed by %s", i, flag, ManagedQemuFlags[flag])
+			if c.AllowOverride {
+				warnings = append(warnings, msg+", overriding it because allow_override is set")
+			} else {
+				errs = append(errs, fmt.Errorf("%s, set allow_override to use it anyway", msg))
+			}
+		}
+	}
+
+	if c.RNGDevice == config.TriUnset {
+		c.RNGDevice = config.TriTrue
+	}
+	c.addRNGDevice = c.RNGDevice.True() && rngArg < 0
+	if c.RNGDevice.True() && rngArg >= 0 {
+		log.Printf("qemuargs[%d] already adds a virtio-rng device, not adding another", rngArg)
+	}
+	c.addBalloonDevice = c.Balloon && balloonArg < 0
+	if c.Balloon && balloonArg >= 0 {
+		log.Printf("qemuargs[%d] already adds a virtio-balloon device, not adding another", balloonArg)
+	}
+
+	cpuWarnings, cpuErrs := c.prepareCPU(cpuArg)
+	warnings = append(warnings, cpuWarnings...)
+	errs = append(errs, cpuErrs...)
+
+	return warnings, errs
+}
+
+// PrepareAppleBackend rejects the options only a QEMU VM has, for a VM of
+// the apple backend, which takes no QEMU arguments, and leaves rng_device
+// off unless it is set. It must run before Prepare.
+func (c *QemuConfig) PrepareAppleBackend() []error {
+	var errs []error
+
+	// Name the arguments, so a -cpu host that would be a silent no-op is
+	// easy to spot in the error.
+	if len(c.QemuArgs) > 0 {
+		args := make([]string, len(c.QemuArgs))
+		for i, arg := range c.QemuArgs {
+			args[i] = strings.Join(arg, " ")
+		}
+		errs = append(errs, fmt.Errorf(
+			"qemuargs only applies to QEMU VMs, UTM would ignore %q with vm_backend = %q",
+			args, VMBackendApple))
+	}
+
+	for _, option := range []struct {
+		name string
+		set  bool
+	}{
+		{"accelerator", c.Accelerator != ""},
+		{"disable_default_accelerator", c.DisableDefaultAccelerator},
+		{"cpu_model", c.CPUModel != ""},
+		{"cpu_features", len(c.CPUFeatures) > 0},
+		{"qemu_monitor", c.QemuMonitor},
+		{"qemu_log", c.QemuLog},
+		{"rng_device", c.RNGDevice.True()},
+		{"balloon", c.Balloon},
+		{"rtc_base", c.RTCBase != ""},
+	} {
+		if option.set {
+			errs = append(errs, fmt.Errorf(
+				"%s only applies to QEMU VMs, it can't be used with vm_backend = %q", option.name, VMBackendApple))
 		}
 	}
 
+	if c.RNGDevice == config.TriUnset {
+		c.RNGDevice = config.TriFalse
+	}
 	return errs
 }
+
+// prepareCPU validates cpu_model and cpu_features. When they are set and
+// qemuargs[cpuArg] is a -cpu argument too, it is merged into them and
+// removed, so the VM ends up with a single -cpu.
+func (c *QemuConfig) prepareCPU(cpuArg int) ([]string, []error) {
+	var warnings []string
+	var errs []error
+
+	for _, feature := range c.CPUFeatures {
+		if !cpuFeatureRe.MatchString(feature) {
+			errs = append(errs, fmt.Errorf(
+				"cpu_features: %q must be +feature, -feature or feature=value", feature))
+		}
+	}
+	if len(errs) > 0 || (c.CPUModel == "" && len(c.CPUFeatures) == 0) {
+		return warnings, errs
+	}
+
+	if cpuArg >= 0 {
+		value := strings.TrimSpace(strings.TrimPrefix(
+			strings.TrimLeft(strings.Join(c.QemuArgs[cpuArg], " "), "-"), "cpu"))
+		model, features := parseCPUArg(value)
+		if c.CPUModel != "" && c.CPUModel != model {
+			errs = append(errs, fmt.Errorf(
+				"qemuargs[%d]: -cpu %s conflicts with cpu_model %s", cpuArg, model, c.CPUModel))
+			return warnings, errs
+		}
+		merged, err := mergeCPUFeatures(features, c.CPUFeatures)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("qemuargs[%d]: %s", cpuArg, err))
+			return warnings, errs
+		}
+		c.CPUModel = model
+		c.CPUFeatures = merged
+		c.QemuArgs = append(c.QemuArgs[:cpuArg:cpuArg], c.QemuArgs[cpuArg+1:]...)
+	} else {
+		merged, err := mergeCPUFeatures(nil, c.CPUFeatures)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("cpu_features: %s", err))
+			return warnings, errs
+		}
+		c.CPUFeatures = merged
+	}
+
+	switch {
+	case c.CPUModel == "":
+		errs = append(errs, errors.New(
+			"cpu_features needs cpu_model, or a -cpu argument in qemuargs"))
+	case c.CPUModel == CPUModelHost && c.Accelerator == AcceleratorTCG:
+		errs = append(errs, errors.New(
+			"cpu_model host needs the hvf accelerator, use cpu_model max with tcg"))
+	case !knownCPUModel(c.CPUModel):
+		warnings = append(warnings, fmt.Sprintf(
+			"cpu_model %q is not a known CPU model, passing it to QEMU anyway", c.CPUModel))
+	}
+
+	return warnings, errs
+}
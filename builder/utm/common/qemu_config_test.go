@@ -4,12 +4,16 @@
 package common
 
 import (
+	"reflect"
+	"strings"
 	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/template/config"
 )
 
 func TestQemuConfigPrepare_empty(t *testing.T) {
 	c := new(QemuConfig)
-	errs := c.Prepare(nil)
+	_, errs := c.Prepare(nil)
 	if len(errs) > 0 {
 		t.Fatalf("should not have errors: %#v", errs)
 	}
@@ -22,7 +26,7 @@ func TestQemuConfigPrepare_validArgs(t *testing.T) {
 			{"-cpu", "host"},
 		},
 	}
-	errs := c.Prepare(nil)
+	_, errs := c.Prepare(nil)
 	if len(errs) > 0 {
 		t.Fatalf("should not have errors: %#v", errs)
 	}
@@ -34,7 +38,7 @@ func TestQemuConfigPrepare_emptyInnerArray(t *testing.T) {
 			{},
 		},
 	}
-	errs := c.Prepare(nil)
+	_, errs := c.Prepare(nil)
 	if len(errs) != 1 {
 		t.Fatalf("expected 1 error, got: %#v", errs)
 	}
@@ -46,8 +50,377 @@ func TestQemuConfigPrepare_whitespaceOnly(t *testing.T) {
 			{" ", "  "},
 		},
 	}
-	errs := c.Prepare(nil)
+	_, errs := c.Prepare(nil)
 	if len(errs) != 1 {
 		t.Fatalf("expected 1 error, got: %#v", errs)
 	}
 }
+
+func TestQemuConfigPrepare_managedFlag(t *testing.T) {
+	for _, args := range [][]string{
+		{"-vnc", ":1"},
+		{"-netdev user,id=net0"},
+		{"--nic", "none"},
+		{"-smbios=type=1"},
+	} {
+		c := &QemuConfig{
+			QemuArgs: [][]string{
+				{"-cpu", "host"},
+				args,
+			},
+		}
+		warnings, errs := c.Prepare(nil)
+		if len(errs) != 1 {
+			t.Fatalf("%#v: expected 1 error, got: %#v", args, errs)
+		}
+		if !strings.Contains(errs[0].Error(), "qemuargs[1]") {
+			t.Fatalf("%#v: should point to the argument: %s", args, errs[0])
+		}
+		if len(warnings) != 0 {
+			t.Fatalf("%#v: should not have warnings: %#v", args, warnings)
+		}
+	}
+}
+
+func TestQemuConfigPrepare_allowOverride(t *testing.T) {
+	c := &QemuConfig{
+		QemuArgs: [][]string{
+			{"-vnc", ":1"},
+			{"-cpu", "host"},
+		},
+		AllowOverride: true,
+	}
+	warnings, errs := c.Prepare(nil)
+	if len(errs) > 0 {
+		t.Fatalf("should not have errors: %#v", errs)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "-vnc") {
+		t.Fatalf("should warn about the override: %#v", warnings)
+	}
+}
+
+func TestManagedQemuFlag(t *testing.T) {
+	cases := map[string]string{
+		"-vnc :1":           QemuFlagVNC,
+		"--netdev user":     QemuFlagNetdev,
+		"-smbios=type=1":    QemuFlagSMBIOS,
+		"-vncfoo":           "",
+		"-device virtio-9p": "",
+	}
+	for arg, expected := range cases {
+		flag, ok := managedQemuFlag(arg)
+		if ok != (expected != "") || (ok && flag != expected) {
+			t.Errorf("%q: expected %q, got %q (%t)", arg, expected, flag, ok)
+		}
+	}
+}
+
+func TestQemuConfigPrepare_accelerator(t *testing.T) {
+	for _, accel := range []string{"", AcceleratorHVF, AcceleratorTCG, AcceleratorAuto} {
+		c := &QemuConfig{Accelerator: accel}
+		if _, errs := c.Prepare(nil); len(errs) > 0 {
+			t.Fatalf("%q: should not have errors: %#v", accel, errs)
+		}
+	}
+
+	c := &QemuConfig{Accelerator: "kvm"}
+	if _, errs := c.Prepare(nil); len(errs) != 1 || !strings.Contains(errs[0].Error(), "kvm") {
+		t.Fatalf("should reject an unknown accelerator: %#v", errs)
+	}
+}
+
+func TestQemuConfigPrepare_acceleratorConflict(t *testing.T) {
+	c := &QemuConfig{
+		QemuArgs:    [][]string{{"-accel", "hvf"}},
+		Accelerator: AcceleratorAuto,
+	}
+	if _, errs := c.Prepare(nil); len(errs) != 1 || !strings.Contains(errs[0].Error(), "conflicts") {
+		t.Fatalf("should reject -accel with accelerator: %#v", errs)
+	}
+
+	c.Accelerator = ""
+	if _, errs := c.Prepare(nil); len(errs) > 0 {
+		t.Fatalf("should allow -accel without accelerator: %#v", errs)
+	}
+}
+
+func TestQemuConfigPrepare_disableDefaultAccelerator(t *testing.T) {
+	c := &QemuConfig{DisableDefaultAccelerator: true}
+	warnings, errs := c.Prepare(nil)
+	if len(errs) > 0 {
+		t.Fatalf("should not have errors: %#v", errs)
+	}
+	if c.Accelerator != AcceleratorTCG {
+		t.Fatalf("should default to tcg, got %q", c.Accelerator)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "slower") {
+		t.Fatalf("should warn about the performance: %#v", warnings)
+	}
+
+	for _, accel := range []string{AcceleratorHVF, AcceleratorAuto} {
+		c := &QemuConfig{DisableDefaultAccelerator: true, Accelerator: accel}
+		if _, errs := c.Prepare(nil); len(errs) != 1 || !strings.Contains(errs[0].Error(), "disable_default_accelerator") {
+			t.Fatalf("%s: should reject the accelerator: %#v", accel, errs)
+		}
+	}
+
+	c = &QemuConfig{DisableDefaultAccelerator: true, QemuArgs: [][]string{{"-accel", "hvf"}}}
+	if _, errs := c.Prepare(nil); len(errs) != 1 || !strings.Contains(errs[0].Error(), "conflicts") {
+		t.Fatalf("should reject -accel in qemuargs: %#v", errs)
+	}
+}
+
+func TestQemuConfigPrepare_rtcBase(t *testing.T) {
+	for _, base := range []string{"", RTCBaseUTC, RTCBaseLocaltime} {
+		c := &QemuConfig{RTCBase: base}
+		if _, errs := c.Prepare(nil); len(errs) > 0 {
+			t.Fatalf("%q: should not have errors: %#v", base, errs)
+		}
+	}
+
+	c := &QemuConfig{RTCBase: "local"}
+	if _, errs := c.Prepare(nil); len(errs) != 1 {
+		t.Fatalf("should reject an unknown rtc_base: %#v", errs)
+	}
+
+	c = &QemuConfig{
+		QemuArgs: [][]string{{"-rtc", "base=utc"}},
+		RTCBase:  RTCBaseLocaltime,
+	}
+	if _, errs := c.Prepare(nil); len(errs) != 1 || !strings.Contains(errs[0].Error(), "conflicts") {
+		t.Fatalf("should reject -rtc with rtc_base: %#v", errs)
+	}
+}
+
+func TestQemuConfig_RTCQemuArg(t *testing.T) {
+	cases := []struct {
+		base     string
+		qemuArgs [][]string
+		windows  bool
+		expected string
+	}{
+		{"", nil, false, ""},
+		{"", nil, true, "-rtc base=localtime"},
+		{"", [][]string{{"-rtc", "base=utc"}}, true, ""},
+		{RTCBaseUTC, nil, true, "-rtc base=utc"},
+		{RTCBaseLocaltime, nil, false, "-rtc base=localtime"},
+	}
+	for _, tc := range cases {
+		c := &QemuConfig{RTCBase: tc.base, QemuArgs: tc.qemuArgs}
+		if _, errs := c.Prepare(nil); len(errs) > 0 {
+			t.Fatalf("should not have errors: %#v", errs)
+		}
+		if arg := c.RTCQemuArg(tc.windows); arg != tc.expected {
+			t.Fatalf("%q/%t: expected %q, got %q", tc.base, tc.windows, tc.expected, arg)
+		}
+	}
+}
+
+func TestQemuConfigPrepare_cpu(t *testing.T) {
+	c := &QemuConfig{
+		CPUModel:    "max",
+		CPUFeatures: []string{"+aes", "sve=off", "+aes"},
+	}
+	warnings, errs := c.Prepare(nil)
+	if len(errs) > 0 || len(warnings) > 0 {
+		t.Fatalf("should be valid: %#v %#v", warnings, errs)
+	}
+	if !reflect.DeepEqual(c.CPUFeatures, []string{"+aes", "sve=off"}) {
+		t.Fatalf("should drop duplicate features: %#v", c.CPUFeatures)
+	}
+
+	c = &QemuConfig{CPUModel: "cortex-z1"}
+	if warnings, errs := c.Prepare(nil); len(errs) > 0 || len(warnings) != 1 {
+		t.Fatalf("should warn about an unknown model: %#v %#v", warnings, errs)
+	}
+}
+
+func TestQemuConfigPrepare_cpuInvalid(t *testing.T) {
+	cases := map[string]*QemuConfig{
+		"bad feature":     {CPUModel: "max", CPUFeatures: []string{"aes"}},
+		"no model":        {CPUFeatures: []string{"+aes"}},
+		"both ways":       {CPUModel: "max", CPUFeatures: []string{"+aes", "aes=off"}},
+		"host with tcg":   {CPUModel: "host", Accelerator: AcceleratorTCG},
+		"model conflicts": {CPUModel: "max", QemuArgs: [][]string{{"-cpu", "host"}}},
+		"feature conflicts": {
+			CPUFeatures: []string{"-aes"},
+			QemuArgs:    [][]string{{"-cpu", "host,+aes"}},
+		},
+	}
+	for name, c := range cases {
+		if _, errs := c.Prepare(nil); len(errs) != 1 {
+			t.Errorf("%s: expected 1 error, got %#v", name, errs)
+		}
+	}
+}
+
+func TestQemuConfigPrepare_cpuMerge(t *testing.T) {
+	c := &QemuConfig{
+		CPUFeatures: []string{"+sve"},
+		QemuArgs: [][]string{
+			{"-smp", "4"},
+			{"-cpu", "host,+aes"},
+			{"-m", "2048"},
+		},
+	}
+	if _, errs := c.Prepare(nil); len(errs) > 0 {
+		t.Fatalf("should not have errors: %#v", errs)
+	}
+	if c.CPUModel != "host" || !reflect.DeepEqual(c.CPUFeatures, []string{"+aes", "+sve"}) {
+		t.Fatalf("should merge the -cpu argument: %q %#v", c.CPUModel, c.CPUFeatures)
+	}
+	expected := [][]string{{"-smp", "4"}, {"-m", "2048"}}
+	if !reflect.DeepEqual(c.QemuArgs, expected) {
+		t.Fatalf("should remove the -cpu argument: %#v", c.QemuArgs)
+	}
+}
+
+func TestQemuConfigPrepare_cpuArgAlone(t *testing.T) {
+	c := &QemuConfig{QemuArgs: [][]string{{"-cpu", "host"}}}
+	if _, errs := c.Prepare(nil); len(errs) > 0 {
+		t.Fatalf("should not have errors: %#v", errs)
+	}
+	if len(c.QemuArgs) != 1 || c.CPUModel != "" {
+		t.Fatalf("should leave a lone -cpu argument alone: %#v", c)
+	}
+}
+
+func TestQemuConfigPrepare_deprecatedFlag(t *testing.T) {
+	c := &QemuConfig{
+		QemuArgs: [][]string{
+			{"-no-hpet"},
+			{"--soundhw", "hda"},
+		},
+	}
+	warnings, errs := c.Prepare(nil)
+	if len(errs) > 0 {
+		t.Fatalf("should not have errors: %#v", errs)
+	}
+	if len(warnings) != 2 {
+		t.Fatalf("expected 2 warnings, got: %#v", warnings)
+	}
+	if !strings.Contains(warnings[0], "qemuargs[0]") || !strings.Contains(warnings[0], "-machine hpet=off") {
+		t.Fatalf("should name the argument and its replacement: %s", warnings[0])
+	}
+}
+
+func TestQemuConfigPrepare_warningsAndErrors(t *testing.T) {
+	c := &QemuConfig{
+		QemuArgs: [][]string{
+			{"-no-acpi"},
+			{},
+		},
+	}
+	warnings, errs := c.Prepare(nil)
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "qemuargs[0]") {
+		t.Fatalf("the deprecated flag should only warn: %#v", warnings)
+	}
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "qemuargs[1]") {
+		t.Fatalf("the empty argument list should be an error: %#v", errs)
+	}
+}
+
+func TestQemuConfigPrepare_rngDevice(t *testing.T) {
+	c := new(QemuConfig)
+	if _, errs := c.Prepare(nil); len(errs) > 0 {
+		t.Fatalf("should not have errors: %#v", errs)
+	}
+	if !c.RNGDevice.True() || !c.AddRNGDevice() {
+		t.Fatalf("should add the RNG device by default: %#v", c.RNGDevice)
+	}
+
+	c = &QemuConfig{RNGDevice: config.TriFalse}
+	if _, errs := c.Prepare(nil); len(errs) > 0 {
+		t.Fatalf("should not have errors: %#v", errs)
+	}
+	if c.AddRNGDevice() {
+		t.Fatal("should not add the RNG device when disabled")
+	}
+}
+
+func TestQemuConfigPrepare_rngDeviceInQemuArgs(t *testing.T) {
+	c := &QemuConfig{
+		QemuArgs: [][]string{
+			{"-object", "rng-random,id=rng0,filename=/dev/urandom"},
+			{"-device", "virtio-rng-pci,rng=rng0"},
+		},
+	}
+	warnings, errs := c.Prepare(nil)
+	if len(errs) > 0 || len(warnings) > 0 {
+		t.Fatalf("should compose with the user's device: %#v %#v", warnings, errs)
+	}
+	if c.AddRNGDevice() {
+		t.Fatal("should not add a second RNG device")
+	}
+	if len(c.QemuArgs) != 2 {
+		t.Fatalf("should keep the user's qemuargs: %#v", c.QemuArgs)
+	}
+}
+
+func TestIsRNGQemuArg(t *testing.T) {
+	cases := map[string]bool{
+		"-device virtio-rng-pci":         true,
+		"--device virtio-rng-device,x=1": true,
+		"-device=virtio-rng-pci":         true,
+		"-device virtio-balloon":         false,
+		"-object rng-random,id=rng0":     false,
+		"-device":                        false,
+	}
+	for arg, expected := range cases {
+		if actual := isRNGQemuArg(arg); actual != expected {
+			t.Errorf("%q: expected %t, got %t", arg, expected, actual)
+		}
+	}
+}
+
+func TestQemuConfigPrepare_balloon(t *testing.T) {
+	c := new(QemuConfig)
+	if _, errs := c.Prepare(nil); len(errs) > 0 || c.AddBalloonDevice() {
+		t.Fatalf("should not add a balloon by default: %#v", errs)
+	}
+
+	c = &QemuConfig{Balloon: true}
+	if _, errs := c.Prepare(nil); len(errs) > 0 || !c.AddBalloonDevice() {
+		t.Fatalf("should add a balloon: %#v", errs)
+	}
+
+	c = &QemuConfig{Balloon: true, QemuArgs: [][]string{{"-device", "virtio-balloon-pci,deflate-on-oom=on"}}}
+	if _, errs := c.Prepare(nil); len(errs) > 0 || c.AddBalloonDevice() {
+		t.Fatalf("should not add a second balloon: %#v", errs)
+	}
+}
+
+func TestQemuConfigPrepareAppleBackend(t *testing.T) {
+	c := new(QemuConfig)
+	if errs := c.PrepareAppleBackend(); len(errs) > 0 {
+		t.Fatalf("should not have errors: %#v", errs)
+	}
+	if _, errs := c.Prepare(nil); len(errs) > 0 || c.AddRNGDevice() {
+		t.Fatalf("should not add the RNG device to an apple VM: %#v", errs)
+	}
+
+	c = &QemuConfig{
+		QemuArgs:    [][]string{{"-smp", "4"}},
+		Accelerator: "hvf",
+		QemuLog:     true,
+	}
+	errs := c.PrepareAppleBackend()
+	if len(errs) != 3 {
+		t.Fatalf("should reject the QEMU options: %#v", errs)
+	}
+	if !strings.Contains(errs[0].Error(), "qemuargs only applies to QEMU VMs") {
+		t.Fatalf("bad error: %s", errs[0])
+	}
+}
+
+func TestQemuConfigPrepareAppleBackend_qemuArgs(t *testing.T) {
+	c := &QemuConfig{QemuArgs: [][]string{{"-cpu", "host"}, {"-smp", "4"}}}
+	errs := c.PrepareAppleBackend()
+	if len(errs) != 1 {
+		t.Fatalf("should reject qemuargs once: %#v", errs)
+	}
+	expected := `qemuargs only applies to QEMU VMs, UTM would ignore ["-cpu host" "-smp 4"] with vm_backend = "apple"`
+	if errs[0].Error() != expected {
+		t.Fatalf("bad error: %s", errs[0])
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import "strings"
+
+// balloonDevices maps the guest architectures whose default UTM machine
+// can take a virtio-balloon device to the device to use. Machines of other
+// architectures have neither a PCI nor a CCW bus to put it on.
+var balloonDevices = map[string]string{
+	"aarch64":     "virtio-balloon-pci",
+	"arm":         "virtio-balloon-pci",
+	"i386":        "virtio-balloon-pci",
+	"x86_64":      "virtio-balloon-pci",
+	"riscv32":     "virtio-balloon-pci",
+	"riscv64":     "virtio-balloon-pci",
+	"ppc64":       "virtio-balloon-pci",
+	"loongarch64": "virtio-balloon-pci",
+	"s390x":       "virtio-balloon-ccw",
+}
+
+// BalloonQemuArg returns the argument adding a virtio-balloon device to a
+// guestArch VM, and false when its machine can't take one. Free page
+// reporting is turned on, so the guest hands the pages it frees back to the
+// host without anything driving the balloon.
+func BalloonQemuArg(guestArch string) (string, bool) {
+	device, ok := balloonDevices[guestArch]
+	if !ok {
+		return "", false
+	}
+	return "-device " + device + ",free-page-reporting=on", true
+}
+
+// rngDevices maps the guest architectures whose default UTM machine can
+// take a virtio-rng device to the device to use, on the same buses as the
+// balloon.
+var rngDevices = map[string]string{
+	"aarch64":     "virtio-rng-pci",
+	"arm":         "virtio-rng-pci",
+	"i386":        "virtio-rng-pci",
+	"x86_64":      "virtio-rng-pci",
+	"riscv32":     "virtio-rng-pci",
+	"riscv64":     "virtio-rng-pci",
+	"ppc64":       "virtio-rng-pci",
+	"loongarch64": "virtio-rng-pci",
+	"s390x":       "virtio-rng-ccw",
+}
+
+// RNGQemuArg returns the argument adding a virtio-rng device fed from the
+// host's entropy to a guestArch VM, and false when its machine can't take
+// one.
+func RNGQemuArg(guestArch string) (string, bool) {
+	device, ok := rngDevices[guestArch]
+	if !ok {
+		return "", false
+	}
+	return "-device " + device, true
+}
+
+// qemuDevice returns the device arg, a joined qemuargs entry, adds, or an
+// empty string when arg is not a -device.
+func qemuDevice(arg string) string {
+	if qemuFlag(arg) != "-device" {
+		return ""
+	}
+	fields := strings.Fields(arg)
+	if len(fields) > 1 {
+		return fields[1]
+	}
+	if parts := strings.SplitN(fields[0], "=", 2); len(parts) == 2 {
+		return parts[1]
+	}
+	return ""
+}
+
+// isRNGQemuArg reports whether arg already gives the VM a virtio-rng device.
+func isRNGQemuArg(arg string) bool {
+	return strings.HasPrefix(qemuDevice(arg), "virtio-rng")
+}
+
+// isBalloonQemuArg reports whether arg already gives the VM a
+// virtio-balloon device.
+func isBalloonQemuArg(arg string) bool {
+	return strings.HasPrefix(qemuDevice(arg), "virtio-balloon")
+}
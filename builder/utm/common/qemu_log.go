@@ -0,0 +1,75 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// QemuLogName is the file UTM writes QEMU's output to, in the Data
+// directory of the VM bundle, when the QEMU debug log is enabled for the VM.
+const QemuLogName = "debug.log"
+
+// qemuLogTailLines is how many lines of the QEMU log are added to errors.
+const qemuLogTailLines = 20
+
+// qemuLogTailBytes bounds how much of the end of the QEMU log is read, as
+// the log of a long-running VM can grow large.
+const qemuLogTailBytes = 64 * 1024
+
+// QemuLogPath returns the path of the QEMU log of the VM whose bundle is at
+// bundlePath.
+func QemuLogPath(bundlePath string) string {
+	return filepath.Join(bundlePath, "Data", QemuLogName)
+}
+
+// ReadQemuLogTail returns the last lines lines of the QEMU log at path.
+func ReadQemuLogTail(path string, lines int) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = f.Close() }()
+
+	info, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+	offset := info.Size() - qemuLogTailBytes
+	if offset < 0 {
+		offset = 0
+	}
+	content, err := io.ReadAll(io.NewSectionReader(f, offset, info.Size()-offset))
+	if err != nil {
+		return "", err
+	}
+	if offset > 0 {
+		// Drop the line cut in half by the offset.
+		if i := bytes.IndexByte(content, '\n'); i >= 0 {
+			content = content[i+1:]
+		}
+	}
+
+	all := strings.Split(strings.TrimRight(string(content), "\r\n"), "\n")
+	if len(all) > lines {
+		all = all[len(all)-lines:]
+	}
+	return strings.Join(all, "\n"), nil
+}
+
+// withQemuLog adds the tail of the QEMU log at path to err, so that a VM
+// that failed to start reports why QEMU exited. err is returned as is when
+// there is no log to read.
+func withQemuLog(err error, path string) error {
+	tail, readErr := ReadQemuLogTail(path, qemuLogTailLines)
+	if readErr != nil || strings.TrimSpace(tail) == "" {
+		return err
+	}
+	return fmt.Errorf("%w\nQEMU log (%s):\n%s", err, path, tail)
+}
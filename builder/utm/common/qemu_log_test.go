@@ -0,0 +1,77 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeQemuLog(t *testing.T, bundlePath string, content string) string {
+	path := QemuLogPath(bundlePath)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	return path
+}
+
+func TestQemuLogPath(t *testing.T) {
+	expected := filepath.Join("docs", "foo.utm", "Data", "debug.log")
+	if path := QemuLogPath(filepath.Join("docs", "foo.utm")); path != expected {
+		t.Fatalf("bad path: %s", path)
+	}
+}
+
+func TestReadQemuLogTail(t *testing.T) {
+	var lines []string
+	for i := 0; i < 30; i++ {
+		lines = append(lines, fmt.Sprintf("line %d", i))
+	}
+	path := writeQemuLog(t, t.TempDir(), strings.Join(lines, "\n")+"\n")
+
+	tail, err := ReadQemuLogTail(path, 3)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if tail != "line 27\nline 28\nline 29" {
+		t.Fatalf("bad tail: %q", tail)
+	}
+}
+
+func TestReadQemuLogTail_large(t *testing.T) {
+	content := strings.Repeat("x", qemuLogTailBytes) + "\npartial line\nqemu-system-aarch64: -accel hvf: not supported\n"
+	path := writeQemuLog(t, t.TempDir(), content)
+
+	tail, err := ReadQemuLogTail(path, 5)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if tail != "partial line\nqemu-system-aarch64: -accel hvf: not supported" {
+		t.Fatalf("should only read the end of the log: %q", tail)
+	}
+}
+
+func TestWithQemuLog(t *testing.T) {
+	path := writeQemuLog(t, t.TempDir(), "qemu-system-aarch64: -accel hvf: not supported\n")
+	startErr := errors.New("error starting VM")
+
+	err := withQemuLog(startErr, path)
+	if !errors.Is(err, startErr) {
+		t.Fatalf("should wrap the error: %v", err)
+	}
+	if !strings.Contains(err.Error(), "-accel hvf: not supported") {
+		t.Fatalf("should include the QEMU log: %v", err)
+	}
+
+	if err := withQemuLog(startErr, filepath.Join(t.TempDir(), "missing.log")); err != startErr {
+		t.Fatalf("should keep the error without a log: %v", err)
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// QemuFlagQMP is the flag of the QEMU monitor added with qemu_monitor.
+const QemuFlagQMP = "-qmp"
+
+// StateQemuMonitorAddress is the state key of the host:port the QEMU
+// monitor of the VM listens on, when qemu_monitor is set.
+const StateQemuMonitorAddress = "qemu_monitor_address"
+
+// qemuMonitorTimeout bounds a whole monitor command, from connecting to
+// reading its result.
+var qemuMonitorTimeout = 30 * time.Second
+
+// qemuMonitors maps VM ids to the address of their QEMU monitor. Monitors
+// are registered by StepConfigureQemuMonitor, so Driver.MonitorCommand only
+// needs the VM id.
+var qemuMonitors sync.Map
+
+// RegisterQemuMonitor records the address of the QEMU monitor of a VM.
+func RegisterQemuMonitor(vmId string, addr string) {
+	qemuMonitors.Store(vmId, addr)
+}
+
+// UnregisterQemuMonitor forgets the QEMU monitor of a VM.
+func UnregisterQemuMonitor(vmId string) {
+	qemuMonitors.Delete(vmId)
+}
+
+func qemuMonitorAddress(vmId string) (string, error) {
+	addr, ok := qemuMonitors.Load(vmId)
+	if !ok {
+		return "", fmt.Errorf("VM %s has no QEMU monitor, set qemu_monitor to enable it", vmId)
+	}
+	return addr.(string), nil
+}
+
+// qmpMessage is any message a QMP server sends: the greeting, the result or
+// error of a command, or an asynchronous event.
+type qmpMessage struct {
+	QMP    json.RawMessage `json:"QMP"`
+	Return json.RawMessage `json:"return"`
+	Error  *struct {
+		Class string `json:"class"`
+		Desc  string `json:"desc"`
+	} `json:"error"`
+	Event string `json:"event"`
+}
+
+type qmpCommand struct {
+	Execute   string      `json:"execute"`
+	Arguments interface{} `json:"arguments,omitempty"`
+}
+
+// QemuMonitorCommand runs cmd, a human monitor command such as
+// `sendkey ctrl-alt-delete`, `screendump /tmp/screen.ppm` or
+// `system_powerdown`, on the QMP server at addr, and returns its output.
+func QemuMonitorCommand(addr string, cmd string) (string, error) {
+	conn, err := net.DialTimeout("tcp", addr, qemuMonitorTimeout)
+	if err != nil {
+		return "", fmt.Errorf("error connecting to the QEMU monitor: %s", err)
+	}
+	defer func() { _ = conn.Close() }()
+	if err := conn.SetDeadline(time.Now().Add(qemuMonitorTimeout)); err != nil {
+		return "", err
+	}
+
+	decoder := json.NewDecoder(conn)
+	encoder := json.NewEncoder(conn)
+
+	var greeting qmpMessage
+	if err := decoder.Decode(&greeting); err != nil {
+		return "", fmt.Errorf("error reading the QEMU monitor greeting: %s", err)
+	}
+	if greeting.QMP == nil {
+		return "", errors.New("the QEMU monitor did not greet with QMP")
+	}
+
+	// The server only takes commands once capabilities are negotiated.
+	if _, err := qmpExecute(decoder, encoder, qmpCommand{Execute: "qmp_capabilities"}); err != nil {
+		return "", err
+	}
+
+	result, err := qmpExecute(decoder, encoder, qmpCommand{
+		Execute:   "human-monitor-command",
+		Arguments: map[string]string{"command-line": cmd},
+	})
+	if err != nil {
+		return "", err
+	}
+	var output string
+	if err := json.Unmarshal(result, &output); err != nil {
+		return "", fmt.Errorf("unexpected QEMU monitor result for %q: %s", cmd, result)
+	}
+	return output, nil
+}
+
+// qmpExecute sends command and returns its result, skipping the events the
+// server sends in between.
+func qmpExecute(decoder *json.Decoder, encoder *json.Encoder, command qmpCommand) (json.RawMessage, error) {
+	if err := encoder.Encode(command); err != nil {
+		return nil, fmt.Errorf("error sending %s to the QEMU monitor: %s", command.Execute, err)
+	}
+	for {
+		var msg qmpMessage
+		if err := decoder.Decode(&msg); err != nil {
+			return nil, fmt.Errorf("error reading the QEMU monitor reply to %s: %s", command.Execute, err)
+		}
+		switch {
+		case msg.Error != nil:
+			return nil, fmt.Errorf("QEMU monitor %s failed: %s: %s", command.Execute, msg.Error.Class, msg.Error.Desc)
+		case msg.Return != nil:
+			return msg.Return, nil
+		}
+	}
+}
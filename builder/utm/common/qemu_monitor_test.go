@@ -0,0 +1,141 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+)
+
+// fakeQMPServer answers one connection the way QEMU's QMP server does:
+// a greeting, then one JSON reply per line received. reply gets each
+// command and returns the raw lines to send back.
+func fakeQMPServer(t *testing.T, reply func(cmd map[string]interface{}) []string) (string, <-chan []string) {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	t.Cleanup(func() { _ = l.Close() })
+
+	received := make(chan []string, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+
+		var lines []string
+		defer func() { received <- lines }()
+
+		fmt.Fprintln(conn, `{"QMP": {"version": {"qemu": {"micro": 0, "minor": 2, "major": 8}}, "capabilities": ["oob"]}}`)
+		scanner := bufio.NewScanner(conn)
+		for scanner.Scan() {
+			line := scanner.Text()
+			lines = append(lines, line)
+
+			var cmd map[string]interface{}
+			if err := json.Unmarshal([]byte(line), &cmd); err != nil {
+				return
+			}
+			for _, out := range reply(cmd) {
+				fmt.Fprintln(conn, out)
+			}
+		}
+	}()
+
+	return l.Addr().String(), received
+}
+
+func TestQemuMonitorCommand(t *testing.T) {
+	addr, received := fakeQMPServer(t, func(cmd map[string]interface{}) []string {
+		if cmd["execute"] == "human-monitor-command" {
+			return []string{
+				`{"timestamp": {"seconds": 1, "microseconds": 2}, "event": "RTC_CHANGE", "data": {"offset": 0}}`,
+				`{"return": "screendump done\r\n"}`,
+			}
+		}
+		return []string{`{"return": {}}`}
+	})
+
+	output, err := QemuMonitorCommand(addr, "screendump /tmp/screen.ppm")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if output != "screendump done\r\n" {
+		t.Fatalf("bad output: %q", output)
+	}
+
+	lines := <-received
+	expected := []string{
+		`{"execute":"qmp_capabilities"}`,
+		`{"execute":"human-monitor-command","arguments":{"command-line":"screendump /tmp/screen.ppm"}}`,
+	}
+	if strings.Join(lines, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("bad commands:\n%s", strings.Join(lines, "\n"))
+	}
+}
+
+func TestQemuMonitorCommand_error(t *testing.T) {
+	addr, _ := fakeQMPServer(t, func(cmd map[string]interface{}) []string {
+		if cmd["execute"] == "human-monitor-command" {
+			return []string{`{"error": {"class": "GenericError", "desc": "unknown command: 'foo'"}}`}
+		}
+		return []string{`{"return": {}}`}
+	})
+
+	_, err := QemuMonitorCommand(addr, "foo")
+	if err == nil {
+		t.Fatal("should error")
+	}
+	if !strings.Contains(err.Error(), "unknown command") {
+		t.Fatalf("bad error: %s", err)
+	}
+}
+
+func TestQemuMonitorCommand_noGreeting(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer func() { _ = l.Close() }()
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		fmt.Fprintln(conn, `{"return": {}}`)
+		_ = conn.Close()
+	}()
+
+	if _, err := QemuMonitorCommand(l.Addr().String(), "info status"); err == nil {
+		t.Fatal("should error without a QMP greeting")
+	}
+}
+
+func TestUtm45Driver_MonitorCommand(t *testing.T) {
+	addr, _ := fakeQMPServer(t, func(cmd map[string]interface{}) []string {
+		if cmd["execute"] == "human-monitor-command" {
+			return []string{`{"return": ""}`}
+		}
+		return []string{`{"return": {}}`}
+	})
+
+	driver := new(Utm45Driver)
+	if _, err := driver.MonitorCommand("test-vm-id", "system_powerdown"); err == nil {
+		t.Fatal("should error when the VM has no monitor")
+	}
+
+	RegisterQemuMonitor("test-vm-id", addr)
+	defer UnregisterQemuMonitor("test-vm-id")
+	if _, err := driver.MonitorCommand("test-vm-id", "system_powerdown"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
@@ -0,0 +1,29 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+// QemuFlagRTC is the QEMU flag setting the real-time clock of the VM.
+const QemuFlagRTC = "-rtc"
+
+// The bases rtc_base can set the real-time clock to.
+const (
+	RTCBaseUTC       = "utc"
+	RTCBaseLocaltime = "localtime"
+)
+
+// RTCQemuArg returns the -rtc argument for the VM, or an empty string when
+// the VM keeps the QEMU default of UTC. Without rtc_base, Windows guests get
+// a clock in local time, which Windows expects. The guest OS is only
+// detected once the VM has booted, so windows says whether the build looks
+// like a Windows one beforehand. It is set by Prepare.
+func (c *QemuConfig) RTCQemuArg(windows bool) string {
+	base := c.RTCBase
+	if base == "" {
+		if !windows || c.hasRTCArg {
+			return ""
+		}
+		base = RTCBaseLocaltime
+	}
+	return QemuFlagRTC + " base=" + base
+}
@@ -0,0 +1,55 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"path/filepath"
+
+	"github.com/hashicorp/packer-plugin-sdk/common"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+// The environment variables ScriptEnv provides to AppleScripts run with
+// ExecuteOsaScriptWithEnv. A script reads them with
+// `system attribute "PACKER_UTM_VM_ID"`.
+const (
+	// ScriptEnvBuildName is the name of the build, as in the template.
+	ScriptEnvBuildName = "PACKER_BUILD_NAME"
+	// ScriptEnvBuilderType is the type of the builder, such as utm-iso.
+	ScriptEnvBuilderType = "PACKER_BUILDER_TYPE"
+	// ScriptEnvOutputDir is the absolute path of output_directory.
+	ScriptEnvOutputDir = "PACKER_UTM_OUTPUT_DIR"
+	// ScriptEnvVMID is the UTM ID of the VM being built.
+	ScriptEnvVMID = "PACKER_UTM_VM_ID"
+	// ScriptEnvVMName is the name of the VM being built.
+	ScriptEnvVMName = "PACKER_UTM_VM_NAME"
+	// ScriptEnvGuestOS is the guest OS found by StepDetectGuestOS.
+	ScriptEnvGuestOS = "PACKER_UTM_GUEST_OS"
+)
+
+// ScriptEnv returns the standard environment for AppleScripts run during a
+// build. The VM variables are left out until the VM has been created, and
+// the guest OS until it has been detected.
+func ScriptEnv(state multistep.StateBag, pc *common.PackerConfig, outputDir string) map[string]string {
+	env := map[string]string{
+		ScriptEnvBuildName:   pc.PackerBuildName,
+		ScriptEnvBuilderType: pc.PackerBuilderType,
+	}
+	if outputDir != "" {
+		if abs, err := filepath.Abs(outputDir); err == nil {
+			outputDir = abs
+		}
+		env[ScriptEnvOutputDir] = outputDir
+	}
+	if vmId, err := GetVMID(state); err == nil {
+		env[ScriptEnvVMID] = vmId
+	}
+	if vmName, err := GetVMName(state); err == nil {
+		env[ScriptEnvVMName] = vmName
+	}
+	if guestOS := GetGuestOS(state); guestOS != "" {
+		env[ScriptEnvGuestOS] = guestOS
+	}
+	return env
+}
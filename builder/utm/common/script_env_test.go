@@ -0,0 +1,64 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/common"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+func TestScriptEnv(t *testing.T) {
+	state := new(multistep.BasicStateBag)
+	pc := &common.PackerConfig{PackerBuildName: "foo", PackerBuilderType: "utm-iso"}
+
+	env := ScriptEnv(state, pc, "")
+	expected := map[string]string{
+		ScriptEnvBuildName:   "foo",
+		ScriptEnvBuilderType: "utm-iso",
+	}
+	if !reflect.DeepEqual(env, expected) {
+		t.Fatalf("bad env before the VM exists: %#v", env)
+	}
+
+	state.Put("vmId", "vm-id")
+	state.Put("vmName", "vm-name")
+	state.Put(StateGuestOS, GuestOSLinux)
+	outputDir, err := filepath.Abs("output-foo")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	env = ScriptEnv(state, pc, "output-foo")
+	expected[ScriptEnvOutputDir] = outputDir
+	expected[ScriptEnvVMID] = "vm-id"
+	expected[ScriptEnvVMName] = "vm-name"
+	expected[ScriptEnvGuestOS] = GuestOSLinux
+	if !reflect.DeepEqual(env, expected) {
+		t.Fatalf("bad env: %#v", env)
+	}
+}
+
+func TestDriverMock_ExecuteOsaScriptWithEnv(t *testing.T) {
+	driver := new(DriverMock)
+	env := map[string]string{ScriptEnvVMID: "vm-id"}
+
+	if _, err := driver.ExecuteOsaScriptWithEnv(context.Background(), env, "hook.applescript", "vm-id"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, err := driver.ExecuteOsaScript(context.Background(), "other.applescript"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if len(driver.ExecuteOsaCalls) != 2 || driver.ExecuteOsaCalls[0][0] != "hook.applescript" {
+		t.Fatalf("bad calls: %#v", driver.ExecuteOsaCalls)
+	}
+	if !reflect.DeepEqual(driver.ExecuteOsaEnvs, []map[string]string{env, nil}) {
+		t.Fatalf("should record the env of each call: %#v", driver.ExecuteOsaEnvs)
+	}
+}
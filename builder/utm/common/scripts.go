@@ -0,0 +1,190 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// RequiredScripts lists the AppleScripts, embedded from scripts/, that the
+// builders run with ExecuteOsaScript. Add a script here when a step starts
+// using it, so a missing file fails the build before any step runs.
+var RequiredScripts = []string{
+	"add_drive.applescript",
+	"add_network_interface.applescript",
+	"add_port_forwards.applescript",
+	"add_qemu_additional_args.applescript",
+	"add_qemu_display.applescript",
+	"attach_iso.applescript",
+	"clear_network_interfaces.applescript",
+	"clear_port_forwards.applescript",
+	"clone_vm.applescript",
+	"create_vm.applescript",
+	"customize_vm.applescript",
+	"delete_vm.applescript",
+	"get_architecture.applescript",
+	"get_ip_addresses.applescript",
+	"get_status.applescript",
+	"get_vm_config.applescript",
+	"list_drives.applescript",
+	"remove_drive.applescript",
+	"remove_qemu_additional_args.applescript",
+	"remove_qemu_display_by_name.applescript",
+	"reorder_drives.applescript",
+	"resume_vm.applescript",
+	"send_keys.applescript",
+	"start_vm.applescript",
+	"stop_vm.applescript",
+	"suspend_vm.applescript",
+}
+
+// scriptChecksums is the SHA-256 manifest of the bundled AppleScripts, in
+// the format of shasum. Regenerate it with `go generate` after changing a
+// script.
+//
+//go:generate sh -c "cd scripts && shasum -a 256 *.applescript > ../scripts.sha256"
+//go:embed scripts.sha256
+var scriptChecksums string
+
+// parseScriptChecksums reads a shasum manifest into a map of file names to
+// hex digests.
+func parseScriptChecksums(manifest string) (map[string]string, error) {
+	checksums := map[string]string{}
+	for i, line := range strings.Split(manifest, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d of the script manifest is malformed: %q", i+1, line)
+		}
+		checksums[strings.TrimPrefix(fields[1], "*")] = strings.ToLower(fields[0])
+	}
+	return checksums, nil
+}
+
+// verifyScriptChecksums checks every required script in the scripts
+// directory of fsys against manifest, so a script modified after the
+// manifest was generated fails the build before it runs with the user's
+// automation permission. The scripts scriptsDir has a copy of are skipped,
+// since that copy runs instead.
+func verifyScriptChecksums(fsys fs.FS, manifest string, scriptsDir string) error {
+	checksums, err := parseScriptChecksums(manifest)
+	if err != nil {
+		return err
+	}
+
+	var mismatched []string
+	for _, name := range RequiredScripts {
+		if scriptsDir != "" {
+			if _, err := os.Stat(filepath.Join(scriptsDir, name)); err == nil {
+				log.Printf("[WARN] Not verifying the checksum of %s, scripts_dir overrides it", name)
+				continue
+			}
+		}
+		content, err := fs.ReadFile(fsys, path.Join("scripts", name))
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(content)
+		expected, ok := checksums[name]
+		if !ok {
+			log.Printf("[ERROR] %s has no checksum in the script manifest", name)
+			mismatched = append(mismatched, name)
+			continue
+		}
+		if actual := hex.EncodeToString(sum[:]); actual != expected {
+			log.Printf("[ERROR] %s has checksum %s, expected %s", name, actual, expected)
+			mismatched = append(mismatched, name)
+		}
+	}
+	if len(mismatched) > 0 {
+		return fmt.Errorf("bundled AppleScripts don't match the plugin's checksums: %s",
+			strings.Join(mismatched, ", "))
+	}
+	return nil
+}
+
+// verifyScripts checks that every required script is in the scripts
+// directory of fsys.
+func verifyScripts(fsys fs.FS) error {
+	var missing []string
+	for _, name := range RequiredScripts {
+		if _, err := fs.Stat(fsys, path.Join("scripts", name)); err != nil {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("the plugin is missing bundled AppleScripts: %s",
+			strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// verifyScriptsDir checks that dir holds patched copies of required scripts.
+// It doesn't need all of them, since the bundled ones are used for the rest,
+// but a script it doesn't know about is most likely misnamed and would be
+// silently ignored.
+func verifyScriptsDir(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	known := make(map[string]bool, len(RequiredScripts))
+	for _, name := range RequiredScripts {
+		known[name] = true
+	}
+
+	var found int
+	var unknown []string
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".applescript" {
+			continue
+		}
+		if known[entry.Name()] {
+			found++
+		} else {
+			unknown = append(unknown, entry.Name())
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("%s has AppleScripts the plugin doesn't use: %s; expected any of: %s",
+			dir, strings.Join(unknown, ", "), strings.Join(RequiredScripts, ", "))
+	}
+	if found == 0 {
+		return fmt.Errorf("%s has none of the plugin's AppleScripts", dir)
+	}
+	return nil
+}
+
+// readScript returns the AppleScript name from scriptsDir when it has a copy
+// of it, and the bundled one otherwise.
+func readScript(scriptsDir string, name string) ([]byte, error) {
+	if scriptsDir != "" {
+		content, err := os.ReadFile(filepath.Join(scriptsDir, name))
+		if err == nil {
+			log.Printf("[WARN] Using %s from %s instead of the bundled script, "+
+				"its checksum is not verified", name, scriptsDir)
+			return content, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+	return osascripts.ReadFile(path.Join("scripts", name))
+}
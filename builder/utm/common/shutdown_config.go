@@ -6,6 +6,7 @@
 package common
 
 import (
+	"errors"
 	"time"
 
 	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
@@ -37,6 +38,15 @@ type ShutdownConfig struct {
 	// Packer will wait for a default of 5 minutes until the virtual machine is shutdown.
 	// The timeout can be changed using `shutdown_timeout` option.
 	DisableShutdown bool `mapstructure:"disable_shutdown" required:"false"`
+	// Suspend the virtual machine instead of shutting it down once all the
+	// provisioning is done. UTM saves the state of the VM, memory included,
+	// into the bundle, so the exported VM resumes where it left off instead
+	// of booting. The `shutdown_command` is not run, and ISOs stay attached
+	// since UTM only changes the drives of a stopped VM. The build fails
+	// when UTM can't save the state of the VM, for example for an Apple
+	// Virtualization VM on a host older than macOS 14. By default, this is
+	// false.
+	SuspendBeforeExport bool `mapstructure:"suspend_before_export" required:"false"`
 }
 
 func (c *ShutdownConfig) Prepare(ctx *interpolate.Context) []error {
@@ -48,5 +58,26 @@ func (c *ShutdownConfig) Prepare(ctx *interpolate.Context) []error {
 		c.PostShutdownDelay = 2 * time.Second
 	}
 
-	return nil
+	var errs []error
+	if c.SuspendBeforeExport && c.DisableShutdown {
+		errs = append(errs, errors.New("suspend_before_export conflicts with disable_shutdown"))
+	}
+
+	return errs
+}
+
+// PrepareSuspend validates suspend_before_export against the build options
+// of the builder, since the saved state is only kept by exporting the VM.
+func (c *ShutdownConfig) PrepareSuspend(skipExport bool, keepRunning bool) []error {
+	if !c.SuspendBeforeExport {
+		return nil
+	}
+
+	var errs []error
+	if keepRunning {
+		errs = append(errs, errors.New("suspend_before_export conflicts with keep_running"))
+	} else if skipExport {
+		errs = append(errs, errors.New("suspend_before_export needs the VM to be exported, it can't be used with skip_export"))
+	}
+	return errs
 }
@@ -4,6 +4,7 @@
 package common
 
 import (
+	"strings"
 	"testing"
 	"time"
 
@@ -91,3 +92,30 @@ func TestShutdownConfigPrepare_DisableShutdown(t *testing.T) {
 		t.Fatalf("bad: %t", c.DisableShutdown)
 	}
 }
+
+func TestShutdownConfigPrepare_SuspendBeforeExport(t *testing.T) {
+	c := testShutdownConfig()
+	c.SuspendBeforeExport = true
+	if errs := c.Prepare(interpolate.NewContext()); len(errs) > 0 {
+		t.Fatalf("err: %#v", errs)
+	}
+	if errs := c.PrepareSuspend(false, false); len(errs) > 0 {
+		t.Fatalf("err: %#v", errs)
+	}
+
+	c = testShutdownConfig()
+	c.SuspendBeforeExport = true
+	c.DisableShutdown = true
+	if errs := c.Prepare(interpolate.NewContext()); len(errs) != 1 {
+		t.Fatalf("should conflict with disable_shutdown: %#v", errs)
+	}
+
+	c = testShutdownConfig()
+	c.SuspendBeforeExport = true
+	if errs := c.PrepareSuspend(true, false); len(errs) != 1 {
+		t.Fatalf("should need the VM to be exported: %#v", errs)
+	}
+	if errs := c.PrepareSuspend(true, true); len(errs) != 1 || !strings.Contains(errs[0].Error(), "keep_running") {
+		t.Fatalf("should conflict with keep_running: %#v", errs)
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:generate packer-sdc struct-markdown
+
+package common
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// unixUsernameRe matches the user names the authorized keys command can be
+// given without quoting.
+var unixUsernameRe = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_.-]{0,31}$`)
+
+type SSHAuthorizedKeysConfig struct {
+	// SSH public keys to add to the `authorized_keys` of
+	// `ssh_authorized_keys_user` in the guest, over the communicator once
+	// the provisioners have run, so that the exported image takes them.
+	// Each is a line of an `authorized_keys` file, such as
+	// `ssh-ed25519 AAAA... alice@example.com`, and must parse as a public
+	// key. Keys the file already has are not added again. Only Unix guests
+	// are supported, and this needs a communicator.
+	//
+	// In HCL2:
+	// ```hcl
+	// ssh_authorized_keys = [
+	//   file("keys/alice.pub"),
+	//   "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAI... bob@example.com",
+	// ]
+	// ```
+	SSHAuthorizedKeys []string `mapstructure:"ssh_authorized_keys" required:"false"`
+	// The guest user whose `~/.ssh/authorized_keys` gets
+	// `ssh_authorized_keys`. The keys are written with `sudo` when it isn't
+	// the communicator user. Defaults to `ssh_username`.
+	SSHAuthorizedKeysUser string `mapstructure:"ssh_authorized_keys_user" required:"false"`
+}
+
+func (c *SSHAuthorizedKeysConfig) Prepare(commType string, sshUsername string) []error {
+	var errs []error
+
+	if len(c.SSHAuthorizedKeys) == 0 {
+		if c.SSHAuthorizedKeysUser != "" {
+			errs = append(errs, errors.New("ssh_authorized_keys_user needs ssh_authorized_keys"))
+		}
+		return errs
+	}
+
+	for i, key := range c.SSHAuthorizedKeys {
+		if _, _, _, rest, err := ssh.ParseAuthorizedKey([]byte(key)); err != nil {
+			errs = append(errs, fmt.Errorf("ssh_authorized_keys[%d]: not a valid public key: %s", i, err))
+		} else if len(rest) > 0 {
+			errs = append(errs, fmt.Errorf("ssh_authorized_keys[%d]: must hold a single key", i))
+		}
+	}
+
+	switch commType {
+	case "none":
+		errs = append(errs, errors.New("ssh_authorized_keys needs a communicator"))
+	case "winrm":
+		errs = append(errs, errors.New("ssh_authorized_keys only supports Unix guests, not the winrm communicator"))
+	}
+
+	if c.SSHAuthorizedKeysUser == "" {
+		c.SSHAuthorizedKeysUser = sshUsername
+	}
+	if c.SSHAuthorizedKeysUser == "" {
+		errs = append(errs, errors.New("ssh_authorized_keys needs ssh_authorized_keys_user or ssh_username"))
+	} else if !unixUsernameRe.MatchString(c.SSHAuthorizedKeysUser) {
+		errs = append(errs, fmt.Errorf("ssh_authorized_keys_user: %q is not a valid user name", c.SSHAuthorizedKeysUser))
+	}
+
+	return errs
+}
@@ -0,0 +1,59 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"strings"
+	"testing"
+)
+
+const testAuthorizedKey = "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIEp5ZOmWwmXU3CMZOkQqhOHBBFEVt8XhkeKkVaMC0tKl alice@example.com"
+
+func TestSSHAuthorizedKeysConfigPrepare(t *testing.T) {
+	c := &SSHAuthorizedKeysConfig{SSHAuthorizedKeys: []string{testAuthorizedKey}}
+	if errs := c.Prepare("ssh", "packer"); len(errs) > 0 {
+		t.Fatalf("should not have error: %s", errs)
+	}
+	if c.SSHAuthorizedKeysUser != "packer" {
+		t.Fatalf("should default to ssh_username: %q", c.SSHAuthorizedKeysUser)
+	}
+
+	c = new(SSHAuthorizedKeysConfig)
+	if errs := c.Prepare("none", ""); len(errs) > 0 {
+		t.Fatalf("should not have error without keys: %s", errs)
+	}
+}
+
+func TestSSHAuthorizedKeysConfigPrepare_invalid(t *testing.T) {
+	cases := map[string]struct {
+		config   SSHAuthorizedKeysConfig
+		commType string
+		expected string
+	}{
+		"bad key": {
+			SSHAuthorizedKeysConfig{SSHAuthorizedKeys: []string{"ssh-ed25519 nope"}}, "ssh", "not a valid public key",
+		},
+		"two keys": {
+			SSHAuthorizedKeysConfig{SSHAuthorizedKeys: []string{testAuthorizedKey + "\n" + testAuthorizedKey}}, "ssh", "single key",
+		},
+		"no communicator": {
+			SSHAuthorizedKeysConfig{SSHAuthorizedKeys: []string{testAuthorizedKey}}, "none", "needs a communicator",
+		},
+		"winrm": {
+			SSHAuthorizedKeysConfig{SSHAuthorizedKeys: []string{testAuthorizedKey}}, "winrm", "Unix guests",
+		},
+		"bad user": {
+			SSHAuthorizedKeysConfig{SSHAuthorizedKeys: []string{testAuthorizedKey}, SSHAuthorizedKeysUser: "bob; rm -rf /"}, "ssh", "not a valid user name",
+		},
+		"user without keys": {
+			SSHAuthorizedKeysConfig{SSHAuthorizedKeysUser: "bob"}, "ssh", "needs ssh_authorized_keys",
+		},
+	}
+	for name, tc := range cases {
+		errs := tc.config.Prepare(tc.commType, "packer")
+		if len(errs) != 1 || !strings.Contains(errs[0].Error(), tc.expected) {
+			t.Fatalf("%s: expected an error about %q, got: %s", name, tc.expected, errs)
+		}
+	}
+}
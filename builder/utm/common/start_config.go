@@ -0,0 +1,37 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:generate packer-sdc struct-markdown
+
+package common
+
+import (
+	"fmt"
+	"time"
+)
+
+// DefaultStartTimeout is how long the builders wait for UTM to report the
+// VM as started.
+const DefaultStartTimeout = 2 * time.Minute
+
+type StartConfig struct {
+	// The amount of time to wait for UTM to report the virtual machine as
+	// started after asking it to start. If QEMU exits or the VM is still not
+	// running by then, the build fails with the error UTM reported instead
+	// of timing out later while connecting to it. By default, the timeout is
+	// 2m or two minutes.
+	StartTimeout time.Duration `mapstructure:"start_timeout" required:"false"`
+}
+
+func (c *StartConfig) Prepare() []error {
+	var errs []error
+
+	if c.StartTimeout == 0 {
+		c.StartTimeout = DefaultStartTimeout
+	}
+	if c.StartTimeout < 0 {
+		errs = append(errs, fmt.Errorf("start_timeout must not be negative, got %s", c.StartTimeout))
+	}
+
+	return errs
+}
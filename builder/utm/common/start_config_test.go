@@ -0,0 +1,29 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStartConfigPrepare(t *testing.T) {
+	c := new(StartConfig)
+	if errs := c.Prepare(); len(errs) > 0 {
+		t.Fatalf("should not have error: %s", errs)
+	}
+	if c.StartTimeout != DefaultStartTimeout {
+		t.Fatalf("bad default: %s", c.StartTimeout)
+	}
+
+	c.StartTimeout = 30 * time.Second
+	if errs := c.Prepare(); len(errs) > 0 || c.StartTimeout != 30*time.Second {
+		t.Fatalf("should keep start_timeout: %s %s", c.StartTimeout, errs)
+	}
+
+	c.StartTimeout = -time.Second
+	if errs := c.Prepare(); len(errs) != 1 {
+		t.Fatalf("should reject a negative start_timeout: %s", errs)
+	}
+}
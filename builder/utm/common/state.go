@@ -0,0 +1,101 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// State keys for the VM being built. Both are set by the step that creates
+// or imports the VM, before any other step touches it.
+const (
+	StateVMID   = "vmId"
+	StateVMName = "vmName"
+)
+
+// ErrVMNotCreated is returned by GetVMID and GetVMName when the VM is not in
+// state, typically because the step creating it failed or has not run yet.
+var ErrVMNotCreated = errors.New("VM was not created")
+
+// GetVMID returns the UTM ID of the VM being built.
+func GetVMID(state multistep.StateBag) (string, error) {
+	return getStateString(state, StateVMID)
+}
+
+// GetVMName returns the name of the VM being built.
+func GetVMName(state multistep.StateBag) (string, error) {
+	return getStateString(state, StateVMName)
+}
+
+func getStateString(state multistep.StateBag, key string) (string, error) {
+	raw, ok := state.GetOk(key)
+	if !ok {
+		return "", fmt.Errorf("%w: %s is not set", ErrVMNotCreated, key)
+	}
+	value, ok := raw.(string)
+	if !ok || value == "" {
+		return "", fmt.Errorf("%s is not a valid VM reference: %#v", key, raw)
+	}
+	return value, nil
+}
+
+// getUI returns the UI from state. Steps use it instead of a bare type
+// assertion so a badly set up state halts the build instead of panicking.
+func getUI(state multistep.StateBag) (packersdk.Ui, error) {
+	ui, ok := state.Get("ui").(packersdk.Ui)
+	if !ok {
+		return nil, errors.New("ui is not set in state")
+	}
+	return ui, nil
+}
+
+// getDriver returns the UTM driver from state.
+func getDriver(state multistep.StateBag) (Driver, error) {
+	driver, ok := state.Get("driver").(Driver)
+	if !ok {
+		return nil, errors.New("driver is not set in state")
+	}
+	return driver, nil
+}
+
+// haltWithError records err as the build error, reports it on ui when there
+// is one, and halts the build.
+func haltWithError(state multistep.StateBag, ui packersdk.Ui, err error) multistep.StepAction {
+	state.Put("error", err)
+	if ui != nil {
+		ui.Error(err.Error())
+	} else {
+		log.Printf("[ERROR] %s", err)
+	}
+	return multistep.ActionHalt
+}
+
+// getCommunicator returns the communicator connected to the VM from state.
+func getCommunicator(state multistep.StateBag) (packersdk.Communicator, error) {
+	comm, ok := state.Get("communicator").(packersdk.Communicator)
+	if !ok {
+		return nil, errors.New("communicator is not set in state")
+	}
+	return comm, nil
+}
+
+// sleepCtx waits for d, returning the error of ctx early when the build is
+// cancelled first.
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
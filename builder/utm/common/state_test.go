@@ -0,0 +1,107 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+func TestGetVMID(t *testing.T) {
+	state := new(multistep.BasicStateBag)
+	if _, err := GetVMID(state); !errors.Is(err, ErrVMNotCreated) {
+		t.Fatalf("should error with ErrVMNotCreated when vmId is missing, got: %v", err)
+	}
+
+	state.Put("vmId", 42)
+	if _, err := GetVMID(state); err == nil {
+		t.Fatal("should error when vmId is not a string")
+	}
+
+	state.Put("vmId", "foo")
+	vmId, err := GetVMID(state)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if vmId != "foo" {
+		t.Fatalf("bad: %s", vmId)
+	}
+}
+
+func TestGetVMName(t *testing.T) {
+	state := new(multistep.BasicStateBag)
+	if _, err := GetVMName(state); err == nil {
+		t.Fatal("should error when vmName is missing")
+	}
+
+	state.Put("vmName", "packer-foo")
+	vmName, err := GetVMName(state)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if vmName != "packer-foo" {
+		t.Fatalf("bad: %s", vmName)
+	}
+}
+
+func TestStepRun_missingVMID(t *testing.T) {
+	state := testState(t)
+	step := new(StepRun)
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("error"); !ok {
+		t.Fatal("should have error")
+	}
+}
+
+func TestGetUIAndDriver(t *testing.T) {
+	state := new(multistep.BasicStateBag)
+	if _, err := getUI(state); err == nil {
+		t.Fatal("should error when ui is missing")
+	}
+	if _, err := getDriver(state); err == nil {
+		t.Fatal("should error when driver is missing")
+	}
+
+	ready := testState(t)
+	if _, err := getUI(ready); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, err := getDriver(ready); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+func TestSteps_emptyStateHalts(t *testing.T) {
+	steps := map[string]multistep.Step{
+		"StepAttachDisplay":     &StepAttachDisplay{HardwareType: "virtio-gpu-pci"},
+		"StepAttachISOs":        new(StepAttachISOs),
+		"StepConfigureQemuArgs": &StepConfigureQemuArgs{QemuArgs: [][]string{{"-accel", "hvf"}}},
+		"StepCreateVM":          new(StepCreateVM),
+		"StepExport":            new(StepExport),
+		"StepPortForwarding":    new(StepPortForwarding),
+		"StepRemoveDevices":     new(StepRemoveDevices),
+		"StepRun":               new(StepRun),
+		"StepShutdown":          new(StepShutdown),
+		"StepStopVm":            new(StepStopVm),
+		"StepUploadVersion":     new(StepUploadVersion),
+	}
+
+	for name, step := range steps {
+		t.Run(name, func(t *testing.T) {
+			state := new(multistep.BasicStateBag)
+			if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+				t.Fatalf("bad action: %#v", action)
+			}
+			if _, ok := state.GetOk("error"); !ok {
+				t.Fatal("should have error")
+			}
+		})
+	}
+}
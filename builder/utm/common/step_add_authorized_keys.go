@@ -0,0 +1,86 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// authorizedKeysUploadPath is where the keys are uploaded before they are
+// added, as their comments can hold anything the shell would interpret.
+const authorizedKeysUploadPath = "/tmp/packer-authorized-keys"
+
+// authorizedKeysCommand adds the keys uploaded to %[2]s to the
+// authorized_keys of the user %[1]s, skipping the keys it already has. The
+// user name is validated, so it needs no quoting.
+const authorizedKeysCommand = `sh -c 'set -e; S=; [ "$(id -u)" -eq 0 ] || [ "$(id -un)" = %[1]s ] || S="sudo -n"; ` +
+	`H=$(eval echo ~%[1]s); F="$H/.ssh/authorized_keys"; ` +
+	`$S mkdir -p "$H/.ssh"; $S touch "$F"; ` +
+	`while IFS= read -r k; do [ -z "$k" ] || $S grep -qxF "$k" "$F" || echo "$k" | $S tee -a "$F" >/dev/null; done < %[2]s; ` +
+	`$S chmod 700 "$H/.ssh"; $S chmod 600 "$F"; $S chown -R %[1]s "$H/.ssh"; rm -f %[2]s'`
+
+// StepAddAuthorizedKeys adds ssh_authorized_keys to the authorized_keys of a
+// guest user over the communicator, after StepDetectGuestOS so Windows
+// guests can be refused. It runs once the provisioners are done, so the
+// exported image has the keys whatever they changed.
+//
+// Uses:
+//
+//	communicator packersdk.Communicator
+//	guest_os     string (optional)
+//	ui           packersdk.Ui
+type StepAddAuthorizedKeys struct {
+	Keys []string
+	User string
+}
+
+func (s *StepAddAuthorizedKeys) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	if len(s.Keys) == 0 {
+		log.Println("No ssh_authorized_keys specified, skipping...")
+		return multistep.ActionContinue
+	}
+
+	ui, err := getUI(state)
+	if err != nil {
+		return haltWithError(state, nil, err)
+	}
+	comm, err := getCommunicator(state)
+	if err != nil {
+		return haltWithError(state, ui, err)
+	}
+
+	if guestOS := GetGuestOS(state); guestOS == GuestOSWindows {
+		return haltWithError(state, ui, fmt.Errorf(
+			"can't add ssh_authorized_keys: only Unix guests are supported, the guest runs %s", guestOS))
+	}
+
+	var keys bytes.Buffer
+	for _, key := range s.Keys {
+		keys.WriteString(strings.TrimSpace(key) + "\n")
+	}
+	if err := comm.Upload(authorizedKeysUploadPath, &keys, nil); err != nil {
+		return haltWithError(state, ui, fmt.Errorf("error uploading ssh_authorized_keys: %s", err))
+	}
+
+	ui.Say(fmt.Sprintf("Adding %d SSH authorized key(s) for %s...", len(s.Keys), s.User))
+	cmd := &packersdk.RemoteCmd{Command: fmt.Sprintf(authorizedKeysCommand, s.User, authorizedKeysUploadPath)}
+	if err := cmd.RunWithUi(ctx, comm, ui); err != nil {
+		return haltWithError(state, ui, fmt.Errorf("error adding ssh_authorized_keys: %s", err))
+	}
+	if status := cmd.ExitStatus(); status != 0 {
+		return haltWithError(state, ui, fmt.Errorf(
+			"authorized keys command exited with status %d", status))
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *StepAddAuthorizedKeys) Cleanup(state multistep.StateBag) {}
@@ -0,0 +1,75 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+func TestStepAddAuthorizedKeys_impl(t *testing.T) {
+	var _ multistep.Step = new(StepAddAuthorizedKeys)
+}
+
+func TestStepAddAuthorizedKeys(t *testing.T) {
+	state := testState(t)
+	comm := new(packersdk.MockCommunicator)
+	state.Put("communicator", comm)
+	state.Put(StateGuestOS, GuestOSLinux)
+
+	step := &StepAddAuthorizedKeys{
+		Keys: []string{testAuthorizedKey + "\n"},
+		User: "deploy",
+	}
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if comm.UploadPath != authorizedKeysUploadPath || comm.UploadData != testAuthorizedKey+"\n" {
+		t.Fatalf("should upload the keys: %q %q", comm.UploadPath, comm.UploadData)
+	}
+	if !strings.Contains(comm.StartCmd.Command, "~deploy") || !strings.Contains(comm.StartCmd.Command, "chown -R deploy") {
+		t.Fatalf("bad command: %q", comm.StartCmd.Command)
+	}
+}
+
+func TestStepAddAuthorizedKeys_skip(t *testing.T) {
+	state := testState(t)
+	step := new(StepAddAuthorizedKeys)
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+}
+
+func TestStepAddAuthorizedKeys_windows(t *testing.T) {
+	state := testState(t)
+	comm := new(packersdk.MockCommunicator)
+	state.Put("communicator", comm)
+	state.Put(StateGuestOS, GuestOSWindows)
+
+	step := &StepAddAuthorizedKeys{Keys: []string{testAuthorizedKey}, User: "deploy"}
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if comm.StartCalled {
+		t.Fatal("should not run anything on a Windows guest")
+	}
+}
+
+func TestStepAddAuthorizedKeys_failure(t *testing.T) {
+	state := testState(t)
+	comm := &packersdk.MockCommunicator{StartExitStatus: 1}
+	state.Put("communicator", comm)
+
+	step := &StepAddAuthorizedKeys{Keys: []string{testAuthorizedKey}, User: "deploy"}
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("error"); !ok {
+		t.Fatal("should have error")
+	}
+}
@@ -16,9 +16,18 @@ type StepAttachDisplay struct {
 }
 
 func (s *StepAttachDisplay) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
-	ui := state.Get("ui").(packersdk.Ui)
-	driver := state.Get("driver").(Driver)
-	vmId := state.Get("vmId").(string)
+	ui, err := getUI(state)
+	if err != nil {
+		return haltWithError(state, nil, err)
+	}
+	driver, err := getDriver(state)
+	if err != nil {
+		return haltWithError(state, ui, err)
+	}
+	vmId, err := GetVMID(state)
+	if err != nil {
+		return haltWithError(state, ui, err)
+	}
 
 	// Check if HardwareType is empty
 	if s.HardwareType == "" {
@@ -34,7 +43,7 @@ func (s *StepAttachDisplay) Run(ctx context.Context, state multistep.StateBag) m
 		"--hardware", s.HardwareType,
 	}
 
-	_, err := driver.ExecuteOsaScript(command...)
+	_, err = driver.ExecuteOsaScript(ctx, command...)
 	if err != nil {
 		err := fmt.Errorf("error attaching display: %s", err)
 		state.Put("error", err)
@@ -71,7 +80,7 @@ func (s *StepAttachDisplay) Cleanup(state multistep.StateBag) {
 	if !ok {
 		ui.Say("Detaching displays...")
 		for _, command := range s.detachDisplayCommands {
-			_, err := driver.ExecuteOsaScript(command...)
+			_, err := driver.ExecuteOsaScript(context.Background(), command...)
 			if err != nil {
 				log.Printf("error detaching display: %s", err)
 			}
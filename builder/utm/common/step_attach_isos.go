@@ -2,10 +2,15 @@ package common
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io/fs"
 	"log"
+	"os"
 	"path/filepath"
-	"regexp"
+	"slices"
+	"strconv"
+	"strings"
 
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
@@ -19,31 +24,52 @@ import (
 //  1. boot_iso - The installation ISO (typically C: after install, but mounted first)
 //  2. cd_files - User-provided files ISO (typically D: in Windows)
 //  3. guest_additions - UTM guest tools ISO (typically E: in Windows)
+//  4. windows_unattended - Generated autounattend.xml ISO (typically F: in Windows)
+//  5. additional_isos - User-provided ISOs, in the order they are configured,
+//     except those attached once the communicator is connected
 //
 // This ordering is critical for Windows installations where scripts may depend
 // on knowing which drive letter to use for accessing files or running installers.
 type StepAttachISOs struct {
-	AttachBootISO           bool
-	ISOInterface            string
-	GuestAdditionsMode      string
-	GuestAdditionsInterface string
-	diskUnmountCommands     map[string][]string
+	AttachBootISO                   bool
+	ISOInterface                    string
+	CDFilesInterface                string
+	GuestAdditionsMode              string
+	GuestAdditionsInterface         string
+	GuestAdditionsInterfaceFallback string
+	AdditionalISOs                  []AdditionalISO
+	diskUnmountCommands             map[string][]string
+	// driveIndexes maps the category of each ISO attached at a given index
+	// on its interface to that index.
+	driveIndexes map[string]int
 }
 
-// diskToMount represents an ISO to mount with its category and path
+// diskToMount represents an ISO to mount with its category and path, and the
+// controller to use when the category doesn't imply one, with the index to
+// attach it at on that controller when one is set
 type diskToMount struct {
-	category string
-	isoPath  string
+	category   string
+	isoPath    string
+	controller string
+	index      *int
 }
 
 func (s *StepAttachISOs) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
 	// Check whether there is anything to attach
-	ui := state.Get("ui").(packersdk.Ui)
+	ui, err := getUI(state)
+	if err != nil {
+		return haltWithError(state, nil, err)
+	}
+	driver, err := getDriver(state)
+	if err != nil {
+		return haltWithError(state, ui, err)
+	}
 
 	ui.Say("Mounting ISOs...")
 	// Use a slice to maintain predictable order for consistent drive letters in Windows
 	disksToMount := []diskToMount{}
 	s.diskUnmountCommands = map[string][]string{}
+	s.driveIndexes = map[string]int{}
 
 	// Track the bootable iso (only used in utm-iso builder. )
 	// Boot ISO should be first for predictable drive letters
@@ -111,23 +137,92 @@ func (s *StepAttachISOs) Run(ctx context.Context, state multistep.StateBag) mult
 		})
 	}
 
+	// The Windows answer file ISO goes after guest additions, so the drive
+	// letters above stay the same whether or not it is used
+	if unattendedPathRaw, ok := state.GetOk("windows_unattended_path"); ok {
+		unattendedPath := unattendedPathRaw.(string)
+		// Convert to absolute path if it's not already
+		if !filepath.IsAbs(unattendedPath) {
+			absPath, err := filepath.Abs(unattendedPath)
+			if err != nil {
+				err := fmt.Errorf("error converting windows_unattended_path to absolute path: %s", err)
+				state.Put("error", err)
+				ui.Error(err.Error())
+				return multistep.ActionHalt
+			}
+			unattendedPath = absPath
+		}
+		disksToMount = append(disksToMount, diskToMount{
+			category: "windows_unattended",
+			isoPath:  unattendedPath,
+		})
+	}
+
+	// Additional ISOs go after everything the builder manages, so they
+	// never change the drive letters of the ISOs above
+	if pathsRaw, ok := state.GetOk("additional_iso_paths"); ok {
+		for i, isoPath := range pathsRaw.([]string) {
+			if s.AdditionalISOs[i].PostBoot() {
+				// Hot-plugged by StepAttachPostBootISOs once the
+				// communicator is connected.
+				continue
+			}
+			// Convert to absolute path if it's not already
+			if !filepath.IsAbs(isoPath) {
+				absPath, err := filepath.Abs(isoPath)
+				if err != nil {
+					err := fmt.Errorf("error converting additional_isos[%d] to absolute path: %s", i, err)
+					state.Put("error", err)
+					ui.Error(err.Error())
+					return multistep.ActionHalt
+				}
+				isoPath = absPath
+			}
+			disksToMount = append(disksToMount, diskToMount{
+				category:   fmt.Sprintf("additional_iso_%d", i),
+				isoPath:    isoPath,
+				controller: s.AdditionalISOs[i].Interface,
+				index:      s.AdditionalISOs[i].Index,
+			})
+		}
+	}
+
 	if len(disksToMount) == 0 {
 		ui.Say("No ISOs to mount; continuing...")
 		return multistep.ActionContinue
 	}
 
-	driver := state.Get("driver").(Driver)
-	vmId := state.Get("vmId").(string)
+	vmId, err := GetVMID(state)
+	if err != nil {
+		return haltWithError(state, ui, err)
+	}
+
+	files := make([]NamedFile, len(disksToMount))
+	for i, disk := range disksToMount {
+		files[i] = NamedFile{Name: disk.category, Path: disk.isoPath}
+	}
+	if err := CheckDistinctFiles(files); err != nil {
+		return haltWithError(state, ui, err)
+	}
+
+	// A retried step finds the ISOs it already attached, which it reuses
+	// rather than attaching them twice.
+	existingDrives, err := driver.ListAttachedDrives(vmId)
+	if err != nil {
+		log.Printf("Can't list the drives already attached, attaching every ISO: %s", err)
+	}
 
 	// Iterate over the ISOs to attach in the specified order
 	// This ensures predictable drive letter assignment in Windows guests
 	for _, disk := range disksToMount {
+		if err := ctx.Err(); err != nil {
+			return haltWithError(state, ui, fmt.Errorf("interrupted while mounting ISOs: %w", err))
+		}
 		diskCategory := disk.category
 		isoPath := disk.isoPath
 		// If it's a symlink, resolve it to its target.
-		resolvedIsoPath, err := filepath.EvalSymlinks(isoPath)
+		resolvedIsoPath, err := resolveISOPath(diskCategory, isoPath)
 		if err != nil {
-			err := fmt.Errorf("error resolving symlink for ISO: %s", err)
 			state.Put("error", err)
 			ui.Error(err.Error())
 			return multistep.ActionHalt
@@ -144,8 +239,20 @@ func (s *StepAttachISOs) Run(ctx context.Context, state multistep.StateBag) mult
 			controllerName = s.GuestAdditionsInterface
 			ui.Say("Mounting guest additions ISO...")
 		case "cd_files":
-			controllerName = "usb"
+			controllerName = s.CDFilesInterface
+			if controllerName == "" {
+				controllerName = "usb"
+			}
 			ui.Say("Mounting cd_files ISO...")
+		case "windows_unattended":
+			controllerName = s.CDFilesInterface
+			if controllerName == "" {
+				controllerName = "usb"
+			}
+			ui.Say("Mounting windows_unattended ISO...")
+		default:
+			controllerName = disk.controller
+			ui.Say(fmt.Sprintf("Mounting additional ISO %s...", isoPath))
 		}
 
 		// Convert controllerName to the corresponding enum code
@@ -155,14 +262,28 @@ func (s *StepAttachISOs) Run(ctx context.Context, state multistep.StateBag) mult
 			ui.Error(err.Error())
 			return multistep.ActionHalt
 		}
+		if disk.index != nil {
+			s.driveIndexes[diskCategory] = *disk.index
+		}
+		if drive, ok := findAttachedISO(existingDrives, isoPath, controllerName); ok {
+			ui.Say(fmt.Sprintf("%s ISO is already attached as drive %s, reusing it", diskCategory, drive.ID))
+			s.diskUnmountCommands[diskCategory] = []string{"remove_drive.applescript", vmId, drive.ID}
+			continue
+		}
 		// Attach the ISO
 		command := []string{
 			"attach_iso.applescript", vmId,
 			"--interface", controllerEnumCode,
 			"--source", isoPath,
 		}
+		if disk.index != nil {
+			command = append(command, "--index", strconv.Itoa(*disk.index))
+		}
 
-		output, err := driver.ExecuteOsaScript(command...)
+		output, err := driver.ExecuteOsaScriptOutput(ctx, nil, command...)
+		if err != nil && diskCategory == "guest_additions" {
+			output, err = s.attachGuestAdditionsFallback(ctx, driver, ui, vmId, isoPath, controllerName, err)
+		}
 		if err != nil {
 			err := fmt.Errorf("error attaching ISO: %s", err)
 			state.Put("error", err)
@@ -173,41 +294,160 @@ func (s *StepAttachISOs) Run(ctx context.Context, state multistep.StateBag) mult
 		// Track the disks we've mounted so we can remove them without having
 		// to re-derive what was mounted where
 
-		// Regular expression to capture the UUID from the output
-		re := regexp.MustCompile(`[0-9a-fA-F-]{36}`)
-		matches := re.FindStringSubmatch(output)
-		if len(matches) > 0 {
-			uuid := matches[0] // Capture the UUID
-			unmountCommand := []string{
-				"remove_drive.applescript", vmId, uuid,
-			}
-			s.diskUnmountCommands[diskCategory] = unmountCommand
-		} else {
-			err := fmt.Errorf("error extracting UUID from output: %s", output)
+		uuid, err := ParseUUID(output.Stdout)
+		if err != nil {
+			err := fmt.Errorf("error extracting UUID from output: %s", output.Combined())
 			state.Put("error", err)
 			ui.Error(err.Error())
 			return multistep.ActionHalt
 		}
+		unmountCommand := []string{
+			"remove_drive.applescript", vmId, uuid,
+		}
+		s.diskUnmountCommands[diskCategory] = unmountCommand
 	}
 
 	state.Put("disk_unmount_commands", s.diskUnmountCommands)
+
+	if err := verifyAttachedDrives(driver, vmId, s.diskUnmountCommands, s.driveIndexes); err != nil {
+		return haltWithError(state, ui, err)
+	}
 	return multistep.ActionContinue
 }
 
-func (s *StepAttachISOs) Cleanup(state multistep.StateBag) {
-	if len(s.diskUnmountCommands) == 0 {
-		return
+// findAttachedISO returns the removable drive among drives with isoPath as
+// its source on the controllerName interface.
+func findAttachedISO(drives []Drive, isoPath string, controllerName string) (Drive, bool) {
+	for _, drive := range drives {
+		if drive.Removable && drive.Source == isoPath && strings.EqualFold(drive.Interface, controllerName) {
+			return drive, true
+		}
 	}
+	return Drive{}, false
+}
 
-	driver := state.Get("driver").(Driver)
-	_, ok := state.GetOk("detached_isos")
+// attachGuestAdditionsFallback attaches the guest additions ISO to
+// GuestAdditionsInterfaceFallback when UTM refused controllerName with
+// attachErr and the VM is not known to support it. Otherwise it returns
+// attachErr, naming the interfaces the VM supports when they are known.
+func (s *StepAttachISOs) attachGuestAdditionsFallback(ctx context.Context, driver Driver, ui packersdk.Ui, vmId string, isoPath string, controllerName string, attachErr error) (OsaScriptOutput, error) {
+	supported, err := driver.SupportedDriveInterfaces(vmId)
+	if err != nil {
+		log.Printf("Could not read the drive interfaces of the VM: %s", err)
+	}
+	supportedNames := strings.Join(supported, ", ")
 
-	if !ok {
-		for _, command := range s.diskUnmountCommands {
-			_, err := driver.ExecuteOsaScript(command...)
-			if err != nil {
-				log.Printf("error detaching iso: %s", err)
+	// Interface names are case-insensitive, the supported ones are lowercase.
+	controllerName = strings.ToLower(controllerName)
+	fallback := strings.ToLower(s.GuestAdditionsInterfaceFallback)
+	if fallback == "" || fallback == controllerName || slices.Contains(supported, controllerName) {
+		if supported != nil {
+			return OsaScriptOutput{}, fmt.Errorf("%s (the VM supports the %s interfaces)", attachErr, supportedNames)
+		}
+		return OsaScriptOutput{}, attachErr
+	}
+
+	fallbackCode, err := GetControllerEnumCode(fallback)
+	if err != nil {
+		return OsaScriptOutput{}, err
+	}
+	if supported != nil {
+		ui.Error(fmt.Sprintf("Warning: the VM has no %s interface, attaching the guest additions ISO to %s instead (supported: %s)",
+			controllerName, fallback, supportedNames))
+	} else {
+		ui.Error(fmt.Sprintf("Warning: attaching the guest additions ISO to %s failed (%s), attaching it to %s instead",
+			controllerName, attachErr, fallback))
+	}
+	output, err := driver.ExecuteOsaScriptOutput(ctx, nil,
+		"attach_iso.applescript", vmId,
+		"--interface", fallbackCode,
+		"--source", isoPath,
+	)
+	if err != nil && supported != nil {
+		return output, fmt.Errorf("%s (the VM supports the %s interfaces)", err, supportedNames)
+	}
+	return output, err
+}
+
+// verifyAttachedDrives checks that UTM lists the drives attach_iso reported,
+// so a drive UTM silently dropped fails the build here rather than at boot,
+// and that the drives of the categories in indexes ended up at their index
+// on their interface, which a later ISO or too few drives can upset. The
+// check is skipped when the drives can't be listed.
+func verifyAttachedDrives(driver Driver, vmId string, unmountCommands map[string][]string, indexes map[string]int) error {
+	drives, err := driver.ListAttachedDrives(vmId)
+	if err != nil {
+		log.Printf("Can't verify the attached ISOs: %s", err)
+		return nil
+	}
+
+	attached := make(map[string]bool, len(drives))
+	for _, drive := range drives {
+		attached[strings.ToUpper(drive.ID)] = true
+	}
+	for category, command := range unmountCommands {
+		if driveId := command[2]; !attached[strings.ToUpper(driveId)] {
+			return fmt.Errorf("%s ISO was not attached: VM %s has no drive %s", category, vmId, driveId)
+		}
+	}
+	for category, index := range indexes {
+		driveId := unmountCommands[category][2]
+		if actual := driveIndex(drives, driveId); actual != index {
+			return fmt.Errorf("%s ISO was attached at index %d of its interface instead of %d, "+
+				"is the index past the drives on the interface?", category, actual, index)
+		}
+	}
+	return nil
+}
+
+// driveIndex returns the position, from 0, of the drive with the given id
+// among the drives on its interface, or -1 when there is no such drive.
+func driveIndex(drives []Drive, driveId string) int {
+	for _, drive := range drives {
+		if !strings.EqualFold(drive.ID, driveId) {
+			continue
+		}
+		index := 0
+		for _, other := range drives {
+			if strings.EqualFold(other.ID, driveId) {
+				return index
+			}
+			if other.Interface == drive.Interface {
+				index++
 			}
 		}
 	}
+	return -1
+}
+
+// resolveISOPath resolves any symlinks in isoPath, returning an error that
+// names the ISO category and tells a missing ISO apart from a broken symlink
+// or a permission problem.
+func resolveISOPath(category string, isoPath string) (string, error) {
+	resolvedPath, err := filepath.EvalSymlinks(isoPath)
+	if err == nil {
+		return resolvedPath, nil
+	}
+
+	info, lstatErr := os.Lstat(isoPath)
+	switch {
+	case errors.Is(lstatErr, fs.ErrNotExist):
+		return "", fmt.Errorf("%s ISO not found: %s", category, isoPath)
+	case errors.Is(lstatErr, fs.ErrPermission), errors.Is(err, fs.ErrPermission):
+		return "", fmt.Errorf("permission denied reading %s ISO %s: %s", category, isoPath, err)
+	case lstatErr == nil && info.Mode()&fs.ModeSymlink != 0 && errors.Is(err, fs.ErrNotExist):
+		return "", fmt.Errorf("%s ISO %s is a symlink to a file that does not exist: %s", category, isoPath, err)
+	default:
+		return "", fmt.Errorf("error resolving symlink for %s ISO %s: %s", category, isoPath, err)
+	}
+}
+
+func (s *StepAttachISOs) Cleanup(state multistep.StateBag) {
+	if len(s.diskUnmountCommands) == 0 {
+		return
+	}
+
+	if _, ok := state.GetOk("detached_isos"); !ok {
+		DetachDrives(state, s.diskUnmountCommands)
+	}
 }
@@ -0,0 +1,498 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+func TestStepAttachISOs_impl(t *testing.T) {
+	var _ multistep.Step = new(StepAttachISOs)
+}
+
+func TestStepAttachISOs_additionalISOs(t *testing.T) {
+	state := testState(t)
+	cdPath, _ := testISOFile(t)
+	extraPath, _ := testISOFile(t)
+	step := &StepAttachISOs{
+		GuestAdditionsMode: GuestAdditionsModeDisable,
+		AdditionalISOs:     []AdditionalISO{{Url: extraPath, Interface: "virtio"}},
+	}
+	state.Put("vmId", "foo")
+	state.Put("cd_path", cdPath)
+	state.Put("additional_iso_paths", []string{extraPath})
+
+	driver := state.Get("driver").(*DriverMock)
+	driver.ExecuteOsaResult = "7FB247A3-DC9F-4A61-A123-0AEE1BEEC636"
+	driver.ListAttachedDrivesResult = []Drive{{ID: "7FB247A3-DC9F-4A61-A123-0AEE1BEEC636"}}
+	driver.VersionResult = "4.6.4"
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	if len(driver.ExecuteOsaCalls) != 2 {
+		t.Fatalf("bad calls: %#v", driver.ExecuteOsaCalls)
+	}
+	// cd_files first, then the additional ISO on its own interface
+	if driver.ExecuteOsaCalls[0][3] != "QdIu" || driver.ExecuteOsaCalls[0][5] != cdPath {
+		t.Fatalf("bad call: %#v", driver.ExecuteOsaCalls[0])
+	}
+	if driver.ExecuteOsaCalls[1][3] != "QdIv" || driver.ExecuteOsaCalls[1][5] != extraPath {
+		t.Fatalf("bad call: %#v", driver.ExecuteOsaCalls[1])
+	}
+
+	commands := state.Get("disk_unmount_commands").(map[string][]string)
+	if _, ok := commands["additional_iso_0"]; !ok {
+		t.Fatalf("should track additional ISO for removal: %#v", commands)
+	}
+}
+
+func TestStepAttachISOs_index(t *testing.T) {
+	state := testState(t)
+	cdPath, _ := testISOFile(t)
+	extraPath, _ := testISOFile(t)
+	zero := 0
+	step := &StepAttachISOs{
+		GuestAdditionsMode: GuestAdditionsModeDisable,
+		AdditionalISOs:     []AdditionalISO{{Url: extraPath, Interface: "usb", Index: &zero}},
+	}
+	state.Put("vmId", "foo")
+	state.Put("cd_path", cdPath)
+	state.Put("additional_iso_paths", []string{extraPath})
+
+	driver := state.Get("driver").(*DriverMock)
+	driver.ExecuteOsaResult = "7FB247A3-DC9F-4A61-A123-0AEE1BEEC636"
+	driver.ListAttachedDrivesResult = []Drive{
+		{ID: "0AEE1BEE-DC9F-4A61-A123-7FB247A3C636", Interface: "virtio"},
+		{ID: "7FB247A3-DC9F-4A61-A123-0AEE1BEEC636", Interface: "usb"},
+	}
+	driver.VersionResult = "4.6.4"
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v %v", action, state.Get("error"))
+	}
+	if len(driver.ExecuteOsaCalls[0]) != 6 {
+		t.Fatalf("should not pass an index for cd_files: %#v", driver.ExecuteOsaCalls[0])
+	}
+	if call := driver.ExecuteOsaCalls[1]; len(call) != 8 || call[6] != "--index" || call[7] != "0" {
+		t.Fatalf("should pass the index: %#v", call)
+	}
+
+	// The ISO landed after another drive on its interface.
+	state = testState(t)
+	state.Put("vmId", "foo")
+	state.Put("additional_iso_paths", []string{extraPath})
+	driver = state.Get("driver").(*DriverMock)
+	driver.ExecuteOsaResult = "7FB247A3-DC9F-4A61-A123-0AEE1BEEC636"
+	driver.ListAttachedDrivesResult = []Drive{
+		{ID: "0AEE1BEE-DC9F-4A61-A123-7FB247A3C636", Interface: "usb"},
+		{ID: "7FB247A3-DC9F-4A61-A123-0AEE1BEEC636", Interface: "usb"},
+	}
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	err, ok := state.GetOk("error")
+	if !ok || !strings.Contains(err.(error).Error(), "at index 1 of its interface instead of 0") {
+		t.Fatalf("should report the wrong index: %v", err)
+	}
+}
+
+func TestStepAttachISOs_alreadyAttached(t *testing.T) {
+	state := testState(t)
+	cdPath, _ := testISOFile(t)
+	extraPath, _ := testISOFile(t)
+	resolvedCDPath, err := filepath.EvalSymlinks(cdPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	step := &StepAttachISOs{
+		GuestAdditionsMode: GuestAdditionsModeDisable,
+		AdditionalISOs:     []AdditionalISO{{Url: extraPath, Interface: "usb"}},
+	}
+	state.Put("vmId", "foo")
+	state.Put("cd_path", cdPath)
+	state.Put("additional_iso_paths", []string{extraPath})
+
+	// A previous attempt attached the cd_files ISO.
+	driver := state.Get("driver").(*DriverMock)
+	driver.ExecuteOsaResult = "7FB247A3-DC9F-4A61-A123-0AEE1BEEC636"
+	driver.ListAttachedDrivesResult = []Drive{
+		{ID: "0AEE1BEE-DC9F-4A61-A123-7FB247A3C636", Interface: "usb", Removable: true, Source: resolvedCDPath},
+		{ID: "7FB247A3-DC9F-4A61-A123-0AEE1BEEC636", Interface: "usb", Removable: true},
+	}
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v %v", action, state.Get("error"))
+	}
+	if len(driver.ExecuteOsaCalls) != 1 || driver.ExecuteOsaCalls[0][5] == cdPath {
+		t.Fatalf("should only attach the additional ISO: %#v", driver.ExecuteOsaCalls)
+	}
+	commands := state.Get("disk_unmount_commands").(map[string][]string)
+	if uuid := commands["cd_files"][2]; uuid != "0AEE1BEE-DC9F-4A61-A123-7FB247A3C636" {
+		t.Fatalf("should remove the drive already attached in cleanup: %s", uuid)
+	}
+}
+
+func TestStepAttachISOs_skipsPostBootISOs(t *testing.T) {
+	state := testState(t)
+	bootPath, _ := testISOFile(t)
+	driversPath, _ := testISOFile(t)
+	step := &StepAttachISOs{
+		GuestAdditionsMode: GuestAdditionsModeDisable,
+		AdditionalISOs: []AdditionalISO{
+			{Url: bootPath, Interface: "usb"},
+			{Url: driversPath, Interface: "usb", AttachAfter: AttachAfterCommunicator},
+		},
+	}
+	state.Put("vmId", "foo")
+	state.Put("additional_iso_paths", []string{bootPath, driversPath})
+
+	driver := state.Get("driver").(*DriverMock)
+	driver.ExecuteOsaResult = "7FB247A3-DC9F-4A61-A123-0AEE1BEEC636"
+	driver.ListAttachedDrivesResult = []Drive{{ID: "7FB247A3-DC9F-4A61-A123-0AEE1BEEC636"}}
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if len(driver.ExecuteOsaCalls) != 1 || driver.ExecuteOsaCalls[0][5] != bootPath {
+		t.Fatalf("should only attach the ISO needed at boot: %#v", driver.ExecuteOsaCalls)
+	}
+	commands := state.Get("disk_unmount_commands").(map[string][]string)
+	if _, ok := commands["additional_iso_1"]; ok {
+		t.Fatalf("should not track the ISO attached after boot: %#v", commands)
+	}
+}
+
+func TestResolveISOPath(t *testing.T) {
+	path, _ := testISOFile(t)
+	link := filepath.Join(t.TempDir(), "link.iso")
+	if err := os.Symlink(path, link); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	resolved, err := resolveISOPath("boot_iso", link)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	expected, _ := filepath.EvalSymlinks(path)
+	if resolved != expected {
+		t.Fatalf("expected %s, got %s", expected, resolved)
+	}
+}
+
+func TestResolveISOPath_notFound(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing.iso")
+
+	_, err := resolveISOPath("cd_files", path)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+	expected := "cd_files ISO not found: " + path
+	if err.Error() != expected {
+		t.Fatalf("expected %q, got %q", expected, err)
+	}
+}
+
+func TestResolveISOPath_brokenSymlink(t *testing.T) {
+	dir := t.TempDir()
+	link := filepath.Join(dir, "link.iso")
+	if err := os.Symlink(filepath.Join(dir, "missing.iso"), link); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	_, err := resolveISOPath("boot_iso", link)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+	if !strings.Contains(err.Error(), "boot_iso ISO "+link+" is a symlink to a file that does not exist") {
+		t.Fatalf("bad error: %s", err)
+	}
+}
+
+func TestResolveISOPath_permissionDenied(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("permissions are not enforced for root")
+	}
+
+	dir := filepath.Join(t.TempDir(), "locked")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	path := filepath.Join(dir, "boot.iso")
+	if err := os.WriteFile(path, []byte("iso"), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := os.Chmod(dir, 0); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer func() { _ = os.Chmod(dir, 0755) }()
+
+	_, err := resolveISOPath("guest_additions", path)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+	if !strings.Contains(err.Error(), "permission denied reading guest_additions ISO "+path) {
+		t.Fatalf("bad error: %s", err)
+	}
+}
+
+func TestStepAttachISOs_missingISO(t *testing.T) {
+	state := testState(t)
+	step := &StepAttachISOs{
+		GuestAdditionsMode: GuestAdditionsModeDisable,
+	}
+	state.Put("vmId", "foo")
+	state.Put("cd_path", filepath.Join(t.TempDir(), "missing.iso"))
+
+	driver := state.Get("driver").(*DriverMock)
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("error"); !ok {
+		t.Fatal("should have error")
+	}
+	if len(driver.ExecuteOsaCalls) != 0 {
+		t.Fatalf("should not attach anything: %#v", driver.ExecuteOsaCalls)
+	}
+}
+
+func TestStepAttachISOs_cdFilesInterface(t *testing.T) {
+	state := testState(t)
+	cdPath, _ := testISOFile(t)
+	step := &StepAttachISOs{
+		CDFilesInterface:   "ide",
+		GuestAdditionsMode: GuestAdditionsModeDisable,
+	}
+	state.Put("vmId", "foo")
+	state.Put("cd_path", cdPath)
+
+	driver := state.Get("driver").(*DriverMock)
+	driver.ExecuteOsaResult = "7FB247A3-DC9F-4A61-A123-0AEE1BEEC636"
+	driver.ListAttachedDrivesResult = []Drive{{ID: "7FB247A3-DC9F-4A61-A123-0AEE1BEEC636"}}
+	driver.VersionResult = "4.6.4"
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if len(driver.ExecuteOsaCalls) != 1 || driver.ExecuteOsaCalls[0][3] != "QdIi" {
+		t.Fatalf("bad calls: %#v", driver.ExecuteOsaCalls)
+	}
+}
+
+func TestStepAttachISOs_badCDFilesInterface(t *testing.T) {
+	state := testState(t)
+	cdPath, _ := testISOFile(t)
+	step := &StepAttachISOs{
+		CDFilesInterface:   "sata",
+		GuestAdditionsMode: GuestAdditionsModeDisable,
+	}
+	state.Put("vmId", "foo")
+	state.Put("cd_path", cdPath)
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+}
+
+func TestStepAttachISOs_windowsUnattended(t *testing.T) {
+	state := testState(t)
+	cdPath, _ := testISOFile(t)
+	unattendedPath, _ := testISOFile(t)
+	extraPath, _ := testISOFile(t)
+	step := &StepAttachISOs{
+		GuestAdditionsMode: GuestAdditionsModeDisable,
+		AdditionalISOs:     []AdditionalISO{{Url: extraPath, Interface: "virtio"}},
+	}
+	state.Put("vmId", "foo")
+	state.Put("cd_path", cdPath)
+	state.Put("windows_unattended_path", unattendedPath)
+	state.Put("additional_iso_paths", []string{extraPath})
+
+	driver := state.Get("driver").(*DriverMock)
+	driver.ExecuteOsaResult = "7FB247A3-DC9F-4A61-A123-0AEE1BEEC636"
+	driver.ListAttachedDrivesResult = []Drive{{ID: "7FB247A3-DC9F-4A61-A123-0AEE1BEEC636"}}
+	driver.VersionResult = "4.6.4"
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	// The answer file goes after cd_files and before the additional ISOs
+	if len(driver.ExecuteOsaCalls) != 3 {
+		t.Fatalf("bad calls: %#v", driver.ExecuteOsaCalls)
+	}
+	if driver.ExecuteOsaCalls[1][3] != "QdIu" || driver.ExecuteOsaCalls[1][5] != unattendedPath {
+		t.Fatalf("bad call: %#v", driver.ExecuteOsaCalls[1])
+	}
+	if driver.ExecuteOsaCalls[2][5] != extraPath {
+		t.Fatalf("bad call: %#v", driver.ExecuteOsaCalls[2])
+	}
+
+	commands := state.Get("disk_unmount_commands").(map[string][]string)
+	if _, ok := commands["windows_unattended"]; !ok {
+		t.Fatalf("should track answer file ISO for removal: %#v", commands)
+	}
+}
+
+func TestStepAttachISOs_uuidFromStdout(t *testing.T) {
+	state := testState(t)
+	cdPath, _ := testISOFile(t)
+	step := &StepAttachISOs{
+		GuestAdditionsMode: GuestAdditionsModeDisable,
+	}
+	state.Put("vmId", "foo")
+	state.Put("cd_path", cdPath)
+
+	driver := state.Get("driver").(*DriverMock)
+	driver.ExecuteOsaResult = "7FB247A3-DC9F-4A61-A123-0AEE1BEEC636"
+	driver.ListAttachedDrivesResult = []Drive{{ID: "7FB247A3-DC9F-4A61-A123-0AEE1BEEC636"}}
+	driver.ExecuteOsaStderr = "warning: drive 00000000-0000-0000-0000-000000000000 is busy"
+	driver.VersionResult = "4.6.4"
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	commands := state.Get("disk_unmount_commands").(map[string][]string)
+	if uuid := commands["cd_files"][2]; uuid != "7FB247A3-DC9F-4A61-A123-0AEE1BEEC636" {
+		t.Fatalf("should read the drive UUID from stdout only: %s", uuid)
+	}
+}
+
+func TestStepAttachISOs_notAttached(t *testing.T) {
+	state := testState(t)
+	cdPath, _ := testISOFile(t)
+	step := &StepAttachISOs{
+		GuestAdditionsMode: GuestAdditionsModeDisable,
+	}
+	state.Put("vmId", "foo")
+	state.Put("cd_path", cdPath)
+
+	driver := state.Get("driver").(*DriverMock)
+	driver.ExecuteOsaResult = "7FB247A3-DC9F-4A61-A123-0AEE1BEEC636"
+	driver.ListAttachedDrivesResult = []Drive{{ID: "00000000-0000-0000-0000-000000000001"}}
+	driver.VersionResult = "4.6.4"
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	err, ok := state.GetOk("error")
+	if !ok || !strings.Contains(err.(error).Error(), "7FB247A3-DC9F-4A61-A123-0AEE1BEEC636") {
+		t.Fatalf("should report the missing drive: %#v", err)
+	}
+}
+
+func TestStepAttachISOs_cancelled(t *testing.T) {
+	state := testState(t)
+	cdPath, _ := testISOFile(t)
+	step := &StepAttachISOs{GuestAdditionsMode: GuestAdditionsModeDisable}
+	state.Put("vmId", "foo")
+	state.Put("cd_path", cdPath)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if action := step.Run(ctx, state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	driver := state.Get("driver").(*DriverMock)
+	if len(driver.ExecuteOsaCalls) != 0 {
+		t.Fatalf("should not mount after cancellation: %#v", driver.ExecuteOsaCalls)
+	}
+}
+
+func TestStepAttachISOs_guestAdditionsFallback(t *testing.T) {
+	state := testState(t)
+	gaPath, _ := testISOFile(t)
+	step := &StepAttachISOs{
+		GuestAdditionsMode:              GuestAdditionsModeAttach,
+		GuestAdditionsInterface:         "ide",
+		GuestAdditionsInterfaceFallback: "usb",
+	}
+	state.Put("vmId", "foo")
+	state.Put("guest_additions_path", gaPath)
+
+	driver := state.Get("driver").(*DriverMock)
+	driver.ExecuteOsaErrs = []error{errors.New("UTM error: invalid interface")}
+	driver.ExecuteOsaResult = "7FB247A3-DC9F-4A61-A123-0AEE1BEEC636"
+	driver.ListAttachedDrivesResult = []Drive{{ID: "7FB247A3-DC9F-4A61-A123-0AEE1BEEC636"}}
+	driver.SupportedDriveInterfacesResult = DriveInterfacesForArch("aarch64")
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if len(driver.ExecuteOsaCalls) != 2 {
+		t.Fatalf("bad calls: %#v", driver.ExecuteOsaCalls)
+	}
+	if driver.ExecuteOsaCalls[0][3] != "QdIi" || driver.ExecuteOsaCalls[1][3] != "QdIu" {
+		t.Fatalf("should retry on usb: %#v", driver.ExecuteOsaCalls)
+	}
+	errs := state.Get("ui").(*packersdk.BasicUi).Writer.(*bytes.Buffer).String()
+	if !strings.Contains(errs, "the VM has no ide interface") {
+		t.Fatalf("should warn about the fallback: %q", errs)
+	}
+}
+
+func TestStepAttachISOs_guestAdditionsSupportedInterfaceFails(t *testing.T) {
+	// Interface names match the supported ones in any case.
+	for _, iface := range []string{"scsi", "SCSI"} {
+		state := testState(t)
+		gaPath, _ := testISOFile(t)
+		step := &StepAttachISOs{
+			GuestAdditionsMode:              GuestAdditionsModeAttach,
+			GuestAdditionsInterface:         iface,
+			GuestAdditionsInterfaceFallback: "usb",
+		}
+		state.Put("vmId", "foo")
+		state.Put("guest_additions_path", gaPath)
+
+		driver := state.Get("driver").(*DriverMock)
+		driver.ExecuteOsaErrs = []error{errors.New("UTM error")}
+		driver.SupportedDriveInterfacesResult = DriveInterfacesForArch("aarch64")
+
+		if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+			t.Fatalf("%s: bad action: %#v", iface, action)
+		}
+		if len(driver.ExecuteOsaCalls) != 1 {
+			t.Fatalf("%s: should not retry a supported interface: %#v", iface, driver.ExecuteOsaCalls)
+		}
+		err := state.Get("error").(error)
+		if !strings.Contains(err.Error(), "the VM supports the mtd, none, nvme") {
+			t.Fatalf("%s: should name the supported interfaces: %s", iface, err)
+		}
+	}
+}
+
+func TestStepAttachISOs_sameFile(t *testing.T) {
+	state := testState(t)
+	isoPath, _ := testISOFile(t)
+	step := &StepAttachISOs{
+		AttachBootISO:      true,
+		GuestAdditionsMode: GuestAdditionsModeDisable,
+	}
+	state.Put("vmId", "foo")
+	state.Put("iso_path", isoPath)
+	state.Put("cd_path", isoPath)
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	driver := state.Get("driver").(*DriverMock)
+	if len(driver.ExecuteOsaCalls) != 0 {
+		t.Fatalf("should not mount the ISO twice: %#v", driver.ExecuteOsaCalls)
+	}
+	err := state.Get("error").(error)
+	if !strings.Contains(err.Error(), "boot_iso and cd_files are the same file") {
+		t.Fatalf("bad error: %s", err)
+	}
+}
@@ -0,0 +1,133 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+// This step hot-plugs the additional ISOs with attach_after = "communicator"
+// once the communicator is connected. UTM only changes the drives of a
+// stopped VM, so the ISOs are added through the QEMU monitor instead of
+// attach_iso.applescript. They are not part of the UTM configuration, so
+// they go away when the VM stops and are never exported.
+//
+// Uses:
+//
+//	additional_iso_paths []string
+//	driver Driver
+//	ui packersdk.Ui
+//	vmId string
+type StepAttachPostBootISOs struct {
+	AdditionalISOs []AdditionalISO
+}
+
+func (s *StepAttachPostBootISOs) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	var postBoot []int
+	for i := range s.AdditionalISOs {
+		if s.AdditionalISOs[i].PostBoot() {
+			postBoot = append(postBoot, i)
+		}
+	}
+	if len(postBoot) == 0 {
+		log.Println("No additional ISOs to attach after boot, skipping...")
+		return multistep.ActionContinue
+	}
+
+	ui, err := getUI(state)
+	if err != nil {
+		return haltWithError(state, nil, err)
+	}
+	driver, err := getDriver(state)
+	if err != nil {
+		return haltWithError(state, ui, err)
+	}
+	vmId, err := GetVMID(state)
+	if err != nil {
+		return haltWithError(state, ui, err)
+	}
+	paths, ok := state.Get("additional_iso_paths").([]string)
+	if !ok || len(paths) != len(s.AdditionalISOs) {
+		return haltWithError(state, ui, fmt.Errorf("additional_iso_paths is not set in state"))
+	}
+
+	for _, i := range postBoot {
+		if err := ctx.Err(); err != nil {
+			return haltWithError(state, ui, fmt.Errorf("interrupted while hot-plugging ISOs: %w", err))
+		}
+		category := fmt.Sprintf("additional_iso_%d", i)
+		isoPath, err := filepath.Abs(paths[i])
+		if err != nil {
+			return haltWithError(state, ui, fmt.Errorf(
+				"error converting additional_isos[%d] to absolute path: %s", i, err))
+		}
+		isoPath, err = resolveISOPath(category, isoPath)
+		if err != nil {
+			return haltWithError(state, ui, err)
+		}
+
+		ui.Say(fmt.Sprintf("Hot-plugging additional ISO %s...", isoPath))
+		driveId := "packer-" + strings.ReplaceAll(category, "_", "-")
+		deviceId := driveId + "-dev"
+		if err := monitorCommand(driver, vmId, hotplugDriveCommand(driveId, isoPath, s.AdditionalISOs[i].Interface)); err != nil {
+			return haltWithError(state, ui, fmt.Errorf("error attaching additional_isos[%d]: %s", i, err))
+		}
+		if err := monitorCommand(driver, vmId, hotplugDeviceCommand(driveId, deviceId, s.AdditionalISOs[i].Interface)); err != nil {
+			// The drive is useless without its device.
+			if err := monitorCommand(driver, vmId, "drive_del "+driveId); err != nil {
+				log.Printf("Error removing drive %s: %s", driveId, err)
+			}
+			return haltWithError(state, ui, fmt.Errorf("error attaching additional_isos[%d]: %s", i, err))
+		}
+	}
+
+	return multistep.ActionContinue
+}
+
+// hotplugDriveCommand returns the monitor command adding a read-only drive
+// backed by isoPath. Commas in QEMU option values are escaped by doubling.
+func hotplugDriveCommand(driveId string, isoPath string, iface string) string {
+	options := []string{
+		"0", "if=none", "id=" + driveId, "readonly=on",
+		"file=" + strings.ReplaceAll(isoPath, ",", ",,"),
+	}
+	// virtio-blk has no CD-ROM media.
+	if iface == "usb" {
+		options = append(options, "media=cdrom")
+	}
+	return "drive_add " + options[0] + " " + strings.Join(options[1:], ",")
+}
+
+// hotplugDeviceCommand returns the monitor command adding the device the
+// drive is attached to the guest with.
+func hotplugDeviceCommand(driveId string, deviceId string, iface string) string {
+	device := fmt.Sprintf("%s,drive=%s,id=%s", hotplugInterfaces[iface], driveId, deviceId)
+	if iface == "usb" {
+		device += ",removable=on"
+	}
+	return "device_add " + device
+}
+
+// monitorCommand runs cmd on the QEMU monitor. Human monitor commands
+// report errors in their output, and print nothing or OK on success.
+func monitorCommand(driver Driver, vmId string, cmd string) error {
+	output, err := driver.MonitorCommand(vmId, cmd)
+	if err != nil {
+		return err
+	}
+	if output = strings.TrimSpace(output); output != "" && output != "OK" {
+		return fmt.Errorf("%s: %s", strings.Fields(cmd)[0], output)
+	}
+	return nil
+}
+
+// Cleanup leaves the hot-plugged ISOs alone, as they go away with QEMU when
+// StepRun or StepShutdown stops the VM.
+func (s *StepAttachPostBootISOs) Cleanup(state multistep.StateBag) {}
@@ -0,0 +1,109 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+func TestStepAttachPostBootISOs_impl(t *testing.T) {
+	var _ multistep.Step = new(StepAttachPostBootISOs)
+}
+
+func TestStepAttachPostBootISOs_none(t *testing.T) {
+	state := testState(t)
+	step := &StepAttachPostBootISOs{AdditionalISOs: []AdditionalISO{{Url: "drivers.iso", Interface: "usb"}}}
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if calls := state.Get("driver").(*DriverMock).MonitorCommandCalls; len(calls) != 0 {
+		t.Fatalf("should not hot-plug anything: %#v", calls)
+	}
+}
+
+func TestStepAttachPostBootISOs(t *testing.T) {
+	state := testState(t)
+	bootPath, _ := testISOFile(t)
+	driversPath, _ := testISOFile(t)
+	toolsPath, _ := testISOFile(t)
+	step := &StepAttachPostBootISOs{AdditionalISOs: []AdditionalISO{
+		{Url: bootPath, Interface: "usb"},
+		{Url: driversPath, Interface: "usb", AttachAfter: AttachAfterCommunicator},
+		{Url: toolsPath, Interface: "virtio", AttachAfter: AttachAfterCommunicator},
+	}}
+	state.Put("vmId", "foo")
+	state.Put("additional_iso_paths", []string{bootPath, driversPath, toolsPath})
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	calls := state.Get("driver").(*DriverMock).MonitorCommandCalls
+	expected := []string{
+		"drive_add 0 if=none,id=packer-additional-iso-1,readonly=on,file=" + driversPath + ",media=cdrom",
+		"device_add usb-storage,drive=packer-additional-iso-1,id=packer-additional-iso-1-dev,removable=on",
+		"drive_add 0 if=none,id=packer-additional-iso-2,readonly=on,file=" + toolsPath,
+		"device_add virtio-blk-pci,drive=packer-additional-iso-2,id=packer-additional-iso-2-dev",
+	}
+	if len(calls) != len(expected) {
+		t.Fatalf("bad calls: %#v", calls)
+	}
+	for i, cmd := range expected {
+		if calls[i][0] != "foo" || calls[i][1] != cmd {
+			t.Fatalf("bad call %d: %#v, expected %q", i, calls[i], cmd)
+		}
+	}
+}
+
+func TestStepAttachPostBootISOs_monitorError(t *testing.T) {
+	state := testState(t)
+	path, _ := testISOFile(t)
+	step := &StepAttachPostBootISOs{AdditionalISOs: []AdditionalISO{
+		{Url: path, Interface: "usb", AttachAfter: AttachAfterCommunicator},
+	}}
+	state.Put("vmId", "foo")
+	state.Put("additional_iso_paths", []string{path})
+	driver := state.Get("driver").(*DriverMock)
+	driver.MonitorCommandResult = "Could not open '" + path + "': Operation not permitted"
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	err := state.Get("error").(error)
+	if !strings.Contains(err.Error(), "additional_isos[0]") || !strings.Contains(err.Error(), "Operation not permitted") {
+		t.Fatalf("bad error: %s", err)
+	}
+}
+
+func TestStepAttachPostBootISOs_deviceError(t *testing.T) {
+	state := testState(t)
+	path, _ := testISOFile(t)
+	step := &StepAttachPostBootISOs{AdditionalISOs: []AdditionalISO{
+		{Url: path, Interface: "usb", AttachAfter: AttachAfterCommunicator},
+	}}
+	state.Put("vmId", "foo")
+	state.Put("additional_iso_paths", []string{path})
+	driver := state.Get("driver").(*DriverMock)
+	driver.MonitorCommandErr = errors.New("connection refused")
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if len(driver.MonitorCommandCalls) != 1 {
+		t.Fatalf("should stop at the first error: %#v", driver.MonitorCommandCalls)
+	}
+}
+
+func TestHotplugDriveCommand_escapesCommas(t *testing.T) {
+	cmd := hotplugDriveCommand("d", "/isos/a,b.iso", "virtio")
+	if !strings.HasSuffix(cmd, "file=/isos/a,,b.iso") {
+		t.Fatalf("should escape commas: %s", cmd)
+	}
+}
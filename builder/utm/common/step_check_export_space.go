@@ -0,0 +1,121 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"log"
+	"path/filepath"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+// This step checks, before StepExport, that the output volume has room for
+// the exported bundle, so a full disk fails the build before the export
+// starts rather than leaving a partial bundle behind.
+//
+// Uses:
+//
+//	ui packersdk.Ui
+//	vmName string
+type StepCheckExportSpace struct {
+	OutputDir  string
+	SkipExport bool
+	// MarginMB is the space, in megabytes, to keep free after the export.
+	MarginMB uint
+
+	// freeSpace looks up the free space of a volume, set by tests.
+	freeSpace func(string) (uint64, error)
+}
+
+func (s *StepCheckExportSpace) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	if s.SkipExport {
+		return multistep.ActionContinue
+	}
+
+	ui, err := getUI(state)
+	if err != nil {
+		return haltWithError(state, nil, err)
+	}
+	driver, err := getDriver(state)
+	if err != nil {
+		return haltWithError(state, ui, err)
+	}
+	vmId, err := GetVMID(state)
+	if err != nil {
+		return haltWithError(state, ui, err)
+	}
+
+	bundlePath, err := driver.BundlePath(vmId)
+	if err != nil {
+		log.Printf("Not checking free space for export: %s", err)
+		return multistep.ActionContinue
+	}
+
+	needed, err := BundleSize(bundlePath)
+	if err != nil {
+		// UTM can keep VMs outside its container, in which case there is
+		// nothing to estimate from.
+		log.Printf("Not checking free space for export, can't size %s: %s", bundlePath, err)
+		return multistep.ActionContinue
+	}
+
+	lookup := s.freeSpace
+	if lookup == nil {
+		lookup = freeSpace
+	}
+	available, err := lookup(s.OutputDir)
+	if err != nil {
+		log.Printf("Not checking free space for export: %s", err)
+		return multistep.ActionContinue
+	}
+
+	margin := uint64(s.MarginMB) * 1024 * 1024
+	if available < needed+margin {
+		return haltWithError(state, ui, fmt.Errorf(
+			"not enough free space to export the VM to %s: the bundle needs about %s, "+
+				"plus an export_space_margin of %s, but only %s is available",
+			s.OutputDir, formatBytes(needed), formatBytes(margin), formatBytes(available)))
+	}
+
+	log.Printf("Export needs about %s, %s available", formatBytes(needed), formatBytes(available))
+	return multistep.ActionContinue
+}
+
+func (s *StepCheckExportSpace) Cleanup(state multistep.StateBag) {}
+
+// BundleSize estimates the size of a UTM bundle once exported, as the sum of
+// the sizes of the files it holds, most of which are its disk images.
+func BundleSize(path string) (uint64, error) {
+	var size uint64
+	err := filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			size += uint64(info.Size())
+		}
+		return nil
+	})
+	return size, err
+}
+
+func formatBytes(b uint64) string {
+	const unit = 1024
+	if b < unit {
+		return fmt.Sprintf("%d B", b)
+	}
+	div, exp := uint64(unit), 0
+	for n := b / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(b)/float64(div), "KMGTPE"[exp])
+}
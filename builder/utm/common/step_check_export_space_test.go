@@ -0,0 +1,117 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+func TestStepCheckExportSpace_impl(t *testing.T) {
+	var _ multistep.Step = new(StepCheckExportSpace)
+}
+
+func testExportSpaceBundle(t *testing.T, size int) string {
+	td := t.TempDir()
+	data := filepath.Join(td, "foo.utm", "Data")
+	if err := os.MkdirAll(data, 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := os.WriteFile(filepath.Join(data, "disk.qcow2"), make([]byte, size), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := os.WriteFile(filepath.Join(td, "foo.utm", "config.plist"), make([]byte, 10), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	return filepath.Join(td, "foo.utm")
+}
+
+func TestBundleSize(t *testing.T) {
+	size, err := BundleSize(testExportSpaceBundle(t, 1000))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if size != 1010 {
+		t.Fatalf("bad size: %d", size)
+	}
+}
+
+func TestStepCheckExportSpace(t *testing.T) {
+	state := testState(t)
+	state.Put("vmId", "vm-id")
+	driver := state.Get("driver").(*DriverMock)
+	driver.BundlePathResult = testExportSpaceBundle(t, 1000)
+	step := &StepCheckExportSpace{
+		OutputDir: t.TempDir(),
+		MarginMB:  1,
+		freeSpace: func(string) (uint64, error) { return 2 * 1024 * 1024, nil },
+	}
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+}
+
+func TestStepCheckExportSpace_notEnough(t *testing.T) {
+	state := testState(t)
+	state.Put("vmId", "vm-id")
+	driver := state.Get("driver").(*DriverMock)
+	driver.BundlePathResult = testExportSpaceBundle(t, 1000)
+	step := &StepCheckExportSpace{
+		OutputDir: t.TempDir(),
+		MarginMB:  1,
+		freeSpace: func(string) (uint64, error) { return 1024 * 1024, nil },
+	}
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	err := state.Get("error").(error)
+	if !strings.Contains(err.Error(), "not enough free space") {
+		t.Fatalf("bad error: %s", err)
+	}
+}
+
+func TestStepCheckExportSpace_unknownBundle(t *testing.T) {
+	state := testState(t)
+	state.Put("vmId", "vm-id")
+	driver := state.Get("driver").(*DriverMock)
+	driver.BundlePathResult = filepath.Join(t.TempDir(), "foo.utm")
+	step := &StepCheckExportSpace{
+		OutputDir: t.TempDir(),
+		freeSpace: func(string) (uint64, error) { return 0, errors.New("should not be called") },
+	}
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+}
+
+func TestStepCheckExportSpace_skipExport(t *testing.T) {
+	state := testState(t)
+	step := &StepCheckExportSpace{SkipExport: true}
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	cases := map[uint64]string{
+		512:                     "512 B",
+		1536:                    "1.5 KiB",
+		10 * 1024 * 1024 * 1024: "10.0 GiB",
+	}
+	for b, expected := range cases {
+		if actual := formatBytes(b); actual != expected {
+			t.Errorf("%d: expected %q, got %q", b, expected, actual)
+		}
+	}
+}
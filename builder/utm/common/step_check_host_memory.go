@@ -0,0 +1,65 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+// This step checks, before anything is downloaded, that the host has room
+// in RAM for the VM, so a build that would swap or run out of memory on an
+// undersized Mac fails right away instead of hours later.
+//
+// Uses:
+//
+//	ui packersdk.Ui
+type StepCheckHostMemory struct {
+	// MemorySize is the memory of the VM, in megabytes.
+	MemorySize int
+	// MinHostMemory is the memory, in megabytes, to keep free on the host on
+	// top of the VM's. The check is off when it is 0.
+	MinHostMemory int
+
+	// availableMemory looks up the available host memory, set by tests.
+	availableMemory func() (uint64, error)
+}
+
+func (s *StepCheckHostMemory) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	if s.MinHostMemory == 0 {
+		return multistep.ActionContinue
+	}
+
+	ui, err := getUI(state)
+	if err != nil {
+		return haltWithError(state, nil, err)
+	}
+
+	lookup := s.availableMemory
+	if lookup == nil {
+		lookup = availableMemory
+	}
+	available, err := lookup()
+	if err != nil {
+		log.Printf("Not checking host memory: %s", err)
+		return multistep.ActionContinue
+	}
+
+	const mb = 1024 * 1024
+	needed := uint64(s.MemorySize+s.MinHostMemory) * mb
+	if available < needed {
+		return haltWithError(state, ui, fmt.Errorf(
+			"not enough free memory on the host: the VM needs %s, "+
+				"plus a min_host_memory of %s, but only %s is available; "+
+				"close other applications or lower memory",
+			formatBytes(uint64(s.MemorySize)*mb), formatBytes(uint64(s.MinHostMemory)*mb), formatBytes(available)))
+	}
+	log.Printf("Host has %s available for a VM of %d MB", formatBytes(available), s.MemorySize)
+	return multistep.ActionContinue
+}
+
+func (s *StepCheckHostMemory) Cleanup(state multistep.StateBag) {}
@@ -0,0 +1,88 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+func TestStepCheckHostMemory_impl(t *testing.T) {
+	var _ multistep.Step = new(StepCheckHostMemory)
+}
+
+func testHostMemory(mb uint64, err error) func() (uint64, error) {
+	return func() (uint64, error) { return mb * 1024 * 1024, err }
+}
+
+func TestStepCheckHostMemory(t *testing.T) {
+	state := testState(t)
+	step := &StepCheckHostMemory{
+		MemorySize:      4096,
+		MinHostMemory:   1024,
+		availableMemory: testHostMemory(6000, nil),
+	}
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("error"); ok {
+		t.Fatal("should NOT have error")
+	}
+}
+
+func TestStepCheckHostMemory_insufficient(t *testing.T) {
+	state := testState(t)
+	step := &StepCheckHostMemory{
+		MemorySize:      4096,
+		MinHostMemory:   1024,
+		availableMemory: testHostMemory(5000, nil),
+	}
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	err, ok := state.GetOk("error")
+	if !ok {
+		t.Fatal("should have error")
+	}
+	if !strings.Contains(err.(error).Error(), "not enough free memory") {
+		t.Fatalf("bad error: %s", err)
+	}
+}
+
+func TestStepCheckHostMemory_disabled(t *testing.T) {
+	state := testState(t)
+	step := &StepCheckHostMemory{
+		MemorySize: 4096,
+		availableMemory: func() (uint64, error) {
+			t.Fatal("should not look up the host memory")
+			return 0, nil
+		},
+	}
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+}
+
+func TestStepCheckHostMemory_unknown(t *testing.T) {
+	state := testState(t)
+	step := &StepCheckHostMemory{
+		MemorySize:      4096,
+		MinHostMemory:   1024,
+		availableMemory: testHostMemory(0, errors.New("no vm_stat")),
+	}
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("error"); ok {
+		t.Fatal("should NOT have error")
+	}
+}
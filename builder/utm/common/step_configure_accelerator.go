@@ -0,0 +1,74 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+// StepConfigureAccelerator picks the QEMU accelerator for the VM, resolving
+// "auto" to hvf when the host can run the guest with it and to tcg
+// otherwise. The QEMU argument is added by StepConfigureQemuArgs, with the
+// user's qemuargs, so this step must run before it.
+//
+// Uses:
+//
+//	ui packersdk.Ui
+//
+// Produces:
+//
+//	accelerator  string - The accelerator the VM runs with.
+//	accelQemuArg string - The -accel argument for the VM.
+type StepConfigureAccelerator struct {
+	Accelerator string
+	VMArch      string
+
+	// hvfAvailable checks the host for hvf support, set by tests.
+	hvfAvailable func(guestArch string) (bool, string)
+}
+
+func (s *StepConfigureAccelerator) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	if s.Accelerator == "" {
+		log.Println("[INFO] No accelerator configured, leaving it to UTM...")
+		return multistep.ActionContinue
+	}
+
+	ui, err := getUI(state)
+	if err != nil {
+		return haltWithError(state, nil, err)
+	}
+
+	check := s.hvfAvailable
+	if check == nil {
+		check = hvfAvailable
+	}
+
+	accelerator := s.Accelerator
+	switch accelerator {
+	case AcceleratorHVF:
+		if ok, reason := check(s.VMArch); !ok {
+			return haltWithError(state, ui, fmt.Errorf(
+				"accelerator hvf is not available: %s; use accelerator = \"auto\" "+
+					"to fall back to tcg", reason))
+		}
+	case AcceleratorAuto:
+		accelerator = AcceleratorHVF
+		if ok, reason := check(s.VMArch); !ok {
+			ui.Error(fmt.Sprintf(
+				"Warning: %s, falling back to the much slower tcg accelerator", reason))
+			accelerator = AcceleratorTCG
+		}
+	}
+
+	ui.Say(fmt.Sprintf("Using the %s accelerator", accelerator))
+	state.Put("accelerator", accelerator)
+	state.Put("accelQemuArg", fmt.Sprintf("%s %s", QemuFlagAccel, accelerator))
+	return multistep.ActionContinue
+}
+
+func (s *StepConfigureAccelerator) Cleanup(state multistep.StateBag) {}
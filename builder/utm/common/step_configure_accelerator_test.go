@@ -0,0 +1,121 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+func TestStepConfigureAccelerator_impl(t *testing.T) {
+	var _ multistep.Step = new(StepConfigureAccelerator)
+}
+
+func testHVF(available bool) func(string) (bool, string) {
+	return func(string) (bool, string) {
+		if available {
+			return true, ""
+		}
+		return false, "no hvf here"
+	}
+}
+
+func TestStepConfigureAccelerator(t *testing.T) {
+	cases := []struct {
+		accelerator string
+		hvf         bool
+		expected    string
+	}{
+		{AcceleratorHVF, true, "-accel hvf"},
+		{AcceleratorTCG, false, "-accel tcg"},
+		{AcceleratorAuto, true, "-accel hvf"},
+		{AcceleratorAuto, false, "-accel tcg"},
+	}
+	for _, tc := range cases {
+		state := testState(t)
+		step := &StepConfigureAccelerator{
+			Accelerator:  tc.accelerator,
+			VMArch:       "aarch64",
+			hvfAvailable: testHVF(tc.hvf),
+		}
+
+		if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+			t.Fatalf("%s (hvf %t): bad action: %#v", tc.accelerator, tc.hvf, action)
+		}
+		if arg := state.Get("accelQemuArg"); arg != tc.expected {
+			t.Fatalf("%s (hvf %t): expected %q, got %#v", tc.accelerator, tc.hvf, tc.expected, arg)
+		}
+	}
+}
+
+func TestStepConfigureAccelerator_hvfUnavailable(t *testing.T) {
+	state := testState(t)
+	step := &StepConfigureAccelerator{
+		Accelerator:  AcceleratorHVF,
+		VMArch:       "x86_64",
+		hvfAvailable: testHVF(false),
+	}
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("accelQemuArg"); ok {
+		t.Fatal("should not set the accelerator")
+	}
+}
+
+func TestStepConfigureAccelerator_unset(t *testing.T) {
+	state := testState(t)
+	step := &StepConfigureAccelerator{
+		hvfAvailable: func(string) (bool, string) {
+			t.Fatal("should not check for hvf")
+			return false, ""
+		},
+	}
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("accelQemuArg"); ok {
+		t.Fatal("should not set the accelerator")
+	}
+}
+
+func TestHvfAvailable_archMismatch(t *testing.T) {
+	guest := "aarch64"
+	if hostQemuArch() == guest {
+		guest = "x86_64"
+	}
+	if ok, reason := hvfAvailable(guest); ok || reason == "" {
+		t.Fatalf("should not run a foreign guest with hvf: %t %q", ok, reason)
+	}
+}
+
+func TestHostArch(t *testing.T) {
+	sysctl := func(value string, err error) func(string) (string, error) {
+		return func(name string) (string, error) {
+			if name != "hw.optional.arm64" {
+				t.Fatalf("bad sysctl: %s", name)
+			}
+			return value, err
+		}
+	}
+
+	// An Intel build under Rosetta runs on Apple Silicon.
+	if arch := hostArch("amd64", sysctl("1", nil)); arch != "arm64" {
+		t.Fatalf("should see through Rosetta: %s", arch)
+	}
+	if arch := hostArch("amd64", sysctl("0", nil)); arch != "amd64" {
+		t.Fatalf("bad arch on an Intel Mac: %s", arch)
+	}
+	if arch := hostArch("amd64", sysctl("", errors.New("unknown oid"))); arch != "amd64" {
+		t.Fatalf("bad arch without the sysctl: %s", arch)
+	}
+	if arch := hostArch("arm64", nil); arch != "arm64" {
+		t.Fatalf("bad native arch: %s", arch)
+	}
+}
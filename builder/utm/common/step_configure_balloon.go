@@ -0,0 +1,54 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+// StepConfigureBalloon picks the virtio-balloon device for the VM
+// architecture. A machine that can't take one only gets a warning, since
+// the VM runs the same without it. It must run before
+// StepConfigureQemuArgs, which adds the argument.
+//
+// Uses:
+//
+//	ui packersdk.Ui
+//
+// Produces:
+//
+//	balloonQemuArg string - The -device argument for the balloon.
+type StepConfigureBalloon struct {
+	Enabled bool
+	VMArch  string
+}
+
+func (s *StepConfigureBalloon) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	if !s.Enabled {
+		log.Println("[INFO] No balloon requested, skipping...")
+		return multistep.ActionContinue
+	}
+
+	ui, err := getUI(state)
+	if err != nil {
+		return haltWithError(state, nil, err)
+	}
+
+	arg, ok := BalloonQemuArg(s.VMArch)
+	if !ok {
+		ui.Error(fmt.Sprintf(
+			"Warning: the %s machine has no bus for a virtio-balloon device, building without one", s.VMArch))
+		return multistep.ActionContinue
+	}
+
+	ui.Say("Adding a virtio-balloon device")
+	state.Put("balloonQemuArg", arg)
+	return multistep.ActionContinue
+}
+
+func (s *StepConfigureBalloon) Cleanup(state multistep.StateBag) {}
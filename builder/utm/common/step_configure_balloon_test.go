@@ -0,0 +1,58 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+func TestStepConfigureBalloon_impl(t *testing.T) {
+	var _ multistep.Step = new(StepConfigureBalloon)
+}
+
+func TestStepConfigureBalloon_disabled(t *testing.T) {
+	state := testState(t)
+	step := &StepConfigureBalloon{VMArch: "aarch64"}
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("balloonQemuArg"); ok {
+		t.Fatal("should not add a balloon")
+	}
+}
+
+func TestStepConfigureBalloon(t *testing.T) {
+	state := testState(t)
+	step := &StepConfigureBalloon{Enabled: true, VMArch: "aarch64"}
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if arg := state.Get("balloonQemuArg"); arg != "-device virtio-balloon-pci,free-page-reporting=on" {
+		t.Fatalf("bad balloonQemuArg: %#v", arg)
+	}
+}
+
+func TestStepConfigureBalloon_unsupported(t *testing.T) {
+	state := testState(t)
+	step := &StepConfigureBalloon{Enabled: true, VMArch: "m68k"}
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("should not fail the build: %#v", action)
+	}
+	if _, ok := state.GetOk("balloonQemuArg"); ok {
+		t.Fatal("should not add a balloon")
+	}
+	out := state.Get("ui").(*packersdk.BasicUi).Writer.(*bytes.Buffer).String()
+	if !strings.Contains(out, "Warning: the m68k machine") {
+		t.Fatalf("should warn: %q", out)
+	}
+}
@@ -0,0 +1,56 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+// StepConfigureCPU turns cpu_model and cpu_features into the -cpu argument
+// of the VM. Any -cpu in the user's qemuargs was already merged into them
+// when the config was prepared. It must run after StepConfigureAccelerator,
+// to catch host being used once auto fell back to tcg, and before
+// StepConfigureQemuArgs, which adds the argument.
+//
+// Uses:
+//
+//	accelerator string (optional)
+//	ui          packersdk.Ui
+//
+// Produces:
+//
+//	cpuQemuArg string - The -cpu argument for the VM.
+type StepConfigureCPU struct {
+	Model    string
+	Features []string
+}
+
+func (s *StepConfigureCPU) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	if s.Model == "" {
+		log.Println("[INFO] No cpu_model configured, leaving it to UTM...")
+		return multistep.ActionContinue
+	}
+
+	ui, err := getUI(state)
+	if err != nil {
+		return haltWithError(state, nil, err)
+	}
+
+	if s.Model == CPUModelHost && state.Get("accelerator") == AcceleratorTCG {
+		return haltWithError(state, ui, errors.New(
+			"cpu_model host needs the hvf accelerator, but the VM runs with tcg; "+
+				"use cpu_model max to build on hosts without hvf"))
+	}
+
+	ui.Say(fmt.Sprintf("Using the %s CPU model", s.Model))
+	state.Put("cpuQemuArg", CPUQemuArg(s.Model, s.Features))
+	return multistep.ActionContinue
+}
+
+func (s *StepConfigureCPU) Cleanup(state multistep.StateBag) {}
@@ -0,0 +1,55 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+func TestStepConfigureCPU_impl(t *testing.T) {
+	var _ multistep.Step = new(StepConfigureCPU)
+}
+
+func TestStepConfigureCPU(t *testing.T) {
+	state := testState(t)
+	step := &StepConfigureCPU{
+		Model:    "max",
+		Features: []string{"+aes", "sve=off"},
+	}
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if arg := state.Get("cpuQemuArg"); arg != "-cpu max,+aes,sve=off" {
+		t.Fatalf("bad cpu arg: %#v", arg)
+	}
+}
+
+func TestStepConfigureCPU_unset(t *testing.T) {
+	state := testState(t)
+	step := &StepConfigureCPU{}
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("cpuQemuArg"); ok {
+		t.Fatal("should not set the cpu arg")
+	}
+}
+
+func TestStepConfigureCPU_hostWithTCG(t *testing.T) {
+	state := testState(t)
+	state.Put("accelerator", AcceleratorTCG)
+	step := &StepConfigureCPU{Model: CPUModelHost}
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("error"); !ok {
+		t.Fatal("should have error")
+	}
+}
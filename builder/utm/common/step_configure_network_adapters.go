@@ -0,0 +1,78 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+// StepConfigureNetworkAdapters replaces the network adapters of the VM with
+// the configured ones. UTM takes the mode, MAC address and bridged host
+// interface of an adapter through AppleScript, so no QEMU arguments are
+// needed. It must run before StepPortForwarding, which then forwards the
+// communicator port on the chosen adapter instead of adding its own.
+//
+// Uses:
+//
+//	driver Driver
+//	ui packersdk.Ui
+//	vmId string
+//
+// Produces:
+//
+//	comm_adapter_index int - The adapter the communicator port is forwarded on.
+type StepConfigureNetworkAdapters struct {
+	Adapters            []NetworkAdapter
+	CommunicatorAdapter *int
+}
+
+func (s *StepConfigureNetworkAdapters) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	if len(s.Adapters) == 0 {
+		log.Println("[INFO] No network adapters configured, skipping...")
+		return multistep.ActionContinue
+	}
+
+	ui, err := getUI(state)
+	if err != nil {
+		return haltWithError(state, nil, err)
+	}
+	driver, err := getDriver(state)
+	if err != nil {
+		return haltWithError(state, ui, err)
+	}
+	vmId, err := GetVMID(state)
+	if err != nil {
+		return haltWithError(state, ui, err)
+	}
+
+	ui.Say(fmt.Sprintf("Configuring %d network adapters...", len(s.Adapters)))
+	if _, err := driver.ExecuteOsaScript(ctx, "clear_network_interfaces.applescript", vmId); err != nil {
+		return haltWithError(state, ui, fmt.Errorf("error clearing network interfaces: %s", err))
+	}
+
+	for i, adapter := range s.Adapters {
+		command := []string{"add_network_interface.applescript", vmId, networkModeCodes[adapter.Mode]}
+		if adapter.MAC != "" {
+			command = append(command, "--address", adapter.MAC)
+		}
+		if adapter.BridgeInterface != "" {
+			command = append(command, "--host-interface", adapter.BridgeInterface)
+		}
+		log.Printf("Adding network adapter %d: %s", i, adapter.Mode)
+		if _, err := driver.ExecuteOsaScript(ctx, command...); err != nil {
+			return haltWithError(state, ui, fmt.Errorf("error adding network adapter %d: %s", i, err))
+		}
+	}
+
+	if s.CommunicatorAdapter != nil {
+		state.Put("comm_adapter_index", *s.CommunicatorAdapter)
+	}
+	return multistep.ActionContinue
+}
+
+func (s *StepConfigureNetworkAdapters) Cleanup(state multistep.StateBag) {}
@@ -0,0 +1,81 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+func TestStepConfigureNetworkAdapters_impl(t *testing.T) {
+	var _ multistep.Step = new(StepConfigureNetworkAdapters)
+}
+
+func TestStepConfigureNetworkAdapters_none(t *testing.T) {
+	state := testState(t)
+	step := new(StepConfigureNetworkAdapters)
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	driver := state.Get("driver").(*DriverMock)
+	if len(driver.ExecuteOsaCalls) != 0 {
+		t.Fatalf("should not change the adapters: %#v", driver.ExecuteOsaCalls)
+	}
+	if index := CommAdapterIndex(state); index != DefaultCommAdapterIndex {
+		t.Fatalf("bad comm adapter index: %d", index)
+	}
+}
+
+func TestStepConfigureNetworkAdapters(t *testing.T) {
+	state := testState(t)
+	index := 2
+	step := &StepConfigureNetworkAdapters{
+		Adapters: []NetworkAdapter{
+			{Mode: NetworkModeShared},
+			{Mode: NetworkModeBridged, MAC: "52:54:00:12:34:56", BridgeInterface: "en0"},
+			{Mode: NetworkModeEmulated},
+		},
+		CommunicatorAdapter: &index,
+	}
+	state.Put("vmId", "foo")
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	driver := state.Get("driver").(*DriverMock)
+	expected := [][]string{
+		{"clear_network_interfaces.applescript", "foo"},
+		{"add_network_interface.applescript", "foo", "ShRd"},
+		{"add_network_interface.applescript", "foo", "BrGd", "--address", "52:54:00:12:34:56", "--host-interface", "en0"},
+		{"add_network_interface.applescript", "foo", "EmUd"},
+	}
+	if !reflect.DeepEqual(driver.ExecuteOsaCalls, expected) {
+		t.Fatalf("bad calls: %#v", driver.ExecuteOsaCalls)
+	}
+	if index := CommAdapterIndex(state); index != 2 {
+		t.Fatalf("bad comm adapter index: %d", index)
+	}
+}
+
+func TestStepConfigureNetworkAdapters_error(t *testing.T) {
+	state := testState(t)
+	step := &StepConfigureNetworkAdapters{Adapters: []NetworkAdapter{{Mode: NetworkModeHost}}}
+	state.Put("vmId", "foo")
+
+	driver := state.Get("driver").(*DriverMock)
+	driver.ExecuteOsaErrs = []error{nil, errors.New("UTM error")}
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("error"); !ok {
+		t.Fatal("should have error")
+	}
+}
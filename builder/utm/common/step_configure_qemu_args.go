@@ -10,36 +10,75 @@ import (
 	"strings"
 
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
-	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 )
 
-// StepConfigureQemuArgs adds user-specified QEMU additional arguments to the VM.
+// StepConfigureQemuArgs adds user-specified QEMU additional arguments to the VM,
+// after the accelerator and CPU picked by StepConfigureAccelerator and
+// StepConfigureCPU and the RTCArg clock if there are any, and followed by the virtio-rng device
+// when RNGDevice is set and the balloon picked by StepConfigureBalloon. A
+// VMArch machine that can't take a virtio-rng device only gets a warning.
 // These args persist in the exported VM (they are intentional configuration).
 //
 // Uses:
 //
-//	driver Driver
-//	ui     packersdk.Ui
-//	vmId   string
+//	accelQemuArg   string (optional)
+//	cpuQemuArg     string (optional)
+//	balloonQemuArg string (optional)
+//	driver         Driver
+//	ui             packersdk.Ui
+//	vmId           string
 type StepConfigureQemuArgs struct {
 	QemuArgs [][]string
+	// RNGDevice adds the RNGQemuArg of VMArch after the user's arguments.
+	RNGDevice bool
+	VMArch    string
+	// RTCArg is the -rtc argument from QemuConfig.RTCQemuArg, if any.
+	RTCArg string
 }
 
 func (s *StepConfigureQemuArgs) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
-	if len(s.QemuArgs) == 0 {
+	accelArg, _ := state.Get("accelQemuArg").(string)
+	cpuArg, _ := state.Get("cpuQemuArg").(string)
+	balloonArg, _ := state.Get("balloonQemuArg").(string)
+	if len(s.QemuArgs) == 0 && accelArg == "" && cpuArg == "" && s.RTCArg == "" && !s.RNGDevice && balloonArg == "" {
 		log.Println("[INFO] No user QEMU args to configure, skipping...")
 		return multistep.ActionContinue
 	}
 
-	driver := state.Get("driver").(Driver)
-	ui := state.Get("ui").(packersdk.Ui)
-	vmId := state.Get("vmId").(string)
+	ui, err := getUI(state)
+	if err != nil {
+		return haltWithError(state, nil, err)
+	}
+	driver, err := getDriver(state)
+	if err != nil {
+		return haltWithError(state, ui, err)
+	}
+	vmId, err := GetVMID(state)
+	if err != nil {
+		return haltWithError(state, ui, fmt.Errorf("cannot configure QEMU args: %w", err))
+	}
 
 	// Join each inner []string into a single QEMU arg string
 	var qemuArgStrings []string
+	for _, arg := range []string{accelArg, cpuArg, s.RTCArg} {
+		if arg != "" {
+			qemuArgStrings = append(qemuArgStrings, arg)
+		}
+	}
 	for _, args := range s.QemuArgs {
 		qemuArgStrings = append(qemuArgStrings, strings.Join(args, " "))
 	}
+	if s.RNGDevice {
+		if arg, ok := RNGQemuArg(s.VMArch); ok {
+			qemuArgStrings = append(qemuArgStrings, arg)
+		} else {
+			ui.Error(fmt.Sprintf(
+				"Warning: the %s machine has no bus for a virtio-rng device, building without one", s.VMArch))
+		}
+	}
+	if balloonArg != "" {
+		qemuArgStrings = append(qemuArgStrings, balloonArg)
+	}
 
 	ui.Say(fmt.Sprintf("Adding %d user QEMU additional argument(s)...", len(qemuArgStrings)))
 	for _, arg := range qemuArgStrings {
@@ -52,7 +91,7 @@ func (s *StepConfigureQemuArgs) Run(ctx context.Context, state multistep.StateBa
 	}
 	addQemuArgsCommand = append(addQemuArgsCommand, qemuArgStrings...)
 
-	_, err := driver.ExecuteOsaScript(addQemuArgsCommand...)
+	_, err = driver.ExecuteOsaScript(ctx, addQemuArgsCommand...)
 	if err != nil {
 		err := fmt.Errorf("error adding user QEMU additional arguments: %s", err)
 		state.Put("error", err)
@@ -5,7 +5,9 @@ package common
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
@@ -99,3 +101,182 @@ func TestStepConfigureQemuArgs_driverError(t *testing.T) {
 		t.Fatal("should have error")
 	}
 }
+
+func TestStepConfigureQemuArgs_accelerator(t *testing.T) {
+	state := testState(t)
+	state.Put("vmId", "test-vm-id")
+	state.Put("accelQemuArg", "-accel tcg")
+
+	step := &StepConfigureQemuArgs{}
+
+	action := step.Run(context.Background(), state)
+	if action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	driver := state.Get("driver").(*DriverMock)
+	if len(driver.ExecuteOsaCalls) != 1 {
+		t.Fatalf("expected 1 ExecuteOsaScript call, got %d", len(driver.ExecuteOsaCalls))
+	}
+	call := driver.ExecuteOsaCalls[0]
+	if len(call) != 4 || call[3] != "-accel tcg" {
+		t.Fatalf("should add the accelerator: %#v", call)
+	}
+}
+
+func TestStepConfigureQemuArgs_rtc(t *testing.T) {
+	state := testState(t)
+	state.Put("vmId", "test-vm-id")
+
+	step := &StepConfigureQemuArgs{
+		QemuArgs: [][]string{{"-smp", "2"}},
+		RTCArg:   "-rtc base=localtime",
+	}
+
+	action := step.Run(context.Background(), state)
+	if action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	driver := state.Get("driver").(*DriverMock)
+	call := driver.ExecuteOsaCalls[0]
+	if len(call) != 5 || call[3] != "-rtc base=localtime" || call[4] != "-smp 2" {
+		t.Fatalf("should add the clock before the user args: %#v", call)
+	}
+}
+
+func TestStepConfigureQemuArgs_missingVMID(t *testing.T) {
+	state := testState(t)
+
+	step := &StepConfigureQemuArgs{
+		QemuArgs: [][]string{
+			{"-cpu", "host"},
+		},
+	}
+
+	action := step.Run(context.Background(), state)
+	if action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	err, ok := state.Get("error").(error)
+	if !ok {
+		t.Fatal("should have error")
+	}
+	if !errors.Is(err, ErrVMNotCreated) {
+		t.Fatalf("should be ErrVMNotCreated, got: %s", err)
+	}
+	if !strings.Contains(err.Error(), "cannot configure QEMU args") {
+		t.Fatalf("bad error: %s", err)
+	}
+
+	driver := state.Get("driver").(*DriverMock)
+	if len(driver.ExecuteOsaCalls) != 0 {
+		t.Fatalf("should not have called ExecuteOsaScript, got: %#v", driver.ExecuteOsaCalls)
+	}
+}
+
+func TestStepConfigureQemuArgs_rngDevice(t *testing.T) {
+	state := testState(t)
+	state.Put("vmId", "test-vm-id")
+
+	step := &StepConfigureQemuArgs{
+		QemuArgs:  [][]string{{"-device", "virtio-balloon"}},
+		RNGDevice: true,
+		VMArch:    "aarch64",
+	}
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	driver := state.Get("driver").(*DriverMock)
+	if len(driver.ExecuteOsaCalls) != 1 {
+		t.Fatalf("expected 1 ExecuteOsaScript call, got %d", len(driver.ExecuteOsaCalls))
+	}
+	call := driver.ExecuteOsaCalls[0]
+	if len(call) != 5 || call[3] != "-device virtio-balloon" || call[4] != "-device virtio-rng-pci" {
+		t.Fatalf("should add the RNG device after the user's args: %#v", call)
+	}
+	if args := state.Get("userQemuArgs").([]string); args[len(args)-1] != "-device virtio-rng-pci" {
+		t.Fatalf("should keep the RNG device for later steps: %#v", args)
+	}
+}
+
+func TestStepConfigureQemuArgs_rngDeviceArch(t *testing.T) {
+	state := testState(t)
+	state.Put("vmId", "test-vm-id")
+
+	// s390x machines have a CCW bus rather than PCI
+	step := &StepConfigureQemuArgs{RNGDevice: true, VMArch: "s390x"}
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	driver := state.Get("driver").(*DriverMock)
+	if call := driver.ExecuteOsaCalls[0]; len(call) != 4 || call[3] != "-device virtio-rng-ccw" {
+		t.Fatalf("should add the CCW device: %#v", call)
+	}
+
+	// Machines with no bus for it build without one
+	state = testState(t)
+	state.Put("vmId", "test-vm-id")
+	step = &StepConfigureQemuArgs{RNGDevice: true, VMArch: "m68k"}
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	driver = state.Get("driver").(*DriverMock)
+	if call := driver.ExecuteOsaCalls[0]; len(call) != 3 {
+		t.Fatalf("should not add a device: %#v", call)
+	}
+}
+
+func TestStepConfigureQemuArgs_balloon(t *testing.T) {
+	state := testState(t)
+	state.Put("vmId", "test-vm-id")
+	state.Put("balloonQemuArg", "-device virtio-balloon-pci,free-page-reporting=on")
+
+	step := &StepConfigureQemuArgs{}
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	call := state.Get("driver").(*DriverMock).ExecuteOsaCalls[0]
+	if len(call) != 4 || call[3] != "-device virtio-balloon-pci,free-page-reporting=on" {
+		t.Fatalf("should add the balloon: %#v", call)
+	}
+}
+
+func TestStepConfigureQemuArgs_disableDefaultAccelerator(t *testing.T) {
+	c := &QemuConfig{
+		DisableDefaultAccelerator: true,
+		QemuArgs:                  [][]string{{"-device", "virtio-net-pci"}},
+	}
+	if _, errs := c.Prepare(nil); len(errs) > 0 {
+		t.Fatalf("err: %#v", errs)
+	}
+
+	state := testState(t)
+	state.Put("vmId", "test-vm-id")
+	steps := []multistep.Step{
+		&StepConfigureAccelerator{
+			Accelerator:  c.Accelerator,
+			VMArch:       "aarch64",
+			hvfAvailable: testHVF(true),
+		},
+		&StepConfigureQemuArgs{QemuArgs: c.QemuArgs},
+	}
+	for _, step := range steps {
+		if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+			t.Fatalf("bad action: %#v", action)
+		}
+	}
+
+	call := state.Get("driver").(*DriverMock).ExecuteOsaCalls[0]
+	if call[3] != "-accel tcg" {
+		t.Fatalf("should run the VM with tcg: %#v", call)
+	}
+	for _, arg := range call {
+		if strings.Contains(arg, AcceleratorHVF) {
+			t.Fatalf("should not pass hvf to the VM: %#v", call)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/hashicorp/packer-plugin-sdk/net"
+)
+
+// The ports the QEMU monitor can listen on. The SDK locks the port it
+// picks, so parallel builds never get the same one.
+const (
+	qemuMonitorPortMin = 5700
+	qemuMonitorPortMax = 5800
+)
+
+// StepConfigureQemuMonitor adds a QMP monitor on a local TCP port to the VM
+// and registers it for Driver.MonitorCommand. A TCP port is used rather than
+// a unix socket since the sandboxed QEMU of UTM can listen on ports, as it
+// does for VNC, but not create sockets outside of its container. The
+// monitor is a build-time argument, removed before export.
+//
+// Uses:
+//
+//	driver Driver
+//	ui     packersdk.Ui
+//	vmId   string
+//
+// Produces:
+//
+//	qemu_monitor_address string - The host:port of the monitor.
+type StepConfigureQemuMonitor struct {
+	Enabled bool
+
+	l    *net.Listener
+	vmId string
+}
+
+func (s *StepConfigureQemuMonitor) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	if !s.Enabled {
+		log.Println("[INFO] No QEMU monitor requested, skipping...")
+		return multistep.ActionContinue
+	}
+
+	ui, err := getUI(state)
+	if err != nil {
+		return haltWithError(state, nil, err)
+	}
+	driver, err := getDriver(state)
+	if err != nil {
+		return haltWithError(state, ui, err)
+	}
+	vmId, err := GetVMID(state)
+	if err != nil {
+		return haltWithError(state, ui, err)
+	}
+
+	s.l, err = net.ListenRangeConfig{
+		Addr:    "127.0.0.1",
+		Min:     qemuMonitorPortMin,
+		Max:     qemuMonitorPortMax,
+		Network: "tcp",
+	}.Listen(ctx)
+	if err != nil {
+		return haltWithError(state, ui, fmt.Errorf("error finding a QEMU monitor port: %s", err))
+	}
+	_ = s.l.Listener.Close() // free port, but don't unlock lock file
+	addr := fmt.Sprintf("127.0.0.1:%d", s.l.Port)
+
+	monitorQemuArg := fmt.Sprintf("%s tcp:%s,server=on,wait=off", QemuFlagQMP, addr)
+	ui.Say(fmt.Sprintf("Adding a QEMU monitor on %s...", addr))
+	if _, err := driver.ExecuteOsaScript(ctx, "add_qemu_additional_args.applescript", vmId, "--args", monitorQemuArg); err != nil {
+		return haltWithError(state, ui, fmt.Errorf("error adding the QEMU monitor: %s", err))
+	}
+
+	buildTimeArgs, _ := state.Get("buildTimeQemuArgs").([]string)
+	buildTimeArgs = append(buildTimeArgs, monitorQemuArg)
+	state.Put("buildTimeQemuArgs", buildTimeArgs)
+
+	s.vmId = vmId
+	RegisterQemuMonitor(vmId, addr)
+	state.Put(StateQemuMonitorAddress, addr)
+
+	return multistep.ActionContinue
+}
+
+func (s *StepConfigureQemuMonitor) Cleanup(state multistep.StateBag) {
+	if s.vmId != "" {
+		UnregisterQemuMonitor(s.vmId)
+	}
+	if s.l != nil {
+		if err := s.l.Close(); err != nil {
+			log.Printf("failed to unlock port lockfile: %v", err)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+func TestStepConfigureQemuMonitor_impl(t *testing.T) {
+	var _ multistep.Step = new(StepConfigureQemuMonitor)
+}
+
+func TestStepConfigureQemuMonitor_disabled(t *testing.T) {
+	state := testState(t)
+	state.Put("vmId", "test-vm-id")
+
+	step := new(StepConfigureQemuMonitor)
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	driver := state.Get("driver").(*DriverMock)
+	if len(driver.ExecuteOsaCalls) != 0 {
+		t.Fatalf("should not have called ExecuteOsaScript, got: %#v", driver.ExecuteOsaCalls)
+	}
+	if _, ok := state.GetOk(StateQemuMonitorAddress); ok {
+		t.Fatal("should not have a monitor address")
+	}
+}
+
+func TestStepConfigureQemuMonitor(t *testing.T) {
+	state := testState(t)
+	state.Put("vmId", "test-vm-id")
+	state.Put("buildTimeQemuArgs", []string{"-vnc 127.0.0.1:0"})
+
+	step := &StepConfigureQemuMonitor{Enabled: true}
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("error"); ok {
+		t.Fatal("should NOT have error")
+	}
+
+	addr := state.Get(StateQemuMonitorAddress).(string)
+	if !strings.HasPrefix(addr, "127.0.0.1:") {
+		t.Fatalf("bad address: %s", addr)
+	}
+	monitorArg := "-qmp tcp:" + addr + ",server=on,wait=off"
+
+	driver := state.Get("driver").(*DriverMock)
+	expected := [][]string{{"add_qemu_additional_args.applescript", "test-vm-id", "--args", monitorArg}}
+	if !reflect.DeepEqual(driver.ExecuteOsaCalls, expected) {
+		t.Fatalf("bad calls: %#v", driver.ExecuteOsaCalls)
+	}
+	buildTimeArgs := state.Get("buildTimeQemuArgs").([]string)
+	if !reflect.DeepEqual(buildTimeArgs, []string{"-vnc 127.0.0.1:0", monitorArg}) {
+		t.Fatalf("bad build-time args: %#v", buildTimeArgs)
+	}
+
+	if registered, err := qemuMonitorAddress("test-vm-id"); err != nil || registered != addr {
+		t.Fatalf("monitor not registered: %q, %v", registered, err)
+	}
+	step.Cleanup(state)
+	if _, err := qemuMonitorAddress("test-vm-id"); err == nil {
+		t.Fatal("monitor should be unregistered after cleanup")
+	}
+}
+
+func TestStepConfigureQemuMonitor_driverError(t *testing.T) {
+	state := testState(t)
+	state.Put("vmId", "test-vm-id")
+
+	driver := state.Get("driver").(*DriverMock)
+	driver.ExecuteOsaErrs = []error{errors.New("applescript failed")}
+
+	step := &StepConfigureQemuMonitor{Enabled: true}
+	defer step.Cleanup(state)
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("error"); !ok {
+		t.Fatal("should have error")
+	}
+	if _, err := qemuMonitorAddress("test-vm-id"); err == nil {
+		t.Fatal("monitor should not be registered")
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+// StepCopyBundleFiles copies extra_bundle_files into the Extras directory of
+// the exported bundle. The artifact lists every file of the output
+// directory, so the copies show up in its files.
+//
+// Uses:
+//
+//	exportPath string
+//	ui packersdk.Ui
+//
+// Produces:
+//
+//	<nothing>
+type StepCopyBundleFiles struct {
+	Files      []BundleFile
+	SkipExport bool
+}
+
+func (s *StepCopyBundleFiles) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	if len(s.Files) == 0 || s.SkipExport {
+		return multistep.ActionContinue
+	}
+
+	ui, err := getUI(state)
+	if err != nil {
+		return haltWithError(state, nil, err)
+	}
+	exportPath, ok := state.Get("exportPath").(string)
+	if !ok {
+		return haltWithError(state, ui, errors.New("no exported VM to copy extra_bundle_files into"))
+	}
+	// UTM 4.5 can't export, and leaves it to the user to do it by hand.
+	if info, err := os.Stat(exportPath); err != nil || !info.IsDir() {
+		return haltWithError(state, ui, fmt.Errorf(
+			"can't copy extra_bundle_files, the exported bundle %s is missing", exportPath))
+	}
+
+	ui.Say(fmt.Sprintf("Copying %d file(s) into the %s directory of the exported bundle...",
+		len(s.Files), BundleExtrasDir))
+	for _, file := range s.Files {
+		dst := filepath.Join(exportPath, BundleExtrasDir, file.Destination)
+		if err := copyBundleFile(file.Source, dst); err != nil {
+			return haltWithError(state, ui, fmt.Errorf("error copying %s into the bundle: %s", file.Source, err))
+		}
+	}
+	return multistep.ActionContinue
+}
+
+// copyBundleFile copies the regular file src to dst, creating the parent
+// directories of dst.
+func copyBundleFile(src string, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+func (s *StepCopyBundleFiles) Cleanup(state multistep.StateBag) {}
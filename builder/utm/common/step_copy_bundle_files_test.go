@@ -0,0 +1,57 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+func TestStepCopyBundleFiles_impl(t *testing.T) {
+	var _ multistep.Step = new(StepCopyBundleFiles)
+}
+
+func TestStepCopyBundleFiles(t *testing.T) {
+	state := testState(t)
+	bundle := testExportedBundle(t, false)
+	state.Put("exportPath", bundle)
+
+	readme := filepath.Join(t.TempDir(), "README.md")
+	if err := os.WriteFile(readme, []byte("# image"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	step := &StepCopyBundleFiles{Files: []BundleFile{
+		{Source: readme, Destination: "README.md"},
+		{Source: readme, Destination: filepath.Join("docs", "image.md")},
+	}}
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v %v", action, state.Get("error"))
+	}
+	for _, name := range []string{"README.md", filepath.Join("docs", "image.md")} {
+		content, err := os.ReadFile(filepath.Join(bundle, BundleExtrasDir, name))
+		if err != nil || string(content) != "# image" {
+			t.Fatalf("should copy %s into the bundle: %q %v", name, content, err)
+		}
+	}
+}
+
+func TestStepCopyBundleFiles_noBundle(t *testing.T) {
+	state := testState(t)
+	state.Put("exportPath", filepath.Join(t.TempDir(), "vm.utm"))
+
+	step := &StepCopyBundleFiles{Files: []BundleFile{{Source: "README.md", Destination: "README.md"}}}
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	err, ok := state.GetOk("error")
+	if !ok || !strings.Contains(err.(error).Error(), "is missing") {
+		t.Fatalf("should report the missing bundle: %v", err)
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"regexp"
 	"strconv"
 
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
@@ -18,21 +17,29 @@ import (
 //	vmId string - The UUID of the VM
 type StepCreateVM struct {
 	// takes
-	VMName         string
-	VMBackend      string
-	VMArch         string
-	VMIcon         string
-	HWConfig       HWConfig
-	UEFIBoot       bool
-	Hypervisor     bool
+	VMName     string
+	VMBackend  string
+	VMArch     string
+	VMIcon     string
+	HWConfig   HWConfig
+	UEFIBoot   bool
+	Hypervisor bool
+	// Rosetta shares Rosetta with the guest, for VMs on the apple backend.
+	Rosetta        bool
 	KeepRegistered bool
 	// produces
 	vmId string
 }
 
 func (s *StepCreateVM) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
-	driver := state.Get("driver").(Driver)
-	ui := state.Get("ui").(packersdk.Ui)
+	ui, err := getUI(state)
+	if err != nil {
+		return haltWithError(state, nil, err)
+	}
+	driver, err := getDriver(state)
+	if err != nil {
+		return haltWithError(state, ui, err)
+	}
 
 	// Create VM command
 	createCommand := []string{
@@ -43,7 +50,7 @@ func (s *StepCreateVM) Run(ctx context.Context, state multistep.StateBag) multis
 	}
 
 	ui.Say("Creating virtual machine...")
-	output, err := driver.ExecuteOsaScript(createCommand...)
+	output, err := driver.ExecuteOsaScriptOutput(ctx, nil, createCommand...)
 	if err != nil {
 		err := fmt.Errorf("error creating VM: %s", err)
 		state.Put("error", err)
@@ -51,21 +58,16 @@ func (s *StepCreateVM) Run(ctx context.Context, state multistep.StateBag) multis
 		return multistep.ActionHalt
 	}
 
-	// Regular expression to capture the VM UUID
-	re := regexp.MustCompile(`[0-9a-fA-F-]{36}`)
-	matches := re.FindStringSubmatch(output)
-	var vmId string
-	if len(matches) > 0 {
-		vmId = matches[0] // Capture the VM UUID
-		s.vmId = vmId
-		state.Put("vmName", s.VMName)
-		state.Put("vmId", s.vmId)
-	} else {
-		err := fmt.Errorf("error extracting VM ID from output: %s", output)
+	vmId, err := ParseUUID(output.Stdout)
+	if err != nil {
+		err := fmt.Errorf("error extracting VM ID from output: %s", output.Combined())
 		state.Put("error", err)
 		ui.Error(err.Error())
 		return multistep.ActionHalt
 	}
+	s.vmId = vmId
+	state.Put(StateVMName, s.VMName)
+	state.Put(StateVMID, s.vmId)
 
 	log.Printf("VM Id: %s", vmId)
 
@@ -75,12 +77,21 @@ func (s *StepCreateVM) Run(ctx context.Context, state multistep.StateBag) multis
 		"--cpus", strconv.Itoa(s.HWConfig.CpuCount),
 		"--memory", strconv.Itoa(s.HWConfig.MemorySize),
 		"--name", s.VMName,
-		"--uefi-boot", strconv.FormatBool(s.UEFIBoot),
-		"--use-hypervisor", strconv.FormatBool(s.Hypervisor),
+	}
+	// Apple Virtualization VMs always boot with UEFI and have no separate
+	// hypervisor setting.
+	if s.VMBackend != VMBackendCodes[VMBackendApple] {
+		customizeCommand = append(customizeCommand,
+			"--uefi-boot", strconv.FormatBool(s.UEFIBoot),
+			"--use-hypervisor", strconv.FormatBool(s.Hypervisor),
+		)
+	}
+	if s.Rosetta {
+		customizeCommand = append(customizeCommand, "--rosetta", "true")
 	}
 
 	ui.Say("Customizing virtual machine...")
-	_, err = driver.ExecuteOsaScript(customizeCommand...)
+	_, err = driver.ExecuteOsaScript(ctx, customizeCommand...)
 	if err != nil {
 		err := fmt.Errorf("error customizing VM: %s", err)
 		state.Put("error", err)
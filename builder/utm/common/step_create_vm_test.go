@@ -0,0 +1,83 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+func TestStepCreateVM_impl(t *testing.T) {
+	var _ multistep.Step = new(StepCreateVM)
+}
+
+func TestStepCreateVM(t *testing.T) {
+	state := testState(t)
+	driver := state.Get("driver").(*DriverMock)
+	driver.ExecuteOsaResult = "7FB247A3-DC9F-4A61-A123-0AEE1BEEC636"
+	step := &StepCreateVM{
+		VMName:    "foo",
+		VMBackend: "QeMu",
+		VMArch:    "aarch64",
+		HWConfig:  HWConfig{CpuCount: 2, MemorySize: 2048},
+	}
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if vmId, _ := GetVMID(state); vmId != "7FB247A3-DC9F-4A61-A123-0AEE1BEEC636" {
+		t.Fatalf("bad vmId: %s", vmId)
+	}
+	if len(driver.ExecuteOsaCalls) != 2 || driver.ExecuteOsaCalls[1][0] != "customize_vm.applescript" {
+		t.Fatalf("should create and customize the VM: %#v", driver.ExecuteOsaCalls)
+	}
+	for _, arg := range driver.ExecuteOsaCalls[1] {
+		if arg == "--rosetta" {
+			t.Fatalf("should leave Rosetta alone: %#v", driver.ExecuteOsaCalls[1])
+		}
+	}
+}
+
+func TestStepCreateVM_rosetta(t *testing.T) {
+	state := testState(t)
+	driver := state.Get("driver").(*DriverMock)
+	driver.ExecuteOsaResult = "7FB247A3-DC9F-4A61-A123-0AEE1BEEC636"
+	step := &StepCreateVM{VMName: "foo", VMBackend: "ApPl", VMArch: "aarch64", Rosetta: true}
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	customize := driver.ExecuteOsaCalls[1]
+	if customize[len(customize)-2] != "--rosetta" || customize[len(customize)-1] != "true" {
+		t.Fatalf("should turn on Rosetta: %#v", customize)
+	}
+}
+
+func TestStepCreateVM_apple(t *testing.T) {
+	state := testState(t)
+	driver := state.Get("driver").(*DriverMock)
+	driver.ExecuteOsaResult = "7FB247A3-DC9F-4A61-A123-0AEE1BEEC636"
+	step := &StepCreateVM{
+		VMName:     "foo",
+		VMBackend:  VMBackendCodes[VMBackendApple],
+		VMArch:     "aarch64",
+		UEFIBoot:   true,
+		Hypervisor: true,
+	}
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if backend := driver.ExecuteOsaCalls[0]; !slices.Contains(backend, "ApPl") {
+		t.Fatalf("should create an apple VM: %#v", backend)
+	}
+	for _, arg := range driver.ExecuteOsaCalls[1] {
+		if arg == "--uefi-boot" || arg == "--use-hypervisor" {
+			t.Fatalf("should not pass QEMU options: %#v", driver.ExecuteOsaCalls[1])
+		}
+	}
+}
@@ -0,0 +1,58 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"log"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/hashicorp/packer-plugin-sdk/multistep/commonsteps"
+)
+
+// This step packages the rendered autounattend.xml into its own ISO, so it
+// can be attached next to the cd_files ISO without replacing it.
+//
+// Produces:
+//
+//	windows_unattended_path string - The path to the answer file ISO.
+type StepCreateWindowsUnattended struct {
+	Content string
+
+	createCD *commonsteps.StepCreateCD
+}
+
+func (s *StepCreateWindowsUnattended) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	if s.Content == "" {
+		log.Println("No windows_unattended specified, skipping...")
+		return multistep.ActionContinue
+	}
+
+	// StepCreateCD always stores its result in cd_path, which belongs to
+	// cd_files, so make sure we don't clobber it.
+	cdPath, hasCDPath := state.GetOk("cd_path")
+
+	s.createCD = &commonsteps.StepCreateCD{
+		Content: map[string]string{WindowsUnattendedFileName: s.Content},
+		Label:   WindowsUnattendedLabel,
+	}
+	action := s.createCD.Run(ctx, state)
+
+	if action == multistep.ActionContinue {
+		state.Put("windows_unattended_path", state.Get("cd_path"))
+	}
+	if hasCDPath {
+		state.Put("cd_path", cdPath)
+	} else {
+		state.Remove("cd_path")
+	}
+
+	return action
+}
+
+func (s *StepCreateWindowsUnattended) Cleanup(state multistep.StateBag) {
+	if s.createCD != nil {
+		s.createCD.Cleanup(state)
+	}
+}
@@ -0,0 +1,32 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+func TestStepCreateWindowsUnattended_impl(t *testing.T) {
+	var _ multistep.Step = new(StepCreateWindowsUnattended)
+}
+
+func TestStepCreateWindowsUnattended_noContent(t *testing.T) {
+	state := testState(t)
+	state.Put("cd_path", "cd.iso")
+	step := new(StepCreateWindowsUnattended)
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("windows_unattended_path"); ok {
+		t.Fatal("should not produce windows_unattended_path")
+	}
+	if state.Get("cd_path") != "cd.iso" {
+		t.Fatalf("cd_path should be untouched: %#v", state.Get("cd_path"))
+	}
+	step.Cleanup(state)
+}
@@ -0,0 +1,64 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+// StepDetectGuestOS probes the guest over the communicator to find out which
+// OS it runs, so later steps can branch on it with GetGuestOS instead of
+// trusting the configuration. Detection is best effort: when no probe
+// recognizes the guest the build goes on without guest_os. It is skipped
+// when the communicator is none.
+//
+// Uses:
+//
+//	communicator packersdk.Communicator
+//	ui           packersdk.Ui
+//
+// Produces:
+//
+//	guest_os string - The guest OS, one of the GuestOS constants.
+type StepDetectGuestOS struct {
+	CommType string
+}
+
+func (s *StepDetectGuestOS) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	if s.CommType == "none" {
+		log.Println("[INFO] Communicator is none, not detecting the guest OS...")
+		return multistep.ActionContinue
+	}
+
+	ui, err := getUI(state)
+	if err != nil {
+		return haltWithError(state, nil, err)
+	}
+	comm, err := getCommunicator(state)
+	if err != nil {
+		return haltWithError(state, ui, err)
+	}
+
+	for _, probe := range guestOSProbes(s.CommType) {
+		guestOS, err := runGuestOSProbe(ctx, comm, probe)
+		if err != nil {
+			log.Printf("[DEBUG] Guest OS probe failed: %s", err)
+			continue
+		}
+		if guestOS != "" {
+			ui.Say(fmt.Sprintf("Detected guest OS: %s", guestOS))
+			state.Put(StateGuestOS, guestOS)
+			return multistep.ActionContinue
+		}
+	}
+
+	ui.Error("Warning: could not detect the guest OS, continuing without it")
+	return multistep.ActionContinue
+}
+
+func (s *StepDetectGuestOS) Cleanup(state multistep.StateBag) {}
@@ -0,0 +1,71 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+func TestStepDetectGuestOS_impl(t *testing.T) {
+	var _ multistep.Step = new(StepDetectGuestOS)
+}
+
+func TestStepDetectGuestOS(t *testing.T) {
+	cases := []struct {
+		commType string
+		stdout   string
+		command  string
+		expected string
+	}{
+		{"ssh", "Linux\n", "uname -s", GuestOSLinux},
+		{"ssh", "Darwin\n", "uname -s", GuestOSDarwin},
+		{"ssh", "OpenBSD\n", "uname -s", GuestOSOpenBSD},
+		{"winrm", "\r\nMicrosoft Windows [Version 10.0.22631.2861]\r\n", "cmd /c ver", GuestOSWindows},
+	}
+	for _, tc := range cases {
+		state := testState(t)
+		comm := &packersdk.MockCommunicator{StartStdout: tc.stdout}
+		state.Put("communicator", comm)
+
+		step := &StepDetectGuestOS{CommType: tc.commType}
+		if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+			t.Fatalf("%q: bad action: %#v", tc.stdout, action)
+		}
+		if guestOS := GetGuestOS(state); guestOS != tc.expected {
+			t.Fatalf("%q: expected %q, got %q", tc.stdout, tc.expected, guestOS)
+		}
+		if comm.StartCmd.Command != tc.command {
+			t.Fatalf("%q: should have run %q first, ran %q", tc.stdout, tc.command, comm.StartCmd.Command)
+		}
+	}
+}
+
+func TestStepDetectGuestOS_unknown(t *testing.T) {
+	state := testState(t)
+	state.Put("communicator", &packersdk.MockCommunicator{StartExitStatus: 127})
+
+	step := &StepDetectGuestOS{CommType: "ssh"}
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk(StateGuestOS); ok {
+		t.Fatal("should not set guest_os")
+	}
+}
+
+func TestStepDetectGuestOS_noCommunicator(t *testing.T) {
+	state := testState(t)
+
+	step := &StepDetectGuestOS{CommType: "none"}
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk(StateGuestOS); ok {
+		t.Fatal("should not set guest_os")
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/hashicorp/packer-plugin-sdk/multistep/commonsteps"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// This step resolves each additional ISO to a local path, downloading it
+// (and verifying its checksum) when needed.
+//
+// Uses:
+//
+//	ui packersdk.Ui
+//
+// Produces:
+//
+//	additional_iso_paths []string - Local path of each additional ISO, in
+//	  the same order as the configuration.
+type StepDownloadAdditionalISOs struct {
+	AdditionalISOs []AdditionalISO
+}
+
+func (s *StepDownloadAdditionalISOs) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	if len(s.AdditionalISOs) == 0 {
+		log.Println("No additional ISOs to retrieve, skipping...")
+		return multistep.ActionContinue
+	}
+
+	ui := state.Get("ui").(packersdk.Ui)
+
+	// StepDownload records the source it used in SourceImageURL, which
+	// belongs to the boot ISO, so make sure we don't clobber it.
+	sourceImageURL, hasSourceImageURL := state.GetOk("SourceImageURL")
+
+	paths := make([]string, 0, len(s.AdditionalISOs))
+	for i, iso := range s.AdditionalISOs {
+		if isLocalPath(iso.Url) && (iso.Checksum == "" || iso.Checksum == "none") {
+			if _, err := os.Stat(iso.Url); err != nil {
+				err := fmt.Errorf("error reading additional_isos[%d]: %s", i, err)
+				state.Put("error", err)
+				ui.Error(err.Error())
+				return multistep.ActionHalt
+			}
+			log.Printf("Using additional ISO %d in place: %s", i, iso.Url)
+			paths = append(paths, iso.Url)
+			continue
+		}
+
+		downStep := &commonsteps.StepDownload{
+			Checksum:    iso.Checksum,
+			Description: fmt.Sprintf("additional ISO %d", i),
+			ResultKey:   "additional_iso_path",
+			Url:         []string{iso.Url},
+			Extension:   "iso",
+		}
+		if action := downStep.Run(ctx, state); action != multistep.ActionContinue {
+			return action
+		}
+		paths = append(paths, state.Get("additional_iso_path").(string))
+	}
+
+	state.Remove("additional_iso_path")
+	if hasSourceImageURL {
+		state.Put("SourceImageURL", sourceImageURL)
+	} else {
+		state.Remove("SourceImageURL")
+	}
+
+	state.Put("additional_iso_paths", paths)
+	return multistep.ActionContinue
+}
+
+func (s *StepDownloadAdditionalISOs) Cleanup(state multistep.StateBag) {}
+
+// isLocalPath reports whether source is a plain path on the local file
+// system rather than a URL.
+func isLocalPath(source string) bool {
+	u, err := url.Parse(source)
+	if err != nil {
+		return true
+	}
+	return u.Scheme == ""
+}
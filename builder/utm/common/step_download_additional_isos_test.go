@@ -0,0 +1,120 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+func testISOFile(t *testing.T) (string, string) {
+	path := filepath.Join(t.TempDir(), "extra.iso")
+	content := []byte("additional iso")
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	sum := sha256.Sum256(content)
+	return path, hex.EncodeToString(sum[:])
+}
+
+func TestStepDownloadAdditionalISOs_impl(t *testing.T) {
+	var _ multistep.Step = new(StepDownloadAdditionalISOs)
+}
+
+func TestStepDownloadAdditionalISOs_none(t *testing.T) {
+	state := testState(t)
+	step := new(StepDownloadAdditionalISOs)
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("additional_iso_paths"); ok {
+		t.Fatal("should not set additional_iso_paths")
+	}
+}
+
+func TestStepDownloadAdditionalISOs_localInPlace(t *testing.T) {
+	state := testState(t)
+	path, _ := testISOFile(t)
+	step := &StepDownloadAdditionalISOs{
+		AdditionalISOs: []AdditionalISO{{Url: path}},
+	}
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	paths := state.Get("additional_iso_paths").([]string)
+	if len(paths) != 1 || paths[0] != path {
+		t.Fatalf("bad paths: %#v", paths)
+	}
+}
+
+func TestStepDownloadAdditionalISOs_localMissing(t *testing.T) {
+	state := testState(t)
+	step := &StepDownloadAdditionalISOs{
+		AdditionalISOs: []AdditionalISO{{Url: filepath.Join(t.TempDir(), "missing.iso")}},
+	}
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("error"); !ok {
+		t.Fatal("should have error")
+	}
+}
+
+func TestStepDownloadAdditionalISOs_checksum(t *testing.T) {
+	t.Setenv("PACKER_CACHE_DIR", t.TempDir())
+	state := testState(t)
+	state.Put("SourceImageURL", "boot.iso")
+	path, sum := testISOFile(t)
+	otherPath, _ := testISOFile(t)
+	step := &StepDownloadAdditionalISOs{
+		AdditionalISOs: []AdditionalISO{
+			{Url: path, Checksum: "sha256:" + sum},
+			{Url: otherPath},
+		},
+	}
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v: %s", action, state.Get("error"))
+	}
+	paths := state.Get("additional_iso_paths").([]string)
+	if len(paths) != 2 {
+		t.Fatalf("bad paths: %#v", paths)
+	}
+	if paths[0] != path {
+		t.Fatalf("bad path: %s", paths[0])
+	}
+	if paths[1] != otherPath {
+		t.Fatalf("bad path: %s", paths[1])
+	}
+	if state.Get("SourceImageURL") != "boot.iso" {
+		t.Fatalf("should keep SourceImageURL: %#v", state.Get("SourceImageURL"))
+	}
+}
+
+func TestStepDownloadAdditionalISOs_badChecksum(t *testing.T) {
+	t.Setenv("PACKER_CACHE_DIR", t.TempDir())
+	state := testState(t)
+	path, _ := testISOFile(t)
+	step := &StepDownloadAdditionalISOs{
+		AdditionalISOs: []AdditionalISO{
+			{Url: path, Checksum: "sha256:0000000000000000000000000000000000000000000000000000000000000000"},
+		},
+	}
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("error"); !ok {
+		t.Fatal("should have error")
+	}
+}
@@ -7,39 +7,60 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"strings"
 
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	"github.com/hashicorp/packer-plugin-sdk/multistep/commonsteps"
-	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
 )
 
-// UTM version to guest additions version map
-var additionsVersionMap = map[string]string{
-	"4.6.4": "0.229.2",
-}
+// StateGuestAdditionsVersion is the state key holding the version of the
+// guest additions the build downloads.
+const StateGuestAdditionsVersion = "guest_additions_version"
 
 type guestAdditionsUrlTemplate struct {
-	Version string
+	Version  string
+	Filename string
+	GuestOS  string
+	Arch     string
 }
 
-// This step uploads a file containing the UTM version, which
-// can be useful for various provisioning reasons.
+// This step downloads the guest additions ISO, or uses the one bundled with
+// UTM when no guest additions url is set. The guest_additions_url and
+// guest_additions_urls mirrors are tried in order, then the getutm.app
+// download, and the first that downloads and passes the checksum is used.
 //
 // Produces:
 //
-//	guest_additions_path string - Path to the guest additions.
+//	guest_additions_path    string - Path to the guest additions.
+//	guest_additions_version string - Version of the guest additions.
 type StepDownloadGuestAdditions struct {
-	GuestAdditionsMode       string
-	GuestAdditionsURL        string
-	GuestAdditionsSHA256     string
-	GuestAdditionsTargetPath string
-	Ctx                      interpolate.Context
+	GuestAdditionsMode           string
+	GuestAdditionsURL            string
+	GuestAdditionsURLs           []string
+	GuestAdditionsFilename       string
+	GuestAdditionsSHA256         string
+	GuestAdditionsTargetPath     string
+	RequireBundledGuestAdditions bool
+	// GuestOS is the guest OS the build looks like it is for, if it can be
+	// told before the guest boots, and VMArch the QEMU architecture.
+	GuestOS string
+	VMArch  string
+	Ctx     interpolate.Context
+
+	// runDownload runs a download, set by tests.
+	runDownload func(context.Context, multistep.StateBag, *commonsteps.StepDownload) multistep.StepAction
 }
 
 func (s *StepDownloadGuestAdditions) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
-	driver := state.Get("driver").(Driver)
-	ui := state.Get("ui").(packersdk.Ui)
+	ui, err := getUI(state)
+	if err != nil {
+		return haltWithError(state, nil, err)
+	}
+	driver, err := getDriver(state)
+	if err != nil {
+		return haltWithError(state, ui, err)
+	}
 
 	// If we've disabled guest additions, don't download
 	if s.GuestAdditionsMode == GuestAdditionsModeDisable {
@@ -47,6 +68,13 @@ func (s *StepDownloadGuestAdditions) Run(ctx context.Context, state multistep.St
 		return multistep.ActionContinue
 	}
 
+	// UTM only bundles the guest additions ISO since 4.6
+	if s.RequireBundledGuestAdditions {
+		if err := RequireUTMVersion(driver, "4.6.0", "require_bundled_guest_additions"); err != nil {
+			return haltWithError(state, ui, err)
+		}
+	}
+
 	// Get UTM version
 	version, err := driver.Version()
 	if err != nil {
@@ -54,85 +82,120 @@ func (s *StepDownloadGuestAdditions) Run(ctx context.Context, state multistep.St
 		return multistep.ActionHalt
 	}
 
-	if newVersion, ok := additionsVersionMap[version]; ok {
-		log.Printf("Rewriting guest additions version: %s to %s", version, newVersion)
-		version = newVersion
-	}
-
-	additionsName := fmt.Sprintf("utm-guest-tools-%s.iso", "latest")
-
-	// Use provided version or get it from getutm.app
-	var checksum string
+	version = guestAdditionsVersion(version, s.GuestOS, s.VMArch)
+	state.Put(StateGuestAdditionsVersion, version)
 
-	checksumType := "sha256"
-
-	// Initialize the template context so we can interpolate some variables..
-	s.Ctx.Data = &guestAdditionsUrlTemplate{
-		Version: version,
+	filename := s.GuestAdditionsFilename
+	if filename == "" {
+		filename = DefaultGuestAdditionsFilename
 	}
-
-	// Interpolate any user-variables specified within the guest_additions_url
-	url, err := interpolate.Render(s.GuestAdditionsURL, &s.Ctx)
+	additionsName, err := renderGuestAdditionsFilename(filename, &guestAdditionsUrlTemplate{
+		Version: version,
+		GuestOS: s.GuestOS,
+		Arch:    s.VMArch,
+	})
 	if err != nil {
-		err := fmt.Errorf("error preparing guest additions url: %s", err)
 		state.Put("error", err)
 		ui.Error(err.Error())
 		return multistep.ActionHalt
 	}
 
-	// If this resulted in an empty url, then ask the driver about it.
-	if url == "" {
+	// Initialize the template context so we can interpolate some variables..
+	s.Ctx.Data = &guestAdditionsUrlTemplate{
+		Version:  version,
+		Filename: additionsName,
+		GuestOS:  s.GuestOS,
+		Arch:     s.VMArch,
+	}
+
+	// Interpolate any user-variables specified within the guest additions
+	// urls, which are tried in order before the getutm.app download
+	var urls []string
+	for _, rawURL := range append([]string{s.GuestAdditionsURL}, s.GuestAdditionsURLs...) {
+		if rawURL == "" {
+			continue
+		}
+		url, err := interpolate.Render(rawURL, &s.Ctx)
+		if err != nil {
+			err := fmt.Errorf("error preparing guest additions url: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+		if url != "" {
+			urls = append(urls, url)
+		}
+	}
+	defaultURL := fmt.Sprintf("https://getutm.app/downloads/%s", additionsName)
+
+	// If this resulted in no url, then ask the driver about it.
+	if len(urls) == 0 {
 		log.Printf("guest_additions_url is blank; querying driver for iso.")
-		url, err = driver.GuestToolsIsoPath()
+		path, err := driver.GuestToolsIsoPath()
 
 		if err == nil {
-			checksumType = "none"
-		} else {
+			// The bundled ISO comes with UTM, so there's nothing to verify.
+			return s.download(ctx, state, path, "")
+		}
+		if s.RequireBundledGuestAdditions {
+			err := fmt.Errorf("bundled guest additions are required "+
+				"(require_bundled_guest_additions = true): %s", err)
+			state.Put("error", err)
 			ui.Error(err.Error())
-			url = fmt.Sprintf(
-				"https://getutm.app/downloads/%s", additionsName)
+			return multistep.ActionHalt
 		}
+		ui.Error(fmt.Sprintf("Bundled guest additions unavailable: %s", err))
+		ui.Say(fmt.Sprintf("Falling back to guest additions download from %s", defaultURL))
 	}
+	urls = append(urls, defaultURL)
 
-	// The driver couldn't even figure it out, so fail hard.
-	if url == "" {
-		err := fmt.Errorf("couldn't detect guest additions URL.\n" +
-			"Please specify `guest_additions_url` manually")
-		state.Put("error", err)
-		ui.Error(err.Error())
-		return multistep.ActionHalt
+	// Figure out a default checksum here
+	checksum := ""
+	if s.GuestAdditionsSHA256 != "" && s.GuestAdditionsSHA256 != "none" {
+		checksum = "sha256:" + s.GuestAdditionsSHA256
+	} else {
+		// Skip checksum verification for default guest additions ISO
+		// since UTM doesn't provide checksums and versions change frequently
+		log.Println("Skipping checksum verification for default guest additions ISO")
 	}
 
-	// Figure out a default checksum here
-	if checksumType != "none" {
-		if s.GuestAdditionsSHA256 != "" && s.GuestAdditionsSHA256 != "none" {
-			checksum = s.GuestAdditionsSHA256
-		} else {
-			// Skip checksum verification for default guest additions ISO
-			// since UTM doesn't provide checksums and versions change frequently
-			log.Println("Skipping checksum verification for default guest additions ISO")
-			checksumType = "none"
+	// Try each mirror in turn, verifying whichever one downloads
+	var failures []string
+	for i, url := range urls {
+		if i > 0 {
+			ui.Say(fmt.Sprintf("Trying the next guest additions mirror: %s", url))
+		}
+		if action := s.download(ctx, state, url, checksum); action == multistep.ActionContinue {
+			ui.Say(fmt.Sprintf("Guest additions downloaded from %s", url))
+			return action
+		}
+		if ctx.Err() != nil {
+			return multistep.ActionHalt
 		}
+		err, _ := state.Get("error").(error)
+		state.Remove("error")
+		failures = append(failures, fmt.Sprintf("%s: %v", url, err))
 	}
 
-	log.Printf("Guest additions URL: %s", url)
-
-	// Build checksum string with type prefix
-	checksumWithType := ""
-	if checksumType != "none" && checksum != "" {
-		checksumWithType = fmt.Sprintf("%s:%s", checksumType, checksum)
-	}
+	return haltWithError(state, ui, fmt.Errorf(
+		"error downloading guest additions from every mirror:\n%s", strings.Join(failures, "\n")))
+}
 
-	// We're good, so let's go ahead and download this thing..
+// download fetches the guest additions ISO from url to
+// guest_additions_path, verifying it against checksum unless it is empty.
+func (s *StepDownloadGuestAdditions) download(ctx context.Context, state multistep.StateBag, url string, checksum string) multistep.StepAction {
+	log.Printf("Guest additions URL: %s", url)
 	downStep := &commonsteps.StepDownload{
-		Checksum:    checksumWithType,
+		Checksum:    checksum,
 		Description: "Guest additions",
 		ResultKey:   "guest_additions_path",
 		TargetPath:  s.GuestAdditionsTargetPath,
 		Url:         []string{url},
 		Extension:   "iso",
 	}
-
+	if s.runDownload != nil {
+		return s.runDownload(ctx, state, downStep)
+	}
 	return downStep.Run(ctx, state)
 }
 
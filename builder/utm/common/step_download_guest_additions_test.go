@@ -0,0 +1,203 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/hashicorp/packer-plugin-sdk/multistep/commonsteps"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+func TestStepDownloadGuestAdditions_impl(t *testing.T) {
+	var _ multistep.Step = new(StepDownloadGuestAdditions)
+}
+
+func TestStepDownloadGuestAdditions_disabled(t *testing.T) {
+	state := testState(t)
+	step := &StepDownloadGuestAdditions{
+		GuestAdditionsMode: GuestAdditionsModeDisable,
+	}
+
+	driver := state.Get("driver").(*DriverMock)
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if driver.GuestToolsIsoPathCalled {
+		t.Fatal("should not query the driver when disabled")
+	}
+}
+
+func TestStepDownloadGuestAdditions_requireBundled(t *testing.T) {
+	state := testState(t)
+	step := &StepDownloadGuestAdditions{
+		GuestAdditionsMode:           GuestAdditionsModeAttach,
+		RequireBundledGuestAdditions: true,
+	}
+
+	driver := state.Get("driver").(*DriverMock)
+	driver.VersionResult = "4.6.4"
+	driver.GuestToolsIsoPathErr = &GuestToolsError{Reason: GuestToolsReasonNotBundled}
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if !driver.GuestToolsIsoPathCalled {
+		t.Fatal("should query the driver")
+	}
+	if _, ok := state.GetOk("error"); !ok {
+		t.Fatal("should have error")
+	}
+	reason := (&GuestToolsError{Reason: GuestToolsReasonNotBundled}).Error()
+	output := state.Get("ui").(*packersdk.BasicUi).Writer.(*bytes.Buffer).String()
+	if n := strings.Count(output, reason); n != 1 {
+		t.Fatalf("should report the reason once, got %d times: %s", n, output)
+	}
+}
+
+func TestStepDownloadGuestAdditions_requireBundledOldUTM(t *testing.T) {
+	state := testState(t)
+	step := &StepDownloadGuestAdditions{
+		GuestAdditionsMode:           GuestAdditionsModeAttach,
+		RequireBundledGuestAdditions: true,
+	}
+
+	driver := state.Get("driver").(*DriverMock)
+	driver.VersionResult = "4.5.4"
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if driver.GuestToolsIsoPathCalled {
+		t.Fatal("should fail before querying the driver")
+	}
+	err, _ := state.Get("error").(error)
+	if err == nil || !strings.Contains(err.Error(), "needs UTM >= 4.6.0, you have 4.5.4") {
+		t.Fatalf("bad error: %v", err)
+	}
+}
+
+func TestStepDownloadGuestAdditions_badFilename(t *testing.T) {
+	state := testState(t)
+	step := &StepDownloadGuestAdditions{
+		GuestAdditionsMode:     GuestAdditionsModeAttach,
+		GuestAdditionsFilename: "utm-guest-tools-{{ .Version }}.dmg",
+	}
+
+	driver := state.Get("driver").(*DriverMock)
+	driver.VersionResult = "4.6.4"
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	err, ok := state.Get("error").(error)
+	if !ok || !strings.Contains(err.Error(), "utm-guest-tools-0.229.2.dmg") {
+		t.Fatalf("should report the rendered filename: %v", err)
+	}
+	if state.Get(StateGuestAdditionsVersion) != "0.229.2" {
+		t.Fatalf("should record the version: %#v", state.Get(StateGuestAdditionsVersion))
+	}
+	if driver.GuestToolsIsoPathCalled {
+		t.Fatal("should not query the driver")
+	}
+}
+
+func TestStepDownloadGuestAdditions_mirrors(t *testing.T) {
+	state := testState(t)
+	var tried []string
+	step := &StepDownloadGuestAdditions{
+		GuestAdditionsMode:   GuestAdditionsModeAttach,
+		GuestAdditionsURL:    "https://a.example.com/{{ .Filename }}",
+		GuestAdditionsURLs:   []string{"https://b.example.com/utm-{{ .Version }}.iso"},
+		GuestAdditionsSHA256: strings.Repeat("a", 64),
+		runDownload: func(ctx context.Context, state multistep.StateBag, step *commonsteps.StepDownload) multistep.StepAction {
+			if step.Checksum != "sha256:"+strings.Repeat("a", 64) {
+				t.Fatalf("should verify every mirror: %q", step.Checksum)
+			}
+			tried = append(tried, step.Url[0])
+			if len(tried) < 3 {
+				state.Put("error", fmt.Errorf("mirror %d is down", len(tried)))
+				return multistep.ActionHalt
+			}
+			state.Put("guest_additions_path", "/tmp/guest-tools.iso")
+			return multistep.ActionContinue
+		},
+	}
+	driver := state.Get("driver").(*DriverMock)
+	driver.VersionResult = "4.6.4"
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	expected := []string{
+		"https://a.example.com/utm-guest-tools-latest.iso",
+		"https://b.example.com/utm-0.229.2.iso",
+		"https://getutm.app/downloads/utm-guest-tools-latest.iso",
+	}
+	if !reflect.DeepEqual(tried, expected) {
+		t.Fatalf("bad mirrors: %#v", tried)
+	}
+	if _, ok := state.GetOk("error"); ok {
+		t.Fatal("should NOT have error")
+	}
+	if driver.GuestToolsIsoPathCalled {
+		t.Fatal("should not use the bundled guest additions")
+	}
+	ui := state.Get("ui").(*packersdk.BasicUi)
+	if !strings.Contains(ui.Writer.(*bytes.Buffer).String(), "downloaded from https://getutm.app/") {
+		t.Fatal("should log the mirror used")
+	}
+}
+
+func TestStepDownloadGuestAdditions_allMirrorsFail(t *testing.T) {
+	state := testState(t)
+	step := &StepDownloadGuestAdditions{
+		GuestAdditionsMode: GuestAdditionsModeAttach,
+		GuestAdditionsURL:  "https://a.example.com/utm.iso",
+		runDownload: func(ctx context.Context, state multistep.StateBag, step *commonsteps.StepDownload) multistep.StepAction {
+			state.Put("error", errors.New("connection refused"))
+			return multistep.ActionHalt
+		},
+	}
+	state.Get("driver").(*DriverMock).VersionResult = "4.6.4"
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	err, ok := state.Get("error").(error)
+	if !ok || !strings.Contains(err.Error(), "https://a.example.com/utm.iso: connection refused") ||
+		!strings.Contains(err.Error(), "getutm.app") {
+		t.Fatalf("should report every mirror: %v", err)
+	}
+}
+
+func TestStepDownloadGuestAdditions_bundled(t *testing.T) {
+	state := testState(t)
+	var tried []*commonsteps.StepDownload
+	step := &StepDownloadGuestAdditions{
+		GuestAdditionsMode: GuestAdditionsModeAttach,
+		runDownload: func(ctx context.Context, state multistep.StateBag, step *commonsteps.StepDownload) multistep.StepAction {
+			tried = append(tried, step)
+			return multistep.ActionContinue
+		},
+	}
+	driver := state.Get("driver").(*DriverMock)
+	driver.VersionResult = "4.6.4"
+	driver.GuestToolsIsoPathResult = "/Applications/UTM.app/Contents/Resources/utm-guest-tools.iso"
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if len(tried) != 1 || tried[0].Url[0] != driver.GuestToolsIsoPathResult || tried[0].Checksum != "" {
+		t.Fatalf("should use the bundled guest additions: %#v", tried)
+	}
+}
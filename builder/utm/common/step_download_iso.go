@@ -0,0 +1,179 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/hashicorp/packer-plugin-sdk/multistep/commonsteps"
+)
+
+// partialDownloadSuffix marks a cached download that has not completed yet.
+const partialDownloadSuffix = ".partial"
+
+// This step downloads the boot ISO with commonsteps.StepDownload, which
+// resumes HTTP downloads with range requests when the server supports them,
+// and keeps track of which cached remote downloads are incomplete; local
+// files, plain paths or file:// urls, aren't downloaded. An incomplete file
+// is resumed when Resume is set and discarded otherwise. A resumed file is
+// verified against the checksum like any other download; when it fails
+// verification, it is downloaded again from scratch.
+//
+// A url naming a local directory of split ISO parts, or a .parts manifest
+// listing them, is not downloaded: the parts are joined into a temporary
+// ISO, which is verified against the checksum and removed on cleanup.
+//
+// Produces:
+//
+//	<ResultKey> string - The path to the downloaded ISO, see
+//	commonsteps.StepDownload.
+type StepDownloadISO struct {
+	Download *commonsteps.StepDownload
+	Resume   bool
+
+	// download runs the download, set by tests.
+	download multistep.Step
+	// joined is the temporary ISO the split parts were joined into.
+	joined string
+}
+
+func (s *StepDownloadISO) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	ui, err := getUI(state)
+	if err != nil {
+		return haltWithError(state, nil, err)
+	}
+
+	if len(s.Download.Url) == 1 {
+		source := s.Download.Url[0]
+		parts, split, err := ISOParts(source)
+		if err != nil {
+			return haltWithError(state, ui, err)
+		}
+		if split {
+			return s.joinParts(ctx, state, source, parts)
+		}
+	}
+
+	download := s.download
+	if download == nil {
+		download = s.Download
+	}
+
+	// Mark every cache target as incomplete until its download succeeds.
+	targets := map[string]string{}
+	var resumed []string
+	for _, source := range s.Download.Url {
+		if !isRemoteSource(source) {
+			continue
+		}
+		_, target, err := s.Download.UseSourceToFindCacheTarget(source)
+		if err != nil {
+			// StepDownload reports bad sources itself.
+			continue
+		}
+		targets[source] = target
+		marker := target + partialDownloadSuffix
+
+		if _, err := os.Stat(marker); err == nil {
+			if _, err := os.Stat(target); err == nil {
+				if s.Resume {
+					ui.Say(fmt.Sprintf("Resuming interrupted download of %s", source))
+					resumed = append(resumed, target)
+				} else {
+					ui.Say(fmt.Sprintf("Discarding interrupted download of %s", source))
+					if err := os.Remove(target); err != nil {
+						return haltWithError(state, ui, fmt.Errorf(
+							"error removing interrupted download %s: %s", target, err))
+					}
+				}
+			}
+			continue
+		}
+		if _, err := os.Stat(target); err == nil {
+			// Already downloaded by a previous build.
+			continue
+		}
+		if err := os.WriteFile(marker, nil, 0644); err != nil {
+			log.Printf("Could not mark %s as incomplete: %s", target, err)
+		}
+	}
+
+	action := download.Run(ctx, state)
+
+	if action != multistep.ActionContinue && len(resumed) > 0 && ctx.Err() == nil {
+		// StepDownload removes a file that fails checksum verification, so
+		// a missing file means a resume produced a corrupt ISO, typically
+		// because the partial file was stale or the server ignored the range.
+		retry := false
+		for _, target := range resumed {
+			if _, err := os.Stat(target); os.IsNotExist(err) {
+				retry = true
+			}
+		}
+		if retry {
+			ui.Say("Resumed download failed verification, downloading again from scratch...")
+			state.Remove("error")
+			action = download.Run(ctx, state)
+		}
+	}
+
+	if action == multistep.ActionContinue {
+		if source, ok := state.Get("SourceImageURL").(string); ok {
+			if target, ok := targets[source]; ok {
+				_ = os.Remove(target + partialDownloadSuffix)
+			}
+		}
+	}
+
+	return action
+}
+
+// isRemoteSource reports whether source is downloaded, as opposed to a
+// local path or a file:// url.
+func isRemoteSource(source string) bool {
+	u, err := url.Parse(source)
+	return err == nil && u.Scheme != "" && u.Scheme != "file"
+}
+
+// joinParts joins the split ISO parts of source into a temporary ISO.
+func (s *StepDownloadISO) joinParts(ctx context.Context, state multistep.StateBag, source string, parts []string) multistep.StepAction {
+	ui, err := getUI(state)
+	if err != nil {
+		return haltWithError(state, nil, err)
+	}
+
+	f, err := os.CreateTemp("", "packer-utm-*.iso")
+	if err != nil {
+		return haltWithError(state, ui, fmt.Errorf("error creating the joined ISO: %s", err))
+	}
+	_ = f.Close()
+	s.joined = f.Name()
+
+	ui.Say(fmt.Sprintf("Joining %d ISO parts of %s...", len(parts), source))
+	if err := JoinISOParts(ctx, parts, s.joined, s.Download.Checksum); err != nil {
+		return haltWithError(state, ui, err)
+	}
+	log.Printf("Joined ISO parts into %s", s.joined)
+
+	state.Put(s.Download.ResultKey, s.joined)
+	state.Put("SourceImageURL", source)
+	return multistep.ActionContinue
+}
+
+func (s *StepDownloadISO) Cleanup(state multistep.StateBag) {
+	if s.joined != "" {
+		if err := os.Remove(s.joined); err != nil && !os.IsNotExist(err) {
+			log.Printf("Error removing the joined ISO %s: %s", s.joined, err)
+		}
+		return
+	}
+	if s.Download != nil {
+		s.Download.Cleanup(state)
+	}
+}
@@ -0,0 +1,231 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/hashicorp/packer-plugin-sdk/multistep/commonsteps"
+)
+
+const testDownloadSource = "http://example.com/boot.iso"
+
+// testDownload stands in for commonsteps.StepDownload, running fn with the
+// number of the attempt.
+type testDownload struct {
+	runs int
+	fn   func(run int, state multistep.StateBag) multistep.StepAction
+}
+
+func (d *testDownload) Run(_ context.Context, state multistep.StateBag) multistep.StepAction {
+	d.runs++
+	return d.fn(d.runs, state)
+}
+
+func (d *testDownload) Cleanup(multistep.StateBag) {}
+
+func testStepDownloadISO(t *testing.T, resume bool, fn func(int, multistep.StateBag) multistep.StepAction) (*StepDownloadISO, *testDownload, string) {
+	target := filepath.Join(t.TempDir(), "boot.iso")
+	download := &testDownload{fn: fn}
+	step := &StepDownloadISO{
+		Download: &commonsteps.StepDownload{
+			Checksum:   "none",
+			ResultKey:  "iso_path",
+			TargetPath: target,
+			Url:        []string{testDownloadSource},
+		},
+		Resume:   resume,
+		download: download,
+	}
+	return step, download, target
+}
+
+func testDownloadSucceeds(target string) func(int, multistep.StateBag) multistep.StepAction {
+	return func(_ int, state multistep.StateBag) multistep.StepAction {
+		if err := os.WriteFile(target, []byte("iso"), 0644); err != nil {
+			panic(err)
+		}
+		state.Put("iso_path", target)
+		state.Put("SourceImageURL", testDownloadSource)
+		return multistep.ActionContinue
+	}
+}
+
+func TestStepDownloadISO_impl(t *testing.T) {
+	var _ multistep.Step = new(StepDownloadISO)
+}
+
+func TestStepDownloadISO(t *testing.T) {
+	state := testState(t)
+	var target string
+	step, download, target := testStepDownloadISO(t, true, func(run int, state multistep.StateBag) multistep.StepAction {
+		if _, err := os.Stat(target + partialDownloadSuffix); err != nil {
+			t.Fatalf("should mark the download as incomplete: %s", err)
+		}
+		return testDownloadSucceeds(target)(run, state)
+	})
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if download.runs != 1 {
+		t.Fatalf("bad runs: %d", download.runs)
+	}
+	if _, err := os.Stat(target + partialDownloadSuffix); !os.IsNotExist(err) {
+		t.Fatal("should clear the incomplete marker")
+	}
+}
+
+func TestStepDownloadISO_local(t *testing.T) {
+	iso := filepath.Join(t.TempDir(), "boot.iso")
+	for _, source := range []string{iso, "file://" + iso} {
+		state := testState(t)
+		step, _, target := testStepDownloadISO(t, true, func(int, multistep.StateBag) multistep.StepAction {
+			state.Put("error", errors.New("no such file"))
+			return multistep.ActionHalt
+		})
+		step.Download.Url = []string{source}
+
+		if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+			t.Fatalf("%s: bad action: %#v", source, action)
+		}
+		if _, err := os.Stat(target + partialDownloadSuffix); !os.IsNotExist(err) {
+			t.Fatalf("%s: should not mark a local file as incomplete", source)
+		}
+	}
+}
+
+func TestStepDownloadISO_interrupted(t *testing.T) {
+	state := testState(t)
+	var target string
+	step, _, target := testStepDownloadISO(t, true, func(int, multistep.StateBag) multistep.StepAction {
+		_ = os.WriteFile(target, []byte("is"), 0644)
+		state.Put("error", errors.New("connection reset"))
+		return multistep.ActionHalt
+	})
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, err := os.Stat(target + partialDownloadSuffix); err != nil {
+		t.Fatal("should keep the incomplete marker")
+	}
+}
+
+func TestStepDownloadISO_resume(t *testing.T) {
+	state := testState(t)
+	var target string
+	step, download, target := testStepDownloadISO(t, true, func(run int, state multistep.StateBag) multistep.StepAction {
+		b, _ := os.ReadFile(target)
+		if string(b) != "is" {
+			t.Fatalf("should keep the partial file: %q", b)
+		}
+		return testDownloadSucceeds(target)(run, state)
+	})
+	_ = os.WriteFile(target, []byte("is"), 0644)
+	_ = os.WriteFile(target+partialDownloadSuffix, nil, 0644)
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if download.runs != 1 {
+		t.Fatalf("bad runs: %d", download.runs)
+	}
+}
+
+func TestStepDownloadISO_noResume(t *testing.T) {
+	state := testState(t)
+	var target string
+	step, _, target := testStepDownloadISO(t, false, func(run int, state multistep.StateBag) multistep.StepAction {
+		if _, err := os.Stat(target); !os.IsNotExist(err) {
+			t.Fatal("should discard the partial file")
+		}
+		return testDownloadSucceeds(target)(run, state)
+	})
+	_ = os.WriteFile(target, []byte("is"), 0644)
+	_ = os.WriteFile(target+partialDownloadSuffix, nil, 0644)
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+}
+
+func TestStepDownloadISO_cached(t *testing.T) {
+	state := testState(t)
+	var target string
+	step, _, target := testStepDownloadISO(t, false, func(run int, state multistep.StateBag) multistep.StepAction {
+		if _, err := os.Stat(target); err != nil {
+			t.Fatal("should keep a completed download")
+		}
+		return testDownloadSucceeds(target)(run, state)
+	})
+	_ = os.WriteFile(target, []byte("iso"), 0644)
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+}
+
+func TestStepDownloadISO_resumeFailsChecksum(t *testing.T) {
+	state := testState(t)
+	var target string
+	step, download, target := testStepDownloadISO(t, true, func(run int, state multistep.StateBag) multistep.StepAction {
+		if run == 1 {
+			// StepDownload removes a file that fails verification.
+			_ = os.Remove(target)
+			state.Put("error", errors.New("checksums didn't match"))
+			return multistep.ActionHalt
+		}
+		return testDownloadSucceeds(target)(run, state)
+	})
+	_ = os.WriteFile(target, []byte("xx"), 0644)
+	_ = os.WriteFile(target+partialDownloadSuffix, nil, 0644)
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if download.runs != 2 {
+		t.Fatalf("should download again from scratch: %d runs", download.runs)
+	}
+	if _, ok := state.GetOk("error"); ok {
+		t.Fatal("should clear the error of the failed resume")
+	}
+}
+
+func TestStepDownloadISO_splitParts(t *testing.T) {
+	dir, sum := testISOParts(t, map[string]string{"win.iso.001": "part1", "win.iso.002": "part2"})
+	download := &testDownload{fn: func(int, multistep.StateBag) multistep.StepAction {
+		return multistep.ActionHalt
+	}}
+	step := &StepDownloadISO{
+		Download: &commonsteps.StepDownload{
+			Checksum:  "sha256:" + sum,
+			ResultKey: "iso_path",
+			Url:       []string{dir},
+		},
+		download: download,
+	}
+	state := testState(t)
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if download.runs != 0 {
+		t.Fatal("should not download split parts")
+	}
+	joined := state.Get("iso_path").(string)
+	if content, _ := os.ReadFile(joined); string(content) != "part1part2" {
+		t.Fatalf("bad joined ISO: %q", content)
+	}
+
+	step.Cleanup(state)
+	if _, err := os.Stat(joined); !os.IsNotExist(err) {
+		t.Fatal("should remove the joined ISO")
+	}
+}
@@ -6,11 +6,13 @@ package common
 import (
 	"context"
 	"fmt"
+	"log"
+	"os"
 	"path/filepath"
 	"strconv"
+	"time"
 
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
-	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 )
 
 // This step cleans up forwarded ports and (TODO) exports the VM to an UTM file.
@@ -28,8 +30,19 @@ type StepExport struct {
 	Bundling       UtmBundleConfig
 	SkipNatMapping bool
 	SkipExport     bool
+
+	// progressInterval is how often the export progress is reported, 10s
+	// when zero.
+	progressInterval time.Duration
+	// stopInterval is how often the size of an interrupted export is
+	// checked until UTM stops writing it, 2s when zero.
+	stopInterval time.Duration
 }
 
+// exportStopTimeout is how long an interrupted export is given to stop
+// growing before it is left for the user to remove.
+const exportStopTimeout = 2 * time.Minute
+
 func (s *StepExport) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
 	// TODO: If ISO export is configured, ensure this option is propagated to UTM.
 	for _, option := range s.ExportOpts {
@@ -39,10 +52,23 @@ func (s *StepExport) Run(ctx context.Context, state multistep.StateBag) multiste
 		}
 	}
 
-	driver := state.Get("driver").(Driver)
-	ui := state.Get("ui").(packersdk.Ui)
-	vmName := state.Get("vmName").(string)
-	vmId := state.Get("vmId").(string)
+	ui, err := getUI(state)
+	if err != nil {
+		return haltWithError(state, nil, err)
+	}
+	driver, err := getDriver(state)
+	if err != nil {
+		return haltWithError(state, ui, err)
+	}
+	vmName, err := GetVMName(state)
+	if err != nil {
+		return haltWithError(state, ui, err)
+	}
+	vmId, err := GetVMID(state)
+	if err != nil {
+		return haltWithError(state, ui, err)
+	}
+
 	if s.OutputFilename == "" {
 		s.OutputFilename = vmName
 	}
@@ -69,9 +95,9 @@ func (s *StepExport) Run(ctx context.Context, state multistep.StateBag) multiste
 		}
 		command := []string{
 			"clear_port_forwards.applescript", vmId,
-			"--index", "1", strconv.Itoa(commPortInt),
+			"--index", strconv.Itoa(CommAdapterIndex(state)), strconv.Itoa(commPortInt),
 		}
-		if _, err := driver.ExecuteOsaScript(command...); err != nil {
+		if _, err := driver.ExecuteOsaScript(ctx, command...); err != nil {
 			err := fmt.Errorf("error deleting port forwarding rule: %s", err)
 			state.Put("error", err)
 			ui.Error(err.Error())
@@ -89,7 +115,7 @@ func (s *StepExport) Run(ctx context.Context, state multistep.StateBag) multiste
 			"--args",
 		}
 		removeQemuArgsCommand = append(removeQemuArgsCommand, buildTimeArgs...)
-		_, err := driver.ExecuteOsaScript(removeQemuArgsCommand...)
+		_, err := driver.ExecuteOsaScript(ctx, removeQemuArgsCommand...)
 		if err != nil {
 			err := fmt.Errorf("error removing build-time QEMU additional arguments: %s", err)
 			state.Put("error", err)
@@ -111,8 +137,43 @@ func (s *StepExport) Run(ctx context.Context, state multistep.StateBag) multiste
 	outputPath := filepath.Join(absOutputDir, s.OutputFilename+"."+s.Format)
 	ui.Say("Exporting virtual machine...")
 
+	// UTM doesn't say how far along the export is, so it is estimated from
+	// the size of the bundle being exported.
+	var total uint64
+	bundlePath, err := driver.BundlePath(vmId)
+	if err == nil {
+		total, err = BundleSize(bundlePath)
+	}
+	if err != nil {
+		log.Printf("Can't estimate the size of the export: %s", err)
+		total = 0
+	}
+	interval := s.progressInterval
+	if interval == 0 {
+		interval = 10 * time.Second
+	}
+	progress := func(written uint64) {
+		ui.Say(formatExportProgress(written, total))
+	}
+
 	// Export the VM to an UTM file
-	if err := driver.Export(vmId, outputPath); err != nil {
+	if err := driver.ExportWithProgress(ctx, vmId, outputPath, interval, progress); err != nil {
+		if ctx.Err() != nil {
+			// Don't leave a partial bundle behind to be mistaken for a
+			// good one, once UTM, which the interruption doesn't stop, is
+			// done with it.
+			stopInterval := s.stopInterval
+			if stopInterval == 0 {
+				stopInterval = 2 * time.Second
+			}
+			ui.Say("Waiting for UTM to stop writing the interrupted export...")
+			if !waitForExportStopped(outputPath, stopInterval, exportStopTimeout) {
+				ui.Error(fmt.Sprintf("Warning: UTM is still writing %s, remove it once UTM is done", outputPath))
+			} else if err := os.RemoveAll(outputPath); err != nil {
+				log.Printf("Error removing the partial export %s: %s", outputPath, err)
+			}
+			return haltWithError(state, ui, fmt.Errorf("export interrupted: %w", ctx.Err()))
+		}
 		err := fmt.Errorf("error exporting VM: %s", err)
 		state.Put("error", err)
 		ui.Error(err.Error())
@@ -0,0 +1,147 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+)
+
+// windowsGuestAdditionsInstallCommand mounts the ISO if it was uploaded,
+// then runs the installer from whichever drive has it, which also finds an
+// attached ISO. The ARM64 installer is picked first on ARM guests and last
+// on the others.
+func windowsGuestAdditionsInstallCommand(arm64 bool) string {
+	order := "-like"
+	if arm64 {
+		order = "-notlike"
+	}
+	return `powershell -NoProfile -ExecutionPolicy Bypass -Command "` +
+		`$ErrorActionPreference = 'Stop'; ` +
+		`if (Test-Path '{{ .Path }}') { Mount-DiskImage -ImagePath (Resolve-Path '{{ .Path }}').Path | Out-Null }; ` +
+		`$installer = Get-PSDrive -PSProvider FileSystem | ` +
+		`ForEach-Object { Get-ChildItem -Path ($_.Root + 'utm-guest-tools-*.exe') -ErrorAction SilentlyContinue } | ` +
+		`Sort-Object { $_.Name ` + order + ` '*arm64*' } | ` +
+		`Select-Object -First 1; ` +
+		`if (-not $installer) { throw 'UTM guest tools installer not found' }; ` +
+		`$p = Start-Process -FilePath $installer.FullName -ArgumentList '/S' -Wait -PassThru; ` +
+		`exit $p.ExitCode"`
+}
+
+// DefaultGuestAdditionsInstallCommands are the install commands used for
+// each guest OS when guest_additions_install_command is unset. An entry
+// for a guest OS and architecture, such as windows/aarch64, takes
+// precedence over the one for the guest OS.
+var DefaultGuestAdditionsInstallCommands = map[string]string{
+	GuestOSWindows: windowsGuestAdditionsInstallCommand(false),
+	// Windows on ARM needs the ARM64 build of the guest tools, which the
+	// ISO carries next to the x64 one.
+	GuestOSWindows + "/aarch64": windowsGuestAdditionsInstallCommand(true),
+	// The UTM guest tools ISO has no Linux installer, the agents come from
+	// the distribution instead.
+	GuestOSLinux: `sh -c 'S=; [ "$(id -u)" -eq 0 ] || S="sudo -n"; ` +
+		`if command -v apt-get >/dev/null; then $S apt-get update && $S apt-get install -y qemu-guest-agent spice-vdagent; ` +
+		`elif command -v dnf >/dev/null; then $S dnf install -y qemu-guest-agent spice-vdagent; ` +
+		`elif command -v zypper >/dev/null; then $S zypper --non-interactive install qemu-guest-agent spice-vdagent; ` +
+		`elif command -v pacman >/dev/null; then $S pacman -S --noconfirm qemu-guest-agent spice-vdagent; ` +
+		`elif command -v apk >/dev/null; then $S apk add qemu-guest-agent spice-vdagent; ` +
+		`else echo "no supported package manager found" >&2; exit 1; fi'`,
+}
+
+// defaultGuestAdditionsInstallCommand returns the default install command
+// for a guestOS guest of architecture arch, or an empty string.
+func defaultGuestAdditionsInstallCommand(guestOS string, arch string) string {
+	if command, ok := DefaultGuestAdditionsInstallCommands[guestOS+"/"+arch]; ok {
+		return command
+	}
+	return DefaultGuestAdditionsInstallCommands[guestOS]
+}
+
+type guestAdditionsInstallTemplate struct {
+	Path    string
+	GuestOS string
+	Arch    string
+}
+
+// StepInstallGuestAdditions runs the guest additions install command over
+// the communicator, after StepDetectGuestOS so the default command can be
+// picked for the guest. The failure of the default command is only a
+// warning since the guest may lack the packages or the sudo rights it
+// relies on.
+//
+// Uses:
+//
+//	communicator packersdk.Communicator
+//	guest_os     string (optional)
+//	ui           packersdk.Ui
+type StepInstallGuestAdditions struct {
+	GuestAdditionsMode string
+	GuestAdditionsPath string
+	Command            string
+	Skip               bool
+	CommType           string
+	VMArch             string
+	Ctx                interpolate.Context
+}
+
+func (s *StepInstallGuestAdditions) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	if s.GuestAdditionsMode == GuestAdditionsModeDisable || s.Skip || s.CommType == "none" {
+		log.Println("Not installing guest additions.")
+		return multistep.ActionContinue
+	}
+
+	ui, err := getUI(state)
+	if err != nil {
+		return haltWithError(state, nil, err)
+	}
+	comm, err := getCommunicator(state)
+	if err != nil {
+		return haltWithError(state, ui, err)
+	}
+
+	guestOS := GetGuestOS(state)
+	command := s.Command
+	if command == "" {
+		command = defaultGuestAdditionsInstallCommand(guestOS, s.VMArch)
+		if command == "" {
+			log.Printf("No default guest additions install command for guest OS %q, skipping.", guestOS)
+			return multistep.ActionContinue
+		}
+	}
+
+	s.Ctx.Data = &guestAdditionsInstallTemplate{
+		Path:    s.GuestAdditionsPath,
+		GuestOS: guestOS,
+		Arch:    s.VMArch,
+	}
+	command, err = interpolate.Render(command, &s.Ctx)
+	if err != nil {
+		return haltWithError(state, ui, fmt.Errorf(
+			"error preparing guest additions install command: %s", err))
+	}
+
+	ui.Say("Installing guest additions...")
+	cmd := &packersdk.RemoteCmd{Command: command}
+	if err = cmd.RunWithUi(ctx, comm, ui); err != nil {
+		err = fmt.Errorf("error installing guest additions: %s", err)
+	} else if status := cmd.ExitStatus(); status != 0 {
+		err = fmt.Errorf("guest additions install command exited with status %d", status)
+	}
+	if err != nil {
+		if s.Command != "" {
+			return haltWithError(state, ui, err)
+		}
+		ui.Error(fmt.Sprintf("Warning: %s, continuing without guest additions. "+
+			"Set guest_additions_install_command to install them another way.", err))
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *StepInstallGuestAdditions) Cleanup(state multistep.StateBag) {}
@@ -0,0 +1,146 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+func TestStepInstallGuestAdditions_impl(t *testing.T) {
+	var _ multistep.Step = new(StepInstallGuestAdditions)
+}
+
+func TestStepInstallGuestAdditions(t *testing.T) {
+	state := testState(t)
+	comm := &packersdk.MockCommunicator{StartStdout: "installed"}
+	state.Put("communicator", comm)
+	state.Put(StateGuestOS, GuestOSLinux)
+
+	step := &StepInstallGuestAdditions{
+		GuestAdditionsMode: GuestAdditionsModeAttach,
+		GuestAdditionsPath: "utm-guest-tools.iso",
+		Command:            "install {{ .Path }} {{ .GuestOS }}",
+		CommType:           "ssh",
+	}
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if comm.StartCmd.Command != "install utm-guest-tools.iso linux" {
+		t.Fatalf("bad command: %q", comm.StartCmd.Command)
+	}
+}
+
+func TestStepInstallGuestAdditions_default(t *testing.T) {
+	state := testState(t)
+	comm := new(packersdk.MockCommunicator)
+	state.Put("communicator", comm)
+	state.Put(StateGuestOS, GuestOSWindows)
+
+	step := &StepInstallGuestAdditions{
+		GuestAdditionsMode: GuestAdditionsModeUpload,
+		GuestAdditionsPath: "utm-guest-tools.iso",
+		CommType:           "winrm",
+	}
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if !comm.StartCalled || !strings.Contains(comm.StartCmd.Command, "'utm-guest-tools.iso'") {
+		t.Fatalf("should run the Windows installer: %#v", comm.StartCmd)
+	}
+}
+
+func TestStepInstallGuestAdditions_defaultWindowsARM(t *testing.T) {
+	state := testState(t)
+	comm := new(packersdk.MockCommunicator)
+	state.Put("communicator", comm)
+	state.Put(StateGuestOS, GuestOSWindows)
+
+	step := &StepInstallGuestAdditions{
+		GuestAdditionsMode: GuestAdditionsModeUpload,
+		GuestAdditionsPath: "utm-guest-tools.iso",
+		CommType:           "winrm",
+		VMArch:             "aarch64",
+	}
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if !strings.Contains(comm.StartCmd.Command, "-notlike '*arm64*'") {
+		t.Fatalf("should prefer the ARM64 installer: %q", comm.StartCmd.Command)
+	}
+
+	if command := defaultGuestAdditionsInstallCommand(GuestOSWindows, "x86_64"); !strings.Contains(command, "-like '*arm64*'") ||
+		strings.Contains(command, "-notlike") {
+		t.Fatalf("should pick the ARM64 installer last on x86_64: %q", command)
+	}
+}
+
+func TestStepInstallGuestAdditions_skip(t *testing.T) {
+	cases := map[string]*StepInstallGuestAdditions{
+		"disabled":       {GuestAdditionsMode: GuestAdditionsModeDisable, CommType: "ssh"},
+		"skipped":        {GuestAdditionsMode: GuestAdditionsModeAttach, Skip: true, CommType: "ssh"},
+		"no comm":        {GuestAdditionsMode: GuestAdditionsModeAttach, CommType: "none"},
+		"no default cmd": {GuestAdditionsMode: GuestAdditionsModeAttach, CommType: "ssh"},
+	}
+	for name, step := range cases {
+		state := testState(t)
+		comm := new(packersdk.MockCommunicator)
+		state.Put("communicator", comm)
+		guestOS := GuestOSLinux
+		if name == "no default cmd" {
+			guestOS = GuestOSDarwin
+		}
+		state.Put(StateGuestOS, guestOS)
+
+		if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+			t.Fatalf("%s: bad action: %#v", name, action)
+		}
+		if comm.StartCalled {
+			t.Fatalf("%s: should not run anything", name)
+		}
+	}
+}
+
+func TestStepInstallGuestAdditions_failure(t *testing.T) {
+	state := testState(t)
+	state.Put("communicator", &packersdk.MockCommunicator{StartExitStatus: 1})
+
+	step := &StepInstallGuestAdditions{
+		GuestAdditionsMode: GuestAdditionsModeAttach,
+		Command:            "false",
+		CommType:           "ssh",
+	}
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("error"); !ok {
+		t.Fatal("should have error")
+	}
+}
+
+func TestStepInstallGuestAdditions_defaultFailure(t *testing.T) {
+	state := testState(t)
+	comm := &packersdk.MockCommunicator{StartExitStatus: 1}
+	state.Put("communicator", comm)
+	state.Put(StateGuestOS, GuestOSLinux)
+
+	// The default command is best effort
+	step := &StepInstallGuestAdditions{
+		GuestAdditionsMode: GuestAdditionsModeUpload,
+		CommType:           "ssh",
+	}
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if !comm.StartCalled {
+		t.Fatal("should run the Linux install")
+	}
+	if _, ok := state.GetOk("error"); ok {
+		t.Fatal("should not have error")
+	}
+}
@@ -0,0 +1,63 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+// This step starts the virtual machine again if it was shut down to detach
+// ISOs, so that it is left running at the end of the build.
+//
+// Uses:
+//
+//	driver Driver
+//	ui packersdk.Ui
+//	vmId string
+//
+// Produces:
+type StepKeepRunning struct {
+	Enabled bool
+	// StartTimeout is how long to wait for UTM to report the VM as started.
+	StartTimeout time.Duration
+}
+
+func (s *StepKeepRunning) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	if !s.Enabled {
+		return multistep.ActionContinue
+	}
+
+	ui, err := getUI(state)
+	if err != nil {
+		return haltWithError(state, nil, err)
+	}
+	driver, err := getDriver(state)
+	if err != nil {
+		return haltWithError(state, ui, err)
+	}
+	vmId, err := GetVMID(state)
+	if err != nil {
+		return haltWithError(state, ui, err)
+	}
+
+	if running, _ := driver.IsRunning(vmId); running {
+		return multistep.ActionContinue
+	}
+
+	ui.Say("Starting the virtual machine again (keep_running = true)...")
+	if err := driver.StartVM(ctx, vmId, s.StartTimeout); err != nil {
+		err := fmt.Errorf("error starting VM: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *StepKeepRunning) Cleanup(state multistep.StateBag) {}
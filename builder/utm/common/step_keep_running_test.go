@@ -0,0 +1,91 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+func TestStepKeepRunning_impl(t *testing.T) {
+	var _ multistep.Step = new(StepKeepRunning)
+}
+
+func TestStepKeepRunning_disabled(t *testing.T) {
+	state := testState(t)
+	step := new(StepKeepRunning)
+	state.Put("vmId", "foo")
+
+	driver := state.Get("driver").(*DriverMock)
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if len(driver.StartVMCalls) != 0 {
+		t.Fatalf("bad: %#v", driver.StartVMCalls)
+	}
+}
+
+func TestStepKeepRunning_stopped(t *testing.T) {
+	state := testState(t)
+	step := &StepKeepRunning{Enabled: true, StartTimeout: time.Minute}
+	state.Put("vmId", "foo")
+
+	driver := state.Get("driver").(*DriverMock)
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if len(driver.StartVMCalls) != 1 || driver.StartVMCalls[0] != "foo" || driver.StartVMTimeout != time.Minute {
+		t.Fatalf("should start the VM: %#v", driver.StartVMCalls)
+	}
+	if len(driver.UtmctlCalls) != 0 {
+		t.Fatalf("should go through the driver backend: %#v", driver.UtmctlCalls)
+	}
+}
+
+func TestStepKeepRunning_running(t *testing.T) {
+	state := testState(t)
+	step := &StepKeepRunning{Enabled: true}
+	state.Put("vmId", "foo")
+
+	driver := state.Get("driver").(*DriverMock)
+	driver.IsRunningReturn = true
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if len(driver.StartVMCalls) != 0 {
+		t.Fatalf("bad: %#v", driver.StartVMCalls)
+	}
+}
+
+func TestStepRun_keepRunningCleanup(t *testing.T) {
+	state := testState(t)
+	step := &StepRun{KeepRunning: true}
+	state.Put("vmId", "foo")
+
+	driver := state.Get("driver").(*DriverMock)
+	driver.IsRunningReturn = true
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	step.Cleanup(state)
+	if len(driver.StartVMCalls) != 1 || len(driver.PowerOffCalls) != 0 {
+		t.Fatalf("should not stop the VM: %#v", driver.PowerOffCalls)
+	}
+
+	// A failed build still stops the VM
+	driver.PowerOffErr = errors.New("no guest agent")
+	state.Put(multistep.StateHalted, true)
+	step.Cleanup(state)
+	if len(driver.PowerOffCalls) != 2 || !driver.PowerOffCalls[1] {
+		t.Fatalf("should stop the VM: %#v", driver.PowerOffCalls)
+	}
+}
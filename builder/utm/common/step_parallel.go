@@ -0,0 +1,160 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// ParallelStep is a step run by StepParallel. Name prefixes the messages
+// the step shows, so concurrent steps can be told apart.
+type ParallelStep struct {
+	Name string
+	Step multistep.Step
+}
+
+// This step runs independent steps, such as downloads, concurrently and
+// continues once all of them have. Each step sees the shared state, except
+// that it gets its own "ui", which prefixes its messages with the step's
+// name, and its own "error". When a step fails, the others are cancelled and
+// the errors of all failed steps are reported together.
+type StepParallel struct {
+	Steps []ParallelStep
+}
+
+func (s *StepParallel) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	ui, err := getUI(state)
+	if err != nil {
+		return haltWithError(state, nil, err)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var uiLock sync.Mutex
+	errCh := make(chan error, len(s.Steps))
+	var wg sync.WaitGroup
+	for _, step := range s.Steps {
+		wg.Add(1)
+		go func(step ParallelStep) {
+			defer wg.Done()
+			stepState := &parallelStepState{
+				StateBag: state,
+				ui:       &prefixedUi{Ui: ui, prefix: step.Name, lock: &uiLock},
+			}
+			if action := step.Step.Run(ctx, stepState); action != multistep.ActionContinue {
+				err := stepState.err
+				if err == nil {
+					err = fmt.Errorf("%s halted", step.Name)
+				}
+				errCh <- fmt.Errorf("%s: %s", step.Name, err)
+				cancel()
+			}
+		}(step)
+	}
+	wg.Wait()
+	close(errCh)
+
+	var errs []string
+	for err := range errCh {
+		errs = append(errs, err.Error())
+	}
+	if len(errs) > 0 {
+		// The steps have already shown their errors.
+		state.Put("error", fmt.Errorf("%s", strings.Join(errs, "\n")))
+		return multistep.ActionHalt
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *StepParallel) Cleanup(state multistep.StateBag) {
+	for i := len(s.Steps) - 1; i >= 0; i-- {
+		s.Steps[i].Step.Cleanup(state)
+	}
+}
+
+// parallelStepState is the state a step run by StepParallel sees: the
+// shared state, with its own ui and error.
+type parallelStepState struct {
+	multistep.StateBag
+	ui  packersdk.Ui
+	err error
+}
+
+func (s *parallelStepState) Get(key string) interface{} {
+	v, _ := s.GetOk(key)
+	return v
+}
+
+func (s *parallelStepState) GetOk(key string) (interface{}, bool) {
+	switch key {
+	case "ui":
+		return s.ui, true
+	case "error":
+		return s.err, s.err != nil
+	}
+	return s.StateBag.GetOk(key)
+}
+
+func (s *parallelStepState) Put(key string, value interface{}) {
+	if key == "error" {
+		s.err, _ = value.(error)
+		return
+	}
+	s.StateBag.Put(key, value)
+}
+
+func (s *parallelStepState) Remove(key string) {
+	if key == "error" {
+		s.err = nil
+		return
+	}
+	s.StateBag.Remove(key)
+}
+
+// prefixedUi prefixes the messages of a step run by StepParallel with its
+// name, and keeps messages of concurrent steps from interleaving. Progress
+// bars are left to the underlying Ui, which draws one per download.
+type prefixedUi struct {
+	packersdk.Ui
+	prefix string
+	lock   *sync.Mutex
+}
+
+func (u *prefixedUi) Say(message string) {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	u.Ui.Say(u.prefixed(message))
+}
+
+func (u *prefixedUi) Sayf(message string, args ...any) {
+	u.Say(fmt.Sprintf(message, args...))
+}
+
+func (u *prefixedUi) Message(message string) {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	u.Ui.Message(u.prefixed(message))
+}
+
+func (u *prefixedUi) Error(message string) {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	u.Ui.Error(u.prefixed(message))
+}
+
+func (u *prefixedUi) Errorf(message string, args ...any) {
+	u.Error(fmt.Sprintf(message, args...))
+}
+
+func (u *prefixedUi) prefixed(message string) string {
+	return fmt.Sprintf("[%s] %s", u.prefix, message)
+}
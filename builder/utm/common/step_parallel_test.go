@@ -0,0 +1,109 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// testParallelStep runs fn as a step.
+type testParallelStep struct {
+	fn      func(ctx context.Context, state multistep.StateBag) multistep.StepAction
+	cleaned bool
+}
+
+func (s *testParallelStep) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	return s.fn(ctx, state)
+}
+
+func (s *testParallelStep) Cleanup(multistep.StateBag) {
+	s.cleaned = true
+}
+
+func TestStepParallel_impl(t *testing.T) {
+	var _ multistep.Step = new(StepParallel)
+	var _ packersdk.Ui = new(prefixedUi)
+}
+
+func TestStepParallel(t *testing.T) {
+	state := testState(t)
+	out := new(bytes.Buffer)
+	state.Put("ui", &packersdk.BasicUi{Reader: new(bytes.Buffer), Writer: out})
+
+	// Each step waits for the other, so they have to run concurrently.
+	isoStarted, additionsStarted := make(chan struct{}), make(chan struct{})
+	iso := &testParallelStep{fn: func(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+		close(isoStarted)
+		<-additionsStarted
+		state.Get("ui").(packersdk.Ui).Say("Retrieving ISO")
+		state.Put("iso_path", "boot.iso")
+		return multistep.ActionContinue
+	}}
+	additions := &testParallelStep{fn: func(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+		close(additionsStarted)
+		<-isoStarted
+		state.Put("guest_additions_path", "tools.iso")
+		return multistep.ActionContinue
+	}}
+	step := &StepParallel{Steps: []ParallelStep{
+		{Name: "ISO", Step: iso},
+		{Name: "Guest additions", Step: additions},
+	}}
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if state.Get("iso_path") != "boot.iso" || state.Get("guest_additions_path") != "tools.iso" {
+		t.Fatal("should set both result keys")
+	}
+	if !strings.Contains(out.String(), "[ISO] Retrieving ISO") {
+		t.Fatalf("should prefix messages: %q", out.String())
+	}
+
+	step.Cleanup(state)
+	if !iso.cleaned || !additions.cleaned {
+		t.Fatal("should clean up all steps")
+	}
+}
+
+func TestStepParallel_error(t *testing.T) {
+	state := testState(t)
+
+	iso := &testParallelStep{fn: func(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+		state.Put("error", errors.New("checksum mismatch"))
+		return multistep.ActionHalt
+	}}
+	additions := &testParallelStep{fn: func(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+		select {
+		case <-ctx.Done():
+			state.Put("error", errors.New("download cancelled"))
+			return multistep.ActionHalt
+		case <-time.After(10 * time.Second):
+			return multistep.ActionContinue
+		}
+	}}
+	step := &StepParallel{Steps: []ParallelStep{
+		{Name: "ISO", Step: iso},
+		{Name: "Guest additions", Step: additions},
+	}}
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	err := state.Get("error").(error)
+	if !strings.Contains(err.Error(), "ISO: checksum mismatch") {
+		t.Fatalf("should report the failed step: %s", err)
+	}
+	if !strings.Contains(err.Error(), "Guest additions: download cancelled") {
+		t.Fatalf("should cancel the other steps: %s", err)
+	}
+}
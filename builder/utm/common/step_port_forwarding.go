@@ -4,11 +4,11 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"strconv"
 
 	"github.com/hashicorp/packer-plugin-sdk/communicator"
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	"github.com/hashicorp/packer-plugin-sdk/net"
-	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 )
 
 // This step adds a Emulated VLAN port forwarding definition so that SSH (or WinRM ?)
@@ -31,9 +31,18 @@ type StepPortForwarding struct {
 }
 
 func (s *StepPortForwarding) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
-	driver := state.Get("driver").(Driver)
-	ui := state.Get("ui").(packersdk.Ui)
-	vmId := state.Get("vmId").(string)
+	ui, err := getUI(state)
+	if err != nil {
+		return haltWithError(state, nil, err)
+	}
+	driver, err := getDriver(state)
+	if err != nil {
+		return haltWithError(state, ui, err)
+	}
+	vmId, err := GetVMID(state)
+	if err != nil {
+		return haltWithError(state, ui, err)
+	}
 
 	if s.CommConfig.Type == "none" {
 		log.Printf("Not using a communicator, skipping setting up port forwarding...")
@@ -68,7 +77,7 @@ func (s *StepPortForwarding) Run(ctx context.Context, state multistep.StateBag)
 		// and 'Emulated VLAN' interface at index 0 and 1 respectively.
 		if s.ClearNetworkInterfaces {
 			// Make sure to clear the network interfaces and prepare for the new configuration
-			if _, err := driver.ExecuteOsaScript("clear_network_interfaces.applescript", vmId); err != nil {
+			if _, err := driver.ExecuteOsaScript(ctx, "clear_network_interfaces.applescript", vmId); err != nil {
 				err := fmt.Errorf("error clearing network interfaces: %s", err)
 				state.Put("error", err)
 				ui.Error(err.Error())
@@ -81,7 +90,7 @@ func (s *StepPortForwarding) Run(ctx context.Context, state multistep.StateBag)
 			// but this should be configurable
 
 			// Add access to localhost => UTM 'Shared Network' interface
-			if _, err := driver.ExecuteOsaScript("add_network_interface.applescript", vmId, "ShRd"); err != nil {
+			if _, err := driver.ExecuteOsaScript(ctx, "add_network_interface.applescript", vmId, "ShRd"); err != nil {
 				err := fmt.Errorf("error adding network interface: %s", err)
 				state.Put("error", err)
 				ui.Error(err.Error())
@@ -93,7 +102,7 @@ func (s *StepPortForwarding) Run(ctx context.Context, state multistep.StateBag)
 			// and then add if needed
 			// Make sure to configure the network interface to 'Emulated VLAN' mode
 			// required for port forwarding now in packer , later in vagrant
-			if _, err := driver.ExecuteOsaScript("add_network_interface.applescript", vmId, "EmUd"); err != nil {
+			if _, err := driver.ExecuteOsaScript(ctx, "add_network_interface.applescript", vmId, "EmUd"); err != nil {
 				err := fmt.Errorf("error adding network interface: %s", err)
 				state.Put("error", err)
 				ui.Error(err.Error())
@@ -106,10 +115,10 @@ func (s *StepPortForwarding) Run(ctx context.Context, state multistep.StateBag)
 		ui.Say(fmt.Sprintf("Creating forwarded port mapping for communicator (SSH, WinRM, etc) (host port %d)", commHostPort))
 		command := []string{
 			"add_port_forwards.applescript", vmId,
-			"--index", "1",
+			"--index", strconv.Itoa(CommAdapterIndex(state)),
 			fmt.Sprintf("TcPp,,%d,127.0.0.1,%d", guestPort, commHostPort),
 		}
-		if _, err := driver.ExecuteOsaScript(command...); err != nil {
+		if _, err := driver.ExecuteOsaScript(ctx, command...); err != nil {
 			err := fmt.Errorf("error adding port forwarding rule: %s", err)
 			state.Put("error", err)
 			ui.Error(err.Error())
@@ -0,0 +1,60 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+// This step checks that UTM is installed and can be scripted before any
+// other step runs, so a broken setup fails with a remediation hint instead
+// of an AppleScript error halfway through the build.
+//
+// Uses:
+//
+//	driver Driver
+//	ui packersdk.Ui
+//
+// Produces:
+type StepPreflight struct{}
+
+func (s *StepPreflight) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	ui, err := getUI(state)
+	if err != nil {
+		return haltWithError(state, nil, err)
+	}
+	driver, err := getDriver(state)
+	if err != nil {
+		return haltWithError(state, ui, err)
+	}
+
+	ui.Say("Checking UTM installation...")
+	installed, err := driver.IsInstalled()
+	if err != nil {
+		return haltWithError(state, ui, fmt.Errorf("error looking for UTM: %s", err))
+	}
+	if !installed {
+		return haltWithError(state, ui, errors.New(
+			"UTM is not installed: install UTM.app from https://mac.getutm.app/ into /Applications"))
+	}
+
+	// CheckAutomation would launch UTM behind the back of auto_launch_utm.
+	if err := driver.EnsureRunning(); err != nil {
+		return haltWithError(state, ui, err)
+	}
+
+	// A refused permission comes back as an AutomationPermissionError,
+	// which already tells the user how to grant it.
+	if err := driver.CheckAutomation(); err != nil {
+		return haltWithError(state, ui, err)
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *StepPreflight) Cleanup(state multistep.StateBag) {}
@@ -0,0 +1,111 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+func TestStepPreflight_impl(t *testing.T) {
+	var _ multistep.Step = new(StepPreflight)
+}
+
+func TestStepPreflight(t *testing.T) {
+	state := testState(t)
+	step := new(StepPreflight)
+
+	driver := state.Get("driver").(*DriverMock)
+	driver.IsInstalledReturn = true
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if !driver.EnsureRunningCalled {
+		t.Fatal("should make sure UTM is running")
+	}
+	if !driver.CheckAutomationCalled {
+		t.Fatal("should check automation")
+	}
+}
+
+func TestStepPreflight_notRunning(t *testing.T) {
+	state := testState(t)
+	step := new(StepPreflight)
+
+	driver := state.Get("driver").(*DriverMock)
+	driver.IsInstalledReturn = true
+	driver.EnsureRunningErr = ErrUTMNotRunning
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	err := state.Get("error").(error)
+	if !strings.Contains(err.Error(), "auto_launch_utm") {
+		t.Fatalf("bad error: %s", err)
+	}
+	// Checking automation would launch UTM.
+	if driver.CheckAutomationCalled {
+		t.Fatal("should not check automation")
+	}
+}
+
+func TestStepPreflight_notInstalled(t *testing.T) {
+	state := testState(t)
+	step := new(StepPreflight)
+
+	driver := state.Get("driver").(*DriverMock)
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	err := state.Get("error").(error)
+	if !strings.Contains(err.Error(), "UTM is not installed") {
+		t.Fatalf("bad error: %s", err)
+	}
+	if driver.CheckAutomationCalled {
+		t.Fatal("should not check automation")
+	}
+}
+
+func TestStepPreflight_notAuthorized(t *testing.T) {
+	state := testState(t)
+	step := new(StepPreflight)
+
+	driver := state.Get("driver").(*DriverMock)
+	driver.IsInstalledReturn = true
+	driver.CheckAutomationErr = &AutomationPermissionError{Output: "execution error (-1743)"}
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	err := state.Get("error").(error)
+	if !strings.Contains(err.Error(), "tccutil reset AppleEvents") {
+		t.Fatalf("should give remediation: %s", err)
+	}
+}
+
+func TestAutomationError(t *testing.T) {
+	err := automationError(
+		"execution error: Not authorized to send Apple events to UTM. (-1743)", errors.New("exit status 1"))
+	if !errors.Is(err, ErrAutomationNotAuthorized) {
+		t.Fatalf("should be not authorized: %s", err)
+	}
+	var permErr *AutomationPermissionError
+	if !errors.As(err, &permErr) {
+		t.Fatalf("should be an AutomationPermissionError: %#v", err)
+	}
+	if !strings.Contains(err.Error(), "Privacy & Security > Automation") {
+		t.Fatalf("should explain how to grant the permission: %s", err)
+	}
+
+	err = automationError("", errors.New("exit status 1"))
+	if errors.Is(err, ErrAutomationNotAuthorized) {
+		t.Fatalf("should not be not authorized: %s", err)
+	}
+}
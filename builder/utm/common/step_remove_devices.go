@@ -8,7 +8,6 @@ import (
 	"fmt"
 
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
-	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 )
 
 // This step removes any devices (floppy disks, ISOs, etc.) from the
@@ -26,27 +25,31 @@ type StepRemoveDevices struct {
 }
 
 func (s *StepRemoveDevices) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
-	driver := state.Get("driver").(Driver)
-	ui := state.Get("ui").(packersdk.Ui)
+	ui, err := getUI(state)
+	if err != nil {
+		return haltWithError(state, nil, err)
+	}
+	driver, err := getDriver(state)
+	if err != nil {
+		return haltWithError(state, ui, err)
+	}
 
 	// TODO: Remove the attached floppy disk, if it exists
 
-	var isoUnmountCommands map[string][]string
-	isoUnmountCommandsRaw, ok := state.GetOk("disk_unmount_commands")
-	if !ok {
+	if _, ok := state.GetOk("disk_unmount_commands"); !ok {
 		// No disks to unmount
 		return multistep.ActionContinue
-	} else {
-		isoUnmountCommands = isoUnmountCommandsRaw.(map[string][]string)
 	}
 
-	for diskCategory, unmountCommand := range isoUnmountCommands {
-		if diskCategory == "boot_iso" && s.Bundling.BundleISO {
-			// skip the unmount if user wants to bundle the iso
-			continue
+	if _, ok := state.GetOk("vm_suspended"); ok {
+		if len(detachableISOs(state, s.Bundling)) > 0 {
+			ui.Say("Leaving the ISOs attached, UTM can't change the drives of a suspended VM")
 		}
+		return multistep.ActionContinue
+	}
 
-		if _, err := driver.ExecuteOsaScript(unmountCommand...); err != nil {
+	for _, unmountCommand := range detachableISOs(state, s.Bundling) {
+		if _, err := driver.ExecuteOsaScript(ctx, unmountCommand...); err != nil {
 			err := fmt.Errorf("error detaching ISO: %s", err)
 			state.Put("error", err)
 			ui.Error(err.Error())
@@ -63,3 +66,22 @@ func (s *StepRemoveDevices) Run(ctx context.Context, state multistep.StateBag) m
 
 func (s *StepRemoveDevices) Cleanup(state multistep.StateBag) {
 }
+
+// detachableISOs returns the unmount commands of the ISOs attached during the
+// build that should not stay attached to the final VM.
+func detachableISOs(state multistep.StateBag, bundling UtmBundleConfig) map[string][]string {
+	isoUnmountCommandsRaw, ok := state.GetOk("disk_unmount_commands")
+	if !ok {
+		return nil
+	}
+
+	isoUnmountCommands := make(map[string][]string)
+	for diskCategory, unmountCommand := range isoUnmountCommandsRaw.(map[string][]string) {
+		if diskCategory == "boot_iso" && bundling.BundleISO {
+			// skip the unmount if user wants to bundle the iso
+			continue
+		}
+		isoUnmountCommands[diskCategory] = unmountCommand
+	}
+	return isoUnmountCommands
+}
@@ -130,3 +130,21 @@ func TestStepRemoveDevices_attachedIsoOnSata(t *testing.T) {
 // 		t.Fatalf("bad: %#v", driver.ExecuteOsaCalls)
 // 	}
 // }
+
+func TestStepRemoveDevices_suspended(t *testing.T) {
+	state := testState(t)
+	step := new(StepRemoveDevices)
+
+	state.Put("disk_unmount_commands", map[string][]string{
+		"boot_iso": {"attach_iso.applescript", "myvm", "--interface", "QdIv", "--source", "/path/to/iso"},
+	})
+	state.Put("vm_suspended", true)
+
+	driver := state.Get("driver").(*DriverMock)
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if len(driver.ExecuteOsaCalls) != 0 {
+		t.Fatalf("should not change the drives of a suspended VM: %#v", driver.ExecuteOsaCalls)
+	}
+}
@@ -0,0 +1,63 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+// NVRAMFile is the EFI variable store of a UTM VM, in the Data directory of
+// its bundle. UTM creates it from the firmware defaults when it is missing.
+const NVRAMFile = "efi_vars.fd"
+
+// StepResetNVRAM removes the EFI variable store from the exported bundle so
+// that the VM starts with the firmware defaults on the host it is imported on.
+//
+// Uses:
+//
+//	exportPath string
+//	ui packersdk.Ui
+//
+// Produces:
+//
+//	<nothing>
+type StepResetNVRAM struct {
+	Enabled    bool
+	SkipExport bool
+}
+
+func (s *StepResetNVRAM) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	if !s.Enabled || s.SkipExport {
+		return multistep.ActionContinue
+	}
+
+	ui, err := getUI(state)
+	if err != nil {
+		return haltWithError(state, nil, err)
+	}
+	exportPath, ok := state.Get("exportPath").(string)
+	if !ok {
+		return haltWithError(state, ui, errors.New("no exported VM to reset the NVRAM of"))
+	}
+
+	nvram := filepath.Join(exportPath, "Data", NVRAMFile)
+	if err := os.Remove(nvram); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			ui.Say("The exported VM has no NVRAM store, nothing to reset (reset_nvram = true)")
+			return multistep.ActionContinue
+		}
+		return haltWithError(state, ui, fmt.Errorf("error resetting the NVRAM: %s", err))
+	}
+	ui.Say("Reset the NVRAM of the exported VM, UTM recreates it on the next start")
+	return multistep.ActionContinue
+}
+
+func (s *StepResetNVRAM) Cleanup(state multistep.StateBag) {}
@@ -0,0 +1,74 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+func TestStepResetNVRAM_impl(t *testing.T) {
+	var _ multistep.Step = new(StepResetNVRAM)
+}
+
+func testExportedBundle(t *testing.T, withNVRAM bool) string {
+	bundle := filepath.Join(t.TempDir(), "vm.utm")
+	if err := os.MkdirAll(filepath.Join(bundle, "Data"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if withNVRAM {
+		if err := os.WriteFile(filepath.Join(bundle, "Data", NVRAMFile), []byte("vars"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return bundle
+}
+
+func TestStepResetNVRAM(t *testing.T) {
+	state := testState(t)
+	bundle := testExportedBundle(t, true)
+	state.Put("exportPath", bundle)
+
+	step := &StepResetNVRAM{Enabled: true}
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, err := os.Stat(filepath.Join(bundle, "Data", NVRAMFile)); !os.IsNotExist(err) {
+		t.Fatalf("should remove the NVRAM store: %v", err)
+	}
+}
+
+func TestStepResetNVRAM_noStore(t *testing.T) {
+	state := testState(t)
+	state.Put("exportPath", testExportedBundle(t, false))
+
+	step := &StepResetNVRAM{Enabled: true}
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	output := testUiOutput(state)
+	if !strings.Contains(output, "no NVRAM store") {
+		t.Fatalf("should say there is nothing to reset: %s", output)
+	}
+}
+
+func TestStepResetNVRAM_disabled(t *testing.T) {
+	state := testState(t)
+	bundle := testExportedBundle(t, true)
+	state.Put("exportPath", bundle)
+
+	for _, step := range []*StepResetNVRAM{{}, {Enabled: true, SkipExport: true}} {
+		if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+			t.Fatalf("bad action: %#v", action)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(bundle, "Data", NVRAMFile)); err != nil {
+		t.Fatalf("should keep the NVRAM store: %v", err)
+	}
+}
@@ -5,7 +5,13 @@ package common
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
 
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
@@ -21,27 +27,65 @@ import (
 //
 // Produces:
 type StepRun struct {
+	// KeepRunning leaves the VM running when the build succeeds.
+	KeepRunning bool
+	// KeepRegistered leaves the VM alone when the build succeeds, as it is
+	// not deleted then.
+	KeepRegistered bool
+	// StartTimeout is how long to wait for UTM to report the VM as started.
+	StartTimeout time.Duration
+	// BootWait is how long to wait for the VM to boot once started, before
+	// the boot command is typed or the communicator connects.
+	BootWait time.Duration
+	// QemuLog copies the QEMU log of the VM next to OutputDir once the VM
+	// is stopped, where it outlives the output directory of a failed build.
+	QemuLog   bool
+	OutputDir string
+
 	vmId string
+	// logReported is set when the QEMU log was already shown with an error.
+	logReported bool
 }
 
 func (s *StepRun) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
-	driver := state.Get("driver").(Driver)
-	ui := state.Get("ui").(packersdk.Ui)
-	vmId := state.Get("vmId").(string)
+	ui, err := getUI(state)
+	if err != nil {
+		return haltWithError(state, nil, err)
+	}
+	driver, err := getDriver(state)
+	if err != nil {
+		return haltWithError(state, ui, err)
+	}
+	vmId, err := GetVMID(state)
+	if err != nil {
+		return haltWithError(state, ui, err)
+	}
 
 	ui.Say("Starting the virtual machine...")
-	command := []string{"start", vmId}
-	if _, err := driver.Utmctl(command...); err != nil {
-		err := fmt.Errorf("error starting VM: %s", err)
-		state.Put("error", err)
-		ui.Error(err.Error())
-		return multistep.ActionHalt
+	// Set before starting, so Cleanup stops a VM that started but was not
+	// confirmed as running in time.
+	s.vmId = vmId
+	if err := driver.StartVM(ctx, vmId, s.StartTimeout); err != nil {
+		if logPath := s.qemuLogPath(state); logPath != "" {
+			err = withQemuLog(err, logPath)
+			s.logReported = true
+		}
+		return haltWithError(state, ui, err)
 	}
 
-	s.vmId = vmId
 	// instance_id is the generic term used so that users can have access to the
 	// instance id inside of the provisioners, used in step_provision.
 	state.Put("instance_id", s.vmId)
+	emitEvent(state, Event{Type: EventVMState, Step: "StepRun", Status: "running"})
+
+	if s.BootWait > 0 {
+		ui.Say(fmt.Sprintf("Waiting %s for boot...", s.BootWait))
+		select {
+		case <-time.After(s.BootWait):
+		case <-ctx.Done():
+			return haltWithError(state, ui, fmt.Errorf("interrupted while waiting for boot: %w", ctx.Err()))
+		}
+	}
 
 	return multistep.ActionContinue
 }
@@ -54,9 +98,96 @@ func (s *StepRun) Cleanup(state multistep.StateBag) {
 	driver := state.Get("driver").(Driver)
 	ui := state.Get("ui").(packersdk.Ui)
 
-	if running, _ := driver.IsRunning(s.vmId); running {
-		if _, err := driver.Utmctl("stop", s.vmId); err != nil {
-			ui.Error(fmt.Sprintf("Error shutting down VM: %s", err))
+	_, cancelled := state.GetOk(multistep.StateCancelled)
+	_, halted := state.GetOk(multistep.StateHalted)
+
+	logPath := s.qemuLogPath(state)
+	if s.QemuLog && logPath != "" {
+		defer s.copyQemuLog(ui, logPath)
+	}
+
+	if (s.KeepRunning || s.KeepRegistered) && (!cancelled && !halted) {
+		return
+	}
+
+	running, _ := driver.IsRunning(s.vmId)
+	if running {
+		powerOff(driver, ui, s.vmId)
+		emitEvent(state, Event{Type: EventVMState, Step: "StepRun", Status: "stopped"})
+	} else if (cancelled || halted) && logPath != "" && !s.logReported {
+		// A VM that is no longer running when the build failed most likely
+		// had QEMU exit, which otherwise only shows as a timeout.
+		if tail, err := ReadQemuLogTail(logPath, qemuLogTailLines); err == nil && tail != "" {
+			ui.Error(fmt.Sprintf("The VM is not running, QEMU log (%s):\n%s", logPath, tail))
 		}
 	}
 }
+
+// powerOffTimeout is how long Cleanup lets the guest shut down before the
+// VM is powered off forcefully.
+var powerOffTimeout = 10 * time.Second
+
+// powerOff stops a VM left running by a build, asking the guest to shut
+// down first and forcing it off when that fails or takes too long. This
+// runs before the VM is deleted, so that UTM doesn't keep it running.
+func powerOff(driver Driver, ui packersdk.Ui, vmId string) {
+	log.Printf("Powering off VM %s...", vmId)
+	if err := driver.PowerOff(vmId, false); err != nil {
+		log.Printf("Graceful power off failed: %s", err)
+	} else {
+		deadline := time.Now().Add(powerOffTimeout)
+		for time.Now().Before(deadline) {
+			if running, err := driver.IsRunning(vmId); err == nil && !running {
+				return
+			}
+			time.Sleep(500 * time.Millisecond)
+		}
+		log.Printf("VM %s still running after %s", vmId, powerOffTimeout)
+	}
+
+	log.Printf("Forcing VM %s off...", vmId)
+	if err := driver.PowerOff(vmId, true); err != nil {
+		ui.Error(fmt.Sprintf("Error shutting down VM: %s", err))
+	}
+}
+
+// qemuLogPath returns the path of the QEMU log of the VM, or an empty
+// string when its bundle can't be found.
+func (s *StepRun) qemuLogPath(state multistep.StateBag) string {
+	driver, err := getDriver(state)
+	if err != nil {
+		return ""
+	}
+	vmId, err := GetVMID(state)
+	if err != nil {
+		return ""
+	}
+	bundlePath, err := driver.BundlePath(vmId)
+	if err != nil {
+		log.Printf("Can't find the QEMU log: %s", err)
+		return ""
+	}
+	return QemuLogPath(bundlePath)
+}
+
+// copyQemuLog copies the QEMU log next to the output directory, as
+// <output directory>-qemu.log. The output directory is removed when the
+// build fails, which is when the log matters most.
+func (s *StepRun) copyQemuLog(ui packersdk.Ui, logPath string) {
+	content, err := os.ReadFile(logPath)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			ui.Say(fmt.Sprintf("No QEMU log at %s, enable the QEMU debug log of the VM in UTM to get one", logPath))
+			return
+		}
+		ui.Error(fmt.Sprintf("Error reading QEMU log: %s", err))
+		return
+	}
+	outputDir := filepath.Clean(s.OutputDir)
+	dest := filepath.Join(filepath.Dir(outputDir), filepath.Base(outputDir)+"-qemu.log")
+	if err := os.WriteFile(dest, content, 0644); err != nil {
+		ui.Error(fmt.Sprintf("Error copying QEMU log: %s", err))
+		return
+	}
+	ui.Say(fmt.Sprintf("Copied the QEMU log to %s", dest))
+}
@@ -0,0 +1,180 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+func TestStepRun_impl(t *testing.T) {
+	var _ multistep.Step = new(StepRun)
+}
+
+func TestStepRun(t *testing.T) {
+	state := testState(t)
+	state.Put("vmId", "vm-id")
+	step := &StepRun{StartTimeout: time.Minute, BootWait: 10 * time.Millisecond}
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	driver := state.Get("driver").(*DriverMock)
+	if len(driver.StartVMCalls) != 1 || driver.StartVMCalls[0] != "vm-id" {
+		t.Fatalf("should start the VM: %#v", driver.StartVMCalls)
+	}
+	if driver.StartVMTimeout != time.Minute {
+		t.Fatalf("bad start timeout: %s", driver.StartVMTimeout)
+	}
+	if state.Get("instance_id") != "vm-id" {
+		t.Fatalf("bad instance_id: %#v", state.Get("instance_id"))
+	}
+}
+
+func TestStepRun_noBootWait(t *testing.T) {
+	state := testState(t)
+	state.Put("vmId", "vm-id")
+	step := &StepRun{BootWait: -time.Second}
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	out := state.Get("ui").(*packersdk.BasicUi).Writer.(*bytes.Buffer).String()
+	if strings.Contains(out, "Waiting") {
+		t.Fatalf("should not wait with a negative boot_wait: %q", out)
+	}
+}
+
+func TestStepRun_bootWaitCancelled(t *testing.T) {
+	state := testState(t)
+	state.Put("vmId", "vm-id")
+	step := &StepRun{BootWait: time.Hour}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if action := step.Run(ctx, state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("error"); !ok {
+		t.Fatal("should have error")
+	}
+}
+
+func TestStepRun_startFailed(t *testing.T) {
+	state := testState(t)
+	state.Put("vmId", "vm-id")
+	driver := state.Get("driver").(*DriverMock)
+	driver.StartVMErr = errors.New("VM is stopped right after starting")
+	step := &StepRun{BootWait: time.Hour}
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if err, ok := state.GetOk("error"); !ok || err != driver.StartVMErr {
+		t.Fatalf("should halt with the start error: %#v", err)
+	}
+	if _, ok := state.GetOk("instance_id"); ok {
+		t.Fatal("should not set instance_id")
+	}
+}
+
+func TestStepRun_startFailedQemuLog(t *testing.T) {
+	state := testState(t)
+	state.Put("vmId", "vm-id")
+	state.Put("vmName", "foo")
+	driver := state.Get("driver").(*DriverMock)
+	driver.StartVMErr = errors.New("VM is stopped right after starting")
+	driver.BundlePathResult = t.TempDir()
+	writeQemuLog(t, driver.BundlePathResult, "qemu-system-aarch64: -accel hvf: not supported\n")
+	step := &StepRun{}
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	err := state.Get("error").(error)
+	if !strings.Contains(err.Error(), "-accel hvf: not supported") {
+		t.Fatalf("should include the QEMU log: %s", err)
+	}
+}
+
+func TestStepRun_cleanupQemuLog(t *testing.T) {
+	state := testState(t)
+	state.Put("vmId", "vm-id")
+	state.Put("vmName", "foo")
+	driver := state.Get("driver").(*DriverMock)
+	driver.BundlePathResult = t.TempDir()
+	writeQemuLog(t, driver.BundlePathResult, "qemu-system-aarch64: terminating on signal 15\n")
+	parent := t.TempDir()
+	outputDir := filepath.Join(parent, "output-foo")
+	step := &StepRun{QemuLog: true, OutputDir: outputDir}
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	state.Put(multistep.StateHalted, true)
+	step.Cleanup(state)
+
+	// The log lands next to the output directory, which a failed build
+	// removes.
+	content, err := os.ReadFile(filepath.Join(parent, "output-foo-qemu.log"))
+	if err != nil {
+		t.Fatalf("should copy the QEMU log: %s", err)
+	}
+	if string(content) != "qemu-system-aarch64: terminating on signal 15\n" {
+		t.Fatalf("bad log: %q", content)
+	}
+	errs := state.Get("ui").(*packersdk.BasicUi).Writer.(*bytes.Buffer).String()
+	if !strings.Contains(errs, "The VM is not running") {
+		t.Fatalf("should show the QEMU log of a VM that stopped: %q", errs)
+	}
+}
+
+func TestStepRun_cleanupPowerOff(t *testing.T) {
+	defer func(timeout time.Duration) { powerOffTimeout = timeout }(powerOffTimeout)
+	powerOffTimeout = time.Millisecond
+
+	state := testState(t)
+	step := new(StepRun)
+	state.Put("vmId", "foo")
+
+	driver := state.Get("driver").(*DriverMock)
+	driver.IsRunningReturn = true
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	state.Put(multistep.StateHalted, true)
+	step.Cleanup(state)
+
+	// The guest is asked first, then forced off as it keeps running
+	if len(driver.PowerOffCalls) != 2 || driver.PowerOffCalls[0] || !driver.PowerOffCalls[1] {
+		t.Fatalf("should power off the VM: %#v", driver.PowerOffCalls)
+	}
+}
+
+func TestStepRun_cleanupKeepRegistered(t *testing.T) {
+	state := testState(t)
+	step := &StepRun{KeepRegistered: true}
+	state.Put("vmId", "foo")
+
+	driver := state.Get("driver").(*DriverMock)
+	driver.IsRunningReturn = true
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	step.Cleanup(state)
+	if len(driver.PowerOffCalls) != 0 {
+		t.Fatalf("should leave a kept VM alone: %#v", driver.PowerOffCalls)
+	}
+}
@@ -0,0 +1,58 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+// StepSetBootOrder reorders the drives of the VM to boot the devices in
+// order. It must run once all drives are attached and before StepRun.
+//
+// Uses:
+//
+//	driver Driver
+//	ui packersdk.Ui
+//	vmId string
+//
+// Produces:
+type StepSetBootOrder struct {
+	Devices []string
+}
+
+func (s *StepSetBootOrder) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	if len(s.Devices) == 0 {
+		log.Println("[INFO] No boot order configured, skipping...")
+		return multistep.ActionContinue
+	}
+
+	ui, err := getUI(state)
+	if err != nil {
+		return haltWithError(state, nil, err)
+	}
+	driver, err := getDriver(state)
+	if err != nil {
+		return haltWithError(state, ui, err)
+	}
+	vmId, err := GetVMID(state)
+	if err != nil {
+		return haltWithError(state, ui, err)
+	}
+
+	ui.Say(fmt.Sprintf("Setting the boot order to %s...", strings.Join(s.Devices, ", ")))
+	if err := driver.SetBootOrder(vmId, s.Devices); err != nil {
+		return haltWithError(state, ui, err)
+	}
+	if devices, err := driver.GetBootOrder(vmId); err == nil {
+		log.Printf("Boot order of VM %s: %s", vmId, strings.Join(devices, ", "))
+	}
+	return multistep.ActionContinue
+}
+
+func (s *StepSetBootOrder) Cleanup(state multistep.StateBag) {}
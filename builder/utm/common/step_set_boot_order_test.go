@@ -0,0 +1,60 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+func TestStepSetBootOrder_impl(t *testing.T) {
+	var _ multistep.Step = new(StepSetBootOrder)
+}
+
+func TestStepSetBootOrder_none(t *testing.T) {
+	state := testState(t)
+	step := new(StepSetBootOrder)
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	driver := state.Get("driver").(*DriverMock)
+	if len(driver.SetBootOrderCalls) != 0 {
+		t.Fatalf("should not set the boot order: %#v", driver.SetBootOrderCalls)
+	}
+}
+
+func TestStepSetBootOrder(t *testing.T) {
+	state := testState(t)
+	step := &StepSetBootOrder{Devices: []string{"cd", "disk"}}
+	state.Put("vmId", "foo")
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	driver := state.Get("driver").(*DriverMock)
+	if !reflect.DeepEqual(driver.SetBootOrderCalls, [][]string{{"cd", "disk"}}) {
+		t.Fatalf("bad calls: %#v", driver.SetBootOrderCalls)
+	}
+}
+
+func TestStepSetBootOrder_error(t *testing.T) {
+	state := testState(t)
+	step := &StepSetBootOrder{Devices: []string{"cd", "disk"}}
+	state.Put("vmId", "foo")
+
+	driver := state.Get("driver").(*DriverMock)
+	driver.SetBootOrderErr = errors.New("UTM error")
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("error"); !ok {
+		t.Fatal("should have error")
+	}
+}
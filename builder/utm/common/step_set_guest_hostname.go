@@ -0,0 +1,89 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// GuestHostnameCommands are the commands setting the hostname, substituted
+// for %[1]s, for each guest OS. Windows gets the computer name instead.
+// The hostname is validated, so it needs no quoting.
+var GuestHostnameCommands = map[string]string{
+	GuestOSLinux: `sh -c 'S=; [ "$(id -u)" -eq 0 ] || S="sudo -n"; ` +
+		`if command -v hostnamectl >/dev/null; then $S hostnamectl set-hostname %[1]s; ` +
+		`else echo %[1]s | $S tee /etc/hostname >/dev/null && $S hostname %[1]s; fi'`,
+	GuestOSDarwin: `sh -c 'S=; [ "$(id -u)" -eq 0 ] || S="sudo -n"; ` +
+		`$S scutil --set HostName %[1]s && $S scutil --set LocalHostName %[1]s && $S scutil --set ComputerName %[1]s'`,
+	GuestOSFreeBSD: `sh -c 'S=; [ "$(id -u)" -eq 0 ] || S="sudo -n"; ` +
+		`$S sysrc hostname=%[1]s && $S hostname %[1]s'`,
+	GuestOSOpenBSD: `sh -c 'S=; [ "$(id -u)" -eq 0 ] || S="doas -n"; ` +
+		`echo %[1]s | $S tee /etc/myname >/dev/null && $S hostname %[1]s'`,
+	GuestOSNetBSD: `sh -c 'S=; [ "$(id -u)" -eq 0 ] || S="sudo -n"; ` +
+		`echo %[1]s | $S tee /etc/myname >/dev/null && $S hostname %[1]s'`,
+	// Rename-Computer fails when the name doesn't change, for example when
+	// windows_unattended already set it.
+	GuestOSWindows: `powershell -NoProfile -Command "` +
+		`$ErrorActionPreference = 'Stop'; ` +
+		`if ($env:COMPUTERNAME -ne '%[1]s') { Rename-Computer -NewName '%[1]s' -Force }"`,
+}
+
+// StepSetGuestHostname sets the guest hostname over the communicator, after
+// StepDetectGuestOS so the command can be picked for the guest. It runs
+// once the provisioners are done, so they can't change it back.
+//
+// Uses:
+//
+//	communicator packersdk.Communicator
+//	guest_os     string
+//	ui           packersdk.Ui
+type StepSetGuestHostname struct {
+	Hostname string
+}
+
+func (s *StepSetGuestHostname) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	if s.Hostname == "" {
+		log.Println("No guest_hostname specified, skipping...")
+		return multistep.ActionContinue
+	}
+
+	ui, err := getUI(state)
+	if err != nil {
+		return haltWithError(state, nil, err)
+	}
+	comm, err := getCommunicator(state)
+	if err != nil {
+		return haltWithError(state, ui, err)
+	}
+
+	guestOS := GetGuestOS(state)
+	command, ok := GuestHostnameCommands[guestOS]
+	if !ok {
+		return haltWithError(state, ui, fmt.Errorf(
+			"can't set guest_hostname: no hostname command for guest OS %q", guestOS))
+	}
+	hostname := s.Hostname
+	if guestOS == GuestOSWindows {
+		hostname = ComputerName(hostname)
+	}
+
+	ui.Say(fmt.Sprintf("Setting the guest hostname to %s...", hostname))
+	cmd := &packersdk.RemoteCmd{Command: fmt.Sprintf(command, hostname)}
+	if err := cmd.RunWithUi(ctx, comm, ui); err != nil {
+		return haltWithError(state, ui, fmt.Errorf("error setting the guest hostname: %s", err))
+	}
+	if status := cmd.ExitStatus(); status != 0 {
+		return haltWithError(state, ui, fmt.Errorf(
+			"guest hostname command exited with status %d", status))
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *StepSetGuestHostname) Cleanup(state multistep.StateBag) {}
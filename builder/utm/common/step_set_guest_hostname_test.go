@@ -0,0 +1,81 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+func TestStepSetGuestHostname_impl(t *testing.T) {
+	var _ multistep.Step = new(StepSetGuestHostname)
+}
+
+func TestStepSetGuestHostname_unset(t *testing.T) {
+	state := testState(t)
+	comm := new(packersdk.MockCommunicator)
+	state.Put("communicator", comm)
+
+	step := &StepSetGuestHostname{}
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if comm.StartCalled {
+		t.Fatal("should not run anything")
+	}
+}
+
+func TestStepSetGuestHostname(t *testing.T) {
+	cases := []struct {
+		guestOS  string
+		expected string
+	}{
+		{GuestOSLinux, "hostnamectl set-hostname build01.example.com"},
+		{GuestOSDarwin, "scutil --set HostName build01.example.com"},
+		{GuestOSFreeBSD, "sysrc hostname=build01.example.com"},
+		{GuestOSWindows, "Rename-Computer -NewName 'build01' -Force"},
+	}
+	for _, tc := range cases {
+		state := testState(t)
+		state.Put(StateGuestOS, tc.guestOS)
+		comm := new(packersdk.MockCommunicator)
+		state.Put("communicator", comm)
+
+		step := &StepSetGuestHostname{Hostname: "build01.example.com"}
+		if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+			t.Fatalf("%s: bad action: %#v", tc.guestOS, action)
+		}
+		if !strings.Contains(comm.StartCmd.Command, tc.expected) {
+			t.Fatalf("%s: bad command: %s", tc.guestOS, comm.StartCmd.Command)
+		}
+	}
+}
+
+func TestStepSetGuestHostname_unknownGuestOS(t *testing.T) {
+	state := testState(t)
+	state.Put("communicator", new(packersdk.MockCommunicator))
+
+	step := &StepSetGuestHostname{Hostname: "build01"}
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if err := state.Get("error").(error); !strings.Contains(err.Error(), "guest_hostname") {
+		t.Fatalf("bad error: %s", err)
+	}
+}
+
+func TestStepSetGuestHostname_failure(t *testing.T) {
+	state := testState(t)
+	state.Put(StateGuestOS, GuestOSLinux)
+	state.Put("communicator", &packersdk.MockCommunicator{StartExitStatus: 1})
+
+	step := &StepSetGuestHostname{Hostname: "build01"}
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+}
@@ -31,13 +31,55 @@ type StepShutdown struct {
 	Timeout         time.Duration
 	Delay           time.Duration
 	DisableShutdown bool
+	// KeepRunning leaves the VM running after the build. The VM is still
+	// shut down when ISOs need detaching, since UTM only changes the drives
+	// of a stopped VM, and StepKeepRunning starts it again afterwards.
+	KeepRunning bool
+	Bundling    UtmBundleConfig
+	// Suspend suspends the VM, saving its state, instead of shutting it
+	// down. It sets vm_suspended so that StepRemoveDevices leaves the
+	// drives alone.
+	Suspend bool
 }
 
 func (s *StepShutdown) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
-	comm := state.Get("communicator").(packersdk.Communicator)
-	driver := state.Get("driver").(Driver)
-	ui := state.Get("ui").(packersdk.Ui)
-	vmId := state.Get("vmId").(string)
+	ui, err := getUI(state)
+	if err != nil {
+		return haltWithError(state, nil, err)
+	}
+	comm, err := getCommunicator(state)
+	if err != nil {
+		return haltWithError(state, ui, err)
+	}
+	driver, err := getDriver(state)
+	if err != nil {
+		return haltWithError(state, ui, err)
+	}
+	vmId, err := GetVMID(state)
+	if err != nil {
+		return haltWithError(state, ui, err)
+	}
+
+	if s.KeepRunning {
+		if len(detachableISOs(state, s.Bundling)) == 0 {
+			ui.Say("Leaving virtual machine running (keep_running = true)")
+			return multistep.ActionContinue
+		}
+		ui.Say("Shutting down to detach ISOs, the virtual machine will be started again (keep_running = true)")
+	}
+
+	if s.Suspend {
+		ui.Say("Suspending the virtual machine and saving its state...")
+		if err := driver.Suspend(vmId); err != nil {
+			if errors.Is(err, ErrSuspendNotSupported) {
+				err = fmt.Errorf("suspend_before_export is not supported for this VM: %s", err)
+			}
+			return haltWithError(state, ui, err)
+		}
+		state.Put("vm_suspended", true)
+		emitEvent(state, Event{Type: EventVMState, Step: "StepShutdown", Status: "suspended"})
+		return multistep.ActionContinue
+	}
 
 	if !s.DisableShutdown {
 		if s.Command != "" {
@@ -66,27 +108,18 @@ func (s *StepShutdown) Run(ctx context.Context, state multistep.StateBag) multis
 
 	// Wait for the machine to actually shut down
 	log.Printf("Waiting max %s for shutdown to complete", s.Timeout)
-	shutdownTimer := time.After(s.Timeout)
-	for {
-		running, _ := driver.IsRunning(vmId)
-		if !running {
-
-			if s.Delay.Nanoseconds() > 0 {
-				log.Printf("Delay for %s after shutdown to allow locks to clear...", s.Delay)
-				time.Sleep(s.Delay)
-			}
-
-			break
+	if err := driver.WaitForState(ctx, vmId, "stopped", s.Timeout, 500*time.Millisecond); err != nil {
+		if ctx.Err() != nil {
+			return haltWithError(state, ui, fmt.Errorf("interrupted while waiting for shutdown: %w", ctx.Err()))
 		}
+		return haltWithError(state, ui, fmt.Errorf("error waiting for the machine to shut down: %s", err))
+	}
+	emitEvent(state, Event{Type: EventVMState, Step: "StepShutdown", Status: "stopped"})
 
-		select {
-		case <-shutdownTimer:
-			err := errors.New("timeout while waiting for machine to shutdown")
-			state.Put("error", err)
-			ui.Error(err.Error())
-			return multistep.ActionHalt
-		default:
-			time.Sleep(500 * time.Millisecond)
+	if s.Delay.Nanoseconds() > 0 {
+		log.Printf("Delay for %s after shutdown to allow locks to clear...", s.Delay)
+		if err := sleepCtx(ctx, s.Delay); err != nil {
+			return haltWithError(state, ui, fmt.Errorf("interrupted while waiting after shutdown: %w", err))
 		}
 	}
 
@@ -5,6 +5,8 @@ package common
 
 import (
 	"context"
+	"fmt"
+	"strings"
 	"testing"
 	"time"
 
@@ -140,3 +142,164 @@ func TestStepShutdown_DisableShutdown(t *testing.T) {
 		t.Fatal("should NOT have error")
 	}
 }
+
+func TestStepShutdown_keepRunning(t *testing.T) {
+	state := testState(t)
+	step := &StepShutdown{KeepRunning: true}
+
+	comm := new(packersdk.MockCommunicator)
+	state.Put("communicator", comm)
+	state.Put("vmId", "foo")
+
+	driver := state.Get("driver").(*DriverMock)
+	driver.IsRunningReturn = true
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if driver.StopName != "" {
+		t.Fatal("should not stop the VM")
+	}
+}
+
+func TestStepShutdown_keepRunningDetachISOs(t *testing.T) {
+	state := testState(t)
+	step := &StepShutdown{KeepRunning: true}
+
+	comm := new(packersdk.MockCommunicator)
+	state.Put("communicator", comm)
+	state.Put("vmId", "foo")
+	state.Put("disk_unmount_commands", map[string][]string{
+		"cd_files": {"remove_drive", "foo", "drive-id"},
+	})
+
+	driver := state.Get("driver").(*DriverMock)
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if driver.StopName != "foo" {
+		t.Fatal("should stop the VM to detach ISOs")
+	}
+}
+
+func TestStepShutdown_keepRunningBundledISO(t *testing.T) {
+	state := testState(t)
+	step := &StepShutdown{
+		KeepRunning: true,
+		Bundling:    UtmBundleConfig{BundleISO: true},
+	}
+
+	comm := new(packersdk.MockCommunicator)
+	state.Put("communicator", comm)
+	state.Put("vmId", "foo")
+	state.Put("disk_unmount_commands", map[string][]string{
+		"boot_iso": {"remove_drive", "foo", "drive-id"},
+	})
+
+	driver := state.Get("driver").(*DriverMock)
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if driver.StopName != "" {
+		t.Fatal("should not stop the VM for a bundled ISO")
+	}
+}
+
+func TestStepShutdown_cancelled(t *testing.T) {
+	state := testState(t)
+	step := new(StepShutdown)
+	step.Command = "poweroff"
+	step.Timeout = 1 * time.Minute
+
+	comm := new(packersdk.MockCommunicator)
+	state.Put("communicator", comm)
+	state.Put("vmId", "foo")
+
+	driver := state.Get("driver").(*DriverMock)
+	driver.IsRunningReturn = true
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+	}()
+
+	start := time.Now()
+	if action := step.Run(ctx, state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if time.Since(start) > 10*time.Second {
+		t.Fatal("should stop waiting once cancelled")
+	}
+	err, ok := state.GetOk("error")
+	if !ok || !strings.Contains(err.(error).Error(), "interrupted") {
+		t.Fatalf("should report the interruption: %v", err)
+	}
+}
+
+func TestStepShutdown_waitsForStopped(t *testing.T) {
+	state := testState(t)
+	step := new(StepShutdown)
+	step.Command = "poweroff"
+	step.Timeout = 1 * time.Minute
+
+	comm := new(packersdk.MockCommunicator)
+	state.Put("communicator", comm)
+	state.Put("vmId", "foo")
+
+	driver := state.Get("driver").(*DriverMock)
+	driver.WaitForStateStates = []string{"started", "stopping", "stopped"}
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if len(driver.WaitForStateCalls) != 1 || driver.WaitForStateCalls[0] != "stopped" {
+		t.Fatalf("should wait for the VM to stop: %#v", driver.WaitForStateCalls)
+	}
+	if len(driver.WaitForStateStates) != 0 {
+		t.Fatalf("should poll through the stopping VM: %#v", driver.WaitForStateStates)
+	}
+}
+
+func TestStepShutdown_suspend(t *testing.T) {
+	state := testState(t)
+	step := &StepShutdown{Command: "poweroff", Timeout: time.Minute, Suspend: true}
+
+	comm := new(packersdk.MockCommunicator)
+	state.Put("communicator", comm)
+	state.Put("vmId", "foo")
+
+	driver := state.Get("driver").(*DriverMock)
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if len(driver.SuspendCalls) != 1 || driver.SuspendCalls[0] != "foo" {
+		t.Fatalf("should suspend the VM: %#v", driver.SuspendCalls)
+	}
+	if comm.StartCalled || driver.StopName != "" {
+		t.Fatal("should not shut down the VM")
+	}
+	if _, ok := state.GetOk("vm_suspended"); !ok {
+		t.Fatal("should record that the VM is suspended")
+	}
+}
+
+func TestStepShutdown_suspendNotSupported(t *testing.T) {
+	state := testState(t)
+	step := &StepShutdown{Suspend: true}
+
+	state.Put("communicator", new(packersdk.MockCommunicator))
+	state.Put("vmId", "foo")
+
+	driver := state.Get("driver").(*DriverMock)
+	driver.SuspendErr = fmt.Errorf("error suspending VM foo: %w", ErrSuspendNotSupported)
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	err, ok := state.GetOk("error")
+	if !ok || !strings.Contains(err.(error).Error(), "suspend_before_export is not supported") {
+		t.Fatalf("should explain that suspend is not supported: %v", err)
+	}
+}
@@ -0,0 +1,133 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// StepTestResume suspends the VM, saving its state, resumes it and waits
+// for the communicator to answer again, then runs the post-resume
+// commands. The communicators reconnect on their own, so waiting is a
+// matter of retrying a no-op command until it succeeds.
+//
+// Uses:
+//
+//	communicator packersdk.Communicator
+//	driver Driver
+//	guest_os     string (optional)
+//	ui     packersdk.Ui
+//	vmId   string
+type StepTestResume struct {
+	Enabled  bool
+	Commands []string
+	Timeout  time.Duration
+	CommType string
+
+	// interval is how often the communicator is retried, 2s when zero.
+	interval time.Duration
+}
+
+func (s *StepTestResume) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	if !s.Enabled {
+		return multistep.ActionContinue
+	}
+
+	ui, err := getUI(state)
+	if err != nil {
+		return haltWithError(state, nil, err)
+	}
+	driver, err := getDriver(state)
+	if err != nil {
+		return haltWithError(state, ui, err)
+	}
+	comm, err := getCommunicator(state)
+	if err != nil {
+		return haltWithError(state, ui, err)
+	}
+	vmId, err := GetVMID(state)
+	if err != nil {
+		return haltWithError(state, ui, err)
+	}
+
+	ui.Say("Suspending the virtual machine to test that it resumes (test_resume = true)...")
+	if err := driver.Suspend(vmId); err != nil {
+		if errors.Is(err, ErrSuspendNotSupported) {
+			err = fmt.Errorf("test_resume is not supported for this VM: %s", err)
+		}
+		return haltWithError(state, ui, err)
+	}
+	ui.Say("Resuming the virtual machine...")
+	if err := driver.Resume(vmId); err != nil {
+		return haltWithError(state, ui, err)
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, s.Timeout)
+	defer cancel()
+	if err := driver.WaitForState(waitCtx, vmId, "started", s.Timeout, time.Second); err != nil {
+		return haltWithError(state, ui, fmt.Errorf("error waiting for the VM to resume: %s", err))
+	}
+	if err := s.waitForCommunicator(waitCtx, state, comm); err != nil {
+		return haltWithError(state, ui, err)
+	}
+
+	for _, command := range s.Commands {
+		log.Printf("Executing test_resume command: %s", command)
+		cmd := &packersdk.RemoteCmd{Command: command}
+		if err := cmd.RunWithUi(ctx, comm, ui); err != nil {
+			return haltWithError(state, ui, fmt.Errorf("error running test_resume command: %s", err))
+		}
+		if status := cmd.ExitStatus(); status != 0 {
+			return haltWithError(state, ui, fmt.Errorf(
+				"test_resume command %q exited with status %d", command, status))
+		}
+	}
+
+	ui.Say("Virtual machine resumed cleanly")
+	return multistep.ActionContinue
+}
+
+// waitForCommunicator retries a no-op command until the communicator runs
+// it again, which for SSH means the connection was reestablished.
+func (s *StepTestResume) waitForCommunicator(ctx context.Context, state multistep.StateBag, comm packersdk.Communicator) error {
+	command := "true"
+	guestOS := GetGuestOS(state)
+	if guestOS == GuestOSWindows || (guestOS == "" && s.CommType == "winrm") {
+		command = "cmd /c exit 0"
+	}
+	interval := s.interval
+	if interval == 0 {
+		interval = 2 * time.Second
+	}
+
+	for {
+		cmd := &packersdk.RemoteCmd{
+			Command: command,
+			Stdout:  new(bytes.Buffer),
+			Stderr:  new(bytes.Buffer),
+		}
+		if err := comm.Start(ctx, cmd); err != nil {
+			log.Printf("Communicator not answering after resume yet: %s", err)
+		} else if status := cmd.Wait(); status == 0 {
+			return nil
+		}
+
+		if err := sleepCtx(ctx, interval); err != nil {
+			if errors.Is(err, context.DeadlineExceeded) {
+				return fmt.Errorf("communicator didn't answer within %s of resuming the VM (test_resume_timeout)", s.Timeout)
+			}
+			return fmt.Errorf("interrupted while waiting for the communicator: %w", err)
+		}
+	}
+}
+
+func (s *StepTestResume) Cleanup(state multistep.StateBag) {}
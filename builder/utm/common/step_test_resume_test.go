@@ -0,0 +1,90 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+func TestStepTestResume_impl(t *testing.T) {
+	var _ multistep.Step = new(StepTestResume)
+}
+
+func TestStepTestResume(t *testing.T) {
+	state := testState(t)
+	comm := new(packersdk.MockCommunicator)
+	state.Put("communicator", comm)
+	state.Put("vmId", "foo")
+
+	driver := state.Get("driver").(*DriverMock)
+	driver.WaitForStateStates = []string{"resuming", "started"}
+
+	step := &StepTestResume{
+		Enabled:  true,
+		Commands: []string{"systemctl is-system-running"},
+		Timeout:  time.Minute,
+		CommType: "ssh",
+	}
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if len(driver.SuspendCalls) != 1 || len(driver.ResumeCalls) != 1 {
+		t.Fatalf("should suspend and resume the VM: %#v %#v", driver.SuspendCalls, driver.ResumeCalls)
+	}
+	if len(driver.WaitForStateCalls) != 1 || driver.WaitForStateCalls[0] != "started" {
+		t.Fatalf("should wait for the VM to run: %#v", driver.WaitForStateCalls)
+	}
+	if comm.StartCmd.Command != "systemctl is-system-running" {
+		t.Fatalf("should run the post-resume command: %q", comm.StartCmd.Command)
+	}
+}
+
+func TestStepTestResume_noAnswer(t *testing.T) {
+	state := testState(t)
+	state.Put("communicator", &packersdk.MockCommunicator{StartExitStatus: 1})
+	state.Put("vmId", "foo")
+	state.Get("driver").(*DriverMock).IsRunningReturn = true
+
+	step := &StepTestResume{
+		Enabled:  true,
+		Timeout:  50 * time.Millisecond,
+		CommType: "ssh",
+		interval: time.Millisecond,
+	}
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	err, ok := state.GetOk("error")
+	if !ok || !strings.Contains(err.(error).Error(), "didn't answer within 50ms") {
+		t.Fatalf("should time out waiting for the communicator: %v", err)
+	}
+}
+
+func TestStepTestResume_notSupported(t *testing.T) {
+	state := testState(t)
+	state.Put("communicator", new(packersdk.MockCommunicator))
+	state.Put("vmId", "foo")
+
+	driver := state.Get("driver").(*DriverMock)
+	driver.SuspendErr = fmt.Errorf("error suspending VM foo: %w", ErrSuspendNotSupported)
+
+	step := &StepTestResume{Enabled: true, Timeout: time.Minute}
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if len(driver.ResumeCalls) != 0 {
+		t.Fatal("should not resume a VM that wasn't suspended")
+	}
+	err, ok := state.GetOk("error")
+	if !ok || !strings.Contains(err.(error).Error(), "test_resume is not supported") {
+		t.Fatalf("should explain that suspend is not supported: %v", err)
+	}
+}
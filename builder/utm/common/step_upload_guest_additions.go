@@ -0,0 +1,101 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// StepUploadGuestAdditions uploads the downloaded guest additions ISO to
+// GuestAdditionsPath in the guest when guest_additions_mode is upload, and
+// then gives it UploadMode if set.
+//
+// Uses:
+//
+//	communicator         packersdk.Communicator
+//	guest_additions_path string - Local path to the guest additions ISO.
+//	guest_os             string (optional)
+//	ui                   packersdk.Ui
+type StepUploadGuestAdditions struct {
+	GuestAdditionsMode string
+	GuestAdditionsPath string
+	UploadMode         string
+	CommType           string
+}
+
+func (s *StepUploadGuestAdditions) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	if s.GuestAdditionsMode != GuestAdditionsModeUpload || s.CommType == "none" {
+		log.Println("Not uploading guest additions since the mode is not upload.")
+		return multistep.ActionContinue
+	}
+
+	ui, err := getUI(state)
+	if err != nil {
+		return haltWithError(state, nil, err)
+	}
+	comm, err := getCommunicator(state)
+	if err != nil {
+		return haltWithError(state, ui, err)
+	}
+
+	localPath, ok := state.Get("guest_additions_path").(string)
+	if !ok || localPath == "" {
+		return haltWithError(state, ui, fmt.Errorf("guest additions ISO was not downloaded"))
+	}
+
+	f, err := os.Open(localPath)
+	if err != nil {
+		return haltWithError(state, ui, fmt.Errorf("error opening guest additions ISO: %s", err))
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return haltWithError(state, ui, fmt.Errorf("error reading guest additions ISO: %s", err))
+	}
+
+	ui.Say(fmt.Sprintf("Uploading guest additions to %s...", s.GuestAdditionsPath))
+	if err := comm.Upload(s.GuestAdditionsPath, f, &fi); err != nil {
+		return haltWithError(state, ui, fmt.Errorf("error uploading guest additions: %s", err))
+	}
+
+	if s.UploadMode == "" {
+		return multistep.ActionContinue
+	}
+	if guestOS := GetGuestOS(state); guestOS == GuestOSWindows {
+		log.Printf("Not applying guest_additions_upload_mode on a %s guest.", guestOS)
+		return multistep.ActionContinue
+	}
+
+	mode, err := ParseFileMode(s.UploadMode)
+	if err != nil {
+		return haltWithError(state, ui, fmt.Errorf("guest_additions_upload_mode: %s", err))
+	}
+	cmd := &packersdk.RemoteCmd{
+		Command: fmt.Sprintf("chmod %04o %s", mode, shellQuote(s.GuestAdditionsPath)),
+	}
+	if err := cmd.RunWithUi(ctx, comm, ui); err != nil {
+		return haltWithError(state, ui, fmt.Errorf("error setting guest additions file mode: %s", err))
+	}
+	if status := cmd.ExitStatus(); status != 0 {
+		return haltWithError(state, ui, fmt.Errorf(
+			"setting guest additions file mode exited with status %d", status))
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *StepUploadGuestAdditions) Cleanup(state multistep.StateBag) {}
+
+// shellQuote quotes arg for a POSIX shell.
+func shellQuote(arg string) string {
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
@@ -0,0 +1,97 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+func TestStepUploadGuestAdditions_impl(t *testing.T) {
+	var _ multistep.Step = new(StepUploadGuestAdditions)
+}
+
+func testGuestAdditionsISO(t *testing.T, state multistep.StateBag) {
+	path := filepath.Join(t.TempDir(), "utm-guest-tools.iso")
+	if err := os.WriteFile(path, []byte("iso"), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	state.Put("guest_additions_path", path)
+}
+
+func TestStepUploadGuestAdditions_notUpload(t *testing.T) {
+	state := testState(t)
+	comm := new(packersdk.MockCommunicator)
+	state.Put("communicator", comm)
+
+	step := &StepUploadGuestAdditions{GuestAdditionsMode: GuestAdditionsModeAttach}
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if comm.UploadCalled {
+		t.Fatal("should not upload")
+	}
+}
+
+func TestStepUploadGuestAdditions(t *testing.T) {
+	state := testState(t)
+	testGuestAdditionsISO(t, state)
+	comm := new(packersdk.MockCommunicator)
+	state.Put("communicator", comm)
+
+	step := &StepUploadGuestAdditions{
+		GuestAdditionsMode: GuestAdditionsModeUpload,
+		GuestAdditionsPath: "/tmp/it's here.iso",
+		UploadMode:         "755",
+	}
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if comm.UploadPath != "/tmp/it's here.iso" || comm.UploadData != "iso" {
+		t.Fatalf("bad upload: %q %q", comm.UploadPath, comm.UploadData)
+	}
+	if comm.StartCmd.Command != `chmod 0755 '/tmp/it'\''s here.iso'` {
+		t.Fatalf("bad command: %s", comm.StartCmd.Command)
+	}
+}
+
+func TestStepUploadGuestAdditions_windows(t *testing.T) {
+	state := testState(t)
+	testGuestAdditionsISO(t, state)
+	state.Put(StateGuestOS, GuestOSWindows)
+	comm := new(packersdk.MockCommunicator)
+	state.Put("communicator", comm)
+
+	step := &StepUploadGuestAdditions{
+		GuestAdditionsMode: GuestAdditionsModeUpload,
+		GuestAdditionsPath: "utm-guest-tools.iso",
+		UploadMode:         "0644",
+	}
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if !comm.UploadCalled || comm.StartCalled {
+		t.Fatal("should upload without running chmod")
+	}
+}
+
+func TestStepUploadGuestAdditions_chmodFailure(t *testing.T) {
+	state := testState(t)
+	testGuestAdditionsISO(t, state)
+	state.Put("communicator", &packersdk.MockCommunicator{StartExitStatus: 1})
+
+	step := &StepUploadGuestAdditions{
+		GuestAdditionsMode: GuestAdditionsModeUpload,
+		GuestAdditionsPath: "utm-guest-tools.iso",
+		UploadMode:         "0644",
+	}
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+}
@@ -10,7 +10,6 @@ import (
 	"log"
 
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
-	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 )
 
 // This step uploads a file containing the UTM version, which
@@ -20,9 +19,18 @@ type StepUploadVersion struct {
 }
 
 func (s *StepUploadVersion) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
-	comm := state.Get("communicator").(packersdk.Communicator)
-	driver := state.Get("driver").(Driver)
-	ui := state.Get("ui").(packersdk.Ui)
+	ui, err := getUI(state)
+	if err != nil {
+		return haltWithError(state, nil, err)
+	}
+	comm, err := getCommunicator(state)
+	if err != nil {
+		return haltWithError(state, ui, err)
+	}
+	driver, err := getDriver(state)
+	if err != nil {
+		return haltWithError(state, ui, err)
+	}
 
 	if s.Path == "" {
 		log.Println("UtmVersionFile is empty. Not uploading.")
@@ -0,0 +1,77 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// GuestAdditionsVerifyCommands are the commands run for each guest OS to
+// check that the guest additions are active. They exit with a non-zero
+// status, saying what is missing, when they are not.
+var GuestAdditionsVerifyCommands = map[string]string{
+	GuestOSWindows: `powershell -NoProfile -ExecutionPolicy Bypass -Command "` +
+		`$missing = @('QEMU-GA', 'spice-agent') | Where-Object { ` +
+		`(Get-Service -Name $_ -ErrorAction SilentlyContinue).Status -ne 'Running' }; ` +
+		`if ($missing) { Write-Error ('guest additions services not running: ' + ($missing -join ', ')); exit 1 }"`,
+	GuestOSLinux: `sh -c 'missing=; ` +
+		`pgrep -x qemu-ga >/dev/null || missing="$missing qemu-guest-agent"; ` +
+		`command -v spice-vdagent >/dev/null || missing="$missing spice-vdagent"; ` +
+		`[ -z "$missing" ] || { echo "guest additions not active:$missing" >&2; exit 1; }'`,
+}
+
+// StepVerifyGuestAdditions checks over the communicator that the guest
+// additions are active, failing the build when they are not. It runs after
+// provisioning, so tools installed by a provisioner are checked too.
+//
+// Uses:
+//
+//	communicator packersdk.Communicator
+//	guest_os     string (optional)
+//	ui           packersdk.Ui
+type StepVerifyGuestAdditions struct {
+	Enabled bool
+}
+
+func (s *StepVerifyGuestAdditions) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	if !s.Enabled {
+		return multistep.ActionContinue
+	}
+
+	ui, err := getUI(state)
+	if err != nil {
+		return haltWithError(state, nil, err)
+	}
+	comm, err := getCommunicator(state)
+	if err != nil {
+		return haltWithError(state, ui, err)
+	}
+
+	guestOS := GetGuestOS(state)
+	command, ok := GuestAdditionsVerifyCommands[guestOS]
+	if !ok {
+		ui.Say(fmt.Sprintf("Can't verify the guest additions of a %q guest, skipping...", guestOS))
+		return multistep.ActionContinue
+	}
+
+	ui.Say("Verifying the guest additions are active...")
+	log.Printf("Executing guest additions check: %s", command)
+	cmd := &packersdk.RemoteCmd{Command: command}
+	if err := cmd.RunWithUi(ctx, comm, ui); err != nil {
+		return haltWithError(state, ui, fmt.Errorf("error verifying guest additions: %s", err))
+	}
+	if status := cmd.ExitStatus(); status != 0 {
+		return haltWithError(state, ui, fmt.Errorf(
+			"guest additions are not active, the check exited with status %d", status))
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *StepVerifyGuestAdditions) Cleanup(state multistep.StateBag) {}
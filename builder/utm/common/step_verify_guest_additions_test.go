@@ -0,0 +1,64 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+func TestStepVerifyGuestAdditions_impl(t *testing.T) {
+	var _ multistep.Step = new(StepVerifyGuestAdditions)
+}
+
+func TestStepVerifyGuestAdditions(t *testing.T) {
+	state := testState(t)
+	comm := new(packersdk.MockCommunicator)
+	state.Put("communicator", comm)
+	state.Put(StateGuestOS, GuestOSLinux)
+
+	step := &StepVerifyGuestAdditions{Enabled: true}
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if !strings.Contains(comm.StartCmd.Command, "pgrep -x qemu-ga") {
+		t.Fatalf("should run the Linux check: %q", comm.StartCmd.Command)
+	}
+}
+
+func TestStepVerifyGuestAdditions_notActive(t *testing.T) {
+	state := testState(t)
+	state.Put("communicator", &packersdk.MockCommunicator{StartExitStatus: 1})
+	state.Put(StateGuestOS, GuestOSWindows)
+
+	step := &StepVerifyGuestAdditions{Enabled: true}
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	err, ok := state.GetOk("error")
+	if !ok || !strings.Contains(err.(error).Error(), "guest additions are not active") {
+		t.Fatalf("should fail the build: %v", err)
+	}
+}
+
+func TestStepVerifyGuestAdditions_skip(t *testing.T) {
+	for name, guestOS := range map[string]string{"disabled": GuestOSLinux, "unknown guest": GuestOSDarwin} {
+		state := testState(t)
+		comm := new(packersdk.MockCommunicator)
+		state.Put("communicator", comm)
+		state.Put(StateGuestOS, guestOS)
+
+		step := &StepVerifyGuestAdditions{Enabled: name != "disabled"}
+		if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+			t.Fatalf("%s: bad action: %#v", name, action)
+		}
+		if comm.StartCalled {
+			t.Fatalf("%s: should not run anything", name)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+// This step reads the configuration of the VM back from UTM before
+// StepExport and checks it still has the cpus, memory, hard drives,
+// network_adapters and QEMU arguments it was built with, so a change made
+// while the build was paused, or one UTM didn't apply, fails the build
+// instead of being exported.
+//
+// Uses:
+//
+//	driver       Driver
+//	ui           packersdk.Ui
+//	userQemuArgs []string (optional)
+//	vmId         string
+type StepVerifyVMConfig struct {
+	HWConfig HWConfig
+	// Disks is the number of hard drives the builder created.
+	Disks           int
+	NetworkAdapters []NetworkAdapter
+	SkipExport      bool
+}
+
+func (s *StepVerifyVMConfig) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	if s.SkipExport {
+		return multistep.ActionContinue
+	}
+
+	ui, err := getUI(state)
+	if err != nil {
+		return haltWithError(state, nil, err)
+	}
+	driver, err := getDriver(state)
+	if err != nil {
+		return haltWithError(state, ui, err)
+	}
+	vmId, err := GetVMID(state)
+	if err != nil {
+		return haltWithError(state, ui, err)
+	}
+
+	config, err := driver.GetVMConfig(vmId)
+	if err != nil {
+		// Older UTM versions don't expose every setting the script reads,
+		// which shouldn't fail a build that is otherwise done.
+		ui.Error(fmt.Sprintf("Not verifying the VM configuration before export: %s", err))
+		return multistep.ActionContinue
+	}
+	log.Printf("VM configuration before export: %+v", config)
+
+	var mismatches []string
+	if s.HWConfig.CpuCount > 0 && config.CPUs != s.HWConfig.CpuCount {
+		mismatches = append(mismatches, fmt.Sprintf("cpus is %d, expected %d", config.CPUs, s.HWConfig.CpuCount))
+	}
+	if s.HWConfig.MemorySize > 0 && config.Memory != s.HWConfig.MemorySize {
+		mismatches = append(mismatches, fmt.Sprintf("memory is %d, expected %d", config.Memory, s.HWConfig.MemorySize))
+	}
+	var disks int
+	for _, drive := range config.Drives {
+		if !drive.Removable {
+			disks++
+		}
+	}
+	if disks < s.Disks {
+		mismatches = append(mismatches, fmt.Sprintf("%d hard drives, expected %d", disks, s.Disks))
+	}
+	mismatches = append(mismatches, networkMismatches(config.NetworkInterfaces, s.NetworkAdapters)...)
+	userArgs, _ := state.Get("userQemuArgs").([]string)
+	for _, arg := range userArgs {
+		if !slices.Contains(config.QemuArgs, arg) {
+			mismatches = append(mismatches, fmt.Sprintf("QEMU argument %q is missing", arg))
+		}
+	}
+	if len(mismatches) > 0 {
+		return haltWithError(state, ui, fmt.Errorf(
+			"the VM configuration changed during the build: %s", strings.Join(mismatches, ", ")))
+	}
+	return multistep.ActionContinue
+}
+
+func (s *StepVerifyVMConfig) Cleanup(state multistep.StateBag) {}
+
+// networkMismatches compares the network interfaces of the VM with the
+// network_adapters it was built with. The default adapters, used when
+// there are none, are not checked.
+func networkMismatches(interfaces []NetworkInterface, adapters []NetworkAdapter) []string {
+	if len(adapters) == 0 {
+		return nil
+	}
+	if len(interfaces) != len(adapters) {
+		return []string{fmt.Sprintf("%d network interfaces, expected %d", len(interfaces), len(adapters))}
+	}
+	var mismatches []string
+	for i, adapter := range adapters {
+		if !strings.EqualFold(interfaces[i].Mode, adapter.Mode) {
+			mismatches = append(mismatches, fmt.Sprintf(
+				"network interface %d is %s, expected %s", i, interfaces[i].Mode, adapter.Mode))
+		}
+		if adapter.MAC != "" && !strings.EqualFold(interfaces[i].MAC, adapter.MAC) {
+			mismatches = append(mismatches, fmt.Sprintf(
+				"network interface %d has MAC %s, expected %s", i, interfaces[i].MAC, adapter.MAC))
+		}
+	}
+	return mismatches
+}
@@ -0,0 +1,130 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+func TestStepVerifyVMConfig_impl(t *testing.T) {
+	var _ multistep.Step = new(StepVerifyVMConfig)
+}
+
+func TestStepVerifyVMConfig(t *testing.T) {
+	state := testState(t)
+	state.Put("vmId", "foo")
+	driver := state.Get("driver").(*DriverMock)
+	driver.GetVMConfigResult = VMConfig{CPUs: 2, Memory: 2048}
+	step := &StepVerifyVMConfig{HWConfig: HWConfig{CpuCount: 2, MemorySize: 2048}}
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("error"); ok {
+		t.Fatal("should NOT have error")
+	}
+	if len(driver.GetVMConfigCalls) != 1 || driver.GetVMConfigCalls[0] != "foo" {
+		t.Fatalf("bad calls: %#v", driver.GetVMConfigCalls)
+	}
+}
+
+func TestStepVerifyVMConfig_mismatch(t *testing.T) {
+	state := testState(t)
+	state.Put("vmId", "foo")
+	driver := state.Get("driver").(*DriverMock)
+	driver.GetVMConfigResult = VMConfig{CPUs: 1, Memory: 2048}
+	step := &StepVerifyVMConfig{HWConfig: HWConfig{CpuCount: 2, MemorySize: 2048}}
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	err, ok := state.GetOk("error")
+	if !ok {
+		t.Fatal("should have error")
+	}
+	if !strings.Contains(err.(error).Error(), "cpus is 1, expected 2") {
+		t.Fatalf("bad error: %s", err)
+	}
+}
+
+func TestStepVerifyVMConfig_devices(t *testing.T) {
+	state := testState(t)
+	state.Put("vmId", "foo")
+	state.Put("userQemuArgs", []string{"-rtc base=localtime", "-device virtio-rng-pci"})
+	driver := state.Get("driver").(*DriverMock)
+	driver.GetVMConfigResult = VMConfig{
+		CPUs:   2,
+		Memory: 2048,
+		Drives: []Drive{{ID: "disk"}, {ID: "cd", Removable: true}},
+		NetworkInterfaces: []NetworkInterface{
+			{Index: 0, Mode: "emulated", MAC: "52:54:00:12:34:56"},
+			{Index: 1, Mode: "shared"},
+		},
+		QemuArgs: []string{"-rtc base=localtime"},
+	}
+	step := &StepVerifyVMConfig{
+		HWConfig: HWConfig{CpuCount: 2, MemorySize: 2048},
+		Disks:    2,
+		NetworkAdapters: []NetworkAdapter{
+			{Mode: "emulated", MAC: "52:54:00:12:34:56"},
+			{Mode: "bridged"},
+		},
+	}
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	err := state.Get("error").(error)
+	for _, expected := range []string{
+		"1 hard drives, expected 2",
+		"network interface 1 is shared, expected bridged",
+		`QEMU argument "-device virtio-rng-pci" is missing`,
+	} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Fatalf("should report %q: %s", expected, err)
+		}
+	}
+	if strings.Contains(err.Error(), "interface 0") {
+		t.Fatalf("should accept the matching adapter: %s", err)
+	}
+}
+
+func TestStepVerifyVMConfig_unreadable(t *testing.T) {
+	state := testState(t)
+	state.Put("vmId", "foo")
+	driver := state.Get("driver").(*DriverMock)
+	driver.GetVMConfigErr = errors.New("no such property")
+	step := &StepVerifyVMConfig{HWConfig: HWConfig{CpuCount: 2, MemorySize: 2048}}
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("error"); ok {
+		t.Fatal("should NOT have error")
+	}
+	ui := state.Get("ui").(*packersdk.BasicUi)
+	if !strings.Contains(ui.Writer.(*bytes.Buffer).String(), "Not verifying") {
+		t.Fatal("should warn about the unverified configuration")
+	}
+}
+
+func TestStepVerifyVMConfig_skipExport(t *testing.T) {
+	state := testState(t)
+	driver := state.Get("driver").(*DriverMock)
+	step := &StepVerifyVMConfig{SkipExport: true}
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if len(driver.GetVMConfigCalls) != 0 {
+		t.Fatal("should not read the config when skipping the export")
+	}
+}
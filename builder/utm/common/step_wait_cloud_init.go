@@ -0,0 +1,122 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// cloudInitStatusCommand prints the status of cloud-init, exiting with 127
+// when the guest doesn't have it. The status is polled rather than waited
+// for with --wait, so that the timeout holds whatever the communicator.
+const cloudInitStatusCommand = `sh -c 'command -v cloud-init >/dev/null 2>&1 || exit 127; cloud-init status'`
+
+// StepWaitCloudInit waits for cloud-init to finish in the guest, polling
+// its status every Interval until Timeout.
+//
+// Uses:
+//
+//	communicator packersdk.Communicator
+//	ui           packersdk.Ui
+type StepWaitCloudInit struct {
+	Enabled  bool
+	Timeout  time.Duration
+	Interval time.Duration
+}
+
+func (s *StepWaitCloudInit) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	if !s.Enabled {
+		return multistep.ActionContinue
+	}
+
+	ui, err := getUI(state)
+	if err != nil {
+		return haltWithError(state, nil, err)
+	}
+	comm, err := getCommunicator(state)
+	if err != nil {
+		return haltWithError(state, ui, err)
+	}
+
+	interval := s.Interval
+	if interval == 0 {
+		interval = 5 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, s.Timeout)
+	defer cancel()
+
+	ui.Say("Waiting for cloud-init to finish...")
+	for {
+		status, err := cloudInitStatus(ctx, comm)
+		switch {
+		case err != nil:
+			log.Printf("Reading the cloud-init status failed: %s", err)
+		case status == "":
+			ui.Error("Warning: cloud-init is not installed in the guest, not waiting for it")
+			return multistep.ActionContinue
+		case status == "done":
+			ui.Say("cloud-init finished")
+			return multistep.ActionContinue
+		case status == "disabled":
+			ui.Error("Warning: cloud-init is disabled in the guest, not waiting for it")
+			return multistep.ActionContinue
+		case strings.HasPrefix(status, "degraded"):
+			ui.Error(fmt.Sprintf("Warning: cloud-init finished with recoverable errors (%s), "+
+				"see /var/log/cloud-init.log in the guest", status))
+			return multistep.ActionContinue
+		case status == "error":
+			return haltWithError(state, ui, fmt.Errorf(
+				"cloud-init failed, see /var/log/cloud-init.log in the guest"))
+		default:
+			log.Printf("cloud-init status: %s", status)
+		}
+
+		select {
+		case <-ctx.Done():
+			if ctx.Err() == context.DeadlineExceeded {
+				return haltWithError(state, ui, fmt.Errorf(
+					"cloud-init didn't finish within %s (cloud_init_timeout)", s.Timeout))
+			}
+			return haltWithError(state, ui, ctx.Err())
+		case <-time.After(interval):
+		}
+	}
+}
+
+func (s *StepWaitCloudInit) Cleanup(state multistep.StateBag) {}
+
+// cloudInitStatus returns the status cloud-init reports, such as "running"
+// or "done", or "" when the guest doesn't have cloud-init.
+func cloudInitStatus(ctx context.Context, comm packersdk.Communicator) (string, error) {
+	var stdout bytes.Buffer
+	cmd := &packersdk.RemoteCmd{
+		Command: cloudInitStatusCommand,
+		Stdout:  &stdout,
+		Stderr:  new(bytes.Buffer),
+	}
+	if err := comm.Start(ctx, cmd); err != nil {
+		return "", err
+	}
+	// cloud-init status exits with 1 on errors and with 2 when degraded,
+	// the status line tells which.
+	status := cmd.Wait()
+	if status == 127 {
+		return "", nil
+	}
+	for _, line := range strings.Split(stdout.String(), "\n") {
+		if value, ok := strings.CutPrefix(strings.TrimSpace(line), "status:"); ok {
+			return strings.TrimSpace(value), nil
+		}
+	}
+	return "", fmt.Errorf("cloud-init status exited with status %d without a status: %q",
+		status, stdout.String())
+}
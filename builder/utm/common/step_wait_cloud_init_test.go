@@ -0,0 +1,83 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+func TestStepWaitCloudInit_impl(t *testing.T) {
+	var _ multistep.Step = new(StepWaitCloudInit)
+}
+
+func TestStepWaitCloudInit_disabled(t *testing.T) {
+	state := testState(t)
+	comm := new(packersdk.MockCommunicator)
+	state.Put("communicator", comm)
+
+	step := &StepWaitCloudInit{}
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if comm.StartCalled {
+		t.Fatal("should not run anything")
+	}
+}
+
+func TestStepWaitCloudInit(t *testing.T) {
+	cases := map[string]multistep.StepAction{
+		"status: done\n":                    multistep.ActionContinue,
+		"\nstatus: disabled\n":              multistep.ActionContinue,
+		"status: degraded done\n":           multistep.ActionContinue,
+		"status: error\nerrors: [boom]\n":   multistep.ActionHalt,
+		"unexpected output from the guest ": multistep.ActionHalt,
+	}
+	for stdout, expected := range cases {
+		state := testState(t)
+		comm := &packersdk.MockCommunicator{StartStdout: stdout}
+		state.Put("communicator", comm)
+
+		step := &StepWaitCloudInit{Enabled: true, Timeout: 20 * time.Millisecond, Interval: time.Millisecond}
+		if action := step.Run(context.Background(), state); action != expected {
+			t.Fatalf("%q: expected %#v, got %#v", stdout, expected, action)
+		}
+		if comm.StartCmd.Command != cloudInitStatusCommand {
+			t.Fatalf("bad command: %s", comm.StartCmd.Command)
+		}
+	}
+}
+
+func TestStepWaitCloudInit_timeout(t *testing.T) {
+	state := testState(t)
+	state.Put("communicator", &packersdk.MockCommunicator{StartStdout: "status: running\n"})
+
+	step := &StepWaitCloudInit{Enabled: true, Timeout: 20 * time.Millisecond, Interval: time.Millisecond}
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if err := state.Get("error").(error); !strings.Contains(err.Error(), "cloud_init_timeout") {
+		t.Fatalf("bad error: %s", err)
+	}
+}
+
+func TestStepWaitCloudInit_notInstalled(t *testing.T) {
+	state := testState(t)
+	state.Put("communicator", &packersdk.MockCommunicator{StartExitStatus: 127})
+
+	step := &StepWaitCloudInit{Enabled: true, Timeout: time.Second}
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	ui := state.Get("ui").(*packersdk.BasicUi)
+	if !strings.Contains(ui.Writer.(*bytes.Buffer).String(), "not installed") {
+		t.Fatal("should warn that cloud-init is missing")
+	}
+}
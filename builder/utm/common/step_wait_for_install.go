@@ -0,0 +1,114 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+// StepWaitForInstall waits up to Timeout for the guest to answer on the
+// communicator port, so that the communicator timeout only starts once the
+// installer is done. QEMU accepts connections on a forwarded port before
+// anything listens in the guest and closes them right away, so the guest
+// only counts as answering once it sends something back: the SSH banner,
+// or the reply to an HTTP request for WinRM.
+//
+// Uses:
+//
+//	commHostPort int
+//	ui           packersdk.Ui
+type StepWaitForInstall struct {
+	Timeout time.Duration
+	// Host returns the host to probe, see CommHost.
+	Host     func(multistep.StateBag) (string, error)
+	CommType string
+
+	// interval is how often the port is probed, 5s when zero.
+	interval time.Duration
+}
+
+func (s *StepWaitForInstall) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	if s.Timeout == 0 || s.CommType == "none" {
+		return multistep.ActionContinue
+	}
+
+	ui, err := getUI(state)
+	if err != nil {
+		return haltWithError(state, nil, err)
+	}
+	port, ok := state.Get("commHostPort").(int)
+	if !ok || port == 0 {
+		return haltWithError(state, ui, errors.New("commHostPort is not set in state"))
+	}
+	interval := s.interval
+	if interval == 0 {
+		interval = 5 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, s.Timeout)
+	defer cancel()
+
+	ui.Say(fmt.Sprintf("Waiting up to %s for the install to finish and the guest to answer on port %d...", s.Timeout, port))
+	for {
+		if err := s.answering(ctx, state, port); err != nil {
+			log.Printf("Guest not answering yet: %s", err)
+		} else {
+			ui.Say("Guest is answering on the communicator port")
+			return multistep.ActionContinue
+		}
+
+		if err := sleepCtx(ctx, interval); err != nil {
+			if errors.Is(err, context.DeadlineExceeded) {
+				return haltWithError(state, ui, fmt.Errorf(
+					"guest didn't answer on port %d within %s (install_timeout)", port, s.Timeout))
+			}
+			return haltWithError(state, ui, fmt.Errorf("interrupted while waiting for the install: %w", err))
+		}
+	}
+}
+
+// answering probes the communicator port of the guest, looking its host
+// up again each time as the guest may not have an address yet.
+func (s *StepWaitForInstall) answering(ctx context.Context, state multistep.StateBag, port int) error {
+	host, err := s.Host(state)
+	if err != nil {
+		return err
+	}
+	address := net.JoinHostPort(host, strconv.Itoa(port))
+	if err := s.probe(ctx, address); err != nil {
+		return fmt.Errorf("%s: %s", address, err)
+	}
+	return nil
+}
+
+// probe connects to address and reads a byte, asking for it first when the
+// server doesn't talk first.
+func (s *StepWaitForInstall) probe(ctx context.Context, address string) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if err := conn.SetDeadline(time.Now().Add(10 * time.Second)); err != nil {
+		return err
+	}
+	if s.CommType == "winrm" {
+		if _, err := conn.Write([]byte("HEAD /wsman HTTP/1.0\r\n\r\n")); err != nil {
+			return err
+		}
+	}
+	_, err = conn.Read(make([]byte, 1))
+	return err
+}
+
+func (s *StepWaitForInstall) Cleanup(state multistep.StateBag) {}
@@ -0,0 +1,82 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+func TestStepWaitForInstall_impl(t *testing.T) {
+	var _ multistep.Step = new(StepWaitForInstall)
+}
+
+// listen starts a listener on a free local port, handing each connection to
+// handle, and returns the port.
+func listen(t *testing.T, handle func(net.Conn)) int {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			handle(conn)
+			conn.Close()
+		}
+	}()
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+func TestStepWaitForInstall(t *testing.T) {
+	port := listen(t, func(conn net.Conn) {
+		conn.Write([]byte("SSH-2.0-OpenSSH_9.6\r\n"))
+	})
+	state := testState(t)
+	state.Put("commHostPort", port)
+
+	step := &StepWaitForInstall{Timeout: time.Minute, Host: CommHost("127.0.0.1"), CommType: "ssh"}
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v %v", action, state.Get("error"))
+	}
+}
+
+func TestStepWaitForInstall_closed(t *testing.T) {
+	// Like QEMU forwarding to a guest port nothing listens on yet.
+	port := listen(t, func(conn net.Conn) {})
+	state := testState(t)
+	state.Put("commHostPort", port)
+
+	step := &StepWaitForInstall{
+		Timeout:  50 * time.Millisecond,
+		Host:     CommHost("127.0.0.1"),
+		CommType: "ssh",
+		interval: time.Millisecond,
+	}
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	err, ok := state.GetOk("error")
+	if !ok || !strings.Contains(err.(error).Error(), "within 50ms (install_timeout)") {
+		t.Fatalf("should time out waiting for the guest: %v", err)
+	}
+}
+
+func TestStepWaitForInstall_disabled(t *testing.T) {
+	state := testState(t)
+
+	step := new(StepWaitForInstall)
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// NetworkCheckCommands are the commands exiting with 0 once the guest
+// network is up, that is once it has a default route or resolves a host
+// name, for Unix guests and for Windows.
+var NetworkCheckCommands = map[string]string{
+	"unix": `sh -c '` +
+		`if command -v ip >/dev/null 2>&1; then ip route show default 2>/dev/null | grep -q . && exit 0; ` +
+		`else netstat -rn 2>/dev/null | grep -Eq "^(default|0\.0\.0\.0) " && exit 0; fi; ` +
+		`if command -v getent >/dev/null 2>&1; then getent hosts example.com >/dev/null 2>&1 && exit 0; ` +
+		`else host example.com >/dev/null 2>&1 && exit 0; fi; exit 1'`,
+	GuestOSWindows: `powershell -NoProfile -Command "` +
+		`if (Get-NetRoute -DestinationPrefix '0.0.0.0/0' -ErrorAction SilentlyContinue) { exit 0 }; ` +
+		`try { [System.Net.Dns]::GetHostEntry('example.com') | Out-Null; exit 0 } catch { exit 1 }"`,
+}
+
+// StepWaitForNetwork waits for the guest network to come up, running the
+// check for the guest every Interval until Timeout. It runs after
+// StepDetectGuestOS, and picks the check from the communicator when the
+// guest OS is unknown.
+//
+// Uses:
+//
+//	communicator packersdk.Communicator
+//	guest_os     string (optional)
+//	ui           packersdk.Ui
+type StepWaitForNetwork struct {
+	Enabled  bool
+	Timeout  time.Duration
+	Interval time.Duration
+	CommType string
+}
+
+func (s *StepWaitForNetwork) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	if !s.Enabled {
+		return multistep.ActionContinue
+	}
+
+	ui, err := getUI(state)
+	if err != nil {
+		return haltWithError(state, nil, err)
+	}
+	comm, err := getCommunicator(state)
+	if err != nil {
+		return haltWithError(state, ui, err)
+	}
+
+	command := NetworkCheckCommands["unix"]
+	guestOS := GetGuestOS(state)
+	if guestOS == GuestOSWindows || (guestOS == "" && s.CommType == "winrm") {
+		command = NetworkCheckCommands[GuestOSWindows]
+	}
+
+	interval := s.Interval
+	if interval == 0 {
+		interval = 2 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, s.Timeout)
+	defer cancel()
+
+	ui.Say("Waiting for the guest network...")
+	for {
+		cmd := &packersdk.RemoteCmd{
+			Command: command,
+			Stdout:  new(bytes.Buffer),
+			Stderr:  new(bytes.Buffer),
+		}
+		if err := comm.Start(ctx, cmd); err != nil {
+			log.Printf("Checking the guest network failed: %s", err)
+		} else if status := cmd.Wait(); status == 0 {
+			ui.Say("Guest network is up")
+			return multistep.ActionContinue
+		} else {
+			log.Printf("Guest network is not up yet (status %d)", status)
+		}
+
+		select {
+		case <-ctx.Done():
+			if ctx.Err() == context.DeadlineExceeded {
+				return haltWithError(state, ui, fmt.Errorf(
+					"guest network didn't come up within %s (wait_for_network_timeout)", s.Timeout))
+			}
+			return haltWithError(state, ui, ctx.Err())
+		case <-time.After(interval):
+		}
+	}
+}
+
+func (s *StepWaitForNetwork) Cleanup(state multistep.StateBag) {}
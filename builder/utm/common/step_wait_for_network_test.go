@@ -0,0 +1,75 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+func TestStepWaitForNetwork_impl(t *testing.T) {
+	var _ multistep.Step = new(StepWaitForNetwork)
+}
+
+func TestStepWaitForNetwork_disabled(t *testing.T) {
+	state := testState(t)
+	comm := new(packersdk.MockCommunicator)
+	state.Put("communicator", comm)
+
+	step := &StepWaitForNetwork{}
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if comm.StartCalled {
+		t.Fatal("should not run anything")
+	}
+}
+
+func TestStepWaitForNetwork(t *testing.T) {
+	cases := []struct {
+		guestOS  string
+		commType string
+		expected string
+	}{
+		{GuestOSLinux, "ssh", NetworkCheckCommands["unix"]},
+		{GuestOSFreeBSD, "ssh", NetworkCheckCommands["unix"]},
+		{GuestOSWindows, "ssh", NetworkCheckCommands[GuestOSWindows]},
+		{"", "winrm", NetworkCheckCommands[GuestOSWindows]},
+		{"", "ssh", NetworkCheckCommands["unix"]},
+	}
+	for _, tc := range cases {
+		state := testState(t)
+		if tc.guestOS != "" {
+			state.Put(StateGuestOS, tc.guestOS)
+		}
+		comm := new(packersdk.MockCommunicator)
+		state.Put("communicator", comm)
+
+		step := &StepWaitForNetwork{Enabled: true, Timeout: time.Second, CommType: tc.commType}
+		if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+			t.Fatalf("%s/%s: bad action: %#v", tc.guestOS, tc.commType, action)
+		}
+		if comm.StartCmd.Command != tc.expected {
+			t.Fatalf("%s/%s: bad command: %s", tc.guestOS, tc.commType, comm.StartCmd.Command)
+		}
+	}
+}
+
+func TestStepWaitForNetwork_timeout(t *testing.T) {
+	state := testState(t)
+	state.Put("communicator", &packersdk.MockCommunicator{StartExitStatus: 1})
+
+	step := &StepWaitForNetwork{Enabled: true, Timeout: 20 * time.Millisecond, Interval: time.Millisecond}
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if err := state.Get("error").(error); !strings.Contains(err.Error(), "wait_for_network_timeout") {
+		t.Fatalf("bad error: %s", err)
+	}
+}
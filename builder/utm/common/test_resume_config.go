@@ -0,0 +1,54 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:generate packer-sdc struct-markdown
+
+package common
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// DefaultTestResumeTimeout is how long the builders wait for the VM to
+// answer again after resuming it when test_resume is set.
+const DefaultTestResumeTimeout = 5 * time.Minute
+
+type TestResumeConfig struct {
+	// Set this to true to suspend the VM once provisioning is done, saving
+	// its state, then resume it and wait for the communicator to answer
+	// again before going on with the build. It checks that the image
+	// survives a warm start, which matters for VMs exported with
+	// `suspend_before_export`. The build fails when UTM can't save the state
+	// of the VM. Defaults to false.
+	TestResume bool `mapstructure:"test_resume" required:"false"`
+	// Commands run over the communicator once the VM has resumed with
+	// `test_resume`, failing the build when one exits with a non-zero
+	// status. Packer runs a template's provisioners in a single phase, so
+	// put the post-resume checks here rather than in a provisioner.
+	TestResumeCommands []string `mapstructure:"test_resume_commands" required:"false"`
+	// How long to wait for the VM to run and the communicator to answer
+	// after resuming it with `test_resume`. Defaults to 5m.
+	TestResumeTimeout time.Duration `mapstructure:"test_resume_timeout" required:"false"`
+}
+
+func (c *TestResumeConfig) Prepare(commType string) []error {
+	var errs []error
+
+	if c.TestResumeTimeout == 0 {
+		c.TestResumeTimeout = DefaultTestResumeTimeout
+	}
+	if c.TestResumeTimeout < 0 {
+		errs = append(errs, fmt.Errorf(
+			"test_resume_timeout must not be negative, got %s", c.TestResumeTimeout))
+	}
+	if len(c.TestResumeCommands) > 0 && !c.TestResume {
+		errs = append(errs, errors.New("test_resume_commands can only be used with test_resume"))
+	}
+	if c.TestResume && commType == "none" {
+		errs = append(errs, errors.New("test_resume needs a communicator"))
+	}
+
+	return errs
+}
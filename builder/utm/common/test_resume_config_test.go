@@ -0,0 +1,33 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"testing"
+)
+
+func TestTestResumeConfigPrepare(t *testing.T) {
+	c := new(TestResumeConfig)
+	if errs := c.Prepare("ssh"); len(errs) > 0 {
+		t.Fatalf("err: %#v", errs)
+	}
+	if c.TestResumeTimeout != DefaultTestResumeTimeout {
+		t.Fatalf("bad timeout: %s", c.TestResumeTimeout)
+	}
+
+	c = &TestResumeConfig{TestResumeCommands: []string{"uptime"}}
+	if errs := c.Prepare("ssh"); len(errs) != 1 {
+		t.Fatalf("should need test_resume: %#v", errs)
+	}
+
+	c = &TestResumeConfig{TestResume: true}
+	if errs := c.Prepare("none"); len(errs) != 1 {
+		t.Fatalf("should need a communicator: %#v", errs)
+	}
+
+	c = &TestResumeConfig{TestResume: true, TestResumeTimeout: -1}
+	if errs := c.Prepare("ssh"); len(errs) != 1 {
+		t.Fatalf("should reject a negative timeout: %#v", errs)
+	}
+}
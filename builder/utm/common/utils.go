@@ -1,6 +1,10 @@
 package common
 
-import "fmt"
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
 
 // Map of controller names to their corresponding enum codes
 var ControllerEnumMap = map[string]string{
@@ -16,11 +20,33 @@ var ControllerEnumMap = map[string]string{
 	"usb":    "QdIu",
 }
 
-// Function to get the UTM enum code for a given controller name
+// Function to get the UTM enum code for a given controller name, in any case
 func GetControllerEnumCode(controllerName string) (string, error) {
-	code, exists := ControllerEnumMap[controllerName]
+	code, exists := ControllerEnumMap[strings.ToLower(controllerName)]
 	if !exists {
 		return "", fmt.Errorf("invalid controller name: %s", controllerName)
 	}
 	return code, nil
 }
+
+// driveInterfacesByArch lists the drive interfaces the QEMU machine UTM
+// picks for an architecture has. The virt machine of the Arm architectures
+// has no IDE or floppy controller.
+var driveInterfacesByArch = map[string][]string{
+	"aarch64": {"none", "scsi", "sd", "mtd", "pflash", "virtio", "nvme", "usb"},
+	"arm":     {"none", "scsi", "sd", "mtd", "pflash", "virtio", "nvme", "usb"},
+	"x86_64":  {"none", "ide", "scsi", "sd", "mtd", "floppy", "pflash", "virtio", "nvme", "usb"},
+	"i386":    {"none", "ide", "scsi", "sd", "mtd", "floppy", "pflash", "virtio", "nvme", "usb"},
+}
+
+// DriveInterfacesForArch returns the drive interfaces a VM of the given
+// architecture supports, sorted, or nil when they are not known.
+func DriveInterfacesForArch(arch string) []string {
+	interfaces, ok := driveInterfacesByArch[arch]
+	if !ok {
+		return nil
+	}
+	sorted := append([]string(nil), interfaces...)
+	sort.Strings(sorted)
+	return sorted
+}
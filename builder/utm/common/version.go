@@ -0,0 +1,98 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// UTMVersion is a UTM release version, without any build number or channel
+// suffix, that can be compared with other versions.
+type UTMVersion struct {
+	Major int
+	Minor int
+	Patch int
+}
+
+// utmVersionRe matches the first major.minor[.patch] in a version string,
+// so "4.6.4", "v4.6.4", "4.6.4 (99)" and "4.7.0-beta" all parse.
+var utmVersionRe = regexp.MustCompile(`(\d+)\.(\d+)(?:\.(\d+))?`)
+
+// ParseUTMVersion normalizes a version string as reported by UTM.
+func ParseUTMVersion(raw string) (UTMVersion, error) {
+	matches := utmVersionRe.FindStringSubmatch(raw)
+	if matches == nil {
+		return UTMVersion{}, fmt.Errorf("no version found: %q", raw)
+	}
+
+	var v UTMVersion
+	var err error
+	if v.Major, err = strconv.Atoi(matches[1]); err != nil {
+		return UTMVersion{}, fmt.Errorf("invalid version %q: %s", raw, err)
+	}
+	if v.Minor, err = strconv.Atoi(matches[2]); err != nil {
+		return UTMVersion{}, fmt.Errorf("invalid version %q: %s", raw, err)
+	}
+	if matches[3] != "" {
+		if v.Patch, err = strconv.Atoi(matches[3]); err != nil {
+			return UTMVersion{}, fmt.Errorf("invalid version %q: %s", raw, err)
+		}
+	}
+	return v, nil
+}
+
+// String returns the version as major.minor.patch.
+func (v UTMVersion) String() string {
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+// MajorMinor returns the version as major.minor.
+func (v UTMVersion) MajorMinor() string {
+	return fmt.Sprintf("%d.%d", v.Major, v.Minor)
+}
+
+// Compare returns -1, 0 or 1 when v is older than, the same as, or newer
+// than other.
+func (v UTMVersion) Compare(other UTMVersion) int {
+	for _, d := range []int{v.Major - other.Major, v.Minor - other.Minor, v.Patch - other.Patch} {
+		if d < 0 {
+			return -1
+		}
+		if d > 0 {
+			return 1
+		}
+	}
+	return 0
+}
+
+// AtLeast reports whether v is the same as or newer than min.
+func (v UTMVersion) AtLeast(min UTMVersion) bool {
+	return v.Compare(min) >= 0
+}
+
+// RequireUTMVersion fails with an actionable error when the installed UTM is
+// older than min. feature names what needs the newer version. NewDriver
+// already rejects UTM older than 4.5, so only features added later need it.
+func RequireUTMVersion(driver Driver, min string, feature string) error {
+	minVersion, err := ParseUTMVersion(min)
+	if err != nil {
+		return err
+	}
+
+	raw, err := driver.Version()
+	if err != nil {
+		return fmt.Errorf("error reading UTM version for %s: %s", feature, err)
+	}
+	version, err := ParseUTMVersion(raw)
+	if err != nil {
+		return fmt.Errorf("error reading UTM version for %s: %s", feature, err)
+	}
+
+	if !version.AtLeast(minVersion) {
+		return fmt.Errorf("%s needs UTM >= %s, you have %s", feature, minVersion, version)
+	}
+	return nil
+}
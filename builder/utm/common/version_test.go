@@ -0,0 +1,89 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"testing"
+)
+
+func TestParseUTMVersion(t *testing.T) {
+	cases := map[string]string{
+		"4.6.4":               "4.6.4",
+		"4.6.4 (99)":          "4.6.4",
+		"4.6.4(99)":           "4.6.4",
+		"v4.7.0":              "4.7.0",
+		"4.7.0-beta":          "4.7.0",
+		"4.5":                 "4.5.0",
+		"Version 4.6.5 (101)": "4.6.5",
+		" 4.6.4\n":            "4.6.4",
+		"10.12.3":             "10.12.3",
+	}
+
+	for raw, expected := range cases {
+		v, err := ParseUTMVersion(raw)
+		if err != nil {
+			t.Fatalf("%q: err: %s", raw, err)
+		}
+		if v.String() != expected {
+			t.Fatalf("%q: bad version: %s", raw, v)
+		}
+	}
+}
+
+func TestParseUTMVersion_invalid(t *testing.T) {
+	for _, raw := range []string{"", "UTM", "4", "get application"} {
+		if _, err := ParseUTMVersion(raw); err == nil {
+			t.Fatalf("%q: should error", raw)
+		}
+	}
+}
+
+func TestUTMVersionCompare(t *testing.T) {
+	parse := func(raw string) UTMVersion {
+		v, err := ParseUTMVersion(raw)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		return v
+	}
+
+	if !parse("4.6.4 (99)").AtLeast(parse("4.6.4")) {
+		t.Fatal("build suffix should not affect comparison")
+	}
+	if !parse("4.10.0").AtLeast(parse("4.9.1")) {
+		t.Fatal("minor versions should compare numerically")
+	}
+	if parse("4.5.2").AtLeast(parse("4.6")) {
+		t.Fatal("4.5.2 should be older than 4.6")
+	}
+	if parse("4.6.4").Compare(parse("4.6.5")) != -1 || parse("5.0").Compare(parse("4.7.9")) != 1 {
+		t.Fatal("bad comparison")
+	}
+	if parse("4.6").MajorMinor() != "4.6" {
+		t.Fatal("bad major.minor")
+	}
+}
+
+func TestRequireUTMVersion(t *testing.T) {
+	driver := new(DriverMock)
+
+	driver.VersionResult = "4.6.4 (99)"
+	if err := RequireUTMVersion(driver, "4.6.0", "feature"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	driver.VersionResult = "4.5.2"
+	err := RequireUTMVersion(driver, "4.6.0", "feature")
+	if err == nil {
+		t.Fatal("should error for an older UTM")
+	}
+	if err.Error() != "feature needs UTM >= 4.6.0, you have 4.5.2" {
+		t.Fatalf("bad error: %s", err)
+	}
+
+	driver.VersionResult = ""
+	if err := RequireUTMVersion(driver, "4.6.0", "feature"); err == nil {
+		t.Fatal("should error when the version is unknown")
+	}
+}
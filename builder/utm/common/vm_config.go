@@ -0,0 +1,128 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// VMConfig is the configuration of a VM, as reported by UTM.
+type VMConfig struct {
+	Name string
+	// Architecture is empty for a VM of the apple backend.
+	Architecture string
+	CPUs         int
+	// Memory is in megabytes.
+	Memory int
+	UEFI   bool
+
+	Drives            []Drive
+	NetworkInterfaces []NetworkInterface
+	QemuArgs          []string
+}
+
+// utmDocumentsDir is where UTM keeps the VM bundles it creates.
+func utmDocumentsDir() string {
+	return filepath.Join(os.Getenv("HOME"), "Library/Containers/com.utmapp.UTM/Data/Documents")
+}
+
+// vmBundlePath returns the path of the .utm bundle of the VM, found from the
+// images of its drives, which UTM keeps in the Data directory of the bundle.
+// It falls back to the bundle UTM would create in its container for a VM
+// without any.
+func vmBundlePath(config VMConfig) string {
+	for _, drive := range config.Drives {
+		if drive.Removable {
+			continue
+		}
+		if i := strings.Index(drive.Source, ".utm/Data/"); i >= 0 {
+			return drive.Source[:i+len(".utm")]
+		}
+	}
+	return filepath.Join(utmDocumentsDir(), config.Name+".utm")
+}
+
+// NetworkInterface is a network interface of a VM, as reported by UTM.
+type NetworkInterface struct {
+	Index int
+	// Mode is one of the network modes, such as emulated.
+	Mode string
+	MAC  string
+}
+
+// networkModeName returns the network mode UTM reported as mode, either
+// its name or its code, such as EmUd.
+func networkModeName(mode string) string {
+	for name, code := range networkModeCodes {
+		if strings.Contains(mode, code) {
+			return name
+		}
+	}
+	mode = strings.ToLower(strings.TrimSpace(mode))
+	for name := range networkModeCodes {
+		if strings.HasPrefix(mode, name) {
+			return name
+		}
+	}
+	return mode
+}
+
+// parseVMConfig reads the output of get_vm_config.applescript, one setting
+// per line with its key and values separated by tabs.
+func parseVMConfig(output string) (VMConfig, error) {
+	var config VMConfig
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "\t")
+		if !ok {
+			return VMConfig{}, fmt.Errorf("unexpected VM configuration line: %q", line)
+		}
+
+		var err error
+		switch key {
+		case "name":
+			config.Name = value
+		case "architecture":
+			config.Architecture = strings.TrimSpace(value)
+		case "cpus":
+			config.CPUs, err = strconv.Atoi(strings.TrimSpace(value))
+		case "memory":
+			config.Memory, err = strconv.Atoi(strings.TrimSpace(value))
+		case "uefi":
+			config.UEFI = strings.TrimSpace(value) == "true"
+		case "drive":
+			var drives []Drive
+			drives, err = parseDrives(value)
+			config.Drives = append(config.Drives, drives...)
+		case "network":
+			fields := strings.SplitN(value, "\t", 3)
+			if len(fields) != 3 {
+				return VMConfig{}, fmt.Errorf("unexpected VM configuration line: %q", line)
+			}
+			var index int
+			index, err = strconv.Atoi(strings.TrimSpace(fields[0]))
+			config.NetworkInterfaces = append(config.NetworkInterfaces, NetworkInterface{
+				Index: index,
+				Mode:  networkModeName(fields[1]),
+				MAC:   strings.ToLower(strings.TrimSpace(fields[2])),
+			})
+		case "qemuarg":
+			config.QemuArgs = append(config.QemuArgs, value)
+		default:
+			// Settings added by later versions of the script.
+			continue
+		}
+		if err != nil {
+			return VMConfig{}, fmt.Errorf("unexpected VM configuration line %q: %s", line, err)
+		}
+	}
+	return config, nil
+}
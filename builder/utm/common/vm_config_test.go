@@ -0,0 +1,92 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseVMConfig(t *testing.T) {
+	output := "name\tubuntu build\n" +
+		"architecture\taarch64\n" +
+		"cpus\t4\n" +
+		"memory\t4096\n" +
+		"uefi\ttrue\n" +
+		"drive\t7FB247A3-DC9F-4A61-A123-0AEE1BEEC636\tvirtio\tfalse\t/Users/me/vm.utm/Data/disk.qcow2\n" +
+		"drive\t0AEE1BEE-DC9F-4A61-A123-7FB247A3C636\tUSB\ttrue\t\n" +
+		"network\t0\temulated\t7A:5E:01:02:03:04\n" +
+		"network\t1\t«constant ****ShRd»\t\r\n" +
+		"qemuarg\t-device virtio-rng-pci\n" +
+		"display\tvirtio-gpu-pci\n"
+
+	config, err := parseVMConfig(output)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	expected := VMConfig{
+		Name:         "ubuntu build",
+		Architecture: "aarch64",
+		CPUs:         4,
+		Memory:       4096,
+		UEFI:         true,
+		Drives: []Drive{
+			{ID: "7FB247A3-DC9F-4A61-A123-0AEE1BEEC636", Interface: "virtio", Source: "/Users/me/vm.utm/Data/disk.qcow2"},
+			{ID: "0AEE1BEE-DC9F-4A61-A123-7FB247A3C636", Interface: "usb", Removable: true},
+		},
+		NetworkInterfaces: []NetworkInterface{
+			{Index: 0, Mode: "emulated", MAC: "7a:5e:01:02:03:04"},
+			{Index: 1, Mode: "shared"},
+		},
+		QemuArgs: []string{"-device virtio-rng-pci"},
+	}
+	if !reflect.DeepEqual(config, expected) {
+		t.Fatalf("bad config: %#v", config)
+	}
+}
+
+func TestParseVMConfig_apple(t *testing.T) {
+	config, err := parseVMConfig("name\tmacos\ncpus\t2\nmemory\t8192\n")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	expected := VMConfig{Name: "macos", CPUs: 2, Memory: 8192}
+	if !reflect.DeepEqual(config, expected) {
+		t.Fatalf("bad config: %#v", config)
+	}
+}
+
+func TestParseVMConfig_malformed(t *testing.T) {
+	for _, output := range []string{
+		"cpus 4\n",
+		"cpus\tfour\n",
+		"drive\t7FB247A3-DC9F-4A61-A123-0AEE1BEEC636\tvirtio\n",
+		"network\t0\temulated\n",
+		"network\tfirst\temulated\t\n",
+	} {
+		if _, err := parseVMConfig(output); err == nil {
+			t.Fatalf("should reject %q", output)
+		}
+	}
+}
+
+func TestVMBundlePath(t *testing.T) {
+	config := VMConfig{
+		Name: "foo",
+		Drives: []Drive{
+			{ID: "cd", Removable: true, Source: "/isos/foo.utm/Data/seed.iso"},
+			{ID: "disk", Source: "/Volumes/vms/foo.utm/Data/disk.qcow2"},
+		},
+	}
+	if path := vmBundlePath(config); path != "/Volumes/vms/foo.utm" {
+		t.Fatalf("bad path: %s", path)
+	}
+
+	config.Drives = nil
+	expected := filepath.Join(utmDocumentsDir(), "foo.utm")
+	if path := vmBundlePath(config); path != expected {
+		t.Fatalf("bad fallback path: %s", path)
+	}
+}
@@ -0,0 +1,34 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// ValidateVMName checks that name can be the name of a UTM VM. UTM stores
+// the VM as the bundle <name>.utm in its documents directory, so the name
+// must be a valid file name: no path separator, no colon, which Finder shows
+// as a slash, no control character and no leading dot, which would hide the
+// bundle.
+func ValidateVMName(name string) error {
+	if strings.TrimSpace(name) == "" {
+		return errors.New("vm_name must not be empty")
+	}
+	if strings.HasPrefix(name, ".") {
+		return fmt.Errorf("vm_name %q must not start with a dot", name)
+	}
+	for _, r := range name {
+		if r == '/' || r == ':' || unicode.IsControl(r) {
+			return fmt.Errorf("vm_name %q must not contain %q", name, r)
+		}
+	}
+	if len(name+".utm") > 255 {
+		return fmt.Errorf("vm_name %q is longer than the 251 bytes a bundle name allows", name)
+	}
+	return nil
+}
@@ -0,0 +1,22 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateVMName(t *testing.T) {
+	for _, name := range []string{"packer-ubuntu-1712345678", "Ubuntu 24.04 (arm64)", "débian"} {
+		if err := ValidateVMName(name); err != nil {
+			t.Errorf("%q: %s", name, err)
+		}
+	}
+	for _, name := range []string{"", " ", ".hidden", "a/b", "a:b", "a\nb", strings.Repeat("a", 252)} {
+		if err := ValidateVMName(name); err == nil {
+			t.Errorf("%q: should be rejected", name)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/bootcommand"
+	"github.com/mitchellh/go-vnc"
+)
+
+// Values of boot_key_mode.
+const (
+	// BootKeyModeText sends boot_command as VNC keysyms, which QEMU maps
+	// to keys with the US layout.
+	BootKeyModeText = "text"
+	// BootKeyModeScancode sends boot_command as PC XT scancodes, which the
+	// guest reads with its own layout.
+	BootKeyModeScancode = "scancode"
+)
+
+// qemuExtendedKeyEventEncoding is the pseudo-encoding a VNC client sends to
+// tell QEMU it sends scancodes. QEMU acknowledges it with an empty rectangle
+// of the same encoding.
+const qemuExtendedKeyEventEncoding = -258
+
+type qemuExtendedKeyEvent struct{}
+
+func (*qemuExtendedKeyEvent) Type() int32 {
+	return qemuExtendedKeyEventEncoding
+}
+
+func (e *qemuExtendedKeyEvent) Read(*vnc.ClientConn, *vnc.Rectangle, io.Reader) (vnc.Encoding, error) {
+	return e, nil
+}
+
+// xtKeyEvent is a key press or release with the XT keycode of the key, as
+// QEMU expects it: the scancode, with the high bit set for keys with an e0
+// prefix.
+type xtKeyEvent struct {
+	Keycode uint32
+	Down    bool
+}
+
+// parseXTScancodes turns the hex scancodes of the SDK's PC XT driver, such as
+// "2a", "1e", "9e", "aa" for "A", into key events.
+func parseXTScancodes(codes []string) ([]xtKeyEvent, error) {
+	var events []xtKeyEvent
+	extended := false
+	for _, code := range codes {
+		b, err := strconv.ParseUint(code, 16, 8)
+		if err != nil {
+			return nil, fmt.Errorf("invalid scancode %q", code)
+		}
+		if b == 0xe0 {
+			extended = true
+			continue
+		}
+
+		keycode := uint32(b & 0x7f)
+		if extended {
+			keycode |= 0x80
+		}
+		events = append(events, xtKeyEvent{Keycode: keycode, Down: b&0x80 == 0})
+		extended = false
+	}
+	return events, nil
+}
+
+// writeQemuExtendedKeyEvent writes a QEMU Extended Key Event client message.
+// The keysym is left out so QEMU uses the keycode alone, whatever its own
+// keymap.
+func writeQemuExtendedKeyEvent(w io.Writer, e xtKeyEvent) error {
+	var down uint16
+	if e.Down {
+		down = 1
+	}
+	data := []interface{}{uint8(255), uint8(0), down, uint32(0), e.Keycode}
+	for _, val := range data {
+		if err := binary.Write(w, binary.BigEndian, val); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// NewVNCScancodeDriver returns a boot_command driver that types scancodes
+// into the QEMU VNC server c is connected to. w is the connection of c,
+// which go-vnc doesn't expose. interval is the delay between key events;
+// when zero, PACKER_KEY_INTERVAL or the SDK default is used, as with the VNC
+// driver.
+func NewVNCScancodeDriver(c *vnc.ClientConn, w io.Writer, interval time.Duration) (bootcommand.BCDriver, error) {
+	if err := c.SetEncodings([]vnc.Encoding{new(qemuExtendedKeyEvent)}); err != nil {
+		return nil, fmt.Errorf("error enabling scancodes on VNC: %s", err)
+	}
+	return newScancodeDriver(w, interval), nil
+}
+
+func newScancodeDriver(w io.Writer, interval time.Duration) bootcommand.BCDriver {
+	if interval <= 0 {
+		interval = bootcommand.PackerKeyDefault
+		if delay, err := time.ParseDuration(os.Getenv(bootcommand.PackerKeyEnv)); err == nil {
+			interval = delay
+		}
+	}
+	send := func(codes []string) error {
+		events, err := parseXTScancodes(codes)
+		if err != nil {
+			return err
+		}
+		for _, e := range events {
+			if err := writeQemuExtendedKeyEvent(w, e); err != nil {
+				return fmt.Errorf("error sending scancode: %s", err)
+			}
+			time.Sleep(interval)
+		}
+		return nil
+	}
+	return bootcommand.NewPCXTDriver(send, 0, interval)
+}
@@ -0,0 +1,102 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+func TestParseXTScancodes(t *testing.T) {
+	events, err := parseXTScancodes([]string{"2a", "1e", "9e", "aa", "e0", "48", "e0", "c8"})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := []xtKeyEvent{
+		{Keycode: 0x2a, Down: true},
+		{Keycode: 0x1e, Down: true},
+		{Keycode: 0x1e, Down: false},
+		{Keycode: 0x2a, Down: false},
+		{Keycode: 0xc8, Down: true},
+		{Keycode: 0xc8, Down: false},
+	}
+	if !reflect.DeepEqual(events, expected) {
+		t.Fatalf("bad events: %#v", events)
+	}
+}
+
+func TestParseXTScancodes_invalid(t *testing.T) {
+	if _, err := parseXTScancodes([]string{"zz"}); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+// readQemuExtendedKeyEvents decodes the client messages a scancode driver
+// wrote.
+func readQemuExtendedKeyEvents(t *testing.T, buf *bytes.Buffer) []xtKeyEvent {
+	t.Helper()
+
+	var events []xtKeyEvent
+	for buf.Len() > 0 {
+		var msg struct {
+			Type    uint8
+			SubType uint8
+			Down    uint16
+			Keysym  uint32
+			Keycode uint32
+		}
+		if err := binary.Read(buf, binary.BigEndian, &msg); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if msg.Type != 255 || msg.SubType != 0 {
+			t.Fatalf("bad message type: %d/%d", msg.Type, msg.SubType)
+		}
+		events = append(events, xtKeyEvent{Keycode: msg.Keycode, Down: msg.Down == 1})
+	}
+	return events
+}
+
+func TestScancodeDriver(t *testing.T) {
+	var buf bytes.Buffer
+	d := newScancodeDriver(&buf, 1)
+
+	if err := RunKeySequence(context.Background(), d, "Q<enter>"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := []xtKeyEvent{
+		{Keycode: 0x2a, Down: true},
+		{Keycode: 0x10, Down: true},
+		{Keycode: 0x10, Down: false},
+		{Keycode: 0x2a, Down: false},
+		{Keycode: 0x1c, Down: true},
+		{Keycode: 0x1c, Down: false},
+	}
+	if events := readQemuExtendedKeyEvents(t, &buf); !reflect.DeepEqual(events, expected) {
+		t.Fatalf("bad events: %#v", events)
+	}
+}
+
+func TestScancodeDriver_modifiers(t *testing.T) {
+	var buf bytes.Buffer
+	d := newScancodeDriver(&buf, 1)
+
+	if err := RunKeySequence(context.Background(), d, "<rightCtrlOn>c<rightCtrlOff>"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := []xtKeyEvent{
+		{Keycode: 0x9d, Down: true},
+		{Keycode: 0x2e, Down: true},
+		{Keycode: 0x2e, Down: false},
+		{Keycode: 0x9d, Down: false},
+	}
+	if events := readQemuExtendedKeyEvents(t, &buf); !reflect.DeepEqual(events, expected) {
+		t.Fatalf("bad events: %#v", events)
+	}
+}
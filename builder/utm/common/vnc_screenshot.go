@@ -0,0 +1,152 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/mitchellh/go-vnc"
+)
+
+// ScreenshotTimeout bounds how long Driver.Screenshot waits for the VNC
+// framebuffer.
+var ScreenshotTimeout = 10 * time.Second
+
+// vncDisplay is where the VNC server of a VM listens.
+type vncDisplay struct {
+	addr     string
+	password string
+}
+
+// vncDisplays maps VM ids to their VNC server. Displays are registered when
+// the builder configures VNC, so Driver.Screenshot only needs the VM id.
+var vncDisplays sync.Map
+
+// RegisterVNCDisplay records the VNC server of a VM, listening on host and
+// port.
+func RegisterVNCDisplay(vmId string, host string, port int, password string) {
+	vncDisplays.Store(vmId, vncDisplay{
+		addr:     net.JoinHostPort(host, strconv.Itoa(port)),
+		password: password,
+	})
+}
+
+// UnregisterVNCDisplay forgets the VNC server of a VM.
+func UnregisterVNCDisplay(vmId string) {
+	vncDisplays.Delete(vmId)
+}
+
+// vncScreenshotPNG grabs the display of the VNC server registered for the
+// VM as a PNG.
+func vncScreenshotPNG(vmId string) ([]byte, error) {
+	value, ok := vncDisplays.Load(vmId)
+	if !ok {
+		return nil, fmt.Errorf("VM %s has no VNC display", vmId)
+	}
+	display := value.(vncDisplay)
+
+	img, err := CaptureVNCScreenshot(display.addr, display.password, ScreenshotTimeout)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("error encoding the screenshot: %s", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// CaptureVNCScreenshot grabs the whole framebuffer of the VNC server at
+// addr. It gives up after timeout, for example when the VM has no display
+// attached and the server never sends a frame.
+func CaptureVNCScreenshot(addr string, password string, timeout time.Duration) (image.Image, error) {
+	nc, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to VNC: %s", err)
+	}
+	defer func() { _ = nc.Close() }()
+	if err := nc.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return nil, err
+	}
+
+	auth := []vnc.ClientAuth{new(vnc.ClientAuthNone)}
+	if password != "" {
+		auth = []vnc.ClientAuth{&vnc.PasswordAuth{Password: password}}
+	}
+
+	messages := make(chan vnc.ServerMessage, 16)
+	c, err := vnc.Client(nc, &vnc.ClientConfig{Auth: auth, ServerMessageCh: messages})
+	if err != nil {
+		return nil, fmt.Errorf("error handshaking with VNC: %s", err)
+	}
+	defer func() { _ = c.Close() }()
+
+	width, height := c.FrameBufferWidth, c.FrameBufferHeight
+	if width == 0 || height == 0 {
+		return nil, errors.New("VNC server has an empty framebuffer")
+	}
+	if !c.PixelFormat.TrueColor {
+		return nil, errors.New("VNC server does not use a true color pixel format")
+	}
+	if err := c.FramebufferUpdateRequest(false, 0, 0, width, height); err != nil {
+		return nil, fmt.Errorf("error requesting the VNC framebuffer: %s", err)
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, int(width), int(height)))
+	covered := 0
+	deadline := time.After(timeout)
+	for covered < int(width)*int(height) {
+		select {
+		case msg := <-messages:
+			update, ok := msg.(*vnc.FramebufferUpdateMessage)
+			if !ok {
+				continue
+			}
+			for _, rect := range update.Rectangles {
+				raw, ok := rect.Enc.(*vnc.RawEncoding)
+				if !ok {
+					continue
+				}
+				drawRawRectangle(img, rect, raw, c.PixelFormat)
+				covered += int(rect.Width) * int(rect.Height)
+			}
+		case <-deadline:
+			return nil, errors.New("timed out waiting for the VNC framebuffer")
+		}
+	}
+
+	return img, nil
+}
+
+// drawRawRectangle copies the pixels of a raw encoded rectangle into img,
+// scaling each channel from the range of the server's pixel format.
+func drawRawRectangle(img *image.RGBA, rect vnc.Rectangle, raw *vnc.RawEncoding, format vnc.PixelFormat) {
+	scale := func(value uint16, max uint16) uint8 {
+		if max == 0 {
+			return 0
+		}
+		return uint8(uint32(value) * 255 / uint32(max))
+	}
+
+	for y := 0; y < int(rect.Height); y++ {
+		for x := 0; x < int(rect.Width); x++ {
+			pixel := raw.Colors[y*int(rect.Width)+x]
+			img.SetRGBA(int(rect.X)+x, int(rect.Y)+y, color.RGBA{
+				R: scale(pixel.R, format.RedMax),
+				G: scale(pixel.G, format.GreenMax),
+				B: scale(pixel.B, format.BlueMax),
+				A: 0xff,
+			})
+		}
+	}
+}
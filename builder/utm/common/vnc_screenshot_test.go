@@ -0,0 +1,122 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image/color"
+	"image/png"
+	"io"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// testVNCServer serves a single client a 2x1 framebuffer with a red and a
+// blue pixel, using the RFB 3.8 handshake without authentication.
+func testVNCServer(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	t.Cleanup(func() { _ = l.Close() })
+
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+
+		write := func(data interface{}) { _ = binary.Write(conn, binary.BigEndian, data) }
+		read := func(n int) { _, _ = io.ReadFull(conn, make([]byte, n)) }
+
+		_, _ = conn.Write([]byte("RFB 003.008\n"))
+		read(12)
+		write([]uint8{1, 1}) // one security type: none
+		read(1)
+		write(uint32(0)) // security result: ok
+		read(1)          // ClientInit
+
+		// ServerInit: 2x1, 32 bpp little endian true color, then the name
+		write([]uint16{2, 1})
+		write([]uint8{32, 24, 0, 1})
+		write([]uint16{255, 255, 255})
+		write([]uint8{16, 8, 0, 0, 0, 0})
+		write(uint32(4))
+		_, _ = conn.Write([]byte("test"))
+
+		read(10) // FramebufferUpdateRequest
+
+		write([]uint8{0, 0})
+		write(uint16(1))
+		write([]uint16{0, 0, 2, 1})
+		write(int32(0))
+		_ = binary.Write(conn, binary.LittleEndian, []uint32{0x00ff0000, 0x000000ff})
+		time.Sleep(time.Second)
+	}()
+
+	return l.Addr().String()
+}
+
+func TestCaptureVNCScreenshot(t *testing.T) {
+	addr := testVNCServer(t)
+
+	img, err := CaptureVNCScreenshot(addr, "", 5*time.Second)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if bounds := img.Bounds(); bounds.Dx() != 2 || bounds.Dy() != 1 {
+		t.Fatalf("bad size: %s", bounds)
+	}
+	if c := color.RGBAModel.Convert(img.At(0, 0)); c != (color.RGBA{R: 255, A: 255}) {
+		t.Fatalf("should be red: %#v", c)
+	}
+	if c := color.RGBAModel.Convert(img.At(1, 0)); c != (color.RGBA{B: 255, A: 255}) {
+		t.Fatalf("should be blue: %#v", c)
+	}
+}
+
+func TestCaptureVNCScreenshot_noServer(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	addr := l.Addr().String()
+	_ = l.Close()
+
+	if _, err := CaptureVNCScreenshot(addr, "", time.Second); err == nil {
+		t.Fatal("should fail without a VNC server")
+	}
+}
+
+func TestUtm45Driver_Screenshot(t *testing.T) {
+	host, port, err := net.SplitHostPort(testVNCServer(t))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	portNum, _ := strconv.Atoi(port)
+
+	d := new(Utm45Driver)
+	if _, err := d.Screenshot("vm"); err == nil {
+		t.Fatal("should fail without a VNC display")
+	}
+
+	RegisterVNCDisplay("vm", host, portNum, "")
+	defer UnregisterVNCDisplay("vm")
+
+	data, err := d.Screenshot("vm")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("should be a PNG: %s", err)
+	}
+	if bounds := img.Bounds(); bounds.Dx() != 2 || bounds.Dy() != 1 {
+		t.Fatalf("bad size: %s", bounds)
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:generate packer-sdc struct-markdown
+
+package common
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+)
+
+// WindowsUnattendedFileName is the name Windows setup looks for in the root
+// of every removable drive.
+const WindowsUnattendedFileName = "autounattend.xml"
+
+// WindowsUnattendedLabel is the volume label of the generated answer file ISO.
+const WindowsUnattendedLabel = "UNATTEND"
+
+type WindowsUnattendedConfig struct {
+	// The path to an autounattend.xml template. The file is rendered, packaged
+	// into an ISO labeled `UNATTEND` and attached to the virtual machine so that
+	// Windows setup discovers it automatically. The template can use
+	// `{{ .WinRMPassword }}`, `{{ .ProductKey }}` and `{{ .Hostname }}`, the
+	// computer name from `guest_hostname`. The values are XML-escaped.
+	WindowsUnattended string `mapstructure:"windows_unattended" required:"false"`
+	// The autounattend.xml template given inline instead of as a file. This is
+	// mutually exclusive with `windows_unattended`.
+	WindowsUnattendedContent string `mapstructure:"windows_unattended_content" required:"false"`
+	// The Windows product key made available to the autounattend.xml template
+	// as `{{ .ProductKey }}`.
+	WindowsProductKey string `mapstructure:"windows_product_key" required:"false"`
+
+	rendered string
+}
+
+type windowsUnattendedTemplateData struct {
+	WinRMPassword string
+	ProductKey    string
+	Hostname      string
+}
+
+func (c *WindowsUnattendedConfig) Prepare(ctx *interpolate.Context, winrmPassword string, hostname string) []error {
+	var errs []error
+
+	if c.WindowsUnattended != "" && c.WindowsUnattendedContent != "" {
+		errs = append(errs, errors.New("only one of windows_unattended or windows_unattended_content may be set"))
+		return errs
+	}
+
+	content := c.WindowsUnattendedContent
+	if c.WindowsUnattended != "" {
+		b, err := os.ReadFile(c.WindowsUnattended)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("error reading windows_unattended: %s", err))
+			return errs
+		}
+		content = string(b)
+	}
+
+	if content == "" {
+		return errs
+	}
+
+	// The values land in XML text or attributes, so a password with a < or
+	// an & must not break the document or inject elements.
+	renderCtx := *ctx
+	renderCtx.Data = &windowsUnattendedTemplateData{
+		WinRMPassword: escapeXML(winrmPassword),
+		ProductKey:    escapeXML(c.WindowsProductKey),
+		Hostname:      escapeXML(ComputerName(hostname)),
+	}
+	rendered, err := interpolate.Render(content, &renderCtx)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("error rendering windows_unattended: %s", err))
+		return errs
+	}
+
+	if err := checkWellFormedXML(rendered); err != nil {
+		errs = append(errs, fmt.Errorf("windows_unattended is not well-formed XML: %s", err))
+		return errs
+	}
+
+	c.rendered = rendered
+	return errs
+}
+
+// Rendered returns the autounattend.xml content after interpolation, or an
+// empty string if no answer file is configured.
+func (c *WindowsUnattendedConfig) Rendered() string {
+	return c.rendered
+}
+
+// escapeXML escapes s for use in XML text and attribute values.
+func escapeXML(s string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// checkWellFormedXML reads the whole document and fails on the first syntax
+// error, or if the document has no root element.
+func checkWellFormedXML(content string) error {
+	decoder := xml.NewDecoder(strings.NewReader(content))
+	hasRoot := false
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if _, ok := token.(xml.StartElement); ok {
+			hasRoot = true
+		}
+	}
+	if !hasRoot {
+		return errors.New("document has no root element")
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+)
+
+const testUnattendedXML = `<?xml version="1.0" encoding="utf-8"?>
+<unattend xmlns="urn:schemas-microsoft-com:unattend">
+  <ProductKey>{{ .ProductKey }}</ProductKey>
+  <Password>{{ .WinRMPassword }}</Password>
+</unattend>`
+
+func TestWindowsUnattendedConfigPrepare_empty(t *testing.T) {
+	c := new(WindowsUnattendedConfig)
+	errs := c.Prepare(&interpolate.Context{}, "", "")
+	if len(errs) > 0 {
+		t.Fatalf("should not have errors: %#v", errs)
+	}
+	if c.Rendered() != "" {
+		t.Fatalf("should not render anything: %s", c.Rendered())
+	}
+}
+
+func TestWindowsUnattendedConfigPrepare_content(t *testing.T) {
+	c := &WindowsUnattendedConfig{
+		WindowsUnattendedContent: testUnattendedXML,
+		WindowsProductKey:        "AAAAA-BBBBB-CCCCC-DDDDD-EEEEE",
+	}
+	errs := c.Prepare(&interpolate.Context{}, "s3cret", "")
+	if len(errs) > 0 {
+		t.Fatalf("should not have errors: %#v", errs)
+	}
+	if !strings.Contains(c.Rendered(), "<ProductKey>AAAAA-BBBBB-CCCCC-DDDDD-EEEEE</ProductKey>") {
+		t.Fatalf("product key not rendered: %s", c.Rendered())
+	}
+	if !strings.Contains(c.Rendered(), "<Password>s3cret</Password>") {
+		t.Fatalf("password not rendered: %s", c.Rendered())
+	}
+}
+
+func TestWindowsUnattendedConfigPrepare_escape(t *testing.T) {
+	c := &WindowsUnattendedConfig{
+		WindowsUnattendedContent: testUnattendedXML,
+		WindowsProductKey:        "<Key>&\"",
+	}
+	errs := c.Prepare(&interpolate.Context{}, "a<b>&c</Password><Injected/>", "")
+	if len(errs) > 0 {
+		t.Fatalf("should escape the values: %#v", errs)
+	}
+	if !strings.Contains(c.Rendered(), "<Password>a&lt;b&gt;&amp;c&lt;/Password&gt;&lt;Injected/&gt;</Password>") {
+		t.Fatalf("password not escaped: %s", c.Rendered())
+	}
+	if !strings.Contains(c.Rendered(), "<ProductKey>&lt;Key&gt;&amp;&#34;</ProductKey>") {
+		t.Fatalf("product key not escaped: %s", c.Rendered())
+	}
+}
+
+func TestWindowsUnattendedConfigPrepare_file(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "autounattend.xml")
+	if err := os.WriteFile(path, []byte(testUnattendedXML), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	c := &WindowsUnattendedConfig{WindowsUnattended: path}
+	errs := c.Prepare(&interpolate.Context{}, "s3cret", "")
+	if len(errs) > 0 {
+		t.Fatalf("should not have errors: %#v", errs)
+	}
+	if !strings.Contains(c.Rendered(), "<Password>s3cret</Password>") {
+		t.Fatalf("password not rendered: %s", c.Rendered())
+	}
+
+	c = &WindowsUnattendedConfig{WindowsUnattended: filepath.Join(t.TempDir(), "missing.xml")}
+	if errs := c.Prepare(&interpolate.Context{}, "", ""); len(errs) == 0 {
+		t.Fatal("should have error for a missing file")
+	}
+}
+
+func TestWindowsUnattendedConfigPrepare_exclusive(t *testing.T) {
+	c := &WindowsUnattendedConfig{
+		WindowsUnattended:        "autounattend.xml",
+		WindowsUnattendedContent: testUnattendedXML,
+	}
+	if errs := c.Prepare(&interpolate.Context{}, "", ""); len(errs) == 0 {
+		t.Fatal("should have error")
+	}
+}
+
+func TestWindowsUnattendedConfigPrepare_malformed(t *testing.T) {
+	for _, content := range []string{
+		"<unattend><settings></unattend>",
+		"not xml at all",
+		"{{ .Nope",
+	} {
+		c := &WindowsUnattendedConfig{WindowsUnattendedContent: content}
+		if errs := c.Prepare(&interpolate.Context{}, "", ""); len(errs) == 0 {
+			t.Fatalf("should have error for %q", content)
+		}
+	}
+}
+
+func TestWindowsUnattendedConfigPrepare_hostname(t *testing.T) {
+	c := &WindowsUnattendedConfig{
+		WindowsUnattendedContent: `<unattend><ComputerName>{{ .Hostname }}</ComputerName></unattend>`,
+	}
+	if errs := c.Prepare(&interpolate.Context{}, "", "build01.example.com"); len(errs) > 0 {
+		t.Fatalf("should not have errors: %#v", errs)
+	}
+	if !strings.Contains(c.Rendered(), "<ComputerName>build01</ComputerName>") {
+		t.Fatalf("computer name not rendered: %s", c.Rendered())
+	}
+}
@@ -35,11 +35,19 @@ func (b *Builder) Prepare(raws ...interface{}) ([]string, []string, error) {
 
 func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook) (packersdk.Artifact, error) {
 	// Create the driver that we'll use to communicate with UTM
-	driver, err := utmcommon.NewDriver()
+	driver, err := utmcommon.NewDriver(&b.config.DriverConfig)
 	if err != nil {
 		return nil, fmt.Errorf("failed creating UTM driver: %s", err)
 	}
 
+	// Emit progress events to events_file, if set
+	events, err := utmcommon.NewEventWriter(b.config.EventsFile)
+	if err != nil {
+		return nil, err
+	}
+	defer events.Close()
+	ui = utmcommon.NewEventUi(ui, events)
+
 	// Setup the state bag
 	state := new(multistep.BasicStateBag)
 	state.Put("config", &b.config)
@@ -47,23 +55,54 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 	state.Put("driver", driver)
 	state.Put("hook", hook)
 	state.Put("ui", ui)
+	if events != nil {
+		state.Put("events", events)
+	}
 
 	// Build the steps.
 	steps := []multistep.Step{
-		&utmcommon.StepDownloadGuestAdditions{
-			GuestAdditionsMode:       b.config.GuestAdditionsMode,
-			GuestAdditionsURL:        b.config.GuestAdditionsURL,
-			GuestAdditionsSHA256:     b.config.GuestAdditionsSHA256,
-			GuestAdditionsTargetPath: b.config.GuestAdditionsTargetPath,
-			Ctx:                      b.config.ctx,
-		},
-		&commonsteps.StepDownload{
-			Checksum:    b.config.ISOChecksum,
-			Description: "ISO",
-			Extension:   b.config.TargetExtension,
-			ResultKey:   "iso_path",
-			TargetPath:  b.config.TargetPath,
-			Url:         b.config.ISOUrls,
+		new(utmcommon.StepPreflight),
+		&utmcommon.StepCheckHostMemory{
+			MemorySize:    b.config.MemorySize,
+			MinHostMemory: b.config.MinHostMemory,
+		},
+		// The guest additions and the boot ISO don't depend on each other,
+		// so download them at the same time.
+		&utmcommon.StepParallel{
+			Steps: []utmcommon.ParallelStep{
+				{
+					Name: "Guest additions",
+					Step: &utmcommon.StepDownloadGuestAdditions{
+						GuestAdditionsMode:           b.config.GuestAdditionsMode,
+						GuestAdditionsURL:            b.config.GuestAdditionsURL,
+						GuestAdditionsURLs:           b.config.GuestAdditionsURLs,
+						GuestAdditionsFilename:       b.config.GuestAdditionsFilename,
+						GuestAdditionsSHA256:         b.config.GuestAdditionsSHA256,
+						GuestAdditionsTargetPath:     b.config.GuestAdditionsTargetPath,
+						RequireBundledGuestAdditions: b.config.RequireBundledGuestAdditions,
+						GuestOS:                      b.config.guestOS(),
+						VMArch:                       b.config.VMArch,
+						Ctx:                          b.config.ctx,
+					},
+				},
+				{
+					Name: "ISO",
+					Step: &utmcommon.StepDownloadISO{
+						Download: &commonsteps.StepDownload{
+							Checksum:    b.config.ISOChecksum,
+							Description: "ISO",
+							Extension:   b.config.TargetExtension,
+							ResultKey:   "iso_path",
+							TargetPath:  b.config.TargetPath,
+							Url:         b.config.ISOUrls,
+						},
+						Resume: !b.config.DownloadResume.False(),
+					},
+				},
+			},
+		},
+		&utmcommon.StepDownloadAdditionalISOs{
+			AdditionalISOs: b.config.AdditionalISOs,
 		},
 		&commonsteps.StepOutputDir{
 			Force: b.config.PackerForce,
@@ -79,6 +118,9 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 			Content: b.config.CDContent,
 			Label:   b.config.CDLabel,
 		},
+		&utmcommon.StepCreateWindowsUnattended{
+			Content: b.config.WindowsUnattendedConfig.Rendered(),
+		},
 		new(utmcommon.StepHTTPIPDiscover),
 		commonsteps.HTTPServerFromHTTPConfig(&b.config.HTTPConfig),
 		&utmcommon.StepSshKeyPair{
@@ -94,29 +136,57 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 			HWConfig:       b.config.HWConfig,
 			UEFIBoot:       b.config.UEFIBoot,
 			Hypervisor:     b.config.Hypervisor,
+			Rosetta:        b.config.Rosetta,
 			KeepRegistered: b.config.KeepRegistered,
 		},
+		&utmcommon.StepConfigureAccelerator{
+			Accelerator: b.config.Accelerator,
+			VMArch:      b.config.VMArch,
+		},
+		&utmcommon.StepConfigureCPU{
+			Model:    b.config.CPUModel,
+			Features: b.config.CPUFeatures,
+		},
+		&utmcommon.StepConfigureBalloon{
+			Enabled: b.config.AddBalloonDevice(),
+			VMArch:  b.config.VMArch,
+		},
 		&utmcommon.StepConfigureQemuArgs{
-			QemuArgs: b.config.QemuArgs,
+			QemuArgs:  b.config.QemuArgs,
+			RNGDevice: b.config.AddRNGDevice(),
+			VMArch:    b.config.VMArch,
+			RTCArg:    b.config.RTCQemuArg(b.config.guestOS() == utmcommon.GuestOSWindows),
+		},
+		&utmcommon.StepConfigureQemuMonitor{
+			Enabled: b.config.QemuMonitor,
 		},
-		// TODO: Make sure ISO is first in the list for boot order
 		new(stepCreateDisk),
 		&utmcommon.StepAttachISOs{
-			AttachBootISO:           true, // Attach boot ISO , since CreateVM does not.
-			ISOInterface:            b.config.ISOInterface,
-			GuestAdditionsMode:      b.config.GuestAdditionsMode,
-			GuestAdditionsInterface: b.config.GuestAdditionsInterface,
+			AttachBootISO:                   true, // Attach boot ISO , since CreateVM does not.
+			ISOInterface:                    b.config.ISOInterface,
+			CDFilesInterface:                b.config.CDFilesInterface,
+			GuestAdditionsMode:              b.config.GuestAdditionsMode,
+			GuestAdditionsInterface:         b.config.GuestAdditionsInterface,
+			GuestAdditionsInterfaceFallback: b.config.GuestAdditionsInterfaceFallback,
+			AdditionalISOs:                  b.config.AdditionalISOs,
+		},
+		&utmcommon.StepSetBootOrder{
+			Devices: b.config.BootOrder,
 		},
 		// TODO: add steps to attach Floppy disk
 		&utmcommon.StepAttachDisplay{
 			HardwareType: b.config.DisplayHardwareType,
 		},
+		&utmcommon.StepConfigureNetworkAdapters{
+			Adapters:            b.config.NetworkAdapters,
+			CommunicatorAdapter: b.config.CommunicatorAdapter,
+		},
 		&utmcommon.StepPortForwarding{
 			CommConfig:             &b.config.Comm,
 			HostPortMin:            b.config.HostPortMin,
 			HostPortMax:            b.config.HostPortMax,
 			SkipNatMapping:         b.config.SkipNatMapping,
-			ClearNetworkInterfaces: true,
+			ClearNetworkInterfaces: len(b.config.NetworkAdapters) == 0,
 		},
 		&stepConfigureVNC{
 			Enabled:            !b.config.DisableVNC,
@@ -129,7 +199,22 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 			Message: "UTM API Unavailable: Add a display device to the VM for VNC to work",
 			NoPause: b.config.DisplayNoPause,
 		},
-		&utmcommon.StepRun{},
+		&utmcommon.StepRun{
+			KeepRunning:    b.config.KeepRunning,
+			KeepRegistered: b.config.KeepRegistered,
+			StartTimeout:   b.config.StartTimeout,
+			BootWait:       b.config.BootWait,
+			QemuLog:        b.config.QemuLog,
+			OutputDir:      b.config.OutputDir,
+		},
+		&stepScreenshotOnFailure{
+			Enabled:   b.config.ScreenshotOnFailure,
+			OutputDir: b.config.OutputDir,
+		},
+		&stepStartScreenshots{
+			Interval:  b.config.ScreenshotInterval,
+			OutputDir: b.config.OutputDir,
+		},
 		&stepTypeBootCommand{},
 		&utmcommon.StepPause{
 			Message: "Confirm Install is complete, VM is running with OS installed. (Next steps is connecting to the VM)",
@@ -144,6 +229,11 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 		// &stepRemoveFirstDisk{},
 		// // We start the VM again for the next steps.
 		// &utmcommon.StepRun{},
+		&utmcommon.StepWaitForInstall{
+			Timeout:  b.config.InstallTimeout,
+			Host:     utmcommon.CommHost(b.config.Comm.Host()),
+			CommType: b.config.Comm.Type,
+		},
 		&communicator.StepConnect{
 			Config:    &b.config.Comm,
 			Host:      utmcommon.CommHost(b.config.Comm.Host()),
@@ -151,11 +241,57 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 			SSHPort:   utmcommon.CommPort,
 			WinRMPort: utmcommon.CommPort,
 		},
+		&utmcommon.StepAttachPostBootISOs{
+			AdditionalISOs: b.config.AdditionalISOs,
+		},
+		new(stepStopScreenshots),
+		&utmcommon.StepDetectGuestOS{
+			CommType: b.config.Comm.Type,
+		},
+		&utmcommon.StepWaitForNetwork{
+			Enabled:  b.config.WaitForNetwork,
+			Timeout:  b.config.WaitForNetworkTimeout,
+			CommType: b.config.Comm.Type,
+		},
 		&utmcommon.StepUploadVersion{
 			Path: *b.config.UtmVersionFile,
 		},
-		// TODO: Add StepUploadGuestAdditions
+		&utmcommon.StepUploadGuestAdditions{
+			GuestAdditionsMode: b.config.GuestAdditionsMode,
+			GuestAdditionsPath: b.config.GuestAdditionsPath,
+			UploadMode:         b.config.GuestAdditionsUploadMode,
+			CommType:           b.config.Comm.Type,
+		},
+		&utmcommon.StepInstallGuestAdditions{
+			GuestAdditionsMode: b.config.GuestAdditionsMode,
+			GuestAdditionsPath: b.config.GuestAdditionsPath,
+			Command:            b.config.GuestAdditionsInstallCommand,
+			Skip:               b.config.SkipGuestAdditionsInstall,
+			CommType:           b.config.Comm.Type,
+			VMArch:             b.config.VMArch,
+			Ctx:                b.config.ctx,
+		},
+		&utmcommon.StepWaitCloudInit{
+			Enabled: b.config.CloudInitWait,
+			Timeout: b.config.CloudInitTimeout,
+		},
 		new(commonsteps.StepProvision),
+		&utmcommon.StepVerifyGuestAdditions{
+			Enabled: b.config.VerifyGuestAdditions,
+		},
+		&utmcommon.StepTestResume{
+			Enabled:  b.config.TestResume,
+			Commands: b.config.TestResumeCommands,
+			Timeout:  b.config.TestResumeTimeout,
+			CommType: b.config.Comm.Type,
+		},
+		&utmcommon.StepSetGuestHostname{
+			Hostname: b.config.GuestHostname,
+		},
+		&utmcommon.StepAddAuthorizedKeys{
+			Keys: b.config.SSHAuthorizedKeys,
+			User: b.config.SSHAuthorizedKeysUser,
+		},
 		&commonsteps.StepCleanupTempKeys{
 			Comm: &b.config.Comm,
 		},
@@ -164,6 +300,9 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 			Timeout:         b.config.ShutdownTimeout,
 			Delay:           b.config.PostShutdownDelay,
 			DisableShutdown: b.config.DisableShutdown,
+			KeepRunning:     b.config.KeepRunning,
+			Suspend:         b.config.SuspendBeforeExport,
+			Bundling:        b.config.UtmBundleConfig,
 		},
 		&utmcommon.StepRemoveDevices{
 			Bundling: b.config.UtmBundleConfig,
@@ -172,6 +311,17 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 			Message: "Make required changes to the VM before export.\nRemove display, Add Serial port, Icon, etc.",
 			NoPause: b.config.ExportNoPause,
 		},
+		&utmcommon.StepCheckExportSpace{
+			OutputDir:  b.config.OutputDir,
+			SkipExport: b.config.SkipExport,
+			MarginMB:   *b.config.ExportSpaceMargin,
+		},
+		&utmcommon.StepVerifyVMConfig{
+			HWConfig:        b.config.HWConfig,
+			Disks:           1 + len(b.config.AdditionalDiskSize),
+			NetworkAdapters: b.config.NetworkAdapters,
+			SkipExport:      b.config.SkipExport,
+		},
 		&utmcommon.StepExport{
 			Format:         b.config.Format,
 			OutputDir:      b.config.OutputDir,
@@ -179,9 +329,22 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 			SkipNatMapping: b.config.SkipNatMapping,
 			SkipExport:     b.config.SkipExport,
 		},
+		&utmcommon.StepResetNVRAM{
+			Enabled:    b.config.ResetNVRAM,
+			SkipExport: b.config.SkipExport,
+		},
+		&utmcommon.StepCopyBundleFiles{
+			Files:      b.config.ExtraBundleFiles,
+			SkipExport: b.config.SkipExport,
+		},
+		&utmcommon.StepKeepRunning{
+			Enabled:      b.config.KeepRunning,
+			StartTimeout: b.config.StartTimeout,
+		},
 	}
 
 	// Run the steps
+	steps = utmcommon.WithStepEvents(steps, events)
 	b.runner = commonsteps.NewRunnerWithPauseFn(steps, b.config.PackerConfig, ui, state)
 	b.runner.Run(ctx, state)
 
@@ -199,6 +362,14 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 		return nil, errors.New("build was halted")
 	}
 
-	generatedData := map[string]interface{}{"generated_data": state.Get("generated_data")}
-	return utmcommon.NewArtifact(b.config.OutputDir, b.config.VMName, generatedData)
+	vmId, err := utmcommon.GetVMID(state)
+	if err != nil {
+		return nil, err
+	}
+
+	generatedData := utmcommon.ArtifactStateData(state)
+	if b.config.KeepRunning {
+		return utmcommon.NewRunningArtifact(vmId, b.config.VMName, generatedData), nil
+	}
+	return utmcommon.NewArtifact(b.config.OutputDir, vmId, b.config.VMName, generatedData)
 }
@@ -6,6 +6,8 @@ package iso
 import (
 	"errors"
 	"fmt"
+	"os/exec"
+	"time"
 
 	"github.com/hashicorp/packer-plugin-sdk/bootcommand"
 	"github.com/hashicorp/packer-plugin-sdk/common"
@@ -18,22 +20,33 @@ import (
 
 // Config is the configuration structure for the UTM ISO builder.
 type Config struct {
-	common.PackerConfig            `mapstructure:",squash"`
-	commonsteps.HTTPConfig         `mapstructure:",squash"`
-	commonsteps.ISOConfig          `mapstructure:",squash"`
-	commonsteps.FloppyConfig       `mapstructure:",squash"`
-	commonsteps.CDConfig           `mapstructure:",squash"`
-	bootcommand.VNCConfig          `mapstructure:",squash"`
-	utmcommon.ExportConfig         `mapstructure:",squash"`
-	utmcommon.OutputConfig         `mapstructure:",squash"`
-	utmcommon.ShutdownConfig       `mapstructure:",squash"`
-	utmcommon.CommConfig           `mapstructure:",squash"`
-	utmcommon.HWConfig             `mapstructure:",squash"`
-	utmcommon.UtmVersionConfig     `mapstructure:",squash"`
-	utmcommon.UtmBundleConfig      `mapstructure:",squash"`
-	utmcommon.GuestAdditionsConfig `mapstructure:",squash"`
-	utmcommon.NoPauseConfig        `mapstructure:",squash"`
-	utmcommon.QemuConfig           `mapstructure:",squash"`
+	common.PackerConfig               `mapstructure:",squash"`
+	commonsteps.HTTPConfig            `mapstructure:",squash"`
+	commonsteps.ISOConfig             `mapstructure:",squash"`
+	commonsteps.FloppyConfig          `mapstructure:",squash"`
+	commonsteps.CDConfig              `mapstructure:",squash"`
+	bootcommand.VNCConfig             `mapstructure:",squash"`
+	utmcommon.ExportConfig            `mapstructure:",squash"`
+	utmcommon.OutputConfig            `mapstructure:",squash"`
+	utmcommon.ShutdownConfig          `mapstructure:",squash"`
+	utmcommon.StartConfig             `mapstructure:",squash"`
+	utmcommon.CommConfig              `mapstructure:",squash"`
+	utmcommon.HWConfig                `mapstructure:",squash"`
+	utmcommon.UtmVersionConfig        `mapstructure:",squash"`
+	utmcommon.DriverConfig            `mapstructure:",squash"`
+	utmcommon.UtmBundleConfig         `mapstructure:",squash"`
+	utmcommon.GuestAdditionsConfig    `mapstructure:",squash"`
+	utmcommon.NoPauseConfig           `mapstructure:",squash"`
+	utmcommon.QemuConfig              `mapstructure:",squash"`
+	utmcommon.AdditionalISOsConfig    `mapstructure:",squash"`
+	utmcommon.WindowsUnattendedConfig `mapstructure:",squash"`
+	utmcommon.GuestHostnameConfig     `mapstructure:",squash"`
+	utmcommon.SSHAuthorizedKeysConfig `mapstructure:",squash"`
+	utmcommon.NetworkWaitConfig       `mapstructure:",squash"`
+	utmcommon.TestResumeConfig        `mapstructure:",squash"`
+	utmcommon.NetworkAdaptersConfig   `mapstructure:",squash"`
+	utmcommon.EventsConfig            `mapstructure:",squash"`
+	utmcommon.CloudInitConfig         `mapstructure:",squash"`
 
 	// Set this to true if you would like to use Hypervisor
 	// Defaults to false.
@@ -87,6 +100,16 @@ type Config struct {
 	// }
 	// ```
 	BootSteps [][]string `mapstructure:"boot_steps" required:"false"`
+	// How `boot_command` is typed over VNC. `text`, the default, sends
+	// characters, which QEMU turns into keys with a US layout. `scancode`
+	// sends the PC keyboard scancodes of the keys instead, which the guest
+	// reads with its own layout, the way other Packer builders type boot
+	// commands. Use `scancode` when non-US guests get the wrong characters.
+	// Characters are mapped to keys as laid out on a US keyboard. VMs
+	// without VNC, such as those of the apple backend, get the boot command
+	// typed into their UTM window instead, which needs the Accessibility
+	// permission and only supports `text`.
+	BootKeyMode string `mapstructure:"boot_key_mode" required:"false"`
 	// The display hardware type to use. This is used to attach the display
 	// device to the VM (for debugging or packaging). Some hardware types
 	// include "virtio-gpu-device", "virtio-rambfb", "virtio-rambfb-gl" etc.
@@ -107,11 +130,34 @@ type Config struct {
 	// When set to nvme, the drive is attached to an NVMe controller.
 	// When set to virtio, the drive is attached to a VirtIO controller.
 	ISOInterface string `mapstructure:"iso_interface" required:"false"`
+	// The type of controller that the cd_files ISO is attached to, defaults
+	// to usb. Use a controller such as ide or virtio when the guest needs a
+	// predictable device path for the CD. Options are
+	// none/‌IDE/‌SCSI/‌SD/‌MTD/‌Floppy/‌PFlash/‌VirtIO/‌NVMe/‌USB.
+	CDFilesInterface string `mapstructure:"cd_files_interface" required:"false"`
+	// The order the VM boots its devices in, such as `["cd", "disk"]` to
+	// boot the ISO even once the disk is bootable. Devices are `cd`, `disk`
+	// and `network`, which must come last as UTM only boots from the
+	// network once no drive booted. Defaults to the order the drives are
+	// attached in, the disk first.
+	BootOrder []string `mapstructure:"boot_order" required:"false"`
 	// Additional disks to create. Attachment starts at 1 since 0
 	// is the default disk. Each value represents the disk image size in MiB.
 	// Each additional disk uses the same disk parameters as the default disk.
 	// Unset by default.
 	AdditionalDiskSize []uint `mapstructure:"disk_additional_size" required:"false"`
+	// How the disks of the VM are allocated on the host, main and
+	// additional disks alike. `off`, the default, lets UTM create thin
+	// disks that only take the space the guest writes, at the cost of
+	// allocating it on the fly on the first write to each area. `metadata`
+	// preallocates the qcow2 metadata, which speeds up first writes for
+	// little space, and only applies to the qcow2 disks of QEMU VMs, as
+	// the raw disks of `apple` VMs have no metadata. `full` writes out the
+	// whole `disk_size` up front, the fastest for the guest but the disk
+	// takes all of its size on the host, in the exported VM too, and
+	// creating it takes a while. Preallocated disks are created with
+	// qemu-img, which must be installed.
+	DiskPreallocation string `mapstructure:"disk_preallocation" required:"false"`
 	// Set this to true if you would like to keep the VM registered with
 	// UTM. Defaults to false.
 	KeepRegistered bool `mapstructure:"keep_registered" required:"false"`
@@ -119,6 +165,17 @@ type Config struct {
 	// if the build output is not the resultant image, but created inside the
 	// VM.
 	SkipExport bool `mapstructure:"skip_export" required:"false"`
+	// Set this to true to leave the VM running and registered with UTM once
+	// provisioning is done, for example to keep using it as a development
+	// environment. This implies `skip_export` and `keep_registered`. ISOs
+	// attached during the build are still detached, which requires a restart
+	// of the VM when there are any. Defaults to false.
+	KeepRunning bool `mapstructure:"keep_running" required:"false"`
+	// Set this to false to always download the ISO from scratch. By default an
+	// interrupted download is resumed with HTTP range requests when the server
+	// supports them, and the resumed file is verified against `iso_checksum`.
+	// A resumed file that fails verification is downloaded again from scratch.
+	DownloadResume config.Trilean `mapstructure:"download_resume" required:"false"`
 	// The IP address that should be
 	// binded to for VNC. By default packer will use 127.0.0.1 for this. If you
 	// wish to bind to all interfaces use 0.0.0.0.
@@ -136,6 +193,19 @@ type Config struct {
 	// vnc display address.
 	VNCPortMin int `mapstructure:"vnc_port_min" required:"false"`
 	VNCPortMax int `mapstructure:"vnc_port_max"`
+	// Set this to true to take a screenshot of the VM display over VNC when
+	// the build fails, to see what the installer or the boot command was up
+	// to. The PNG is saved next to `output_directory`, which is deleted on
+	// failure, as `<output_directory>-failure-<vm id>-<timestamp>.png`.
+	// Nothing is saved when VNC is disabled or the VM has no display.
+	// Defaults to false.
+	ScreenshotOnFailure bool `mapstructure:"screenshot_on_failure" required:"false"`
+	// Set this to take a screenshot of the VM display over VNC every
+	// interval, such as `5s`, from the moment the VM starts until the
+	// communicator connects. The screenshots are numbered in order in the
+	// `screenshots` directory of `output_directory`, so the timing of
+	// `boot_command` can be reviewed. Needs VNC. Off by default.
+	ScreenshotInterval time.Duration `mapstructure:"screenshot_interval" required:"false"`
 	// QEMU system architecture of the virtual machine.
 	// If this is a QEMU virtual machine, you must specify the architecture
 	// Which is required in confirguration. By default, this is aarch64.
@@ -143,8 +213,19 @@ type Config struct {
 	// Backend to use for the virtual machine.
 	// apple : Apple Virtualization.framework backend.
 	// qemu : QEMU backend.
-	// By default, this is qemu.
+	// By default, this is qemu. The apple backend runs without VNC and
+	// rejects the QEMU-only options, such as `qemuargs`, `accelerator`,
+	// `hypervisor`, `uefi_boot` and `boot_command`.
 	VMBackend string `mapstructure:"vm_backend" required:"false"`
+	// Set this to true to let an aarch64 Linux guest run x86_64 binaries
+	// with Rosetta. UTM shares the Rosetta runtime with the guest as a
+	// virtiofs directory share tagged `rosetta`, which the guest mounts, for
+	// example with `mount -t virtiofs rosetta /media/rosetta`, and registers
+	// `/media/rosetta/rosetta` with binfmt_misc as the interpreter of x86_64
+	// ELF binaries. Rosetta only works with the `apple` backend and the
+	// `aarch64` architecture on Apple Silicon hosts, it is ignored with a
+	// warning otherwise. Off by default.
+	Rosetta bool `mapstructure:"rosetta" required:"false"`
 	// UTM VM icon.
 	VMIcon string `mapstructure:"vm_icon" required:"false"`
 	// This is the name of the utm file for the new virtual machine, without
@@ -155,6 +236,46 @@ type Config struct {
 	ctx interpolate.Context
 }
 
+// rosettaUnsupported returns why Rosetta can't be given to a VM of the
+// backend enum and guest architecture on a hostArch host, or an empty string
+// when it can.
+func rosettaUnsupported(backend string, guestArch string, hostArch string) string {
+	switch {
+	case backend != utmcommon.VMBackendCodes[utmcommon.VMBackendApple]:
+		return "it needs vm_backend = \"apple\", QEMU VMs can't use Rosetta"
+	case guestArch != "aarch64":
+		return fmt.Sprintf("it needs an aarch64 guest, not %s", guestArch)
+	case hostArch != "arm64":
+		return "it needs an Apple Silicon host"
+	}
+	return ""
+}
+
+// prepareAppleBackend rejects the options an Apple Virtualization VM can't
+// honor. Such a VM has no QEMU VNC server, so VNC is turned off, the boot
+// command is typed into the VM window instead and the options that only
+// work over VNC are errors.
+func (c *Config) prepareAppleBackend() []error {
+	var errs []error
+	for _, option := range []struct {
+		name string
+		set  bool
+	}{
+		{"hypervisor", c.Hypervisor},
+		{"uefi_boot", c.UEFIBoot},
+		{"boot_key_mode = \"scancode\"", c.BootKeyMode == utmcommon.BootKeyModeScancode},
+		{"screenshot_interval", c.ScreenshotInterval > 0},
+	} {
+		if option.set {
+			errs = append(errs, fmt.Errorf(
+				"%s only applies to QEMU VMs, it can't be used with vm_backend = %q",
+				option.name, utmcommon.VMBackendApple))
+		}
+	}
+	c.DisableVNC = true
+	return errs
+}
+
 func (c *Config) Prepare(raws ...interface{}) ([]string, error) {
 	err := config.Decode(c, &config.DecodeOpts{
 		PluginType:         BuilderId,
@@ -164,8 +285,11 @@ func (c *Config) Prepare(raws ...interface{}) ([]string, error) {
 			Exclude: []string{
 				"boot_command",
 				"boot_steps",
+				"guest_additions_filename",
+				"guest_additions_install_command",
 				"guest_additions_path",
 				"guest_additions_url",
+				"windows_unattended_content",
 			},
 		},
 	}, raws...)
@@ -177,22 +301,79 @@ func (c *Config) Prepare(raws ...interface{}) ([]string, error) {
 	var errs *packersdk.MultiError
 	warnings := make([]string, 0)
 
-	isoWarnings, isoErrs := c.ISOConfig.Prepare(&c.ctx)
-	warnings = append(warnings, isoWarnings...)
-	errs = packersdk.MultiErrorAppend(errs, isoErrs...)
+	// Check the checksum format first, since ISOConfig only finds a bad
+	// one with a less helpful error once it parses it.
+	if err := utmcommon.ValidateChecksum("iso_checksum", c.ISOChecksum); err != nil {
+		errs = packersdk.MultiErrorAppend(errs, err)
+	} else {
+		isoWarnings, isoErrs := c.ISOConfig.Prepare(&c.ctx)
+		warnings = append(warnings, isoWarnings...)
+		errs = packersdk.MultiErrorAppend(errs, isoErrs...)
+	}
 
 	errs = packersdk.MultiErrorAppend(errs, c.ExportConfig.Prepare(&c.ctx)...)
-	errs = packersdk.MultiErrorAppend(errs, c.ExportConfig.Prepare(&c.ctx)...)
+	if c.ResetNVRAM && c.VMBackend == utmcommon.VMBackendApple {
+		// Apple Virtualization VMs don't keep their firmware state in the
+		// EFI variable store of QEMU VMs
+		errs = packersdk.MultiErrorAppend(errs, errors.New(
+			"reset_nvram only applies to QEMU VMs, it can't be used with vm_backend = 'apple'"))
+	} else {
+		errs = packersdk.MultiErrorAppend(errs, c.ExportConfig.PrepareNVRAM(c.UEFIBoot, c.SuspendBeforeExport)...)
+	}
 	errs = packersdk.MultiErrorAppend(errs, c.CDConfig.Prepare(&c.ctx)...)
+	cdLabel, cdWarnings, cdErrs := utmcommon.PrepareCDLabel(c.CDLabel, c.CDFiles, c.CDContent)
+	c.CDLabel = cdLabel
+	warnings = append(warnings, cdWarnings...)
+	errs = packersdk.MultiErrorAppend(errs, cdErrs...)
 	errs = packersdk.MultiErrorAppend(
 		errs, c.OutputConfig.Prepare(&c.ctx, &c.PackerConfig)...)
 	errs = packersdk.MultiErrorAppend(errs, c.HTTPConfig.Prepare(&c.ctx)...)
 	errs = packersdk.MultiErrorAppend(errs, c.ShutdownConfig.Prepare(&c.ctx)...)
+	errs = packersdk.MultiErrorAppend(errs, c.ShutdownConfig.PrepareSuspend(c.SkipExport, c.KeepRunning)...)
+	errs = packersdk.MultiErrorAppend(errs, c.ExportConfig.PrepareExtraBundleFiles(c.SkipExport, c.KeepRunning)...)
+	errs = packersdk.MultiErrorAppend(errs, c.StartConfig.Prepare()...)
+	errs = packersdk.MultiErrorAppend(errs, c.GuestHostnameConfig.Prepare(c.Comm.Type)...)
+	errs = packersdk.MultiErrorAppend(errs, c.SSHAuthorizedKeysConfig.Prepare(c.Comm.Type, c.Comm.SSHUsername)...)
+	errs = packersdk.MultiErrorAppend(errs, c.NetworkWaitConfig.Prepare(c.Comm.Type)...)
+	errs = packersdk.MultiErrorAppend(errs, c.TestResumeConfig.Prepare(c.Comm.Type)...)
+	errs = packersdk.MultiErrorAppend(errs, c.CloudInitConfig.Prepare(c.Comm.Type)...)
 	errs = packersdk.MultiErrorAppend(errs, c.CommConfig.Prepare(&c.ctx)...)
+	errs = packersdk.MultiErrorAppend(errs, c.NetworkAdaptersConfig.Prepare(c.Comm.Type, c.SkipNatMapping)...)
+	errs = packersdk.MultiErrorAppend(errs, c.HWConfig.Prepare(&c.ctx)...)
 	errs = packersdk.MultiErrorAppend(errs, c.UtmBundleConfig.Prepare(&c.ctx)...)
 	errs = packersdk.MultiErrorAppend(errs, c.UtmVersionConfig.Prepare(c.Comm.Type)...)
+	errs = packersdk.MultiErrorAppend(errs, c.DriverConfig.Prepare()...)
+	errs = packersdk.MultiErrorAppend(errs, c.GuestAdditionsConfig.Prepare(c.Comm.Type)...)
+	errs = packersdk.MultiErrorAppend(errs, c.NoPauseConfig.Prepare(&c.ctx)...)
 	errs = packersdk.MultiErrorAppend(errs, c.VNCConfig.Prepare(&c.ctx)...)
-	errs = packersdk.MultiErrorAppend(errs, c.QemuConfig.Prepare(&c.ctx)...)
+	if c.VMBackend == utmcommon.VMBackendApple {
+		errs = packersdk.MultiErrorAppend(errs, c.QemuConfig.PrepareAppleBackend()...)
+	}
+	qemuWarnings, qemuErrs := c.QemuConfig.Prepare(&c.ctx)
+	warnings = append(warnings, qemuWarnings...)
+	errs = packersdk.MultiErrorAppend(errs, qemuErrs...)
+	if c.DisableDefaultAccelerator && c.Hypervisor {
+		errs = packersdk.MultiErrorAppend(errs, errors.New(
+			"hypervisor conflicts with disable_default_accelerator, UTM would run the VM with hvf"))
+	}
+	errs = packersdk.MultiErrorAppend(errs, c.AdditionalISOsConfig.Prepare(&c.ctx, c.QemuMonitor)...)
+
+	// The boot ISO, cd_files and additional_isos must not be the same file
+	var isoFiles []utmcommon.NamedFile
+	for _, url := range c.ISOUrls {
+		isoFiles = append(isoFiles, utmcommon.NamedFile{Name: "iso_url", Path: url})
+	}
+	for i, file := range c.CDFiles {
+		isoFiles = append(isoFiles, utmcommon.NamedFile{Name: fmt.Sprintf("cd_files[%d]", i), Path: file})
+	}
+	for i, iso := range c.AdditionalISOs {
+		isoFiles = append(isoFiles, utmcommon.NamedFile{Name: fmt.Sprintf("additional_isos[%d]", i), Path: iso.Url})
+	}
+	if err := utmcommon.CheckDistinctFiles(isoFiles); err != nil {
+		errs = packersdk.MultiErrorAppend(errs, err)
+	}
+	errs = packersdk.MultiErrorAppend(
+		errs, c.WindowsUnattendedConfig.Prepare(&c.ctx, c.Comm.WinRMPassword, c.GuestHostname)...)
 
 	if c.DiskSize == 0 {
 		c.DiskSize = 40960
@@ -207,19 +388,50 @@ func (c *Config) Prepare(raws ...interface{}) ([]string, error) {
 	}
 
 	if c.VMBackend == "" {
-		c.VMBackend = "qemu"
+		c.VMBackend = utmcommon.VMBackendQEMU
+	}
+	if c.DiskPreallocation == "" {
+		c.DiskPreallocation = utmcommon.DiskPreallocationOff
+	}
+	if err := utmcommon.ValidateDiskPreallocation(
+		c.DiskPreallocation, utmcommon.DiskFormatForBackend(c.VMBackend)); err != nil {
+		errs = packersdk.MultiErrorAppend(errs, err)
+	} else if c.DiskPreallocation != utmcommon.DiskPreallocationOff {
+		if _, err := exec.LookPath("qemu-img"); err != nil {
+			errs = packersdk.MultiErrorAppend(errs, fmt.Errorf(
+				"disk_preallocation is %s, but qemu-img is not available: %s", c.DiskPreallocation, err))
+		}
+	}
+	if c.VMBackend == utmcommon.VMBackendApple {
+		errs = packersdk.MultiErrorAppend(errs, c.prepareAppleBackend()...)
+		if c.SuspendBeforeExport {
+			warnings = append(warnings,
+				"suspend_before_export needs macOS 14 or later to save the state of a VM on the apple backend")
+		}
 	}
 	// Validate and use Enums for the VM backend
-	switch c.VMBackend {
-	case "apple":
-		c.VMBackend = "ApPl"
-	case "qemu":
-		c.VMBackend = "QeMu"
-	default:
+	if code, ok := utmcommon.VMBackendCodes[c.VMBackend]; ok {
+		c.VMBackend = code
+	} else {
 		errs = packersdk.MultiErrorAppend(
 			errs, errors.New("vm_backend must be either 'apple' or 'qemu'"))
 	}
 
+	// The guest tools download and install are picked for the guest OS and
+	// architecture, so a Windows build needs tools for its architecture
+	if c.GuestAdditionsMode != utmcommon.GuestAdditionsModeDisable && c.GuestAdditionsInstallCommand == "" {
+		if err := utmcommon.CheckGuestAdditionsPlatform(c.guestOS(), c.VMArch); err != nil {
+			errs = packersdk.MultiErrorAppend(errs, err)
+		}
+	}
+
+	if c.Rosetta {
+		if reason := rosettaUnsupported(c.VMBackend, c.VMArch, utmcommon.HostArch()); reason != "" {
+			warnings = append(warnings, fmt.Sprintf("rosetta is ignored: %s", reason))
+			c.Rosetta = false
+		}
+	}
+
 	if c.VNCBindAddress == "" {
 		c.VNCBindAddress = "127.0.0.1"
 	}
@@ -232,10 +444,18 @@ func (c *Config) Prepare(raws ...interface{}) ([]string, error) {
 		c.VNCPortMax = 6000
 	}
 
+	if c.KeepRunning {
+		c.SkipExport = true
+		c.KeepRegistered = true
+	}
+
 	if c.VMName == "" {
 		c.VMName = fmt.Sprintf(
 			"packer-%s-%d", c.PackerBuildName, interpolate.InitTime.Unix())
 	}
+	if err := utmcommon.ValidateVMName(c.VMName); err != nil {
+		errs = packersdk.MultiErrorAppend(errs, err)
+	}
 
 	if c.VNCPortMin < 5900 {
 		errs = packersdk.MultiErrorAppend(
@@ -244,7 +464,16 @@ func (c *Config) Prepare(raws ...interface{}) ([]string, error) {
 
 	if c.VNCPortMin > 65535 || c.VNCPortMax > 65535 {
 		errs = packersdk.MultiErrorAppend(
-			errs, fmt.Errorf("vmc_port_min and vnc_port_max must both be below 65535 to be valid TCP ports"))
+			errs, fmt.Errorf("vnc_port_min and vnc_port_max must both be below 65535 to be valid TCP ports"))
+	}
+
+	if c.ScreenshotInterval < 0 {
+		errs = packersdk.MultiErrorAppend(
+			errs, fmt.Errorf("screenshot_interval must not be negative"))
+	}
+	if c.ScreenshotInterval > 0 && c.DisableVNC {
+		errs = packersdk.MultiErrorAppend(
+			errs, fmt.Errorf("screenshot_interval needs VNC, it can't be used with disable_vnc"))
 	}
 
 	if c.VNCPortMin > c.VNCPortMax {
@@ -257,6 +486,15 @@ func (c *Config) Prepare(raws ...interface{}) ([]string, error) {
 			fmt.Errorf("boot_command and boot_steps cannot be used together"))
 	}
 
+	switch c.BootKeyMode {
+	case "":
+		c.BootKeyMode = utmcommon.BootKeyModeText
+	case utmcommon.BootKeyModeText, utmcommon.BootKeyModeScancode:
+	default:
+		errs = packersdk.MultiErrorAppend(errs,
+			fmt.Errorf("boot_key_mode must be %q or %q", utmcommon.BootKeyModeText, utmcommon.BootKeyModeScancode))
+	}
+
 	if c.ISOInterface == "" {
 		c.ISOInterface = "usb"
 	}
@@ -265,6 +503,19 @@ func (c *Config) Prepare(raws ...interface{}) ([]string, error) {
 		c.GuestAdditionsInterface = c.ISOInterface
 	}
 
+	if err := utmcommon.ValidateBootOrder(c.BootOrder); err != nil {
+		errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("boot_order: %s", err))
+	}
+
+	if c.CDFilesInterface == "" {
+		c.CDFilesInterface = "usb"
+	}
+
+	if _, err := utmcommon.GetControllerEnumCode(c.CDFilesInterface); err != nil {
+		errs = packersdk.MultiErrorAppend(
+			errs, fmt.Errorf("cd_files_interface is invalid: %s", err))
+	}
+
 	switch c.HardDriveInterface {
 	case "none", "ide", "scsi", "virtio", "nvme", "usb":
 		// do nothing
@@ -295,3 +546,13 @@ func (c *Config) Prepare(raws ...interface{}) ([]string, error) {
 	return warnings, nil
 
 }
+
+// guestOS returns the guest OS the build looks like it is for before the
+// guest boots, windows for builds with the winrm communicator or an
+// unattended Windows install, or an empty string when it can't tell.
+func (c *Config) guestOS() string {
+	if c.Comm.Type == "winrm" || c.WindowsUnattended != "" || c.WindowsUnattendedContent != "" {
+		return utmcommon.GuestOSWindows
+	}
+	return ""
+}
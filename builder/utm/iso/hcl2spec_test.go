@@ -0,0 +1,58 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package iso
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/naveenrajm7/packer-plugin-utm/builder/utm/common/hcl2spectest"
+)
+
+func TestHCL2Spec_fields(t *testing.T) {
+	for _, name := range hcl2spectest.MissingFields(Config{}, new(FlatConfig).HCL2Spec()) {
+		t.Errorf("%s is missing from the generated hcl2spec, run `make generate`", name)
+	}
+}
+
+func TestHCL2Spec_decode(t *testing.T) {
+	dir := t.TempDir()
+	isoPath := filepath.Join(dir, "install.iso")
+	if err := os.WriteFile(isoPath, nil, 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	value := hcl2spectest.Decode(t, new(FlatConfig).HCL2Spec(), `
+iso_url          = "`+isoPath+`"
+iso_checksum     = "none"
+output_directory = "`+filepath.Join(dir, "output")+`"
+ssh_username     = "packer"
+shutdown_command = "sudo poweroff"
+
+accelerator         = "auto"
+cpu_model           = "max"
+cpu_features        = ["+aes", "-sve"]
+qemu_monitor        = true
+qemuargs            = [["-no-hpet"]]
+boot_key_mode       = "scancode"
+screenshot_interval = "10s"
+scripts_dir         = ""
+`)
+
+	var c Config
+	if _, err := c.Prepare(value); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if c.Accelerator != "auto" || c.CPUModel != "max" || !c.QemuMonitor || c.BootKeyMode != "scancode" {
+		t.Fatalf("bad config: %#v", c.QemuConfig)
+	}
+	if !reflect.DeepEqual(c.CPUFeatures, []string{"+aes", "-sve"}) {
+		t.Fatalf("bad cpu_features: %#v", c.CPUFeatures)
+	}
+	if !reflect.DeepEqual(c.QemuArgs, [][]string{{"-no-hpet"}}) {
+		t.Fatalf("bad qemuargs: %#v", c.QemuArgs)
+	}
+}
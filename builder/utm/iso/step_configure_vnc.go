@@ -15,7 +15,8 @@ import (
 	utmcommon "github.com/naveenrajm7/packer-plugin-utm/builder/utm/common"
 )
 
-// This step configures the VM to enable the VNC server.
+// This step configures the VM to enable the VNC server, and registers it
+// for Driver.Screenshot.
 //
 // Uses:
 //
@@ -31,7 +32,8 @@ type stepConfigureVNC struct {
 	VNCPortMax         int
 	VNCDisablePassword bool
 
-	l *net.Listener
+	l    *net.Listener
+	vmId string
 }
 
 func VNCPassword(skipPassword bool) string {
@@ -60,7 +62,12 @@ func (s *stepConfigureVNC) Run(ctx context.Context, state multistep.StateBag) mu
 
 	driver := state.Get("driver").(utmcommon.Driver)
 	ui := state.Get("ui").(packersdk.Ui)
-	vmId := state.Get("vmId").(string)
+	vmId, err := utmcommon.GetVMID(state)
+	if err != nil {
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
 
 	// Find an open VNC port. Note that this can still fail later on
 	// because we have to release the port at some point. But this does its
@@ -69,7 +76,6 @@ func (s *stepConfigureVNC) Run(ctx context.Context, state multistep.StateBag) mu
 	ui.Say(msg)
 	log.Print(msg)
 
-	var err error
 	s.l, err = net.ListenRangeConfig{
 		Addr:    s.VNCBindAddress,
 		Min:     s.VNCPortMin,
@@ -90,12 +96,14 @@ func (s *stepConfigureVNC) Run(ctx context.Context, state multistep.StateBag) mu
 	log.Printf("Found available VNC port: %d on IP: %s", vncPort, s.VNCBindAddress)
 	state.Put("vnc_port", vncPort)
 	state.Put("vnc_password", vncPassword)
+	utmcommon.RegisterVNCDisplay(vmId, s.VNCBindAddress, vncPort, vncPassword)
+	s.vmId = vmId
 
 	// Add VNC arguments to the VM via Qemu additional arguments.
 	// Send choosen vncPort - 5900 as the VNC port.
 	// IMPORTANT: The AppleScript replaces (not appends) all QEMU additional args,
 	// so we must include any previously-set user args alongside the VNC arg.
-	vncQemuArg := fmt.Sprintf("-vnc %s:%d", s.VNCBindAddress, vncPort-5900)
+	vncQemuArg := fmt.Sprintf("%s %s:%d", utmcommon.QemuFlagVNC, s.VNCBindAddress, vncPort-5900)
 
 	// Collect all args: user qemuargs (already set) + VNC arg
 	addQemuArgsCommand := []string{
@@ -109,7 +117,7 @@ func (s *stepConfigureVNC) Run(ctx context.Context, state multistep.StateBag) mu
 	addQemuArgsCommand = append(addQemuArgsCommand, vncQemuArg)
 
 	ui.Say("Adding QEMU additional arguments...")
-	_, err = driver.ExecuteOsaScript(addQemuArgsCommand...)
+	_, err = driver.ExecuteOsaScript(ctx, addQemuArgsCommand...)
 	if err != nil {
 		err := fmt.Errorf("error adding QEMU additional arguments: %s", err)
 		state.Put("error", err)
@@ -125,6 +133,9 @@ func (s *stepConfigureVNC) Run(ctx context.Context, state multistep.StateBag) mu
 }
 
 func (s *stepConfigureVNC) Cleanup(multistep.StateBag) {
+	if s.vmId != "" {
+		utmcommon.UnregisterVNCDisplay(s.vmId)
+	}
 	// release the port
 	if s.l != nil {
 		err := s.l.Close()
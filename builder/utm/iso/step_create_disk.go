@@ -3,22 +3,36 @@ package iso
 import (
 	"context"
 	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
 	"strconv"
 
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/tmp"
 	utmcommon "github.com/naveenrajm7/packer-plugin-utm/builder/utm/common"
 )
 
 // This step creates the virtual disk that will be used as the
-// hard drive for the virtual machine.
-type stepCreateDisk struct{}
+// hard drive for the virtual machine. UTM creates thin disks, so
+// preallocated ones are created with qemu-img and attached, which has UTM
+// copy them into the VM bundle.
+type stepCreateDisk struct {
+	// tmpDir holds the preallocated disks until UTM has copied them.
+	tmpDir string
+}
 
 func (s *stepCreateDisk) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
 	config := state.Get("config").(*Config)
 	driver := state.Get("driver").(utmcommon.Driver)
 	ui := state.Get("ui").(packersdk.Ui)
-	vmId := state.Get("vmId").(string)
+	vmId, err := utmcommon.GetVMID(state)
+	if err != nil {
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
 
 	// The main disk and additional disks
 	// We do not give names to the disks, as UTM does not support it
@@ -44,7 +58,22 @@ func (s *stepCreateDisk) Run(ctx context.Context, state multistep.StateBag) mult
 			"--interface", controllerEnumCode,
 			"--size", strconv.FormatUint(uint64(diskSizes[i]), 10),
 		}
-		_, err = driver.ExecuteOsaScript(command...)
+		if config.DiskPreallocation != utmcommon.DiskPreallocationOff {
+			path, err := s.createPreallocatedDisk(config, i, diskSizes[i])
+			if err != nil {
+				err := fmt.Errorf("error creating hard drive: %s", err)
+				state.Put("error", err)
+				ui.Error(err.Error())
+				return multistep.ActionHalt
+			}
+			command = []string{
+				"attach_iso.applescript", vmId,
+				"--interface", controllerEnumCode,
+				"--source", path,
+				"--removable", "false",
+			}
+		}
+		_, err = driver.ExecuteOsaScript(ctx, command...)
 		if err != nil {
 			err := fmt.Errorf("error creating hard drive: %s", err)
 			state.Put("error", err)
@@ -58,4 +87,33 @@ func (s *stepCreateDisk) Run(ctx context.Context, state multistep.StateBag) mult
 	return multistep.ActionContinue
 }
 
-func (s *stepCreateDisk) Cleanup(state multistep.StateBag) {}
+// createPreallocatedDisk creates the index-th disk of the VM with qemu-img,
+// preallocated as set by disk_preallocation, and returns its path.
+func (s *stepCreateDisk) createPreallocatedDisk(config *Config, index int, sizeMiB uint) (string, error) {
+	qemuImg, err := exec.LookPath("qemu-img")
+	if err != nil {
+		return "", fmt.Errorf("disk_preallocation needs qemu-img: %s", err)
+	}
+	if s.tmpDir == "" {
+		if s.tmpDir, err = tmp.Dir("packer-disk"); err != nil {
+			return "", err
+		}
+	}
+	format := utmcommon.DiskFormatForBackend(config.VMBackend)
+	path := filepath.Join(s.tmpDir, fmt.Sprintf("disk%d.%s", index, format))
+	args := utmcommon.QemuImgCreateArgs(path, format, sizeMiB, config.DiskPreallocation)
+	if output, err := exec.Command(qemuImg, args...).CombinedOutput(); err != nil {
+		return "", fmt.Errorf("%s, output: %s", err, output)
+	}
+	return path, nil
+}
+
+func (s *stepCreateDisk) Cleanup(state multistep.StateBag) {
+	if s.tmpDir == "" {
+		return
+	}
+	ui := state.Get("ui").(packersdk.Ui)
+	if err := os.RemoveAll(s.tmpDir); err != nil {
+		ui.Error(fmt.Sprintf("error removing the preallocated disks: %s", err))
+	}
+}
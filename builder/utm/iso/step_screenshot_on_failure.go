@@ -0,0 +1,73 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package iso
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	utmcommon "github.com/naveenrajm7/packer-plugin-utm/builder/utm/common"
+)
+
+// This step does nothing when it runs. When the build halts, its Cleanup
+// grabs the display of the VM with Driver.Screenshot and saves it, before
+// the VM is stopped. The screenshot is saved next to the output directory, which
+// is deleted when a build fails.
+//
+// Uses:
+//
+//	driver   utmcommon.Driver
+//	ui       packersdk.Ui
+//	vmId     string
+//	vnc_port int
+type stepScreenshotOnFailure struct {
+	Enabled   bool
+	OutputDir string
+}
+
+func (s *stepScreenshotOnFailure) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	return multistep.ActionContinue
+}
+
+func (s *stepScreenshotOnFailure) Cleanup(state multistep.StateBag) {
+	if !s.Enabled {
+		return
+	}
+	if _, halted := state.GetOk(multistep.StateHalted); !halted {
+		return
+	}
+
+	if _, ok := state.Get("vnc_port").(int); !ok {
+		log.Println("VNC is not configured, not taking a screenshot of the failed build.")
+		return
+	}
+	vmId, err := utmcommon.GetVMID(state)
+	if err != nil {
+		log.Printf("Not taking a screenshot of the failed build: %s", err)
+		return
+	}
+	driver := state.Get("driver").(utmcommon.Driver)
+	ui := state.Get("ui").(packersdk.Ui)
+
+	data, err := driver.Screenshot(vmId)
+	if err != nil {
+		ui.Say(fmt.Sprintf("Could not take a screenshot of the failed build: %s", err))
+		return
+	}
+
+	path := filepath.Join(filepath.Dir(filepath.Clean(s.OutputDir)), fmt.Sprintf("%s-failure-%s-%s.png",
+		filepath.Base(filepath.Clean(s.OutputDir)), vmId, time.Now().Format("20060102-150405")))
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		ui.Error(fmt.Sprintf("Error saving the screenshot of the failed build: %s", err))
+		return
+	}
+
+	ui.Say(fmt.Sprintf("Saved a screenshot of the failed build to %s", path))
+}
@@ -0,0 +1,136 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package iso
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	utmcommon "github.com/naveenrajm7/packer-plugin-utm/builder/utm/common"
+)
+
+// screenshotsDir is the directory of the output directory the periodic
+// screenshots are saved in.
+const screenshotsDir = "screenshots"
+
+// This step starts taking a screenshot of the VM display with
+// Driver.Screenshot every
+// Interval, numbered in order, until stepStopScreenshots runs once the
+// communicator is connected, or the build ends.
+//
+// Uses:
+//
+//	driver   utmcommon.Driver
+//	ui       packersdk.Ui
+//	vmId     string
+//	vnc_port int
+//
+// Produces:
+//
+//	stop_screenshots func() - Stops taking screenshots.
+type stepStartScreenshots struct {
+	Interval  time.Duration
+	OutputDir string
+
+	stop func()
+}
+
+func (s *stepStartScreenshots) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	if s.Interval <= 0 {
+		return multistep.ActionContinue
+	}
+
+	driver := state.Get("driver").(utmcommon.Driver)
+	ui := state.Get("ui").(packersdk.Ui)
+	if _, ok := state.Get("vnc_port").(int); !ok {
+		log.Println("VNC is not configured, not taking screenshots.")
+		return multistep.ActionContinue
+	}
+	vmId, err := utmcommon.GetVMID(state)
+	if err != nil {
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	dir := filepath.Join(s.OutputDir, screenshotsDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		err := fmt.Errorf("error creating screenshot directory: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	ui.Say(fmt.Sprintf("Taking a screenshot every %s into %s", s.Interval, dir))
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(s.Interval)
+		defer ticker.Stop()
+		for n := 1; ; n++ {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			path := filepath.Join(dir, fmt.Sprintf("screenshot-%04d.png", n))
+			if err := saveScreenshot(driver, vmId, path); err != nil {
+				log.Printf("Error taking screenshot %d: %s", n, err)
+			}
+		}
+	}()
+
+	var once sync.Once
+	s.stop = func() {
+		once.Do(func() {
+			close(done)
+			wg.Wait()
+		})
+	}
+	state.Put("stop_screenshots", s.stop)
+
+	return multistep.ActionContinue
+}
+
+func (s *stepStartScreenshots) Cleanup(state multistep.StateBag) {
+	if s.stop != nil {
+		s.stop()
+	}
+}
+
+// saveScreenshot grabs the display of the VM into a PNG at path.
+func saveScreenshot(driver utmcommon.Driver, vmId string, path string) error {
+	data, err := driver.Screenshot(vmId)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// This step stops the screenshots started by stepStartScreenshots.
+//
+// Uses:
+//
+//	stop_screenshots func() (optional)
+type stepStopScreenshots struct{}
+
+func (s *stepStopScreenshots) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	if stop, ok := state.Get("stop_screenshots").(func()); ok {
+		log.Println("Communicator connected, no longer taking screenshots.")
+		stop()
+	}
+	return multistep.ActionContinue
+}
+
+func (s *stepStopScreenshots) Cleanup(state multistep.StateBag) {}
@@ -9,13 +9,13 @@ import (
 	"log"
 	"net"
 	"strconv"
-	"time"
 
 	"github.com/hashicorp/packer-plugin-sdk/bootcommand"
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
 	"github.com/mitchellh/go-vnc"
+	utmcommon "github.com/naveenrajm7/packer-plugin-utm/builder/utm/common"
 )
 
 const KeyLeftShift uint32 = 0xFFE1
@@ -27,13 +27,17 @@ type bootCommandTemplateData struct {
 	SSHPublicKey string
 }
 
-// This step "types" the boot command into the VM over VNC.
+// This step "types" the boot command into the VM over VNC or, for a VM
+// without VNC such as one of the apple backend, into its UTM window with
+// Driver.SendKeys.
 //
 // Uses:
 //
 //	config *config
+//	driver utmcommon.Driver
 //	http_port int
 //	ui     packersdk.Ui
+//	vmId   string
 //	vnc_port int
 //
 // Produces:
@@ -43,10 +47,6 @@ type stepTypeBootCommand struct{}
 
 func (s *stepTypeBootCommand) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
 	config := state.Get("config").(*Config)
-	if config.DisableVNC {
-		log.Println("Skipping boot command step...")
-		return multistep.ActionContinue
-	}
 
 	command := config.FlatBootCommand()
 	bootSteps := config.BootSteps
@@ -54,6 +54,10 @@ func (s *stepTypeBootCommand) Run(ctx context.Context, state multistep.StateBag)
 	if len(command) > 0 {
 		bootSteps = [][]string{{command}}
 	}
+	if len(bootSteps) == 0 {
+		log.Println("Skipping boot command step...")
+		return multistep.ActionContinue
+	}
 
 	return typeBootCommands(ctx, state, bootSteps)
 }
@@ -65,57 +69,12 @@ func typeBootCommands(ctx context.Context, state multistep.StateBag, bootSteps [
 	debug := state.Get("debug").(bool)
 	httpPort := state.Get("http_port").(int)
 	ui := state.Get("ui").(packersdk.Ui)
-	vncPort := state.Get("vnc_port").(int)
-	vncIP := config.VNCBindAddress
-	vncPassword := state.Get("vnc_password")
-
-	// Wait the for the vm to boot.
-	if int64(config.BootWait) > 0 {
-		ui.Say(fmt.Sprintf("Waiting %s for boot...", config.BootWait))
-		select {
-		case <-time.After(config.BootWait):
-			break
-		case <-ctx.Done():
-			return multistep.ActionHalt
-		}
-	}
 
 	var pauseFn multistep.DebugPauseFn
 	if debug {
 		pauseFn = state.Get("pauseFn").(multistep.DebugPauseFn)
 	}
 
-	// Connect to VNC
-	ui.Say(fmt.Sprintf("Connecting to VM via VNC (%s:%d)", vncIP, vncPort))
-
-	nc, err := net.Dial("tcp", net.JoinHostPort(vncIP, strconv.Itoa(vncPort)))
-	if err != nil {
-		err := fmt.Errorf("error connecting to VNC: %s", err)
-		state.Put("error", err)
-		ui.Error(err.Error())
-		return multistep.ActionHalt
-	}
-	defer func() { _ = nc.Close() }()
-
-	var auth []vnc.ClientAuth
-
-	if vncPassword != nil && len(vncPassword.(string)) > 0 {
-		auth = []vnc.ClientAuth{&vnc.PasswordAuth{Password: vncPassword.(string)}}
-	} else {
-		auth = []vnc.ClientAuth{new(vnc.ClientAuthNone)}
-	}
-
-	c, err := vnc.Client(nc, &vnc.ClientConfig{Auth: auth, Exclusive: false})
-	if err != nil {
-		err := fmt.Errorf("error handshaking with VNC: %s", err)
-		state.Put("error", err)
-		ui.Error(err.Error())
-		return multistep.ActionHalt
-	}
-	defer func() { _ = c.Close() }()
-
-	log.Printf("Connected to VNC desktop: %s", c.DesktopName)
-
 	hostIP := state.Get("http_ip").(string)
 	SSHPublicKey := string(config.Comm.SSHPublicKey)
 	configCtx := config.ctx
@@ -126,9 +85,31 @@ func typeBootCommands(ctx context.Context, state multistep.StateBag, bootSteps [
 		SSHPublicKey,
 	}
 
-	d := bootcommand.NewVNCDriver(c, config.BootKeyInterval)
-
-	ui.Say("Typing the boot commands over VNC...")
+	var typeKeys func(command string) error
+	if config.DisableVNC {
+		driver := state.Get("driver").(utmcommon.Driver)
+		vmId, err := utmcommon.GetVMID(state)
+		if err != nil {
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+		ui.Say("Typing the boot commands into the VM window...")
+		typeKeys = func(command string) error {
+			return driver.SendKeys(ctx, vmId, command)
+		}
+	} else {
+		d, closeVNC, err := connectVNC(state, config, ui)
+		if err != nil {
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+		defer closeVNC()
+		typeKeys = func(command string) error {
+			return utmcommon.RunKeySequence(ctx, d, command)
+		}
+	}
 
 	for _, step := range bootSteps {
 		if len(step) == 0 {
@@ -156,15 +137,7 @@ func typeBootCommands(ctx context.Context, state multistep.StateBag, bootSteps [
 			return multistep.ActionHalt
 		}
 
-		seq, err := bootcommand.GenerateExpressionSequence(command)
-		if err != nil {
-			err := fmt.Errorf("error generating boot command: %s", err)
-			state.Put("error", err)
-			ui.Error(err.Error())
-			return multistep.ActionHalt
-		}
-
-		if err := seq.Do(ctx, d); err != nil {
+		if err := typeKeys(command); err != nil {
 			err := fmt.Errorf("error running boot command: %s", err)
 			state.Put("error", err)
 			ui.Error(err.Error())
@@ -186,3 +159,50 @@ func typeBootCommands(ctx context.Context, state multistep.StateBag, bootSteps [
 
 	return multistep.ActionContinue
 }
+
+// connectVNC connects to the VNC server of the VM and returns the driver
+// typing over it in boot_key_mode, with a function closing the connection.
+func connectVNC(state multistep.StateBag, config *Config, ui packersdk.Ui) (bootcommand.BCDriver, func(), error) {
+	vncPort := state.Get("vnc_port").(int)
+	vncIP := config.VNCBindAddress
+	vncPassword := state.Get("vnc_password")
+
+	ui.Say(fmt.Sprintf("Connecting to VM via VNC (%s:%d)", vncIP, vncPort))
+
+	nc, err := net.Dial("tcp", net.JoinHostPort(vncIP, strconv.Itoa(vncPort)))
+	if err != nil {
+		return nil, nil, fmt.Errorf("error connecting to VNC: %s", err)
+	}
+
+	var auth []vnc.ClientAuth
+
+	if vncPassword != nil && len(vncPassword.(string)) > 0 {
+		auth = []vnc.ClientAuth{&vnc.PasswordAuth{Password: vncPassword.(string)}}
+	} else {
+		auth = []vnc.ClientAuth{new(vnc.ClientAuthNone)}
+	}
+
+	c, err := vnc.Client(nc, &vnc.ClientConfig{Auth: auth, Exclusive: false})
+	if err != nil {
+		_ = nc.Close()
+		return nil, nil, fmt.Errorf("error handshaking with VNC: %s", err)
+	}
+	closeVNC := func() {
+		_ = c.Close()
+		_ = nc.Close()
+	}
+
+	log.Printf("Connected to VNC desktop: %s", c.DesktopName)
+
+	if config.BootKeyMode == utmcommon.BootKeyModeScancode {
+		d, err := utmcommon.NewVNCScancodeDriver(c, nc, config.BootKeyInterval)
+		if err != nil {
+			closeVNC()
+			return nil, nil, err
+		}
+		ui.Say("Typing the boot commands over VNC as scancodes...")
+		return d, closeVNC, nil
+	}
+	ui.Say("Typing the boot commands over VNC...")
+	return bootcommand.NewVNCDriver(c, config.BootKeyInterval), closeVNC, nil
+}
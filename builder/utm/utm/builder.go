@@ -31,15 +31,52 @@ func (b *Builder) Prepare(raws ...interface{}) ([]string, []string, error) {
 	return nil, warnings, nil
 }
 
+// sourceSteps returns the steps creating the VM of the build, by cloning
+// source_vm or by downloading and importing source_path.
+func (b *Builder) sourceSteps() []multistep.Step {
+	if b.config.SourceVM != "" {
+		return []multistep.Step{
+			&StepClone{
+				SourceVM:       b.config.SourceVM,
+				Name:           b.config.VMName,
+				Linked:         b.config.Linked(),
+				KeepRegistered: b.config.KeepRegistered,
+			},
+		}
+	}
+	return []multistep.Step{
+		&utmcommon.StepUtmDownload{
+			Checksum:    b.config.Checksum,
+			Description: "UTM",
+			Extension:   "utm",
+			ResultKey:   "vm_path",
+			TargetPath:  b.config.TargetPath,
+			Url:         []string{b.config.SourcePath},
+		},
+		&StepImport{
+			Name:           b.config.VMName,
+			KeepRegistered: b.config.KeepRegistered,
+		},
+	}
+}
+
 // Run executes a Packer build and returns a packersdk.Artifact representing
 // a UTM appliance.
 func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook) (packersdk.Artifact, error) {
 	// Create the driver that we'll use to communicate with UTM
-	driver, err := utmcommon.NewDriver()
+	driver, err := utmcommon.NewDriver(&b.config.DriverConfig)
 	if err != nil {
 		return nil, fmt.Errorf("failed creating UTM driver: %s", err)
 	}
 
+	// Emit progress events to events_file, if set
+	events, err := utmcommon.NewEventWriter(b.config.EventsFile)
+	if err != nil {
+		return nil, err
+	}
+	defer events.Close()
+	ui = utmcommon.NewEventUi(ui, events)
+
 	// Set up the state
 	state := new(multistep.BasicStateBag)
 	state.Put("config", &b.config)
@@ -47,9 +84,13 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 	state.Put("driver", driver)
 	state.Put("hook", hook)
 	state.Put("ui", ui)
+	if events != nil {
+		state.Put("events", events)
+	}
 
 	// Build the steps
 	steps := []multistep.Step{
+		new(utmcommon.StepPreflight),
 		&commonsteps.StepOutputDir{
 			Force: b.config.PackerForce,
 			Path:  b.config.OutputDir,
@@ -59,17 +100,12 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 			DebugKeyPath: fmt.Sprintf("%s.pem", b.config.PackerBuildName),
 			Comm:         &b.config.Comm,
 		},
-		&utmcommon.StepUtmDownload{
-			Checksum:    b.config.Checksum,
-			Description: "UTM",
-			Extension:   "utm",
-			ResultKey:   "vm_path",
-			TargetPath:  b.config.TargetPath,
-			Url:         []string{b.config.SourcePath},
-		},
-		&StepImport{
-			Name:           b.config.VMName,
-			KeepRegistered: b.config.KeepRegistered,
+	}
+	steps = append(steps, b.sourceSteps()...)
+	steps = append(steps, []multistep.Step{
+		&utmcommon.StepConfigureNetworkAdapters{
+			Adapters:            b.config.NetworkAdapters,
+			CommunicatorAdapter: b.config.CommunicatorAdapter,
 		},
 		&utmcommon.StepPortForwarding{
 			CommConfig:     &b.config.Comm,
@@ -77,7 +113,17 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 			HostPortMax:    b.config.HostPortMax,
 			SkipNatMapping: b.config.SkipNatMapping,
 		},
-		&utmcommon.StepRun{},
+		&utmcommon.StepRun{
+			KeepRunning:    b.config.KeepRunning,
+			KeepRegistered: b.config.KeepRegistered,
+			StartTimeout:   b.config.StartTimeout,
+			BootWait:       b.config.BootWait,
+		},
+		&utmcommon.StepWaitForInstall{
+			Timeout:  b.config.InstallTimeout,
+			Host:     utmcommon.CommHost(b.config.Comm.Host()),
+			CommType: b.config.Comm.Type,
+		},
 		&communicator.StepConnect{
 			Config:    &b.config.Comm,
 			Host:      utmcommon.CommHost(b.config.Comm.Host()),
@@ -85,10 +131,31 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 			SSHPort:   utmcommon.CommPort,
 			WinRMPort: utmcommon.CommPort,
 		},
+		&utmcommon.StepDetectGuestOS{
+			CommType: b.config.Comm.Type,
+		},
+		&utmcommon.StepWaitForNetwork{
+			Enabled:  b.config.WaitForNetwork,
+			Timeout:  b.config.WaitForNetworkTimeout,
+			CommType: b.config.Comm.Type,
+		},
 		&utmcommon.StepUploadVersion{
 			Path: *b.config.UtmVersionFile,
 		},
 		new(commonsteps.StepProvision),
+		&utmcommon.StepTestResume{
+			Enabled:  b.config.TestResume,
+			Commands: b.config.TestResumeCommands,
+			Timeout:  b.config.TestResumeTimeout,
+			CommType: b.config.Comm.Type,
+		},
+		&utmcommon.StepSetGuestHostname{
+			Hostname: b.config.GuestHostname,
+		},
+		&utmcommon.StepAddAuthorizedKeys{
+			Keys: b.config.SSHAuthorizedKeys,
+			User: b.config.SSHAuthorizedKeysUser,
+		},
 		&commonsteps.StepCleanupTempKeys{
 			Comm: &b.config.Comm,
 		},
@@ -97,6 +164,13 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 			Timeout:         b.config.ShutdownTimeout,
 			Delay:           b.config.PostShutdownDelay,
 			DisableShutdown: b.config.DisableShutdown,
+			KeepRunning:     b.config.KeepRunning,
+			Suspend:         b.config.SuspendBeforeExport,
+		},
+		&utmcommon.StepCheckExportSpace{
+			OutputDir:  b.config.OutputDir,
+			SkipExport: b.config.SkipExport,
+			MarginMB:   *b.config.ExportSpaceMargin,
 		},
 		&utmcommon.StepExport{
 			Format:         b.config.Format,
@@ -105,9 +179,22 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 			SkipNatMapping: b.config.SkipNatMapping,
 			SkipExport:     b.config.SkipExport,
 		},
-	}
+		&utmcommon.StepResetNVRAM{
+			Enabled:    b.config.ResetNVRAM,
+			SkipExport: b.config.SkipExport,
+		},
+		&utmcommon.StepCopyBundleFiles{
+			Files:      b.config.ExtraBundleFiles,
+			SkipExport: b.config.SkipExport,
+		},
+		&utmcommon.StepKeepRunning{
+			Enabled:      b.config.KeepRunning,
+			StartTimeout: b.config.StartTimeout,
+		},
+	}...)
 
 	// Run the steps.
+	steps = utmcommon.WithStepEvents(steps, events)
 	b.runner = commonsteps.NewRunnerWithPauseFn(steps, b.config.PackerConfig, ui, state)
 	b.runner.Run(ctx, state)
 
@@ -125,6 +212,14 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 		return nil, errors.New("build was halted")
 	}
 
-	generatedData := map[string]interface{}{"generated_data": state.Get("generated_data")}
-	return utmcommon.NewArtifact(b.config.OutputDir, b.config.VMName, generatedData)
+	vmId, err := utmcommon.GetVMID(state)
+	if err != nil {
+		return nil, err
+	}
+
+	generatedData := utmcommon.ArtifactStateData(state)
+	if b.config.KeepRunning {
+		return utmcommon.NewRunningArtifact(vmId, b.config.VMName, generatedData), nil
+	}
+	return utmcommon.NewArtifact(b.config.OutputDir, vmId, b.config.VMName, generatedData)
 }
@@ -21,9 +21,19 @@ type Config struct {
 	// TODO: Use run config to fill remote connection details
 	// like VRDP for VirtualBox, VNC for UTM (QEMU) ?
 	// RunConfig           `mapstructure:",squash"`
-	utmcommon.CommConfig       `mapstructure:",squash"`
-	utmcommon.ShutdownConfig   `mapstructure:",squash"`
-	utmcommon.UtmVersionConfig `mapstructure:",squash"`
+	utmcommon.CommConfig              `mapstructure:",squash"`
+	utmcommon.ShutdownConfig          `mapstructure:",squash"`
+	utmcommon.StartConfig             `mapstructure:",squash"`
+	utmcommon.BootWaitConfig          `mapstructure:",squash"`
+	utmcommon.UtmVersionConfig        `mapstructure:",squash"`
+	utmcommon.DriverConfig            `mapstructure:",squash"`
+	utmcommon.GuestHostnameConfig     `mapstructure:",squash"`
+	utmcommon.SSHAuthorizedKeysConfig `mapstructure:",squash"`
+	utmcommon.NetworkWaitConfig       `mapstructure:",squash"`
+	utmcommon.TestResumeConfig        `mapstructure:",squash"`
+	utmcommon.NetworkAdaptersConfig   `mapstructure:",squash"`
+	utmcommon.EventsConfig            `mapstructure:",squash"`
+	utmcommon.CloneConfig             `mapstructure:",squash"`
 	// The checksum for the source_path file. The type of the checksum is
 	// specified within the checksum field as a prefix, ex: "md5:{$checksum}".
 	// The type of the checksum can also be omitted and Packer will try to
@@ -46,16 +56,16 @@ type Config struct {
 	// corruption does happen from time to time.
 	Checksum string `mapstructure:"checksum" required:"true"`
 	// The filepath or URL to a UTM file that acts as the
-	// source of this build.
+	// source of this build. Required unless `source_vm` is set.
 	SourcePath string `mapstructure:"source_path" required:"true"`
 	// The path where the UTM file should be saved
 	// after download. By default, it will go in the packer cache, with a hash of
 	// the original filename as its name.
 	TargetPath string `mapstructure:"target_path" required:"false"`
 	// This is the name of the UTM file for the new virtual machine, without
-	// the file extension. Make sure VMName in UTM after import is same
-	// as the UTM file name, By default this is packer-BUILDNAME,
-	// where "BUILDNAME" is the name of the build.
+	// the file extension. The imported or cloned VM is renamed to it, since
+	// it otherwise keeps the name of its source. By default this is
+	// packer-BUILDNAME, where "BUILDNAME" is the name of the build.
 	VMName string `mapstructure:"vm_name" required:"false"`
 	// Set this to true if you would like to keep
 	// the VM registered with UTM. Defaults to false.
@@ -64,6 +74,12 @@ type Config struct {
 	// not export the VM. Useful if the build output is not the resultant image,
 	// but created inside the VM.
 	SkipExport bool `mapstructure:"skip_export" required:"false"`
+	// Set this to true to leave the VM running and registered with UTM once
+	// provisioning is done, for example to keep using it as a development
+	// environment. This implies `skip_export` and `keep_registered`. ISOs
+	// attached during the build are still detached, which requires a restart
+	// of the VM when there are any. Defaults to false.
+	KeepRunning bool `mapstructure:"keep_running" required:"false"`
 
 	ctx interpolate.Context
 }
@@ -84,6 +100,11 @@ func (c *Config) Prepare(raws ...interface{}) ([]string, error) {
 	}
 
 	// Defaults
+	if c.KeepRunning {
+		c.SkipExport = true
+		c.KeepRegistered = true
+	}
+
 	if c.VMName == "" {
 		c.VMName = fmt.Sprintf(
 			"packer-%s-%d", c.PackerBuildName, interpolate.InitTime.Unix())
@@ -91,16 +112,35 @@ func (c *Config) Prepare(raws ...interface{}) ([]string, error) {
 
 	// Prepare the errors
 	var errs *packersdk.MultiError
+	if err := utmcommon.ValidateVMName(c.VMName); err != nil {
+		errs = packersdk.MultiErrorAppend(errs, err)
+	}
 	errs = packersdk.MultiErrorAppend(errs, c.ExportConfig.Prepare(&c.ctx)...)
-	errs = packersdk.MultiErrorAppend(errs, c.ExportConfig.Prepare(&c.ctx)...)
+	// The firmware of the source VM is only known once it is imported, so a
+	// VM without an NVRAM store is left as it is.
+	errs = packersdk.MultiErrorAppend(errs, c.ExportConfig.PrepareNVRAM(true, c.SuspendBeforeExport)...)
 	errs = packersdk.MultiErrorAppend(errs, c.OutputConfig.Prepare(&c.ctx, &c.PackerConfig)...)
 	// errs = packersdk.MultiErrorAppend(errs, c.RunConfig.Prepare(&c.ctx)...)
 	errs = packersdk.MultiErrorAppend(errs, c.ShutdownConfig.Prepare(&c.ctx)...)
+	errs = packersdk.MultiErrorAppend(errs, c.ShutdownConfig.PrepareSuspend(c.SkipExport, c.KeepRunning)...)
+	errs = packersdk.MultiErrorAppend(errs, c.ExportConfig.PrepareExtraBundleFiles(c.SkipExport, c.KeepRunning)...)
+	errs = packersdk.MultiErrorAppend(errs, c.StartConfig.Prepare()...)
+	errs = packersdk.MultiErrorAppend(errs, c.GuestHostnameConfig.Prepare(c.Comm.Type)...)
+	errs = packersdk.MultiErrorAppend(errs, c.SSHAuthorizedKeysConfig.Prepare(c.Comm.Type, c.Comm.SSHUsername)...)
+	errs = packersdk.MultiErrorAppend(errs, c.NetworkWaitConfig.Prepare(c.Comm.Type)...)
+	errs = packersdk.MultiErrorAppend(errs, c.TestResumeConfig.Prepare(c.Comm.Type)...)
+	errs = packersdk.MultiErrorAppend(errs, c.BootWaitConfig.Prepare(&c.ctx)...)
 	errs = packersdk.MultiErrorAppend(errs, c.CommConfig.Prepare(&c.ctx)...)
+	errs = packersdk.MultiErrorAppend(errs, c.NetworkAdaptersConfig.Prepare(c.Comm.Type, c.SkipNatMapping)...)
 	errs = packersdk.MultiErrorAppend(errs, c.UtmVersionConfig.Prepare(c.Comm.Type)...)
+	errs = packersdk.MultiErrorAppend(errs, c.DriverConfig.Prepare()...)
+	errs = packersdk.MultiErrorAppend(errs, c.CloneConfig.Prepare(c.SkipExport)...)
 
-	if c.SourcePath == "" {
+	switch {
+	case c.SourcePath == "" && c.SourceVM == "":
 		errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("source_path is required"))
+	case c.SourcePath != "" && c.SourceVM != "":
+		errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("only one of source_path or source_vm may be set"))
 	}
 
 	// Warnings
@@ -5,6 +5,7 @@ package utm
 
 import (
 	"os"
+	"strings"
 	"testing"
 )
 
@@ -84,3 +85,62 @@ func TestNewConfig_shutdown_timeout(t *testing.T) {
 		t.Fatalf("bad: %s", err)
 	}
 }
+
+func TestNewConfig_allErrors(t *testing.T) {
+	cfg := testConfig(t)
+	cfg["format"] = "ova"
+	cfg["install_timeout"] = "-1s"
+	cfg["osascript_path"] = "/nonexistent/osascript"
+
+	var c Config
+	_, err := c.Prepare(cfg)
+	if err == nil {
+		t.Fatal("should error")
+	}
+
+	// Every invalid field is reported at once, each under its name.
+	for _, field := range []string{"format", "install_timeout", "osascript_path"} {
+		if !strings.Contains(err.Error(), field) {
+			t.Errorf("should report %s: %s", field, err)
+		}
+	}
+}
+
+func TestNewConfig_sourceVM(t *testing.T) {
+	cfg := testConfig(t)
+	delete(cfg, "source_path")
+	cfg["source_vm"] = "A1B2C3D4-0000-0000-0000-000000000000"
+	var c Config
+	if _, err := c.Prepare(cfg); err != nil {
+		t.Fatalf("bad: %s", err)
+	}
+	if c.Linked() {
+		t.Fatalf("should default to a full clone: %q", c.CloneType)
+	}
+
+	cfg["source_path"] = "config_test.go"
+	c = Config{}
+	if _, err := c.Prepare(cfg); err == nil || !strings.Contains(err.Error(), "only one of") {
+		t.Fatalf("should reject source_path with source_vm: %v", err)
+	}
+}
+
+func TestNewConfig_linkedClone(t *testing.T) {
+	cfg := testConfig(t)
+	delete(cfg, "source_path")
+	cfg["source_vm"] = "A1B2C3D4-0000-0000-0000-000000000000"
+	cfg["clone_type"] = "linked"
+	var c Config
+	if _, err := c.Prepare(cfg); err == nil || !strings.Contains(err.Error(), "can't be exported") {
+		t.Fatalf("should reject exporting a linked clone: %v", err)
+	}
+
+	cfg["skip_export"] = true
+	c = Config{}
+	if _, err := c.Prepare(cfg); err != nil {
+		t.Fatalf("bad: %s", err)
+	}
+	if !c.Linked() {
+		t.Fatal("should be a linked clone")
+	}
+}
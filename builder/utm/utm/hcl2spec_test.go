@@ -0,0 +1,42 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package utm
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/naveenrajm7/packer-plugin-utm/builder/utm/common/hcl2spectest"
+)
+
+func TestHCL2Spec_fields(t *testing.T) {
+	for _, name := range hcl2spectest.MissingFields(Config{}, new(FlatConfig).HCL2Spec()) {
+		t.Errorf("%s is missing from the generated hcl2spec, run `make generate`", name)
+	}
+}
+
+func TestHCL2Spec_decode(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "source.utm")
+	if err := os.WriteFile(source, nil, 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	value := hcl2spectest.Decode(t, new(FlatConfig).HCL2Spec(), `
+source_path      = "`+source+`"
+output_directory = "`+filepath.Join(dir, "output")+`"
+ssh_username     = "packer"
+shutdown_command = "sudo poweroff"
+boot_wait        = "30s"
+`)
+
+	var c Config
+	if _, err := c.Prepare(value); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if c.BootWait.String() != "30s" {
+		t.Fatalf("bad boot_wait: %s", c.BootWait)
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package utm
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	utmcommon "github.com/naveenrajm7/packer-plugin-utm/builder/utm/common"
+)
+
+// This step clones a VM registered with UTM into the VM of the build.
+type StepClone struct {
+	SourceVM       string
+	Name           string
+	Linked         bool
+	KeepRegistered bool
+
+	vmId string
+}
+
+func (s *StepClone) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	driver := state.Get("driver").(utmcommon.Driver)
+	ui := state.Get("ui").(packersdk.Ui)
+
+	cloneType := utmcommon.CloneTypeFull
+	if s.Linked {
+		cloneType = utmcommon.CloneTypeLinked
+	}
+	ui.Say(fmt.Sprintf("Cloning VM %s (%s clone)...", s.SourceVM, cloneType))
+
+	vmId, err := driver.Clone(s.SourceVM, s.Name, s.Linked)
+	// A VM that was duplicated but not linked is still deleted in Cleanup.
+	s.vmId = vmId
+	if err != nil {
+		err := fmt.Errorf("error cloning VM: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	state.Put(utmcommon.StateVMID, s.vmId)
+
+	// UTM may keep the name of the source VM for the clone.
+	if err := driver.RenameVM(vmId, s.Name); err != nil {
+		err := fmt.Errorf("error setting VM name: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	state.Put(utmcommon.StateVMName, s.Name)
+
+	return multistep.ActionContinue
+}
+
+func (s *StepClone) Cleanup(state multistep.StateBag) {
+	if s.vmId == "" {
+		return
+	}
+
+	driver := state.Get("driver").(utmcommon.Driver)
+	ui := state.Get("ui").(packersdk.Ui)
+
+	_, cancelled := state.GetOk(multistep.StateCancelled)
+	_, halted := state.GetOk(multistep.StateHalted)
+	if s.KeepRegistered && !cancelled && !halted {
+		ui.Say("Keeping virtual machine registered with UTM host (keep_registered = true)")
+		return
+	}
+
+	ui.Say("Deregistering and deleting cloned VM...")
+	if err := driver.Delete(s.vmId); err != nil {
+		ui.Error(fmt.Sprintf("Error deleting VM: %s", err))
+	}
+}
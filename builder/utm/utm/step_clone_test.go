@@ -0,0 +1,63 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package utm
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	utmcommon "github.com/naveenrajm7/packer-plugin-utm/builder/utm/common"
+)
+
+func TestStepClone_impl(t *testing.T) {
+	var _ multistep.Step = new(StepClone)
+}
+
+func TestStepClone(t *testing.T) {
+	for _, linked := range []bool{false, true} {
+		state := testState(t)
+		driver := state.Get("driver").(*utmcommon.DriverMock)
+		driver.CloneId = "clone-id"
+
+		step := &StepClone{SourceVM: "source-id", Name: "packer-clone", Linked: linked}
+		if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+			t.Fatalf("bad action: %#v", action)
+		}
+		if !reflect.DeepEqual(driver.CloneCalls, [][]string{{"source-id", "packer-clone"}}) || driver.CloneLinked != linked {
+			t.Fatalf("bad clone: %#v %t", driver.CloneCalls, driver.CloneLinked)
+		}
+		if !reflect.DeepEqual(driver.RenameVMCalls, [][]string{{"clone-id", "packer-clone"}}) {
+			t.Fatalf("should rename the clone: %#v", driver.RenameVMCalls)
+		}
+		if state.Get("vmId") != "clone-id" || state.Get("vmName") != "packer-clone" {
+			t.Fatalf("bad state: %#v %#v", state.Get("vmId"), state.Get("vmName"))
+		}
+
+		step.Cleanup(state)
+		if driver.DeleteName != "clone-id" {
+			t.Fatalf("should delete the clone: %q", driver.DeleteName)
+		}
+	}
+}
+
+func TestStepClone_linkFailure(t *testing.T) {
+	state := testState(t)
+	driver := state.Get("driver").(*utmcommon.DriverMock)
+	driver.CloneId = "clone-id"
+	driver.CloneErr = errors.New("linked clones need qemu-img")
+
+	step := &StepClone{SourceVM: "source-id", Name: "packer-clone", Linked: true, KeepRegistered: true}
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	state.Put(multistep.StateHalted, true)
+
+	step.Cleanup(state)
+	if driver.DeleteName != "clone-id" {
+		t.Fatalf("should delete the half-made clone: %q", driver.DeleteName)
+	}
+}
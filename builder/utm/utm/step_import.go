@@ -38,17 +38,17 @@ func (s *StepImport) Run(ctx context.Context, state multistep.StateBag) multiste
 		return multistep.ActionHalt
 	}
 	s.vmId = vmId
-	state.Put("vmId", s.vmId)
+	state.Put(utmcommon.StateVMID, s.vmId)
 
-	// set VM name
-	if _, err = driver.ExecuteOsaScript("customize_vm.applescript", vmId, "--name", s.Name); err != nil {
+	// The imported VM keeps the name it was exported with.
+	if err = driver.RenameVM(vmId, s.Name); err != nil {
 		err := fmt.Errorf("error setting VM name: %s", err)
 		state.Put("error", err)
 		ui.Error(err.Error())
 		return multistep.ActionHalt
 	}
 	s.vmName = s.Name
-	state.Put("vmName", s.Name)
+	state.Put(utmcommon.StateVMName, s.Name)
 
 	return multistep.ActionContinue
 }
@@ -39,6 +39,9 @@ func TestStepImport(t *testing.T) {
 	if driver.ImportId != step.vmId {
 		t.Fatalf("bad: %#v", driver.ImportId)
 	}
+	if len(driver.RenameVMCalls) != 1 || driver.RenameVMCalls[0][1] != "bar" {
+		t.Fatalf("should rename the imported VM: %#v", driver.RenameVMCalls)
+	}
 
 	// Test output state
 	if name, ok := state.GetOk("vmName"); !ok {
@@ -12,6 +12,10 @@ import (
 	"github.com/naveenrajm7/packer-plugin-utm/builder/utm/cloud"
 	"github.com/naveenrajm7/packer-plugin-utm/builder/utm/iso"
 	"github.com/naveenrajm7/packer-plugin-utm/builder/utm/utm"
+	utmPPchecksum "github.com/naveenrajm7/packer-plugin-utm/post-processor/checksum"
+	utmPPmanifest "github.com/naveenrajm7/packer-plugin-utm/post-processor/manifest"
+	utmPPtodisk "github.com/naveenrajm7/packer-plugin-utm/post-processor/todisk"
+	utmPPupload "github.com/naveenrajm7/packer-plugin-utm/post-processor/upload"
 	utmPPvagrant "github.com/naveenrajm7/packer-plugin-utm/post-processor/vagrant"
 	utmPPzip "github.com/naveenrajm7/packer-plugin-utm/post-processor/zip"
 	"github.com/naveenrajm7/packer-plugin-utm/version"
@@ -24,6 +28,10 @@ func main() {
 	pps.RegisterBuilder("cloud", new(cloud.Builder))
 	pps.RegisterPostProcessor("zip", new(utmPPzip.PostProcessor))
 	pps.RegisterPostProcessor("vagrant", new(utmPPvagrant.PostProcessor))
+	pps.RegisterPostProcessor("manifest", new(utmPPmanifest.PostProcessor))
+	pps.RegisterPostProcessor("to-disk", new(utmPPtodisk.PostProcessor))
+	pps.RegisterPostProcessor("upload", new(utmPPupload.PostProcessor))
+	pps.RegisterPostProcessor("checksum", new(utmPPchecksum.PostProcessor))
 	pps.SetVersion(version.PluginVersion)
 	err := pps.Run()
 	if err != nil {
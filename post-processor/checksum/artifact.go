@@ -0,0 +1,55 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package checksum
+
+import (
+	"fmt"
+	"os"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// Artifact is the result of running the UTM checksum post-processor,
+// namely the files of the input artifact followed by their checksum files.
+type Artifact struct {
+	// The artifact the checksums are of.
+	Input packersdk.Artifact
+	// The checksum files, in the order of the files of the input.
+	Sidecars []string
+}
+
+// BuilderId answers with the BuilderId of the input artifact, so that the
+// post-processors taking the artifacts of the UTM builders can follow.
+func (a *Artifact) BuilderId() string {
+	return a.Input.BuilderId()
+}
+
+func (a *Artifact) Id() string {
+	return a.Input.Id()
+}
+
+func (a *Artifact) Files() []string {
+	return append(append([]string{}, a.Input.Files()...), a.Sidecars...)
+}
+
+func (a *Artifact) String() string {
+	return fmt.Sprintf("%s, with %d checksum file(s)", a.Input.String(), len(a.Sidecars))
+}
+
+// State answers with the state of the input artifact, so that later
+// post-processors still see its VM.
+func (a *Artifact) State(name string) interface{} {
+	return a.Input.State(name)
+}
+
+// Destroy removes the checksum files; the input artifact is destroyed on
+// its own.
+func (a *Artifact) Destroy() error {
+	for _, sidecar := range a.Sidecars {
+		if err := os.Remove(sidecar); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
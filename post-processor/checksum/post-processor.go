@@ -0,0 +1,160 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:generate packer-sdc struct-markdown
+//go:generate packer-sdc mapstructure-to-hcl2 -type Config
+
+package checksum
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/hashicorp/packer-plugin-sdk/common"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/template/config"
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+)
+
+// Algorithms maps the algorithms the post-processor hashes with to their
+// constructor.
+var Algorithms = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+// DefaultAlgorithm is the algorithm used when algorithm is unset.
+const DefaultAlgorithm = "sha256"
+
+type Config struct {
+	common.PackerConfig `mapstructure:",squash"`
+
+	// The hash algorithm: `md5`, `sha1`, `sha256` or `sha512`. The checksum of
+	// each file is written next to it, to the file name followed by the
+	// algorithm, such as `debian.box.sha256`. The files of a `.utm` bundle
+	// share one checksum file next to the bundle, such as `debian.utm.sha256`.
+	// Defaults to `sha256`.
+	Algorithm string `mapstructure:"algorithm" required:"false"`
+
+	ctx interpolate.Context
+}
+
+// PostProcessor implements packersdk.PostProcessor
+// Writes a checksum file next to each file, or UTM bundle, of an artifact.
+type PostProcessor struct {
+	config Config
+}
+
+func (p *PostProcessor) ConfigSpec() hcldec.ObjectSpec { return p.config.FlatMapstructure().HCL2Spec() }
+
+func (p *PostProcessor) Configure(raws ...interface{}) error {
+	err := config.Decode(&p.config, &config.DecodeOpts{
+		PluginType:         "checksum",
+		Interpolate:        true,
+		InterpolateContext: &p.config.ctx,
+	}, raws...)
+	if err != nil {
+		return err
+	}
+
+	p.config.Algorithm = strings.ToLower(p.config.Algorithm)
+	if p.config.Algorithm == "" {
+		p.config.Algorithm = DefaultAlgorithm
+	}
+	if _, ok := Algorithms[p.config.Algorithm]; !ok {
+		names := make([]string, 0, len(Algorithms))
+		for name := range Algorithms {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("algorithm must be one of %s, got %q",
+			strings.Join(names, ", "), p.config.Algorithm)
+	}
+
+	return nil
+}
+
+func (p *PostProcessor) PostProcess(
+	ctx context.Context,
+	ui packersdk.Ui,
+	artifact packersdk.Artifact,
+) (packersdk.Artifact, bool, bool, error) {
+	newHash := Algorithms[p.config.Algorithm]
+
+	// The files of a UTM bundle share one checksum file next to the
+	// bundle, so that the bundle itself is left as UTM wrote it.
+	var sidecars []string
+	lines := make(map[string][]string)
+	for _, file := range artifact.Files() {
+		if err := ctx.Err(); err != nil {
+			return nil, false, false, err
+		}
+
+		sum, err := fileChecksum(file, newHash())
+		if err != nil {
+			return nil, false, false, fmt.Errorf("error hashing %s: %s", file, err)
+		}
+		ui.Say(fmt.Sprintf("%s %s: %s", p.config.Algorithm, file, sum))
+
+		// Name the file relative to the checksum file, like sha256sum does.
+		name := filepath.Base(file)
+		sidecar := file + "." + p.config.Algorithm
+		if bundle := bundlePath(file); bundle != "" {
+			name, _ = filepath.Rel(filepath.Dir(bundle), file)
+			sidecar = bundle + "." + p.config.Algorithm
+		}
+		if _, ok := lines[sidecar]; !ok {
+			sidecars = append(sidecars, sidecar)
+		}
+		lines[sidecar] = append(lines[sidecar], fmt.Sprintf("%s  %s\n", sum, filepath.ToSlash(name)))
+	}
+
+	for _, sidecar := range sidecars {
+		if err := os.WriteFile(sidecar, []byte(strings.Join(lines[sidecar], "")), 0644); err != nil {
+			return nil, false, false, fmt.Errorf("error writing %s: %s", sidecar, err)
+		}
+	}
+
+	// The new artifact lists the files of the input, so the input must not
+	// be deleted.
+	return &Artifact{Input: artifact, Sidecars: sidecars}, true, true, nil
+}
+
+// bundlePath returns the .utm bundle file is in, or an empty string.
+func bundlePath(file string) string {
+	sep := string(filepath.Separator)
+	i := strings.Index(file, ".utm"+sep)
+	if i < 0 {
+		return ""
+	}
+	return file[:i+len(".utm")]
+}
+
+// fileChecksum streams the file at path through h, and returns the sum in
+// hex.
+func fileChecksum(path string, h hash.Hash) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = f.Close() }()
+
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
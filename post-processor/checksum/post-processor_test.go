@@ -0,0 +1,137 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package checksum
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+func testUi() *packersdk.BasicUi {
+	return &packersdk.BasicUi{
+		Reader: new(bytes.Buffer),
+		Writer: new(bytes.Buffer),
+	}
+}
+
+func TestPostProcessor_ImplementsPostProcessor(t *testing.T) {
+	var _ packersdk.PostProcessor = new(PostProcessor)
+}
+
+func TestPostProcessorConfigure(t *testing.T) {
+	var p PostProcessor
+	if err := p.Configure(map[string]interface{}{}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if p.config.Algorithm != DefaultAlgorithm {
+		t.Fatalf("bad default algorithm: %s", p.config.Algorithm)
+	}
+
+	if err := new(PostProcessor).Configure(map[string]interface{}{"algorithm": "SHA512"}); err != nil {
+		t.Fatalf("should take an upper case algorithm: %s", err)
+	}
+	if err := new(PostProcessor).Configure(map[string]interface{}{"algorithm": "crc32"}); err == nil {
+		t.Fatal("should reject an unknown algorithm")
+	}
+}
+
+func TestPostProcessor_PostProcess(t *testing.T) {
+	dir := t.TempDir()
+	box := filepath.Join(dir, "debian.box")
+	if err := os.WriteFile(box, []byte("hello"), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	input := &packersdk.MockArtifact{FilesValue: []string{box}}
+
+	var p PostProcessor
+	if err := p.Configure(map[string]interface{}{}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	a, keep, force, err := p.PostProcess(context.Background(), testUi(), input)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !keep || !force {
+		t.Fatalf("should keep the input artifact: %t %t", keep, force)
+	}
+
+	sidecar := box + ".sha256"
+	if !reflect.DeepEqual(a.Files(), []string{box, sidecar}) {
+		t.Fatalf("bad files: %#v", a.Files())
+	}
+	data, err := os.ReadFile(sidecar)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	expected := "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824  debian.box\n"
+	if string(data) != expected {
+		t.Fatalf("bad checksum file: %q", data)
+	}
+
+	if err := a.Destroy(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, err := os.Stat(sidecar); !os.IsNotExist(err) {
+		t.Fatal("should remove the checksum file")
+	}
+	if _, err := os.Stat(box); err != nil {
+		t.Fatal("should leave the input alone")
+	}
+}
+
+func TestPostProcessor_PostProcessBundle(t *testing.T) {
+	dir := t.TempDir()
+	bundle := filepath.Join(dir, "debian.utm")
+	if err := os.MkdirAll(filepath.Join(bundle, "Data"), 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	plist := filepath.Join(bundle, "config.plist")
+	disk := filepath.Join(bundle, "Data", "disk.qcow2")
+	for _, file := range []string{plist, disk} {
+		if err := os.WriteFile(file, []byte("hello"), 0644); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+	input := &packersdk.MockArtifact{BuilderIdValue: "naveenrajm7.utm", FilesValue: []string{plist, disk}}
+
+	var p PostProcessor
+	if err := p.Configure(map[string]interface{}{}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	a, _, _, err := p.PostProcess(context.Background(), testUi(), input)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if a.BuilderId() != input.BuilderId() {
+		t.Fatalf("should keep the BuilderId of the input: %s", a.BuilderId())
+	}
+
+	// One checksum file next to the bundle, nothing inside it
+	sidecar := bundle + ".sha256"
+	if !reflect.DeepEqual(a.Files(), []string{plist, disk, sidecar}) {
+		t.Fatalf("bad files: %#v", a.Files())
+	}
+	data, err := os.ReadFile(sidecar)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	sum := "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+	expected := sum + "  debian.utm/config.plist\n" + sum + "  debian.utm/Data/disk.qcow2\n"
+	if string(data) != expected {
+		t.Fatalf("bad checksum file: %q", data)
+	}
+	entries, err := os.ReadDir(bundle)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("should leave the bundle alone: %v", entries)
+	}
+}
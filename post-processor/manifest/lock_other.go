@@ -0,0 +1,11 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:build !darwin && !linux
+
+package manifest
+
+// lockFile is a no-op on this platform; UTM only runs on macOS.
+func lockFile(path string) (func(), error) {
+	return func() {}, nil
+}
@@ -0,0 +1,179 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:generate packer-sdc struct-markdown
+//go:generate packer-sdc mapstructure-to-hcl2 -type Config
+
+package manifest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/hashicorp/packer-plugin-sdk/common"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/template/config"
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+	utmcommon "github.com/naveenrajm7/packer-plugin-utm/builder/utm/common"
+)
+
+// DefaultOutput is the manifest file written when output is unset.
+const DefaultOutput = "utm-manifest.json"
+
+type Config struct {
+	common.PackerConfig `mapstructure:",squash"`
+
+	// The manifest file to write. Every build using this post-processor in a
+	// `packer build` run adds its VM to the same file. Defaults to
+	// `utm-manifest.json` in the current directory.
+	OutputPath string `mapstructure:"output" required:"false"`
+
+	ctx interpolate.Context
+}
+
+// Build is the manifest entry of a single build.
+type Build struct {
+	// The name of the build block or source.
+	Name string `json:"name"`
+	// The builder type, such as utm-iso.
+	BuilderType string `json:"builder_type"`
+	// When the entry was written, in seconds since the Unix epoch.
+	BuildTime int64 `json:"build_time"`
+	// The UTM ID (UUID) of the VM.
+	VMID string `json:"vm_id"`
+	// The name of the VM.
+	VMName string `json:"vm_name"`
+	// The exported .utm file, empty when the VM is kept running.
+	ArtifactPath string `json:"artifact_path,omitempty"`
+	// The files of the artifact.
+	Files []string `json:"files"`
+	// The PACKER_RUN_UUID of the packer build run that made the VM.
+	PackerRunUUID string `json:"packer_run_uuid"`
+}
+
+// Manifest is the content of the manifest file.
+type Manifest struct {
+	Builds      []Build `json:"builds"`
+	LastRunUUID string  `json:"last_run_uuid"`
+}
+
+// PostProcessor implements packersdk.PostProcessor
+// Records the VM of each build of a packer build run in a JSON manifest.
+type PostProcessor struct {
+	config Config
+}
+
+func (p *PostProcessor) ConfigSpec() hcldec.ObjectSpec { return p.config.FlatMapstructure().HCL2Spec() }
+
+func (p *PostProcessor) Configure(raws ...interface{}) error {
+	err := config.Decode(&p.config, &config.DecodeOpts{
+		PluginType:         "manifest",
+		Interpolate:        true,
+		InterpolateContext: &p.config.ctx,
+	}, raws...)
+	if err != nil {
+		return err
+	}
+
+	if p.config.OutputPath == "" {
+		p.config.OutputPath = DefaultOutput
+	}
+
+	return nil
+}
+
+func (p *PostProcessor) PostProcess(
+	ctx context.Context,
+	ui packersdk.Ui,
+	artifact packersdk.Artifact,
+) (packersdk.Artifact, bool, bool, error) {
+	if artifact.BuilderId() != utmcommon.BuilderId {
+		return nil, false, false, fmt.Errorf(
+			"unknown artifact type %q, can only record artifacts of the UTM builders", artifact.BuilderId())
+	}
+
+	build := Build{
+		Name:          p.config.PackerBuildName,
+		BuilderType:   p.config.PackerBuilderType,
+		BuildTime:     time.Now().Unix(),
+		VMID:          stateString(artifact, utmcommon.ArtifactStateVMID),
+		VMName:        stateString(artifact, utmcommon.ArtifactStateVMName),
+		ArtifactPath:  stateString(artifact, utmcommon.ArtifactStateExportPath),
+		Files:         artifact.Files(),
+		PackerRunUUID: os.Getenv("PACKER_RUN_UUID"),
+	}
+	if build.Files == nil {
+		build.Files = []string{}
+	}
+
+	ui.Say(fmt.Sprintf("Recording VM %s in manifest %s", build.VMName, p.config.OutputPath))
+	if err := addBuild(p.config.OutputPath, build); err != nil {
+		return nil, false, false, fmt.Errorf("error writing manifest: %s", err)
+	}
+
+	return artifact, true, true, nil
+}
+
+func stateString(artifact packersdk.Artifact, name string) string {
+	value, _ := artifact.State(name).(string)
+	return value
+}
+
+// addBuild adds build to the manifest at path. Entries of other runs are
+// dropped, so that the manifest lists what the latest run produced, and an
+// entry of the same build in this run is replaced. Builds of a run finish
+// concurrently, so the manifest is locked while it is rewritten.
+func addBuild(path string, build Build) error {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+
+	unlock, err := lockFile(path + ".lock")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	var manifest Manifest
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return err
+	default:
+		if err := json.Unmarshal(data, &manifest); err != nil {
+			return fmt.Errorf("%s is not a manifest: %s", path, err)
+		}
+	}
+
+	builds := []Build{build}
+	for _, b := range manifest.Builds {
+		if b.PackerRunUUID != build.PackerRunUUID || b.Name == build.Name {
+			continue
+		}
+		builds = append(builds, b)
+	}
+	manifest = Manifest{Builds: builds, LastRunUUID: build.PackerRunUUID}
+
+	data, err = json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Write next to the manifest and rename, so that readers never see a
+	// partial file.
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,149 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package manifest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	utmcommon "github.com/naveenrajm7/packer-plugin-utm/builder/utm/common"
+)
+
+func testUi() *packersdk.BasicUi {
+	return &packersdk.BasicUi{
+		Reader: new(bytes.Buffer),
+		Writer: new(bytes.Buffer),
+	}
+}
+
+func testArtifact(t *testing.T, vmId string, vmName string) packersdk.Artifact {
+	dir := t.TempDir()
+	exportPath := filepath.Join(dir, vmName+".utm")
+	if err := os.WriteFile(exportPath, []byte("utm"), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	state := new(multistep.BasicStateBag)
+	state.Put("exportPath", exportPath)
+	a, err := utmcommon.NewArtifact(dir, vmId, vmName, utmcommon.ArtifactStateData(state))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	return a
+}
+
+func testPP(t *testing.T, output string, buildName string) *PostProcessor {
+	var p PostProcessor
+	err := p.Configure(map[string]interface{}{
+		"output":              output,
+		"packer_build_name":   buildName,
+		"packer_builder_type": "utm-iso",
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	return &p
+}
+
+func readManifest(t *testing.T, path string) Manifest {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	return m
+}
+
+func TestPostProcessor_ImplementsPostProcessor(t *testing.T) {
+	var _ packersdk.PostProcessor = new(PostProcessor)
+}
+
+func TestPostProcessorConfigure_defaultOutput(t *testing.T) {
+	var p PostProcessor
+	if err := p.Configure(map[string]interface{}{}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if p.config.OutputPath != DefaultOutput {
+		t.Fatalf("bad output: %s", p.config.OutputPath)
+	}
+}
+
+func TestPostProcessorPostProcess(t *testing.T) {
+	t.Setenv("PACKER_RUN_UUID", "run-1")
+	output := filepath.Join(t.TempDir(), "out", "manifest.json")
+
+	for _, build := range []struct{ name, vmId string }{
+		{"debian", "vm-1"},
+		{"fedora", "vm-2"},
+		{"debian", "vm-3"},
+	} {
+		artifact := testArtifact(t, build.vmId, build.name)
+		result, keep, force, err := testPP(t, output, build.name).PostProcess(context.Background(), testUi(), artifact)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if result != artifact || !keep || !force {
+			t.Fatalf("should keep the input artifact: %#v %t %t", result, keep, force)
+		}
+	}
+
+	m := readManifest(t, output)
+	if m.LastRunUUID != "run-1" || len(m.Builds) != 2 {
+		t.Fatalf("bad manifest: %#v", m)
+	}
+	debian := m.Builds[0]
+	if debian.Name != "debian" || debian.VMID != "vm-3" || debian.VMName != "debian" || debian.BuilderType != "utm-iso" {
+		t.Fatalf("should replace the entry of a rebuilt build: %#v", debian)
+	}
+	if filepath.Base(debian.ArtifactPath) != "debian.utm" || len(debian.Files) != 1 {
+		t.Fatalf("bad artifact path: %#v", debian)
+	}
+	if m.Builds[1].VMID != "vm-2" {
+		t.Fatalf("should keep the other build: %#v", m.Builds[1])
+	}
+
+	t.Setenv("PACKER_RUN_UUID", "run-2")
+	if _, _, _, err := testPP(t, output, "fedora").PostProcess(
+		context.Background(), testUi(), testArtifact(t, "vm-4", "fedora")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	m = readManifest(t, output)
+	if m.LastRunUUID != "run-2" || len(m.Builds) != 1 || m.Builds[0].VMID != "vm-4" {
+		t.Fatalf("should drop the builds of the previous run: %#v", m)
+	}
+}
+
+func TestPostProcessorPostProcess_runningArtifact(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "manifest.json")
+	artifact := utmcommon.NewRunningArtifact("vm-1", "debian", nil)
+
+	if _, _, _, err := testPP(t, output, "debian").PostProcess(context.Background(), testUi(), artifact); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	m := readManifest(t, output)
+	if len(m.Builds) != 1 || m.Builds[0].ArtifactPath != "" || m.Builds[0].Files == nil {
+		t.Fatalf("bad manifest: %#v", m)
+	}
+}
+
+func TestPostProcessorPostProcess_badArtifact(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "manifest.json")
+	artifact := &packersdk.MockArtifact{BuilderIdValue: "packer.other"}
+
+	if _, _, _, err := testPP(t, output, "debian").PostProcess(context.Background(), testUi(), artifact); err == nil {
+		t.Fatal("should reject artifacts of other builders")
+	}
+	if _, err := os.Stat(output); !os.IsNotExist(err) {
+		t.Fatalf("should not write the manifest: %v", err)
+	}
+}
@@ -0,0 +1,49 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package todisk
+
+import (
+	"fmt"
+	"os"
+)
+
+const BuilderId = "naveenrajm7.utm.post-processor.to-disk"
+
+// ArtifactStateFormat is the name the artifact answers State with the
+// format of the disk image for.
+const ArtifactStateFormat = "disk_format"
+
+// Artifact is the result of running the UTM to-disk post-processor,
+// namely the disk image of the VM, outside of its .utm bundle.
+type Artifact struct {
+	Path   string
+	Format string
+}
+
+func (a *Artifact) BuilderId() string {
+	return BuilderId
+}
+
+func (*Artifact) Id() string {
+	return ""
+}
+
+func (a *Artifact) Files() []string {
+	return []string{a.Path}
+}
+
+func (a *Artifact) String() string {
+	return fmt.Sprintf("%s disk image: %s", a.Format, a.Path)
+}
+
+func (a *Artifact) State(name string) interface{} {
+	if name == ArtifactStateFormat {
+		return a.Format
+	}
+	return nil
+}
+
+func (a *Artifact) Destroy() error {
+	return os.Remove(a.Path)
+}
@@ -0,0 +1,272 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:generate packer-sdc struct-markdown
+//go:generate packer-sdc mapstructure-to-hcl2 -type Config
+
+package todisk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/hashicorp/packer-plugin-sdk/common"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/template/config"
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+	utmcommon "github.com/naveenrajm7/packer-plugin-utm/builder/utm/common"
+)
+
+// The disk image formats the post-processor writes, named like the
+// qemu-img output formats.
+var Formats = []string{"qcow2", "raw", "vmdk", "vdi", "vhdx"}
+
+// DefaultFormat is the format used when format is unset.
+const DefaultFormat = "qcow2"
+
+type Config struct {
+	common.PackerConfig `mapstructure:",squash"`
+
+	// The format of the disk image to write: `qcow2`, `raw`, `vmdk`, `vdi`
+	// or `vhdx`. A disk already in this format, `qcow2` for QEMU VMs and
+	// `raw` for Apple Virtualization VMs, is copied as it is; any other is
+	// converted with `qemu-img`, which must be on the `PATH`. Defaults to
+	// `qcow2`.
+	Format string `mapstructure:"format" required:"false"`
+	// The path of the disk image to write. It can use `{{.BuildName}}`,
+	// `{{.BuilderType}}` and `{{.Format}}`. Defaults to
+	// `packer_{{.BuildName}}_{{.BuilderType}}.{{.Format}}`.
+	OutputPath string `mapstructure:"output" required:"false"`
+	// The file name of the disk to extract, in the `Data` directory of the
+	// bundle, such as `A1B2C3D4-E5F6-4A7B-8C9D-0E1F2A3B4C5D.qcow2`. Only
+	// needed when the VM has several disks. By default the only disk of
+	// the bundle, `.qcow2` or `.img`, is extracted.
+	Disk string `mapstructure:"disk" required:"false"`
+
+	ctx interpolate.Context
+}
+
+// PostProcessor implements packersdk.PostProcessor
+// Extracts the disk of the exported UTM VM, converting it to another
+// format if requested.
+type PostProcessor struct {
+	config Config
+}
+
+func (p *PostProcessor) ConfigSpec() hcldec.ObjectSpec { return p.config.FlatMapstructure().HCL2Spec() }
+
+func (p *PostProcessor) Configure(raws ...interface{}) error {
+	err := config.Decode(&p.config, &config.DecodeOpts{
+		PluginType:         "to-disk",
+		Interpolate:        true,
+		InterpolateContext: &p.config.ctx,
+		InterpolateFilter: &interpolate.RenderFilter{
+			Exclude: []string{"output"},
+		},
+	}, raws...)
+	if err != nil {
+		return err
+	}
+
+	errs := new(packersdk.MultiError)
+
+	if p.config.Format == "" {
+		p.config.Format = DefaultFormat
+	}
+	if !validFormat(p.config.Format) {
+		errs = packersdk.MultiErrorAppend(errs, fmt.Errorf(
+			"format must be one of %s, got %q", strings.Join(Formats, ", "), p.config.Format))
+	}
+
+	if p.config.OutputPath == "" {
+		p.config.OutputPath = "packer_{{.BuildName}}_{{.BuilderType}}.{{.Format}}"
+	}
+	if err = interpolate.Validate(p.config.OutputPath, &p.config.ctx); err != nil {
+		errs = packersdk.MultiErrorAppend(
+			errs, fmt.Errorf("error parsing target template: %s", err))
+	}
+
+	if p.config.Disk != "" && filepath.Base(p.config.Disk) != p.config.Disk {
+		errs = packersdk.MultiErrorAppend(errs, fmt.Errorf(
+			"disk must be a file name in the Data directory of the bundle, got %q", p.config.Disk))
+	}
+
+	if len(errs.Errors) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+func validFormat(format string) bool {
+	for _, f := range Formats {
+		if format == f {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *PostProcessor) PostProcess(
+	ctx context.Context,
+	ui packersdk.Ui,
+	artifact packersdk.Artifact,
+) (packersdk.Artifact, bool, bool, error) {
+	if artifact.BuilderId() != utmcommon.BuilderId {
+		return nil, false, false, fmt.Errorf(
+			"unknown artifact type %q, can only extract disks of the UTM builders", artifact.BuilderId())
+	}
+
+	bundle, _ := artifact.State(utmcommon.ArtifactStateExportPath).(string)
+	if bundle == "" {
+		return nil, false, false, errors.New(
+			"the build did not export the VM, there is no disk to extract with keep_running")
+	}
+
+	p.config.ctx.Data = map[string]string{
+		"BuildName":   p.config.PackerBuildName,
+		"BuilderType": p.config.PackerBuilderType,
+		"Format":      p.config.Format,
+	}
+	target, err := interpolate.Render(p.config.OutputPath, &p.config.ctx)
+	if err != nil {
+		return nil, false, false, fmt.Errorf("error interpolating output value: %s", err)
+	}
+
+	disk, err := findDisk(bundle, p.config.Disk)
+	if err != nil {
+		return nil, false, false, err
+	}
+
+	if dir := filepath.Dir(target); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, false, false, err
+		}
+	}
+
+	ui.Say(fmt.Sprintf("Extracting %s as a %s disk image to %s", filepath.Base(disk), p.config.Format, target))
+	if err := extractDisk(disk, target, p.config.Format); err != nil {
+		_ = os.Remove(target)
+		return nil, false, false, fmt.Errorf("error extracting disk: %s", err)
+	}
+
+	return &Artifact{Path: target, Format: p.config.Format}, false, false, nil
+}
+
+// diskExtensions maps the extensions of the disks UTM keeps in the Data
+// directory of a bundle to their format: qcow2 for QEMU VMs, raw .img for
+// Apple Virtualization VMs.
+var diskExtensions = map[string]string{
+	".qcow2": utmcommon.DiskFormatQcow2,
+	".img":   utmcommon.DiskFormatRaw,
+}
+
+// diskFormat returns the format of disk, from its extension.
+func diskFormat(disk string) (string, error) {
+	format, ok := diskExtensions[strings.ToLower(filepath.Ext(disk))]
+	if !ok {
+		return "", fmt.Errorf("unknown format of disk %s, expected a .qcow2 or .img disk", filepath.Base(disk))
+	}
+	return format, nil
+}
+
+// findDisk returns the disk named name in the Data directory of bundle, or
+// its only disk when name is empty.
+func findDisk(bundle string, name string) (string, error) {
+	dataDir := filepath.Join(bundle, "Data")
+	if name != "" {
+		disk := filepath.Join(dataDir, name)
+		if _, err := os.Stat(disk); err != nil {
+			return "", fmt.Errorf("disk %s not found in %s", name, bundle)
+		}
+		return disk, nil
+	}
+
+	entries, err := os.ReadDir(dataDir)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return "", err
+	}
+	var disks []string
+	for _, entry := range entries {
+		if _, err := diskFormat(entry.Name()); err == nil && !entry.IsDir() {
+			disks = append(disks, filepath.Join(dataDir, entry.Name()))
+		}
+	}
+	switch len(disks) {
+	case 0:
+		return "", fmt.Errorf("%s has no disk", bundle)
+	case 1:
+		return disks[0], nil
+	}
+	names := make([]string, 0, len(disks))
+	for _, disk := range disks {
+		names = append(names, filepath.Base(disk))
+	}
+	return "", fmt.Errorf("%s has %d disks, set disk to one of %s",
+		bundle, len(disks), strings.Join(names, ", "))
+}
+
+// extractDisk writes disk to target in format. A standalone disk already in
+// format is copied as it is; other formats, and disks of linked clones
+// backed by another image, are written by qemu-img.
+func extractDisk(disk string, target string, format string) error {
+	source, err := diskFormat(disk)
+	if err != nil {
+		return err
+	}
+	if source == utmcommon.DiskFormatRaw && format == source {
+		return copyFile(disk, target)
+	}
+	if source == utmcommon.DiskFormatQcow2 && format == source {
+		header, err := utmcommon.ReadQcow2Header(disk)
+		if err != nil {
+			return err
+		}
+		if header.BackingFile == "" {
+			return copyFile(disk, target)
+		}
+	}
+
+	qemuImg, err := exec.LookPath("qemu-img")
+	if err != nil {
+		return fmt.Errorf("writing a %s image needs qemu-img: %s", format, err)
+	}
+	output, err := exec.Command(qemuImg, convertArgs(disk, target, source, format)...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("qemu-img convert failed: %s: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// convertArgs returns the qemu-img arguments converting disk, in source
+// format, to target in format. The output of qemu-img is only shown when it
+// fails, so it isn't asked for progress.
+func convertArgs(disk string, target string, source string, format string) []string {
+	return []string{"convert", "-f", source, "-O", format, disk, target}
+}
+
+func copyFile(src string, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = in.Close() }()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,233 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package todisk
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	utmcommon "github.com/naveenrajm7/packer-plugin-utm/builder/utm/common"
+)
+
+func testUi() *packersdk.BasicUi {
+	return &packersdk.BasicUi{
+		Reader: new(bytes.Buffer),
+		Writer: new(bytes.Buffer),
+	}
+}
+
+// writeTestQcow2 writes a minimal standalone qcow2 header.
+func writeTestQcow2(t *testing.T, path string) []byte {
+	t.Helper()
+
+	header := make([]byte, 72)
+	copy(header, "QFI\xfb")
+	binary.BigEndian.PutUint32(header[4:], 3)
+	if err := os.WriteFile(path, header, 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	return header
+}
+
+// testBundle returns an exported .utm bundle with the given disks.
+func testBundle(t *testing.T, disks ...string) string {
+	bundle := filepath.Join(t.TempDir(), "vm.utm")
+	if err := os.MkdirAll(filepath.Join(bundle, "Data"), 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	for _, disk := range disks {
+		writeTestQcow2(t, filepath.Join(bundle, "Data", disk))
+	}
+	return bundle
+}
+
+func testArtifact(t *testing.T, bundle string) packersdk.Artifact {
+	state := new(multistep.BasicStateBag)
+	if bundle != "" {
+		state.Put("exportPath", bundle)
+	}
+	a, err := utmcommon.NewArtifact(t.TempDir(), "vm-id", "vm", utmcommon.ArtifactStateData(state))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	return a
+}
+
+func testConfig() map[string]interface{} {
+	return map[string]interface{}{
+		"packer_build_name":   "debian",
+		"packer_builder_type": "utm-utm",
+	}
+}
+
+func TestPostProcessor_ImplementsPostProcessor(t *testing.T) {
+	var _ packersdk.PostProcessor = new(PostProcessor)
+}
+
+func TestPostProcessorConfigure(t *testing.T) {
+	var p PostProcessor
+	if err := p.Configure(testConfig()); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if p.config.Format != DefaultFormat {
+		t.Fatalf("bad default format: %s", p.config.Format)
+	}
+
+	c := testConfig()
+	c["format"] = "iso"
+	if err := new(PostProcessor).Configure(c); err == nil {
+		t.Fatal("should reject an unknown format")
+	}
+
+	c = testConfig()
+	c["disk"] = "../other.qcow2"
+	if err := new(PostProcessor).Configure(c); err == nil {
+		t.Fatal("should reject a disk outside of the bundle")
+	}
+}
+
+func TestPostProcessor_copy(t *testing.T) {
+	bundle := testBundle(t, "disk.qcow2")
+	output := filepath.Join(t.TempDir(), "out", "{{.BuildName}}.{{.Format}}")
+
+	c := testConfig()
+	c["output"] = output
+	var p PostProcessor
+	if err := p.Configure(c); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	a, keep, force, err := p.PostProcess(context.Background(), testUi(), testArtifact(t, bundle))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if keep || force {
+		t.Fatalf("should not keep the input artifact: %t %t", keep, force)
+	}
+
+	expected := filepath.Join(filepath.Dir(output), "debian.qcow2")
+	if !reflect.DeepEqual(a.Files(), []string{expected}) {
+		t.Fatalf("bad files: %#v", a.Files())
+	}
+	if a.State(ArtifactStateFormat) != "qcow2" {
+		t.Fatalf("bad format: %#v", a.State(ArtifactStateFormat))
+	}
+	data, err := os.ReadFile(expected)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !bytes.HasPrefix(data, []byte("QFI\xfb")) {
+		t.Fatal("should copy the disk")
+	}
+}
+
+func TestPostProcessor_notExported(t *testing.T) {
+	var p PostProcessor
+	if err := p.Configure(testConfig()); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	_, _, _, err := p.PostProcess(context.Background(), testUi(), testArtifact(t, ""))
+	if err == nil || !strings.Contains(err.Error(), "keep_running") {
+		t.Fatalf("should fail without an exported VM: %v", err)
+	}
+}
+
+func TestFindDisk(t *testing.T) {
+	bundle := testBundle(t, "disk.qcow2")
+	disk, err := findDisk(bundle, "")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if filepath.Base(disk) != "disk.qcow2" {
+		t.Fatalf("bad disk: %s", disk)
+	}
+
+	bundle = testBundle(t, "a.qcow2", "b.qcow2")
+	if _, err := findDisk(bundle, ""); err == nil || !strings.Contains(err.Error(), "a.qcow2, b.qcow2") {
+		t.Fatalf("should list the disks to pick from: %v", err)
+	}
+	disk, err = findDisk(bundle, "b.qcow2")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if filepath.Base(disk) != "b.qcow2" {
+		t.Fatalf("bad disk: %s", disk)
+	}
+	if _, err := findDisk(bundle, "c.qcow2"); err == nil {
+		t.Fatal("should fail on a missing disk")
+	}
+
+	bundle = testBundle(t, "disk.qcow2")
+	if err := os.WriteFile(filepath.Join(bundle, "Data", "efi_vars.fd"), nil, 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := os.WriteFile(filepath.Join(bundle, "Data", "disk2.img"), nil, 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, err := findDisk(bundle, ""); err == nil || !strings.Contains(err.Error(), "disk.qcow2, disk2.img") {
+		t.Fatalf("should find raw disks too: %v", err)
+	}
+
+	if _, err := findDisk(testBundle(t), ""); err == nil {
+		t.Fatal("should fail without a disk")
+	}
+}
+
+func TestPostProcessor_raw(t *testing.T) {
+	// Apple Virtualization VMs keep raw .img disks.
+	bundle := filepath.Join(t.TempDir(), "vm.utm")
+	if err := os.MkdirAll(filepath.Join(bundle, "Data"), 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := os.WriteFile(filepath.Join(bundle, "Data", "disk.img"), []byte("raw disk"), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	output := filepath.Join(t.TempDir(), "{{.BuildName}}.{{.Format}}")
+
+	c := testConfig()
+	c["format"] = "raw"
+	c["output"] = output
+	var p PostProcessor
+	if err := p.Configure(c); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	a, _, _, err := p.PostProcess(context.Background(), testUi(), testArtifact(t, bundle))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	data, err := os.ReadFile(a.Files()[0])
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if string(data) != "raw disk" {
+		t.Fatalf("should copy the raw disk: %q", data)
+	}
+}
+
+func TestDiskFormat(t *testing.T) {
+	for disk, expected := range map[string]string{"a.qcow2": "qcow2", "b.img": "raw", "c.IMG": "raw"} {
+		if format, err := diskFormat(disk); err != nil || format != expected {
+			t.Fatalf("%s: bad format %q: %v", disk, format, err)
+		}
+	}
+	if _, err := diskFormat("config.plist"); err == nil {
+		t.Fatal("should reject a file that isn't a disk")
+	}
+}
+
+func TestConvertArgs(t *testing.T) {
+	args := convertArgs("disk.qcow2", "out.img", "qcow2", "raw")
+	expected := []string{"convert", "-f", "qcow2", "-O", "raw", "disk.qcow2", "out.img"}
+	if !reflect.DeepEqual(args, expected) {
+		t.Fatalf("bad args: %#v", args)
+	}
+}
@@ -0,0 +1,45 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package upload
+
+import (
+	"fmt"
+	"strings"
+)
+
+const BuilderId = "naveenrajm7.utm.post-processor.upload"
+
+// Artifact is the result of running the UTM upload post-processor, namely
+// the files of the input artifact on the remote host.
+type Artifact struct {
+	Host string
+	// The remote paths of the uploaded files.
+	Paths []string
+}
+
+func (a *Artifact) BuilderId() string {
+	return BuilderId
+}
+
+func (a *Artifact) Id() string {
+	return a.Host
+}
+
+func (a *Artifact) Files() []string {
+	return a.Paths
+}
+
+func (a *Artifact) String() string {
+	return fmt.Sprintf("uploaded to %s: %s", a.Host, strings.Join(a.Paths, ", "))
+}
+
+func (*Artifact) State(name string) interface{} {
+	return nil
+}
+
+// Destroy leaves the uploaded files alone, they are not local files of the
+// build.
+func (a *Artifact) Destroy() error {
+	return nil
+}
@@ -0,0 +1,313 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:generate packer-sdc struct-markdown
+//go:generate packer-sdc mapstructure-to-hcl2 -type Config
+
+package upload
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/hashicorp/packer-plugin-sdk/common"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/pathing"
+	"github.com/hashicorp/packer-plugin-sdk/template/config"
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+type Config struct {
+	common.PackerConfig `mapstructure:",squash"`
+
+	// The host to upload the artifact to.
+	Host string `mapstructure:"host" required:"true"`
+	// The SSH port of the host. Defaults to 22.
+	Port int `mapstructure:"port" required:"false"`
+	// The user to log in as.
+	Username string `mapstructure:"username" required:"true"`
+	// The password to log in with. Either this or `private_key_file` must be
+	// set.
+	Password string `mapstructure:"password" required:"false"`
+	// The private key to log in with, in PEM or OpenSSH format. Encrypted
+	// keys are not supported.
+	PrivateKeyFile string `mapstructure:"private_key_file" required:"false"`
+	// The known_hosts file the key of the host is checked against. Defaults
+	// to `~/.ssh/known_hosts`.
+	KnownHostsFile string `mapstructure:"known_hosts_file" required:"false"`
+	// Set this to true to not check the key of the host. Only use this on
+	// trusted networks. Off by default.
+	InsecureSkipHostKeyCheck bool `mapstructure:"insecure_skip_host_key_check" required:"false"`
+	// The directory on the host to upload the artifact into. It is created
+	// if needed, and can use `{{.BuildName}}` and `{{.BuilderType}}`. A
+	// `.utm` bundle keeps its layout under it.
+	Path string `mapstructure:"path" required:"true"`
+	// How long to wait for the SSH connection. Defaults to 30s.
+	Timeout time.Duration `mapstructure:"timeout" required:"false"`
+
+	ctx interpolate.Context
+}
+
+// PostProcessor implements packersdk.PostProcessor
+// Uploads the files of an artifact to a host over SFTP.
+type PostProcessor struct {
+	config Config
+}
+
+func (p *PostProcessor) ConfigSpec() hcldec.ObjectSpec { return p.config.FlatMapstructure().HCL2Spec() }
+
+func (p *PostProcessor) Configure(raws ...interface{}) error {
+	err := config.Decode(&p.config, &config.DecodeOpts{
+		PluginType:         "upload",
+		Interpolate:        true,
+		InterpolateContext: &p.config.ctx,
+		InterpolateFilter: &interpolate.RenderFilter{
+			Exclude: []string{"path"},
+		},
+	}, raws...)
+	if err != nil {
+		return err
+	}
+
+	errs := new(packersdk.MultiError)
+
+	if p.config.Host == "" {
+		errs = packersdk.MultiErrorAppend(errs, errors.New("host must be specified"))
+	}
+	if p.config.Port == 0 {
+		p.config.Port = 22
+	}
+	if p.config.Port < 1 || p.config.Port > 65535 {
+		errs = packersdk.MultiErrorAppend(errs, fmt.Errorf(
+			"port must be between 1 and 65535, got %d", p.config.Port))
+	}
+	if p.config.Username == "" {
+		errs = packersdk.MultiErrorAppend(errs, errors.New("username must be specified"))
+	}
+
+	for _, file := range []*string{&p.config.PrivateKeyFile, &p.config.KnownHostsFile} {
+		if *file == "" {
+			continue
+		}
+		expanded, err := pathing.ExpandUser(*file)
+		if err != nil {
+			errs = packersdk.MultiErrorAppend(errs, err)
+			continue
+		}
+		*file = expanded
+	}
+
+	switch {
+	case p.config.Password == "" && p.config.PrivateKeyFile == "":
+		errs = packersdk.MultiErrorAppend(errs, errors.New("one of password or private_key_file must be specified"))
+	case p.config.PrivateKeyFile != "":
+		if _, err := signerFromFile(p.config.PrivateKeyFile); err != nil {
+			errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("private_key_file: %s", err))
+		}
+	}
+
+	if !p.config.InsecureSkipHostKeyCheck {
+		if p.config.KnownHostsFile == "" {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("known_hosts_file: %s", err))
+			}
+			p.config.KnownHostsFile = filepath.Join(home, ".ssh", "known_hosts")
+		}
+		if _, err := knownhosts.New(p.config.KnownHostsFile); err != nil {
+			errs = packersdk.MultiErrorAppend(errs, fmt.Errorf(
+				"known_hosts_file: %s, set insecure_skip_host_key_check to not check the host key", err))
+		}
+	}
+
+	if p.config.Path == "" {
+		errs = packersdk.MultiErrorAppend(errs, errors.New("path must be specified"))
+	} else if err := interpolate.Validate(p.config.Path, &p.config.ctx); err != nil {
+		errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("error parsing path template: %s", err))
+	}
+
+	if p.config.Timeout == 0 {
+		p.config.Timeout = 30 * time.Second
+	}
+	if p.config.Timeout < 0 {
+		errs = packersdk.MultiErrorAppend(errs, fmt.Errorf(
+			"timeout must not be negative, got %s", p.config.Timeout))
+	}
+
+	if len(errs.Errors) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+func signerFromFile(path string) (ssh.Signer, error) {
+	key, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ssh.ParsePrivateKey(key)
+}
+
+// clientConfig returns the SSH configuration to log in to the host with.
+func (p *PostProcessor) clientConfig() (*ssh.ClientConfig, error) {
+	var auth []ssh.AuthMethod
+	if p.config.PrivateKeyFile != "" {
+		signer, err := signerFromFile(p.config.PrivateKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading private_key_file: %s", err)
+		}
+		auth = append(auth, ssh.PublicKeys(signer))
+	}
+	if p.config.Password != "" {
+		auth = append(auth, ssh.Password(p.config.Password))
+	}
+
+	hostKeyCallback := ssh.InsecureIgnoreHostKey()
+	if !p.config.InsecureSkipHostKeyCheck {
+		callback, err := knownhosts.New(p.config.KnownHostsFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading known_hosts_file: %s", err)
+		}
+		hostKeyCallback = callback
+	}
+
+	return &ssh.ClientConfig{
+		User:            p.config.Username,
+		Auth:            auth,
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         p.config.Timeout,
+	}, nil
+}
+
+func (p *PostProcessor) PostProcess(
+	ctx context.Context,
+	ui packersdk.Ui,
+	artifact packersdk.Artifact,
+) (packersdk.Artifact, bool, bool, error) {
+	files := artifact.Files()
+	if len(files) == 0 {
+		return nil, false, false, errors.New("the artifact has no files to upload")
+	}
+
+	p.config.ctx.Data = map[string]string{
+		"BuildName":   p.config.PackerBuildName,
+		"BuilderType": p.config.PackerBuilderType,
+	}
+	remoteDir, err := interpolate.Render(p.config.Path, &p.config.ctx)
+	if err != nil {
+		return nil, false, false, fmt.Errorf("error interpolating path: %s", err)
+	}
+
+	sshConfig, err := p.clientConfig()
+	if err != nil {
+		return nil, false, false, err
+	}
+	addr := net.JoinHostPort(p.config.Host, strconv.Itoa(p.config.Port))
+	ui.Say(fmt.Sprintf("Connecting to %s as %s", addr, p.config.Username))
+	conn, err := ssh.Dial("tcp", addr, sshConfig)
+	if err != nil {
+		return nil, false, false, fmt.Errorf("error connecting to %s: %s", addr, err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	client, err := sftp.NewClient(conn)
+	if err != nil {
+		return nil, false, false, fmt.Errorf("error starting SFTP on %s: %s", addr, err)
+	}
+	defer func() { _ = client.Close() }()
+
+	paths, err := uploadFiles(ctx, client, ui, files, remoteDir)
+	if err != nil {
+		return nil, false, false, err
+	}
+
+	ui.Say(fmt.Sprintf("Uploaded %d file(s) to %s:%s", len(paths), p.config.Host, remoteDir))
+	// The local artifact is kept unless keep_input_artifact is set to
+	// false, since uploading does not replace it.
+	return &Artifact{Host: p.config.Host, Paths: paths}, true, false, nil
+}
+
+// uploadFiles uploads files into remoteDir, keeping their layout relative
+// to the directory they have in common, and returns their remote paths.
+func uploadFiles(ctx context.Context, client *sftp.Client, ui packersdk.Ui, files []string, remoteDir string) ([]string, error) {
+	base := commonDir(files)
+	paths := make([]string, 0, len(files))
+	for _, file := range files {
+		if err := ctx.Err(); err != nil {
+			return paths, err
+		}
+
+		rel, err := filepath.Rel(base, file)
+		if err != nil {
+			return paths, err
+		}
+		remote := path.Join(remoteDir, filepath.ToSlash(rel))
+		if err := uploadFile(client, ui, file, remote); err != nil {
+			return paths, fmt.Errorf("error uploading %s: %s", file, err)
+		}
+		paths = append(paths, remote)
+	}
+	return paths, nil
+}
+
+func uploadFile(client *sftp.Client, ui packersdk.Ui, local string, remote string) error {
+	in, err := os.Open(local)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = in.Close() }()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+
+	if err := client.MkdirAll(path.Dir(remote)); err != nil {
+		return err
+	}
+	out, err := client.Create(remote)
+	if err != nil {
+		return err
+	}
+
+	stream := ui.TrackProgress(filepath.Base(local), 0, info.Size(), in)
+	defer func() { _ = stream.Close() }()
+	if _, err := io.Copy(out, stream); err != nil {
+		_ = out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// commonDir returns the deepest directory holding all files, or the
+// directory of the .utm bundle they are in, so that the bundle is uploaded
+// as a whole.
+func commonDir(files []string) string {
+	base := filepath.Dir(filepath.Clean(files[0]))
+	for _, file := range files[1:] {
+		for !strings.HasPrefix(filepath.Clean(file), base+string(filepath.Separator)) {
+			parent := filepath.Dir(base)
+			if parent == base {
+				return base
+			}
+			base = parent
+		}
+	}
+	if idx := strings.Index(base+string(filepath.Separator), ".utm"+string(filepath.Separator)); idx != -1 {
+		return filepath.Dir(base[:idx+len(".utm")])
+	}
+	return base
+}
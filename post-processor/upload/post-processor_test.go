@@ -0,0 +1,192 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package upload
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+func testUi() *packersdk.BasicUi {
+	return &packersdk.BasicUi{
+		Reader: new(bytes.Buffer),
+		Writer: new(bytes.Buffer),
+		PB:     &packersdk.NoopProgressTracker{},
+	}
+}
+
+func testConfig() map[string]interface{} {
+	return map[string]interface{}{
+		"host":                         "artifacts.example.com",
+		"username":                     "packer",
+		"password":                     "packer",
+		"path":                         "/srv/images/{{.BuildName}}",
+		"insecure_skip_host_key_check": true,
+	}
+}
+
+func testPrivateKey(t *testing.T) string {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	block, err := ssh.MarshalPrivateKey(key, "")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	path := filepath.Join(t.TempDir(), "id_ed25519")
+	if err := os.WriteFile(path, pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	return path
+}
+
+func TestPostProcessor_ImplementsPostProcessor(t *testing.T) {
+	var _ packersdk.PostProcessor = new(PostProcessor)
+}
+
+func TestPostProcessorConfigure(t *testing.T) {
+	var p PostProcessor
+	if err := p.Configure(testConfig()); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if p.config.Port != 22 {
+		t.Fatalf("bad default port: %d", p.config.Port)
+	}
+
+	c := testConfig()
+	delete(c, "password")
+	c["private_key_file"] = testPrivateKey(t)
+	if err := new(PostProcessor).Configure(c); err != nil {
+		t.Fatalf("should take a private key: %s", err)
+	}
+
+	knownHosts := filepath.Join(t.TempDir(), "known_hosts")
+	if err := os.WriteFile(knownHosts, nil, 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	c = testConfig()
+	delete(c, "insecure_skip_host_key_check")
+	c["known_hosts_file"] = knownHosts
+	if err := new(PostProcessor).Configure(c); err != nil {
+		t.Fatalf("should take a known_hosts file: %s", err)
+	}
+}
+
+func TestPostProcessorConfigure_bad(t *testing.T) {
+	cases := map[string]func(map[string]interface{}){
+		"no host":     func(c map[string]interface{}) { delete(c, "host") },
+		"no username": func(c map[string]interface{}) { delete(c, "username") },
+		"no path":     func(c map[string]interface{}) { delete(c, "path") },
+		"bad port":    func(c map[string]interface{}) { c["port"] = 70000 },
+		"no auth":     func(c map[string]interface{}) { delete(c, "password") },
+		"bad key": func(c map[string]interface{}) {
+			c["private_key_file"] = filepath.Join(os.TempDir(), "does-not-exist")
+		},
+		"no known_hosts": func(c map[string]interface{}) {
+			delete(c, "insecure_skip_host_key_check")
+			c["known_hosts_file"] = filepath.Join(os.TempDir(), "does-not-exist")
+		},
+	}
+	for name, modify := range cases {
+		c := testConfig()
+		modify(c)
+		if err := new(PostProcessor).Configure(c); err == nil {
+			t.Fatalf("%s: should have an error", name)
+		}
+	}
+}
+
+func TestCommonDir(t *testing.T) {
+	cases := []struct {
+		files    []string
+		expected string
+	}{
+		{[]string{"out/box.box"}, "out"},
+		{[]string{"out/a/x", "out/b/y"}, "out"},
+		{[]string{"out/vm.utm/config.plist", "out/vm.utm/Data/disk.qcow2"}, "out"},
+	}
+	for _, tc := range cases {
+		if dir := commonDir(tc.files); dir != tc.expected {
+			t.Fatalf("%v: expected %s, got %s", tc.files, tc.expected, dir)
+		}
+	}
+}
+
+// testSFTPClient returns an SFTP client talking to a server on the local
+// filesystem through pipes.
+func testSFTPClient(t *testing.T) *sftp.Client {
+	serverRead, clientWrite := io.Pipe()
+	clientRead, serverWrite := io.Pipe()
+
+	server, err := sftp.NewServer(struct {
+		io.Reader
+		io.WriteCloser
+	}{serverRead, serverWrite})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	go func() { _ = server.Serve() }()
+
+	client, err := sftp.NewClientPipe(clientRead, clientWrite)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	t.Cleanup(func() {
+		_ = server.Close()
+		_ = client.Close()
+	})
+	return client
+}
+
+func TestUploadFiles(t *testing.T) {
+	bundle := filepath.Join(t.TempDir(), "vm.utm")
+	files := []string{
+		filepath.Join(bundle, "config.plist"),
+		filepath.Join(bundle, "Data", "disk.qcow2"),
+	}
+	for _, file := range files {
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if err := os.WriteFile(file, []byte(filepath.Base(file)), 0644); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+
+	remoteDir := filepath.Join(t.TempDir(), "images")
+	paths, err := uploadFiles(context.Background(), testSFTPClient(t), testUi(), files, remoteDir)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := []string{
+		filepath.Join(remoteDir, "vm.utm", "config.plist"),
+		filepath.Join(remoteDir, "vm.utm", "Data", "disk.qcow2"),
+	}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Fatalf("bad paths: %#v", paths)
+	}
+	for _, path := range expected {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if string(data) != filepath.Base(path) {
+			t.Fatalf("bad content of %s: %q", path, data)
+		}
+	}
+}
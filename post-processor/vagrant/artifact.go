@@ -6,6 +6,7 @@ package vagrant
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 )
 
 const BuilderId = "naveenrajm7.utm.post-processor.vagrant"
@@ -13,12 +14,22 @@ const BuilderId = "naveenrajm7.utm.post-processor.vagrant"
 type Artifact struct {
 	Path     string
 	Provider string
+
+	// files are the box files produced, as absolute paths so that later
+	// post-processors find them regardless of their working directory.
+	files []string
 }
 
 func NewArtifact(provider, path string) *Artifact {
+	boxPath := path
+	if abs, err := filepath.Abs(path); err == nil {
+		boxPath = abs
+	}
+
 	return &Artifact{
 		Path:     path,
 		Provider: provider,
+		files:    []string{boxPath},
 	}
 }
 
@@ -27,7 +38,10 @@ func (*Artifact) BuilderId() string {
 }
 
 func (a *Artifact) Files() []string {
-	return []string{a.Path}
+	if a.files == nil && a.Path != "" {
+		return []string{a.Path}
+	}
+	return a.files
 }
 
 func (a *Artifact) Id() string {
@@ -4,6 +4,7 @@
 package vagrant
 
 import (
+	"path/filepath"
 	"testing"
 
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
@@ -19,3 +20,19 @@ func TestArtifact_Id(t *testing.T) {
 		t.Fatalf("should return name as Id")
 	}
 }
+
+func TestArtifact_Files(t *testing.T) {
+	artifact := NewArtifact("utm", "packer_utm.box")
+
+	expected, err := filepath.Abs("packer_utm.box")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	files := artifact.Files()
+	if len(files) != 1 || files[0] != expected {
+		t.Fatalf("should return the box path: %#v", files)
+	}
+	if artifact.Id() != "utm" {
+		t.Fatalf("should return name as Id")
+	}
+}
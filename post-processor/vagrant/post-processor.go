@@ -26,10 +26,15 @@ import (
 	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
 	"github.com/hashicorp/packer-plugin-sdk/tmp"
 	"github.com/mitchellh/mapstructure"
+	utmcommon "github.com/naveenrajm7/packer-plugin-utm/builder/utm/common"
 )
 
+// artificeBuilderId is the BuilderId of artifacts wrapped by the artifice
+// post-processor, which are accepted when provider_override is set.
+const artificeBuilderId = "packer.post-processor.artifice"
+
 var builtins = map[string]string{
-	"naveenrajm7.utm": "utm",
+	utmcommon.BuilderId: "utm",
 }
 
 var vagrantArchMap = map[string]string{
@@ -211,19 +216,22 @@ func (p *PostProcessor) PostProcessProvider(name string, provider Provider, ui p
 }
 
 func (p *PostProcessor) PostProcess(ctx context.Context, ui packersdk.Ui, artifact packersdk.Artifact) (packersdk.Artifact, bool, bool, error) {
+	_, builtin := builtins[artifact.BuilderId()]
+	artifice := artifact.BuilderId() == artificeBuilderId
+	if !builtin && !artifice {
+		return nil, false, false, fmt.Errorf(
+			"unknown artifact type %q: this post-processor only supports utm artifacts",
+			artifact.BuilderId())
+	}
+
 	name := p.config.ProviderOverride
 	if name == "" {
-		n, ok := builtins[artifact.BuilderId()]
-		if !ok {
-			return nil, false, false, fmt.Errorf(
-				"unknown artifact type, can't build box: %s", artifact.BuilderId())
-		}
-		name = n
+		name = builtins[artifact.BuilderId()]
 	}
 
 	provider := providerForName(name)
 	if provider == nil {
-		if artifact.BuilderId() == "packer.post-processor.artifice" {
+		if artifice {
 			return nil, false, false, fmt.Errorf(
 				"unknown provider type: When using an artifact created by " +
 					"the artifice post-processor, you need to set the " +
@@ -234,14 +242,12 @@ func (p *PostProcessor) PostProcess(ctx context.Context, ui packersdk.Ui, artifa
 		}
 	}
 
-	artifact, keep, err := p.PostProcessProvider(name, provider, ui, artifact)
-
-	// In some cases, (e.g. AMI), deleting the input artifact would render the
-	// resulting vagrant box useless. Because of these cases, we want to
-	// forcibly set keep_input_artifact.
+	artifact, _, err := p.PostProcessProvider(name, provider, ui, artifact)
 
-	// TODO: rework all provisioners to only forcibly keep those where it matters
-	return artifact, keep, true, err
+	// Keep the input .utm bundle by default, as this post-processor always
+	// did, without forcing it: keep_input_artifact = false on the
+	// post-processor still removes it once the box holds its own copy.
+	return artifact, true, false, err
 }
 
 func (p *PostProcessor) configureSingle(c *Config, raws ...interface{}) error {
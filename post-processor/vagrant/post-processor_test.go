@@ -8,11 +8,13 @@ import (
 	"compress/flate"
 	"context"
 	"os"
+	"path/filepath"
 	"runtime"
 	"strings"
 	"testing"
 
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	utmcommon "github.com/naveenrajm7/packer-plugin-utm/builder/utm/common"
 )
 
 func testConfig() map[string]interface{} {
@@ -230,6 +232,31 @@ func TestPostProcessorPostProcess_badId(t *testing.T) {
 	}
 }
 
+func TestPostProcessorPostProcess_foreignArtifact(t *testing.T) {
+	artifact := &packersdk.MockArtifact{
+		BuilderIdValue: "mitchellh.virtualbox",
+	}
+
+	var p PostProcessor
+	if err := p.Configure(map[string]interface{}{"provider_override": "utm"}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	_, _, _, err := p.PostProcess(context.Background(), testUi(), artifact)
+	if err == nil {
+		t.Fatal("should reject artifacts from other builders")
+	}
+	if !strings.Contains(err.Error(), "only supports utm artifacts") {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+func TestBuiltins_builderId(t *testing.T) {
+	if builtins[utmcommon.BuilderId] != "utm" {
+		t.Fatalf("utm builder artifacts should map to the utm provider: %#v", builtins)
+	}
+}
+
 func TestProviderForName(t *testing.T) {
 	if v, ok := providerForName("utm").(*UtmProvider); !ok {
 		t.Fatalf("bad: %#v", v)
@@ -239,3 +266,42 @@ func TestProviderForName(t *testing.T) {
 		t.Fatal("should be nil if bad provider")
 	}
 }
+
+func TestPostProcessorPostProcess_keepInputArtifact(t *testing.T) {
+	td := t.TempDir()
+	bundle := filepath.Join(td, "vm.utm")
+	if err := os.MkdirAll(bundle, 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	configPath := filepath.Join(bundle, "config.plist")
+	if err := os.WriteFile(configPath, []byte("config"), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	artifact := &packersdk.MockArtifact{
+		BuilderIdValue: utmcommon.BuilderId,
+		FilesValue:     []string{configPath},
+	}
+
+	var p PostProcessor
+	if err := p.Configure(map[string]interface{}{
+		"output": filepath.Join(td, "vm.box"),
+	}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	box, keep, forceOverride, err := p.PostProcess(context.Background(), testUi(), artifact)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	// The bundle is kept by default, and keep_input_artifact can override it.
+	if !keep || forceOverride {
+		t.Fatalf("should keep the input artifact without forcing it: keep=%t force=%t", keep, forceOverride)
+	}
+	if _, err := os.Stat(configPath); err != nil {
+		t.Fatalf("input artifact should be preserved: %s", err)
+	}
+	if files := box.Files(); len(files) != 1 || filepath.Base(files[0]) != "vm.box" {
+		t.Fatalf("bad box files: %#v", files)
+	}
+}